@@ -1,20 +1,20 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/swagger"
 	"github.com/teguh/go-todo-api/config"
 	"github.com/teguh/go-todo-api/docs"
-	"github.com/teguh/go-todo-api/internal/app/handlers"
-	"github.com/teguh/go-todo-api/internal/database"
-	"github.com/teguh/go-todo-api/internal/middleware"
+	"github.com/teguh/go-todo-api/internal/app"
+	"google.golang.org/grpc"
 )
 
 // @title Todo API
@@ -42,86 +42,53 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	// Initialize database
-	if err := database.Initialize(cfg.DatabasePath); err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+	// Build the application
+	server, err := app.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize application: %v", err)
 	}
-	defer database.Close()
-
-	// Create Fiber app
-	app := fiber.New(fiber.Config{
-		AppName:      cfg.AppName,
-		ErrorHandler: customErrorHandler,
-	})
-
-	// Setup middleware
-	middleware.SetupMiddleware(app)
-
-	// API routes
-	api := app.Group("/api/v1")
-
-	// Register handlers
-	todoHandler := handlers.NewTodoHandler()
-	todoHandler.RegisterRoutes(api)
-
-	// Swagger documentation
-	app.Get("/swagger/*", swagger.HandlerDefault)
-
-	// Health check endpoint
-	app.Get("/health", func(c *fiber.Ctx) error {
-		return c.JSON(fiber.Map{
-			"status": "ok",
-			"time":   time.Now(),
-		})
-	})
-
-	// Root route - redirect to Swagger
-	app.Get("/", func(c *fiber.Ctx) error {
-		return c.Redirect("/swagger/", fiber.StatusMovedPermanently)
-	})
 
 	// Handle graceful shutdown
-	go handleShutdown(app)
+	stopped := make(chan struct{})
+	go handleShutdown(server.HTTP, cfg.ShutdownTimeout, stopped)
+
+	// Start the gRPC server; it is stopped by the HTTP server's shutdown hooks
+	grpcAddr := fmt.Sprintf(":%d", cfg.GRPCPort)
+	listener, err := net.Listen("tcp", grpcAddr)
+	if err != nil {
+		log.Fatalf("Failed to listen for gRPC: %v", err)
+	}
+	go func() {
+		log.Printf("Starting gRPC server on %s", grpcAddr)
+		if err := server.GRPC.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			log.Fatalf("Failed to serve gRPC: %v", err)
+		}
+	}()
 
 	// Start server
 	addr := fmt.Sprintf(":%d", cfg.AppPort)
 	log.Printf("Starting %s server on %s in %s mode", cfg.AppName, addr, cfg.Environment)
-	if err := app.Listen(addr); err != nil {
+	if err := server.HTTP.Listen(addr); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
-}
-
-// customErrorHandler handles errors thrown by Fiber
-func customErrorHandler(c *fiber.Ctx, err error) error {
-	// Default error response
-	code := fiber.StatusInternalServerError
-	message := "Internal Server Error"
-
-	// Check if it's a Fiber error
-	if e, ok := err.(*fiber.Error); ok {
-		code = e.Code
-		message = e.Message
-	} else {
-		// Log non-Fiber errors
-		log.Printf("Error: %v", err)
-	}
 
-	// Return JSON response
-	return c.Status(code).JSON(fiber.Map{
-		"success": false,
-		"message": message,
-	})
+	// Listen returns as soon as the listener closes; wait for the shutdown
+	// hooks, which flush the outbox, before exiting
+	<-stopped
 }
 
-// handleShutdown handles graceful shutdown
-func handleShutdown(app *fiber.App) {
+// handleShutdown handles graceful shutdown, closing stopped once the
+// server and its shutdown hooks are done
+func handleShutdown(app *fiber.App, timeout time.Duration, stopped chan<- struct{}) {
+	defer close(stopped)
+
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 
 	<-sigCh
 	log.Println("Shutting down server...")
 
-	if err := app.Shutdown(); err != nil {
+	if err := app.ShutdownWithTimeout(timeout); err != nil {
 		log.Fatalf("Error shutting down server: %v", err)
 	}
 
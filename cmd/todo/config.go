@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// defaultBaseURL is used when no profile names a server
+const defaultBaseURL = "http://localhost:3000"
+
+// defaultProfile is the profile used when none has been selected
+const defaultProfile = "default"
+
+// Profile holds the settings for talking to one server
+type Profile struct {
+	BaseURL string `json:"base_url"`
+	Token   string `json:"token,omitempty"`
+	User    string `json:"user,omitempty"`
+}
+
+// Config is the CLI's configuration file
+type Config struct {
+	Current  string              `json:"current,omitempty"`
+	Profiles map[string]*Profile `json:"profiles"`
+
+	path string
+}
+
+// configPath returns the location of the configuration file, which
+// TODO_CONFIG overrides
+func configPath() (string, error) {
+	if path := os.Getenv("TODO_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate the configuration directory: %w", err)
+	}
+	return filepath.Join(dir, "todo", "config.json"), nil
+}
+
+// loadConfig reads the configuration file, which need not exist
+func loadConfig() (*Config, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{
+		Profiles: map[string]*Profile{},
+		path:     path,
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = map[string]*Profile{}
+	}
+	return cfg, nil
+}
+
+// Save writes the configuration file. It may hold tokens, so only the
+// owner can read it.
+func (c *Config) Save() error {
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(c.path), err)
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(c.path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", c.path, err)
+	}
+	return nil
+}
+
+// CurrentName returns the name of the selected profile
+func (c *Config) CurrentName() string {
+	if c.Current == "" {
+		return defaultProfile
+	}
+	return c.Current
+}
+
+// Names returns the profile names in order
+func (c *Config) Names() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve returns the named profile, or the selected one if name is empty.
+// Only the default profile may be missing, in which case the local server
+// is assumed.
+func (c *Config) Resolve(name string) (*Profile, error) {
+	if name == "" {
+		name = c.CurrentName()
+	}
+	profile, ok := c.Profiles[name]
+	if !ok {
+		if name != defaultProfile {
+			return nil, fmt.Errorf("profile %q does not exist; create it with: todo profile set %s --base-url URL", name, name)
+		}
+		profile = &Profile{}
+	}
+
+	resolved := *profile
+	if resolved.BaseURL == "" {
+		resolved.BaseURL = defaultBaseURL
+	}
+	return &resolved, nil
+}
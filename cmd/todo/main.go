@@ -0,0 +1,97 @@
+// Command todo is a command-line client for the Todo API.
+//
+// Servers are addressed through profiles kept in a configuration file, so
+// switching between a local server and a deployed one is a flag away:
+//
+//	todo profile set prod --base-url https://todo.example.com --token $TOKEN
+//	todo --profile prod ls --project work
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/teguh/go-todo-api/pkg/client"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := newRootCommand().ExecuteContext(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+// cli holds the global flags shared by every command
+type cli struct {
+	profile string
+	baseURL string
+	token   string
+	user    string
+}
+
+func newRootCommand() *cobra.Command {
+	c := &cli{}
+	root := &cobra.Command{
+		Use:           "todo",
+		Short:         "Manage todos from the terminal",
+		Long:          "todo manages todos on a Todo API server. Settings come from the selected profile, overridden by flags and the TODO_BASE_URL, TODO_TOKEN and TODO_USER environment variables.",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	flags := root.PersistentFlags()
+	flags.StringVar(&c.profile, "profile", os.Getenv("TODO_PROFILE"), "profile to use instead of the current one")
+	flags.StringVar(&c.baseURL, "base-url", os.Getenv("TODO_BASE_URL"), "server URL, overriding the profile")
+	flags.StringVar(&c.token, "token", os.Getenv("TODO_TOKEN"), "bearer token, overriding the profile")
+	flags.StringVar(&c.user, "user", os.Getenv("TODO_USER"), "user to act as (X-User-ID), overriding the profile")
+	_ = root.RegisterFlagCompletionFunc("profile", completeProfiles)
+
+	root.AddCommand(
+		newAddCommand(c),
+		newListCommand(c),
+		newShowCommand(c),
+		newDoneCommand(c),
+		newEditCommand(c),
+		newRemoveCommand(c),
+		newWatchCommand(c),
+		newExportCommand(c),
+		newImportCommand(c),
+		newProfileCommand(),
+	)
+	return root
+}
+
+// client builds an API client from the selected profile and the flags
+func (c *cli) client() (*client.Client, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	profile, err := cfg.Resolve(c.profile)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.baseURL != "" {
+		profile.BaseURL = c.baseURL
+	}
+	if c.token != "" {
+		profile.Token = c.token
+	}
+	if c.user != "" {
+		profile.User = c.user
+	}
+
+	return client.New(profile.BaseURL,
+		client.WithToken(profile.Token),
+		client.WithUser(profile.User),
+		client.WithUserAgent("todo-cli"),
+	)
+}
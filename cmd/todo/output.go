@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/teguh/go-todo-api/pkg/client"
+)
+
+// Output formats
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+// outputFlag adds the --output flag to cmd and returns its value
+func outputFlag(cmd *cobra.Command) *string {
+	format := cmd.Flags().StringP("output", "o", formatTable, "output format: table or json")
+	_ = cmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions([]string{formatTable, formatJSON}, cobra.ShellCompDirectiveNoFileComp))
+	return format
+}
+
+// checkFormat rejects unknown output formats before any request is made
+func checkFormat(format string) error {
+	if format != formatTable && format != formatJSON {
+		return fmt.Errorf("unknown output format %q: must be table or json", format)
+	}
+	return nil
+}
+
+// writeJSON writes v as indented JSON
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// writeTodos writes todos as a table, one per line
+func writeTodos(w io.Writer, todos []*client.Todo) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tDONE\tPRI\tDUE\tPROJECT\tTAGS\tTITLE")
+	for _, todo := range todos {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\t%s\n",
+			todo.ID, checkbox(todo.Completed), todo.Priority, dueDate(todo),
+			dash(todo.Project), dash(strings.Join(todo.Tags, ",")), todo.Title)
+	}
+	return tw.Flush()
+}
+
+// writeTodo writes every field of todo, ending with its description
+func writeTodo(w io.Writer, todo *client.Todo) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "ID:\t%s\n", todo.ID)
+	fmt.Fprintf(tw, "Title:\t%s\n", todo.Title)
+	fmt.Fprintf(tw, "Done:\t%s\n", checkbox(todo.Completed))
+	fmt.Fprintf(tw, "Priority:\t%d\n", todo.Priority)
+	fmt.Fprintf(tw, "Due:\t%s\n", dueDate(todo))
+	fmt.Fprintf(tw, "Project:\t%s\n", dash(todo.Project))
+	fmt.Fprintf(tw, "Tags:\t%s\n", dash(strings.Join(todo.Tags, ", ")))
+	if todo.ParentID != "" {
+		fmt.Fprintf(tw, "Parent:\t%s\n", todo.ParentID)
+	}
+	fmt.Fprintf(tw, "Created:\t%s\n", todo.CreatedAt.Local().Format(time.DateTime))
+	fmt.Fprintf(tw, "Updated:\t%s\n", todo.UpdatedAt.Local().Format(time.DateTime))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if todo.Description != "" {
+		_, err := fmt.Fprintf(w, "\n%s\n", strings.TrimRight(todo.Description, "\n"))
+		return err
+	}
+	return nil
+}
+
+func checkbox(done bool) string {
+	if done {
+		return "[x]"
+	}
+	return "[ ]"
+}
+
+func dueDate(todo *client.Todo) string {
+	switch {
+	case todo.DueDate == nil:
+		return "-"
+	case todo.AllDay:
+		return todo.DueDate.UTC().Format(time.DateOnly)
+	}
+	return todo.DueDate.Local().Format("2006-01-02 15:04")
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/teguh/go-todo-api/pkg/client"
+)
+
+func newProfileCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "profile",
+		Short: "Manage the servers todo talks to",
+		Long:  "Profiles name a server with its base URL, token and user. They are stored in $TODO_CONFIG, or todo/config.json in the user configuration directory.",
+	}
+	cmd.AddCommand(
+		newProfileListCommand(),
+		newProfileSetCommand(),
+		newProfileUseCommand(),
+		newProfileRemoveCommand(),
+	)
+	return cmd
+}
+
+func newProfileListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "ls",
+		Short: "List profiles, marking the current one",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "\tNAME\tBASE URL\tUSER\tTOKEN")
+			for _, name := range cfg.Names() {
+				profile := cfg.Profiles[name]
+				current, token := "", "-"
+				if name == cfg.CurrentName() {
+					current = "*"
+				}
+				if profile.Token != "" {
+					// Never print secrets
+					token = "set"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", current, name, dash(profile.BaseURL), dash(profile.User), token)
+			}
+			return tw.Flush()
+		},
+	}
+}
+
+func newProfileSetCommand() *cobra.Command {
+	var (
+		baseURL, token, user string
+		use                  bool
+	)
+	cmd := &cobra.Command{
+		Use:   "set NAME",
+		Short: "Create or change a profile",
+		Example: `  todo profile set local --base-url http://localhost:3000 --user alice
+  todo profile set prod --base-url https://todo.example.com --token "$TODO_PROD_TOKEN" --use`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeProfiles,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			name := args[0]
+			profile, ok := cfg.Profiles[name]
+			if !ok {
+				profile = &Profile{}
+				cfg.Profiles[name] = profile
+			}
+			flags := cmd.Flags()
+			if flags.Changed("base-url") {
+				// Fail now rather than on the profile's first use
+				if _, err := client.New(baseURL); err != nil {
+					return err
+				}
+				profile.BaseURL = baseURL
+			}
+			if flags.Changed("token") {
+				profile.Token = token
+			}
+			if flags.Changed("user") {
+				profile.User = user
+			}
+			if use {
+				cfg.Current = name
+			}
+
+			if err := cfg.Save(); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Saved profile %s\n", name)
+			return nil
+		},
+	}
+
+	// Local flags shadow the global overrides of the same names
+	flags := cmd.Flags()
+	flags.StringVar(&baseURL, "base-url", "", "server URL, such as http://localhost:3000")
+	flags.StringVar(&token, "token", "", "bearer token sent with every request; empty to remove")
+	flags.StringVar(&user, "user", "", "user to act as (X-User-ID); empty to remove")
+	flags.BoolVar(&use, "use", false, "make this the current profile")
+	return cmd
+}
+
+func newProfileUseCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:               "use NAME",
+		Short:             "Select the profile used by default",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeProfiles,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			name := args[0]
+			if _, ok := cfg.Profiles[name]; !ok && name != defaultProfile {
+				return fmt.Errorf("profile %q does not exist", name)
+			}
+
+			cfg.Current = name
+			if err := cfg.Save(); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Using profile %s\n", name)
+			return nil
+		},
+	}
+}
+
+func newProfileRemoveCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:               "rm NAME",
+		Short:             "Delete a profile",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeProfiles,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			name := args[0]
+			if _, ok := cfg.Profiles[name]; !ok {
+				return fmt.Errorf("profile %q does not exist", name)
+			}
+
+			delete(cfg.Profiles, name)
+			if cfg.Current == name {
+				cfg.Current = ""
+			}
+			if err := cfg.Save(); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Deleted profile %s\n", name)
+			return nil
+		},
+	}
+}
+
+// completeProfiles completes the names of profiles
+func completeProfiles(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	return cfg.Names(), cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/teguh/go-todo-api/pkg/client"
+)
+
+// dueLayouts are the accepted formats of --due times besides RFC3339;
+// times without a zone are local
+var dueLayouts = []string{"2006-01-02 15:04", "2006-01-02T15:04"}
+
+// dueWindows lists the values of ls --due
+var dueWindows = []string{client.DueOverdue, client.DueToday, client.DueWeek}
+
+// sortFields lists the values of --sort
+var sortFields = []string{client.SortPriority, client.SortCreatedAt, client.SortUpdatedAt, client.SortDueDate, client.SortTitle}
+
+func newAddCommand(c *cli) *cobra.Command {
+	var (
+		create client.TodoCreate
+		edit   bool
+		due    string
+		format *string
+	)
+	cmd := &cobra.Command{
+		Use:   "add TITLE...",
+		Short: "Add a todo",
+		Example: `  todo add Buy milk --project home --tag errand --due tomorrow
+  todo add Write the quarterly report --priority 3 --edit`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkFormat(*format); err != nil {
+				return err
+			}
+			create.Title = strings.Join(args, " ")
+			if due != "" {
+				parsed, allDay, err := parseDue(due)
+				if err != nil {
+					return err
+				}
+				create.DueDate = &parsed
+				create.AllDay = allDay
+			}
+			if edit {
+				description, err := editText(create.Description)
+				if err != nil {
+					return err
+				}
+				create.Description = description
+			}
+
+			api, err := c.client()
+			if err != nil {
+				return err
+			}
+			todo, err := api.CreateTodo(cmd.Context(), create)
+			if err != nil {
+				return err
+			}
+
+			if *format == formatJSON {
+				return writeJSON(cmd.OutOrStdout(), todo)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Added %s %s\n", todo.ID, todo.Title)
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&create.Description, "description", "d", "", "description")
+	flags.BoolVarP(&edit, "edit", "e", false, "write the description in $EDITOR")
+	flags.StringVarP(&create.Project, "project", "p", "", "project")
+	flags.StringSliceVarP(&create.Tags, "tag", "t", nil, "tag, repeatable or comma-separated")
+	flags.IntVar(&create.Priority, "priority", 0, "priority from 0 to 5")
+	flags.StringVar(&due, "due", "", "due date: today, tomorrow or YYYY-MM-DD for all day, YYYY-MM-DD HH:MM or RFC3339")
+	flags.StringVar(&create.ParentID, "parent", "", "ID of the todo this is a subtask of")
+	format = outputFlag(cmd)
+	_ = cmd.RegisterFlagCompletionFunc("parent", c.completeTodoIDs)
+	_ = cmd.RegisterFlagCompletionFunc("due", cobra.FixedCompletions([]string{"today", "tomorrow"}, cobra.ShellCompDirectiveNoFileComp))
+	return cmd
+}
+
+func newListCommand(c *cli) *cobra.Command {
+	var (
+		opts      client.ListOptions
+		all, done bool
+		project   string
+		parent    string
+		topLevel  bool
+		format    *string
+	)
+	cmd := &cobra.Command{
+		Use:     "ls",
+		Aliases: []string{"list"},
+		Short:   "List todos",
+		Long:    "List open todos, by default ordered by priority, then newest first.",
+		Example: `  todo ls --project work --tag urgent
+  todo ls --all --sort due_date
+  todo ls --due today
+  todo ls --search report -o json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := checkFormat(*format); err != nil {
+				return err
+			}
+			switch {
+			case all && done:
+				return fmt.Errorf("--all and --done cannot be combined")
+			case done:
+				opts.Completed = client.Ptr(true)
+			case !all:
+				opts.Completed = client.Ptr(false)
+			}
+			if cmd.Flags().Changed("project") {
+				opts.Project = &project
+			}
+			switch {
+			case parent != "" && topLevel:
+				return fmt.Errorf("--parent and --top-level cannot be combined")
+			case parent != "":
+				opts.ParentID = &parent
+			case topLevel:
+				opts.ParentID = client.Ptr("")
+			}
+
+			api, err := c.client()
+			if err != nil {
+				return err
+			}
+			todos, err := api.ListTodos(cmd.Context(), opts)
+			if err != nil {
+				return err
+			}
+
+			if *format == formatJSON {
+				if todos == nil {
+					todos = []*client.Todo{}
+				}
+				return writeJSON(cmd.OutOrStdout(), todos)
+			}
+			return writeTodos(cmd.OutOrStdout(), todos)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVarP(&all, "all", "a", false, "include completed todos")
+	flags.BoolVar(&done, "done", false, "only list completed todos")
+	flags.StringVarP(&project, "project", "p", "", "only list todos in this project; empty for todos without one")
+	flags.StringVarP(&opts.Tag, "tag", "t", "", "only list todos with this tag")
+	flags.StringVarP(&opts.Search, "search", "s", "", "only list todos whose title or description contains this text")
+	flags.StringVar(&opts.Due, "due", "", "only list todos due: "+strings.Join(dueWindows, ", "))
+	flags.StringVar(&parent, "parent", "", "only list the subtasks of this todo")
+	flags.BoolVar(&topLevel, "top-level", false, "only list todos that are not subtasks")
+	flags.StringVar(&opts.Sort, "sort", "", "sort by "+strings.Join(sortFields, ", "))
+	flags.BoolVar(&opts.Descending, "desc", false, "sort in descending order")
+	flags.IntVarP(&opts.Limit, "limit", "n", 0, "list at most this many todos (up to 100)")
+	format = outputFlag(cmd)
+	_ = cmd.RegisterFlagCompletionFunc("sort", cobra.FixedCompletions(sortFields, cobra.ShellCompDirectiveNoFileComp))
+	_ = cmd.RegisterFlagCompletionFunc("due", cobra.FixedCompletions(dueWindows, cobra.ShellCompDirectiveNoFileComp))
+	_ = cmd.RegisterFlagCompletionFunc("parent", c.completeTodoIDs)
+	return cmd
+}
+
+func newShowCommand(c *cli) *cobra.Command {
+	var format *string
+	cmd := &cobra.Command{
+		Use:               "show ID",
+		Short:             "Show a todo with its description",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: c.completeTodoIDs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkFormat(*format); err != nil {
+				return err
+			}
+			api, err := c.client()
+			if err != nil {
+				return err
+			}
+			todo, err := api.GetTodo(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			if *format == formatJSON {
+				return writeJSON(cmd.OutOrStdout(), todo)
+			}
+			return writeTodo(cmd.OutOrStdout(), todo)
+		},
+	}
+	format = outputFlag(cmd)
+	return cmd
+}
+
+func newDoneCommand(c *cli) *cobra.Command {
+	var undo bool
+	cmd := &cobra.Command{
+		Use:               "done ID...",
+		Short:             "Mark todos as completed",
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: c.completeTodoIDs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			api, err := c.client()
+			if err != nil {
+				return err
+			}
+			verb := "Completed"
+			if undo {
+				verb = "Reopened"
+			}
+			for _, id := range args {
+				todo, err := api.UpdateTodo(cmd.Context(), id, client.TodoUpdate{Completed: client.Ptr(!undo)})
+				if err != nil {
+					return fmt.Errorf("%s: %w", id, err)
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%s %s %s\n", verb, todo.ID, todo.Title)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&undo, "undo", false, "mark the todos as open again")
+	return cmd
+}
+
+func newEditCommand(c *cli) *cobra.Command {
+	var (
+		title, description, project, parent, due string
+		tags                                     []string
+		priority                                 int
+		edit, noDue                              bool
+		format                                   *string
+	)
+	cmd := &cobra.Command{
+		Use:   "edit ID",
+		Short: "Change a todo",
+		Long:  "Change the given fields of a todo. Without any, the description is opened in $EDITOR.",
+		Example: `  todo edit 0b5c... --priority 4 --due 2025-07-01
+  todo edit 0b5c... --tag home,errand
+  todo edit 0b5c...`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: c.completeTodoIDs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkFormat(*format); err != nil {
+				return err
+			}
+			flags := cmd.Flags()
+			var update client.TodoUpdate
+			if flags.Changed("title") {
+				update.Title = &title
+			}
+			if flags.Changed("description") {
+				update.Description = &description
+			}
+			if flags.Changed("project") {
+				update.Project = &project
+			}
+			if flags.Changed("parent") {
+				update.ParentID = &parent
+			}
+			if flags.Changed("tag") {
+				update.Tags = &tags
+			}
+			if flags.Changed("priority") {
+				update.Priority = &priority
+			}
+			switch {
+			case noDue && due != "":
+				return fmt.Errorf("--due and --no-due cannot be combined")
+			case noDue:
+				update.ClearDueDate = true
+			case due != "":
+				parsed, allDay, err := parseDue(due)
+				if err != nil {
+					return err
+				}
+				update.DueDate = &parsed
+				update.AllDay = allDay
+			}
+			if update == (client.TodoUpdate{}) {
+				edit = true
+			}
+
+			api, err := c.client()
+			if err != nil {
+				return err
+			}
+			if edit {
+				if update.Description == nil {
+					todo, err := api.GetTodo(cmd.Context(), args[0])
+					if err != nil {
+						return err
+					}
+					update.Description = &todo.Description
+				}
+				edited, err := editText(*update.Description)
+				if err != nil {
+					return err
+				}
+				update.Description = &edited
+			}
+
+			todo, err := api.UpdateTodo(cmd.Context(), args[0], update)
+			if err != nil {
+				return err
+			}
+
+			if *format == formatJSON {
+				return writeJSON(cmd.OutOrStdout(), todo)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Updated %s %s\n", todo.ID, todo.Title)
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&title, "title", "", "new title")
+	flags.StringVarP(&description, "description", "d", "", "new description")
+	flags.BoolVarP(&edit, "edit", "e", false, "edit the description in $EDITOR")
+	flags.StringVarP(&project, "project", "p", "", "new project; empty to clear")
+	flags.StringSliceVarP(&tags, "tag", "t", nil, "replace the tags, repeatable or comma-separated; empty to clear")
+	flags.IntVar(&priority, "priority", 0, "new priority from 0 to 5")
+	flags.StringVar(&due, "due", "", "new due date: today, tomorrow or YYYY-MM-DD for all day, YYYY-MM-DD HH:MM or RFC3339")
+	flags.BoolVar(&noDue, "no-due", false, "clear the due date")
+	flags.StringVar(&parent, "parent", "", "make this a subtask of the given todo; empty to detach")
+	format = outputFlag(cmd)
+	_ = cmd.RegisterFlagCompletionFunc("parent", c.completeTodoIDs)
+	_ = cmd.RegisterFlagCompletionFunc("due", cobra.FixedCompletions([]string{"today", "tomorrow"}, cobra.ShellCompDirectiveNoFileComp))
+	return cmd
+}
+
+func newRemoveCommand(c *cli) *cobra.Command {
+	return &cobra.Command{
+		Use:               "rm ID...",
+		Aliases:           []string{"delete"},
+		Short:             "Delete todos",
+		Long:              "Delete todos. A todo with subtasks cannot be deleted until they are.",
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: c.completeTodoIDs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			api, err := c.client()
+			if err != nil {
+				return err
+			}
+			for _, id := range args {
+				if err := api.DeleteTodo(cmd.Context(), id); err != nil {
+					return fmt.Errorf("%s: %w", id, err)
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Deleted %s\n", id)
+			}
+			return nil
+		},
+	}
+}
+
+// parseDue parses the value of --due, reporting whether it is a day,
+// which makes the todo all-day, rather than a time
+func parseDue(value string) (time.Time, bool, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	switch value {
+	case "today":
+		return today, true, nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), true, nil
+	}
+
+	if due, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return due, true, nil
+	}
+	if due, err := time.Parse(time.RFC3339, value); err == nil {
+		return due, false, nil
+	}
+	for _, layout := range dueLayouts {
+		if due, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return due, false, nil
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("invalid due date %q: use today, tomorrow, YYYY-MM-DD, YYYY-MM-DD HH:MM or RFC3339", value)
+}
+
+// completeTodoIDs completes the IDs of todos, described by their titles,
+// leaving out those already given
+func (c *cli) completeTodoIDs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	api, err := c.client()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	todos, err := api.ListTodos(cmd.Context(), client.ListOptions{})
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	given := make(map[string]bool, len(args))
+	for _, arg := range args {
+		given[arg] = true
+	}
+	var completions []string
+	for _, todo := range todos {
+		if !given[todo.ID] && strings.HasPrefix(todo.ID, toComplete) {
+			completions = append(completions, todo.ID+"\t"+todo.Title)
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
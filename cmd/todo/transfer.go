@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/teguh/go-todo-api/pkg/client"
+)
+
+// fileFormats maps the extensions of files to their format, so --format can
+// be left out
+var fileFormats = map[string]string{
+	".csv":      client.FormatCSV,
+	".ndjson":   client.FormatNDJSON,
+	".jsonl":    client.FormatNDJSON,
+	".json":     client.FormatJSON,
+	".ics":      client.FormatICS,
+	".txt":      client.FormatTodoTxt,
+	".md":       client.FormatMarkdown,
+	".markdown": client.FormatMarkdown,
+}
+
+// exportFormats and importFormats list the values of --format
+var (
+	exportFormats = []string{client.FormatCSV, client.FormatNDJSON, client.FormatJSON, client.FormatICS, client.FormatTodoTxt, client.FormatMarkdown}
+	importFormats = []string{client.FormatCSV, client.FormatNDJSON, client.FormatJSON, client.FormatICS, client.FormatTodoTxt, client.FormatMarkdown, client.FormatTodoist, client.FormatTrello, client.FormatMSTodo}
+)
+
+func newExportCommand(c *cli) *cobra.Command {
+	var (
+		opts       client.ListOptions
+		open, done bool
+		project    string
+		format     string
+		file       string
+	)
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export todos to a file",
+		Long:  "Export every todo, or those matching the filters, to a file or standard output. The format is taken from the file's extension unless given; todo.txt and Markdown files keep every field, so they can be edited and imported again.",
+		Example: `  todo export -f todo.txt
+  todo export --format markdown --sort title --project work > work.md
+  todo export --open --format csv`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			format, err := fileFormat(format, file, client.FormatJSON)
+			if err != nil {
+				return err
+			}
+			switch {
+			case open && done:
+				return fmt.Errorf("--open and --done cannot be combined")
+			case open:
+				opts.Completed = client.Ptr(false)
+			case done:
+				opts.Completed = client.Ptr(true)
+			}
+			if cmd.Flags().Changed("project") {
+				opts.Project = &project
+			}
+
+			api, err := c.client()
+			if err != nil {
+				return err
+			}
+			body, err := api.ExportTodos(cmd.Context(), format, opts)
+			if err != nil {
+				return err
+			}
+			defer body.Close()
+
+			if file == "" || file == "-" {
+				return copyExport(cmd.OutOrStdout(), body)
+			}
+			f, err := os.Create(file)
+			if err != nil {
+				return err
+			}
+			if err := copyExport(f, body); err != nil {
+				f.Close()
+				return err
+			}
+			return f.Close()
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&file, "file", "f", "", "file to write, instead of standard output")
+	flags.StringVar(&format, "format", "", "file format: "+strings.Join(exportFormats, ", "))
+	flags.BoolVar(&open, "open", false, "only export open todos")
+	flags.BoolVar(&done, "done", false, "only export completed todos")
+	flags.StringVarP(&project, "project", "p", "", "only export todos in this project; empty for todos without one")
+	flags.StringVarP(&opts.Tag, "tag", "t", "", "only export todos with this tag")
+	flags.StringVarP(&opts.Search, "search", "s", "", "only export todos whose title or description contains this text")
+	flags.StringVar(&opts.Sort, "sort", "", "sort by "+strings.Join(sortFields, ", "))
+	flags.BoolVar(&opts.Descending, "desc", false, "sort in descending order")
+	_ = cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions(exportFormats, cobra.ShellCompDirectiveNoFileComp))
+	_ = cmd.RegisterFlagCompletionFunc("sort", cobra.FixedCompletions(sortFields, cobra.ShellCompDirectiveNoFileComp))
+	return cmd
+}
+
+func newImportCommand(c *cli) *cobra.Command {
+	var (
+		opts     client.ImportOptions
+		upsert   bool
+		mappings []string
+		format   *string
+	)
+	cmd := &cobra.Command{
+		Use:   "import FILE",
+		Short: "Import todos from a file",
+		Long:  "Import the todos in a file, or standard input when FILE is -. Nothing is written unless every row is valid; the problems of every row are listed otherwise. The format is taken from the file's extension unless given, which the export files of Todoist, Trello and Microsoft To Do need.",
+		Example: `  todo import todo.txt --upsert
+  todo import tasks.csv --map "Task Name:title" --dry-run
+  todo import board.json --format trello`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkFormat(*format); err != nil {
+				return err
+			}
+			var err error
+			if opts.Format, err = fileFormat(opts.Format, args[0], ""); err != nil {
+				return err
+			}
+			if upsert {
+				opts.Mode = client.ImportUpsert
+			}
+			opts.Mapping = make(map[string]string, len(mappings))
+			for _, mapping := range mappings {
+				i := strings.LastIndex(mapping, ":")
+				if i < 0 {
+					return fmt.Errorf("invalid mapping %q: use column:field", mapping)
+				}
+				opts.Mapping[mapping[:i]] = mapping[i+1:]
+			}
+
+			var file io.Reader = cmd.InOrStdin()
+			if args[0] != "-" {
+				f, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				file = f
+			}
+
+			api, err := c.client()
+			if err != nil {
+				return err
+			}
+			result, err := api.ImportTodos(cmd.Context(), file, opts)
+			if err != nil {
+				return err
+			}
+
+			if *format == formatJSON {
+				if err := writeJSON(cmd.OutOrStdout(), result); err != nil {
+					return err
+				}
+			} else {
+				writeImportResult(cmd.OutOrStdout(), result)
+			}
+			if len(result.Errors) > 0 && !result.DryRun {
+				return fmt.Errorf("nothing was imported: the file has errors")
+			}
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.Format, "format", "", "file format: "+strings.Join(importFormats, ", "))
+	flags.BoolVar(&upsert, "upsert", false, "replace the todos whose ID is in the file, instead of creating new ones")
+	flags.BoolVar(&opts.DryRun, "dry-run", false, "check the file and report what would happen without writing")
+	flags.StringArrayVar(&mappings, "map", nil, "read a column as a field, as column:field; repeatable")
+	format = outputFlag(cmd)
+	_ = cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions(importFormats, cobra.ShellCompDirectiveNoFileComp))
+	return cmd
+}
+
+// copyExport writes an export as it is received
+func copyExport(w io.Writer, body io.Reader) error {
+	if _, err := io.Copy(w, body); err != nil {
+		return fmt.Errorf("export cut short: %w", err)
+	}
+	return nil
+}
+
+// fileFormat returns format if given, or else that of file by its
+// extension, or else fallback
+func fileFormat(format, file, fallback string) (string, error) {
+	if format != "" {
+		return format, nil
+	}
+	if format, ok := fileFormats[strings.ToLower(filepath.Ext(file))]; ok {
+		return format, nil
+	}
+	if fallback == "" {
+		return "", fmt.Errorf("cannot tell the format of %q from its extension: use --format", file)
+	}
+	return fallback, nil
+}
+
+// writeImportResult summarizes an import, listing the problems of its rows
+func writeImportResult(w io.Writer, result *client.ImportResult) {
+	switch {
+	case len(result.Errors) > 0:
+		fmt.Fprintf(w, "%d of %d rows have errors:\n", countRows(result.Errors), result.Rows)
+		writeRowErrors(w, result.Errors)
+	case result.DryRun:
+		fmt.Fprintf(w, "Would create %d and update %d todos\n", result.Created, result.Updated)
+	default:
+		fmt.Fprintf(w, "Created %d and updated %d todos\n", result.Created, result.Updated)
+	}
+	if len(result.Warnings) > 0 {
+		fmt.Fprintf(w, "%d rows were changed to fit:\n", countRows(result.Warnings))
+		writeRowErrors(w, result.Warnings)
+	}
+	if len(result.IgnoredColumns) > 0 {
+		fmt.Fprintf(w, "Ignored: %s\n", strings.Join(result.IgnoredColumns, ", "))
+	}
+}
+
+// writeRowErrors lists the problems of rows, one per line
+func writeRowErrors(w io.Writer, errors []client.ImportRowError) {
+	for _, e := range errors {
+		if e.Field != "" {
+			fmt.Fprintf(w, "  row %d: %s: %s\n", e.Row, e.Field, e.Message)
+		} else {
+			fmt.Fprintf(w, "  row %d: %s\n", e.Row, e.Message)
+		}
+	}
+}
+
+// countRows counts the rows with errors or warnings
+func countRows(errors []client.ImportRowError) int {
+	rows := make(map[int]bool, len(errors))
+	for _, e := range errors {
+		rows[e.Row] = true
+	}
+	return len(rows)
+}
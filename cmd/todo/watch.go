@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/teguh/go-todo-api/pkg/client"
+)
+
+func newWatchCommand(c *cli) *cobra.Command {
+	var (
+		opts    client.WatchOptions
+		after   int64
+		project string
+		format  *string
+	)
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Print changes to todos as they happen",
+		Long:  "Print changes to todos as they happen, until interrupted. Dropped connections are resumed without missing changes.",
+		Example: `  todo watch --project work
+  todo watch --after 1042 -o json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := checkFormat(*format); err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("after") {
+				opts.After = &after
+			}
+
+			api, err := c.client()
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			err = api.WatchTodos(cmd.Context(), opts, func(event *client.Event) error {
+				if project != "" && event.PreviousProject != project && (event.Todo == nil || event.Todo.Project != project) {
+					return nil
+				}
+				if *format == formatJSON {
+					return writeJSON(out, event)
+				}
+
+				title := ""
+				if event.Todo != nil {
+					title = event.Todo.Title
+				}
+				_, err := fmt.Fprintf(out, "%s  %-7s  %s  %s  %s\n",
+					event.CreatedAt.Local().Format(time.TimeOnly), event.Type, event.TodoID, dash(event.Actor), title)
+				return err
+			})
+			if errors.Is(err, context.Canceled) {
+				// Interrupted by the user
+				return nil
+			}
+			return err
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.Int64Var(&after, "after", 0, "first replay the changes after the event with this ID")
+	flags.StringVar(&opts.User, "by", "", "only print changes made by this user")
+	flags.StringVarP(&project, "project", "p", "", "only print changes to todos in this project")
+	format = outputFlag(cmd)
+	return cmd
+}
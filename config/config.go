@@ -3,17 +3,76 @@ package config
 import (
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 // Config holds all configuration for the application
 type Config struct {
-	AppName      string
-	AppPort      int
-	LogLevel     string
-	Environment  string
-	DatabasePath string
+	AppName        string
+	AppPort        int
+	GRPCPort       int
+	LogLevel       string
+	Environment    string
+	DatabasePath   string
+	DatabaseURL    string
+	DatabaseDriver string
+	StrictJSON     bool
+	MaxBodyBytes   int
+	SSEHeartbeat   time.Duration
+
+	// Webhook delivery
+	WebhookTimeout      time.Duration
+	WebhookMaxAttempts  int
+	WebhookRetryBase    time.Duration
+	WebhookPollInterval time.Duration
+
+	// Reminders; a reminder is kept from other replicas for ReminderLease
+	// while it is sent
+	ReminderPollInterval time.Duration
+	ReminderLease        time.Duration
+	ReminderMaxAttempts  int
+	ReminderRetryBase    time.Duration
+	ReminderTimeout      time.Duration
+	// ReminderWebhookSecret, when set, signs webhook reminders
+	ReminderWebhookSecret string
+
+	// SMTP relay sending email reminders and digests; email is disabled
+	// without a host
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+	SMTPTimeout  time.Duration
+
+	// Digests; a digest is kept from other replicas for DigestLease while it
+	// is sent, and retried after DigestRetryInterval if sending fails
+	DigestPollInterval  time.Duration
+	DigestLease         time.Duration
+	DigestRetryInterval time.Duration
+
+	// Transactional outbox; an entry is kept from other replicas for
+	// OutboxLease while it is relayed
+	OutboxPollInterval time.Duration
+	OutboxLease        time.Duration
+	// OutboxFile, when set, receives every event as a line of NDJSON
+	OutboxFile string
+
+	// ImportMaxRows caps the rows of an import file; zero means no limit
+	ImportMaxRows int
+
+	// CalendarRefreshInterval is how often subscribers are asked to poll the
+	// calendar feed
+	CalendarRefreshInterval time.Duration
+
+	// GraphQL limits; the playground is served in development only
+	GraphQLMaxComplexity int
+	GraphQLMaxDepth      int
+
+	// ShutdownTimeout bounds how long open connections are waited for on shutdown
+	ShutdownTimeout time.Duration
 }
 
 // LoadConfig loads configuration from environment variables
@@ -23,11 +82,63 @@ func LoadConfig() (*Config, error) {
 
 	// Set default values
 	config := &Config{
-		AppName:      getEnv("APP_NAME", "Todo API"),
-		AppPort:      getEnvAsInt("APP_PORT", 3000),
-		LogLevel:     getEnv("LOG_LEVEL", "info"),
-		Environment:  getEnv("ENVIRONMENT", "development"),
-		DatabasePath: getEnv("DATABASE_PATH", "data/todo.db"),
+		AppName:        getEnv("APP_NAME", "Todo API"),
+		AppPort:        getEnvAsInt("APP_PORT", 3000),
+		GRPCPort:       getEnvAsInt("GRPC_PORT", 9090),
+		LogLevel:       getEnv("LOG_LEVEL", "info"),
+		Environment:    getEnv("ENVIRONMENT", "development"),
+		DatabasePath:   getEnv("DATABASE_PATH", "data/todo.db"),
+		DatabaseURL:    getEnv("DATABASE_URL", ""),
+		DatabaseDriver: getEnv("DATABASE_DRIVER", ""),
+		StrictJSON:     getEnvAsBool("STRICT_JSON", true),
+		MaxBodyBytes:   getEnvAsInt("MAX_BODY_BYTES", 64*1024),
+		SSEHeartbeat:   getEnvAsDuration("SSE_HEARTBEAT", 15*time.Second),
+
+		WebhookTimeout:      getEnvAsDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		WebhookMaxAttempts:  getEnvAsInt("WEBHOOK_MAX_ATTEMPTS", 8),
+		WebhookRetryBase:    getEnvAsDuration("WEBHOOK_RETRY_BASE", 30*time.Second),
+		WebhookPollInterval: getEnvAsDuration("WEBHOOK_POLL_INTERVAL", 5*time.Second),
+
+		ReminderPollInterval:  getEnvAsDuration("REMINDER_POLL_INTERVAL", 15*time.Second),
+		ReminderLease:         getEnvAsDuration("REMINDER_LEASE", time.Minute),
+		ReminderMaxAttempts:   getEnvAsInt("REMINDER_MAX_ATTEMPTS", 5),
+		ReminderRetryBase:     getEnvAsDuration("REMINDER_RETRY_BASE", time.Minute),
+		ReminderTimeout:       getEnvAsDuration("REMINDER_TIMEOUT", 30*time.Second),
+		ReminderWebhookSecret: getEnv("REMINDER_WEBHOOK_SECRET", ""),
+
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnvAsInt("SMTP_PORT", 587),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:     getEnv("SMTP_FROM", "todo@localhost"),
+		SMTPTimeout:  getEnvAsDuration("SMTP_TIMEOUT", 30*time.Second),
+
+		DigestPollInterval:  getEnvAsDuration("DIGEST_POLL_INTERVAL", time.Minute),
+		DigestLease:         getEnvAsDuration("DIGEST_LEASE", 5*time.Minute),
+		DigestRetryInterval: getEnvAsDuration("DIGEST_RETRY_INTERVAL", 15*time.Minute),
+
+		OutboxPollInterval: getEnvAsDuration("OUTBOX_POLL_INTERVAL", time.Second),
+		OutboxLease:        getEnvAsDuration("OUTBOX_LEASE", time.Minute),
+		OutboxFile:         getEnv("OUTBOX_FILE", ""),
+
+		ImportMaxRows: getEnvAsInt("IMPORT_MAX_ROWS", 10000),
+
+		CalendarRefreshInterval: getEnvAsDuration("CALENDAR_REFRESH_INTERVAL", 15*time.Minute),
+
+		GraphQLMaxComplexity: getEnvAsInt("GRAPHQL_MAX_COMPLEXITY", 1000),
+		GraphQLMaxDepth:      getEnvAsInt("GRAPHQL_MAX_DEPTH", 10),
+
+		ShutdownTimeout: getEnvAsDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+	}
+
+	// DATABASE_DRIVER=memory or DATABASE_PATH=:memory: selects the in-memory store
+	switch {
+	case config.DatabaseDriver == "memory",
+		config.DatabaseURL == "" && config.DatabasePath == ":memory:":
+		config.DatabaseURL = "memory://"
+	case config.DatabaseURL == "":
+		// Fall back to the SQLite file at DATABASE_PATH when no URL is given
+		config.DatabaseURL = "sqlite://" + config.DatabasePath
 	}
 
 	return config, nil
@@ -48,3 +159,19 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	valueStr := getEnv(key, "")
+	if value, err := time.ParseDuration(valueStr); err == nil && value > 0 {
+		return value
+	}
+	return defaultValue
+}
@@ -23,165 +23,370 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/todos": {
+        "/calendar.ics": {
             "get": {
-                "description": "Get all todo items, optionally filtered by completion status",
+                "description": "Subscribable iCalendar feed of todos, authenticated by a calendar token rather than X-User-ID since\ncalendar apps cannot set headers. By default each todo with a due date is an event at that time, which\nevery calendar app shows; component=vtodo renders every todo as a task instead, for apps that show them.\nPriorities map onto iCalendar's, where 1 is the highest and 9 the lowest, completed todos have\nSTATUS:COMPLETED, tags become CATEGORIES and the project X-TODO-PROJECT.",
                 "produces": [
-                    "application/json"
+                    "text/calendar"
                 ],
                 "tags": [
-                    "todos"
+                    "calendar"
                 ],
-                "summary": "Get all todos",
+                "summary": "Calendar feed",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Calendar token",
+                        "name": "token",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "vevent",
+                            "vtodo"
+                        ],
+                        "type": "string",
+                        "default": "vevent",
+                        "description": "Component todos are rendered as",
+                        "name": "component",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by project",
+                        "name": "project",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by tag",
+                        "name": "tag",
+                        "in": "query"
+                    },
                     {
                         "type": "boolean",
                         "description": "Filter by completion status",
                         "name": "completed",
                         "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "iCalendar file",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/calendar/token": {
+            "get": {
+                "description": "Tell whether the user named by X-User-ID has a calendar token, and since when. The token itself is\nonly returned when it is created.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "calendar"
+                ],
+                "summary": "Get the calendar token",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Acting user",
+                        "name": "X-User-ID",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.Todo"
-                            }
+                            "$ref": "#/definitions/models.CalendarToken"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/utils.ErrorResponse"
+                            "$ref": "#/definitions/utils.ProblemDetails"
                         }
                     }
                 }
             },
             "post": {
-                "description": "Create a new todo item",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Issue a calendar token to the user named by X-User-ID, revoking any they had, and return it with the\nfeed URL to subscribe to. Only a hash is stored, so this is the one response that includes the token.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "todos"
+                    "calendar"
                 ],
-                "summary": "Create a new todo",
+                "summary": "Create a calendar token",
                 "parameters": [
                     {
-                        "description": "Todo to create",
-                        "name": "todo",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.TodoCreate"
-                        }
+                        "type": "string",
+                        "description": "Acting user",
+                        "name": "X-User-ID",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "201": {
                         "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/models.Todo"
+                            "$ref": "#/definitions/models.CalendarToken"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
                         }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Revoke the calendar token of the user named by X-User-ID; subscriptions using it stop updating",
+                "tags": [
+                    "calendar"
+                ],
+                "summary": "Revoke the calendar token",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Acting user",
+                        "name": "X-User-ID",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/utils.ErrorResponse"
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/utils.ErrorResponse"
+                            "$ref": "#/definitions/utils.ProblemDetails"
                         }
                     }
                 }
             }
         },
-        "/todos/{id}": {
+        "/digest/preview": {
             "get": {
-                "description": "Get a todo item by its ID",
+                "description": "Render the digest the user named by X-User-ID would be mailed now, without sending it: their open todos\nthat are overdue, due today and due in the six days after, each grouped by project. Days are counted in\nthe user's time zone unless timezone is given. format=html and format=text return the email's bodies.",
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "text/html",
+                    "text/plain"
                 ],
                 "tags": [
-                    "todos"
+                    "preferences"
                 ],
-                "summary": "Get a todo by ID",
+                "summary": "Preview the digest",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Todo ID",
-                        "name": "id",
-                        "in": "path",
+                        "description": "Acting user",
+                        "name": "X-User-ID",
+                        "in": "header",
                         "required": true
+                    },
+                    {
+                        "enum": [
+                            "json",
+                            "html",
+                            "text"
+                        ],
+                        "type": "string",
+                        "default": "json",
+                        "description": "Format",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone, instead of the user's",
+                        "name": "timezone",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Todo"
+                            "$ref": "#/definitions/models.Digest"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/utils.ErrorResponse"
+                            "$ref": "#/definitions/utils.ProblemDetails"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/utils.ErrorResponse"
+                            "$ref": "#/definitions/utils.ProblemDetails"
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "Delete a todo item by its ID",
+            }
+        },
+        "/graphql": {
+            "get": {
+                "description": "Runs a query passed in the URL; mutations must be sent with POST.\nIn development, browsers requesting HTML without a query get the GraphiQL playground.",
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "text/html"
                 ],
                 "tags": [
-                    "todos"
+                    "graphql"
                 ],
-                "summary": "Delete a todo",
+                "summary": "Execute a GraphQL query",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Todo ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "GraphQL query",
+                        "name": "query",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Operation to run when the query has several",
+                        "name": "operationName",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Variables as a JSON object",
+                        "name": "variables",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
-                    },
-                    "404": {
-                        "description": "Not Found",
+                    "200": {
+                        "description": "GraphQL response with data and errors",
                         "schema": {
-                            "$ref": "#/definitions/utils.ErrorResponse"
+                            "type": "object"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/utils.ErrorResponse"
+                            "$ref": "#/definitions/utils.ProblemDetails"
                         }
                     }
                 }
             },
-            "patch": {
-                "description": "Update a todo item by its ID",
+            "post": {
+                "description": "Runs a query or mutation against the todo schema. Operations that nest deeper or are\nestimated to resolve more fields than allowed are rejected with a QUERY_TOO_COMPLEX error.\nErrors are reported in the errors array of a 200 response, with a code in their extensions.",
                 "consumes": [
                     "application/json"
                 ],
@@ -189,134 +394,2520 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "todos"
+                    "graphql"
                 ],
-                "summary": "Update a todo",
+                "summary": "Execute a GraphQL operation",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Todo ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "Todo update data",
-                        "name": "todo",
+                        "description": "GraphQL request",
+                        "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.TodoUpdate"
+                            "$ref": "#/definitions/gql.Request"
                         }
+                    },
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "GraphQL response with data and errors",
                         "schema": {
-                            "$ref": "#/definitions/models.Todo"
+                            "type": "object"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/utils.ErrorResponse"
+                            "$ref": "#/definitions/utils.ProblemDetails"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "413": {
+                        "description": "Request Entity Too Large",
                         "schema": {
-                            "$ref": "#/definitions/utils.ErrorResponse"
+                            "$ref": "#/definitions/utils.ProblemDetails"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "415": {
+                        "description": "Unsupported Media Type",
                         "schema": {
-                            "$ref": "#/definitions/utils.ErrorResponse"
+                            "$ref": "#/definitions/utils.ProblemDetails"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "models.Todo": {
-            "type": "object",
-            "properties": {
-                "completed": {
-                    "type": "boolean"
-                },
-                "created_at": {
-                    "type": "string"
-                },
-                "description": {
-                    "type": "string"
-                },
-                "due_date": {
-                    "type": "string"
-                },
-                "id": {
-                    "type": "string"
-                },
-                "priority": {
-                    "type": "integer"
-                },
-                "title": {
-                    "type": "string"
-                },
-                "updated_at": {
-                    "type": "string"
-                }
-            }
         },
-        "models.TodoCreate": {
-            "type": "object",
-            "required": [
-                "title"
-            ],
-            "properties": {
-                "description": {
-                    "type": "string"
-                },
-                "due_date": {
-                    "type": "string"
-                },
-                "priority": {
-                    "type": "integer"
-                },
-                "title": {
-                    "type": "string"
-                }
+        "/preferences": {
+            "get": {
+                "description": "Get the time zone and digest schedule of the user named by X-User-ID, or the defaults if they have set none",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "preferences"
+                ],
+                "summary": "Get preferences",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Acting user",
+                        "name": "X-User-ID",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Preferences"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replace the preferences of the user named by X-User-ID. Omitted fields are reset to their defaults:\ntimezone UTC, digest_frequency off, digest_hour 7 and digest_weekday monday. Daily and weekly digests\nof the open todos that are overdue, due today and due this week are mailed to email at digest_hour\nin timezone, when the server has SMTP configured. Digests with nothing due are not sent.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "preferences"
+                ],
+                "summary": "Replace preferences",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Acting user",
+                        "name": "X-User-ID",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Preferences",
+                        "name": "preferences",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.PreferencesReplace"
+                        }
+                    },
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Preferences"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Reset the preferences of the user named by X-User-ID to the defaults, which stops their digest",
+                "tags": [
+                    "preferences"
+                ],
+                "summary": "Reset preferences",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Acting user",
+                        "name": "X-User-ID",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/todos": {
+            "get": {
+                "description": "Get the todo items matching the given filters, by default ordered by priority, then newest first.\nWithout a limit every match is returned; with one, a page of at most 100 todos is returned and\nthe X-Total-Count header holds the number of matches. An empty project or parent_id selects todos\nwithout a project or top-level todos respectively. Days for the due filter are counted, and\ntimes rendered, in the timezone parameter, else the user's time zone, else UTC.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "todos"
+                ],
+                "summary": "Get all todos",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Filter by completion status",
+                        "name": "completed",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by project",
+                        "name": "project",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by tag",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by parent todo",
+                        "name": "parent_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by text in the title or description, ignoring case",
+                        "name": "search",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "overdue",
+                            "today",
+                            "week"
+                        ],
+                        "type": "string",
+                        "description": "Filter by due date: before now, today, or in the seven days from today",
+                        "name": "due",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone to count days and render times in",
+                        "name": "timezone",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "priority",
+                            "created_at",
+                            "updated_at",
+                            "due_date",
+                            "title"
+                        ],
+                        "type": "string",
+                        "description": "Sort field",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "default": "asc",
+                        "description": "Sort direction",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size, between 1 and 100",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of todos to skip; requires limit",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Todo"
+                            }
+                        },
+                        "headers": {
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "Number of matching todos, when limit is set"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Create a new todo item",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "todos"
+                ],
+                "summary": "Create a new todo",
+                "parameters": [
+                    {
+                        "description": "Todo to create",
+                        "name": "todo",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.TodoCreate"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone to render times in; defaults to the user's, else UTC",
+                        "name": "timezone",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Todo"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/todos/events": {
+            "get": {
+                "description": "Server-Sent Events stream emitting created, updated and deleted events with the todo as data.\nReconnecting clients send Last-Event-ID (or last_event_id) to replay missed events from the event log.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "events"
+                ],
+                "summary": "Stream todo changes",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID of the last event received",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Alternative to the Last-Event-ID header",
+                        "name": "last_event_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only stream changes made by this user (X-User-ID)",
+                        "name": "user",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "One event per message",
+                        "schema": {
+                            "$ref": "#/definitions/models.TodoEvent"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/todos/export": {
+            "get": {
+                "description": "Stream every todo matching the given filters as CSV, newline-delimited JSON, a JSON array, an\niCalendar file of VTODOs, a todo.txt file or a Markdown checklist, ordered as in the list endpoint. CSV\nfiles start with a header row and join tags with commas. todo.txt and Markdown files keep every field,\nwriting the id, parent and description as key:value pairs or comments, so they can be edited and\nimported again; Markdown has a heading whenever the project changes. The todos are read in batches,\neach starting after the last todo of the one before, so writes during a long export never duplicate or\ndrop the todos they do not touch; only a todo whose sort fields change can appear twice or not at all.\nTimes are written in the timezone parameter, else the user's time zone, else UTC.",
+                "produces": [
+                    "text/csv",
+                    "application/x-ndjson",
+                    "application/json",
+                    "text/calendar",
+                    "text/plain",
+                    "text/markdown"
+                ],
+                "tags": [
+                    "todos"
+                ],
+                "summary": "Export todos",
+                "parameters": [
+                    {
+                        "enum": [
+                            "csv",
+                            "ndjson",
+                            "json",
+                            "ics",
+                            "todotxt",
+                            "markdown"
+                        ],
+                        "type": "string",
+                        "default": "json",
+                        "description": "File format",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Filter by completion status",
+                        "name": "completed",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by project",
+                        "name": "project",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by tag",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by parent todo",
+                        "name": "parent_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by text in the title or description, ignoring case",
+                        "name": "search",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "overdue",
+                            "today",
+                            "week"
+                        ],
+                        "type": "string",
+                        "description": "Filter by due date: before now, today, or in the seven days from today",
+                        "name": "due",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone to count days and write times in",
+                        "name": "timezone",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "priority",
+                            "created_at",
+                            "updated_at",
+                            "due_date",
+                            "title"
+                        ],
+                        "type": "string",
+                        "description": "Sort field",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "default": "asc",
+                        "description": "Sort direction",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Todo"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/todos/import": {
+            "post": {
+                "description": "Import todos from a CSV file with a header row, newline-delimited JSON, a JSON array, an iCalendar\nfile, a todo.txt file or a Markdown checklist, as given by format or the Content-Type. Columns (or keys) named after a todo field are read into\nit, and map renames others, e.g. map=Task Name:title; the rest are ignored and listed in ignored_columns.\nCSV tags are separated by commas. iCalendar files have a row per VTODO, read as the calendar feed\nwrites them, with UID as the id and a RELATED-TO parent as the parent_id; other components are skipped. Rows are validated like created todos, with RFC3339 due dates, and a parent_id may\nname a todo or another row by its id. todo.txt files have a row per line, with (A) to (E) as priorities 5\nto 1, +project, @tags, and due, id, parent, pri and description as key:value pairs. Markdown files have a\nrow per checklist item, under a heading naming its project, with #tags, the priority as in todo.txt and\nquoted lines below as the description; indented items are subtasks. Neither can be mapped.\n\nThe export files of other apps are read with format=todoist (a project exported as CSV, or tasks from\nthe Todoist API), format=trello (a board exported as JSON) or format=mstodo (Microsoft To Do lists with\ntheir tasks from Microsoft Graph). Their projects, labels and subtasks are kept where the file has them,\nand ignored_columns lists the fields of their tasks that todos have no counterpart for. Such files can\nonly be imported in create mode and cannot be mapped.\n\nIn create mode every row becomes a new todo and ids only link rows to their parents; in upsert mode\nrows with the id of an existing todo replace it and the others are created with their id. Rows are\nwritten in one transaction only if all are valid; otherwise nothing is written, the response is 422\nand errors lists the problems of every row. A dry run validates and reports without writing.",
+                "consumes": [
+                    "text/csv",
+                    "application/x-ndjson",
+                    "application/json",
+                    "text/calendar",
+                    "text/plain",
+                    "text/markdown"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "todos"
+                ],
+                "summary": "Import todos",
+                "parameters": [
+                    {
+                        "enum": [
+                            "csv",
+                            "ndjson",
+                            "json",
+                            "ics",
+                            "todotxt",
+                            "markdown",
+                            "todoist",
+                            "trello",
+                            "mstodo"
+                        ],
+                        "type": "string",
+                        "description": "File format, if not given by the Content-Type",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "create",
+                            "upsert"
+                        ],
+                        "type": "string",
+                        "default": "create",
+                        "description": "Import mode",
+                        "name": "mode",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Validate without writing",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Column mappings as column:field",
+                        "name": "map",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Import file",
+                        "name": "file",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ImportResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "422": {
+                        "description": "Some rows are invalid; nothing was written",
+                        "schema": {
+                            "$ref": "#/definitions/models.ImportResult"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/todos/ws": {
+            "get": {
+                "description": "WebSocket endpoint. Clients send SocketRequest messages to subscribe to projects or todos and to create, update, replace or delete todos;\nthe server replies with ack or error messages and pushes event and presence messages for subscriptions. All messages are JSON text frames.\nBrowsers, which cannot set X-User-ID on a WebSocket, may pass the user as a query parameter instead.",
+                "tags": [
+                    "events"
+                ],
+                "summary": "Real-time todo channel",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Acting user when X-User-ID cannot be sent",
+                        "name": "user",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "101": {
+                        "description": "Switching Protocols",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SocketMessage"
+                        }
+                    },
+                    "426": {
+                        "description": "Upgrade Required",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/todos/{id}": {
+            "get": {
+                "description": "Get a todo item by its ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "todos"
+                ],
+                "summary": "Get a todo by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Todo ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone to render times in; defaults to the user's, else UTC",
+                        "name": "timezone",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Todo"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replace every field of the todo with the given ID, or create it with that ID if it does not exist.\nIntended for offline-first clients that generate UUIDs locally. Omitted fields are reset to their defaults.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "todos"
+                ],
+                "summary": "Replace or create a todo",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Todo ID (lowercase UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Full todo state",
+                        "name": "todo",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.TodoReplace"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone to render times in; defaults to the user's, else UTC",
+                        "name": "timezone",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Replaced",
+                        "schema": {
+                            "$ref": "#/definitions/models.Todo"
+                        }
+                    },
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Todo"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "409": {
+                        "description": "Body id does not match the path",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a todo item by its ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "todos"
+                ],
+                "summary": "Delete a todo",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Todo ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Update a todo item by its ID. The body is interpreted by its Content-Type:\napplication/json takes a partial TodoUpdate, application/merge-patch+json a JSON Merge Patch (RFC 7396)\nwhere null clears description, project or due_date, and application/json-patch+json a JSON Patch (RFC 6902)\nincluding test operations. Patches apply atomically against the todo's JSON representation.",
+                "consumes": [
+                    "application/json",
+                    "application/merge-patch+json",
+                    "application/json-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "todos"
+                ],
+                "summary": "Update a todo",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Todo ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Todo update data",
+                        "name": "todo",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.TodoUpdate"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone to render times in; defaults to the user's, else UTC",
+                        "name": "timezone",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Todo"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "409": {
+                        "description": "A JSON Patch test operation failed, or the todo changed while it was patched",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/todos/{id}/reminders": {
+            "get": {
+                "description": "Get every reminder of a todo, oldest first, whether sent or not",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reminders"
+                ],
+                "summary": "Get the reminders of a todo",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Todo ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Reminder"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Remind someone of a todo, either at a set time or a duration before its due date, such as 30m or 24h.\nReminders before the due date move with it, and are sent again when it moves after they were sent.\nThe log channel writes to the server log, webhook POSTs the reminder and its todo as JSON to the recipient URL,\nand email mails it to the recipient address when the server has SMTP configured.\nReminders of todos completed by the time they are due are skipped. The due date of an all-day todo\nstarts at midnight in the time zone of the X-User-ID user's preferences, or UTC without one.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reminders"
+                ],
+                "summary": "Create a reminder",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Todo ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Reminder to create",
+                        "name": "reminder",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ReminderCreate"
+                        }
+                    },
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Reminder"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/todos/{id}/reminders/{reminderId}": {
+            "get": {
+                "description": "Get a reminder of a todo, with its status and the outcome of its last attempt",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reminders"
+                ],
+                "summary": "Get a reminder by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Todo ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Reminder ID",
+                        "name": "reminderId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Reminder"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a reminder of a todo by its ID",
+                "tags": [
+                    "reminders"
+                ],
+                "summary": "Delete a reminder",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Todo ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Reminder ID",
+                        "name": "reminderId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks": {
+            "get": {
+                "description": "Get every webhook, oldest first. Secrets are not included.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Get all webhooks",
+                "parameters": [
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Webhook"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Subscribe an HTTP endpoint to todo events. Deliveries are POSTed as JSON and signed with HMAC-SHA256:\nX-Webhook-Signature is \"sha256=\" followed by the hex HMAC of X-Webhook-Timestamp, a dot and the body.\nThe secret is generated if omitted and only returned in this response.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Create a webhook",
+                "parameters": [
+                    {
+                        "description": "Webhook to create",
+                        "name": "webhook",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.WebhookCreate"
+                        }
+                    },
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Webhook"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}": {
+            "get": {
+                "description": "Get a webhook by its ID. The secret is not included.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Get a webhook by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Webhook"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a webhook by its ID, together with its deliveries",
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Delete a webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Update a webhook by its ID. Sending a secret rotates it.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Update a webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Webhook update data",
+                        "name": "webhook",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.WebhookUpdate"
+                        }
+                    },
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Webhook"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}/deliveries": {
+            "get": {
+                "description": "Get the 100 most recent deliveries of a webhook, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Get webhook deliveries",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.WebhookDelivery"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}/deliveries/{deliveryId}": {
+            "get": {
+                "description": "Get a delivery of a webhook including the log of every attempt made",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Get a webhook delivery",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Delivery ID",
+                        "name": "deliveryId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.WebhookDelivery"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}/deliveries/{deliveryId}/redeliver": {
+            "post": {
+                "description": "Queue a new delivery with the same payload as an earlier one, whatever its outcome",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Redeliver a webhook delivery",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Webhook ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Delivery ID",
+                        "name": "deliveryId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "legacy"
+                        ],
+                        "type": "string",
+                        "description": "Set to legacy for {success, message} error bodies instead of problem details",
+                        "name": "X-Error-Format",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/models.WebhookDelivery"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ProblemDetails"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "events.Viewers": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "description": "Count is the number of open views, including anonymous ones",
+                    "type": "integer"
+                },
+                "project": {
+                    "type": "string"
+                },
+                "users": {
+                    "description": "Users lists the distinct identified users, sorted",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "gql.Request": {
+            "type": "object",
+            "properties": {
+                "extensions": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "operationName": {
+                    "type": "string"
+                },
+                "query": {
+                    "type": "string"
+                },
+                "variables": {
+                    "type": "object",
+                    "additionalProperties": true
+                }
+            }
+        },
+        "handlers.SocketMessage": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "$ref": "#/definitions/utils.ProblemDetails"
+                },
+                "event": {
+                    "$ref": "#/definitions/models.TodoEvent"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "presence": {
+                    "$ref": "#/definitions/events.Viewers"
+                },
+                "todo": {
+                    "$ref": "#/definitions/models.Todo"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.CalendarToken": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "feed_url": {
+                    "description": "FeedURL is the address to subscribe to, including the token. It is\nonly returned when the token is created.",
+                    "type": "string"
+                },
+                "token": {
+                    "description": "Token is only returned when the token is created",
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Digest": {
+            "type": "object",
+            "properties": {
+                "generated_at": {
+                    "type": "string"
+                },
+                "sections": {
+                    "description": "Sections are always overdue, today and this_week, in that order",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.DigestSection"
+                    }
+                },
+                "timezone": {
+                    "type": "string"
+                },
+                "total": {
+                    "description": "Total counts the todos in every section",
+                    "type": "integer"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.DigestProject": {
+            "type": "object",
+            "properties": {
+                "project": {
+                    "type": "string"
+                },
+                "todos": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Todo"
+                    }
+                }
+            }
+        },
+        "models.DigestSection": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "projects": {
+                    "description": "Projects are sorted by name, with todos outside any project first",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.DigestProject"
+                    }
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ImportResult": {
+            "type": "object",
+            "properties": {
+                "committed": {
+                    "type": "boolean"
+                },
+                "created": {
+                    "description": "Created and Updated count the todos written, or that would be on a dry run",
+                    "type": "integer"
+                },
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ImportRowError"
+                    }
+                },
+                "ignored_columns": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "mode": {
+                    "type": "string"
+                },
+                "rows": {
+                    "type": "integer"
+                },
+                "todos": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ImportedTodo"
+                    }
+                },
+                "updated": {
+                    "type": "integer"
+                },
+                "warnings": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ImportRowError"
+                    }
+                }
+            }
+        },
+        "models.ImportRowError": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "row": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.ImportedTodo": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "Action is EventCreated or EventUpdated",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "row": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.Preferences": {
+            "type": "object",
+            "properties": {
+                "digest_frequency": {
+                    "type": "string"
+                },
+                "digest_hour": {
+                    "description": "DigestHour is the hour of the day, in Timezone, digests are sent at",
+                    "type": "integer"
+                },
+                "digest_weekday": {
+                    "description": "DigestWeekday is the day weekly digests are sent on, such as monday",
+                    "type": "string"
+                },
+                "email": {
+                    "description": "Email is where digests are sent",
+                    "type": "string"
+                },
+                "last_digest_at": {
+                    "description": "LastDigestAt is when a digest was last sent",
+                    "type": "string"
+                },
+                "last_digest_error": {
+                    "description": "LastDigestError is why the last attempt at a digest failed, if it did",
+                    "type": "string"
+                },
+                "next_digest_at": {
+                    "description": "NextDigestAt is when the next digest is due; nil while digests are off",
+                    "type": "string"
+                },
+                "timezone": {
+                    "description": "Timezone is an IANA time zone such as Europe/Berlin",
+                    "type": "string"
+                },
+                "updated_at": {
+                    "description": "UpdatedAt is when the preferences were set; nil for the defaults",
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.PreferencesReplace": {
+            "type": "object",
+            "properties": {
+                "digest_frequency": {
+                    "type": "string",
+                    "enum": [
+                        "off|daily|weekly"
+                    ]
+                },
+                "digest_hour": {
+                    "type": "integer",
+                    "maximum": 23,
+                    "minimum": 0
+                },
+                "digest_weekday": {
+                    "type": "string",
+                    "enum": [
+                        "sunday|monday|tuesday|wednesday|thursday|friday|saturday"
+                    ]
+                },
+                "email": {
+                    "type": "string",
+                    "maxLength": 320
+                },
+                "timezone": {
+                    "type": "string",
+                    "maxLength": 64
+                }
+            }
+        },
+        "models.Reminder": {
+            "type": "object",
+            "properties": {
+                "at": {
+                    "description": "At is when an absolute reminder fires",
+                    "type": "string"
+                },
+                "attempts": {
+                    "type": "integer"
+                },
+                "before": {
+                    "description": "Before is how long before the due date a relative reminder fires,\nas a duration such as \"30m\" or \"24h\"",
+                    "type": "string"
+                },
+                "channel": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "fire_at": {
+                    "description": "FireAt is when the reminder is due; it is empty while a relative\nreminder's todo has no due date",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "next_attempt_at": {
+                    "description": "NextAttemptAt is when a pending reminder is tried next, otherwise\nwhen it was last tried",
+                    "type": "string"
+                },
+                "recipient": {
+                    "type": "string"
+                },
+                "sent_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "todo_id": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "description": "UserID is who set the reminder; the due dates of all-day todos start\nat midnight in their time zone",
+                    "type": "string"
+                }
             }
         },
-        "models.TodoUpdate": {
+        "models.ReminderCreate": {
+            "type": "object",
+            "required": [
+                "channel"
+            ],
+            "properties": {
+                "at": {
+                    "type": "string"
+                },
+                "before": {
+                    "type": "string"
+                },
+                "channel": {
+                    "type": "string",
+                    "enum": [
+                        "log|webhook|email"
+                    ]
+                },
+                "recipient": {
+                    "type": "string",
+                    "maxLength": 2000
+                }
+            }
+        },
+        "models.Todo": {
             "type": "object",
             "properties": {
+                "all_day": {
+                    "type": "boolean"
+                },
                 "completed": {
                     "type": "boolean"
                 },
+                "created_at": {
+                    "type": "string"
+                },
                 "description": {
                     "type": "string"
                 },
                 "due_date": {
                     "type": "string"
                 },
+                "id": {
+                    "type": "string"
+                },
+                "parent_id": {
+                    "type": "string"
+                },
                 "priority": {
                     "type": "integer"
                 },
+                "project": {
+                    "type": "string"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
                 "title": {
                     "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "integer"
                 }
             }
         },
-        "utils.ErrorResponse": {
+        "models.TodoCreate": {
             "type": "object",
+            "required": [
+                "title"
+            ],
             "properties": {
-                "message": {
+                "description": {
+                    "type": "string",
+                    "maxLength": 2000
+                },
+                "due_date": {
+                    "type": "string"
+                },
+                "parent_id": {
+                    "type": "string"
+                },
+                "priority": {
+                    "type": "integer",
+                    "maximum": 5,
+                    "minimum": 0
+                },
+                "project": {
+                    "type": "string",
+                    "maxLength": 100
+                },
+                "tags": {
+                    "type": "array",
+                    "maxItems": 20,
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "title": {
+                    "type": "string",
+                    "maxLength": 200
+                }
+            }
+        },
+        "models.TodoEvent": {
+            "type": "object",
+            "properties": {
+                "actor": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "previous_project": {
+                    "description": "PreviousProject is set on updates moving the todo out of another\nproject, so subscribers of that project learn it left",
+                    "type": "string"
+                },
+                "todo": {
+                    "$ref": "#/definitions/models.Todo"
+                },
+                "todo_id": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.TodoReplace": {
+            "type": "object",
+            "required": [
+                "title"
+            ],
+            "properties": {
+                "completed": {
+                    "type": "boolean"
+                },
+                "description": {
+                    "type": "string",
+                    "maxLength": 2000
+                },
+                "due_date": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "parent_id": {
+                    "type": "string"
+                },
+                "priority": {
+                    "type": "integer",
+                    "maximum": 5,
+                    "minimum": 0
+                },
+                "project": {
+                    "type": "string",
+                    "maxLength": 100
+                },
+                "tags": {
+                    "type": "array",
+                    "maxItems": 20,
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "title": {
+                    "type": "string",
+                    "maxLength": 200
+                }
+            }
+        },
+        "models.TodoUpdate": {
+            "type": "object",
+            "properties": {
+                "completed": {
+                    "type": "boolean"
+                },
+                "description": {
+                    "type": "string",
+                    "maxLength": 2000
+                },
+                "due_date": {
+                    "type": "string"
+                },
+                "parent_id": {
+                    "type": "string"
+                },
+                "priority": {
+                    "type": "integer",
+                    "maximum": 5,
+                    "minimum": 0
+                },
+                "project": {
+                    "type": "string",
+                    "maxLength": 100
+                },
+                "tags": {
+                    "type": "array",
+                    "maxItems": 20,
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "title": {
+                    "type": "string",
+                    "maxLength": 200
+                }
+            }
+        },
+        "models.Webhook": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "events": {
+                    "description": "Events lists the event types delivered; empty means all of them",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "secret": {
+                    "description": "Secret signs deliveries. It is only returned when the webhook is created.",
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.WebhookAttempt": {
+            "type": "object",
+            "properties": {
+                "attempt": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "delivery_id": {
+                    "type": "string"
+                },
+                "duration_ms": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "response_status": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.WebhookCreate": {
+            "type": "object",
+            "required": [
+                "url"
+            ],
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "description": {
+                    "type": "string",
+                    "maxLength": 2000
+                },
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "type": "string",
+                        "enum": [
+                            "created|updated|deleted"
+                        ]
+                    }
+                },
+                "secret": {
+                    "type": "string",
+                    "maxLength": 200
+                },
+                "url": {
+                    "type": "string",
+                    "maxLength": 2000
+                }
+            }
+        },
+        "models.WebhookDelivery": {
+            "type": "object",
+            "properties": {
+                "attempt_log": {
+                    "description": "AttemptLog is only filled in when a single delivery is requested",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.WebhookAttempt"
+                    }
+                },
+                "attempts": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "event_id": {
+                    "type": "integer"
+                },
+                "event_type": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "next_attempt_at": {
+                    "description": "NextAttemptAt is when a pending delivery is tried next, otherwise when it was last tried",
+                    "type": "string"
+                },
+                "payload": {
+                    "description": "Payload is the exact request body sent on every attempt",
+                    "type": "object"
+                },
+                "redelivery_of": {
+                    "description": "RedeliveryOf is the ID of the delivery a redelivery repeats",
                     "type": "string"
                 },
-                "success": {
+                "response_status": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "webhook_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.WebhookUpdate": {
+            "type": "object",
+            "properties": {
+                "active": {
                     "type": "boolean"
+                },
+                "description": {
+                    "type": "string",
+                    "maxLength": 2000
+                },
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "type": "string",
+                        "enum": [
+                            "created|updated|deleted"
+                        ]
+                    }
+                },
+                "secret": {
+                    "type": "string",
+                    "maxLength": 200
+                },
+                "url": {
+                    "type": "string",
+                    "maxLength": 2000
+                }
+            }
+        },
+        "utils.ProblemDetails": {
+            "type": "object",
+            "properties": {
+                "detail": {
+                    "type": "string"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/utils.ProblemError"
+                    }
+                },
+                "instance": {
+                    "type": "string"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "integer"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.ProblemError": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
                 }
             }
         }
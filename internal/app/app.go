@@ -0,0 +1,346 @@
+package app
+
+import (
+	"context"
+	"log"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/swagger"
+	"github.com/teguh/go-todo-api/config"
+	"github.com/teguh/go-todo-api/internal/app/caldav"
+	"github.com/teguh/go-todo-api/internal/app/digest"
+	"github.com/teguh/go-todo-api/internal/app/events"
+	"github.com/teguh/go-todo-api/internal/app/gql"
+	"github.com/teguh/go-todo-api/internal/app/handlers"
+	"github.com/teguh/go-todo-api/internal/app/mailer"
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/outbox"
+	"github.com/teguh/go-todo-api/internal/app/reminders"
+	"github.com/teguh/go-todo-api/internal/app/repositories"
+	"github.com/teguh/go-todo-api/internal/app/rpc"
+	"github.com/teguh/go-todo-api/internal/app/services"
+	"github.com/teguh/go-todo-api/internal/app/webhooks"
+	"github.com/teguh/go-todo-api/internal/database"
+	"github.com/teguh/go-todo-api/internal/middleware"
+	"github.com/teguh/go-todo-api/pkg/utils"
+	"google.golang.org/grpc"
+)
+
+// Server is the API, served over HTTP and gRPC from the same services. The
+// caller serves both; shutting down HTTP also stops gRPC.
+type Server struct {
+	HTTP *fiber.App
+	GRPC *grpc.Server
+}
+
+// New opens the database configured in cfg and returns a fully wired Server.
+// The database is closed when the server shuts down.
+func New(cfg *config.Config) (*Server, error) {
+	db, err := database.Initialize(cfg.DatabaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	stores := NewStores(db)
+	var file *outbox.FileSink
+	if cfg.OutboxFile != "" {
+		if file, err = outbox.NewFileSink(cfg.OutboxFile); err != nil {
+			db.Close()
+			return nil, err
+		}
+		stores.Sinks = append(stores.Sinks, file)
+	}
+
+	// Shutdown hooks run in order, so the app's own hooks have stopped
+	// relaying events by the time the file and database are closed
+	server := NewWithStores(cfg, stores)
+	if file != nil {
+		server.HTTP.Hooks().OnShutdown(file.Close)
+	}
+	server.HTTP.Hooks().OnShutdown(db.Close)
+
+	return server, nil
+}
+
+// Stores bundles the persistence backends the app is built on
+type Stores struct {
+	// Todos must write its change events to Outbox
+	Todos    repositories.TodoStore
+	Outbox   repositories.OutboxStore
+	Events   repositories.EventStore
+	Webhooks repositories.WebhookStore
+	// Reminders belong to todos in Todos
+	Reminders repositories.ReminderStore
+	// CalendarTokens authenticates the calendar feed
+	CalendarTokens repositories.CalendarTokenStore
+	Preferences    repositories.PreferenceStore
+	// Sinks receive every relayed event after the event bus, webhooks and
+	// reminders
+	Sinks []outbox.Sink
+}
+
+// NewStores creates every store on top of db
+func NewStores(db *database.DB) Stores {
+	if db.Dialect == database.DialectMemory {
+		// In memory the outbox is only shared if handed to the todo store
+		box := repositories.NewMemoryOutboxRepository()
+		return Stores{
+			Todos:          repositories.NewMemoryTodoRepository(box),
+			Outbox:         box,
+			Events:         repositories.NewMemoryEventRepository(),
+			Webhooks:       repositories.NewMemoryWebhookRepository(),
+			Reminders:      repositories.NewMemoryReminderRepository(),
+			CalendarTokens: repositories.NewMemoryCalendarTokenRepository(),
+			Preferences:    repositories.NewMemoryPreferenceRepository(),
+		}
+	}
+
+	return Stores{
+		Todos:          repositories.NewTodoRepository(db),
+		Outbox:         repositories.NewOutboxRepository(db),
+		Events:         repositories.NewEventRepository(db),
+		Webhooks:       repositories.NewWebhookRepository(db),
+		Reminders:      repositories.NewReminderRepository(db),
+		CalendarTokens: repositories.NewCalendarTokenRepository(db),
+		Preferences:    repositories.NewPreferenceRepository(db),
+	}
+}
+
+// NewWithStores returns a Server serving data from stores.
+// It is the seam for running the API against fake or in-memory stores.
+func NewWithStores(cfg *config.Config, stores Stores) *Server {
+	// Create Fiber app
+	app := fiber.New(fiber.Config{
+		AppName:        cfg.AppName,
+		ErrorHandler:   customErrorHandler,
+		RequestMethods: slices.Concat(fiber.DefaultMethods, caldav.Methods),
+	})
+
+	// Setup middleware
+	middleware.SetupMiddleware(app)
+
+	// Wire dependencies: repository -> service -> handler
+	broker := events.NewBroker(stores.Events)
+	dispatcher := webhooks.NewDispatcher(stores.Webhooks, webhooks.Options{
+		Timeout:      cfg.WebhookTimeout,
+		MaxAttempts:  cfg.WebhookMaxAttempts,
+		RetryBase:    cfg.WebhookRetryBase,
+		PollInterval: cfg.WebhookPollInterval,
+		UserAgent:    cfg.AppName + " Webhooks",
+	})
+	webhookService := services.NewWebhookService(stores.Webhooks, dispatcher)
+	mail := newMailer(cfg)
+	scheduler := reminders.NewScheduler(stores.Reminders, stores.Todos, reminders.Options{
+		Notifiers:    newNotifiers(cfg, mail),
+		PollInterval: cfg.ReminderPollInterval,
+		Lease:        cfg.ReminderLease,
+		MaxAttempts:  cfg.ReminderMaxAttempts,
+		RetryBase:    cfg.ReminderRetryBase,
+	})
+	// Digests are only scheduled when there is a relay to mail them through
+	generator := digest.NewGenerator(stores.Todos)
+	var digests *digest.Scheduler
+	var digestWaker services.Waker
+	if mail != nil {
+		digests = digest.NewScheduler(stores.Preferences, generator, mail, digest.Options{
+			PollInterval:  cfg.DigestPollInterval,
+			Lease:         cfg.DigestLease,
+			RetryInterval: cfg.DigestRetryInterval,
+		})
+		digestWaker = digests
+	}
+	preferenceService := services.NewPreferenceService(stores.Preferences, digests != nil, digestWaker)
+	reminderService := services.NewReminderService(stores.Reminders, stores.Todos, preferenceService, scheduler.Channels(), scheduler)
+	digestService := services.NewDigestService(preferenceService, generator)
+	// Changes reach the event bus, webhooks, reminders and any extra sinks
+	// through the outbox
+	sinks := append([]outbox.Sink{broker, webhookService, reminderService}, stores.Sinks...)
+	relay := outbox.NewDispatcher(stores.Outbox, sinks, outbox.Options{
+		PollInterval: cfg.OutboxPollInterval,
+		Lease:        cfg.OutboxLease,
+	})
+	todoService := services.NewTodoService(stores.Todos, relay)
+	decoder := handlers.BodyDecoder{
+		Strict:   cfg.StrictJSON,
+		MaxBytes: cfg.MaxBodyBytes,
+	}
+	todoHandler := handlers.NewTodoHandler(todoService, preferenceService, decoder)
+	transferHandler := handlers.NewTransferHandler(todoService, preferenceService, cfg.ImportMaxRows)
+	calendarService := services.NewCalendarService(stores.CalendarTokens)
+	calendarHandler := handlers.NewCalendarHandler(calendarService, todoService, cfg.AppName, cfg.CalendarRefreshInterval)
+	calDAVHandler := caldav.NewHandler(todoService, calendarService, cfg.AppName)
+	eventHandler := handlers.NewEventHandler(broker, cfg.SSEHeartbeat)
+	socketHandler := handlers.NewSocketHandler(todoService, broker, events.NewPresence(), decoder)
+	webhookHandler := handlers.NewWebhookHandler(webhookService, decoder)
+	reminderHandler := handlers.NewReminderHandler(reminderService, decoder)
+	preferenceHandler := handlers.NewPreferenceHandler(preferenceService, digestService, decoder)
+	graphQLServer, err := gql.NewServer(todoService, gql.Options{
+		MaxComplexity: cfg.GraphQLMaxComplexity,
+		MaxDepth:      cfg.GraphQLMaxDepth,
+	})
+	if err != nil {
+		// The schema is static, so this is a programming error
+		panic(err)
+	}
+	graphQLHandler := handlers.NewGraphQLHandler(graphQLServer, decoder, cfg.Environment == "development")
+	grpcServer, healthServer := rpc.NewServer(rpc.NewTodoServer(todoService, broker))
+
+	// API routes; real-time and transfer routes first so /todos/events,
+	// /todos/ws and /todos/export are not matched as /todos/:id
+	api := app.Group("/api/v1")
+	eventHandler.RegisterRoutes(api)
+	socketHandler.RegisterRoutes(api)
+	transferHandler.RegisterRoutes(api)
+	todoHandler.RegisterRoutes(api)
+	reminderHandler.RegisterRoutes(api)
+	webhookHandler.RegisterRoutes(api)
+	graphQLHandler.RegisterRoutes(api)
+	calendarHandler.RegisterRoutes(api)
+	preferenceHandler.RegisterRoutes(api)
+
+	// CalDAV clients discover the server from /.well-known/caldav, so it is
+	// served outside the versioned API
+	calDAVHandler.RegisterRoutes(app)
+
+	// Relay the outbox, deliver webhooks and send reminders and digests in
+	// the background until shutdown
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+		relay.Run(relayCtx)
+	}()
+	// Replicas sharing the event log relay different outbox entries, so the
+	// broker also fans out the events the others logged
+	brokerCtx, stopBroker := context.WithCancel(context.Background())
+	brokerDone := make(chan struct{})
+	go func() {
+		defer close(brokerDone)
+		broker.Run(brokerCtx, cfg.OutboxPollInterval)
+	}()
+	dispatchCtx, stopDispatch := context.WithCancel(context.Background())
+	dispatchDone := make(chan struct{})
+	go func() {
+		defer close(dispatchDone)
+		dispatcher.Run(dispatchCtx)
+	}()
+	remindCtx, stopReminders := context.WithCancel(context.Background())
+	remindDone := make(chan struct{})
+	go func() {
+		defer close(remindDone)
+		scheduler.Run(remindCtx)
+	}()
+	digestCtx, stopDigests := context.WithCancel(context.Background())
+	digestDone := make(chan struct{})
+	go func() {
+		defer close(digestDone)
+		if digests != nil {
+			digests.Run(digestCtx)
+		}
+	}()
+
+	// Hooks run once HTTP requests have finished, so the relay's final pass
+	// flushes their changes to subscribers that are still connected. Streams
+	// end when the server shuts down, but WebSockets and gRPC watches only
+	// end once the broker closes, after which gRPC waits up to the shutdown
+	// timeout for its remaining calls. Anything left in the outbox, and
+	// deliveries, reminders and digests cut short, are picked up on the next
+	// start.
+	app.Hooks().OnShutdown(func() error {
+		healthServer.Shutdown()
+		stopRelay()
+		<-relayDone
+		stopBroker()
+		<-brokerDone
+		broker.Close()
+		rpc.Stop(grpcServer, cfg.ShutdownTimeout)
+		stopDispatch()
+		<-dispatchDone
+		stopReminders()
+		<-remindDone
+		stopDigests()
+		<-digestDone
+		return nil
+	})
+
+	// Swagger documentation
+	app.Get("/swagger/*", swagger.HandlerDefault)
+
+	// Health check endpoint
+	app.Get("/health", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{
+			"status": "ok",
+			"time":   time.Now(),
+		})
+	})
+
+	// Root route - redirect to Swagger
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.Redirect("/swagger/", fiber.StatusMovedPermanently)
+	})
+
+	return &Server{
+		HTTP: app,
+		GRPC: grpcServer,
+	}
+}
+
+// newMailer returns the mailer of the SMTP relay configured in cfg, or nil
+// if there is none. A misconfigured relay disables email.
+func newMailer(cfg *config.Config) *mailer.Mailer {
+	if cfg.SMTPHost == "" {
+		return nil
+	}
+	m, err := mailer.New(mailer.Options{
+		Addr:     net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort)),
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		From:     cfg.SMTPFrom,
+		Timeout:  cfg.SMTPTimeout,
+	})
+	if err != nil {
+		log.Printf("Email disabled: %v", err)
+		return nil
+	}
+	return m
+}
+
+// newNotifiers returns the notifiers of every reminder channel enabled by
+// cfg. Email needs mail, the mailer of an SMTP relay.
+func newNotifiers(cfg *config.Config, mail *mailer.Mailer) map[string]reminders.Notifier {
+	notifiers := map[string]reminders.Notifier{
+		models.ChannelLog:     reminders.NewLogNotifier(nil),
+		models.ChannelWebhook: reminders.NewWebhookNotifier(cfg.ReminderTimeout, cfg.ReminderWebhookSecret, cfg.AppName+" Reminders"),
+	}
+	if mail != nil {
+		notifiers[models.ChannelEmail] = reminders.NewSMTPNotifier(mail)
+	}
+	return notifiers
+}
+
+// errorFormatHeader lets clients opt back into the legacy {success, message} error shape
+const errorFormatHeader = "X-Error-Format"
+
+// customErrorHandler handles errors thrown by Fiber and returned by handlers,
+// rendering them as application/problem+json
+func customErrorHandler(c *fiber.Ctx, err error) error {
+	problem := handlers.ErrorProblem(err)
+
+	// Log unexpected errors; the client only sees a generic message
+	if problem.Status == fiber.StatusInternalServerError {
+		log.Printf("Error: %v", err)
+	}
+
+	if strings.EqualFold(c.Get(errorFormatHeader), "legacy") {
+		return utils.SendError(c, problem.Status, problem.Detail)
+	}
+
+	problem.Instance = c.OriginalURL()
+	problem.RequestID = middleware.RequestID(c)
+	return utils.SendProblem(c, problem)
+}
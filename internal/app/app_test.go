@@ -0,0 +1,129 @@
+package app_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/teguh/go-todo-api/config"
+	"github.com/teguh/go-todo-api/internal/app"
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/database"
+)
+
+// send makes a request to a and decodes a JSON response into out, if it is not nil
+func send(t *testing.T, a *fiber.App, method, path, body string, out interface{}) int {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := a.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(data, out); err != nil {
+			t.Fatalf("%s %s: %v in %s", method, path, err, data)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestAPIRunsOnTheMemoryStore(t *testing.T) {
+	// Nothing is written to the working directory
+	dir := t.TempDir()
+	t.Chdir(dir)
+	t.Setenv("DATABASE_PATH", ":memory:")
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DatabaseURL != "memory://" {
+		t.Fatalf("DATABASE_PATH=:memory: selects %q", cfg.DatabaseURL)
+	}
+
+	db, err := database.Initialize(cfg.DatabaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Dialect != database.DialectMemory {
+		t.Fatalf("dialect = %s", db.Dialect)
+	}
+	server := app.NewWithStores(cfg, app.NewStores(db))
+	t.Cleanup(func() { server.HTTP.Shutdown() })
+	api := server.HTTP
+
+	var created models.Todo
+	if status := send(t, api, http.MethodPost, "/api/v1/todos", `{"title": "Buy milk", "tags": ["Shopping"], "priority": 2}`, &created); status != http.StatusCreated {
+		t.Fatalf("create = %d", status)
+	}
+	var other models.Todo
+	if status := send(t, api, http.MethodPost, "/api/v1/todos", `{"title": "Walk the dog", "priority": 4}`, &other); status != http.StatusCreated {
+		t.Fatalf("create = %d", status)
+	}
+
+	var fetched models.Todo
+	if status := send(t, api, http.MethodGet, "/api/v1/todos/"+created.ID, "", &fetched); status != http.StatusOK || fetched.Title != "Buy milk" || len(fetched.Tags) != 1 || fetched.Tags[0] != "shopping" {
+		t.Errorf("get = %d, %+v", status, fetched)
+	}
+
+	// Listing filters and sorts as the SQL stores do
+	var listed []models.Todo
+	if status := send(t, api, http.MethodGet, "/api/v1/todos", "", &listed); status != http.StatusOK || len(listed) != 2 || listed[0].ID != other.ID {
+		t.Errorf("list = %d, %+v", status, listed)
+	}
+	if status := send(t, api, http.MethodGet, "/api/v1/todos?tag=shopping", "", &listed); status != http.StatusOK || len(listed) != 1 || listed[0].ID != created.ID {
+		t.Errorf("list by tag = %d, %+v", status, listed)
+	}
+
+	var updated models.Todo
+	if status := send(t, api, http.MethodPut, "/api/v1/todos/"+created.ID, `{"title": "Buy oat milk", "completed": true}`, &updated); status != http.StatusOK || !updated.Completed || updated.Version != 2 {
+		t.Errorf("replace = %d, %+v", status, updated)
+	}
+
+	if status := send(t, api, http.MethodDelete, "/api/v1/todos/"+created.ID, "", nil); status != http.StatusNoContent {
+		t.Errorf("delete = %d", status)
+	}
+	if status := send(t, api, http.MethodGet, "/api/v1/todos/"+created.ID, "", nil); status != http.StatusNotFound {
+		t.Errorf("get deleted = %d, want 404", status)
+	}
+
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Errorf("working directory holds %v, %v", entries, err)
+	}
+}
+
+func TestBrowsersMayAskForLegacyErrors(t *testing.T) {
+	db, err := database.Initialize("memory://")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := app.NewWithStores(&config.Config{AppName: "Todo API", DatabaseURL: "memory://"}, app.NewStores(db))
+	t.Cleanup(func() { server.HTTP.Shutdown() })
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/v1/todos/missing", nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	req.Header.Set("Access-Control-Request-Headers", "x-error-format")
+	resp, err := server.HTTP.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if allowed := resp.Header.Get("Access-Control-Allow-Headers"); !strings.Contains(allowed, "X-Error-Format") {
+		t.Errorf("preflight allows %q, want X-Error-Format", allowed)
+	}
+}
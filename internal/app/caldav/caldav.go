@@ -0,0 +1,376 @@
+// Package caldav serves todos as a CalDAV calendar (RFC 4791) of VTODOs, so
+// task apps can sync them both ways.
+//
+// The server is deliberately minimal. There is one principal, whose home
+// holds one calendar, /caldav/todos/, with a resource <id>.ics per todo.
+// PROPFIND, the calendar-query and calendar-multiget reports, and GET, PUT
+// and DELETE of resources are supported; PROPPATCH, MKCALENDAR, locking and
+// sync-collection are not. Clients sign in with HTTP Basic authentication,
+// giving their user ID and calendar token.
+//
+// Every change goes through the TodoService, so it is validated and
+// recorded like any other. ETags derive from the version of todos.
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/teguh/go-todo-api/internal/app/ical"
+	"github.com/teguh/go-todo-api/internal/app/identity"
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/services"
+)
+
+// WebDAV methods beyond those of HTTP
+const (
+	MethodPropfind = "PROPFIND"
+	MethodReport   = "REPORT"
+)
+
+// Methods lists the request methods the server needs beyond Fiber's
+// defaults; they must be added to the app's RequestMethods
+var Methods = []string{MethodPropfind, MethodReport}
+
+// Paths of the principal, which is also the calendar home, and of the
+// calendar
+const (
+	rootPath       = "/caldav/"
+	collectionPath = rootPath + "todos/"
+)
+
+// objectExt is the extension of the resource of each todo
+const objectExt = ".ics"
+
+// objectContentType is the media type of the resource of each todo
+const objectContentType = "text/calendar; charset=utf-8; component=vtodo"
+
+// davHeader advertises the WebDAV classes and CalDAV support
+const davHeader = "1, 3, calendar-access"
+
+// allowHeader lists the methods resources allow
+const allowHeader = "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT"
+
+// kind tells the resources of the server apart
+type kind int
+
+const (
+	kindRoot kind = iota
+	kindCollection
+	kindObject
+)
+
+// resource is a resource of the server. Objects have the ID of their todo,
+// and the todo itself if it exists.
+type resource struct {
+	kind kind
+	id   string
+	todo *models.Todo
+}
+
+// href returns the path of r
+func (r resource) href() string {
+	switch r.kind {
+	case kindRoot:
+		return rootPath
+	case kindCollection:
+		return collectionPath
+	default:
+		return collectionPath + r.id + objectExt
+	}
+}
+
+// Handler serves the CalDAV calendar of todos
+type Handler struct {
+	todos     *services.TodoService
+	calendars *services.CalendarService
+	name      string
+}
+
+// NewHandler creates a new Handler. Clients authenticate with calendar
+// tokens from calendars and show the calendar as name.
+func NewHandler(todos *services.TodoService, calendars *services.CalendarService, name string) *Handler {
+	return &Handler{
+		todos:     todos,
+		calendars: calendars,
+		name:      name,
+	}
+}
+
+// RegisterRoutes registers the CalDAV routes and the well-known URL clients
+// discover them from. It must be given the app itself, as clients expect
+// the well-known URL at the root.
+func (h *Handler) RegisterRoutes(router fiber.Router) {
+	router.Get("/.well-known/caldav", h.wellKnown)
+	router.Add(MethodPropfind, "/.well-known/caldav", h.wellKnown)
+
+	dav := router.Group(strings.TrimSuffix(rootPath, "/"), h.authenticate)
+	dav.Options("/*", h.options)
+	dav.Add(MethodPropfind, "/*", h.propfind)
+	dav.Add(MethodReport, "/*", h.report)
+	dav.Get("/*", h.get)
+	dav.Put("/*", h.put)
+	dav.Delete("/*", h.delete)
+}
+
+// wellKnown redirects clients to the principal (RFC 6764)
+func (h *Handler) wellKnown(c *fiber.Ctx) error {
+	return c.Redirect(rootPath, fiber.StatusMovedPermanently)
+}
+
+// authenticate checks the Basic credentials of requests, whose username
+// must be the user a calendar token was issued to and whose password is
+// the token, and makes that user the acting user. OPTIONS needs none.
+func (h *Handler) authenticate(c *fiber.Ctx) error {
+	if c.Method() == fiber.MethodOptions {
+		return c.Next()
+	}
+
+	username, password, ok := basicAuth(c.Get(fiber.HeaderAuthorization))
+	if !ok {
+		return h.challenge(c, fmt.Errorf("%w: sign in with your user ID and calendar token", models.ErrUnauthorized))
+	}
+	userID, err := h.calendars.Authenticate(password)
+	if err != nil {
+		if errors.Is(err, models.ErrUnauthorized) {
+			return h.challenge(c, err)
+		}
+		return err
+	}
+	if userID != username {
+		return h.challenge(c, fmt.Errorf("%w: invalid calendar token", models.ErrUnauthorized))
+	}
+
+	c.SetUserContext(identity.WithUser(c.UserContext(), userID))
+	return c.Next()
+}
+
+// challenge asks the client for credentials alongside err
+func (h *Handler) challenge(c *fiber.Ctx, err error) error {
+	c.Set(fiber.HeaderWWWAuthenticate, fmt.Sprintf(`Basic realm=%q, charset="UTF-8"`, h.name))
+	return err
+}
+
+// basicAuth reads the credentials of a Basic Authorization header
+func basicAuth(header string) (username, password string, ok bool) {
+	scheme, credentials, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Basic") {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(credentials))
+	if err != nil {
+		return "", "", false
+	}
+	return strings.Cut(string(decoded), ":")
+}
+
+// options advertises what the server supports
+func (h *Handler) options(c *fiber.Ctx) error {
+	c.Set("DAV", davHeader)
+	c.Set(fiber.HeaderAllow, allowHeader)
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// get serves the VCALENDAR of a todo
+func (h *Handler) get(c *fiber.Ctx) error {
+	res, err := h.resolve(c.UserContext(), c.Params("*"))
+	if err != nil {
+		return err
+	}
+	if res.kind != kindObject {
+		c.Set(fiber.HeaderAllow, "OPTIONS, PROPFIND, REPORT")
+		return fiber.NewError(fiber.StatusMethodNotAllowed, "collections cannot be downloaded; use PROPFIND or REPORT")
+	}
+	if res.todo == nil {
+		return models.ErrNotFound
+	}
+
+	data, err := calendarData(res.todo)
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, objectContentType)
+	c.Set(fiber.HeaderETag, etag(res.todo))
+	c.Set(fiber.HeaderLastModified, res.todo.UpdatedAt.UTC().Format(http.TimeFormat))
+	return c.Send(data)
+}
+
+// put creates or replaces a todo from a VCALENDAR holding one VTODO. The
+// todo is stored as the API represents it, which may differ from what was
+// sent, so no ETag is returned and clients fetch it again (RFC 4791 5.3.4).
+func (h *Handler) put(c *fiber.Ctx) error {
+	res, err := h.resolve(c.UserContext(), c.Params("*"))
+	if err != nil {
+		return err
+	}
+	if res.kind != kindObject {
+		return fiber.NewError(fiber.StatusMethodNotAllowed, "only calendar objects can be written")
+	}
+
+	mediaType, _, _ := strings.Cut(c.Get(fiber.HeaderContentType), ";")
+	if !strings.EqualFold(strings.TrimSpace(mediaType), "text/calendar") {
+		return fiber.NewError(fiber.StatusUnsupportedMediaType, "calendar objects must be sent as text/calendar")
+	}
+
+	calendar, err := ical.Decode(bytes.NewReader(c.Body()))
+	if err != nil {
+		var syntaxErr *ical.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("malformed iCalendar on %v", syntaxErr))
+		}
+		return err
+	}
+	var component *ical.Component
+	for _, child := range calendar.Children {
+		switch {
+		case child.Name == ical.Todo && component == nil:
+			component = child
+		case child.Name == ical.Todo:
+			return fiber.NewError(fiber.StatusForbidden, "recurring todos are not supported; send a single VTODO")
+		case child.Name != "VTIMEZONE":
+			return fiber.NewError(fiber.StatusForbidden, fmt.Sprintf("the calendar only holds VTODOs, not %s", child.Name))
+		}
+	}
+	if component == nil {
+		return fiber.NewError(fiber.StatusForbidden, "the calendar object must hold a VTODO")
+	}
+	replace, fieldErrs := ical.ReadTodo(component)
+	if len(fieldErrs) > 0 {
+		return &models.ValidationError{Fields: fieldErrs}
+	}
+
+	pre, err := checkPreconditions(c, res.todo)
+	if err != nil {
+		return err
+	}
+
+	_, created, err := h.todos.ReplaceTodoIf(c.UserContext(), res.id, replace, pre)
+	if err != nil {
+		return preconditionFailed(err)
+	}
+	if created {
+		c.Location(res.href())
+		return c.SendStatus(fiber.StatusCreated)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// delete deletes a todo
+func (h *Handler) delete(c *fiber.Ctx) error {
+	res, err := h.resolve(c.UserContext(), c.Params("*"))
+	if err != nil {
+		return err
+	}
+	if res.kind != kindObject {
+		return fiber.NewError(fiber.StatusForbidden, "the calendar cannot be deleted")
+	}
+	if res.todo == nil {
+		return models.ErrNotFound
+	}
+	pre, err := checkPreconditions(c, res.todo)
+	if err != nil {
+		return err
+	}
+
+	if err := h.todos.DeleteTodoIf(c.UserContext(), res.id, pre); err != nil {
+		return preconditionFailed(err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// checkPreconditions evaluates If-Match and If-None-Match against todo,
+// which is nil if it does not exist, and returns the precondition the write
+// must still meet so that a change made meanwhile is not overwritten
+func checkPreconditions(c *fiber.Ctx, todo *models.Todo) (services.Precondition, error) {
+	var pre services.Precondition
+	if match := c.Get(fiber.HeaderIfMatch); match != "" {
+		if todo == nil || !matchETag(match, etag(todo)) {
+			return pre, fiber.NewError(fiber.StatusPreconditionFailed, "the calendar object has changed")
+		}
+		pre.Version = todo.Version
+	}
+	if noneMatch := c.Get(fiber.HeaderIfNoneMatch); noneMatch != "" {
+		if todo != nil && matchETag(noneMatch, etag(todo)) {
+			return pre, fiber.NewError(fiber.StatusPreconditionFailed, "the calendar object already exists")
+		}
+		pre.Absent = strings.TrimSpace(noneMatch) == "*"
+	}
+	return pre, nil
+}
+
+// preconditionFailed turns the error of a write whose precondition no
+// longer held into 412 Precondition Failed
+func preconditionFailed(err error) error {
+	if errors.Is(err, models.ErrVersionMismatch) {
+		return fiber.NewError(fiber.StatusPreconditionFailed, "the calendar object has changed")
+	}
+	return err
+}
+
+// matchETag reports whether an If-Match or If-None-Match header lists tag
+func matchETag(header, tag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// etag returns the ETag of the resource of todo. Versions restart when a
+// todo is deleted and created again, so its creation time is part of it.
+func etag(todo *models.Todo) string {
+	return fmt.Sprintf(`"%d-%d"`, todo.Version, todo.CreatedAt.Unix())
+}
+
+// calendarData renders todo as a calendar object
+func calendarData(todo *models.Todo) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := ical.NewWriter(&buf, ical.WriterOptions{})
+	if err := writer.Write(todo); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// resolve returns the resource at a path below the root, loading the todo
+// of objects. Unknown paths are not found.
+func (h *Handler) resolve(ctx context.Context, path string) (resource, error) {
+	path = strings.TrimSuffix(path, "/")
+	collection := strings.Trim(strings.TrimPrefix(collectionPath, rootPath), "/")
+
+	switch {
+	case path == "":
+		return resource{kind: kindRoot}, nil
+	case path == collection:
+		return resource{kind: kindCollection}, nil
+	}
+
+	name, found := strings.CutPrefix(path, collection+"/")
+	if !found || strings.Contains(name, "/") || !strings.HasSuffix(name, objectExt) {
+		return resource{}, fiber.NewError(fiber.StatusNotFound, "no such resource")
+	}
+	name, err := url.PathUnescape(name)
+	if err != nil {
+		return resource{}, fiber.NewError(fiber.StatusNotFound, "no such resource")
+	}
+
+	res := resource{kind: kindObject, id: strings.Clone(strings.TrimSuffix(name, objectExt))}
+	todo, err := h.todos.GetTodoByID(ctx, res.id)
+	if err != nil && !errors.Is(err, models.ErrNotFound) {
+		return resource{}, err
+	}
+	res.todo = todo
+	return res, nil
+}
@@ -0,0 +1,261 @@
+package caldav_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/emersion/go-webdav"
+	webdavcaldav "github.com/emersion/go-webdav/caldav"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/teguh/go-todo-api/internal/app/caldav"
+	"github.com/teguh/go-todo-api/internal/app/identity"
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/repositories"
+	"github.com/teguh/go-todo-api/internal/app/services"
+)
+
+// racingStore runs race once, right after the todo it was armed with is
+// next read, as a request changing that todo concurrently would
+type racingStore struct {
+	repositories.TodoStore
+
+	mu   sync.Mutex
+	id   string
+	race func()
+}
+
+func (s *racingStore) arm(id string, race func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.id, s.race = id, race
+}
+
+func (s *racingStore) GetByID(id string) (*models.Todo, error) {
+	todo, err := s.TodoStore.GetByID(id)
+
+	s.mu.Lock()
+	race := s.race
+	if id == s.id {
+		s.race = nil
+	} else {
+		race = nil
+	}
+	s.mu.Unlock()
+
+	if race != nil {
+		race()
+	}
+	return todo, err
+}
+
+// conditionalClient sends the WebDAV client's requests with the headers
+// set for the next one, as the client cannot send If-Match itself, and
+// remembers the status of the last response
+type conditionalClient struct {
+	header http.Header
+	status int
+}
+
+func (c *conditionalClient) Do(req *http.Request) (*http.Response, error) {
+	for name, values := range c.header {
+		req.Header[name] = values
+	}
+	c.header = nil
+
+	resp, err := http.DefaultClient.Do(req)
+	if err == nil {
+		c.status = resp.StatusCode
+	}
+	return resp, err
+}
+
+// next sets a header of the next request
+func (c *conditionalClient) next(name, value string) {
+	c.header = http.Header{name: {value}}
+}
+
+type fixture struct {
+	todos  *services.TodoService
+	store  *racingStore
+	http   *conditionalClient
+	client *webdavcaldav.Client
+}
+
+// newFixture serves the calendar on a local port and signs a CalDAV client in
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+
+	store := &racingStore{TodoStore: repositories.NewMemoryTodoRepository(repositories.NewMemoryOutboxRepository())}
+	todos := services.NewTodoService(store, nil)
+	calendars := services.NewCalendarService(repositories.NewMemoryCalendarTokenRepository())
+	token, err := calendars.CreateToken(identity.WithUser(context.Background(), "alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+		RequestMethods:        slices.Concat(fiber.DefaultMethods, caldav.Methods),
+	})
+	caldav.NewHandler(todos, calendars, "Todos").RegisterRoutes(app)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(listener)
+	t.Cleanup(func() { app.Shutdown() })
+
+	conditional := &conditionalClient{}
+	client, err := webdavcaldav.NewClient(
+		webdav.HTTPClientWithBasicAuth(conditional, "alice", token.Token),
+		"http://"+listener.Addr().String()+"/caldav/",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fixture{todos: todos, store: store, http: conditional, client: client}
+}
+
+// vtodo returns a calendar holding a VTODO with the given UID and summary
+func vtodo(uid, summary string) *ical.Calendar {
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropVersion, "2.0")
+	cal.Props.SetText(ical.PropProductID, "-//test//EN")
+	todo := ical.NewComponent(ical.CompToDo)
+	todo.Props.SetText(ical.PropUID, uid)
+	todo.Props.SetText(ical.PropSummary, summary)
+	todo.Props.SetDateTime(ical.PropDateTimeStamp, time.Now().UTC())
+	cal.Children = append(cal.Children, todo)
+	return cal
+}
+
+func objectPath(id string) string {
+	return "/caldav/todos/" + id + ".ics"
+}
+
+func TestClientDiscoversAndSyncsTodos(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	todo, err := f.todos.CreateTodo(ctx, models.TodoCreate{Title: "Water the plants"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	principal, err := f.client.FindCurrentUserPrincipal(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	home, err := f.client.FindCalendarHomeSet(ctx, principal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calendars, err := f.client.FindCalendars(ctx, home)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calendars) != 1 || calendars[0].Path != "/caldav/todos/" {
+		t.Fatalf("calendars = %+v", calendars)
+	}
+
+	objects, err := f.client.QueryCalendar(ctx, calendars[0].Path, &webdavcaldav.CalendarQuery{
+		CompRequest: webdavcaldav.CalendarCompRequest{Name: "VCALENDAR", Comps: []webdavcaldav.CalendarCompRequest{{Name: "VTODO", AllProps: true}}},
+		CompFilter:  webdavcaldav.CompFilter{Name: "VCALENDAR", Comps: []webdavcaldav.CompFilter{{Name: "VTODO"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || objects[0].Path != objectPath(todo.ID) || objects[0].ETag == "" {
+		t.Fatalf("objects = %+v", objects)
+	}
+
+	// A todo written by the client is created, then replaced while its ETag holds
+	id := uuid.New().String()
+	if _, err := f.client.PutCalendarObject(ctx, objectPath(id), vtodo(id, "Call the plumber")); err != nil {
+		t.Fatal(err)
+	}
+	object, err := f.client.GetCalendarObject(ctx, objectPath(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.http.next("If-Match", strconv.Quote(object.ETag))
+	if _, err := f.client.PutCalendarObject(ctx, objectPath(id), vtodo(id, "Call the plumber again")); err != nil {
+		t.Fatal(err)
+	}
+	f.http.next("If-Match", strconv.Quote(object.ETag))
+	if _, err := f.client.PutCalendarObject(ctx, objectPath(id), vtodo(id, "Stale")); err == nil || f.http.status != http.StatusPreconditionFailed {
+		t.Fatalf("put with a stale ETag: %v (status %d), want 412", err, f.http.status)
+	}
+	if stored, _ := f.todos.GetTodoByID(ctx, id); stored.Title != "Call the plumber again" || stored.Version != 2 {
+		t.Errorf("stored todo = %+v", stored)
+	}
+}
+
+func TestPreconditionsHoldAgainstConcurrentWrites(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	todo, err := f.todos.CreateTodo(ctx, models.TodoCreate{Title: "Original"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	object, err := f.client.GetCalendarObject(ctx, objectPath(todo.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	changeMeanwhile := func() {
+		title := "Changed meanwhile"
+		if _, err := f.todos.UpdateTodo(ctx, todo.ID, models.TodoUpdate{Title: &title}); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// The ETag matches when the request reads the todo, but the todo
+	// changes before the write
+	f.store.arm(todo.ID, changeMeanwhile)
+	f.http.next("If-Match", strconv.Quote(object.ETag))
+	if _, err := f.client.PutCalendarObject(ctx, objectPath(todo.ID), vtodo(todo.ID, "Overwrite")); err == nil || f.http.status != http.StatusPreconditionFailed {
+		t.Errorf("put racing a change: %v (status %d), want 412", err, f.http.status)
+	}
+	stored, _ := f.todos.GetTodoByID(ctx, todo.ID)
+	if stored.Title != "Changed meanwhile" {
+		t.Errorf("concurrent change was overwritten: %+v", stored)
+	}
+
+	object, err = f.client.GetCalendarObject(ctx, objectPath(todo.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.store.arm(todo.ID, changeMeanwhile)
+	f.http.next("If-Match", strconv.Quote(object.ETag))
+	if err := f.client.RemoveAll(ctx, objectPath(todo.ID)); err == nil || f.http.status != http.StatusPreconditionFailed {
+		t.Errorf("delete racing a change: %v (status %d), want 412", err, f.http.status)
+	}
+	if stored, _ := f.todos.GetTodoByID(ctx, todo.ID); stored == nil {
+		t.Error("todo changed meanwhile was deleted")
+	}
+
+	// If-None-Match: * only creates, even when another client creates the
+	// todo first
+	id := uuid.New().String()
+	f.store.arm(id, func() {
+		if _, _, err := f.todos.ReplaceTodo(ctx, id, models.TodoReplace{Title: "Created meanwhile"}); err != nil {
+			t.Error(err)
+		}
+	})
+	f.http.next("If-None-Match", "*")
+	if _, err := f.client.PutCalendarObject(ctx, objectPath(id), vtodo(id, "Overwrite")); err == nil || f.http.status != http.StatusPreconditionFailed {
+		t.Errorf("create racing a create: %v (status %d), want 412", err, f.http.status)
+	}
+	if stored, _ := f.todos.GetTodoByID(ctx, id); stored == nil || stored.Title != "Created meanwhile" {
+		t.Errorf("todo created meanwhile = %+v", stored)
+	}
+}
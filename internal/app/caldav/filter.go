@@ -0,0 +1,141 @@
+package caldav
+
+import (
+	"strings"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/ical"
+)
+
+// timeRangeFormat is the format of the start and end of a time-range
+const timeRangeFormat = "20060102T150405Z"
+
+// matchFilter reports whether calendar, a VCALENDAR holding one calendar
+// object, matches the C:filter of a calendar-query. A filter holds a single
+// comp-filter for VCALENDAR.
+func matchFilter(filter *node, calendar *ical.Component) bool {
+	compFilter := filter.child(nsCalDAV, "comp-filter")
+	if compFilter == nil {
+		return true
+	}
+	return matchComp(compFilter, []*ical.Component{calendar})
+}
+
+// matchComp reports whether the components named by a comp-filter among
+// candidates match it: any of them must, or none may exist for
+// is-not-defined
+func matchComp(filter *node, candidates []*ical.Component) bool {
+	name := strings.ToUpper(filter.attr("name"))
+	var comps []*ical.Component
+	for _, c := range candidates {
+		if c.Name == name {
+			comps = append(comps, c)
+		}
+	}
+
+	if filter.child(nsCalDAV, "is-not-defined") != nil {
+		return len(comps) == 0
+	}
+	for _, c := range comps {
+		if matchCompConditions(filter, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchCompConditions reports whether c matches the time-range, prop-filters
+// and nested comp-filters of a comp-filter
+func matchCompConditions(filter *node, c *ical.Component) bool {
+	if timeRange := filter.child(nsCalDAV, "time-range"); timeRange != nil && !matchTimeRange(timeRange, c) {
+		return false
+	}
+	for _, propFilter := range filter.all(nsCalDAV, "prop-filter") {
+		if !matchProp(propFilter, c) {
+			return false
+		}
+	}
+	for _, compFilter := range filter.all(nsCalDAV, "comp-filter") {
+		if !matchComp(compFilter, c.Children) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchProp reports whether c matches a prop-filter: it must have the
+// property, one of whose values matches the text-match if there is one, or
+// lack it for is-not-defined. Parameter filters are not supported and match.
+func matchProp(filter *node, c *ical.Component) bool {
+	props := c.GetAll(strings.ToUpper(filter.attr("name")))
+	if filter.child(nsCalDAV, "is-not-defined") != nil {
+		return len(props) == 0
+	}
+	if len(props) == 0 {
+		return false
+	}
+
+	textMatch := filter.child(nsCalDAV, "text-match")
+	if textMatch == nil {
+		return true
+	}
+	for _, prop := range props {
+		if matchText(textMatch, prop.Text()) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchText reports whether value matches a text-match, which is a
+// substring search that is case-insensitive unless the collation is i;octet
+func matchText(filter *node, value string) bool {
+	needle := filter.text
+	var matched bool
+	if filter.attr("collation") == "i;octet" {
+		matched = strings.Contains(value, needle)
+	} else {
+		matched = strings.Contains(strings.ToLower(value), strings.ToLower(needle))
+	}
+	if filter.attr("negate-condition") == "yes" {
+		return !matched
+	}
+	return matched
+}
+
+// matchTimeRange reports whether a VTODO overlaps a time-range, following
+// RFC 4791 for the properties todos have: one with a DUE overlaps if it is
+// due within the range, and one without if it was created before its end.
+// Malformed bounds are ignored.
+func matchTimeRange(filter *node, c *ical.Component) bool {
+	start, hasStart := parseRangeBound(filter.attr("start"))
+	end, hasEnd := parseRangeBound(filter.attr("end"))
+
+	if prop := c.Get("DUE"); prop != nil {
+		due, err := ical.ParseDateTime(prop)
+		if err != nil {
+			return false
+		}
+		return (!hasStart || start.Before(due)) && (!hasEnd || !end.Before(due))
+	}
+	if prop := c.Get("CREATED"); prop != nil && hasEnd {
+		created, err := ical.ParseDateTime(prop)
+		if err != nil {
+			return false
+		}
+		return end.After(created)
+	}
+	return true
+}
+
+// parseRangeBound reads the start or end of a time-range
+func parseRangeBound(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(timeRangeFormat, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
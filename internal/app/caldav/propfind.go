@@ -0,0 +1,373 @@
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/teguh/go-todo-api/internal/app/ical"
+	"github.com/teguh/go-todo-api/internal/app/identity"
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+// Properties served
+var (
+	propResourceType       = xml.Name{Space: nsDAV, Local: "resourcetype"}
+	propDisplayName        = xml.Name{Space: nsDAV, Local: "displayname"}
+	propPrincipal          = xml.Name{Space: nsDAV, Local: "current-user-principal"}
+	propPrincipalURL       = xml.Name{Space: nsDAV, Local: "principal-URL"}
+	propPrivileges         = xml.Name{Space: nsDAV, Local: "current-user-privilege-set"}
+	propSupportedReports   = xml.Name{Space: nsDAV, Local: "supported-report-set"}
+	propETag               = xml.Name{Space: nsDAV, Local: "getetag"}
+	propContentType        = xml.Name{Space: nsDAV, Local: "getcontenttype"}
+	propLastModified       = xml.Name{Space: nsDAV, Local: "getlastmodified"}
+	propCalendarHome       = xml.Name{Space: nsCalDAV, Local: "calendar-home-set"}
+	propSupportedComponent = xml.Name{Space: nsCalDAV, Local: "supported-calendar-component-set"}
+	propCalendarData       = xml.Name{Space: nsCalDAV, Local: "calendar-data"}
+	propCTag               = xml.Name{Space: nsCalServer, Local: "getctag"}
+)
+
+// allProps lists the properties of each kind of resource that allprop and
+// propname return. calendar-data is only returned when asked for.
+var allProps = map[kind][]xml.Name{
+	kindRoot:       {propResourceType, propDisplayName, propPrincipal, propPrincipalURL, propCalendarHome, propPrivileges},
+	kindCollection: {propResourceType, propDisplayName, propPrincipal, propSupportedComponent, propSupportedReports, propCTag, propPrivileges},
+	kindObject:     {propResourceType, propPrincipal, propETag, propContentType, propLastModified, propPrivileges},
+}
+
+// Fixed property values
+const (
+	privilegesValue = "<d:privilege><d:read/></d:privilege><d:privilege><d:write/></d:privilege>" +
+		"<d:privilege><d:write-content/></d:privilege><d:privilege><d:bind/></d:privilege>" +
+		"<d:privilege><d:unbind/></d:privilege>"
+	supportedReportsValue = "<d:supported-report><d:report><c:calendar-query/></d:report></d:supported-report>" +
+		"<d:supported-report><d:report><c:calendar-multiget/></d:report></d:supported-report>"
+)
+
+// propRequest is what a PROPFIND or report asks of each resource: the
+// values of names, of every property for allprop, or only the names of
+// every property for propname
+type propRequest struct {
+	names    []xml.Name
+	allprop  bool
+	propname bool
+}
+
+// readPropRequest reads the prop, allprop or propname element of root,
+// defaulting to allprop
+func readPropRequest(root *node) propRequest {
+	switch {
+	case root.child(nsDAV, "propname") != nil:
+		return propRequest{propname: true}
+	case root.child(nsDAV, "prop") != nil:
+		var req propRequest
+		for _, prop := range root.child(nsDAV, "prop").children {
+			req.names = append(req.names, prop.name)
+		}
+		return req
+	default:
+		return propRequest{allprop: true}
+	}
+}
+
+// listing answers a request, listing the todos at most once for it
+type listing struct {
+	h      *Handler
+	ctx    context.Context
+	todos  []*models.Todo
+	listed bool
+}
+
+// list returns every todo
+func (l *listing) list() ([]*models.Todo, error) {
+	if !l.listed {
+		todos, err := l.h.todos.FindTodos(l.ctx, models.TodoQuery{Sort: models.SortCreatedAt})
+		if err != nil {
+			return nil, err
+		}
+		l.todos, l.listed = todos, true
+	}
+	return l.todos, nil
+}
+
+// objects returns the resources of every todo
+func (l *listing) objects() ([]resource, error) {
+	todos, err := l.list()
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]resource, len(todos))
+	for i, todo := range todos {
+		objects[i] = resource{kind: kindObject, id: todo.ID, todo: todo}
+	}
+	return objects, nil
+}
+
+// respond adds the properties req asks of res to m
+func (l *listing) respond(m *multistatus, res resource, req propRequest) error {
+	names := req.names
+	if req.allprop || req.propname {
+		names = allProps[res.kind]
+	}
+
+	var found []property
+	var missing []xml.Name
+	for _, name := range names {
+		value, ok, err := l.property(res, name)
+		if err != nil {
+			return err
+		}
+		switch {
+		case !ok:
+			missing = append(missing, name)
+		case req.propname:
+			found = append(found, property{name: name})
+		default:
+			found = append(found, property{name: name, value: value})
+		}
+	}
+	m.response(res.href(), found, missing)
+	return nil
+}
+
+// property returns the value of a property of res as inner XML, or false
+// if res does not have it
+func (l *listing) property(res resource, name xml.Name) (string, bool, error) {
+	switch name {
+	case propResourceType:
+		switch res.kind {
+		case kindRoot:
+			return "<d:collection/><d:principal/>", true, nil
+		case kindCollection:
+			return "<d:collection/><c:calendar/>", true, nil
+		default:
+			return "", true, nil
+		}
+	case propPrincipal:
+		return hrefElement(rootPath), true, nil
+	case propPrivileges:
+		return privilegesValue, true, nil
+	}
+
+	switch res.kind {
+	case kindRoot:
+		switch name {
+		case propDisplayName:
+			return escape(identity.User(l.ctx)), true, nil
+		case propPrincipalURL, propCalendarHome:
+			return hrefElement(rootPath), true, nil
+		}
+	case kindCollection:
+		switch name {
+		case propDisplayName:
+			return escape(l.h.name), true, nil
+		case propSupportedComponent:
+			return `<c:comp name="` + ical.Todo + `"/>`, true, nil
+		case propSupportedReports:
+			return supportedReportsValue, true, nil
+		case propCTag:
+			tag, err := l.ctag()
+			return escape(tag), err == nil, err
+		}
+	case kindObject:
+		switch name {
+		case propETag:
+			return escape(etag(res.todo)), true, nil
+		case propContentType:
+			return objectContentType, true, nil
+		case propLastModified:
+			return res.todo.UpdatedAt.UTC().Format(http.TimeFormat), true, nil
+		case propCalendarData:
+			data, err := calendarData(res.todo)
+			if err != nil {
+				return "", false, err
+			}
+			return escape(string(data)), true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// ctag returns the tag of the calendar, which changes whenever a todo is
+// created, changed or deleted
+func (l *listing) ctag() (string, error) {
+	todos, err := l.list()
+	if err != nil {
+		return "", err
+	}
+	tags := make([]string, len(todos))
+	for i, todo := range todos {
+		tags[i] = todo.ID + ":" + etag(todo)
+	}
+	sort.Strings(tags)
+
+	sum := sha256.Sum256([]byte(strings.Join(tags, "\n")))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`, nil
+}
+
+// propfind lists the properties of a resource and, depending on the Depth
+// header, its members. Depth infinity, the default, lists every resource
+// below it, of which there are only two levels.
+func (h *Handler) propfind(c *fiber.Ctx) error {
+	res, err := h.resolve(c.UserContext(), c.Params("*"))
+	if err != nil {
+		return err
+	}
+	if res.kind == kindObject && res.todo == nil {
+		return models.ErrNotFound
+	}
+
+	depth := c.Get("Depth", "infinity")
+	if depth != "0" && depth != "1" && depth != "infinity" {
+		return fiber.NewError(fiber.StatusBadRequest, "Depth must be 0, 1 or infinity")
+	}
+
+	req := propRequest{allprop: true}
+	if body := bytes.TrimSpace(c.Body()); len(body) > 0 {
+		root, err := parseXML(body)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("malformed XML: %v", err))
+		}
+		if !root.is(nsDAV, "propfind") {
+			return fiber.NewError(fiber.StatusBadRequest, "the body must be a DAV:propfind element")
+		}
+		req = readPropRequest(root)
+	}
+
+	l := &listing{h: h, ctx: c.UserContext()}
+	resources := []resource{res}
+	if depth != "0" {
+		switch res.kind {
+		case kindRoot:
+			resources = append(resources, resource{kind: kindCollection})
+			if depth == "infinity" {
+				objects, err := l.objects()
+				if err != nil {
+					return err
+				}
+				resources = append(resources, objects...)
+			}
+		case kindCollection:
+			objects, err := l.objects()
+			if err != nil {
+				return err
+			}
+			resources = append(resources, objects...)
+		}
+	}
+
+	m := newMultistatus()
+	for _, member := range resources {
+		if err := l.respond(m, member, req); err != nil {
+			return err
+		}
+	}
+	return sendMultistatus(c, m)
+}
+
+// report answers calendar-query and calendar-multiget reports. Queries
+// apply to the calendar or to one of its objects.
+func (h *Handler) report(c *fiber.Ctx) error {
+	res, err := h.resolve(c.UserContext(), c.Params("*"))
+	if err != nil {
+		return err
+	}
+	root, err := parseXML(c.Body())
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("malformed XML: %v", err))
+	}
+
+	l := &listing{h: h, ctx: c.UserContext()}
+	m := newMultistatus()
+	req := readPropRequest(root)
+
+	switch {
+	case root.is(nsCalDAV, "calendar-query"):
+		var candidates []resource
+		switch {
+		case res.kind == kindCollection:
+			if candidates, err = l.objects(); err != nil {
+				return err
+			}
+		case res.kind == kindObject && res.todo != nil:
+			candidates = []resource{res}
+		case res.kind == kindObject:
+			return models.ErrNotFound
+		default:
+			return fiber.NewError(fiber.StatusForbidden, fmt.Sprintf("calendar-query applies to the calendar at %s", collectionPath))
+		}
+
+		filter := root.child(nsCalDAV, "filter")
+		for _, candidate := range candidates {
+			calendar := ical.NewComponent(ical.Calendar)
+			calendar.Children = append(calendar.Children, ical.TodoComponent(candidate.todo))
+			if filter != nil && !matchFilter(filter, calendar) {
+				continue
+			}
+			if err := l.respond(m, candidate, req); err != nil {
+				return err
+			}
+		}
+
+	case root.is(nsCalDAV, "calendar-multiget"):
+		hrefs := root.all(nsDAV, "href")
+		ids := make([]string, 0, len(hrefs))
+		for _, href := range hrefs {
+			if id, ok := objectID(href.text); ok {
+				ids = append(ids, id)
+			}
+		}
+		todos, err := h.todos.GetTodosByIDs(c.UserContext(), ids)
+		if err != nil {
+			return err
+		}
+		byID := make(map[string]*models.Todo, len(todos))
+		for _, todo := range todos {
+			byID[todo.ID] = todo
+		}
+
+		for _, href := range hrefs {
+			id, ok := objectID(href.text)
+			if !ok || byID[id] == nil {
+				m.statusResponse(strings.TrimSpace(href.text), fiber.StatusNotFound)
+				continue
+			}
+			if err := l.respond(m, resource{kind: kindObject, id: id, todo: byID[id]}, req); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return fiber.NewError(fiber.StatusForbidden, fmt.Sprintf("the %s report is not supported", root.name.Local))
+	}
+
+	return sendMultistatus(c, m)
+}
+
+// objectID returns the ID of the todo whose resource href, a path or URL,
+// points to
+func objectID(href string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return "", false
+	}
+	name, found := strings.CutPrefix(u.Path, collectionPath)
+	if !found || strings.Contains(name, "/") || !strings.HasSuffix(name, objectExt) {
+		return "", false
+	}
+	return strings.TrimSuffix(name, objectExt), true
+}
+
+// sendMultistatus sends m as a 207 Multi-Status response
+func sendMultistatus(c *fiber.Ctx, m *multistatus) error {
+	c.Set(fiber.HeaderContentType, "application/xml; charset=utf-8")
+	return c.Status(fiber.StatusMultiStatus).Send(m.bytes())
+}
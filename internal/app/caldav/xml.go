@@ -0,0 +1,218 @@
+package caldav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// XML namespaces of the properties and reports served
+const (
+	nsDAV       = "DAV:"
+	nsCalDAV    = "urn:ietf:params:xml:ns:caldav"
+	nsCalServer = "http://calendarserver.org/ns/"
+)
+
+// prefixes are used for the namespaces above in responses; elements in any
+// other namespace declare it themselves
+var prefixes = map[string]string{
+	nsDAV:       "d",
+	nsCalDAV:    "c",
+	nsCalServer: "cs",
+}
+
+// node is an element of a request body
+type node struct {
+	name     xml.Name
+	attrs    []xml.Attr
+	children []*node
+	text     string
+}
+
+// child returns the first child element called space:local, or nil
+func (n *node) child(space, local string) *node {
+	for _, child := range n.children {
+		if child.name.Space == space && child.name.Local == local {
+			return child
+		}
+	}
+	return nil
+}
+
+// all returns every child element called space:local
+func (n *node) all(space, local string) []*node {
+	var matches []*node
+	for _, child := range n.children {
+		if child.name.Space == space && child.name.Local == local {
+			matches = append(matches, child)
+		}
+	}
+	return matches
+}
+
+// attr returns the value of the unqualified attribute name, or ""
+func (n *node) attr(name string) string {
+	for _, attr := range n.attrs {
+		if attr.Name.Space == "" && attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// is reports whether n is called space:local
+func (n *node) is(space, local string) bool {
+	return n.name.Space == space && n.name.Local == local
+}
+
+// parseXML reads the root element of a request body
+func parseXML(body []byte) (*node, error) {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	var stack []*node
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil, fmt.Errorf("the document has no root element")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			n := &node{name: tok.Name, attrs: tok.Attr}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return n, nil
+			}
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(tok)
+			}
+		}
+	}
+}
+
+// property is a property of a resource, with its value as inner XML
+type property struct {
+	name  xml.Name
+	value string
+}
+
+// multistatus builds a 207 Multi-Status response body
+type multistatus struct {
+	b strings.Builder
+}
+
+func newMultistatus() *multistatus {
+	m := &multistatus{}
+	m.b.WriteString(xml.Header)
+	m.b.WriteString(`<d:multistatus xmlns:d="DAV:" xmlns:c="` + nsCalDAV + `" xmlns:cs="` + nsCalServer + `">`)
+	return m
+}
+
+// response adds the properties of the resource at href: found ones with
+// their values and missing ones as not found
+func (m *multistatus) response(href string, found []property, missing []xml.Name) {
+	m.b.WriteString("<d:response>")
+	m.href(href)
+	if len(found) > 0 {
+		m.b.WriteString("<d:propstat><d:prop>")
+		for _, prop := range found {
+			m.element(prop.name, prop.value)
+		}
+		m.b.WriteString("</d:prop>")
+		m.status(http.StatusOK)
+		m.b.WriteString("</d:propstat>")
+	}
+	if len(missing) > 0 {
+		m.b.WriteString("<d:propstat><d:prop>")
+		for _, name := range missing {
+			m.element(name, "")
+		}
+		m.b.WriteString("</d:prop>")
+		m.status(http.StatusNotFound)
+		m.b.WriteString("</d:propstat>")
+	}
+	m.b.WriteString("</d:response>")
+}
+
+// statusResponse adds a response giving only a status for href
+func (m *multistatus) statusResponse(href string, status int) {
+	m.b.WriteString("<d:response>")
+	m.href(href)
+	m.status(status)
+	m.b.WriteString("</d:response>")
+}
+
+// bytes ends the document and returns it
+func (m *multistatus) bytes() []byte {
+	m.b.WriteString("</d:multistatus>")
+	return []byte(m.b.String())
+}
+
+func (m *multistatus) href(href string) {
+	m.b.WriteString(hrefElement(href))
+}
+
+func (m *multistatus) status(code int) {
+	fmt.Fprintf(&m.b, "<d:status>HTTP/1.1 %d %s</d:status>", code, http.StatusText(code))
+}
+
+// element writes an element with inner XML
+func (m *multistatus) element(name xml.Name, inner string) {
+	m.b.WriteString(startTag(name, inner == ""))
+	if inner != "" {
+		m.b.WriteString(inner)
+		m.b.WriteString("</" + qualified(name) + ">")
+	}
+}
+
+// startTag returns the start tag of an element, or the whole element if
+// it is empty. Elements outside the known namespaces declare their own.
+func startTag(name xml.Name, empty bool) string {
+	tag := "<" + qualified(name)
+	if _, known := prefixes[name.Space]; !known && name.Space != "" {
+		var b strings.Builder
+		xml.EscapeText(&b, []byte(name.Space))
+		tag += ` xmlns="` + b.String() + `"`
+	}
+	if empty {
+		return tag + "/>"
+	}
+	return tag + ">"
+}
+
+// qualified returns the prefixed name of an element
+func qualified(name xml.Name) string {
+	if prefix, known := prefixes[name.Space]; known {
+		return prefix + ":" + name.Local
+	}
+	return name.Local
+}
+
+// hrefElement returns a DAV:href element holding href
+func hrefElement(href string) string {
+	var b strings.Builder
+	b.WriteString("<d:href>")
+	xml.EscapeText(&b, []byte(href))
+	b.WriteString("</d:href>")
+	return b.String()
+}
+
+// escape returns s escaped as XML character data
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,194 @@
+package app_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/teguh/go-todo-api/internal/app/ical"
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+// createCalendarToken issues a calendar token to user
+func createCalendarToken(t *testing.T, a *fiber.App, user string) *models.CalendarToken {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/calendar/token", nil)
+	req.Header.Set("X-User-ID", user)
+	resp, err := a.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create token = %d", resp.StatusCode)
+	}
+	var token models.CalendarToken
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		t.Fatal(err)
+	}
+	return &token
+}
+
+// feed fetches the calendar feed with token and the query, returning the
+// status and, if it is served, the calendar
+func feed(t *testing.T, a *fiber.App, token, query string) (int, *ical.Component) {
+	t.Helper()
+
+	status, header, data := transfer(t, a, http.MethodGet, "/api/v1/calendar.ics?token="+url.QueryEscape(token)+query, "", "")
+	if status != http.StatusOK {
+		return status, nil
+	}
+	if contentType := header.Get("Content-Type"); contentType != "text/calendar; charset=utf-8" {
+		t.Errorf("content type %q", contentType)
+	}
+	calendar, err := ical.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode %s: %v", data, err)
+	}
+	return status, calendar
+}
+
+// summaries returns the SUMMARY of each component of calendar named name
+func summaries(t *testing.T, calendar *ical.Component, name string) []string {
+	t.Helper()
+	titles := []string{}
+	for _, c := range calendar.Children {
+		if c.Name != name {
+			t.Errorf("feed holds a %s, want only %s", c.Name, name)
+			continue
+		}
+		titles = append(titles, c.Get("SUMMARY").Text())
+	}
+	slices.Sort(titles)
+	return titles
+}
+
+func TestCalendarFeed(t *testing.T) {
+	a := newTransferServer(t, 0)
+	todos := seedTodos(t, a)
+	var callMum models.Todo
+	for _, todo := range todos {
+		if todo.Title == "Call mum" {
+			callMum = todo
+		}
+	}
+	if status := send(t, a, http.MethodPatch, "/api/v1/todos/"+callMum.ID, `{"completed": true}`, nil); status != http.StatusOK {
+		t.Fatalf("complete = %d", status)
+	}
+
+	token := createCalendarToken(t, a, "alice")
+	if !strings.HasSuffix(token.FeedURL, "/api/v1/calendar.ics?token="+token.Token) {
+		t.Errorf("feed URL %q", token.FeedURL)
+	}
+
+	tests := []struct {
+		query     string
+		component string
+		want      []string
+	}{
+		// Events skip todos without a due date
+		{"", ical.Event, []string{"Move house", "Pack books"}},
+		{"&component=vtodo", ical.Todo, []string{"Call mum", "Move house", "Pack books"}},
+		{"&component=vtodo&project=Home", ical.Todo, []string{"Move house"}},
+		// An empty project selects the todos without one
+		{"&component=vtodo&project=", ical.Todo, []string{"Call mum", "Pack books"}},
+		{"&component=vtodo&tag=boxes", ical.Todo, []string{"Move house"}},
+		{"&component=vtodo&tag=nothing", ical.Todo, []string{}},
+		{"&component=vtodo&completed=true", ical.Todo, []string{"Call mum"}},
+		{"&tag=big&project=Home", ical.Event, []string{"Move house"}},
+	}
+	for _, tt := range tests {
+		status, calendar := feed(t, a, token.Token, tt.query)
+		if status != http.StatusOK {
+			t.Errorf("%s: status %d", tt.query, status)
+			continue
+		}
+		if got := summaries(t, calendar, tt.component); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.query, got, tt.want)
+		}
+	}
+
+	// The calendar is published under the app's name, with the todos'
+	// fields mapped onto iCalendar's
+	_, calendar := feed(t, a, token.Token, "&component=vtodo")
+	if method := calendar.Get("METHOD"); method == nil || method.Value != ical.MethodPublish {
+		t.Errorf("method %v", method)
+	}
+	if name := calendar.Get("X-WR-CALNAME"); name == nil || name.Text() != "Todo API" {
+		t.Errorf("name %v", name)
+	}
+	want := map[string][]string{
+		"Move house": {"DUE;VALUE=DATE:20300501", "PRIORITY:1", "CATEGORIES:big,boxes", "X-TODO-PROJECT:Home", "STATUS:NEEDS-ACTION"},
+		"Pack books": {"DUE:20300420T093000Z", "STATUS:NEEDS-ACTION"},
+		"Call mum":   {"PRIORITY:7", "STATUS:COMPLETED", "PERCENT-COMPLETE:100"},
+	}
+	for _, c := range calendar.Children {
+		var lines bytes.Buffer
+		enc := ical.NewEncoder(&lines)
+		for _, prop := range c.Properties {
+			enc.Property(prop)
+		}
+		enc.Flush()
+		title := c.Get("SUMMARY").Text()
+		for _, line := range want[title] {
+			if !strings.Contains(lines.String(), line+"\r\n") {
+				t.Errorf("%s lacks %s in\n%s", title, line, lines.String())
+			}
+		}
+	}
+}
+
+func TestCalendarFeedRejectsBadTokens(t *testing.T) {
+	a := newTransferServer(t, 0)
+	seedTodos(t, a)
+
+	old := createCalendarToken(t, a, "alice")
+	if status, _ := feed(t, a, old.Token, ""); status != http.StatusOK {
+		t.Fatalf("status %d with a fresh token", status)
+	}
+	if status, _ := feed(t, a, old.Token, "&component=vjournal"); status != http.StatusBadRequest {
+		t.Errorf("status %d for an unknown component, want 400", status)
+	}
+
+	// Rotating the token revokes the old one
+	current := createCalendarToken(t, a, "alice")
+	// Another user's token leaves alice's alone
+	createCalendarToken(t, a, "bob")
+
+	for name, token := range map[string]string{
+		"missing": "",
+		"bad":     "not-a-token",
+		"rotated": old.Token,
+		"altered": current.Token[:len(current.Token)-1] + "x",
+	} {
+		if status, _ := feed(t, a, token, ""); status != http.StatusUnauthorized {
+			t.Errorf("%s token: status %d, want 401", name, status)
+		}
+	}
+	if status, _ := feed(t, a, current.Token, ""); status != http.StatusOK {
+		t.Errorf("status %d with the rotated token", status)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/calendar/token", nil)
+	req.Header.Set("X-User-ID", "alice")
+	resp, err := a.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete token = %d", resp.StatusCode)
+	}
+	if status, _ := feed(t, a, current.Token, ""); status != http.StatusUnauthorized {
+		t.Errorf("status %d with a revoked token, want 401", status)
+	}
+}
@@ -0,0 +1,159 @@
+// Package digest summarises the todos that are overdue or due soon, renders
+// the summary as email, and mails it to users on the schedule they choose.
+package digest
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	// Time zones load even where the system has no zoneinfo
+	_ "time/tzdata"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/repositories"
+)
+
+// sectionTitles are the headings of the sections of a digest, in order
+var sectionTitles = [][2]string{
+	{models.DigestOverdue, "Overdue"},
+	{models.DigestToday, "Due today"},
+	{models.DigestThisWeek, "Due this week"},
+}
+
+// Generator builds digests from the todos in a store
+type Generator struct {
+	todos repositories.TodoStore
+}
+
+// NewGenerator creates a Generator for the todos in todos
+func NewGenerator(todos repositories.TodoStore) *Generator {
+	return &Generator{todos: todos}
+}
+
+// Generate returns the digest of the open todos due before the end of the
+// week, as of now in loc
+func (g *Generator) Generate(now time.Time, loc *time.Location) (*models.Digest, error) {
+	_, _, weekEnd := Days(now, loc)
+	completed := false
+	todos, err := g.todos.Find(models.TodoQuery{
+		Filter: models.TodoFilter{
+			Completed: &completed,
+			Due:       &models.DueRange{To: &weekEnd},
+		},
+		Sort: models.SortDueDate,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get due todos: %w", err)
+	}
+	return Build(todos, now, loc), nil
+}
+
+// Days returns the start of the day of now in loc, the start of the next
+// day, and the end of the week: the start of the seventh day after today.
+// Days are calendar days, so they are 23 or 25 hours long across daylight
+// saving changes.
+func Days(now time.Time, loc *time.Location) (today, tomorrow, weekEnd time.Time) {
+	local := now.In(loc)
+	year, month, day := local.Date()
+	today = time.Date(year, month, day, 0, 0, 0, 0, loc)
+	tomorrow = time.Date(year, month, day+1, 0, 0, 0, 0, loc)
+	weekEnd = time.Date(year, month, day+7, 0, 0, 0, 0, loc)
+	return today, tomorrow, weekEnd
+}
+
+// Window returns the due range of the window called name, one of
+// models.DueWindows, as of now in loc. It reports false for other names.
+func Window(name string, now time.Time, loc *time.Location) (models.DueRange, bool) {
+	now = now.In(loc)
+	today, tomorrow, weekEnd := Days(now, loc)
+	switch name {
+	case models.DueOverdue:
+		return models.DueRange{To: &now}, true
+	case models.DueToday:
+		return models.DueRange{From: &today, To: &tomorrow}, true
+	case models.DueWeek:
+		return models.DueRange{From: &today, To: &weekEnd}, true
+	}
+	return models.DueRange{}, false
+}
+
+// Build sorts todos into the sections of a digest as of now in loc. Todos
+// due before now are overdue, those due later today are due today, and
+// those due in the six days after are due this week; all-day todos count as
+// due today for the whole of their date in loc. Completed todos, todos
+// without a due date and those due later are left out.
+func Build(todos []*models.Todo, now time.Time, loc *time.Location) *models.Digest {
+	now = now.In(loc)
+	_, tomorrow, weekEnd := Days(now, loc)
+	sections := map[string]models.DueRange{
+		models.DigestOverdue:  {To: &now},
+		models.DigestToday:    {From: &now, To: &tomorrow},
+		models.DigestThisWeek: {From: &tomorrow, To: &weekEnd},
+	}
+
+	digest := &models.Digest{
+		Timezone:    loc.String(),
+		GeneratedAt: now,
+	}
+	bySection := make(map[string][]*models.Todo)
+	for _, todo := range todos {
+		if todo.Completed {
+			continue
+		}
+		for _, title := range sectionTitles {
+			if sections[title[0]].Matches(todo) {
+				bySection[title[0]] = append(bySection[title[0]], todo.In(loc))
+				break
+			}
+		}
+	}
+
+	for _, title := range sectionTitles {
+		section := &models.DigestSection{
+			Name:     title[0],
+			Title:    title[1],
+			Projects: byProject(bySection[title[0]], loc),
+			Count:    len(bySection[title[0]]),
+		}
+		digest.Sections = append(digest.Sections, section)
+		digest.Total += section.Count
+	}
+	return digest
+}
+
+// byProject groups todos by project, sorting projects by name and each
+// project's todos soonest due first in loc, then by priority
+func byProject(todos []*models.Todo, loc *time.Location) []*models.DigestProject {
+	sorted := append([]*models.Todo(nil), todos...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := dueAt(sorted[i], loc), dueAt(sorted[j], loc)
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return sorted[i].Priority > sorted[j].Priority
+	})
+
+	projects := []*models.DigestProject{}
+	index := make(map[string]*models.DigestProject)
+	for _, todo := range sorted {
+		project, ok := index[todo.Project]
+		if !ok {
+			project = &models.DigestProject{Project: todo.Project}
+			index[todo.Project] = project
+			projects = append(projects, project)
+		}
+		project.Todos = append(project.Todos, todo)
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Project < projects[j].Project })
+	return projects
+}
+
+// dueAt returns when todo is due in loc, taking all-day todos to be due at
+// the start of their date
+func dueAt(todo *models.Todo, loc *time.Location) time.Time {
+	if todo.AllDay {
+		return todo.DueOn(loc)
+	}
+	return todo.DueDate.Time
+}
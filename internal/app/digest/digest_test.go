@@ -0,0 +1,288 @@
+package digest_test
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/teguh/go-todo-api/internal/app/digest"
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/repositories"
+)
+
+// newYork is the zone of the tests, five hours behind UTC in winter and
+// four in summer
+var newYork, _ = time.LoadLocation("America/New_York")
+
+// newTodo returns a todo in project due at due, an RFC3339 time or a date
+func newTodo(t *testing.T, title, project, due string) *models.Todo {
+	t.Helper()
+	todo := &models.Todo{ID: uuid.NewString(), Title: title, Project: project, Tags: []string{}}
+	if err := todo.SetDueDate(due); err != nil {
+		t.Fatal(err)
+	}
+	return todo
+}
+
+// outline lists each section of d with its todos by project, such as
+// "today: Home [Water plants, Call plumber]"
+func outline(d *models.Digest) []string {
+	var lines []string
+	for _, section := range d.Sections {
+		if section.Count == 0 {
+			continue
+		}
+		var projects []string
+		for _, project := range section.Projects {
+			var titles []string
+			for _, todo := range project.Todos {
+				titles = append(titles, todo.Title)
+			}
+			projects = append(projects, fmt.Sprintf("%s [%s]", project.Project, strings.Join(titles, ", ")))
+		}
+		lines = append(lines, section.Name+": "+strings.Join(projects, "; "))
+	}
+	return lines
+}
+
+// eveningTodos are due around 22:30 on Tuesday 5 March 2030 in New York,
+// which is already Wednesday in UTC
+func eveningTodos(t *testing.T) []*models.Todo {
+	review := newTodo(t, "Review", "Work", "2030-03-05T23:00:00-05:00")
+	review.Priority = 4
+	standUp := newTodo(t, "Stand-up notes", "Work", "2030-03-05T23:00:00-05:00")
+	standUp.Priority = 1
+	done := newTodo(t, "Done", "Home", "2030-03-05T08:00:00-05:00")
+	done.Completed = true
+
+	return []*models.Todo{
+		newTodo(t, "Pay rent", "Home", "2030-03-05T20:00:00-05:00"),
+		newTodo(t, "File taxes", "", "2030-03-04"),
+		newTodo(t, "Call plumber", "Home", "2030-03-05T23:30:00-05:00"),
+		newTodo(t, "Water plants", "Home", "2030-03-05"),
+		standUp,
+		review,
+		newTodo(t, "Dentist", "Health", "2030-03-07T09:00:00-05:00"),
+		newTodo(t, "Trip", "", "2030-03-11"),
+		newTodo(t, "Next month", "Home", "2030-03-12T09:00:00-04:00"),
+		newTodo(t, "Someday", "Home", ""),
+		done,
+	}
+}
+
+// evening is when eveningTodos are summarised
+var evening = time.Date(2030, 3, 5, 22, 30, 0, 0, newYork)
+
+func TestBuildGroupsByProjectInTheUsersZone(t *testing.T) {
+	d := digest.Build(eveningTodos(t), evening.UTC(), newYork)
+
+	// Days are New York's: the todo due at 23:30 is due today although it
+	// is Wednesday in UTC, and the all-day todo for Tuesday is not overdue
+	want := []string{
+		"overdue:  [File taxes]; Home [Pay rent]",
+		"today: Home [Water plants, Call plumber]; Work [Review, Stand-up notes]",
+		"this_week:  [Trip]; Health [Dentist]",
+	}
+	if got := outline(d); !slices.Equal(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if d.Total != 8 || d.Timezone != "America/New_York" || !d.GeneratedAt.Equal(evening) || d.GeneratedAt.Location() != newYork {
+		t.Errorf("total %d, time zone %s, generated at %v", d.Total, d.Timezone, d.GeneratedAt)
+	}
+
+	// Due dates are shown in the zone too
+	plumber := d.Sections[1].Projects[0].Todos[1]
+	if plumber.DueDateStr != "2030-03-05T23:30:00-05:00" {
+		t.Errorf("due %s", plumber.DueDateStr)
+	}
+
+	// In UTC it is Wednesday already, so Tuesday's all-day todo is overdue
+	// and a week reaches a day further
+	utc := digest.Build(eveningTodos(t), evening, time.UTC)
+	want = []string{
+		"overdue:  [File taxes]; Home [Water plants, Pay rent]",
+		"today: Home [Call plumber]; Work [Review, Stand-up notes]",
+		"this_week:  [Trip]; Health [Dentist]; Home [Next month]",
+	}
+	if got := outline(utc); !slices.Equal(got, want) {
+		t.Errorf("in UTC got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestDigestAcrossDaylightSavingChanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		now     time.Time
+		dayLong time.Duration
+		todos   map[string]string
+		want    []string
+	}{
+		{
+			// Clocks go forward at 02:00 on 10 March 2030, so that day has
+			// 23 hours and midnight after it is 04:00 UTC
+			name:    "spring forward",
+			now:     time.Date(2030, 3, 10, 1, 30, 0, 0, newYork),
+			dayLong: 23 * time.Hour,
+			todos: map[string]string{
+				"Late":   "2030-03-10T23:30:00-04:00",
+				"Early":  "2030-03-11T00:30:00-04:00",
+				"Last":   "2030-03-16T23:30:00-04:00",
+				"Beyond": "2030-03-17T00:30:00-04:00",
+			},
+			want: []string{"today:  [Late]", "this_week:  [Early, Last]"},
+		},
+		{
+			// Clocks go back at 02:00 on 3 November 2030, so that day has
+			// 25 hours and midnight after it is 05:00 UTC
+			name:    "fall back",
+			now:     time.Date(2030, 11, 3, 8, 0, 0, 0, newYork),
+			dayLong: 25 * time.Hour,
+			todos: map[string]string{
+				"Late":   "2030-11-03T23:30:00-05:00",
+				"Early":  "2030-11-04T00:30:00-05:00",
+				"Last":   "2030-11-09T23:30:00-05:00",
+				"Beyond": "2030-11-10T00:30:00-05:00",
+			},
+			want: []string{"today:  [Late]", "this_week:  [Early, Last]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			today, tomorrow, weekEnd := digest.Days(tt.now, newYork)
+			if got := tomorrow.Sub(today); got != tt.dayLong {
+				t.Errorf("day lasts %v, want %v", got, tt.dayLong)
+			}
+			if weekEnd.In(newYork).Hour() != 0 {
+				t.Errorf("week ends at %v, want midnight", weekEnd.In(newYork))
+			}
+
+			todos := repositories.NewMemoryTodoRepository(repositories.NewMemoryOutboxRepository())
+			for title, due := range tt.todos {
+				if err := todos.Create(newTodo(t, title, "", due), nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			d, err := digest.NewGenerator(todos).Generate(tt.now, newYork)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := outline(d); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextDigestAcrossDaylightSavingChanges(t *testing.T) {
+	tests := []struct {
+		name  string
+		hour  int
+		after time.Time
+		want  time.Time
+	}{
+		{"same hour after spring forward", 7, time.Date(2030, 3, 9, 8, 0, 0, 0, newYork), time.Date(2030, 3, 10, 11, 0, 0, 0, time.UTC)},
+		{"skipped hour", 2, time.Date(2030, 3, 9, 12, 0, 0, 0, newYork), time.Date(2030, 3, 10, 7, 0, 0, 0, time.UTC)},
+		{"same hour after fall back", 7, time.Date(2030, 11, 2, 8, 0, 0, 0, newYork), time.Date(2030, 11, 3, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		preferences := &models.Preferences{Timezone: "America/New_York", DigestFrequency: models.DigestDaily, DigestHour: tt.hour}
+		next := digest.Next(preferences, tt.after)
+		if next == nil || !next.Equal(tt.want) {
+			t.Errorf("%s: next at %v, want %v", tt.name, next, tt.want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	d := digest.Build(eveningTodos(t), evening, newYork)
+
+	if subject := digest.Subject(d); subject != "Todo digest for Tuesday 5 March: 2 overdue, 4 due today, 2 due this week" {
+		t.Errorf("subject %q", subject)
+	}
+
+	text, err := digest.Text(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `Todo digest for Tuesday 5 March
+
+Overdue (2)
+
+  No project
+  - File taxes, due Mon 4 Mar
+
+  Home
+  - Pay rent, due 20:00
+
+Due today (4)
+
+  Home
+  - Water plants, due today
+  - Call plumber, due 23:30
+
+  Work
+  - Review, due 23:00, priority 4
+  - Stand-up notes, due 23:00, priority 1
+
+Due this week (2)
+
+  No project
+  - Trip, due Mon 11 Mar
+
+  Health
+  - Dentist, due Thu 7 Mar 09:00
+
+Times are in America/New_York.`
+	if strings.TrimSpace(text) != want {
+		t.Errorf("text\n%s\nwant\n%s", text, want)
+	}
+
+	html, err := digest.HTML(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fragment := range []string{
+		"<title>Todo digest for Tuesday 5 March</title>",
+		`<h2 style="font-size: 16px; color: #b00020;">Overdue (2)</h2>`,
+		"<li><strong>Call plumber</strong>, due 23:30</li>",
+		"<li><strong>Review</strong>, due 23:00, priority 4</li>",
+		"<li><strong>Dentist</strong>, due Thu 7 Mar 09:00</li>",
+	} {
+		if !strings.Contains(html, fragment) {
+			t.Errorf("HTML lacks %s", fragment)
+		}
+	}
+}
+
+func TestRenderEscapesAndEmptyDigests(t *testing.T) {
+	todo := newTodo(t, `<script>alert("hi")</script>`, "R&D", "2030-03-06")
+	d := digest.Build([]*models.Todo{todo}, evening, newYork)
+
+	html, err := digest.HTML(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(html, "<script>") || !strings.Contains(html, "&lt;script&gt;") || !strings.Contains(html, "R&amp;D") {
+		t.Errorf("todo text is not escaped in\n%s", html)
+	}
+
+	// A digest with nothing due says so
+	later := newTodo(t, "Later", "", "2031-01-02")
+	d = digest.Build([]*models.Todo{later}, evening, newYork)
+	if d.Total != 0 {
+		t.Fatalf("total %d", d.Total)
+	}
+	if subject := digest.Subject(d); subject != "Todo digest for Tuesday 5 March" {
+		t.Errorf("subject %q", subject)
+	}
+	text, err := digest.Text(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text, "Nothing is overdue or due this week.") {
+		t.Errorf("text\n%s", text)
+	}
+}
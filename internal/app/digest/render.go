@@ -0,0 +1,113 @@
+package digest
+
+import (
+	"embed"
+	htmltemplate "html/template"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+//go:embed templates
+var templates embed.FS
+
+var (
+	textTemplate = texttemplate.Must(texttemplate.New("digest.txt.tmpl").Funcs(texttemplate.FuncMap(funcs)).ParseFS(templates, "templates/digest.txt.tmpl"))
+	htmlTemplate = htmltemplate.Must(htmltemplate.New("digest.html.tmpl").Funcs(htmltemplate.FuncMap(funcs)).ParseFS(templates, "templates/digest.html.tmpl"))
+)
+
+// funcs are available to both templates. due is replaced per render to
+// show times in the digest's time zone.
+var funcs = map[string]interface{}{
+	"projectName": projectName,
+	"due":         func(*models.Todo) string { return "" },
+}
+
+// view is what the templates render
+type view struct {
+	Heading string
+	Digest  *models.Digest
+}
+
+// Subject returns the subject line of an email carrying digest
+func Subject(digest *models.Digest) string {
+	var counts []string
+	for _, section := range digest.Sections {
+		if section.Count > 0 {
+			counts = append(counts, strconv.Itoa(section.Count)+" "+strings.ToLower(section.Title))
+		}
+	}
+	if len(counts) == 0 {
+		return heading(digest)
+	}
+	return heading(digest) + ": " + strings.Join(counts, ", ")
+}
+
+// Text renders digest as plain text
+func Text(digest *models.Digest) (string, error) {
+	tmpl, err := textTemplate.Clone()
+	if err != nil {
+		return "", err
+	}
+	tmpl.Funcs(texttemplate.FuncMap{"due": dueFormatter(digest)})
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, view{Heading: heading(digest), Digest: digest}); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// HTML renders digest as an HTML document, escaping the todos' text
+func HTML(digest *models.Digest) (string, error) {
+	tmpl, err := htmlTemplate.Clone()
+	if err != nil {
+		return "", err
+	}
+	tmpl.Funcs(htmltemplate.FuncMap{"due": dueFormatter(digest)})
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, view{Heading: heading(digest), Digest: digest}); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// heading names the day of digest, such as "Todo digest for Monday 19 October"
+func heading(digest *models.Digest) string {
+	return "Todo digest for " + digest.GeneratedAt.Format("Monday 2 January")
+}
+
+// dueFormatter returns a function showing the due date of a todo in the
+// time zone of digest: just the time when it is due today, and the day too
+// otherwise. All-day todos show only the day, or "today".
+func dueFormatter(digest *models.Digest) func(*models.Todo) string {
+	loc := digest.GeneratedAt.Location()
+	today, tomorrow, _ := Days(digest.GeneratedAt, loc)
+	return func(todo *models.Todo) string {
+		due := dueAt(todo, loc).In(loc)
+		dueToday := !due.Before(today) && due.Before(tomorrow)
+		layout := "Mon 2 Jan"
+		if due.Year() != digest.GeneratedAt.Year() {
+			layout += " 2006"
+		}
+		switch {
+		case todo.AllDay && dueToday:
+			return "today"
+		case todo.AllDay:
+			return due.Format(layout)
+		case dueToday:
+			return due.Format("15:04")
+		}
+		return due.Format(layout + " 15:04")
+	}
+}
+
+func projectName(project string) string {
+	if project == "" {
+		return "No project"
+	}
+	return project
+}
@@ -0,0 +1,78 @@
+package digest
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+// LoadLocation returns the IANA time zone called name, such as
+// Europe/Berlin. Unlike time.LoadLocation it refuses "" and "Local", which
+// would depend on the server.
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	return time.LoadLocation(name)
+}
+
+// ParseWeekday parses the lowercase English name of a weekday
+func ParseWeekday(name string) (time.Weekday, bool) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.ToLower(day.String()) == name {
+			return day, true
+		}
+	}
+	return 0, false
+}
+
+// Next returns when the next digest of preferences is due after after, or
+// nil if digests are off. Digests are sent at the top of the hour in the
+// user's time zone; on days when that hour is skipped by daylight saving,
+// they are sent an hour later.
+func Next(preferences *models.Preferences, after time.Time) *time.Time {
+	loc, err := LoadLocation(preferences.Timezone)
+	if err != nil {
+		return nil
+	}
+
+	var step int
+	var weekday time.Weekday
+	switch preferences.DigestFrequency {
+	case models.DigestDaily:
+		step = 1
+	case models.DigestWeekly:
+		var ok bool
+		if weekday, ok = ParseWeekday(preferences.DigestWeekday); !ok {
+			return nil
+		}
+		step = 7
+	default:
+		return nil
+	}
+
+	local := after.In(loc)
+	year, month, day := local.Date()
+	if step == 7 {
+		day += (int(weekday) - int(local.Weekday()) + 7) % 7
+	}
+	next := atHour(year, month, day, preferences.DigestHour, loc)
+	if !next.After(after) {
+		next = atHour(year, month, day+step, preferences.DigestHour, loc)
+	}
+	next = next.UTC()
+	return &next
+}
+
+// atHour returns the top of hour on the given day in loc, or of the hour
+// after if daylight saving skips it. time.Date alone may resolve a skipped
+// hour to the one before.
+func atHour(year int, month time.Month, day, hour int, loc *time.Location) time.Time {
+	t := time.Date(year, month, day, hour, 0, 0, 0, loc)
+	if t.Hour() != hour {
+		t = time.Date(year, month, day, hour+1, 0, 0, 0, loc)
+	}
+	return t
+}
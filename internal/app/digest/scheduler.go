@@ -0,0 +1,186 @@
+package digest
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/mailer"
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/repositories"
+)
+
+// Sender delivers email; *mailer.Mailer is one
+type Sender interface {
+	Send(ctx context.Context, msg *mailer.Message) error
+}
+
+// Options configures a Scheduler. Zero values select the defaults.
+type Options struct {
+	// PollInterval is how often due digests are looked for (default 1m)
+	PollInterval time.Duration
+	// Lease is how long a claimed digest is kept from other replicas; it
+	// must outlast building and sending one (default 5m)
+	Lease time.Duration
+	// RetryInterval is how long after a failure a digest is tried again,
+	// unless the next one is due first (default 15m)
+	RetryInterval time.Duration
+	// Now returns the current time; tests may pin it
+	Now func() time.Time
+}
+
+// claimBatch is how many digests are claimed at once
+const claimBatch = 20
+
+// Scheduler mails digests as they fall due. Like reminders, digests that
+// fell due while no scheduler ran are sent on the next start, and replicas
+// sharing the database claim each before sending it. Digests with nothing
+// overdue or due this week are not sent.
+type Scheduler struct {
+	store     repositories.PreferenceStore
+	generator *Generator
+	sender    Sender
+	opts      Options
+	wake      chan struct{}
+}
+
+// NewScheduler creates a Scheduler for the preferences in store, building
+// digests with generator and mailing them through sender
+func NewScheduler(store repositories.PreferenceStore, generator *Generator, sender Sender, opts Options) *Scheduler {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Minute
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = 5 * time.Minute
+	}
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = 15 * time.Minute
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	return &Scheduler{
+		store:     store,
+		generator: generator,
+		sender:    sender,
+		opts:      opts,
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// Wake asks a running scheduler to look for due digests now, e.g. after a
+// schedule changes. It never blocks.
+func (s *Scheduler) Wake() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends due digests until ctx is cancelled. Digests cut short by
+// cancellation are sent once their claim expires.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.SendDue(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Digest scheduler: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// SendDue sends every digest due now and returns how many were handled
+func (s *Scheduler) SendDue(ctx context.Context) (int, error) {
+	total := 0
+	for ctx.Err() == nil {
+		claimed, err := s.store.ClaimDueDigests(s.opts.Now(), s.opts.Lease, claimBatch)
+		if err != nil {
+			return total, err
+		}
+
+		for _, preferences := range claimed {
+			s.send(ctx, preferences)
+		}
+		total += len(claimed)
+
+		if len(claimed) < claimBatch {
+			break
+		}
+	}
+	return total, nil
+}
+
+// send builds and mails the digest of preferences, then schedules the next
+// one, or a retry if sending failed
+func (s *Scheduler) send(ctx context.Context, preferences *models.Preferences) {
+	claimedUntil := *preferences.NextDigestAt
+	now := s.opts.Now()
+	next := Next(preferences, now)
+
+	err := s.mail(ctx, preferences, now)
+	if ctx.Err() != nil {
+		return
+	}
+
+	preferences.NextDigestAt = next
+	if err != nil {
+		preferences.LastDigestError = err.Error()
+		if retry := s.opts.Now().Add(s.opts.RetryInterval); next == nil || retry.Before(*next) {
+			preferences.NextDigestAt = &retry
+		}
+	} else {
+		preferences.LastDigestError = ""
+	}
+
+	if _, err := s.store.RecordDigest(preferences, claimedUntil); err != nil {
+		log.Printf("Digest scheduler: %v", err)
+	}
+}
+
+// mail sends the digest of preferences as of now, unless it is empty, and
+// sets when it was sent
+func (s *Scheduler) mail(ctx context.Context, preferences *models.Preferences, now time.Time) error {
+	loc, err := LoadLocation(preferences.Timezone)
+	if err != nil {
+		return err
+	}
+	digest, err := s.generator.Generate(now, loc)
+	if err != nil {
+		return err
+	}
+	if digest.Total == 0 {
+		return nil
+	}
+	digest.UserID = preferences.UserID
+
+	text, err := Text(digest)
+	if err != nil {
+		return err
+	}
+	html, err := HTML(digest)
+	if err != nil {
+		return err
+	}
+	err = s.sender.Send(ctx, &mailer.Message{
+		To:      preferences.Email,
+		Subject: Subject(digest),
+		Text:    text,
+		HTML:    html,
+	})
+	if err != nil {
+		return err
+	}
+
+	sent := s.opts.Now()
+	preferences.LastDigestAt = &sent
+	return nil
+}
@@ -0,0 +1,133 @@
+package app_test
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/teguh/go-todo-api/config"
+	"github.com/teguh/go-todo-api/internal/app"
+	"github.com/teguh/go-todo-api/internal/app/mailer/smtptest"
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/database"
+)
+
+// sendAs makes a request to a as user, returning the status, headers and
+// body of the response
+func sendAs(t *testing.T, a *fiber.App, user, method, path, body string) (int, http.Header, []byte) {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("X-User-ID", user)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := a.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, resp.Header, data
+}
+
+func TestDigestPreviewDoesNotSend(t *testing.T) {
+	srv := smtptest.NewServer()
+	t.Cleanup(srv.Close)
+	host, port, _ := net.SplitHostPort(srv.Addr)
+	smtpPort, _ := strconv.Atoi(port)
+
+	db, err := database.Initialize("memory://")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{AppName: "Todo API", DatabaseURL: "memory://", SMTPHost: host, SMTPPort: smtpPort, SMTPFrom: "todo@example.com"}
+	server := app.NewWithStores(cfg, app.NewStores(db))
+	t.Cleanup(func() { server.HTTP.Shutdown() })
+	a := server.HTTP
+
+	preferences := `{"email": "alice@example.com", "timezone": "America/New_York", "digest_frequency": "daily", "digest_hour": 7}`
+	if status, _, data := sendAs(t, a, "alice", http.MethodPut, "/api/v1/preferences", preferences); status != http.StatusOK {
+		t.Fatalf("preferences = %d: %s", status, data)
+	}
+
+	newYork, _ := time.LoadLocation("America/New_York")
+	overdue := time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+	inThreeDays := time.Now().In(newYork).AddDate(0, 0, 3).Format(models.DateLayout)
+	for _, body := range []string{
+		`{"title": "Pay <rent>", "project": "Home", "due_date": "` + overdue + `"}`,
+		`{"title": "Dentist", "due_date": "` + inThreeDays + `"}`,
+		`{"title": "Someday"}`,
+	} {
+		if status := send(t, a, http.MethodPost, "/api/v1/todos", body, nil); status != http.StatusCreated {
+			t.Fatalf("create = %d", status)
+		}
+	}
+
+	tests := []struct {
+		query    string
+		timezone string
+	}{
+		{"", "America/New_York"},
+		{"?timezone=Asia/Tokyo", "Asia/Tokyo"},
+	}
+	for _, tt := range tests {
+		status, _, data := sendAs(t, a, "alice", http.MethodGet, "/api/v1/digest/preview"+tt.query, "")
+		if status != http.StatusOK {
+			t.Fatalf("preview%s = %d: %s", tt.query, status, data)
+		}
+		var d models.Digest
+		if err := json.Unmarshal(data, &d); err != nil {
+			t.Fatal(err)
+		}
+		if d.UserID != "alice" || d.Timezone != tt.timezone || d.Total != 2 {
+			t.Errorf("preview%s for %s in %s with %d todos", tt.query, d.UserID, d.Timezone, d.Total)
+		}
+		if len(d.Sections) != 3 || d.Sections[0].Count != 1 || d.Sections[0].Projects[0].Project != "Home" {
+			t.Errorf("preview%s sections %s", tt.query, data)
+		}
+	}
+
+	formats := map[string][2]string{
+		"text": {"text/plain; charset=utf-8", "  - Pay <rent>, due "},
+		"html": {"text/html; charset=utf-8", "<li><strong>Pay &lt;rent&gt;</strong>, due "},
+	}
+	for format, want := range formats {
+		status, header, data := sendAs(t, a, "alice", http.MethodGet, "/api/v1/digest/preview?format="+format, "")
+		if status != http.StatusOK || header.Get("Content-Type") != want[0] {
+			t.Errorf("%s: status %d, content type %q", format, status, header.Get("Content-Type"))
+		}
+		if body := string(data); !strings.Contains(body, want[1]) || !strings.Contains(body, "Times are in America/New_York.") {
+			t.Errorf("%s:\n%s", format, body)
+		}
+	}
+
+	for _, query := range []string{"?format=pdf", "?timezone=Mars/Olympus", "?timezone=Local"} {
+		if status, _, _ := sendAs(t, a, "alice", http.MethodGet, "/api/v1/digest/preview"+query, ""); status != http.StatusBadRequest {
+			t.Errorf("preview%s = %d, want 400", query, status)
+		}
+	}
+
+	// Nothing was mailed or recorded as sent
+	if msgs := srv.Messages(); len(msgs) != 0 {
+		t.Errorf("previews sent %d messages", len(msgs))
+	}
+	var stored models.Preferences
+	_, _, data := sendAs(t, a, "alice", http.MethodGet, "/api/v1/preferences", "")
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.LastDigestAt != nil || stored.NextDigestAt == nil {
+		t.Errorf("last digest at %v, next at %v", stored.LastDigestAt, stored.NextDigestAt)
+	}
+}
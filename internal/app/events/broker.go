@@ -0,0 +1,246 @@
+// Package events distributes todo change events to live subscribers.
+package events
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/repositories"
+)
+
+// Filter selects the events a subscriber receives; nil accepts everything
+type Filter func(event *models.TodoEvent) bool
+
+// Subscription is a live feed of events. C is closed when the subscription
+// ends, either through Close, broker shutdown, or because the subscriber
+// fell more than its buffer behind; in the last case Dropped reports true
+// and the subscriber should resume from the event log.
+type Subscription struct {
+	C <-chan *models.TodoEvent
+	// After is the log position the feed starts after: every event sent
+	// on C has a greater Position. It is -1 if the log could not be read.
+	After int64
+
+	ch      chan *models.TodoEvent
+	filter  Filter
+	broker  *Broker
+	dropped bool
+}
+
+// Dropped reports whether the subscription was ended for being too slow
+func (s *Subscription) Dropped() bool {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	return s.dropped
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.remove(s)
+}
+
+// catchUpBatchSize bounds how many logged events are read per query while
+// the broker catches up with the log
+const catchUpBatchSize = 100
+
+// Broker appends events to the event log and fans them out to subscribers
+// from the log, in the order they were logged. Events logged by other
+// replicas sharing the log are fanned out too, once Run polls for them.
+type Broker struct {
+	log repositories.EventStore
+
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	closed bool
+	// last is the position of the last event fanned out, or -1 until the
+	// end of the log is known
+	last int64
+}
+
+// NewBroker creates a Broker persisting events to log
+func NewBroker(log repositories.EventStore) *Broker {
+	return &Broker{
+		log:  log,
+		subs: make(map[*Subscription]struct{}),
+		last: -1,
+	}
+}
+
+// Publish records event in the log under the ID the outbox assigned, then
+// delivers it, and any event logged before it that was not yet, to every
+// matching subscriber without blocking. An event the log already holds is
+// not delivered again, so retried relays do not repeat it.
+func (b *Broker) Publish(event *models.TodoEvent) error {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	// Hold the lock across the append so subscribers see events in log order
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.start(); err != nil {
+		return err
+	}
+	if err := b.log.Append(event); err != nil {
+		return fmt.Errorf("failed to record event: %w", err)
+	}
+	return b.catchUp()
+}
+
+// Run fans out the events other replicas log, checking the log every
+// interval (1s if zero) until ctx is cancelled
+func (b *Broker) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		b.mu.Lock()
+		err := b.start()
+		if err == nil {
+			err = b.catchUp()
+		}
+		b.mu.Unlock()
+		if err != nil {
+			log.Printf("Event broker: %v", err)
+		}
+	}
+}
+
+// start reads where the log ends unless it is known; b.mu must be held.
+// Events logged before the broker started are only replayed, never fanned out.
+func (b *Broker) start() error {
+	if b.last >= 0 {
+		return nil
+	}
+	last, err := b.log.Latest()
+	if err != nil {
+		return fmt.Errorf("failed to read event log: %w", err)
+	}
+	b.last = last
+	return nil
+}
+
+// catchUp fans out every event logged after the last one fanned out; b.mu
+// must be held
+func (b *Broker) catchUp() error {
+	for {
+		batch, err := b.log.ListSince(b.last, catchUpBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to read event log: %w", err)
+		}
+		for _, event := range batch {
+			b.fanOut(event)
+			b.last = event.Position
+		}
+		if len(batch) < catchUpBatchSize {
+			return nil
+		}
+	}
+}
+
+// fanOut delivers event to every matching subscriber; b.mu must be held
+func (b *Broker) fanOut(event *models.TodoEvent) {
+	for sub := range b.subs {
+		if sub.filter != nil && !sub.filter(event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			// Slow consumer: cut it loose rather than stall publishers
+			sub.dropped = true
+			b.remove(sub)
+		}
+	}
+}
+
+// Subscribe registers a subscriber buffering up to buffer events
+func (b *Broker) Subscribe(buffer int, filter Filter) *Subscription {
+	ch := make(chan *models.TodoEvent, buffer)
+	sub := &Subscription{C: ch, ch: ch, filter: filter, broker: b}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.start(); err != nil {
+		log.Printf("Event broker: %v", err)
+	}
+	sub.After = b.last
+	if b.closed {
+		close(ch)
+	} else {
+		b.subs[sub] = struct{}{}
+	}
+	return sub
+}
+
+// Cursor returns the log position to replay from for a client that last
+// received the event with the given ID
+func (b *Broker) Cursor(eventID int64) (int64, error) {
+	position, err := b.log.Locate(eventID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find event %d: %w", eventID, err)
+	}
+	return position, nil
+}
+
+// Replay passes every event logged after the given position that matches
+// filter to fn, in log order, reading the log in batches of batchSize. It
+// returns the position of the last event read, matching or not, so callers
+// can skip live events they have already seen.
+func (b *Broker) Replay(afterPosition int64, batchSize int, filter Filter, fn func(event *models.TodoEvent) error) (int64, error) {
+	for {
+		batch, err := b.log.ListSince(afterPosition, batchSize)
+		if err != nil {
+			return afterPosition, fmt.Errorf("failed to replay events after position %d: %w", afterPosition, err)
+		}
+		for _, event := range batch {
+			afterPosition = event.Position
+			if filter != nil && !filter(event) {
+				continue
+			}
+			if err := fn(event); err != nil {
+				return afterPosition, err
+			}
+		}
+		if len(batch) < batchSize {
+			return afterPosition, nil
+		}
+	}
+}
+
+// Close ends every subscription and rejects new ones
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subs {
+		b.remove(sub)
+	}
+}
+
+// remove unregisters sub and closes its channel; b.mu must be held
+func (b *Broker) remove(sub *Subscription) {
+	if _, ok := b.subs[sub]; !ok {
+		return
+	}
+	delete(b.subs, sub)
+	close(sub.ch)
+}
@@ -0,0 +1,127 @@
+package events_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/events"
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/repositories"
+	"github.com/teguh/go-todo-api/internal/database"
+)
+
+// openLog returns an event log of the given dialect
+func openLog(t *testing.T, dialect string) repositories.EventStore {
+	t.Helper()
+
+	url := "memory://"
+	if dialect == database.DialectSQLite {
+		url = "sqlite://" + filepath.Join(t.TempDir(), "todo.db")
+	}
+	db, err := database.Initialize(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return repositories.NewEventRepository(db)
+}
+
+// receive returns the IDs of the next n events of sub
+func receive(t *testing.T, sub *events.Subscription, n int) []int64 {
+	t.Helper()
+
+	var ids []int64
+	for len(ids) < n {
+		select {
+		case event, ok := <-sub.C:
+			if !ok {
+				t.Fatalf("subscription ended after %v", ids)
+			}
+			ids = append(ids, event.ID)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %v, want %d events", ids, n)
+		}
+	}
+	return ids
+}
+
+func publish(t *testing.T, broker *events.Broker, id int64) {
+	t.Helper()
+	event := &models.TodoEvent{ID: id, Type: models.EventUpdated, TodoID: "t1", Todo: &models.Todo{ID: "t1"}}
+	if err := broker.Publish(event); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLateEventsReachStreams(t *testing.T) {
+	for _, dialect := range []string{database.DialectSQLite, database.DialectMemory} {
+		t.Run(dialect, func(t *testing.T) {
+			broker := events.NewBroker(openLog(t, dialect))
+			sub := broker.Subscribe(16, nil)
+			defer sub.Close()
+
+			// Event 5 is relayed after 6, as when its first relay failed or
+			// its transaction committed last. Repeats are not fanned out again.
+			publish(t, broker, 6)
+			publish(t, broker, 5)
+			publish(t, broker, 6)
+			publish(t, broker, 7)
+			if ids := receive(t, sub, 3); ids[0] != 6 || ids[1] != 5 || ids[2] != 7 {
+				t.Fatalf("received %v, want [6 5 7]", ids)
+			}
+
+			// A client that last saw 6 resumes with 5 and 7
+			var replayed []int64
+			cursor, err := broker.Cursor(6)
+			if err != nil {
+				t.Fatal(err)
+			}
+			last, err := broker.Replay(cursor, 1, nil, func(event *models.TodoEvent) error {
+				replayed = append(replayed, event.ID)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(replayed) != 2 || replayed[0] != 5 || replayed[1] != 7 {
+				t.Errorf("replayed %v after 6, want [5 7]", replayed)
+			}
+
+			// Live events arriving after the replay are told apart by position
+			publish(t, broker, 4)
+			event := <-sub.C
+			if event.ID != 4 || event.Position <= last {
+				t.Errorf("live event %d at position %d, replay ended at %d", event.ID, event.Position, last)
+			}
+
+			// A cursor that was never logged resumes before the first event
+			// logged with a higher ID, here 6
+			if cursor, err := broker.Cursor(3); err != nil || cursor != 0 {
+				t.Errorf("cursor of 3 = %d, %v; want 0", cursor, err)
+			}
+		})
+	}
+}
+
+func TestBrokerFansOutEventsOfOtherReplicas(t *testing.T) {
+	log := openLog(t, database.DialectSQLite)
+	relaying := events.NewBroker(log)
+	watching := events.NewBroker(log)
+
+	publish(t, relaying, 1)
+	sub := watching.Subscribe(16, nil)
+	defer sub.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watching.Run(ctx, 10*time.Millisecond)
+
+	// Only events logged after the subscription began are fanned out
+	publish(t, relaying, 3)
+	publish(t, relaying, 2)
+	if ids := receive(t, sub, 2); ids[0] != 3 || ids[1] != 2 {
+		t.Errorf("received %v from the other replica, want [3 2]", ids)
+	}
+}
@@ -0,0 +1,98 @@
+package events
+
+import (
+	"sort"
+	"sync"
+)
+
+// Viewers describes who is currently viewing a project
+type Viewers struct {
+	Project string `json:"project"`
+	// Users lists the distinct identified users, sorted
+	Users []string `json:"users"`
+	// Count is the number of open views, including anonymous ones
+	Count int `json:"count"`
+}
+
+// viewer is one open view of a project
+type viewer struct {
+	user   string
+	notify func(project string)
+}
+
+// Presence tracks which users are viewing which projects
+type Presence struct {
+	mu    sync.Mutex
+	rooms map[string]map[*viewer]struct{}
+}
+
+// NewPresence creates an empty Presence
+func NewPresence() *Presence {
+	return &Presence{rooms: make(map[string]map[*viewer]struct{})}
+}
+
+// Join records user as viewing project and returns a function undoing it.
+// notify is called, and must not block, whenever the viewers of project
+// change, starting with this join; it should fetch the current state with
+// Viewers rather than rely on the order of notifications.
+func (p *Presence) Join(project, user string, notify func(project string)) (leave func()) {
+	v := &viewer{user: user, notify: notify}
+
+	p.mu.Lock()
+	room, ok := p.rooms[project]
+	if !ok {
+		room = make(map[*viewer]struct{})
+		p.rooms[project] = room
+	}
+	room[v] = struct{}{}
+	p.mu.Unlock()
+
+	p.notifyRoom(project)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.mu.Lock()
+			delete(room, v)
+			if len(room) == 0 {
+				delete(p.rooms, project)
+			}
+			p.mu.Unlock()
+
+			p.notifyRoom(project)
+		})
+	}
+}
+
+// Viewers returns who is viewing project right now
+func (p *Presence) Viewers(project string) Viewers {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	room := p.rooms[project]
+	seen := make(map[string]bool)
+	users := []string{}
+	for v := range room {
+		if v.user != "" && !seen[v.user] {
+			seen[v.user] = true
+			users = append(users, v.user)
+		}
+	}
+	sort.Strings(users)
+
+	return Viewers{Project: project, Users: users, Count: len(room)}
+}
+
+// notifyRoom tells every viewer of project that its viewers changed
+func (p *Presence) notifyRoom(project string) {
+	p.mu.Lock()
+	notify := make([]func(string), 0, len(p.rooms[project]))
+	for v := range p.rooms[project] {
+		notify = append(notify, v.notify)
+	}
+	p.mu.Unlock()
+
+	for _, fn := range notify {
+		fn(project)
+	}
+}
@@ -0,0 +1,131 @@
+package gql
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// subtaskEstimate is the number of subtasks assumed per todo when costing
+// a query, since the subtasks field is not paginated
+const subtaskEstimate = 10
+
+// maxIntrospectionDepth bounds how deeply introspection selections may
+// nest. It leaves room for the queries of GraphiQL and other tools, which
+// follow ofType several levels down to describe wrapped types.
+const maxIntrospectionDepth = 20
+
+// cost is the estimated cost of executing an operation
+type cost struct {
+	complexity int
+	depth      int
+	// introspectionDepth is the depth of the deepest field selected within
+	// an introspection field such as __schema or __type
+	introspectionDepth int
+}
+
+// analyze estimates the cost of operation before it is executed. Every
+// field costs one, and the fields selected below a list are counted once
+// per item it may hold: first (or the default page size) for paginated
+// lists and subtaskEstimate for subtasks. Introspection fields cost one
+// each however many items their lists hold, as the schema bounds those, so
+// aliasing __schema or __type adds up while GraphiQL's query costs under
+// 200. Their depth is measured separately, against maxIntrospectionDepth,
+// so tools keep working under tight depth limits. The document must have
+// passed validation, which rules out fragment cycles.
+func analyze(doc *ast.Document, operation *ast.OperationDefinition, variables map[string]interface{}) cost {
+	a := analyzer{
+		fragments: make(map[string]*ast.FragmentDefinition),
+		variables: variables,
+	}
+	for _, def := range doc.Definitions {
+		if fragment, ok := def.(*ast.FragmentDefinition); ok {
+			a.fragments[fragment.Name.Value] = fragment
+		}
+	}
+	return a.selectionSet(operation.SelectionSet, 1, false)
+}
+
+type analyzer struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+}
+
+// selectionSet costs the fields of set, which sit at the given depth and
+// within an introspection field if introspection is set
+func (a analyzer) selectionSet(set *ast.SelectionSet, depth int, introspection bool) cost {
+	var total cost
+	if set == nil {
+		return total
+	}
+
+	add := func(c cost) {
+		total.complexity += c.complexity
+		total.depth = max(total.depth, c.depth)
+		total.introspectionDepth = max(total.introspectionDepth, c.introspectionDepth)
+	}
+
+	for _, selection := range set.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if introspection || strings.HasPrefix(selection.Name.Value, "__") {
+				children := a.selectionSet(selection.SelectionSet, depth+1, true)
+				add(cost{
+					complexity:         1 + children.complexity,
+					introspectionDepth: max(depth, children.introspectionDepth),
+				})
+				continue
+			}
+			children := a.selectionSet(selection.SelectionSet, depth+1, false)
+			add(cost{
+				complexity:         1 + children.complexity*a.multiplier(selection),
+				depth:              max(depth, children.depth),
+				introspectionDepth: children.introspectionDepth,
+			})
+		case *ast.InlineFragment:
+			add(a.selectionSet(selection.SelectionSet, depth, introspection))
+		case *ast.FragmentSpread:
+			if fragment, ok := a.fragments[selection.Name.Value]; ok {
+				add(a.selectionSet(fragment.SelectionSet, depth, introspection))
+			}
+		}
+	}
+	return total
+}
+
+// multiplier returns how many items field may resolve to
+func (a analyzer) multiplier(field *ast.Field) int {
+	if field.Name.Value == "subtasks" {
+		return subtaskEstimate
+	}
+	for _, arg := range field.Arguments {
+		if arg.Name.Value == "first" {
+			if first, ok := a.intValue(arg.Value); ok && first > 0 {
+				return first
+			}
+			return DefaultPageSize
+		}
+	}
+	if field.Name.Value == "todos" {
+		return DefaultPageSize
+	}
+	return 1
+}
+
+// intValue resolves an integer literal or variable
+func (a analyzer) intValue(value ast.Value) (int, bool) {
+	switch value := value.(type) {
+	case *ast.IntValue:
+		n, err := strconv.Atoi(value.Value)
+		return n, err == nil
+	case *ast.Variable:
+		switch n := a.variables[value.Name.Value].(type) {
+		case int:
+			return n, true
+		case float64:
+			return int(n), true
+		}
+	}
+	return 0, false
+}
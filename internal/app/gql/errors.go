@@ -0,0 +1,58 @@
+package gql
+
+import (
+	"errors"
+	"log"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+// Error codes reported in the extensions of GraphQL errors. Clients should
+// switch on these rather than on the message.
+const (
+	CodeBadUserInput   = "BAD_USER_INPUT"
+	CodeNotFound       = "NOT_FOUND"
+	CodeConflict       = "CONFLICT"
+	CodeTooComplex     = "QUERY_TOO_COMPLEX"
+	CodeInternalServer = "INTERNAL_SERVER_ERROR"
+)
+
+// Error is a GraphQL error with a code and, for validation failures, the
+// rejected fields in its extensions
+type Error struct {
+	Message string
+	Code    string
+	Fields  []models.FieldError
+}
+
+// Error returns the message
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Extensions implements gqlerrors.ExtendedError
+func (e *Error) Extensions() map[string]interface{} {
+	extensions := map[string]interface{}{"code": e.Code}
+	if len(e.Fields) > 0 {
+		extensions["fields"] = e.Fields
+	}
+	return extensions
+}
+
+// toError maps an error returned by the service to an *Error. Like the REST
+// handlers, unknown errors are logged and reported with a generic message so
+// internal details are not leaked.
+func toError(err error) error {
+	var validationErr *models.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return &Error{Message: validationErr.Error(), Code: CodeBadUserInput, Fields: validationErr.Fields}
+	case errors.Is(err, models.ErrNotFound):
+		return &Error{Message: err.Error(), Code: CodeNotFound}
+	case errors.Is(err, models.ErrConflict):
+		return &Error{Message: err.Error(), Code: CodeConflict}
+	default:
+		log.Printf("Error: %v", err)
+		return &Error{Message: "Internal Server Error", Code: CodeInternalServer}
+	}
+}
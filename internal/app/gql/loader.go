@@ -0,0 +1,138 @@
+package gql
+
+import (
+	"context"
+	"sync"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/services"
+)
+
+// loader batches the keys requested while one level of a query is being
+// resolved into a single fetch, DataLoader style. Load hands back a thunk;
+// graphql-go calls the thunks of a level only after every resolver of that
+// level has run, so the first thunk called fetches all queued keys at once.
+// Results are cached for the rest of the request.
+type loader struct {
+	fetch func(keys []string) (map[string]interface{}, error)
+
+	mu      sync.Mutex
+	queued  []string
+	results map[string]interface{}
+	errs    map[string]error
+}
+
+func newLoader(fetch func(keys []string) (map[string]interface{}, error)) *loader {
+	return &loader{
+		fetch:   fetch,
+		results: make(map[string]interface{}),
+		errs:    make(map[string]error),
+	}
+}
+
+// load queues key and returns a thunk resolving to its value, or nil if
+// fetch found nothing for it
+func (l *loader) load(key string) func() (interface{}, error) {
+	l.mu.Lock()
+	if !l.done(key) && !l.isQueued(key) {
+		l.queued = append(l.queued, key)
+	}
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if !l.done(key) {
+			l.flush()
+		}
+		if err := l.errs[key]; err != nil {
+			return nil, err
+		}
+		return l.results[key], nil
+	}
+}
+
+// flush fetches every queued key; l.mu must be held
+func (l *loader) flush() {
+	keys := l.queued
+	l.queued = nil
+
+	values, err := l.fetch(keys)
+	for _, key := range keys {
+		if err != nil {
+			l.errs[key] = err
+		} else {
+			l.results[key] = values[key]
+		}
+	}
+}
+
+func (l *loader) done(key string) bool {
+	_, ok := l.results[key]
+	if !ok {
+		_, ok = l.errs[key]
+	}
+	return ok
+}
+
+func (l *loader) isQueued(key string) bool {
+	for _, k := range l.queued {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// loaders holds the loaders of a single request. They must not outlive it,
+// or results would be served stale.
+type loaders struct {
+	todos    *loader
+	subtasks *loader
+}
+
+type loadersKey struct{}
+
+// withLoaders returns a copy of ctx carrying fresh loaders backed by service
+func withLoaders(ctx context.Context, service *services.TodoService) context.Context {
+	l := &loaders{
+		// Todos by ID, for parents
+		todos: newLoader(func(ids []string) (map[string]interface{}, error) {
+			todos, err := service.GetTodosByIDs(ctx, ids)
+			if err != nil {
+				return nil, err
+			}
+			values := make(map[string]interface{}, len(todos))
+			for _, todo := range todos {
+				values[todo.ID] = todo
+			}
+			return values, nil
+		}),
+		// Subtasks by parent ID; every parent gets a list, even if empty
+		subtasks: newLoader(func(parentIDs []string) (map[string]interface{}, error) {
+			todos, err := service.ListSubtasks(ctx, parentIDs)
+			if err != nil {
+				return nil, err
+			}
+			lists := make(map[string][]*models.Todo, len(parentIDs))
+			for _, todo := range todos {
+				lists[todo.ParentID] = append(lists[todo.ParentID], todo)
+			}
+			values := make(map[string]interface{}, len(parentIDs))
+			for _, id := range parentIDs {
+				if lists[id] == nil {
+					lists[id] = []*models.Todo{}
+				}
+				values[id] = lists[id]
+			}
+			return values, nil
+		}),
+	}
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+// loadersFrom returns the loaders stored in ctx by withLoaders
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
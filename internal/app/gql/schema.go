@@ -0,0 +1,457 @@
+package gql
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/services"
+)
+
+// DefaultPageSize is the number of todos returned when first is omitted
+const DefaultPageSize = 20
+
+// cursorPrefix marks cursors, which are opaque to clients but encode an offset
+const cursorPrefix = "offset:"
+
+// connection is the source of a TodoConnection. The count is only queried
+// if totalCount or pageInfo is selected.
+type connection struct {
+	nodes  []*models.Todo
+	offset int
+
+	count  func() (*models.TodoCounts, error)
+	once   sync.Once
+	counts *models.TodoCounts
+	err    error
+}
+
+func (c *connection) total() (int, error) {
+	c.once.Do(func() { c.counts, c.err = c.count() })
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.counts.Total, nil
+}
+
+// newSchema builds the schema, resolving everything through service
+func newSchema(service *services.TodoService) (graphql.Schema, error) {
+	todoType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Todo",
+		Description: "A todo item. Subtasks are one level deep: a subtask has a parent but no subtasks.",
+		Fields: graphql.Fields{
+			"id":          todoField(graphql.NewNonNull(graphql.ID), func(t *models.Todo) interface{} { return t.ID }),
+			"title":       todoField(graphql.NewNonNull(graphql.String), func(t *models.Todo) interface{} { return t.Title }),
+			"description": todoField(graphql.NewNonNull(graphql.String), func(t *models.Todo) interface{} { return t.Description }),
+			"project":     todoField(graphql.NewNonNull(graphql.String), func(t *models.Todo) interface{} { return t.Project }),
+			"completed":   todoField(graphql.NewNonNull(graphql.Boolean), func(t *models.Todo) interface{} { return t.Completed }),
+			"priority":    todoField(graphql.NewNonNull(graphql.Int), func(t *models.Todo) interface{} { return t.Priority }),
+			"tags":        todoField(graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))), func(t *models.Todo) interface{} { return t.Tags }),
+			"dueDate": todoField(graphql.String, func(t *models.Todo) interface{} {
+				if !t.DueDate.Valid {
+					return nil
+				}
+				if t.AllDay {
+					return t.DueDate.Time.UTC().Format(models.DateLayout)
+				}
+				return t.DueDate.Time.UTC().Format(time.RFC3339)
+			}),
+			"allDay":    todoField(graphql.NewNonNull(graphql.Boolean), func(t *models.Todo) interface{} { return t.AllDay }),
+			"createdAt": todoField(graphql.NewNonNull(graphql.String), func(t *models.Todo) interface{} { return t.CreatedAt.Format(time.RFC3339Nano) }),
+			"updatedAt": todoField(graphql.NewNonNull(graphql.String), func(t *models.Todo) interface{} { return t.UpdatedAt.Format(time.RFC3339Nano) }),
+			"parentId": todoField(graphql.ID, func(t *models.Todo) interface{} {
+				if t.ParentID == "" {
+					return nil
+				}
+				return t.ParentID
+			}),
+		},
+	})
+
+	// Relations are added once the type exists, since they refer back to it
+	todoType.AddFieldConfig("parent", &graphql.Field{
+		Type:        todoType,
+		Description: "The todo this is a subtask of",
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			todo := p.Source.(*models.Todo)
+			if todo.ParentID == "" {
+				return nil, nil
+			}
+			return thunk(loadersFrom(p.Context).todos.load(todo.ParentID)), nil
+		},
+	})
+	todoType.AddFieldConfig("subtasks", &graphql.Field{
+		Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(todoType))),
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			todo := p.Source.(*models.Todo)
+			return thunk(loadersFrom(p.Context).subtasks.load(todo.ID)), nil
+		},
+	})
+	todoType.AddFieldConfig("subtaskCount", &graphql.Field{
+		Type: graphql.NewNonNull(graphql.Int),
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			todo := p.Source.(*models.Todo)
+			load := loadersFrom(p.Context).subtasks.load(todo.ID)
+			return thunk(func() (interface{}, error) {
+				subtasks, err := load()
+				if err != nil {
+					return nil, err
+				}
+				return len(subtasks.([]*models.Todo)), nil
+			}), nil
+		},
+	})
+
+	sortValues := graphql.EnumValueConfigMap{}
+	for _, field := range models.SortFields {
+		sortValues[strings.ToUpper(field)] = &graphql.EnumValueConfig{Value: field}
+	}
+	sortType := graphql.NewEnum(graphql.EnumConfig{
+		Name:        "TodoSort",
+		Description: "Fields todos can be sorted by. Todos without a due date sort last.",
+		Values:      sortValues,
+	})
+
+	filterType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "TodoFilter",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"completed": &graphql.InputObjectFieldConfig{Type: graphql.Boolean},
+			"project":   &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"tag":       &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"parentId":  &graphql.InputObjectFieldConfig{Type: graphql.ID, Description: "Only subtasks of this todo"},
+			"topLevel":  &graphql.InputObjectFieldConfig{Type: graphql.Boolean, Description: "Only todos that are not subtasks"},
+			"search":    &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "Case-insensitive match on title or description"},
+		},
+	})
+
+	countsType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "TodoCounts",
+		Fields: graphql.Fields{
+			"total":     &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"completed": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"open":      &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		},
+	})
+
+	pageInfoType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PageInfo",
+		Fields: graphql.Fields{
+			"hasNextPage": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					conn := p.Source.(*connection)
+					total, err := conn.total()
+					if err != nil {
+						return nil, toError(err)
+					}
+					return conn.offset+len(conn.nodes) < total, nil
+				},
+			},
+			"endCursor": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Pass as after to fetch the next page",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					conn := p.Source.(*connection)
+					if len(conn.nodes) == 0 {
+						return nil, nil
+					}
+					return encodeCursor(conn.offset + len(conn.nodes) - 1), nil
+				},
+			},
+		},
+	})
+
+	connectionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "TodoConnection",
+		Fields: graphql.Fields{
+			"nodes": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(todoType))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*connection).nodes, nil
+				},
+			},
+			"totalCount": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Int),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					total, err := p.Source.(*connection).total()
+					if err != nil {
+						return nil, toError(err)
+					}
+					return total, nil
+				},
+			},
+			"pageInfo": &graphql.Field{
+				Type: graphql.NewNonNull(pageInfoType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source, nil
+				},
+			},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"todo": &graphql.Field{
+				Type:        todoType,
+				Description: "A todo by ID, or null if it does not exist",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					todo, err := service.GetTodoByID(p.Context, p.Args["id"].(string))
+					if errors.Is(err, models.ErrNotFound) {
+						return nil, nil
+					}
+					if err != nil {
+						return nil, toError(err)
+					}
+					return todo, nil
+				},
+			},
+			"todos": &graphql.Field{
+				Type:        graphql.NewNonNull(connectionType),
+				Description: fmt.Sprintf("A page of todos. first may be at most %d.", services.MaxPageSize),
+				Args: graphql.FieldConfigArgument{
+					"filter":     &graphql.ArgumentConfig{Type: filterType},
+					"sort":       &graphql.ArgumentConfig{Type: sortType, Description: "Defaults to priority, then newest first"},
+					"descending": &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false},
+					"first":      &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: DefaultPageSize},
+					"after":      &graphql.ArgumentConfig{Type: graphql.String, Description: "endCursor of the previous page"},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					query := models.TodoQuery{
+						Filter: filterFromArgs(p.Args["filter"]),
+						Limit:  p.Args["first"].(int),
+					}
+					if query.Limit < 1 {
+						// FindTodos reads zero as no limit, which would escape the cost limits
+						return nil, toError(models.NewValidationError("first", fmt.Sprintf("first must be between 1 and %d", services.MaxPageSize)))
+					}
+					query.Sort, _ = p.Args["sort"].(string)
+					query.Descending, _ = p.Args["descending"].(bool)
+					if after, ok := p.Args["after"].(string); ok {
+						position, err := decodeCursor(after)
+						if err != nil {
+							return nil, toError(err)
+						}
+						query.Offset = position + 1
+					}
+
+					todos, err := service.FindTodos(p.Context, query)
+					if err != nil {
+						return nil, toError(err)
+					}
+					if todos == nil {
+						todos = []*models.Todo{}
+					}
+					return &connection{
+						nodes:  todos,
+						offset: query.Offset,
+						count:  func() (*models.TodoCounts, error) { return service.CountTodos(p.Context, query.Filter) },
+					}, nil
+				},
+			},
+			"todoCounts": &graphql.Field{
+				Type: graphql.NewNonNull(countsType),
+				Args: graphql.FieldConfigArgument{
+					"filter": &graphql.ArgumentConfig{Type: filterType},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					counts, err := service.CountTodos(p.Context, filterFromArgs(p.Args["filter"]))
+					if err != nil {
+						return nil, toError(err)
+					}
+					return map[string]interface{}{
+						"total":     counts.Total,
+						"completed": counts.Completed,
+						"open":      counts.Open,
+					}, nil
+				},
+			},
+		},
+	})
+
+	createInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "CreateTodoInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"title":       &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"description": &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"project":     &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"parentId":    &graphql.InputObjectFieldConfig{Type: graphql.ID},
+			"tags":        &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+			"priority":    &graphql.InputObjectFieldConfig{Type: graphql.Int},
+			"dueDate":     &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "RFC3339 timestamp, or a date such as 2006-01-02 for an all-day todo"},
+		},
+	})
+	updateInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        "UpdateTodoInput",
+		Description: "Omitted fields are left unchanged. Empty strings clear description, project, dueDate and parentId.",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"title":       &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"description": &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"project":     &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"parentId":    &graphql.InputObjectFieldConfig{Type: graphql.ID},
+			"tags":        &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String)), Description: "Replaces every tag"},
+			"completed":   &graphql.InputObjectFieldConfig{Type: graphql.Boolean},
+			"priority":    &graphql.InputObjectFieldConfig{Type: graphql.Int},
+			"dueDate":     &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "RFC3339 timestamp, or a date such as 2006-01-02 for an all-day todo"},
+		},
+	})
+
+	mutationType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createTodo": &graphql.Field{
+				Type: graphql.NewNonNull(todoType),
+				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(createInput)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					input := p.Args["input"].(map[string]interface{})
+					create := models.TodoCreate{Tags: stringList(input["tags"])}
+					create.Title, _ = input["title"].(string)
+					create.Description, _ = input["description"].(string)
+					create.Project, _ = input["project"].(string)
+					create.ParentID, _ = input["parentId"].(string)
+					create.Priority, _ = input["priority"].(int)
+					create.DueDate, _ = input["dueDate"].(string)
+
+					todo, err := service.CreateTodo(p.Context, create)
+					if err != nil {
+						return nil, toError(err)
+					}
+					return todo, nil
+				},
+			},
+			"updateTodo": &graphql.Field{
+				Type: graphql.NewNonNull(todoType),
+				Args: graphql.FieldConfigArgument{
+					"id":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(updateInput)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					input := p.Args["input"].(map[string]interface{})
+					update := models.TodoUpdate{
+						Title:       optional[string](input, "title"),
+						Description: optional[string](input, "description"),
+						Project:     optional[string](input, "project"),
+						ParentID:    optional[string](input, "parentId"),
+						Completed:   optional[bool](input, "completed"),
+						Priority:    optional[int](input, "priority"),
+						DueDate:     optional[string](input, "dueDate"),
+					}
+					if _, ok := input["tags"]; ok {
+						tags := stringList(input["tags"])
+						update.Tags = &tags
+					}
+
+					todo, err := service.UpdateTodo(p.Context, p.Args["id"].(string), update)
+					if err != nil {
+						return nil, toError(err)
+					}
+					return todo, nil
+				},
+			},
+			"deleteTodo": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.ID),
+				Description: "Deletes a todo and returns its ID. Todos with subtasks cannot be deleted.",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id := p.Args["id"].(string)
+					if err := service.DeleteTodo(p.Context, id); err != nil {
+						return nil, toError(err)
+					}
+					return id, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:    queryType,
+		Mutation: mutationType,
+	})
+}
+
+// todoField is a field of Todo read from the source todo by get
+func todoField(fieldType graphql.Output, get func(t *models.Todo) interface{}) *graphql.Field {
+	return &graphql.Field{
+		Type: fieldType,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return get(p.Source.(*models.Todo)), nil
+		},
+	}
+}
+
+// thunk adapts a loader thunk to the signature graphql-go resolves lazily,
+// mapping its error like any other
+func thunk(load func() (interface{}, error)) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		value, err := load()
+		if err != nil {
+			return nil, toError(err)
+		}
+		return value, nil
+	}
+}
+
+// filterFromArgs converts a TodoFilter argument
+func filterFromArgs(arg interface{}) models.TodoFilter {
+	var filter models.TodoFilter
+	input, _ := arg.(map[string]interface{})
+	filter.Completed = optional[bool](input, "completed")
+	filter.Project = optional[string](input, "project")
+	filter.ParentID = optional[string](input, "parentId")
+	filter.Tag, _ = input["tag"].(string)
+	filter.Search, _ = input["search"].(string)
+	if topLevel, _ := input["topLevel"].(bool); topLevel && filter.ParentID == nil {
+		filter.ParentID = new(string)
+	}
+	return filter
+}
+
+// optional returns a pointer to input[key], or nil if it is absent
+func optional[T any](input map[string]interface{}, key string) *T {
+	if value, ok := input[key].(T); ok {
+		return &value
+	}
+	return nil
+}
+
+// stringList converts a list argument, which graphql-go passes as []interface{}
+func stringList(arg interface{}) []string {
+	items, _ := arg.([]interface{})
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			values = append(values, s)
+		}
+	}
+	return values
+}
+
+func encodeCursor(position int) string {
+	return base64.StdEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(position)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	invalid := models.NewValidationError("after", "after is not a valid cursor")
+	decoded, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(decoded), cursorPrefix) {
+		return 0, invalid
+	}
+	position, err := strconv.Atoi(strings.TrimPrefix(string(decoded), cursorPrefix))
+	if err != nil || position < 0 {
+		return 0, invalid
+	}
+	return position, nil
+}
@@ -0,0 +1,151 @@
+// Package gql serves todos over GraphQL. Related todos are fetched through
+// per-request loaders that batch lookups, so a page of todos with their
+// parents and subtasks costs a fixed number of queries rather than one per
+// todo, and operations are costed before they run so that deeply nested or
+// very wide queries are rejected up front.
+package gql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/location"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/teguh/go-todo-api/internal/app/services"
+)
+
+// Options limits the operations a Server executes
+type Options struct {
+	// MaxComplexity bounds the estimated number of fields resolved (1000 if zero)
+	MaxComplexity int
+	// MaxDepth bounds how deeply selections may nest (10 if zero)
+	MaxDepth int
+}
+
+// Request is a GraphQL request as sent over HTTP. Extensions are accepted
+// so that clients which send them pass strict decoding, but are ignored.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	Extensions    map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// Server executes GraphQL requests against a TodoService
+type Server struct {
+	schema  graphql.Schema
+	service *services.TodoService
+	opts    Options
+}
+
+// NewServer builds the schema on top of service
+func NewServer(service *services.TodoService, opts Options) (*Server, error) {
+	if opts.MaxComplexity <= 0 {
+		opts.MaxComplexity = 1000
+	}
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = 10
+	}
+
+	schema, err := newSchema(service)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build GraphQL schema: %w", err)
+	}
+
+	return &Server{
+		schema:  schema,
+		service: service,
+		opts:    opts,
+	}, nil
+}
+
+// Execute parses, validates, costs and runs req. Errors are reported in the
+// result, never returned. When queryOnly is set mutations are refused,
+// which keeps GET requests safe.
+func (s *Server) Execute(ctx context.Context, req Request, queryOnly bool) *graphql.Result {
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	if validation := graphql.ValidateDocument(&s.schema, doc, nil); !validation.IsValid {
+		return &graphql.Result{Errors: validation.Errors}
+	}
+
+	operation, err := findOperation(doc, req.OperationName)
+	if err != nil {
+		return errorResult(&Error{Message: err.Error(), Code: CodeBadUserInput})
+	}
+	if queryOnly && operation.Operation != ast.OperationTypeQuery {
+		return errorResult(&Error{
+			Message: fmt.Sprintf("%s operations must be sent with POST", operation.Operation),
+			Code:    CodeBadUserInput,
+		})
+	}
+
+	estimate := analyze(doc, operation, req.Variables)
+	if estimate.depth > s.opts.MaxDepth {
+		return errorResult(&Error{
+			Message: fmt.Sprintf("query depth %d exceeds the maximum of %d", estimate.depth, s.opts.MaxDepth),
+			Code:    CodeTooComplex,
+		})
+	}
+	if estimate.introspectionDepth > maxIntrospectionDepth {
+		return errorResult(&Error{
+			Message: fmt.Sprintf("introspection depth %d exceeds the maximum of %d", estimate.introspectionDepth, maxIntrospectionDepth),
+			Code:    CodeTooComplex,
+		})
+	}
+	if estimate.complexity > s.opts.MaxComplexity {
+		return errorResult(&Error{
+			Message: fmt.Sprintf("query complexity %d exceeds the maximum of %d", estimate.complexity, s.opts.MaxComplexity),
+			Code:    CodeTooComplex,
+		})
+	}
+
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        s.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       withLoaders(ctx, s.service),
+	})
+}
+
+// errorResult reports err, which stopped the request before execution
+func errorResult(err *Error) *graphql.Result {
+	return &graphql.Result{Errors: []gqlerrors.FormattedError{{
+		Message:    err.Message,
+		Locations:  []location.SourceLocation{},
+		Extensions: err.Extensions(),
+	}}}
+}
+
+// findOperation picks the operation named name, or the only one if name is empty
+func findOperation(doc *ast.Document, name string) (*ast.OperationDefinition, error) {
+	var found *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		operation, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if name == "" {
+			if found != nil {
+				return nil, fmt.Errorf("operationName is required when the document has several operations")
+			}
+			found = operation
+		} else if operation.Name != nil && operation.Name.Value == name {
+			return operation, nil
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("unknown operation %q", name)
+	}
+	return found, nil
+}
@@ -0,0 +1,63 @@
+package gql_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql/testutil"
+	"github.com/teguh/go-todo-api/internal/app/gql"
+	"github.com/teguh/go-todo-api/internal/app/repositories"
+	"github.com/teguh/go-todo-api/internal/app/services"
+)
+
+func newServer(t *testing.T, opts gql.Options) *gql.Server {
+	t.Helper()
+	store := repositories.NewMemoryTodoRepository(repositories.NewMemoryOutboxRepository())
+	server, err := gql.NewServer(services.NewTodoService(store, nil), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server
+}
+
+func TestIntrospectionIsLimited(t *testing.T) {
+	// A depth limit far below that of the introspection query does not
+	// stop tools from loading the schema
+	server := newServer(t, gql.Options{MaxDepth: 3})
+	result := server.Execute(context.Background(), gql.Request{Query: testutil.IntrospectionQuery}, true)
+	if result.HasErrors() {
+		t.Fatalf("introspection query failed: %v", result.Errors)
+	}
+
+	// but introspection cannot nest without bound
+	query := "{ __schema { types { fields { type " + strings.Repeat("{ ofType ", 20) + "{ name }" + strings.Repeat(" }", 20) + " } } } }"
+	result = server.Execute(context.Background(), gql.Request{Query: query}, true)
+	if len(result.Errors) != 1 || result.Errors[0].Extensions["code"] != gql.CodeTooComplex {
+		t.Fatalf("deep introspection: %v, want QUERY_TOO_COMPLEX", result.Errors)
+	}
+	if !strings.Contains(result.Errors[0].Message, "introspection depth") {
+		t.Errorf("message = %q", result.Errors[0].Message)
+	}
+
+	// and every introspection field counts towards the complexity, so
+	// aliasing __schema cannot repeat the work without bound
+	var aliases strings.Builder
+	for i := 0; i < 150; i++ {
+		fmt.Fprintf(&aliases, "s%d: __schema { types { name fields { name args { name } type { name } } } } ", i)
+	}
+	result = server.Execute(context.Background(), gql.Request{Query: "{ " + aliases.String() + "}"}, true)
+	if len(result.Errors) != 1 || result.Errors[0].Extensions["code"] != gql.CodeTooComplex {
+		t.Fatalf("aliased introspection: %v, want QUERY_TOO_COMPLEX", result.Errors)
+	}
+	if !strings.Contains(result.Errors[0].Message, "query complexity 1350 ") {
+		t.Errorf("message = %q", result.Errors[0].Message)
+	}
+
+	// while other queries keep to MaxDepth
+	result = server.Execute(context.Background(), gql.Request{Query: "{ todos { nodes { parent { title } } } }"}, true)
+	if len(result.Errors) != 1 || result.Errors[0].Extensions["code"] != gql.CodeTooComplex {
+		t.Errorf("deep query: %v, want QUERY_TOO_COMPLEX", result.Errors)
+	}
+}
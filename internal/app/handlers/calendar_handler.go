@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/teguh/go-todo-api/internal/app/ical"
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/services"
+)
+
+// Components the calendar feed can render todos as
+const (
+	feedComponentEvent = "vevent"
+	feedComponentTodo  = "vtodo"
+)
+
+// CalendarHandler serves todos as a calendar feed and manages the tokens
+// that let calendar apps subscribe to it
+type CalendarHandler struct {
+	calendars *services.CalendarService
+	todos     *services.TodoService
+	name      string
+	refresh   time.Duration
+}
+
+// NewCalendarHandler creates a new CalendarHandler. The feed is called name
+// in calendar apps, which are asked to poll it every refresh.
+func NewCalendarHandler(calendars *services.CalendarService, todos *services.TodoService, name string, refresh time.Duration) *CalendarHandler {
+	return &CalendarHandler{
+		calendars: calendars,
+		todos:     todos,
+		name:      name,
+		refresh:   refresh,
+	}
+}
+
+// RegisterRoutes registers the calendar feed and token routes
+func (h *CalendarHandler) RegisterRoutes(router fiber.Router) {
+	router.Get("/calendar.ics", h.Feed)
+	router.Get("/calendar/token", h.GetToken)
+	router.Post("/calendar/token", h.CreateToken)
+	router.Delete("/calendar/token", h.DeleteToken)
+}
+
+// Feed handles serving the calendar feed
+// @Summary Calendar feed
+// @Description Subscribable iCalendar feed of todos, authenticated by a calendar token rather than X-User-ID since
+// @Description calendar apps cannot set headers. By default each todo with a due date is an event at that time, which
+// @Description every calendar app shows; component=vtodo renders every todo as a task instead, for apps that show them.
+// @Description Priorities map onto iCalendar's, where 1 is the highest and 9 the lowest, completed todos have
+// @Description STATUS:COMPLETED, tags become CATEGORIES and the project X-TODO-PROJECT.
+// @Tags calendar
+// @Produce text/calendar
+// @Param token query string true "Calendar token"
+// @Param component query string false "Component todos are rendered as" Enums(vevent, vtodo) default(vevent)
+// @Param project query string false "Filter by project"
+// @Param tag query string false "Filter by tag"
+// @Param completed query boolean false "Filter by completion status"
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
+// @Success 200 {string} string "iCalendar file"
+// @Failure 400 {object} utils.ProblemDetails
+// @Failure 401 {object} utils.ProblemDetails
+// @Failure 500 {object} utils.ProblemDetails
+// @Router /calendar.ics [get]
+func (h *CalendarHandler) Feed(c *fiber.Ctx) error {
+	if _, err := h.calendars.Authenticate(c.Query("token")); err != nil {
+		return err
+	}
+
+	component := c.Query("component", feedComponentEvent)
+	if component != feedComponentEvent && component != feedComponentTodo {
+		return models.NewValidationError("component", fmt.Sprintf("component must be %s or %s", feedComponentEvent, feedComponentTodo))
+	}
+
+	query := models.TodoQuery{
+		Filter: models.TodoFilter{Tag: c.Query("tag")},
+		Sort:   models.SortDueDate,
+	}
+	if c.Query("completed") != "" {
+		completed := c.QueryBool("completed")
+		query.Filter.Completed = &completed
+	}
+	if c.Context().QueryArgs().Has("project") {
+		project := c.Query("project")
+		query.Filter.Project = &project
+	}
+	// The todos are read after the handler returns, when Fiber has reused
+	// the buffers the query's strings point into
+	query = cloneQuery(query)
+
+	todos, err := h.todos.ExportTodos(c.UserContext(), query)
+	if err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, `inline; filename="calendar.ics"`)
+
+	opts := ical.WriterOptions{
+		Name:            h.name,
+		Method:          ical.MethodPublish,
+		Events:          component == feedComponentEvent,
+		RefreshInterval: h.refresh,
+	}
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		writer := ical.NewWriter(w, opts)
+		for todo, err := range todos {
+			if err != nil {
+				// The status is sent already; the client sees a truncated calendar
+				log.Printf("Failed to render calendar feed: %v", err)
+				return
+			}
+			if writer.Write(todo) != nil {
+				return
+			}
+		}
+		if writer.Close() == nil {
+			w.Flush()
+		}
+	})
+
+	return nil
+}
+
+// GetToken handles retrieving the caller's calendar token
+// @Summary Get the calendar token
+// @Description Tell whether the user named by X-User-ID has a calendar token, and since when. The token itself is
+// @Description only returned when it is created.
+// @Tags calendar
+// @Produce json
+// @Param X-User-ID header string true "Acting user"
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
+// @Success 200 {object} models.CalendarToken
+// @Failure 400 {object} utils.ProblemDetails
+// @Failure 404 {object} utils.ProblemDetails
+// @Failure 500 {object} utils.ProblemDetails
+// @Router /calendar/token [get]
+func (h *CalendarHandler) GetToken(c *fiber.Ctx) error {
+	token, err := h.calendars.GetToken(c.UserContext())
+	if err != nil {
+		return err
+	}
+	return c.JSON(token)
+}
+
+// CreateToken handles issuing a calendar token
+// @Summary Create a calendar token
+// @Description Issue a calendar token to the user named by X-User-ID, revoking any they had, and return it with the
+// @Description feed URL to subscribe to. Only a hash is stored, so this is the one response that includes the token.
+// @Tags calendar
+// @Produce json
+// @Param X-User-ID header string true "Acting user"
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
+// @Success 201 {object} models.CalendarToken
+// @Failure 400 {object} utils.ProblemDetails
+// @Failure 500 {object} utils.ProblemDetails
+// @Router /calendar/token [post]
+func (h *CalendarHandler) CreateToken(c *fiber.Ctx) error {
+	token, err := h.calendars.CreateToken(c.UserContext())
+	if err != nil {
+		return err
+	}
+
+	// The feed is served next to this route, at .../calendar.ics
+	feedPath := strings.TrimSuffix(c.Path(), "/token") + ".ics"
+	token.FeedURL = c.BaseURL() + feedPath + "?token=" + token.Token
+
+	return c.Status(fiber.StatusCreated).JSON(token)
+}
+
+// DeleteToken handles revoking the caller's calendar token
+// @Summary Revoke the calendar token
+// @Description Revoke the calendar token of the user named by X-User-ID; subscriptions using it stop updating
+// @Tags calendar
+// @Param X-User-ID header string true "Acting user"
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
+// @Success 204 "No Content"
+// @Failure 400 {object} utils.ProblemDetails
+// @Failure 404 {object} utils.ProblemDetails
+// @Failure 500 {object} utils.ProblemDetails
+// @Router /calendar/token [delete]
+func (h *CalendarHandler) DeleteToken(c *fiber.Ctx) error {
+	if err := h.calendars.DeleteToken(c.UserContext()); err != nil {
+		return err
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
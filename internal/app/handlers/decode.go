@@ -0,0 +1,343 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// BodyDecoder parses request bodies into request models.
+//
+// In strict mode only JSON bodies are accepted, and unknown fields, duplicate
+// keys, values of the wrong type and data after the top-level value are all
+// rejected with a *BodyError naming the offending JSON path. Otherwise bodies
+// go through Fiber's lenient BodyParser.
+type BodyDecoder struct {
+	Strict bool
+	// MaxBytes caps the body size in strict mode; zero means no limit
+	MaxBytes int
+}
+
+// BodyError reports a request body that could not be decoded
+type BodyError struct {
+	// Path locates the problem, e.g. "$" for the whole body or "$.priority"
+	Path    string
+	Message string
+}
+
+// Error implements the error interface
+func (e *BodyError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// Decode parses the request body of c into v
+func (d BodyDecoder) Decode(c *fiber.Ctx, v interface{}) error {
+	if !d.Strict {
+		if err := c.BodyParser(v); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+		}
+		return nil
+	}
+
+	if mediaType(c.Get(fiber.HeaderContentType)) != fiber.MIMEApplicationJSON {
+		return fiber.NewError(fiber.StatusUnsupportedMediaType, "Content-Type must be application/json")
+	}
+
+	body := c.Body()
+	if d.MaxBytes > 0 && len(body) > d.MaxBytes {
+		return fiber.NewError(fiber.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", d.MaxBytes))
+	}
+
+	return DecodeStrict(body, v)
+}
+
+// DecodeJSON parses a JSON document received outside a request body, such
+// as the payload of a WebSocket message, into v with the same rules as Decode
+func (d BodyDecoder) DecodeJSON(data []byte, v interface{}) error {
+	if !d.Strict {
+		if err := json.Unmarshal(data, v); err != nil {
+			return decodeError(err)
+		}
+		return nil
+	}
+
+	if d.MaxBytes > 0 && len(data) > d.MaxBytes {
+		return &BodyError{Path: "$", Message: fmt.Sprintf("document exceeds %d bytes", d.MaxBytes)}
+	}
+	return DecodeStrict(data, v)
+}
+
+// RawJSON returns the raw request body for handlers that interpret it
+// themselves, such as patch documents. In strict mode the size limit,
+// duplicate key and trailing data checks of Decode still apply.
+func (d BodyDecoder) RawJSON(c *fiber.Ctx) ([]byte, error) {
+	body := c.Body()
+	if !d.Strict {
+		return body, nil
+	}
+
+	if d.MaxBytes > 0 && len(body) > d.MaxBytes {
+		return nil, fiber.NewError(fiber.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", d.MaxBytes))
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, &BodyError{Path: "$", Message: "request body is empty"}
+	}
+	if err := checkStructure(body, nil); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// DecodeStrict unmarshals a single JSON value from data into v, rejecting
+// duplicate keys, unknown fields, mistyped values and trailing data.
+//
+// encoding/json matches object keys to struct fields case-insensitively, so
+// keys are checked against the fields of v: a key naming a field in the
+// wrong case is rejected rather than silently accepted, which also keeps
+// {"title": "a", "TITLE": ""} from passing as two distinct keys.
+func DecodeStrict(data []byte, v interface{}) error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return &BodyError{Path: "$", Message: "request body is empty"}
+	}
+
+	// First pass: syntax, duplicate keys, field name case and trailing data
+	if err := checkStructure(data, reflect.TypeOf(v)); err != nil {
+		return err
+	}
+
+	// Second pass: decode with unknown fields disallowed
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return decodeError(err)
+	}
+	return nil
+}
+
+// decodeError converts encoding/json errors into BodyErrors
+func decodeError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+
+	switch {
+	case errors.As(err, &typeErr):
+		path := "$"
+		if typeErr.Field != "" {
+			path += "." + typeErr.Field
+		}
+		return &BodyError{Path: path, Message: fmt.Sprintf("expected %s, got %s", jsonKind(typeErr.Type.String()), typeErr.Value)}
+	case errors.As(err, &syntaxErr):
+		return &BodyError{Path: "$", Message: fmt.Sprintf("malformed JSON at offset %d", syntaxErr.Offset)}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		return &BodyError{Path: "$." + field, Message: "unknown field"}
+	default:
+		return &BodyError{Path: "$", Message: "malformed JSON"}
+	}
+}
+
+// jsonKind describes a Go type in JSON terms for error messages
+func jsonKind(goType string) string {
+	goType = strings.TrimLeft(goType, "*")
+	switch {
+	case goType == "string":
+		return "string"
+	case goType == "bool":
+		return "boolean"
+	case strings.HasPrefix(goType, "int"), strings.HasPrefix(goType, "uint"), strings.HasPrefix(goType, "float"):
+		return "number"
+	case strings.HasPrefix(goType, "[]"):
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// checkStructure walks the token stream of data, reporting syntax errors,
+// duplicate object keys and anything following the first JSON value. When
+// the type data decodes into is known, keys of objects decoding into structs
+// must also name their field exactly.
+func checkStructure(data []byte, t reflect.Type) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	if err := walkValue(dec, "$", t); err != nil {
+		return err
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return &BodyError{Path: "$", Message: "unexpected data after top-level value"}
+	}
+	return nil
+}
+
+// walkValue consumes one JSON value from dec, located at path and decoding
+// into t, which is nil when unknown
+func walkValue(dec *json.Decoder, path string, t reflect.Type) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return syntaxError(err)
+	}
+
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return nil // scalar
+	}
+
+	t = decodedType(t)
+	switch delim {
+	case '{':
+		var fields map[string]structField
+		var elem reflect.Type
+		if t != nil {
+			switch t.Kind() {
+			case reflect.Struct:
+				fields = jsonFields(t)
+			case reflect.Map:
+				elem = t.Elem()
+			}
+		}
+
+		seen := make(map[string]bool)
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return syntaxError(err)
+			}
+			key := keyTok.(string)
+			keyPath := path + "." + key
+			valueType := elem
+			if fields != nil {
+				// Unknown fields are left to the decoder to report
+				if field, ok := fields[strings.ToLower(key)]; ok {
+					if field.name != key {
+						return &BodyError{Path: keyPath, Message: fmt.Sprintf("unknown field, did you mean %q?", field.name)}
+					}
+					valueType = field.typ
+				}
+			}
+			if seen[key] {
+				return &BodyError{Path: keyPath, Message: "duplicate key"}
+			}
+			seen[key] = true
+			if err := walkValue(dec, keyPath, valueType); err != nil {
+				return err
+			}
+		}
+	case '[':
+		var elem reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elem = t.Elem()
+		}
+		for i := 0; dec.More(); i++ {
+			if err := walkValue(dec, fmt.Sprintf("%s[%d]", path, i), elem); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Closing delimiter
+	if _, err := dec.Token(); err != nil {
+		return syntaxError(err)
+	}
+	return nil
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// decodedType returns the type whose fields a JSON value decoding into t is
+// matched against, dereferencing pointers, or nil when t is unknown or
+// decodes itself
+func decodedType(t reflect.Type) reflect.Type {
+	for t != nil {
+		if t.Implements(unmarshalerType) || reflect.PointerTo(t).Implements(unmarshalerType) {
+			return nil
+		}
+		if t.Kind() != reflect.Pointer {
+			return t
+		}
+		t = t.Elem()
+	}
+	return nil
+}
+
+// structField is a struct field as encoding/json names it
+type structField struct {
+	name string
+	typ  reflect.Type
+}
+
+var fieldCache sync.Map // reflect.Type -> map[string]structField
+
+// jsonFields returns the fields encoding/json decodes into struct type t,
+// keyed by their lower-cased names
+func jsonFields(t reflect.Type) map[string]structField {
+	if cached, ok := fieldCache.Load(t); ok {
+		return cached.(map[string]structField)
+	}
+
+	fields := make(map[string]structField)
+	collectFields(t, fields)
+	fieldCache.Store(t, fields)
+	return fields
+}
+
+// collectFields adds the fields of t to fields; fields of t itself take
+// precedence over those of structs embedded in it
+func collectFields(t reflect.Type, fields map[string]structField) {
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		// Untagged embedded structs contribute their fields
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if _, ok := fields[strings.ToLower(name)]; !ok {
+			fields[strings.ToLower(name)] = structField{name: name, typ: f.Type}
+		}
+	}
+	for _, e := range embedded {
+		collectFields(e, fields)
+	}
+}
+
+func syntaxError(err error) error {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return &BodyError{Path: "$", Message: fmt.Sprintf("malformed JSON at offset %d", syntaxErr.Offset)}
+	}
+	return &BodyError{Path: "$", Message: "malformed JSON"}
+}
+
+// mediaType returns the lower-cased media type of a Content-Type header without parameters
+func mediaType(contentType string) string {
+	mt, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(mt))
+}
@@ -0,0 +1,129 @@
+package handlers_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/teguh/go-todo-api/internal/app/handlers"
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+func TestDecodeStrict(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		wantPath string // empty when the body decodes
+	}{
+		{"valid", `{"title": "a", "tags": ["x"], "priority": 2}`, ""},
+		{"empty", `  `, "$"},
+		{"malformed", `{"title": }`, "$"},
+		{"trailing data", `{"title": "a"} {}`, "$"},
+		{"duplicate key", `{"title": "a", "title": "b"}`, "$.title"},
+		{"duplicate key in another case", `{"title": "a", "TITLE": ""}`, "$.TITLE"},
+		{"field in another case", `{"Title": "a"}`, "$.Title"},
+		{"boolean field in another case", `{"title": "a", "Completed": true}`, "$.Completed"},
+		{"unknown field", `{"title": "a", "colour": "red"}`, "$.colour"},
+		{"mistyped value", `{"title": "a", "priority": "high"}`, "$.priority"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var input models.TodoUpdate
+			err := handlers.DecodeStrict([]byte(tt.body), &input)
+			if tt.wantPath == "" {
+				if err != nil {
+					t.Fatalf("DecodeStrict = %v", err)
+				}
+				if input.Title == nil || *input.Title != "a" || input.Priority == nil || *input.Priority != 2 {
+					t.Errorf("decoded %+v", input)
+				}
+				return
+			}
+			var bodyErr *handlers.BodyError
+			if !errors.As(err, &bodyErr) || bodyErr.Path != tt.wantPath {
+				t.Errorf("DecodeStrict = %v, want an error at %s", err, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestDecodeStrictChecksNestedValues(t *testing.T) {
+	var bodyErr *handlers.BodyError
+
+	var objects []map[string]int
+	if err := handlers.DecodeStrict([]byte(`[{"a": 1}, {"a": 1, "a": 2}]`), &objects); !errors.As(err, &bodyErr) || bodyErr.Path != "$[1].a" {
+		t.Errorf("duplicate key in a nested object: %v", err)
+	}
+
+	// Map keys are data, so only exact repeats are duplicates
+	if err := handlers.DecodeStrict([]byte(`[{"a": 1, "A": 2}]`), &objects); err != nil || objects[0]["A"] != 2 {
+		t.Errorf("map keys differing in case: %v, %v", objects, err)
+	}
+
+	var nested struct {
+		Todos []models.TodoCreate `json:"todos"`
+	}
+	if err := handlers.DecodeStrict([]byte(`{"todos": [{"title": "a", "Priority": 1}]}`), &nested); !errors.As(err, &bodyErr) || bodyErr.Path != "$.todos[0].Priority" {
+		t.Errorf("field in another case inside a list: %v", err)
+	}
+}
+
+func TestDecodeLimitsBodies(t *testing.T) {
+	tests := []struct {
+		name        string
+		decoder     handlers.BodyDecoder
+		contentType string
+		body        string
+		wantStatus  int
+	}{
+		{"strict", handlers.BodyDecoder{Strict: true, MaxBytes: 64}, "application/json", `{"title": "a"}`, fiber.StatusOK},
+		{"strict with charset", handlers.BodyDecoder{Strict: true}, "application/json; charset=utf-8", `{"title": "a"}`, fiber.StatusOK},
+		{"over MaxBytes", handlers.BodyDecoder{Strict: true, MaxBytes: 8}, "application/json", `{"title": "a"}`, fiber.StatusRequestEntityTooLarge},
+		{"not JSON", handlers.BodyDecoder{Strict: true}, "application/x-www-form-urlencoded", `title=a`, fiber.StatusUnsupportedMediaType},
+		{"strict rejects case variants", handlers.BodyDecoder{Strict: true}, "application/json", `{"TITLE": "a"}`, fiber.StatusBadRequest},
+		{"lenient accepts forms", handlers.BodyDecoder{}, "application/x-www-form-urlencoded", `title=a`, fiber.StatusOK},
+		{"lenient ignores MaxBytes", handlers.BodyDecoder{MaxBytes: 8}, "application/json", `{"title": "a", "colour": "red"}`, fiber.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Post("/", func(c *fiber.Ctx) error {
+				var input models.TodoCreate
+				if err := tt.decoder.Decode(c, &input); err != nil {
+					var bodyErr *handlers.BodyError
+					if errors.As(err, &bodyErr) {
+						return c.SendStatus(fiber.StatusBadRequest)
+					}
+					return err
+				}
+				if input.Title != "a" {
+					return c.Status(fiber.StatusTeapot).SendString(input.Title)
+				}
+				return c.SendStatus(fiber.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestDecodeJSONLimitsDocuments(t *testing.T) {
+	decoder := handlers.BodyDecoder{Strict: true, MaxBytes: 8}
+	var input models.TodoCreate
+	var bodyErr *handlers.BodyError
+	if err := decoder.DecodeJSON([]byte(`{"title": "a"}`), &input); !errors.As(err, &bodyErr) || bodyErr.Path != "$" {
+		t.Errorf("DecodeJSON of an oversized document = %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/pkg/utils"
+)
+
+// Problem type URIs identifying each class of error. Clients should switch
+// on these rather than on the human-readable title or detail.
+const (
+	ProblemTypeValidation   = "/problems/validation-error"
+	ProblemTypeInvalidBody  = "/problems/invalid-body"
+	ProblemTypeNotFound     = "/problems/not-found"
+	ProblemTypeUnauthorized = "/problems/unauthorized"
+	ProblemTypeConflict     = "/problems/conflict"
+	ProblemTypeInternal     = "/problems/internal-error"
+)
+
+// ErrorProblem maps an error returned by a handler to a problem details
+// response. Unknown errors become a 500 with a generic detail so internal
+// details are not leaked. Instance and RequestID are left for the caller.
+func ErrorProblem(err error) utils.ProblemDetails {
+	var fiberErr *fiber.Error
+	var bodyErr *BodyError
+	var validationErr *models.ValidationError
+
+	switch {
+	case errors.As(err, &fiberErr):
+		// Plain HTTP errors carry no extra semantics beyond their status
+		return utils.ProblemDetails{
+			Type:   "about:blank",
+			Status: fiberErr.Code,
+			Detail: fiberErr.Message,
+		}
+	case errors.As(err, &bodyErr):
+		return utils.ProblemDetails{
+			Type:   ProblemTypeInvalidBody,
+			Title:  "Invalid request body",
+			Status: fiber.StatusBadRequest,
+			Detail: bodyErr.Error(),
+			Errors: []utils.ProblemError{{Field: bodyErr.Path, Message: bodyErr.Message}},
+		}
+	case errors.As(err, &validationErr):
+		problem := utils.ProblemDetails{
+			Type:   ProblemTypeValidation,
+			Title:  "Validation failed",
+			Status: fiber.StatusBadRequest,
+			Detail: validationErr.Error(),
+		}
+		for _, field := range validationErr.Fields {
+			problem.Errors = append(problem.Errors, utils.ProblemError{
+				Field:   field.Field,
+				Message: field.Message,
+			})
+		}
+		return problem
+	case errors.Is(err, models.ErrNotFound):
+		return utils.ProblemDetails{
+			Type:   ProblemTypeNotFound,
+			Title:  "Resource not found",
+			Status: fiber.StatusNotFound,
+			Detail: err.Error(),
+		}
+	case errors.Is(err, models.ErrUnauthorized):
+		return utils.ProblemDetails{
+			Type:   ProblemTypeUnauthorized,
+			Title:  "Unauthorized",
+			Status: fiber.StatusUnauthorized,
+			Detail: err.Error(),
+		}
+	case errors.Is(err, models.ErrConflict):
+		return utils.ProblemDetails{
+			Type:   ProblemTypeConflict,
+			Title:  "Conflict",
+			Status: fiber.StatusConflict,
+			Detail: err.Error(),
+		}
+	default:
+		return utils.ProblemDetails{
+			Type:   ProblemTypeInternal,
+			Title:  "Internal Server Error",
+			Status: fiber.StatusInternalServerError,
+			Detail: "Internal Server Error",
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/teguh/go-todo-api/internal/app/events"
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+// replayBatchSize bounds how many logged events are read per query while a
+// resuming client catches up
+const replayBatchSize = 500
+
+// EventHandler streams todo change events to clients as Server-Sent Events
+type EventHandler struct {
+	broker    *events.Broker
+	heartbeat time.Duration
+	buffer    int
+}
+
+// NewEventHandler creates a new EventHandler. A comment line is written
+// every heartbeat (15s if zero) to keep idle connections open through proxies.
+func NewEventHandler(broker *events.Broker, heartbeat time.Duration) *EventHandler {
+	if heartbeat <= 0 {
+		heartbeat = 15 * time.Second
+	}
+	return &EventHandler{
+		broker:    broker,
+		heartbeat: heartbeat,
+		buffer:    64,
+	}
+}
+
+// RegisterRoutes registers the routes for todo events. It must be called
+// before TodoHandler.RegisterRoutes so /todos/events is not taken for an ID.
+func (h *EventHandler) RegisterRoutes(router fiber.Router) {
+	router.Get("/todos/events", h.StreamEvents)
+}
+
+// StreamEvents handles the Server-Sent Events stream of todo changes
+// @Summary Stream todo changes
+// @Description Server-Sent Events stream emitting created, updated and deleted events with the todo as data.
+// @Description Reconnecting clients send Last-Event-ID (or last_event_id) to replay missed events from the event log.
+// @Tags events
+// @Produce text/event-stream
+// @Param Last-Event-ID header string false "ID of the last event received"
+// @Param last_event_id query int false "Alternative to the Last-Event-ID header"
+// @Param user query string false "Only stream changes made by this user (X-User-ID)"
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
+// @Success 200 {object} models.TodoEvent "One event per message"
+// @Failure 400 {object} utils.ProblemDetails
+// @Router /todos/events [get]
+func (h *EventHandler) StreamEvents(c *fiber.Ctx) error {
+	lastID, err := lastEventID(c)
+	if err != nil {
+		return err
+	}
+
+	var filter events.Filter
+	if user := c.Query("user"); user != "" {
+		filter = func(event *models.TodoEvent) bool { return event.Actor == user }
+	}
+
+	var cursor int64
+	if lastID >= 0 {
+		if cursor, err = h.broker.Cursor(lastID); err != nil {
+			return err
+		}
+	}
+
+	// Subscribe before replaying so nothing published in between is missed
+	sub := h.broker.Subscribe(h.buffer, filter)
+	shutdown := c.Context().Done()
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer sub.Close()
+
+		fmt.Fprintf(w, "retry: %d\n\n", 3000)
+		if w.Flush() != nil {
+			return
+		}
+
+		// Replay what the client missed, then skip live duplicates by their
+		// position in the log, as events are not logged in ID order
+		lastPosition := sub.After
+		if lastID >= 0 {
+			var writeErr error
+			lastPosition, err = h.broker.Replay(cursor, replayBatchSize, filter, func(event *models.TodoEvent) error {
+				writeErr = writeEvent(w, event)
+				return writeErr
+			})
+			if err != nil {
+				if writeErr == nil {
+					log.Printf("Failed to replay events: %v", err)
+				}
+				return
+			}
+			if w.Flush() != nil {
+				return
+			}
+		}
+
+		ticker := time.NewTicker(h.heartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case event, ok := <-sub.C:
+				if !ok {
+					// Dropped as a slow consumer or shutting down; the client
+					// reconnects with Last-Event-ID and resumes from the log
+					return
+				}
+				if event.Position <= lastPosition {
+					continue
+				}
+				lastPosition = event.Position
+				if writeEvent(w, event) != nil || w.Flush() != nil {
+					return
+				}
+			case <-ticker.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+				if w.Flush() != nil {
+					return
+				}
+			case <-shutdown:
+				return
+			}
+		}
+	})
+
+	return nil
+}
+
+// lastEventID returns the resumption cursor sent by the client, or -1 for a fresh stream
+func lastEventID(c *fiber.Ctx) (int64, error) {
+	raw := c.Get("Last-Event-ID")
+	if raw == "" {
+		raw = c.Query("last_event_id")
+	}
+	if raw == "" {
+		return -1, nil
+	}
+
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id < 0 {
+		return 0, fiber.NewError(fiber.StatusBadRequest, "Last-Event-ID must be a non-negative integer")
+	}
+	return id, nil
+}
+
+// writeEvent writes one event in text/event-stream framing
+func writeEvent(w *bufio.Writer, event *models.TodoEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
@@ -0,0 +1,225 @@
+package handlers_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/identity"
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+// message is one message of an event stream; comments have only Comment set
+type message struct {
+	ID      string
+	Event   string
+	Data    string
+	Comment string
+}
+
+// stream is a client of the event stream
+type stream struct {
+	t        *testing.T
+	messages chan message
+}
+
+// stream connects to the event stream with query and headers, and waits
+// for the retry interval the server opens with, by which time the
+// connection is subscribed
+func (r *realtime) stream(t *testing.T, query string, header http.Header) *stream {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, "http://"+r.addr+"/api/v1/todos/events"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status %d, content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	s := &stream{t: t, messages: make(chan message, 64)}
+	go s.read(bufio.NewScanner(resp.Body))
+	if opening := s.next(); opening != (message{}) {
+		t.Fatalf("stream opened with %+v, want the retry interval", opening)
+	}
+	return s
+}
+
+// read parses messages until the stream ends. The retry field is dropped,
+// leaving the opening message empty.
+func (s *stream) read(lines *bufio.Scanner) {
+	defer close(s.messages)
+	var msg message
+	for lines.Scan() {
+		line := lines.Text()
+		if line == "" {
+			s.messages <- msg
+			msg = message{}
+			continue
+		}
+		field, value, _ := strings.Cut(line, ": ")
+		switch field {
+		case "":
+			msg.Comment = value
+		case "id":
+			msg.ID = value
+		case "event":
+			msg.Event = value
+		case "data":
+			msg.Data = value
+		}
+	}
+}
+
+// next returns the next message
+func (s *stream) next() message {
+	s.t.Helper()
+	select {
+	case msg, ok := <-s.messages:
+		if !ok {
+			s.t.Fatal("stream ended")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		s.t.Fatal("no message within 5s")
+	}
+	return message{}
+}
+
+// nextEvent returns the next change event, skipping heartbeats
+func (s *stream) nextEvent() *models.TodoEvent {
+	s.t.Helper()
+	for {
+		msg := s.next()
+		if msg.Comment != "" {
+			continue
+		}
+		var event models.TodoEvent
+		if err := json.Unmarshal([]byte(msg.Data), &event); err != nil {
+			s.t.Fatalf("data of %+v: %v", msg, err)
+		}
+		if msg.ID != strconv.FormatInt(event.ID, 10) || msg.Event != event.Type {
+			s.t.Fatalf("message %+v frames event %d of type %s", msg, event.ID, event.Type)
+		}
+		return &event
+	}
+}
+
+// createTodos creates a todo for each title as user and relays the changes
+func (r *realtime) createTodos(t *testing.T, user string, titles ...string) []*models.Todo {
+	t.Helper()
+	ctx := identity.WithUser(context.Background(), user)
+	var todos []*models.Todo
+	for _, title := range titles {
+		todo, err := r.todos.CreateTodo(ctx, models.TodoCreate{Title: title})
+		if err != nil {
+			t.Fatal(err)
+		}
+		todos = append(todos, todo)
+	}
+	r.relayChanges(t)
+	return todos
+}
+
+func TestEventStreamResumesFromLastEventID(t *testing.T) {
+	r := newRealtime(t, time.Hour)
+	live := r.stream(t, "", nil)
+	todos := r.createTodos(t, "alice", "one", "two", "three")
+	var ids []int64
+	for _, todo := range todos {
+		event := live.nextEvent()
+		if event.TodoID != todo.ID {
+			t.Fatalf("live event for %s, want %s", event.TodoID, todo.ID)
+		}
+		ids = append(ids, event.ID)
+	}
+
+	// A fresh stream only sees what happens after it connects
+	fresh := r.stream(t, "", nil)
+
+	// Resuming after the first event replays the other two, by header or
+	// query, then carries on live without repeating them
+	resumed := []*stream{
+		r.stream(t, "", http.Header{"Last-Event-ID": {strconv.FormatInt(ids[0], 10)}}),
+		r.stream(t, "?last_event_id="+strconv.FormatInt(ids[0], 10), nil),
+	}
+	for i, s := range resumed {
+		for _, want := range ids[1:] {
+			if event := s.nextEvent(); event.ID != want {
+				t.Fatalf("stream %d replayed event %d, want %d", i, event.ID, want)
+			}
+		}
+	}
+
+	four := r.createTodos(t, "alice", "four")[0]
+	for i, s := range append(resumed, fresh) {
+		if event := s.nextEvent(); event.TodoID != four.ID || event.Type != models.EventCreated {
+			t.Errorf("stream %d got %+v, want the new todo", i, event)
+		}
+	}
+}
+
+func TestEventStreamRejectsBadLastEventID(t *testing.T) {
+	r := newRealtime(t, time.Hour)
+	for _, id := range []string{"abc", "-1"} {
+		req, _ := http.NewRequest(http.MethodGet, "http://"+r.addr+"/api/v1/todos/events", nil)
+		req.Header.Set("Last-Event-ID", id)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Last-Event-ID %q: status %d, want 400", id, resp.StatusCode)
+		}
+	}
+}
+
+func TestEventStreamFiltersByUser(t *testing.T) {
+	r := newRealtime(t, time.Hour)
+	first := r.createTodos(t, "alice", "alice's first")[0]
+
+	alice := r.stream(t, "?user=alice", nil)
+	r.createTodos(t, "bob", "bob's")
+	mine := r.createTodos(t, "alice", "alice's second")[0]
+	if event := alice.nextEvent(); event.TodoID != mine.ID || event.Actor != "alice" {
+		t.Errorf("got %+v, want alice's second todo", event)
+	}
+
+	// Replays are filtered too
+	resumed := r.stream(t, "?user=alice&last_event_id=0", nil)
+	for _, want := range []string{first.ID, mine.ID} {
+		if event := resumed.nextEvent(); event.TodoID != want {
+			t.Errorf("replayed %+v, want %s", event, want)
+		}
+	}
+}
+
+func TestEventStreamSendsHeartbeats(t *testing.T) {
+	r := newRealtime(t, 20*time.Millisecond)
+	s := r.stream(t, "", nil)
+	for i := 0; i < 2; i++ {
+		if msg := s.next(); msg != (message{Comment: "heartbeat"}) {
+			t.Fatalf("got %+v, want a heartbeat", msg)
+		}
+	}
+
+	// Events still arrive between heartbeats
+	todo := r.createTodos(t, "alice", "between beats")[0]
+	if event := s.nextEvent(); event.TodoID != todo.ID {
+		t.Errorf("got %+v", event)
+	}
+}
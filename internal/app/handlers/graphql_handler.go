@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/teguh/go-todo-api/internal/app/gql"
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+// GraphQLHandler serves the GraphQL endpoint and, in development, the
+// GraphiQL playground
+type GraphQLHandler struct {
+	server     *gql.Server
+	decoder    BodyDecoder
+	playground bool
+}
+
+// NewGraphQLHandler creates a new GraphQLHandler executing requests on
+// server. GET requests from browsers are answered with GraphiQL if
+// playground is set.
+func NewGraphQLHandler(server *gql.Server, decoder BodyDecoder, playground bool) *GraphQLHandler {
+	return &GraphQLHandler{
+		server:     server,
+		decoder:    decoder,
+		playground: playground,
+	}
+}
+
+// RegisterRoutes registers the routes for GraphQL
+func (h *GraphQLHandler) RegisterRoutes(router fiber.Router) {
+	router.Post("/graphql", h.Execute)
+	router.Get("/graphql", h.ExecuteQuery)
+}
+
+// Execute handles a GraphQL request sent as a JSON body
+// @Summary Execute a GraphQL operation
+// @Description Runs a query or mutation against the todo schema. Operations that nest deeper or are
+// @Description estimated to resolve more fields than allowed are rejected with a QUERY_TOO_COMPLEX error.
+// @Description Errors are reported in the errors array of a 200 response, with a code in their extensions.
+// @Tags graphql
+// @Accept json
+// @Produce json
+// @Param request body gql.Request true "GraphQL request"
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
+// @Success 200 {object} object "GraphQL response with data and errors"
+// @Failure 400 {object} utils.ProblemDetails
+// @Failure 413 {object} utils.ProblemDetails
+// @Failure 415 {object} utils.ProblemDetails
+// @Router /graphql [post]
+func (h *GraphQLHandler) Execute(c *fiber.Ctx) error {
+	var req gql.Request
+	if err := h.decoder.Decode(c, &req); err != nil {
+		return err
+	}
+
+	return c.JSON(h.server.Execute(c.UserContext(), req, false))
+}
+
+// ExecuteQuery handles a GraphQL query sent as URL parameters
+// @Summary Execute a GraphQL query
+// @Description Runs a query passed in the URL; mutations must be sent with POST.
+// @Description In development, browsers requesting HTML without a query get the GraphiQL playground.
+// @Tags graphql
+// @Produce json
+// @Produce html
+// @Param query query string false "GraphQL query"
+// @Param operationName query string false "Operation to run when the query has several"
+// @Param variables query string false "Variables as a JSON object"
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
+// @Success 200 {object} object "GraphQL response with data and errors"
+// @Failure 400 {object} utils.ProblemDetails
+// @Router /graphql [get]
+func (h *GraphQLHandler) ExecuteQuery(c *fiber.Ctx) error {
+	if h.playground && c.Query("query") == "" && strings.Contains(c.Get(fiber.HeaderAccept), fiber.MIMETextHTML) {
+		c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+		return c.SendString(graphiQLPage)
+	}
+
+	req := gql.Request{
+		Query:         c.Query("query"),
+		OperationName: c.Query("operationName"),
+	}
+	if variables := c.Query("variables"); variables != "" {
+		if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+			return models.NewValidationError("variables", "variables must be a JSON object")
+		}
+	}
+
+	return c.JSON(h.server.Execute(c.UserContext(), req, true))
+}
+
+// graphiQLPage loads GraphiQL from a CDN and points it at the page's own URL
+const graphiQLPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>GraphiQL</title>
+  <link rel="stylesheet" href="https://unpkg.com/graphiql@3/graphiql.min.css">
+  <style>body { margin: 0; height: 100vh; } #graphiql { height: 100vh; }</style>
+</head>
+<body>
+  <div id="graphiql">Loading...</div>
+  <script crossorigin src="https://unpkg.com/react@18/umd/react.production.min.js"></script>
+  <script crossorigin src="https://unpkg.com/react-dom@18/umd/react-dom.production.min.js"></script>
+  <script crossorigin src="https://unpkg.com/graphiql@3/graphiql.min.js"></script>
+  <script>
+    const fetcher = GraphiQL.createFetcher({ url: window.location.pathname });
+    ReactDOM.createRoot(document.getElementById('graphiql')).render(React.createElement(GraphiQL, { fetcher }));
+  </script>
+</body>
+</html>
+`
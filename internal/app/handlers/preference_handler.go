@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/teguh/go-todo-api/internal/app/digest"
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/services"
+)
+
+// Formats a digest can be previewed in
+const (
+	digestFormatJSON = "json"
+	digestFormatHTML = "html"
+	digestFormatText = "text"
+)
+
+// PreferenceHandler handles HTTP requests for the preferences of users and
+// previews of their digests
+type PreferenceHandler struct {
+	preferences *services.PreferenceService
+	digests     *services.DigestService
+	decoder     BodyDecoder
+}
+
+// NewPreferenceHandler creates a new PreferenceHandler that delegates to
+// the preference and digest services and parses request bodies with decoder
+func NewPreferenceHandler(preferences *services.PreferenceService, digests *services.DigestService, decoder BodyDecoder) *PreferenceHandler {
+	return &PreferenceHandler{
+		preferences: preferences,
+		digests:     digests,
+		decoder:     decoder,
+	}
+}
+
+// RegisterRoutes registers the preference and digest routes
+func (h *PreferenceHandler) RegisterRoutes(router fiber.Router) {
+	router.Get("/preferences", h.GetPreferences)
+	router.Put("/preferences", h.ReplacePreferences)
+	router.Delete("/preferences", h.DeletePreferences)
+	router.Get("/digest/preview", h.PreviewDigest)
+}
+
+// GetPreferences handles retrieving the caller's preferences
+// @Summary Get preferences
+// @Description Get the time zone and digest schedule of the user named by X-User-ID, or the defaults if they have set none
+// @Tags preferences
+// @Produce json
+// @Param X-User-ID header string true "Acting user"
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
+// @Success 200 {object} models.Preferences
+// @Failure 400 {object} utils.ProblemDetails
+// @Failure 500 {object} utils.ProblemDetails
+// @Router /preferences [get]
+func (h *PreferenceHandler) GetPreferences(c *fiber.Ctx) error {
+	preferences, err := h.preferences.GetPreferences(c.UserContext())
+	if err != nil {
+		return err
+	}
+	return c.JSON(preferences)
+}
+
+// ReplacePreferences handles replacing the caller's preferences
+// @Summary Replace preferences
+// @Description Replace the preferences of the user named by X-User-ID. Omitted fields are reset to their defaults:
+// @Description timezone UTC, digest_frequency off, digest_hour 7 and digest_weekday monday. Daily and weekly digests
+// @Description of the open todos that are overdue, due today and due this week are mailed to email at digest_hour
+// @Description in timezone, when the server has SMTP configured. Digests with nothing due are not sent.
+// @Tags preferences
+// @Accept json
+// @Produce json
+// @Param X-User-ID header string true "Acting user"
+// @Param preferences body models.PreferencesReplace true "Preferences"
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
+// @Success 200 {object} models.Preferences
+// @Failure 400 {object} utils.ProblemDetails
+// @Failure 413 {object} utils.ProblemDetails
+// @Failure 415 {object} utils.ProblemDetails
+// @Failure 500 {object} utils.ProblemDetails
+// @Router /preferences [put]
+func (h *PreferenceHandler) ReplacePreferences(c *fiber.Ctx) error {
+	var input models.PreferencesReplace
+	if err := h.decoder.Decode(c, &input); err != nil {
+		return err
+	}
+
+	preferences, err := h.preferences.ReplacePreferences(c.UserContext(), input)
+	if err != nil {
+		return err
+	}
+	return c.JSON(preferences)
+}
+
+// DeletePreferences handles resetting the caller's preferences
+// @Summary Reset preferences
+// @Description Reset the preferences of the user named by X-User-ID to the defaults, which stops their digest
+// @Tags preferences
+// @Param X-User-ID header string true "Acting user"
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
+// @Success 204 "No Content"
+// @Failure 400 {object} utils.ProblemDetails
+// @Failure 500 {object} utils.ProblemDetails
+// @Router /preferences [delete]
+func (h *PreferenceHandler) DeletePreferences(c *fiber.Ctx) error {
+	if err := h.preferences.DeletePreferences(c.UserContext()); err != nil {
+		return err
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// PreviewDigest handles rendering the caller's digest without sending it
+// @Summary Preview the digest
+// @Description Render the digest the user named by X-User-ID would be mailed now, without sending it: their open todos
+// @Description that are overdue, due today and due in the six days after, each grouped by project. Days are counted in
+// @Description the user's time zone unless timezone is given. format=html and format=text return the email's bodies.
+// @Tags preferences
+// @Produce json,html,plain
+// @Param X-User-ID header string true "Acting user"
+// @Param format query string false "Format" Enums(json, html, text) default(json)
+// @Param timezone query string false "IANA time zone, instead of the user's"
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
+// @Success 200 {object} models.Digest
+// @Failure 400 {object} utils.ProblemDetails
+// @Failure 500 {object} utils.ProblemDetails
+// @Router /digest/preview [get]
+func (h *PreferenceHandler) PreviewDigest(c *fiber.Ctx) error {
+	format := c.Query("format", digestFormatJSON)
+	if format != digestFormatJSON && format != digestFormatHTML && format != digestFormatText {
+		return models.NewValidationError("format", fmt.Sprintf("format must be %s, %s or %s", digestFormatJSON, digestFormatHTML, digestFormatText))
+	}
+
+	result, err := h.digests.Preview(c.UserContext(), c.Query("timezone"))
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case digestFormatHTML:
+		body, err := digest.HTML(result)
+		if err != nil {
+			return err
+		}
+		c.Type("html", "utf-8")
+		return c.SendString(body)
+	case digestFormatText:
+		body, err := digest.Text(result)
+		if err != nil {
+			return err
+		}
+		c.Type("txt", "utf-8")
+		return c.SendString(body)
+	}
+	return c.JSON(result)
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/services"
+)
+
+// ReminderHandler handles HTTP requests for the reminders of todos
+type ReminderHandler struct {
+	service *services.ReminderService
+	decoder BodyDecoder
+}
+
+// NewReminderHandler creates a new ReminderHandler that delegates to
+// service and parses request bodies with decoder
+func NewReminderHandler(service *services.ReminderService, decoder BodyDecoder) *ReminderHandler {
+	return &ReminderHandler{
+		service: service,
+		decoder: decoder,
+	}
+}
+
+// RegisterRoutes registers the routes for reminders, under their todo
+func (h *ReminderHandler) RegisterRoutes(router fiber.Router) {
+	reminders := router.Group("/todos/:id/reminders")
+
+	reminders.Post("/", h.CreateReminder)
+	reminders.Get("/", h.GetReminders)
+	reminders.Get("/:reminderId", h.GetReminderByID)
+	reminders.Delete("/:reminderId", h.DeleteReminder)
+}
+
+// CreateReminder handles adding a reminder to a todo
+// @Summary Create a reminder
+// @Description Remind someone of a todo, either at a set time or a duration before its due date, such as 30m or 24h.
+// @Description Reminders before the due date move with it, and are sent again when it moves after they were sent.
+// @Description The log channel writes to the server log, webhook POSTs the reminder and its todo as JSON to the recipient URL,
+// @Description and email mails it to the recipient address when the server has SMTP configured.
+// @Description Reminders of todos completed by the time they are due are skipped. The due date of an all-day todo
+// @Description starts at midnight in the time zone of the X-User-ID user's preferences, or UTC without one.
+// @Tags reminders
+// @Accept json
+// @Produce json
+// @Param id path string true "Todo ID"
+// @Param reminder body models.ReminderCreate true "Reminder to create"
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
+// @Success 201 {object} models.Reminder
+// @Failure 400 {object} utils.ProblemDetails
+// @Failure 404 {object} utils.ProblemDetails
+// @Failure 413 {object} utils.ProblemDetails
+// @Failure 415 {object} utils.ProblemDetails
+// @Failure 500 {object} utils.ProblemDetails
+// @Router /todos/{id}/reminders [post]
+func (h *ReminderHandler) CreateReminder(c *fiber.Ctx) error {
+	var input models.ReminderCreate
+	if err := h.decoder.Decode(c, &input); err != nil {
+		return err
+	}
+
+	reminder, err := h.service.CreateReminder(c.UserContext(), c.Params("id"), input)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(reminder)
+}
+
+// GetReminders handles retrieving the reminders of a todo
+// @Summary Get the reminders of a todo
+// @Description Get every reminder of a todo, oldest first, whether sent or not
+// @Tags reminders
+// @Produce json
+// @Param id path string true "Todo ID"
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
+// @Success 200 {array} models.Reminder
+// @Failure 404 {object} utils.ProblemDetails
+// @Failure 500 {object} utils.ProblemDetails
+// @Router /todos/{id}/reminders [get]
+func (h *ReminderHandler) GetReminders(c *fiber.Ctx) error {
+	reminders, err := h.service.ListReminders(c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(reminders)
+}
+
+// GetReminderByID handles retrieving a reminder by ID
+// @Summary Get a reminder by ID
+// @Description Get a reminder of a todo, with its status and the outcome of its last attempt
+// @Tags reminders
+// @Produce json
+// @Param id path string true "Todo ID"
+// @Param reminderId path string true "Reminder ID"
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
+// @Success 200 {object} models.Reminder
+// @Failure 404 {object} utils.ProblemDetails
+// @Failure 500 {object} utils.ProblemDetails
+// @Router /todos/{id}/reminders/{reminderId} [get]
+func (h *ReminderHandler) GetReminderByID(c *fiber.Ctx) error {
+	reminder, err := h.service.GetReminder(c.Params("id"), c.Params("reminderId"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(reminder)
+}
+
+// DeleteReminder handles deleting a reminder
+// @Summary Delete a reminder
+// @Description Delete a reminder of a todo by its ID
+// @Tags reminders
+// @Param id path string true "Todo ID"
+// @Param reminderId path string true "Reminder ID"
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
+// @Success 204 "No Content"
+// @Failure 404 {object} utils.ProblemDetails
+// @Failure 500 {object} utils.ProblemDetails
+// @Router /todos/{id}/reminders/{reminderId} [delete]
+func (h *ReminderHandler) DeleteReminder(c *fiber.Ctx) error {
+	if err := h.service.DeleteReminder(c.Params("id"), c.Params("reminderId")); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,483 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	fiberutils "github.com/gofiber/fiber/v2/utils"
+	"github.com/teguh/go-todo-api/internal/app/events"
+	"github.com/teguh/go-todo-api/internal/app/identity"
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/services"
+	"github.com/teguh/go-todo-api/pkg/utils"
+)
+
+// Message types sent by WebSocket clients
+const (
+	SocketSubscribe   = "subscribe"
+	SocketUnsubscribe = "unsubscribe"
+	SocketCreate      = "create"
+	SocketUpdate      = "update"
+	SocketReplace     = "replace"
+	SocketDelete      = "delete"
+)
+
+// Message types sent to WebSocket clients
+const (
+	SocketAck      = "ack"
+	SocketError    = "error"
+	SocketEvent    = "event"
+	SocketPresence = "presence"
+)
+
+const (
+	// socketBuffer bounds both the queued replies and the queued events of a connection
+	socketBuffer = 64
+	// socketEnvelopeBytes is allowed on top of the body size limit for the message envelope
+	socketEnvelopeBytes = 4 * 1024
+	writeWait           = 10 * time.Second
+	pongWait            = 60 * time.Second
+	pingPeriod          = pongWait / 2
+)
+
+// socketUserKey is the c.Locals key carrying the user across the upgrade
+const socketUserKey = "socketUser"
+
+// SocketRequest is a message sent by a WebSocket client
+type SocketRequest struct {
+	// ID is chosen by the client and echoed in the reply
+	ID   string `json:"id,omitempty"`
+	Type string `json:"type"`
+	// Project or TodoID selects what to (un)subscribe; TodoID also names the
+	// todo to update, replace or delete
+	Project string `json:"project,omitempty"`
+	TodoID  string `json:"todo_id,omitempty"`
+	// Data is the request body of create, update and replace
+	Data json.RawMessage `json:"data,omitempty" swaggertype:"object"`
+}
+
+// SocketMessage is a message sent to a WebSocket client: a reply to a
+// request (ack or error), a change event, or a presence update
+type SocketMessage struct {
+	Type     string                `json:"type"`
+	ID       string                `json:"id,omitempty"`
+	Todo     *models.Todo          `json:"todo,omitempty"`
+	Event    *models.TodoEvent     `json:"event,omitempty"`
+	Presence *events.Viewers       `json:"presence,omitempty"`
+	Error    *utils.ProblemDetails `json:"error,omitempty"`
+}
+
+// SocketHandler serves the bidirectional WebSocket channel for todos
+type SocketHandler struct {
+	service  *services.TodoService
+	broker   *events.Broker
+	presence *events.Presence
+	decoder  BodyDecoder
+}
+
+// NewSocketHandler creates a new SocketHandler. Mutations go through service,
+// change events come from broker and message payloads are parsed with decoder.
+func NewSocketHandler(service *services.TodoService, broker *events.Broker, presence *events.Presence, decoder BodyDecoder) *SocketHandler {
+	return &SocketHandler{
+		service:  service,
+		broker:   broker,
+		presence: presence,
+		decoder:  decoder,
+	}
+}
+
+// RegisterRoutes registers the WebSocket route. Like EventHandler, it must be
+// called before TodoHandler.RegisterRoutes so /todos/ws is not taken for an ID.
+func (h *SocketHandler) RegisterRoutes(router fiber.Router) {
+	router.Get("/todos/ws", h.Upgrade, websocket.New(h.serve))
+}
+
+// Upgrade handles the WebSocket handshake
+// @Summary Real-time todo channel
+// @Description WebSocket endpoint. Clients send SocketRequest messages to subscribe to projects or todos and to create, update, replace or delete todos;
+// @Description the server replies with ack or error messages and pushes event and presence messages for subscriptions. All messages are JSON text frames.
+// @Description Browsers, which cannot set X-User-ID on a WebSocket, may pass the user as a query parameter instead.
+// @Tags events
+// @Param user query string false "Acting user when X-User-ID cannot be sent"
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
+// @Success 101 {object} SocketMessage "Switching Protocols"
+// @Failure 426 {object} utils.ProblemDetails
+// @Router /todos/ws [get]
+func (h *SocketHandler) Upgrade(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return fiber.ErrUpgradeRequired
+	}
+
+	user := identity.User(c.UserContext())
+	if user == "" {
+		user = c.Query("user")
+	}
+	c.Locals(socketUserKey, user)
+	return c.Next()
+}
+
+// serve runs one WebSocket connection until either side closes it
+func (h *SocketHandler) serve(conn *websocket.Conn) {
+	user, _ := conn.Locals(socketUserKey).(string)
+	ctx := context.Background()
+	if user != "" {
+		ctx = identity.WithUser(ctx, user)
+	}
+
+	s := &socketSession{
+		handler:       h,
+		conn:          conn,
+		ctx:           ctx,
+		user:          user,
+		replies:       make(chan SocketMessage, socketBuffer),
+		readerDone:    make(chan struct{}),
+		writerDone:    make(chan struct{}),
+		projects:      make(map[string]func()),
+		todos:         make(map[string]bool),
+		presenceDirty: make(map[string]bool),
+		presenceReady: make(chan struct{}, 1),
+	}
+
+	go s.writeLoop()
+	s.readLoop()
+	close(s.readerDone)
+	<-s.writerDone
+	s.leaveAll()
+}
+
+// socketSession is the state of one WebSocket connection. The read loop
+// handles requests and owns the subscriptions; the write loop owns the
+// connection's writes and the broker subscription.
+type socketSession struct {
+	handler *SocketHandler
+	conn    *websocket.Conn
+	ctx     context.Context
+	user    string
+
+	// replies queues answers to requests; when it is full the read loop
+	// stops reading, pushing back on clients that do not read their replies
+	replies    chan SocketMessage
+	readerDone chan struct{}
+	writerDone chan struct{}
+
+	mu       sync.Mutex
+	projects map[string]func() // subscribed projects and how to leave their presence
+	todos    map[string]bool
+	// presenceDirty holds projects whose viewers changed since the last
+	// presence message; updates are coalesced so they never queue up
+	presenceDirty map[string]bool
+	presenceReady chan struct{}
+}
+
+// readLoop reads and handles requests until the connection fails or closes
+func (s *socketSession) readLoop() {
+	if s.handler.decoder.MaxBytes > 0 {
+		s.conn.SetReadLimit(int64(s.handler.decoder.MaxBytes + socketEnvelopeBytes))
+	}
+	s.conn.SetReadDeadline(time.Now().Add(pongWait))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := s.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("WebSocket read failed: %v", err)
+			}
+			return
+		}
+		s.conn.SetReadDeadline(time.Now().Add(pongWait))
+
+		reply := s.handle(data)
+		select {
+		case s.replies <- reply:
+		case <-s.writerDone:
+			return
+		}
+	}
+}
+
+// writeLoop writes replies, matching events, presence updates and pings
+// until the read loop ends, a write fails or the broker shuts down
+func (s *socketSession) writeLoop() {
+	defer close(s.writerDone)
+	// Unblocks the read loop if the write side gives up first
+	defer s.conn.Close()
+
+	broker := s.handler.broker
+	sub := broker.Subscribe(socketBuffer, nil)
+	defer func() { sub.Close() }()
+
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	lastPosition := sub.After
+	for {
+		select {
+		case reply := <-s.replies:
+			if s.write(reply) != nil {
+				return
+			}
+		case event, ok := <-sub.C:
+			if !ok {
+				if !sub.Dropped() {
+					s.conn.WriteControl(websocket.CloseMessage,
+						websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+						time.Now().Add(writeWait))
+					return
+				}
+
+				// Fell behind: resubscribe, then catch up from the event log.
+				// Every buffered event was read before the channel reported
+				// closed, so lastPosition is where the live feed stopped.
+				sub = broker.Subscribe(socketBuffer, nil)
+				if lastPosition < 0 {
+					// The log could not be read when the feed began
+					lastPosition = sub.After
+					continue
+				}
+				var writeErr error
+				var err error
+				lastPosition, err = broker.Replay(lastPosition, replayBatchSize, s.matches, func(event *models.TodoEvent) error {
+					writeErr = s.write(SocketMessage{Type: SocketEvent, Event: event})
+					return writeErr
+				})
+				if err != nil {
+					if writeErr == nil {
+						log.Printf("Failed to replay events: %v", err)
+					}
+					return
+				}
+				continue
+			}
+			if event.Position <= lastPosition {
+				continue
+			}
+			lastPosition = event.Position
+			if !s.matches(event) {
+				continue
+			}
+			if s.write(SocketMessage{Type: SocketEvent, Event: event}) != nil {
+				return
+			}
+		case <-s.presenceReady:
+			for _, project := range s.takePresence() {
+				viewers := s.handler.presence.Viewers(project)
+				if s.write(SocketMessage{Type: SocketPresence, Presence: &viewers}) != nil {
+					return
+				}
+			}
+		case <-ticker.C:
+			if s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)) != nil {
+				return
+			}
+		case <-s.readerDone:
+			return
+		}
+	}
+}
+
+// write sends msg, giving up on clients that do not read within writeWait
+func (s *socketSession) write(msg SocketMessage) error {
+	s.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return s.conn.WriteJSON(msg)
+}
+
+// handle executes one request and returns the reply
+func (s *socketSession) handle(data []byte) SocketMessage {
+	var req SocketRequest
+	if err := s.handler.decoder.DecodeJSON(data, &req); err != nil {
+		return errorMessage("", err)
+	}
+
+	todo, err := s.dispatch(&req)
+	if err != nil {
+		return errorMessage(req.ID, err)
+	}
+	return SocketMessage{Type: SocketAck, ID: req.ID, Todo: todo}
+}
+
+// dispatch executes req, returning the affected todo for mutations
+func (s *socketSession) dispatch(req *SocketRequest) (*models.Todo, error) {
+	service := s.handler.service
+
+	switch req.Type {
+	case SocketSubscribe, SocketUnsubscribe:
+		if (req.Project == "") == (req.TodoID == "") {
+			return nil, models.NewValidationError("project", "exactly one of project and todo_id is required")
+		}
+		if req.Type == SocketSubscribe {
+			s.subscribe(req.Project, req.TodoID)
+		} else {
+			s.unsubscribe(req.Project, req.TodoID)
+		}
+		return nil, nil
+	case SocketCreate:
+		var input models.TodoCreate
+		if err := s.decodeData(req, &input); err != nil {
+			return nil, err
+		}
+		return service.CreateTodo(s.ctx, input)
+	case SocketUpdate:
+		var input models.TodoUpdate
+		if err := s.decodeData(req, &input); err != nil {
+			return nil, err
+		}
+		return service.UpdateTodo(s.ctx, req.TodoID, input)
+	case SocketReplace:
+		var input models.TodoReplace
+		if err := s.decodeData(req, &input); err != nil {
+			return nil, err
+		}
+		todo, _, err := service.ReplaceTodo(s.ctx, req.TodoID, input)
+		return todo, err
+	case SocketDelete:
+		if req.TodoID == "" {
+			return nil, models.NewValidationError("todo_id", "todo_id is required")
+		}
+		return nil, service.DeleteTodo(s.ctx, req.TodoID)
+	default:
+		return nil, &BodyError{Path: "$.type", Message: fmt.Sprintf("unknown message type %q", req.Type)}
+	}
+}
+
+// decodeData parses the data of req into v, reporting errors at $.data
+func (s *socketSession) decodeData(req *SocketRequest, v interface{}) error {
+	if (req.Type == SocketUpdate || req.Type == SocketReplace) && req.TodoID == "" {
+		return models.NewValidationError("todo_id", "todo_id is required")
+	}
+	if len(req.Data) == 0 {
+		return &BodyError{Path: "$.data", Message: "data is required"}
+	}
+
+	err := s.handler.decoder.DecodeJSON(req.Data, v)
+	var bodyErr *BodyError
+	if errors.As(err, &bodyErr) {
+		bodyErr.Path = "$.data" + strings.TrimPrefix(bodyErr.Path, "$")
+	}
+	return err
+}
+
+// subscribe starts delivering events for a project or a todo
+func (s *socketSession) subscribe(project, todoID string) {
+	s.mu.Lock()
+	if todoID != "" {
+		s.todos[todoID] = true
+		s.mu.Unlock()
+		return
+	}
+	if _, ok := s.projects[project]; ok {
+		s.mu.Unlock()
+		return
+	}
+	// Mark the subscription before joining so the first presence update is sent
+	s.projects[project] = func() {}
+	s.mu.Unlock()
+
+	leave := s.handler.presence.Join(project, s.user, s.presenceChanged)
+
+	s.mu.Lock()
+	s.projects[project] = leave
+	s.mu.Unlock()
+}
+
+// unsubscribe stops delivering events for a project or a todo
+func (s *socketSession) unsubscribe(project, todoID string) {
+	s.mu.Lock()
+	if todoID != "" {
+		delete(s.todos, todoID)
+		s.mu.Unlock()
+		return
+	}
+	leave, ok := s.projects[project]
+	delete(s.projects, project)
+	s.mu.Unlock()
+
+	if ok {
+		leave()
+	}
+}
+
+// leaveAll leaves the presence of every subscribed project
+func (s *socketSession) leaveAll() {
+	s.mu.Lock()
+	projects := s.projects
+	s.projects = make(map[string]func())
+	s.mu.Unlock()
+
+	for _, leave := range projects {
+		leave()
+	}
+}
+
+// matches reports whether event concerns one of the session's
+// subscriptions. A todo moved out of a subscribed project matches too, so
+// its subscribers can drop it.
+func (s *socketSession) matches(event *models.TodoEvent) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.todos[event.TodoID] {
+		return true
+	}
+	if event.PreviousProject != "" {
+		if _, ok := s.projects[event.PreviousProject]; ok {
+			return true
+		}
+	}
+	if event.Todo == nil {
+		return false
+	}
+	_, ok := s.projects[event.Todo.Project]
+	return ok
+}
+
+// presenceChanged is the presence callback; it must not block
+func (s *socketSession) presenceChanged(project string) {
+	s.mu.Lock()
+	s.presenceDirty[project] = true
+	s.mu.Unlock()
+
+	select {
+	case s.presenceReady <- struct{}{}:
+	default:
+	}
+}
+
+// takePresence returns, sorted, the subscribed projects with pending presence updates
+func (s *socketSession) takePresence() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var projects []string
+	for project := range s.presenceDirty {
+		if _, ok := s.projects[project]; ok {
+			projects = append(projects, project)
+		}
+	}
+	s.presenceDirty = make(map[string]bool)
+	sort.Strings(projects)
+	return projects
+}
+
+// errorMessage converts err into an error reply
+func errorMessage(id string, err error) SocketMessage {
+	problem := ErrorProblem(err)
+	if problem.Status == fiber.StatusInternalServerError {
+		log.Printf("Error: %v", err)
+	}
+	if problem.Title == "" {
+		problem.Title = fiberutils.StatusMessage(problem.Status)
+	}
+	return SocketMessage{Type: SocketError, ID: id, Error: &problem}
+}
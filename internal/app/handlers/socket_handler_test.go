@@ -0,0 +1,162 @@
+package handlers_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/teguh/go-todo-api/internal/app/events"
+	"github.com/teguh/go-todo-api/internal/app/handlers"
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/outbox"
+	"github.com/teguh/go-todo-api/internal/app/repositories"
+	"github.com/teguh/go-todo-api/internal/app/services"
+)
+
+// realtime serves the event stream and the WebSocket channel over the
+// memory store. Changes reach subscribers when the test relays them.
+type realtime struct {
+	todos  *services.TodoService
+	broker *events.Broker
+	relay  *outbox.Dispatcher
+	addr   string
+}
+
+func newRealtime(t *testing.T, heartbeat time.Duration) *realtime {
+	t.Helper()
+
+	entries := repositories.NewMemoryOutboxRepository()
+	broker := events.NewBroker(repositories.NewMemoryEventRepository())
+	r := &realtime{
+		todos:  services.NewTodoService(repositories.NewMemoryTodoRepository(entries), nil),
+		broker: broker,
+		relay:  outbox.NewDispatcher(entries, []outbox.Sink{broker}, outbox.Options{}),
+	}
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	api := app.Group("/api/v1")
+	handlers.NewEventHandler(broker, heartbeat).RegisterRoutes(api)
+	handlers.NewSocketHandler(r.todos, broker, events.NewPresence(), handlers.BodyDecoder{Strict: true}).RegisterRoutes(api)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(listener)
+	t.Cleanup(func() {
+		broker.Close()
+		app.Shutdown()
+	})
+	r.addr = listener.Addr().String()
+	return r
+}
+
+// relayChanges publishes the changes made so far to subscribers
+func (r *realtime) relayChanges(t *testing.T) {
+	t.Helper()
+	if _, err := r.relay.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// socket is a WebSocket client of the realtime server
+type socket struct {
+	t    *testing.T
+	conn *websocket.Conn
+}
+
+func (r *realtime) dial(t *testing.T) *socket {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+r.addr+"/api/v1/todos/ws?user=alice", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &socket{t: t, conn: conn}
+}
+
+// send sends req and waits for its acknowledgement
+func (s *socket) send(req handlers.SocketRequest) {
+	s.t.Helper()
+	if err := s.conn.WriteJSON(req); err != nil {
+		s.t.Fatal(err)
+	}
+	if msg := s.next(); msg.Type != handlers.SocketAck || msg.ID != req.ID {
+		s.t.Fatalf("reply to %+v = %+v", req, msg)
+	}
+}
+
+// next returns the next message other than a presence update
+func (s *socket) next() handlers.SocketMessage {
+	s.t.Helper()
+	s.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var msg handlers.SocketMessage
+		if err := s.conn.ReadJSON(&msg); err != nil {
+			s.t.Fatal(err)
+		}
+		if msg.Type != handlers.SocketPresence {
+			return msg
+		}
+	}
+}
+
+// nextEvent returns the next change event
+func (s *socket) nextEvent() *models.TodoEvent {
+	s.t.Helper()
+	msg := s.next()
+	if msg.Type != handlers.SocketEvent || msg.Event == nil {
+		s.t.Fatalf("got %+v, want an event", msg)
+	}
+	return msg.Event
+}
+
+func TestSocketSubscribersSeeTodosLeaveTheirProject(t *testing.T) {
+	r := newRealtime(t, 0)
+	ctx := context.Background()
+	home := r.dial(t)
+	home.send(handlers.SocketRequest{ID: "1", Type: handlers.SocketSubscribe, Project: "home"})
+	work := r.dial(t)
+	work.send(handlers.SocketRequest{ID: "1", Type: handlers.SocketSubscribe, Project: "work"})
+
+	todo, err := r.todos.CreateTodo(ctx, models.TodoCreate{Title: "Fix the shelf", Project: "home"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.relayChanges(t)
+	if event := home.nextEvent(); event.Type != models.EventCreated || event.TodoID != todo.ID || event.PreviousProject != "" {
+		t.Fatalf("created event = %+v", event)
+	}
+
+	// Both projects hear of the move, the one it left through previous_project
+	moved := "work"
+	if _, err := r.todos.UpdateTodo(ctx, todo.ID, models.TodoUpdate{Project: &moved}); err != nil {
+		t.Fatal(err)
+	}
+	r.relayChanges(t)
+	for name, s := range map[string]*socket{"home": home, "work": work} {
+		if event := s.nextEvent(); event.Type != models.EventUpdated || event.Todo.Project != "work" || event.PreviousProject != "home" {
+			t.Errorf("%s subscriber got %+v", name, event)
+		}
+	}
+
+	// Later changes in the new project no longer concern the old one
+	title := "Fix the office shelf"
+	if _, err := r.todos.UpdateTodo(ctx, todo.ID, models.TodoUpdate{Title: &title}); err != nil {
+		t.Fatal(err)
+	}
+	other, err := r.todos.CreateTodo(ctx, models.TodoCreate{Title: "Water the plants", Project: "home"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.relayChanges(t)
+	if event := work.nextEvent(); event.TodoID != todo.ID || event.Todo.Title != title || event.PreviousProject != "" {
+		t.Errorf("work subscriber got %+v", event)
+	}
+	if event := home.nextEvent(); event.TodoID != other.ID {
+		t.Errorf("home subscriber got %+v, want the new todo", event)
+	}
+}
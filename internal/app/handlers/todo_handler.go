@@ -1,31 +1,50 @@
 package handlers
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/teguh/go-todo-api/internal/app/digest"
 	"github.com/teguh/go-todo-api/internal/app/models"
 	"github.com/teguh/go-todo-api/internal/app/services"
-	"github.com/teguh/go-todo-api/pkg/utils"
 )
 
 // TodoHandler handles HTTP requests for todos
 type TodoHandler struct {
-	service *services.TodoService
+	service     *services.TodoService
+	preferences *services.PreferenceService
+	decoder     BodyDecoder
 }
 
-// NewTodoHandler creates a new TodoHandler
-func NewTodoHandler() *TodoHandler {
+// NewTodoHandler creates a new TodoHandler that delegates to service,
+// renders times in the time zones users set in preferences and parses
+// request bodies with decoder
+func NewTodoHandler(service *services.TodoService, preferences *services.PreferenceService, decoder BodyDecoder) *TodoHandler {
 	return &TodoHandler{
-		service: services.NewTodoService(),
+		service:     service,
+		preferences: preferences,
+		decoder:     decoder,
 	}
 }
 
-// RegisterRoutes registers the routes for todos
+// location returns the time zone of a request: the timezone query
+// parameter, else the one the user set in their preferences, else UTC
+func (h *TodoHandler) location(c *fiber.Ctx) (*time.Location, error) {
+	return h.preferences.Location(c.UserContext(), c.Query("timezone"))
+}
+
+// RegisterRoutes registers the routes for todos.
+// Handlers return domain errors as-is; the app's error handler maps them to responses.
 func (h *TodoHandler) RegisterRoutes(router fiber.Router) {
 	todos := router.Group("/todos")
 
 	todos.Post("/", h.CreateTodo)
 	todos.Get("/", h.GetAllTodos)
 	todos.Get("/:id", h.GetTodoByID)
+	todos.Put("/:id", h.ReplaceTodo)
 	todos.Patch("/:id", h.UpdateTodo)
 	todos.Delete("/:id", h.DeleteTodo)
 }
@@ -37,46 +56,143 @@ func (h *TodoHandler) RegisterRoutes(router fiber.Router) {
 // @Accept json
 // @Produce json
 // @Param todo body models.TodoCreate true "Todo to create"
+// @Param timezone query string false "IANA time zone to render times in; defaults to the user's, else UTC"
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
 // @Success 201 {object} models.Todo
-// @Failure 400 {object} utils.ErrorResponse
-// @Failure 500 {object} utils.ErrorResponse
+// @Failure 400 {object} utils.ProblemDetails
+// @Failure 413 {object} utils.ProblemDetails
+// @Failure 415 {object} utils.ProblemDetails
+// @Failure 500 {object} utils.ProblemDetails
 // @Router /todos [post]
 func (h *TodoHandler) CreateTodo(c *fiber.Ctx) error {
+	loc, err := h.location(c)
+	if err != nil {
+		return err
+	}
 	var input models.TodoCreate
-	if err := c.BodyParser(&input); err != nil {
-		return utils.SendError(c, fiber.StatusBadRequest, "Invalid request body")
+	if err := h.decoder.Decode(c, &input); err != nil {
+		return err
 	}
 
-	todo, err := h.service.CreateTodo(input)
+	todo, err := h.service.CreateTodo(c.UserContext(), input)
 	if err != nil {
-		return utils.SendError(c, fiber.StatusInternalServerError, err.Error())
+		return err
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(todo)
+	return c.Status(fiber.StatusCreated).JSON(todo.In(loc))
 }
 
+// TotalCountHeader carries the number of todos matching a paged list request
+const TotalCountHeader = "X-Total-Count"
+
 // GetAllTodos handles retrieving all todos
 // @Summary Get all todos
-// @Description Get all todo items, optionally filtered by completion status
+// @Description Get the todo items matching the given filters, by default ordered by priority, then newest first.
+// @Description Without a limit every match is returned; with one, a page of at most 100 todos is returned and
+// @Description the X-Total-Count header holds the number of matches. An empty project or parent_id selects todos
+// @Description without a project or top-level todos respectively. Days for the due filter are counted, and
+// @Description times rendered, in the timezone parameter, else the user's time zone, else UTC.
 // @Tags todos
 // @Produce json
 // @Param completed query boolean false "Filter by completion status"
+// @Param project query string false "Filter by project"
+// @Param tag query string false "Filter by tag"
+// @Param parent_id query string false "Filter by parent todo"
+// @Param search query string false "Filter by text in the title or description, ignoring case"
+// @Param due query string false "Filter by due date: before now, today, or in the seven days from today" Enums(overdue, today, week)
+// @Param timezone query string false "IANA time zone to count days and render times in"
+// @Param sort query string false "Sort field" Enums(priority, created_at, updated_at, due_date, title)
+// @Param order query string false "Sort direction" Enums(asc, desc) default(asc)
+// @Param limit query int false "Page size, between 1 and 100"
+// @Param offset query int false "Number of todos to skip; requires limit"
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
 // @Success 200 {array} models.Todo
-// @Failure 500 {object} utils.ErrorResponse
+// @Header 200 {integer} X-Total-Count "Number of matching todos, when limit is set"
+// @Failure 400 {object} utils.ProblemDetails
+// @Failure 500 {object} utils.ProblemDetails
 // @Router /todos [get]
 func (h *TodoHandler) GetAllTodos(c *fiber.Ctx) error {
-	var completed *bool
-	if c.Query("completed") != "" {
-		completedVal := c.QueryBool("completed")
-		completed = &completedVal
+	loc, err := h.location(c)
+	if err != nil {
+		return err
+	}
+	query, err := todoQuery(c, loc)
+	if err != nil {
+		return err
 	}
 
-	todos, err := h.service.GetAllTodos(completed)
+	todos, err := h.service.FindTodos(c.UserContext(), query)
 	if err != nil {
-		return utils.SendError(c, fiber.StatusInternalServerError, err.Error())
+		return err
+	}
+	localized := make([]*models.Todo, len(todos))
+	for i, todo := range todos {
+		localized[i] = todo.In(loc)
+	}
+
+	if query.Limit > 0 {
+		counts, err := h.service.CountTodos(c.UserContext(), query.Filter)
+		if err != nil {
+			return err
+		}
+		c.Set(TotalCountHeader, strconv.Itoa(counts.Total))
 	}
 
-	return c.JSON(todos)
+	return c.JSON(localized)
+}
+
+// todoQuery reads the filters, sort and page of a list request, counting
+// days in loc
+func todoQuery(c *fiber.Ctx, loc *time.Location) (models.TodoQuery, error) {
+	args := c.Context().QueryArgs()
+	query := models.TodoQuery{
+		Filter: models.TodoFilter{
+			Tag:    c.Query("tag"),
+			Search: c.Query("search"),
+		},
+		Sort: c.Query("sort"),
+	}
+
+	if c.Query("completed") != "" {
+		completed := c.QueryBool("completed")
+		query.Filter.Completed = &completed
+	}
+	if args.Has("project") {
+		project := c.Query("project")
+		query.Filter.Project = &project
+	}
+	if args.Has("parent_id") {
+		parentID := c.Query("parent_id")
+		query.Filter.ParentID = &parentID
+	}
+	if due := c.Query("due"); due != "" {
+		window, ok := digest.Window(due, time.Now(), loc)
+		if !ok {
+			return query, models.NewValidationError("due", "due must be one of "+strings.Join(models.DueWindows, ", "))
+		}
+		query.Filter.Due = &window
+	}
+
+	switch c.Query("order") {
+	case "", "asc":
+	case "desc":
+		query.Descending = true
+	default:
+		return query, models.NewValidationError("order", "order must be asc or desc")
+	}
+
+	var err error
+	if query.Limit, err = queryInt(c, "limit"); err != nil {
+		return query, err
+	}
+	if query.Offset, err = queryInt(c, "offset"); err != nil {
+		return query, err
+	}
+	if args.Has("limit") && query.Limit == 0 {
+		return query, models.NewValidationError("limit", fmt.Sprintf("limit must be between 1 and %d", services.MaxPageSize))
+	}
+
+	return query, nil
 }
 
 // GetTodoByID handles retrieving a todo by ID
@@ -85,54 +201,151 @@ func (h *TodoHandler) GetAllTodos(c *fiber.Ctx) error {
 // @Tags todos
 // @Produce json
 // @Param id path string true "Todo ID"
+// @Param timezone query string false "IANA time zone to render times in; defaults to the user's, else UTC"
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
 // @Success 200 {object} models.Todo
-// @Failure 404 {object} utils.ErrorResponse
-// @Failure 500 {object} utils.ErrorResponse
+// @Failure 400 {object} utils.ProblemDetails
+// @Failure 404 {object} utils.ProblemDetails
+// @Failure 500 {object} utils.ProblemDetails
 // @Router /todos/{id} [get]
 func (h *TodoHandler) GetTodoByID(c *fiber.Ctx) error {
+	loc, err := h.location(c)
+	if err != nil {
+		return err
+	}
 	id := c.Params("id")
-	todo, err := h.service.GetTodoByID(id)
+	todo, err := h.service.GetTodoByID(c.UserContext(), id)
 	if err != nil {
-		status := fiber.StatusInternalServerError
-		if err.Error() == "todo not found" {
-			status = fiber.StatusNotFound
-		}
-		return utils.SendError(c, status, err.Error())
+		return err
+	}
+
+	return c.JSON(todo.In(loc))
+}
+
+// ReplaceTodo handles replacing, or creating, a todo with a client-supplied ID
+// @Summary Replace or create a todo
+// @Description Replace every field of the todo with the given ID, or create it with that ID if it does not exist.
+// @Description Intended for offline-first clients that generate UUIDs locally. Omitted fields are reset to their defaults.
+// @Tags todos
+// @Accept json
+// @Produce json
+// @Param id path string true "Todo ID (lowercase UUID)"
+// @Param todo body models.TodoReplace true "Full todo state"
+// @Param timezone query string false "IANA time zone to render times in; defaults to the user's, else UTC"
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
+// @Success 200 {object} models.Todo "Replaced"
+// @Success 201 {object} models.Todo "Created"
+// @Failure 400 {object} utils.ProblemDetails
+// @Failure 409 {object} utils.ProblemDetails "Body id does not match the path"
+// @Failure 413 {object} utils.ProblemDetails
+// @Failure 415 {object} utils.ProblemDetails
+// @Failure 500 {object} utils.ProblemDetails
+// @Router /todos/{id} [put]
+func (h *TodoHandler) ReplaceTodo(c *fiber.Ctx) error {
+	loc, err := h.location(c)
+	if err != nil {
+		return err
+	}
+	// The ID may become the key of a stored todo, so it must not share
+	// Fiber's request buffer, which is reused once the handler returns
+	id := strings.Clone(c.Params("id"))
+	var input models.TodoReplace
+	if err := h.decoder.Decode(c, &input); err != nil {
+		return err
+	}
+
+	todo, created, err := h.service.ReplaceTodo(c.UserContext(), id, input)
+	if err != nil {
+		return err
+	}
+
+	if created {
+		c.Location(c.OriginalURL())
+		return c.Status(fiber.StatusCreated).JSON(todo.In(loc))
 	}
+	return c.JSON(todo.In(loc))
+}
 
-	return c.JSON(todo)
+// queryInt parses the integer query parameter name, which is zero if absent
+func queryInt(c *fiber.Ctx, name string) (int, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return 0, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, models.NewValidationError(name, name+" must be an integer")
+	}
+	return value, nil
 }
 
+// Patch document media types accepted by UpdateTodo
+const (
+	MIMEMergePatch = "application/merge-patch+json"
+	MIMEJSONPatch  = "application/json-patch+json"
+)
+
 // UpdateTodo handles updating a todo
 // @Summary Update a todo
-// @Description Update a todo item by its ID
+// @Description Update a todo item by its ID. The body is interpreted by its Content-Type:
+// @Description application/json takes a partial TodoUpdate, application/merge-patch+json a JSON Merge Patch (RFC 7396)
+// @Description where null clears description, project or due_date, and application/json-patch+json a JSON Patch (RFC 6902)
+// @Description including test operations. Patches apply atomically against the todo's JSON representation.
 // @Tags todos
 // @Accept json
+// @Accept application/merge-patch+json
+// @Accept application/json-patch+json
 // @Produce json
 // @Param id path string true "Todo ID"
 // @Param todo body models.TodoUpdate true "Todo update data"
+// @Param timezone query string false "IANA time zone to render times in; defaults to the user's, else UTC"
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
 // @Success 200 {object} models.Todo
-// @Failure 400 {object} utils.ErrorResponse
-// @Failure 404 {object} utils.ErrorResponse
-// @Failure 500 {object} utils.ErrorResponse
+// @Failure 400 {object} utils.ProblemDetails
+// @Failure 404 {object} utils.ProblemDetails
+// @Failure 409 {object} utils.ProblemDetails "A JSON Patch test operation failed, or the todo changed while it was patched"
+// @Failure 413 {object} utils.ProblemDetails
+// @Failure 415 {object} utils.ProblemDetails
+// @Failure 500 {object} utils.ProblemDetails
 // @Router /todos/{id} [patch]
 func (h *TodoHandler) UpdateTodo(c *fiber.Ctx) error {
+	loc, err := h.location(c)
+	if err != nil {
+		return err
+	}
 	id := c.Params("id")
+
+	var format services.PatchFormat
+	switch mediaType(c.Get(fiber.HeaderContentType)) {
+	case MIMEMergePatch:
+		format = services.MergePatch
+	case MIMEJSONPatch:
+		format = services.JSONPatch
+	}
+
+	if format != 0 {
+		patch, err := h.decoder.RawJSON(c)
+		if err != nil {
+			return err
+		}
+		todo, err := h.service.PatchTodo(c.UserContext(), id, format, patch, loc)
+		if err != nil {
+			return err
+		}
+		return c.JSON(todo.In(loc))
+	}
+
 	var input models.TodoUpdate
-	if err := c.BodyParser(&input); err != nil {
-		return utils.SendError(c, fiber.StatusBadRequest, "Invalid request body")
+	if err := h.decoder.Decode(c, &input); err != nil {
+		return err
 	}
 
-	todo, err := h.service.UpdateTodo(id, input)
+	todo, err := h.service.UpdateTodo(c.UserContext(), id, input)
 	if err != nil {
-		status := fiber.StatusInternalServerError
-		if err.Error() == "todo not found" {
-			status = fiber.StatusNotFound
-		}
-		return utils.SendError(c, status, err.Error())
+		return err
 	}
 
-	return c.JSON(todo)
+	return c.JSON(todo.In(loc))
 }
 
 // DeleteTodo handles deleting a todo
@@ -141,19 +354,16 @@ func (h *TodoHandler) UpdateTodo(c *fiber.Ctx) error {
 // @Tags todos
 // @Produce json
 // @Param id path string true "Todo ID"
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
 // @Success 204 "No Content"
-// @Failure 404 {object} utils.ErrorResponse
-// @Failure 500 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ProblemDetails
+// @Failure 500 {object} utils.ProblemDetails
 // @Router /todos/{id} [delete]
 func (h *TodoHandler) DeleteTodo(c *fiber.Ctx) error {
 	id := c.Params("id")
-	err := h.service.DeleteTodo(id)
+	err := h.service.DeleteTodo(c.UserContext(), id)
 	if err != nil {
-		status := fiber.StatusInternalServerError
-		if err.Error() == "todo not found" {
-			status = fiber.StatusNotFound
-		}
-		return utils.SendError(c, status, err.Error())
+		return err
 	}
 
 	return c.SendStatus(fiber.StatusNoContent)
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/services"
+	"github.com/teguh/go-todo-api/internal/app/transfer"
+)
+
+// TransferHandler imports and exports todos as CSV, NDJSON, JSON,
+// iCalendar, todo.txt or Markdown files, and imports the export files of
+// other apps
+type TransferHandler struct {
+	service     *services.TodoService
+	preferences *services.PreferenceService
+	maxRows     int
+}
+
+// NewTransferHandler creates a new TransferHandler exporting times in the
+// time zones users set in preferences. Imports of more than maxRows rows
+// are rejected; zero means no limit.
+func NewTransferHandler(service *services.TodoService, preferences *services.PreferenceService, maxRows int) *TransferHandler {
+	return &TransferHandler{
+		service:     service,
+		preferences: preferences,
+		maxRows:     maxRows,
+	}
+}
+
+// RegisterRoutes registers the import and export routes. It must be called
+// before TodoHandler.RegisterRoutes so /todos/export is not taken for an ID.
+func (h *TransferHandler) RegisterRoutes(router fiber.Router) {
+	router.Get("/todos/export", h.ExportTodos)
+	router.Post("/todos/import", h.ImportTodos)
+}
+
+// ExportTodos handles exporting todos to a file
+// @Summary Export todos
+// @Description Stream every todo matching the given filters as CSV, newline-delimited JSON, a JSON array, an
+// @Description iCalendar file of VTODOs, a todo.txt file or a Markdown checklist, ordered as in the list endpoint. CSV
+// @Description files start with a header row and join tags with commas. todo.txt and Markdown files keep every field,
+// @Description writing the id, parent and description as key:value pairs or comments, so they can be edited and
+// @Description imported again; Markdown has a heading whenever the project changes. The todos are read in batches,
+// @Description each starting after the last todo of the one before, so writes during a long export never duplicate or
+// @Description drop the todos they do not touch; only a todo whose sort fields change can appear twice or not at all.
+// @Description Times are written in the timezone parameter, else the user's time zone, else UTC.
+// @Tags todos
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Produce json
+// @Produce text/calendar
+// @Produce text/plain
+// @Produce text/markdown
+// @Param format query string false "File format" Enums(csv, ndjson, json, ics, todotxt, markdown) default(json)
+// @Param completed query boolean false "Filter by completion status"
+// @Param project query string false "Filter by project"
+// @Param tag query string false "Filter by tag"
+// @Param parent_id query string false "Filter by parent todo"
+// @Param search query string false "Filter by text in the title or description, ignoring case"
+// @Param due query string false "Filter by due date: before now, today, or in the seven days from today" Enums(overdue, today, week)
+// @Param timezone query string false "IANA time zone to count days and write times in"
+// @Param sort query string false "Sort field" Enums(priority, created_at, updated_at, due_date, title)
+// @Param order query string false "Sort direction" Enums(asc, desc) default(asc)
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
+// @Success 200 {array} models.Todo
+// @Failure 400 {object} utils.ProblemDetails
+// @Failure 500 {object} utils.ProblemDetails
+// @Router /todos/export [get]
+func (h *TransferHandler) ExportTodos(c *fiber.Ctx) error {
+	format := c.Query("format", transfer.FormatJSON)
+	contentType := transfer.ContentType(format)
+	if contentType == "" {
+		return models.NewValidationError("format", fmt.Sprintf("cannot export as %s", format))
+	}
+
+	loc, err := h.preferences.Location(c.UserContext(), c.Query("timezone"))
+	if err != nil {
+		return err
+	}
+	query, err := todoQuery(c, loc)
+	if err != nil {
+		return err
+	}
+	// The todos are read after the handler returns, when Fiber has reused
+	// the buffers the query's strings point into
+	query = cloneQuery(query)
+
+	todos, err := h.service.ExportTodos(c.UserContext(), query)
+	if err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="todos.%s"`, transfer.Extension(format)))
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// The format was checked above
+		writer, _ := transfer.NewWriter(w, format)
+		for todo, err := range todos {
+			if err != nil {
+				// The status is sent already; the client sees a truncated file
+				log.Printf("Failed to export todos: %v", err)
+				return
+			}
+			if writer.Write(todo.In(loc)) != nil {
+				return
+			}
+		}
+		if writer.Close() == nil {
+			w.Flush()
+		}
+	})
+
+	return nil
+}
+
+// ImportTodos handles importing todos from a file
+// @Summary Import todos
+// @Description Import todos from a CSV file with a header row, newline-delimited JSON, a JSON array, an iCalendar
+// @Description file, a todo.txt file or a Markdown checklist, as given by format or the Content-Type. Columns (or keys) named after a todo field are read into
+// @Description it, and map renames others, e.g. map=Task Name:title; the rest are ignored and listed in ignored_columns.
+// @Description CSV tags are separated by commas. iCalendar files have a row per VTODO, read as the calendar feed
+// @Description writes them, with UID as the id and a RELATED-TO parent as the parent_id; other components are skipped. Rows are validated like created todos, with RFC3339 due dates, and a parent_id may
+// @Description name a todo or another row by its id. todo.txt files have a row per line, with (A) to (E) as priorities 5
+// @Description to 1, +project, @tags, and due, id, parent, pri and description as key:value pairs. Markdown files have a
+// @Description row per checklist item, under a heading naming its project, with #tags, the priority as in todo.txt and
+// @Description quoted lines below as the description; indented items are subtasks. Neither can be mapped.
+// @Description
+// @Description The export files of other apps are read with format=todoist (a project exported as CSV, or tasks from
+// @Description the Todoist API), format=trello (a board exported as JSON) or format=mstodo (Microsoft To Do lists with
+// @Description their tasks from Microsoft Graph). Their projects, labels and subtasks are kept where the file has them,
+// @Description and ignored_columns lists the fields of their tasks that todos have no counterpart for. Such files can
+// @Description only be imported in create mode and cannot be mapped.
+// @Description
+// @Description In create mode every row becomes a new todo and ids only link rows to their parents; in upsert mode
+// @Description rows with the id of an existing todo replace it and the others are created with their id. Rows are
+// @Description written in one transaction only if all are valid; otherwise nothing is written, the response is 422
+// @Description and errors lists the problems of every row. A dry run validates and reports without writing.
+// @Tags todos
+// @Accept text/csv
+// @Accept application/x-ndjson
+// @Accept json
+// @Accept text/calendar
+// @Accept text/plain
+// @Accept text/markdown
+// @Produce json
+// @Param format query string false "File format, if not given by the Content-Type" Enums(csv, ndjson, json, ics, todotxt, markdown, todoist, trello, mstodo)
+// @Param mode query string false "Import mode" Enums(create, upsert) default(create)
+// @Param dry_run query boolean false "Validate without writing"
+// @Param map query []string false "Column mappings as column:field" collectionFormat(multi)
+// @Param file body string true "Import file"
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
+// @Success 200 {object} models.ImportResult
+// @Failure 400 {object} utils.ProblemDetails
+// @Failure 415 {object} utils.ProblemDetails
+// @Failure 422 {object} models.ImportResult "Some rows are invalid; nothing was written"
+// @Failure 500 {object} utils.ProblemDetails
+// @Router /todos/import [post]
+func (h *TransferHandler) ImportTodos(c *fiber.Ctx) error {
+	format := c.Query("format")
+	if format == "" {
+		format = transfer.FormatOf(mediaType(c.Get(fiber.HeaderContentType)))
+		if format == "" {
+			return fiber.NewError(fiber.StatusUnsupportedMediaType, "Content-Type must be text/csv, application/x-ndjson, application/json, text/calendar, text/plain or text/markdown")
+		}
+	} else if !transfer.CanImport(format) {
+		return models.NewValidationError("format", fmt.Sprintf("cannot import %s", format))
+	}
+
+	mode := c.Query("mode", models.ImportCreate)
+	if transfer.IsAppFormat(format) && mode != models.ImportCreate {
+		return models.NewValidationError("mode", fmt.Sprintf("%s files can only be imported in %s mode", format, models.ImportCreate))
+	}
+
+	var specs []string
+	for _, spec := range c.Context().QueryArgs().PeekMulti("map") {
+		specs = append(specs, string(spec))
+	}
+	mapping, err := transfer.ParseMapping(specs)
+	if err != nil {
+		return err
+	}
+
+	rows, ignored, err := transfer.Read(bytes.NewReader(c.Body()), format, mapping, h.maxRows)
+	if err != nil {
+		return err
+	}
+
+	result, err := h.service.ImportTodos(c.UserContext(), rows, models.ImportOptions{
+		Mode:           mode,
+		DryRun:         c.QueryBool("dry_run"),
+		IgnoredColumns: ignored,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(result.Errors) > 0 && !result.DryRun {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(result)
+	}
+	return c.JSON(result)
+}
+
+// cloneQuery copies the strings of query so it outlives the request
+func cloneQuery(query models.TodoQuery) models.TodoQuery {
+	query.Filter.Tag = strings.Clone(query.Filter.Tag)
+	query.Filter.Search = strings.Clone(query.Filter.Search)
+	query.Sort = strings.Clone(query.Sort)
+	if query.Filter.Project != nil {
+		project := strings.Clone(*query.Filter.Project)
+		query.Filter.Project = &project
+	}
+	if query.Filter.ParentID != nil {
+		parentID := strings.Clone(*query.Filter.ParentID)
+		query.Filter.ParentID = &parentID
+	}
+	return query
+}
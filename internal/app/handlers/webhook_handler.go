@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/services"
+)
+
+// WebhookHandler handles HTTP requests for webhooks and their deliveries
+type WebhookHandler struct {
+	service *services.WebhookService
+	decoder BodyDecoder
+}
+
+// NewWebhookHandler creates a new WebhookHandler that delegates to service
+// and parses request bodies with decoder
+func NewWebhookHandler(service *services.WebhookService, decoder BodyDecoder) *WebhookHandler {
+	return &WebhookHandler{
+		service: service,
+		decoder: decoder,
+	}
+}
+
+// RegisterRoutes registers the routes for webhooks
+func (h *WebhookHandler) RegisterRoutes(router fiber.Router) {
+	webhooks := router.Group("/webhooks")
+
+	webhooks.Post("/", h.CreateWebhook)
+	webhooks.Get("/", h.GetAllWebhooks)
+	webhooks.Get("/:id", h.GetWebhookByID)
+	webhooks.Patch("/:id", h.UpdateWebhook)
+	webhooks.Delete("/:id", h.DeleteWebhook)
+	webhooks.Get("/:id/deliveries", h.GetDeliveries)
+	webhooks.Get("/:id/deliveries/:deliveryId", h.GetDeliveryByID)
+	webhooks.Post("/:id/deliveries/:deliveryId/redeliver", h.Redeliver)
+}
+
+// CreateWebhook handles the creation of a webhook
+// @Summary Create a webhook
+// @Description Subscribe an HTTP endpoint to todo events. Deliveries are POSTed as JSON and signed with HMAC-SHA256:
+// @Description X-Webhook-Signature is "sha256=" followed by the hex HMAC of X-Webhook-Timestamp, a dot and the body.
+// @Description The secret is generated if omitted and only returned in this response.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param webhook body models.WebhookCreate true "Webhook to create"
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
+// @Success 201 {object} models.Webhook
+// @Failure 400 {object} utils.ProblemDetails
+// @Failure 413 {object} utils.ProblemDetails
+// @Failure 415 {object} utils.ProblemDetails
+// @Failure 500 {object} utils.ProblemDetails
+// @Router /webhooks [post]
+func (h *WebhookHandler) CreateWebhook(c *fiber.Ctx) error {
+	var input models.WebhookCreate
+	if err := h.decoder.Decode(c, &input); err != nil {
+		return err
+	}
+
+	hook, err := h.service.CreateWebhook(input)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(hook)
+}
+
+// GetAllWebhooks handles retrieving all webhooks
+// @Summary Get all webhooks
+// @Description Get every webhook, oldest first. Secrets are not included.
+// @Tags webhooks
+// @Produce json
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
+// @Success 200 {array} models.Webhook
+// @Failure 500 {object} utils.ProblemDetails
+// @Router /webhooks [get]
+func (h *WebhookHandler) GetAllWebhooks(c *fiber.Ctx) error {
+	hooks, err := h.service.ListWebhooks()
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(hooks)
+}
+
+// GetWebhookByID handles retrieving a webhook by ID
+// @Summary Get a webhook by ID
+// @Description Get a webhook by its ID. The secret is not included.
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
+// @Success 200 {object} models.Webhook
+// @Failure 404 {object} utils.ProblemDetails
+// @Failure 500 {object} utils.ProblemDetails
+// @Router /webhooks/{id} [get]
+func (h *WebhookHandler) GetWebhookByID(c *fiber.Ctx) error {
+	hook, err := h.service.GetWebhook(c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(hook)
+}
+
+// UpdateWebhook handles updating a webhook
+// @Summary Update a webhook
+// @Description Update a webhook by its ID. Sending a secret rotates it.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Param webhook body models.WebhookUpdate true "Webhook update data"
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
+// @Success 200 {object} models.Webhook
+// @Failure 400 {object} utils.ProblemDetails
+// @Failure 404 {object} utils.ProblemDetails
+// @Failure 413 {object} utils.ProblemDetails
+// @Failure 415 {object} utils.ProblemDetails
+// @Failure 500 {object} utils.ProblemDetails
+// @Router /webhooks/{id} [patch]
+func (h *WebhookHandler) UpdateWebhook(c *fiber.Ctx) error {
+	var input models.WebhookUpdate
+	if err := h.decoder.Decode(c, &input); err != nil {
+		return err
+	}
+
+	hook, err := h.service.UpdateWebhook(c.Params("id"), input)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(hook)
+}
+
+// DeleteWebhook handles deleting a webhook
+// @Summary Delete a webhook
+// @Description Delete a webhook by its ID, together with its deliveries
+// @Tags webhooks
+// @Param id path string true "Webhook ID"
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
+// @Success 204 "No Content"
+// @Failure 404 {object} utils.ProblemDetails
+// @Failure 500 {object} utils.ProblemDetails
+// @Router /webhooks/{id} [delete]
+func (h *WebhookHandler) DeleteWebhook(c *fiber.Ctx) error {
+	if err := h.service.DeleteWebhook(c.Params("id")); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetDeliveries handles retrieving the deliveries of a webhook
+// @Summary Get webhook deliveries
+// @Description Get the 100 most recent deliveries of a webhook, newest first
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
+// @Success 200 {array} models.WebhookDelivery
+// @Failure 404 {object} utils.ProblemDetails
+// @Failure 500 {object} utils.ProblemDetails
+// @Router /webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) GetDeliveries(c *fiber.Ctx) error {
+	deliveries, err := h.service.ListDeliveries(c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(deliveries)
+}
+
+// GetDeliveryByID handles retrieving a delivery with its attempt log
+// @Summary Get a webhook delivery
+// @Description Get a delivery of a webhook including the log of every attempt made
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Param deliveryId path string true "Delivery ID"
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
+// @Success 200 {object} models.WebhookDelivery
+// @Failure 404 {object} utils.ProblemDetails
+// @Failure 500 {object} utils.ProblemDetails
+// @Router /webhooks/{id}/deliveries/{deliveryId} [get]
+func (h *WebhookHandler) GetDeliveryByID(c *fiber.Ctx) error {
+	delivery, err := h.service.GetDelivery(c.Params("id"), c.Params("deliveryId"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(delivery)
+}
+
+// Redeliver handles sending a delivery again
+// @Summary Redeliver a webhook delivery
+// @Description Queue a new delivery with the same payload as an earlier one, whatever its outcome
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Param deliveryId path string true "Delivery ID"
+// @Param X-Error-Format header string false "Set to legacy for {success, message} error bodies instead of problem details" Enums(legacy)
+// @Success 202 {object} models.WebhookDelivery
+// @Failure 404 {object} utils.ProblemDetails
+// @Failure 500 {object} utils.ProblemDetails
+// @Router /webhooks/{id}/deliveries/{deliveryId}/redeliver [post]
+func (h *WebhookHandler) Redeliver(c *fiber.Ctx) error {
+	delivery, err := h.service.Redeliver(c.Params("id"), c.Params("deliveryId"))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(delivery)
+}
@@ -0,0 +1,485 @@
+// Package ical reads and writes iCalendar (RFC 5545) data and converts
+// todos to and from its VTODO and VEVENT components.
+//
+// Only the parts of the format todos need are supported: content lines with
+// parameters, line folding, TEXT escaping and DATE and DATE-TIME values.
+// Recurrence and time zone definitions are not interpreted.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Component names
+const (
+	Calendar = "VCALENDAR"
+	Todo     = "VTODO"
+	Event    = "VEVENT"
+)
+
+// MethodPublish is the METHOD of calendars published for anyone to read
+const MethodPublish = "PUBLISH"
+
+// Property is a content line of a component. Value is kept encoded, as
+// it appears in the file; see Text and Component.AddText for TEXT values.
+type Property struct {
+	Name   string
+	Params map[string][]string
+	Value  string
+}
+
+// Param returns the first value of the parameter name, or ""
+func (p *Property) Param(name string) string {
+	if values := p.Params[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Text returns the value of a TEXT property, unescaped
+func (p *Property) Text() string {
+	return unescapeText(p.Value)
+}
+
+// Component is a calendar object, such as a VCALENDAR holding VTODOs
+type Component struct {
+	Name       string
+	Properties []*Property
+	Children   []*Component
+}
+
+// NewComponent returns an empty component called name
+func NewComponent(name string) *Component {
+	return &Component{Name: name}
+}
+
+// Get returns the first property called name, or nil
+func (c *Component) Get(name string) *Property {
+	for _, prop := range c.Properties {
+		if prop.Name == name {
+			return prop
+		}
+	}
+	return nil
+}
+
+// GetAll returns every property called name, in order
+func (c *Component) GetAll(name string) []*Property {
+	var props []*Property
+	for _, prop := range c.Properties {
+		if prop.Name == name {
+			props = append(props, prop)
+		}
+	}
+	return props
+}
+
+// Add appends a property with an encoded value
+func (c *Component) Add(name, value string) *Property {
+	prop := &Property{Name: name, Value: value}
+	c.Properties = append(c.Properties, prop)
+	return prop
+}
+
+// AddText appends a TEXT property, escaping value
+func (c *Component) AddText(name, value string) *Property {
+	return c.Add(name, escapeText(value))
+}
+
+// AddTextList appends a property holding a list of TEXT values, such as
+// CATEGORIES
+func (c *Component) AddTextList(name string, values []string) *Property {
+	escaped := make([]string, len(values))
+	for i, value := range values {
+		escaped[i] = escapeText(value)
+	}
+	return c.Add(name, strings.Join(escaped, ","))
+}
+
+// TextList returns the values of every property called name, each split on
+// its unescaped commas, as for CATEGORIES
+func (c *Component) TextList(name string) []string {
+	var values []string
+	for _, prop := range c.GetAll(name) {
+		for _, value := range splitList(prop.Value) {
+			values = append(values, unescapeText(value))
+		}
+	}
+	return values
+}
+
+// maxLineOctets is the longest a content line may be before it is folded,
+// not counting the line break
+const maxLineOctets = 75
+
+// Encoder writes components as content lines
+type Encoder struct {
+	w *bufio.Writer
+}
+
+// NewEncoder returns an Encoder writing to w. Flush must be called once
+// everything is encoded.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: bufio.NewWriter(w)}
+}
+
+// Encode writes c and its children
+func (e *Encoder) Encode(c *Component) error {
+	if err := e.Begin(c.Name); err != nil {
+		return err
+	}
+	for _, prop := range c.Properties {
+		if err := e.Property(prop); err != nil {
+			return err
+		}
+	}
+	for _, child := range c.Children {
+		if err := e.Encode(child); err != nil {
+			return err
+		}
+	}
+	return e.End(c.Name)
+}
+
+// Begin opens a component; its properties and children can then be written
+// one at a time, which lets a calendar be streamed
+func (e *Encoder) Begin(name string) error {
+	return e.line("BEGIN:" + name)
+}
+
+// End closes the component opened by Begin
+func (e *Encoder) End(name string) error {
+	return e.line("END:" + name)
+}
+
+// Property writes a single content line
+func (e *Encoder) Property(prop *Property) error {
+	var b strings.Builder
+	b.WriteString(prop.Name)
+	for _, name := range sortedKeys(prop.Params) {
+		b.WriteString(";")
+		b.WriteString(name)
+		b.WriteString("=")
+		for i, value := range prop.Params[name] {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			b.WriteString(quoteParam(value))
+		}
+	}
+	b.WriteString(":")
+	b.WriteString(prop.Value)
+	return e.line(b.String())
+}
+
+// Flush writes any buffered data to the underlying writer
+func (e *Encoder) Flush() error {
+	return e.w.Flush()
+}
+
+// line writes a content line, folding it so no line exceeds maxLineOctets.
+// Folds never split a UTF-8 sequence.
+func (e *Encoder) line(s string) error {
+	limit := maxLineOctets
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		e.w.WriteString(s[:cut])
+		e.w.WriteString("\r\n ")
+		s = s[cut:]
+		// The leading space of a continuation line counts towards its length
+		limit = maxLineOctets - 1
+	}
+	e.w.WriteString(s)
+	_, err := e.w.WriteString("\r\n")
+	return err
+}
+
+// SyntaxError reports malformed iCalendar data
+type SyntaxError struct {
+	Line    int
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// Decode reads the calendar in r. Anything after its END:VCALENDAR is
+// ignored, as are blank lines.
+func Decode(r io.Reader) (*Component, error) {
+	lines := &lineReader{r: bufio.NewReader(r)}
+
+	var stack []*Component
+	for {
+		line, err := lines.next()
+		if err == io.EOF {
+			if len(stack) > 0 {
+				return nil, &SyntaxError{Line: lines.number, Message: fmt.Sprintf("%s is not closed", stack[len(stack)-1].Name)}
+			}
+			return nil, &SyntaxError{Line: lines.number, Message: "no " + Calendar + " found"}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		prop, err := parseLine(line)
+		if err != nil {
+			return nil, &SyntaxError{Line: lines.start, Message: err.Error()}
+		}
+
+		switch prop.Name {
+		case "BEGIN":
+			name := strings.ToUpper(prop.Value)
+			if len(stack) == 0 && name != Calendar {
+				return nil, &SyntaxError{Line: lines.start, Message: fmt.Sprintf("expected BEGIN:%s, found BEGIN:%s", Calendar, name)}
+			}
+			component := NewComponent(name)
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, component)
+			}
+			stack = append(stack, component)
+		case "END":
+			if len(stack) == 0 {
+				return nil, &SyntaxError{Line: lines.start, Message: "END outside of any component"}
+			}
+			current := stack[len(stack)-1]
+			if name := strings.ToUpper(prop.Value); name != current.Name {
+				return nil, &SyntaxError{Line: lines.start, Message: fmt.Sprintf("expected END:%s, found END:%s", current.Name, name)}
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return current, nil
+			}
+		default:
+			if len(stack) == 0 {
+				return nil, &SyntaxError{Line: lines.start, Message: fmt.Sprintf("%s outside of any component", prop.Name)}
+			}
+			current := stack[len(stack)-1]
+			current.Properties = append(current.Properties, prop)
+		}
+	}
+}
+
+// lineReader unfolds content lines, tracking the physical line each
+// starts on
+type lineReader struct {
+	r *bufio.Reader
+	// pending is the physical line read ahead to look for a continuation
+	pending *string
+	// number is the last physical line read; start is where the last
+	// content line returned began
+	number int
+	start  int
+}
+
+// next returns the next non-blank content line, unfolded
+func (l *lineReader) next() (string, error) {
+	for {
+		line, err := l.physical()
+		if err != nil {
+			return "", err
+		}
+		if line == "" {
+			continue
+		}
+		l.start = l.number
+
+		var b strings.Builder
+		b.WriteString(line)
+		for {
+			more, err := l.physical()
+			if err == io.EOF {
+				return b.String(), nil
+			}
+			if err != nil {
+				return "", err
+			}
+			if more == "" || (more[0] != ' ' && more[0] != '\t') {
+				l.pending = &more
+				return b.String(), nil
+			}
+			b.WriteString(more[1:])
+		}
+	}
+}
+
+// physical returns the next physical line without its line break
+func (l *lineReader) physical() (string, error) {
+	if l.pending != nil {
+		line := *l.pending
+		l.pending = nil
+		return line, nil
+	}
+
+	line, err := l.r.ReadString('\n')
+	if err == io.EOF && line == "" {
+		return "", io.EOF
+	}
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	l.number++
+	// Some producers end lines with a bare LF
+	line = strings.TrimSuffix(line, "\n")
+	line = strings.TrimSuffix(line, "\r")
+	if l.number == 1 {
+		line = strings.TrimPrefix(line, "\ufeff")
+	}
+	return line, nil
+}
+
+// parseLine splits a content line into its name, parameters and value.
+// Names are uppercased, since they are case-insensitive.
+func parseLine(line string) (*Property, error) {
+	i := strings.IndexAny(line, ";:")
+	if i <= 0 {
+		return nil, fmt.Errorf("malformed content line %q", truncate(line))
+	}
+	prop := &Property{Name: strings.ToUpper(line[:i])}
+
+	rest := line[i:]
+	for rest[0] == ';' {
+		rest = rest[1:]
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("malformed parameter in %s", prop.Name)
+		}
+		name := strings.ToUpper(rest[:eq])
+		rest = rest[eq+1:]
+
+		for {
+			var value string
+			if strings.HasPrefix(rest, `"`) {
+				end := strings.IndexByte(rest[1:], '"')
+				if end < 0 {
+					return nil, fmt.Errorf("unterminated quoted parameter %s in %s", name, prop.Name)
+				}
+				value, rest = rest[1:end+1], rest[end+2:]
+			} else {
+				end := strings.IndexAny(rest, ",;:")
+				if end < 0 {
+					return nil, fmt.Errorf("%s has no value", prop.Name)
+				}
+				value, rest = rest[:end], rest[end:]
+			}
+			if prop.Params == nil {
+				prop.Params = make(map[string][]string)
+			}
+			prop.Params[name] = append(prop.Params[name], value)
+
+			if rest == "" {
+				return nil, fmt.Errorf("%s has no value", prop.Name)
+			}
+			if rest[0] != ',' {
+				break
+			}
+			rest = rest[1:]
+		}
+	}
+
+	if rest[0] != ':' {
+		return nil, fmt.Errorf("malformed parameters in %s", prop.Name)
+	}
+	prop.Value = rest[1:]
+	return prop, nil
+}
+
+// escapeText escapes a TEXT value
+func escapeText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case ';':
+			b.WriteString(`\;`)
+		case ',':
+			b.WriteString(`\,`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			// Line breaks are written as \n alone
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// unescapeText reverses escapeText, keeping unknown escapes as they are
+func unescapeText(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		case '\\', ';', ',':
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// splitList splits an encoded list value on the commas that are not escaped
+func splitList(s string) []string {
+	var values []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ',':
+			values = append(values, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(values, s[start:])
+}
+
+// quoteParam quotes a parameter value if it holds a delimiter. Double
+// quotes cannot be escaped, so they are dropped.
+func quoteParam(value string) string {
+	value = strings.ReplaceAll(value, `"`, "")
+	if strings.ContainsAny(value, ",;:") {
+		return `"` + value + `"`
+	}
+	return value
+}
+
+// sortedKeys returns the parameter names in order, so output is stable
+func sortedKeys(params map[string][]string) []string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// truncate shortens s for an error message
+func truncate(s string) string {
+	if len(s) > 40 {
+		return s[:40] + "..."
+	}
+	return s
+}
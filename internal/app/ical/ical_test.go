@@ -0,0 +1,374 @@
+package ical_test
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/teguh/go-todo-api/internal/app/ical"
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+// newTodo returns a todo due at due, as the API would store it
+func newTodo(t *testing.T, title, due string) *models.Todo {
+	t.Helper()
+	created := time.Date(2030, 4, 1, 8, 0, 0, 0, time.UTC)
+	todo := &models.Todo{ID: "todo-1", Title: title, CreatedAt: created, UpdatedAt: created.Add(time.Hour)}
+	if err := todo.SetDueDate(due); err != nil {
+		t.Fatal(err)
+	}
+	return todo
+}
+
+// encode returns the content lines of c
+func encode(t *testing.T, c *ical.Component) []string {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := ical.NewEncoder(&buf)
+	if err := enc.Encode(c); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n")
+}
+
+// roundTrip writes todos as a calendar of VTODOs and decodes it
+func roundTrip(t *testing.T, todos ...*models.Todo) *ical.Component {
+	t.Helper()
+	var buf bytes.Buffer
+	w := ical.NewWriter(&buf, ical.WriterOptions{})
+	for _, todo := range todos {
+		if err := w.Write(todo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	calendar, err := ical.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return calendar
+}
+
+func TestPriorityMapping(t *testing.T) {
+	// High todo priorities are low iCalendar ones, and back again
+	for priority, want := range []int{0, 9, 7, 5, 3, 1} {
+		got := ical.ToPriority(priority)
+		if got != want {
+			t.Errorf("ToPriority(%d) = %d, want %d", priority, got, want)
+		}
+		if back := ical.FromPriority(got); back != priority {
+			t.Errorf("FromPriority(%d) = %d, want %d", got, back, priority)
+		}
+	}
+	for _, priority := range []int{-1, 6} {
+		if got := ical.ToPriority(priority); got != 0 {
+			t.Errorf("ToPriority(%d) = %d, want 0", priority, got)
+		}
+	}
+
+	// Every iCalendar priority lands in RFC 5545's high, medium or low band
+	tests := map[int]int{-1: 0, 0: 0, 1: 5, 2: 5, 3: 4, 4: 4, 5: 3, 6: 2, 7: 2, 8: 1, 9: 1, 10: 0}
+	for priority, want := range tests {
+		if got := ical.FromPriority(priority); got != want {
+			t.Errorf("FromPriority(%d) = %d, want %d", priority, got, want)
+		}
+	}
+}
+
+func TestTodoComponent(t *testing.T) {
+	timed := newTodo(t, "Pack books", "2030-04-20T09:30:00Z")
+	timed.Priority = 2
+	timed.Tags = []string{"boxes", "home"}
+	timed.Project = "Move"
+	timed.ParentID = "todo-0"
+
+	allDay := newTodo(t, "Move house", "2030-05-01")
+	allDay.Priority = 5
+	allDay.Completed = true
+
+	tests := []struct {
+		name      string
+		component *ical.Component
+		want      []string
+	}{
+		{
+			name:      "timed vtodo",
+			component: ical.TodoComponent(timed),
+			want: []string{
+				"BEGIN:VTODO",
+				"UID:todo-1",
+				"DTSTAMP:20300401T090000Z",
+				"CREATED:20300401T080000Z",
+				"LAST-MODIFIED:20300401T090000Z",
+				"SUMMARY:Pack books",
+				"CATEGORIES:boxes,home",
+				"PRIORITY:7",
+				"X-TODO-PROJECT:Move",
+				"RELATED-TO:todo-0",
+				"DUE:20300420T093000Z",
+				"STATUS:NEEDS-ACTION",
+				"END:VTODO",
+			},
+		},
+		{
+			name:      "completed all-day vtodo",
+			component: ical.TodoComponent(allDay),
+			want: []string{
+				"BEGIN:VTODO",
+				"UID:todo-1",
+				"DTSTAMP:20300401T090000Z",
+				"CREATED:20300401T080000Z",
+				"LAST-MODIFIED:20300401T090000Z",
+				"SUMMARY:Move house",
+				"PRIORITY:1",
+				"DUE;VALUE=DATE:20300501",
+				"STATUS:COMPLETED",
+				"PERCENT-COMPLETE:100",
+				"END:VTODO",
+			},
+		},
+		{
+			name:      "all-day vevent",
+			component: ical.EventComponent(allDay),
+			want: []string{
+				"BEGIN:VEVENT",
+				"UID:todo-1",
+				"DTSTAMP:20300401T090000Z",
+				"CREATED:20300401T080000Z",
+				"LAST-MODIFIED:20300401T090000Z",
+				"SUMMARY:Move house",
+				"PRIORITY:1",
+				"DTSTART;VALUE=DATE:20300501",
+				"TRANSP:TRANSPARENT",
+				"END:VEVENT",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := encode(t, tt.component); !slices.Equal(got, tt.want) {
+				t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+
+	if event := ical.EventComponent(newTodo(t, "Someday", "")); event != nil {
+		t.Errorf("event for a todo without a due date: %v", encode(t, event))
+	}
+}
+
+func TestReadTodoRoundTrip(t *testing.T) {
+	timed := newTodo(t, "Pack books", "2030-04-20T09:30:00Z")
+	timed.Description = "Fragile, first; then the rest"
+	timed.Priority = 4
+	timed.Tags = []string{"boxes", "a,b"}
+	timed.Project = "Move"
+	timed.ParentID = "todo-0"
+
+	allDay := newTodo(t, "Move house", "2030-05-01")
+	allDay.ID = "todo-2"
+	allDay.Completed = true
+
+	calendar := roundTrip(t, timed, allDay)
+	if len(calendar.Children) != 2 {
+		t.Fatalf("decoded %d components, want 2", len(calendar.Children))
+	}
+
+	want := []models.TodoReplace{
+		{
+			ID:          "todo-1",
+			Title:       "Pack books",
+			Description: "Fragile, first; then the rest",
+			Project:     "Move",
+			ParentID:    "todo-0",
+			Tags:        []string{"boxes", "a,b"},
+			Priority:    4,
+			DueDate:     "2030-04-20T09:30:00Z",
+		},
+		{ID: "todo-2", Title: "Move house", DueDate: "2030-05-01", Completed: true},
+	}
+	for i, c := range calendar.Children {
+		got, errs := ical.ReadTodo(c)
+		if len(errs) > 0 {
+			t.Fatalf("read %s: %v", c.Name, errs)
+		}
+		if got.ID != want[i].ID || got.Title != want[i].Title || got.Description != want[i].Description ||
+			got.Project != want[i].Project || got.ParentID != want[i].ParentID || !slices.Equal(got.Tags, want[i].Tags) ||
+			got.Priority != want[i].Priority || got.DueDate != want[i].DueDate || got.Completed != want[i].Completed {
+			t.Errorf("read %+v, want %+v", got, want[i])
+		}
+	}
+}
+
+func TestReadTodoStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		props     [][2]string
+		completed bool
+	}{
+		{"needs action", [][2]string{{"STATUS", "NEEDS-ACTION"}}, false},
+		{"in process", [][2]string{{"STATUS", "IN-PROCESS"}, {"PERCENT-COMPLETE", "50"}}, false},
+		{"status", [][2]string{{"STATUS", "completed"}}, true},
+		{"completed date", [][2]string{{"COMPLETED", "20300401T100000Z"}}, true},
+		{"percent complete", [][2]string{{"PERCENT-COMPLETE", "100"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := ical.NewComponent(ical.Todo)
+			c.Add("SUMMARY", "Call")
+			for _, prop := range tt.props {
+				c.Add(prop[0], prop[1])
+			}
+			todo, errs := ical.ReadTodo(c)
+			if len(errs) > 0 {
+				t.Fatal(errs)
+			}
+			if todo.Completed != tt.completed {
+				t.Errorf("completed = %v, want %v", todo.Completed, tt.completed)
+			}
+		})
+	}
+}
+
+func TestReadTodoRejectsBadValues(t *testing.T) {
+	c := ical.NewComponent(ical.Todo)
+	c.Add("SUMMARY", "Call")
+	c.Add("PRIORITY", "10")
+	c.Add("DUE", "2030-05-01")
+
+	_, errs := ical.ReadTodo(c)
+	var fields []string
+	for _, err := range errs {
+		fields = append(fields, err.Field)
+	}
+	if !slices.Equal(fields, []string{"priority", "due_date"}) {
+		t.Errorf("errors %v, want priority and due_date", errs)
+	}
+}
+
+func TestDateTimes(t *testing.T) {
+	tests := []struct {
+		value  string
+		params map[string][]string
+		want   time.Time
+	}{
+		{"20300501", map[string][]string{"VALUE": {"DATE"}}, time.Date(2030, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{"20300501", nil, time.Date(2030, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{"20300501T093000Z", nil, time.Date(2030, 5, 1, 9, 30, 0, 0, time.UTC)},
+		{"20300501T093000", nil, time.Date(2030, 5, 1, 9, 30, 0, 0, time.UTC)},
+		{"20300501T093000", map[string][]string{"TZID": {"Europe/Berlin"}}, time.Date(2030, 5, 1, 7, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		prop := &ical.Property{Name: "DUE", Value: tt.value, Params: tt.params}
+		got, err := ical.ParseDateTime(prop)
+		if err != nil {
+			t.Errorf("%s %v: %v", tt.value, tt.params, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("%s %v = %v, want %v", tt.value, tt.params, got, tt.want)
+		}
+	}
+
+	bad := &ical.Property{Name: "DUE", Value: "20300501T093000", Params: map[string][]string{"TZID": {"Mars/Olympus"}}}
+	if _, err := ical.ParseDateTime(bad); err == nil {
+		t.Error("parsed a time in an unknown zone")
+	}
+
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	if got := ical.FormatDateTime(time.Date(2030, 5, 1, 9, 30, 0, 0, berlin)); got != "20300501T073000Z" {
+		t.Errorf("FormatDateTime = %s", got)
+	}
+}
+
+func TestTextIsEscaped(t *testing.T) {
+	c := ical.NewComponent(ical.Todo)
+	c.AddText("SUMMARY", `a, b; c\d`+"\r\nnext")
+	c.AddTextList("CATEGORIES", []string{"x,y", "z;"})
+
+	got := encode(t, c)
+	want := []string{
+		"BEGIN:VTODO",
+		`SUMMARY:a\, b\; c\\d\nnext`,
+		`CATEGORIES:x\,y,z\;`,
+		"END:VTODO",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	if summary := c.Get("SUMMARY").Text(); summary != `a, b; c\d`+"\nnext" {
+		t.Errorf("summary = %q", summary)
+	}
+	if categories := c.TextList("CATEGORIES"); !slices.Equal(categories, []string{"x,y", "z;"}) {
+		t.Errorf("categories = %q", categories)
+	}
+}
+
+func TestLongLinesAreFolded(t *testing.T) {
+	// Multibyte characters straddle the points a fold would fall on
+	title := strings.Repeat("Zahnarzt – Müller, ", 12)
+	todo := newTodo(t, title, "")
+
+	var buf bytes.Buffer
+	w := ical.NewWriter(&buf, ical.WriterOptions{Name: strings.Repeat("ä", 50)})
+	if err := w.Write(todo); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	folds := 0
+	for i, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line %d is %d octets", i+1, len(line))
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("line %d splits a character: %q", i+1, line)
+		}
+		if strings.HasPrefix(line, " ") {
+			folds++
+		}
+	}
+	if folds == 0 {
+		t.Fatal("nothing was folded")
+	}
+
+	calendar, err := ical.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := calendar.Get("X-WR-CALNAME").Text(); name != strings.Repeat("ä", 50) {
+		t.Errorf("calendar name = %q", name)
+	}
+	if summary := calendar.Children[0].Get("SUMMARY").Text(); summary != title {
+		t.Errorf("summary = %q, want %q", summary, title)
+	}
+}
+
+func TestDecodeRejectsMalformedCalendars(t *testing.T) {
+	tests := map[string]string{
+		"no calendar": "BEGIN:VTODO\r\nEND:VTODO\r\n",
+		"not closed":  "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nEND:VTODO\r\n",
+		"mismatched":  "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+		"no colon":    "BEGIN:VCALENDAR\r\nSUMMARY\r\nEND:VCALENDAR\r\n",
+		"outside":     "SUMMARY:x\r\nBEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n",
+		"empty":       "",
+		"blank lines": "\r\n\r\n",
+	}
+	for name, data := range tests {
+		_, err := ical.Decode(strings.NewReader(data))
+		if _, ok := err.(*ical.SyntaxError); !ok {
+			t.Errorf("%s: error %v, want a SyntaxError", name, err)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package ical
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+// PropProject carries the project of a todo, which iCalendar has no
+// property for
+const PropProject = "X-TODO-PROJECT"
+
+// Value formats
+const (
+	dateTimeFormat  = "20060102T150405Z"
+	localTimeFormat = "20060102T150405"
+	dateFormat      = "20060102"
+)
+
+// VTODO statuses
+const (
+	statusCompleted   = "COMPLETED"
+	statusNeedsAction = "NEEDS-ACTION"
+)
+
+// completedPercent is the PERCENT-COMPLETE of a completed VTODO
+const completedPercent = "100"
+
+// maxICalPriority is the lowest iCalendar priority
+const maxICalPriority = 9
+
+// icalPriorities maps the priorities of todos, from 0 (none) and 1 (low) to
+// 5 (high), onto iCalendar's, where 0 is undefined, 1 the highest and 9
+// the lowest
+var icalPriorities = [...]int{0, 9, 7, 5, 3, 1}
+
+// ToPriority converts the priority of a todo to an iCalendar PRIORITY
+func ToPriority(priority int) int {
+	if priority < 0 || priority >= len(icalPriorities) {
+		return 0
+	}
+	return icalPriorities[priority]
+}
+
+// FromPriority converts an iCalendar PRIORITY to the priority of a todo,
+// following RFC 5545's grouping of 1-4 as high, 5 as medium and 6-9 as low
+func FromPriority(priority int) int {
+	switch {
+	case priority <= 0 || priority > maxICalPriority:
+		return 0
+	case priority <= 2:
+		return 5
+	case priority <= 4:
+		return 4
+	case priority == 5:
+		return 3
+	case priority <= 7:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// TodoComponent returns the VTODO representing todo
+func TodoComponent(todo *models.Todo) *Component {
+	c := newTodoComponent(Todo, todo)
+	if todo.DueDate.Valid {
+		addDue(c, "DUE", todo)
+	}
+	if todo.Completed {
+		c.Add("STATUS", statusCompleted)
+		c.Add("PERCENT-COMPLETE", completedPercent)
+	} else {
+		c.Add("STATUS", statusNeedsAction)
+	}
+	return c
+}
+
+// EventComponent returns a VEVENT placing todo at its due date, or nil if
+// it has none. The event has no duration, or lasts the day for all-day
+// todos, and does not make anyone busy.
+func EventComponent(todo *models.Todo) *Component {
+	if !todo.DueDate.Valid {
+		return nil
+	}
+	c := newTodoComponent(Event, todo)
+	addDue(c, "DTSTART", todo)
+	c.Add("TRANSP", "TRANSPARENT")
+	return c
+}
+
+// addDue appends the due date of todo as the property name: a DATE for
+// all-day todos and a UTC DATE-TIME otherwise
+func addDue(c *Component, name string, todo *models.Todo) {
+	if todo.AllDay {
+		prop := c.Add(name, todo.DueDate.Time.UTC().Format(dateFormat))
+		prop.Params = map[string][]string{"VALUE": {"DATE"}}
+		return
+	}
+	c.Add(name, FormatDateTime(todo.DueDate.Time))
+}
+
+// newTodoComponent returns a component called name with the properties
+// VTODOs and VEVENTs share
+func newTodoComponent(name string, todo *models.Todo) *Component {
+	c := NewComponent(name)
+	c.AddText("UID", todo.ID)
+	c.Add("DTSTAMP", FormatDateTime(todo.UpdatedAt))
+	c.Add("CREATED", FormatDateTime(todo.CreatedAt))
+	c.Add("LAST-MODIFIED", FormatDateTime(todo.UpdatedAt))
+	c.AddText("SUMMARY", todo.Title)
+	if todo.Description != "" {
+		c.AddText("DESCRIPTION", todo.Description)
+	}
+	if len(todo.Tags) > 0 {
+		c.AddTextList("CATEGORIES", todo.Tags)
+	}
+	if todo.Priority != 0 {
+		c.Add("PRIORITY", strconv.Itoa(ToPriority(todo.Priority)))
+	}
+	if todo.Project != "" {
+		c.AddText(PropProject, todo.Project)
+	}
+	if todo.ParentID != "" {
+		c.AddText("RELATED-TO", todo.ParentID)
+	}
+	return c
+}
+
+// ReadTodo reads a todo from a VTODO, returning the fields whose value
+// could not be read. UID becomes the ID and a RELATED-TO parent the
+// parent_id, and a DUE date rather than date-time makes it all-day. The
+// todo is completed if its STATUS is COMPLETED, it has a COMPLETED date or
+// PERCENT-COMPLETE is 100.
+func ReadTodo(c *Component) (models.TodoReplace, []models.FieldError) {
+	var todo models.TodoReplace
+	var errs []models.FieldError
+	fail := func(field, message string) {
+		errs = append(errs, models.FieldError{Field: field, Message: message})
+	}
+
+	if prop := c.Get("UID"); prop != nil {
+		todo.ID = strings.TrimSpace(prop.Text())
+	}
+	if prop := c.Get("SUMMARY"); prop != nil {
+		todo.Title = prop.Text()
+	}
+	if prop := c.Get("DESCRIPTION"); prop != nil {
+		todo.Description = prop.Text()
+	}
+	if prop := c.Get(PropProject); prop != nil {
+		todo.Project = prop.Text()
+	}
+	for _, tag := range c.TextList("CATEGORIES") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			todo.Tags = append(todo.Tags, tag)
+		}
+	}
+	for _, prop := range c.GetAll("RELATED-TO") {
+		if reltype := strings.ToUpper(prop.Param("RELTYPE")); reltype == "" || reltype == "PARENT" {
+			todo.ParentID = strings.TrimSpace(prop.Text())
+			break
+		}
+	}
+
+	if prop := c.Get("PRIORITY"); prop != nil {
+		priority, err := strconv.Atoi(strings.TrimSpace(prop.Value))
+		if err != nil || priority < 0 || priority > maxICalPriority {
+			fail("priority", fmt.Sprintf("PRIORITY must be an integer from 0 to %d", maxICalPriority))
+		} else {
+			todo.Priority = FromPriority(priority)
+		}
+	}
+
+	if prop := c.Get("DUE"); prop != nil {
+		due, err := ParseDateTime(prop)
+		switch {
+		case err != nil:
+			fail("due_date", fmt.Sprintf("DUE: %v", err))
+		case isDate(prop):
+			todo.DueDate = due.Format(models.DateLayout)
+		default:
+			todo.DueDate = due.Format(time.RFC3339)
+		}
+	}
+
+	if prop := c.Get("STATUS"); prop != nil && strings.EqualFold(prop.Value, statusCompleted) {
+		todo.Completed = true
+	}
+	if c.Get("COMPLETED") != nil {
+		todo.Completed = true
+	}
+	if prop := c.Get("PERCENT-COMPLETE"); prop != nil && strings.TrimSpace(prop.Value) == completedPercent {
+		todo.Completed = true
+	}
+
+	return todo, errs
+}
+
+// FormatDateTime formats t as a UTC DATE-TIME value
+func FormatDateTime(t time.Time) string {
+	return t.UTC().Format(dateTimeFormat)
+}
+
+// ParseDateTime reads a DATE or DATE-TIME value. Times in UTC or with a
+// TZID naming an IANA time zone are read as such; floating times, which
+// have neither, are taken as UTC. Dates are read as midnight UTC.
+func ParseDateTime(prop *Property) (time.Time, error) {
+	value := strings.TrimSpace(prop.Value)
+
+	if isDate(prop) {
+		t, err := time.Parse(dateFormat, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%q is not a date", value)
+		}
+		return t, nil
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(dateTimeFormat, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%q is not a date-time", value)
+		}
+		return t, nil
+	}
+
+	loc := time.UTC
+	if tzid := prop.Param("TZID"); tzid != "" {
+		var err error
+		if loc, err = time.LoadLocation(tzid); err != nil {
+			return time.Time{}, fmt.Errorf("unknown time zone %q", tzid)
+		}
+	}
+	t, err := time.ParseInLocation(localTimeFormat, value, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a date-time", value)
+	}
+	return t, nil
+}
+
+// isDate reports whether prop holds a DATE rather than a DATE-TIME
+func isDate(prop *Property) bool {
+	return strings.EqualFold(prop.Param("VALUE"), "DATE") || len(strings.TrimSpace(prop.Value)) == len(dateFormat)
+}
@@ -0,0 +1,115 @@
+package ical
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+// prodID identifies the API as the producer of its calendars
+const prodID = "-//go-todo-api//Todos//EN"
+
+// WriterOptions controls the calendar a Writer produces
+type WriterOptions struct {
+	// Name is the name calendar apps show for the calendar, if not empty
+	Name string
+	// Method is the METHOD of the calendar, such as PUBLISH for a feed. It
+	// is omitted if empty, as CalDAV requires of stored calendar objects.
+	Method string
+	// Events writes todos as VEVENTs at their due date rather than as
+	// VTODOs, skipping todos without one
+	Events bool
+	// RefreshInterval suggests how often subscribers poll the calendar;
+	// zero leaves it to them
+	RefreshInterval time.Duration
+}
+
+// Writer streams todos as the components of a calendar. Close must be
+// called once they are all written to finish it.
+type Writer struct {
+	enc     *Encoder
+	opts    WriterOptions
+	started bool
+}
+
+// NewWriter returns a Writer producing a calendar on w
+func NewWriter(w io.Writer, opts WriterOptions) *Writer {
+	return &Writer{enc: NewEncoder(w), opts: opts}
+}
+
+// Write adds todo to the calendar
+func (w *Writer) Write(todo *models.Todo) error {
+	if err := w.start(); err != nil {
+		return err
+	}
+
+	component := TodoComponent(todo)
+	if w.opts.Events {
+		if component = EventComponent(todo); component == nil {
+			return nil
+		}
+	}
+	return w.enc.Encode(component)
+}
+
+// Close ends the calendar and flushes it
+func (w *Writer) Close() error {
+	if err := w.start(); err != nil {
+		return err
+	}
+	if err := w.enc.End(Calendar); err != nil {
+		return err
+	}
+	return w.enc.Flush()
+}
+
+// start writes the properties of the calendar before its first component
+func (w *Writer) start() error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+
+	calendar := NewComponent(Calendar)
+	calendar.Add("VERSION", "2.0")
+	calendar.Add("PRODID", prodID)
+	calendar.Add("CALSCALE", "GREGORIAN")
+	if w.opts.Method != "" {
+		calendar.Add("METHOD", w.opts.Method)
+	}
+	if w.opts.Name != "" {
+		calendar.AddText("X-WR-CALNAME", w.opts.Name)
+		calendar.AddText("NAME", w.opts.Name)
+	}
+	if w.opts.RefreshInterval > 0 {
+		interval := formatDuration(w.opts.RefreshInterval)
+		refresh := calendar.Add("REFRESH-INTERVAL", interval)
+		refresh.Params = map[string][]string{"VALUE": {"DURATION"}}
+		// The property clients understood before REFRESH-INTERVAL existed
+		calendar.Add("X-PUBLISHED-TTL", interval)
+	}
+
+	if err := w.enc.Begin(Calendar); err != nil {
+		return err
+	}
+	for _, prop := range calendar.Properties {
+		if err := w.enc.Property(prop); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatDuration formats d as a DURATION value, to the second
+func formatDuration(d time.Duration) string {
+	seconds := int64(d / time.Second)
+	if seconds%3600 == 0 {
+		return fmt.Sprintf("PT%dH", seconds/3600)
+	}
+	if seconds%60 == 0 {
+		return fmt.Sprintf("PT%dM", seconds/60)
+	}
+	return fmt.Sprintf("PT%dS", seconds)
+}
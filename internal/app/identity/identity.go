@@ -0,0 +1,24 @@
+// Package identity carries the acting user through request contexts.
+//
+// The API has no authentication of its own; callers identify themselves with
+// the X-User-ID header (typically set by a gateway), and the identity is only
+// used to attribute changes and filter change notifications.
+package identity
+
+import "context"
+
+// UserHeader is the request header naming the acting user
+const UserHeader = "X-User-ID"
+
+type contextKey struct{}
+
+// WithUser returns a copy of ctx carrying userID
+func WithUser(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, userID)
+}
+
+// User returns the user carried by ctx, or "" if there is none
+func User(ctx context.Context) string {
+	userID, _ := ctx.Value(contextKey{}).(string)
+	return userID
+}
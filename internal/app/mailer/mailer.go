@@ -0,0 +1,205 @@
+// Package mailer sends email through an SMTP relay, for reminders, digests
+// and anything else the server mails.
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Options configures a Mailer
+type Options struct {
+	// Addr is the host:port of the relay
+	Addr string
+	// Username and Password log in with PLAIN authentication when Username
+	// is set. They are only sent over TLS, or to a relay on localhost.
+	Username string
+	Password string
+	// From is the sender, as an address or with a name: Todo <todo@example.com>
+	From string
+	// Timeout bounds each message, from connecting to quitting (default 30s)
+	Timeout time.Duration
+}
+
+// Message is an email to a single recipient. Text is required; when HTML
+// is set too the message carries both as alternatives.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+	// ID, when set, makes the Message-ID stable, so that retries of the same
+	// message can be recognised by the recipient's mail client
+	ID string
+}
+
+// Mailer sends messages through an SMTP relay, one connection per message.
+// STARTTLS is used whenever the relay offers it.
+type Mailer struct {
+	opts   Options
+	host   string
+	sender string
+}
+
+// New creates a Mailer, checking the address of the relay and the sender
+func New(opts Options) (*Mailer, error) {
+	host, _, err := net.SplitHostPort(opts.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP address %q: %w", opts.Addr, err)
+	}
+	from, err := mail.ParseAddress(opts.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP sender %q: %w", opts.From, err)
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	opts.From = from.String()
+
+	return &Mailer{
+		opts:   opts,
+		host:   host,
+		sender: from.Address,
+	}, nil
+}
+
+// Send delivers msg to the relay
+func (m *Mailer) Send(ctx context.Context, msg *Message) error {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient: %w", err)
+	}
+	data, err := m.render(to, msg)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.opts.Timeout)
+	defer cancel()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", m.opts.Addr)
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+	if m.opts.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.opts.Username, m.opts.Password, m.host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(m.sender); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// render writes the headers and body of msg, quoted-printable, as a
+// multipart/alternative message when it has HTML
+func (m *Mailer) render(to *mail.Address, msg *Message) ([]byte, error) {
+	id := msg.ID
+	if id == "" {
+		id = randomID()
+	}
+
+	var b bytes.Buffer
+	headers := [][2]string{
+		{"From", m.opts.From},
+		{"To", to.String()},
+		{"Subject", mime.QEncoding.Encode("utf-8", msg.Subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", fmt.Sprintf("<%s@%s>", id, m.host)},
+		{"MIME-Version", "1.0"},
+	}
+	for _, header := range headers {
+		fmt.Fprintf(&b, "%s: %s\r\n", header[0], header[1])
+	}
+
+	if msg.HTML == "" {
+		b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		if err := writeQuoted(&b, msg.Text); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(&b)
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", parts.Boundary())
+	// Clients show the last alternative they understand, so HTML goes last
+	for _, alternative := range [][2]string{{"text/plain", msg.Text}, {"text/html", msg.HTML}} {
+		part, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {alternative[0] + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuoted(part, alternative[1]); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// writeQuoted writes text quoted-printable with CRLF line endings
+func writeQuoted(w io.Writer, text string) error {
+	text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\n", "\r\n")
+	if !strings.HasSuffix(text, "\r\n") {
+		text += "\r\n"
+	}
+	body := quotedprintable.NewWriter(w)
+	if _, err := body.Write([]byte(text)); err != nil {
+		return err
+	}
+	return body.Close()
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,188 @@
+// Package smtptest provides an SMTP server on localhost for tests, as
+// net/http/httptest does for HTTP. It accepts every message and keeps it,
+// so tests can check what was sent:
+//
+//	srv := smtptest.NewServer()
+//	defer srv.Close()
+//	m, _ := mailer.New(mailer.Options{Addr: srv.Addr, From: "todo@example.com"})
+//	...
+//	msgs := srv.Messages()
+package smtptest
+
+import (
+	"bytes"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+// Message is a message received by the server
+type Message struct {
+	From string
+	To   []string
+	// Data is the message as sent, headers and body, with CRLF line endings
+	Data []byte
+}
+
+// Server is an SMTP server listening on a port of localhost. It offers
+// neither STARTTLS nor real authentication: any credentials are accepted.
+type Server struct {
+	// Addr is the host:port the server listens on
+	Addr string
+
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mu       sync.Mutex
+	conns    map[net.Conn]bool
+	messages []Message
+	reject   string
+}
+
+// NewServer starts a Server, panicking if it cannot listen
+func NewServer() *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("smtptest: failed to listen: " + err.Error())
+	}
+	s := &Server{
+		Addr:     listener.Addr().String(),
+		listener: listener,
+		conns:    make(map[net.Conn]bool),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Messages returns the messages received so far, oldest first
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// Reject makes the server refuse recipients with a 550 reply carrying
+// reason, as servers do for unknown mailboxes, until it is called with ""
+func (s *Server) Reject(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reject = reason
+}
+
+// Close stops the server, closing the connections still open
+func (s *Server) Close() {
+	s.listener.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = true
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(textproto.NewConn(conn))
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+// handle speaks SMTP on conn until the client quits or goes away
+func (s *Server) handle(conn *textproto.Conn) {
+	var msg Message
+	reply := func(code int, text string) bool {
+		return conn.PrintfLine("%d %s", code, text) == nil
+	}
+
+	if !reply(220, "smtptest ESMTP ready") {
+		return
+	}
+	for {
+		line, err := conn.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		ok := true
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			ok = conn.PrintfLine("250-smtptest") == nil && reply(250, "AUTH PLAIN")
+		case "HELO", "NOOP":
+			ok = reply(250, "OK")
+		case "AUTH":
+			ok = reply(235, "Authentication succeeded")
+		case "MAIL":
+			msg = Message{From: address(arg)}
+			ok = reply(250, "OK")
+		case "RCPT":
+			s.mu.Lock()
+			reason := s.reject
+			s.mu.Unlock()
+			if reason != "" {
+				ok = reply(550, reason)
+				break
+			}
+			msg.To = append(msg.To, address(arg))
+			ok = reply(250, "OK")
+		case "DATA":
+			if len(msg.To) == 0 {
+				ok = reply(503, "no recipients")
+				break
+			}
+			if !reply(354, "end data with <CR><LF>.<CR><LF>") {
+				return
+			}
+			data, err := conn.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.Data = crlf(data)
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			msg = Message{}
+			ok = reply(250, "OK")
+		case "RSET":
+			msg = Message{}
+			ok = reply(250, "OK")
+		case "QUIT":
+			reply(221, "bye")
+			return
+		default:
+			ok = reply(502, "command not implemented")
+		}
+		if !ok {
+			return
+		}
+	}
+}
+
+// address returns the address in the argument of MAIL or RCPT, such as
+// FROM:<someone@example.com>
+func address(arg string) string {
+	_, addr, _ := strings.Cut(arg, ":")
+	addr, _, _ = strings.Cut(strings.TrimSpace(addr), " ")
+	return strings.Trim(addr, "<>")
+}
+
+// crlf restores the CRLF line endings that ReadDotBytes turned into LF
+func crlf(data []byte) []byte {
+	return bytes.ReplaceAll(data, []byte("\n"), []byte("\r\n"))
+}
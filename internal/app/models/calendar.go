@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// CalendarToken is the secret a user's calendar apps present to read the
+// calendar feed. Stores keep only a hash of it, so the token itself is
+// only known when it is created.
+type CalendarToken struct {
+	UserID string `json:"user_id"`
+	// Token is only returned when the token is created
+	Token string `json:"token,omitempty"`
+	// FeedURL is the address to subscribe to, including the token. It is
+	// only returned when the token is created.
+	FeedURL   string    `json:"feed_url,omitempty"`
+	TokenHash string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
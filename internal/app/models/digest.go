@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Digest sections
+const (
+	DigestOverdue  = "overdue"
+	DigestToday    = "today"
+	DigestThisWeek = "this_week"
+)
+
+// Digest summarises the open todos of a user that are overdue or due within
+// the week, each section grouped by project. Days are counted in Timezone.
+type Digest struct {
+	UserID      string    `json:"user_id,omitempty"`
+	Timezone    string    `json:"timezone"`
+	GeneratedAt time.Time `json:"generated_at"`
+	// Sections are always overdue, today and this_week, in that order
+	Sections []*DigestSection `json:"sections"`
+	// Total counts the todos in every section
+	Total int `json:"total"`
+}
+
+// DigestSection is one part of a digest, such as the overdue todos
+type DigestSection struct {
+	Name  string `json:"name"`
+	Title string `json:"title"`
+	// Projects are sorted by name, with todos outside any project first
+	Projects []*DigestProject `json:"projects"`
+	Count    int              `json:"count"`
+}
+
+// DigestProject lists the todos of a project in a digest section, soonest
+// due first
+type DigestProject struct {
+	Project string  `json:"project"`
+	Todos   []*Todo `json:"todos"`
+}
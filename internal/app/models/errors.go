@@ -0,0 +1,90 @@
+package models
+
+import (
+	"errors"
+	"strings"
+)
+
+// Domain errors returned by services and repositories. Callers should test
+// for them with errors.Is, since they are usually wrapped with context.
+var (
+	// ErrNotFound reports that the requested todo does not exist
+	ErrNotFound = errors.New("todo not found")
+	// ErrConflict reports that a write clashes with existing state, such as a duplicate ID
+	ErrConflict = errors.New("conflict")
+	// ErrValidation is matched by every *ValidationError
+	ErrValidation = errors.New("validation failed")
+	// ErrUnauthorized reports missing or invalid credentials
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// ErrWebhookNotFound reports that the requested webhook or delivery does
+	// not exist. It matches ErrNotFound.
+	ErrWebhookNotFound error = notFoundError("webhook not found")
+	// ErrCalendarTokenNotFound reports that the user has no calendar token.
+	// It matches ErrNotFound.
+	ErrCalendarTokenNotFound error = notFoundError("calendar token not found")
+	// ErrReminderNotFound reports that the requested reminder does not
+	// exist. It matches ErrNotFound.
+	ErrReminderNotFound error = notFoundError("reminder not found")
+
+	// ErrVersionMismatch reports that a todo is not in the state a write
+	// expected, because it changed since it was read. It matches ErrConflict.
+	ErrVersionMismatch error = conflictError("todo has changed")
+)
+
+// conflictError is a conflict that callers may want to tell apart
+type conflictError string
+
+// Error returns the message
+func (e conflictError) Error() string {
+	return string(e)
+}
+
+// Is makes every conflictError match ErrConflict
+func (e conflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// notFoundError is a not found error for resources other than todos
+type notFoundError string
+
+// Error returns the message
+func (e notFoundError) Error() string {
+	return string(e)
+}
+
+// Is makes every notFoundError match ErrNotFound
+func (e notFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// FieldError describes why a single input field was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError carries one or more field-level validation failures.
+// errors.Is(err, ErrValidation) reports true for it.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// NewValidationError creates a ValidationError for a single field
+func NewValidationError(field, message string) *ValidationError {
+	return &ValidationError{Fields: []FieldError{{Field: field, Message: message}}}
+}
+
+// Error joins the field messages
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		messages = append(messages, f.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Is makes every ValidationError match ErrValidation
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+// Todo event types
+const (
+	EventCreated = "created"
+	EventUpdated = "updated"
+	EventDeleted = "deleted"
+)
+
+// TodoEvent records a change to a todo. IDs are assigned by the outbox as
+// the change is written and double as resumption cursors. They increase,
+// but events need not be logged in ID order: a failed relay is retried
+// after later events, and concurrent transactions may commit out of order.
+type TodoEvent struct {
+	ID        int64     `json:"id"`
+	Type      string    `json:"type"`
+	TodoID    string    `json:"todo_id"`
+	Actor     string    `json:"actor,omitempty"`
+	Todo      *Todo     `json:"todo"`
+	CreatedAt time.Time `json:"created_at"`
+	// PreviousProject is set on updates moving the todo out of another
+	// project, so subscribers of that project learn it left
+	PreviousProject string `json:"previous_project,omitempty"`
+	// Position is the place of the event in the event log, which only
+	// grows, so streams compare positions rather than IDs to skip events
+	// they have already sent
+	Position int64 `json:"-"`
+}
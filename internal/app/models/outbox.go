@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// OutboxEntry is a todo event written to the transactional outbox together
+// with the change it describes, waiting to be relayed to every sink
+type OutboxEntry struct {
+	Event *TodoEvent
+	// Attempts counts the failed relays so far
+	Attempts  int
+	LastError string
+	// NextAttemptAt is when the entry may be relayed; later entries for the
+	// same todo wait for it
+	NextAttemptAt time.Time
+}
@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// Digest frequencies
+const (
+	DigestOff    = "off"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// Defaults of the preferences of a user who has not set them
+const (
+	DefaultTimezone   = "UTC"
+	DefaultDigestHour = 7
+	// DefaultDigestWeekday is the day weekly digests are sent on
+	DefaultDigestWeekday = "monday"
+)
+
+// Preferences are the settings of a user: the timezone their days are
+// counted in and the email digest of due todos they receive
+type Preferences struct {
+	UserID string `json:"user_id"`
+	// Email is where digests are sent
+	Email string `json:"email,omitempty"`
+	// Timezone is an IANA time zone such as Europe/Berlin
+	Timezone        string `json:"timezone"`
+	DigestFrequency string `json:"digest_frequency"`
+	// DigestHour is the hour of the day, in Timezone, digests are sent at
+	DigestHour int `json:"digest_hour"`
+	// DigestWeekday is the day weekly digests are sent on, such as monday
+	DigestWeekday string `json:"digest_weekday"`
+	// NextDigestAt is when the next digest is due; nil while digests are off
+	NextDigestAt *time.Time `json:"next_digest_at,omitempty"`
+	// LastDigestAt is when a digest was last sent
+	LastDigestAt *time.Time `json:"last_digest_at,omitempty"`
+	// LastDigestError is why the last attempt at a digest failed, if it did
+	LastDigestError string `json:"last_digest_error,omitempty"`
+	// UpdatedAt is when the preferences were set; nil for the defaults
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// PreferencesReplace represents the full preferences of a user sent with
+// PUT. Omitted fields are reset to their defaults.
+type PreferencesReplace struct {
+	Email           string  `json:"email,omitempty" validate:"trim,max=320"`
+	Timezone        string  `json:"timezone,omitempty" validate:"trim,max=64"`
+	DigestFrequency *string `json:"digest_frequency,omitempty" validate:"trim,oneof=off|daily|weekly"`
+	DigestHour      *int    `json:"digest_hour,omitempty" validate:"min=0,max=23"`
+	DigestWeekday   *string `json:"digest_weekday,omitempty" validate:"trim,oneof=sunday|monday|tuesday|wednesday|thursday|friday|saturday"`
+}
+
+// NewPreferences returns the default preferences of a user
+func NewPreferences(userID string) *Preferences {
+	return &Preferences{
+		UserID:          userID,
+		Timezone:        DefaultTimezone,
+		DigestFrequency: DigestOff,
+		DigestHour:      DefaultDigestHour,
+		DigestWeekday:   DefaultDigestWeekday,
+	}
+}
@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+// Reminder channels, naming the notifier that sends a reminder
+const (
+	// ChannelLog writes the reminder to the server log
+	ChannelLog = "log"
+	// ChannelWebhook POSTs the reminder as JSON to the recipient URL
+	ChannelWebhook = "webhook"
+	// ChannelEmail mails the reminder to the recipient address
+	ChannelEmail = "email"
+)
+
+// Reminder statuses
+const (
+	ReminderPending = "pending"
+	ReminderSent    = "sent"
+	// ReminderFailed means every attempt failed
+	ReminderFailed = "failed"
+	// ReminderSkipped means the todo was completed or deleted by the time
+	// the reminder was due
+	ReminderSkipped = "skipped"
+)
+
+// Reminder notifies someone of a todo at a set time: either At, or Before
+// its due date. Reminders relative to the due date follow it as it moves.
+type Reminder struct {
+	ID     string `json:"id"`
+	TodoID string `json:"todo_id"`
+	// UserID is who set the reminder; the due dates of all-day todos start
+	// at midnight in their time zone
+	UserID string `json:"user_id,omitempty"`
+	// At is when an absolute reminder fires
+	At *time.Time `json:"at,omitempty"`
+	// Before is how long before the due date a relative reminder fires,
+	// as a duration such as "30m" or "24h"
+	Before    string `json:"before,omitempty"`
+	Channel   string `json:"channel"`
+	Recipient string `json:"recipient,omitempty"`
+	Status    string `json:"status"`
+	// FireAt is when the reminder is due; it is empty while a relative
+	// reminder's todo has no due date
+	FireAt   *time.Time `json:"fire_at,omitempty"`
+	Attempts int        `json:"attempts"`
+	// NextAttemptAt is when a pending reminder is tried next, otherwise
+	// when it was last tried
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// ReminderCreate represents the data needed to create a reminder. Exactly
+// one of At and Before must be given. Email reminders need an address as
+// their recipient and webhook reminders a URL; log reminders need none.
+type ReminderCreate struct {
+	At        string `json:"at,omitempty" validate:"trim,rfc3339"`
+	Before    string `json:"before,omitempty" validate:"trim"`
+	Channel   string `json:"channel" validate:"trim,required,oneof=log|webhook|email"`
+	Recipient string `json:"recipient,omitempty" validate:"trim,max=2000"`
+}
@@ -2,72 +2,180 @@ package models
 
 import (
 	"database/sql"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-// Todo represents a todo item
+// Todo represents a todo item. ParentID names the todo it is a subtask of;
+// subtasks cannot have subtasks of their own. Version starts at 1 and is
+// incremented by every change, so it tells whether a copy is current.
+//
+// An all-day todo is due on a date rather than at an instant, whatever the
+// time zone: its DueDate is midnight UTC of that date and its due_date is
+// just the date, such as 2026-10-23.
 type Todo struct {
 	ID          string       `json:"id"`
 	Title       string       `json:"title"`
 	Description string       `json:"description"`
+	Project     string       `json:"project"`
+	ParentID    string       `json:"parent_id,omitempty"`
+	Tags        []string     `json:"tags"`
 	Completed   bool         `json:"completed"`
 	Priority    int          `json:"priority"`
 	DueDate     sql.NullTime `json:"-"`
 	DueDateStr  string       `json:"due_date,omitempty"`
+	AllDay      bool         `json:"all_day"`
 	CreatedAt   time.Time    `json:"created_at"`
 	UpdatedAt   time.Time    `json:"updated_at"`
+	Version     int64        `json:"version"`
 }
 
 // TodoCreate represents the data needed to create a new todo
 type TodoCreate struct {
-	Title       string `json:"title" validate:"required"`
-	Description string `json:"description"`
-	Priority    int    `json:"priority"`
-	DueDate     string `json:"due_date,omitempty"`
+	Title       string   `json:"title" validate:"trim,required,max=200"`
+	Description string   `json:"description" validate:"max=2000"`
+	Project     string   `json:"project" validate:"trim,max=100"`
+	ParentID    string   `json:"parent_id,omitempty" validate:"trim"`
+	Tags        []string `json:"tags,omitempty" validate:"max=20,trim,notblank,itemmax=50"`
+	Priority    int      `json:"priority" validate:"min=0,max=5"`
+	DueDate     string   `json:"due_date,omitempty" validate:"trim,duedate,maxpast=8760h"`
 }
 
-// TodoUpdate represents the data needed to update a todo
+// TodoUpdate represents the data needed to update a todo. An empty
+// ParentID detaches a subtask, and Tags replaces every tag.
 type TodoUpdate struct {
-	Title       *string `json:"title,omitempty"`
-	Description *string `json:"description,omitempty"`
-	Completed   *bool   `json:"completed,omitempty"`
-	Priority    *int    `json:"priority,omitempty"`
-	DueDate     *string `json:"due_date,omitempty"`
+	Title       *string   `json:"title,omitempty" validate:"trim,notblank,max=200"`
+	Description *string   `json:"description,omitempty" validate:"max=2000"`
+	Project     *string   `json:"project,omitempty" validate:"trim,max=100"`
+	ParentID    *string   `json:"parent_id,omitempty" validate:"trim"`
+	Tags        *[]string `json:"tags,omitempty" validate:"max=20,trim,notblank,itemmax=50"`
+	Completed   *bool     `json:"completed,omitempty"`
+	Priority    *int      `json:"priority,omitempty" validate:"min=0,max=5"`
+	DueDate     *string   `json:"due_date,omitempty" validate:"trim,duedate,maxpast=8760h"`
 }
 
+// TodoReplace represents the full state of a todo sent with PUT. Omitted
+// fields are reset to their defaults rather than left unchanged. Unlike on
+// create, due dates may lie any time in the past, so that synced and
+// imported todos can be written back as they are.
+type TodoReplace struct {
+	ID          string   `json:"id,omitempty"`
+	Title       string   `json:"title" validate:"trim,required,max=200"`
+	Description string   `json:"description" validate:"max=2000"`
+	Project     string   `json:"project" validate:"trim,max=100"`
+	ParentID    string   `json:"parent_id,omitempty" validate:"trim"`
+	Tags        []string `json:"tags" validate:"max=20,trim,notblank,itemmax=50"`
+	Completed   bool     `json:"completed"`
+	Priority    int      `json:"priority" validate:"min=0,max=5"`
+	DueDate     string   `json:"due_date,omitempty" validate:"trim,duedate"`
+}
+
+// DateLayout is the format of the due date of all-day todos
+const DateLayout = "2006-01-02"
+
 // NewTodo creates a new Todo with default values
 func NewTodo(create TodoCreate) (*Todo, error) {
+	now := time.Now().UTC()
 	todo := &Todo{
 		ID:          uuid.New().String(),
 		Title:       create.Title,
 		Description: create.Description,
+		Project:     create.Project,
+		ParentID:    create.ParentID,
+		Tags:        NormalizeTags(create.Tags),
 		Completed:   false,
 		Priority:    create.Priority,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
 	}
 
-	// Parse due date if provided
-	if create.DueDate != "" {
-		dueDate, err := time.Parse(time.RFC3339, create.DueDate)
-		if err != nil {
-			return nil, err
-		}
-		todo.DueDate = sql.NullTime{
-			Time:  dueDate,
-			Valid: true,
-		}
-		todo.DueDateStr = create.DueDate
+	if err := todo.SetDueDate(create.DueDate); err != nil {
+		return nil, err
 	}
 
 	return todo, nil
 }
 
+// ParseDueDate parses a due date: an RFC3339 timestamp, which is normalized
+// to UTC, or a date such as 2026-10-23 for an all-day todo. It returns a
+// ValidationError on bad input.
+func ParseDueDate(value string) (dueDate sql.NullTime, allDay bool, err error) {
+	if date, err := time.Parse(DateLayout, value); err == nil {
+		return sql.NullTime{Time: date, Valid: true}, true, nil
+	}
+	due, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return sql.NullTime{}, false, NewValidationError("due_date", "invalid due date format, expected an RFC3339 timestamp or a date such as 2006-01-02")
+	}
+	return sql.NullTime{Time: due.UTC(), Valid: true}, false, nil
+}
+
+// SetDueDate sets the due date of the todo from value, as ParseDueDate
+// reads it; "" clears it
+func (t *Todo) SetDueDate(value string) error {
+	if value == "" {
+		t.DueDate = sql.NullTime{}
+		t.AllDay = false
+		t.DueDateStr = ""
+		return nil
+	}
+
+	dueDate, allDay, err := ParseDueDate(value)
+	if err != nil {
+		return err
+	}
+	t.DueDate = dueDate
+	t.AllDay = allDay
+	t.FormatDates()
+	return nil
+}
+
+// NormalizeTags lowercases tags, drops repeats and sorts them, which is
+// the order stores return them in. The result is never nil.
+func NormalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
 // FormatDates formats the dates for JSON response
 func (t *Todo) FormatDates() {
-	if t.DueDate.Valid {
+	switch {
+	case !t.DueDate.Valid:
+		t.DueDateStr = ""
+	case t.AllDay:
+		t.DueDateStr = t.DueDate.Time.UTC().Format(DateLayout)
+	default:
 		t.DueDateStr = t.DueDate.Time.Format(time.RFC3339)
 	}
 }
+
+// In returns a copy of the todo with its times in loc, for responses. The
+// date of an all-day todo is the same in every zone.
+func (t *Todo) In(loc *time.Location) *Todo {
+	localized := *t
+	localized.CreatedAt = t.CreatedAt.In(loc)
+	localized.UpdatedAt = t.UpdatedAt.In(loc)
+	if t.DueDate.Valid && !t.AllDay {
+		localized.DueDate.Time = t.DueDate.Time.In(loc)
+	}
+	localized.FormatDates()
+	return &localized
+}
+
+// DueOn returns the date an all-day todo is due, as midnight in loc
+func (t *Todo) DueOn(loc *time.Location) time.Time {
+	year, month, day := t.DueDate.Time.UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, loc)
+}
@@ -0,0 +1,71 @@
+package models
+
+// Import modes
+const (
+	// ImportCreate creates a new todo for every row. IDs in the file are only
+	// used to resolve parent_id references between its rows.
+	ImportCreate = "create"
+	// ImportUpsert replaces the todos whose ID exists and creates the rest,
+	// keeping the IDs given in the file
+	ImportUpsert = "upsert"
+)
+
+// ImportFields lists the todo fields rows can be mapped to
+var ImportFields = []string{"id", "title", "description", "project", "parent_id", "tags", "completed", "priority", "due_date"}
+
+// ImportRow is one record of an import file. Errors holds the fields that
+// could not be read from it, such as a priority that is not a number, and
+// Warnings those that were changed to fit a todo, such as a title that was
+// too long.
+type ImportRow struct {
+	// Row numbers the records of the file from 1, not counting a CSV header
+	Row      int
+	Todo     TodoReplace
+	Errors   []FieldError
+	Warnings []FieldError
+}
+
+// ImportOptions controls how ImportRows are written
+type ImportOptions struct {
+	// Mode is ImportCreate or ImportUpsert
+	Mode string
+	// DryRun validates the rows and reports what would happen without
+	// writing anything
+	DryRun bool
+	// IgnoredColumns lists the columns of the file that map to no field; they
+	// are passed through to the result
+	IgnoredColumns []string
+}
+
+// ImportResult reports the outcome of an import. Nothing is written unless
+// every row is valid, so Committed is false whenever Errors is not empty.
+// Warnings do not stop an import.
+type ImportResult struct {
+	Mode      string `json:"mode"`
+	DryRun    bool   `json:"dry_run"`
+	Committed bool   `json:"committed"`
+	Rows      int    `json:"rows"`
+	// Created and Updated count the todos written, or that would be on a dry run
+	Created        int              `json:"created"`
+	Updated        int              `json:"updated"`
+	Todos          []ImportedTodo   `json:"todos"`
+	Errors         []ImportRowError `json:"errors"`
+	Warnings       []ImportRowError `json:"warnings,omitempty"`
+	IgnoredColumns []string         `json:"ignored_columns,omitempty"`
+}
+
+// ImportedTodo tells which todo a row became
+type ImportedTodo struct {
+	Row int    `json:"row"`
+	ID  string `json:"id"`
+	// Action is EventCreated or EventUpdated
+	Action string `json:"action"`
+}
+
+// ImportRowError describes why a row, or one of its fields, was rejected,
+// or how it was changed
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,106 @@
+package models
+
+import "time"
+
+// Fields todos can be sorted by
+const (
+	SortPriority  = "priority"
+	SortCreatedAt = "created_at"
+	SortUpdatedAt = "updated_at"
+	SortDueDate   = "due_date"
+	SortTitle     = "title"
+)
+
+// SortFields lists every field todos can be sorted by
+var SortFields = []string{SortPriority, SortCreatedAt, SortUpdatedAt, SortDueDate, SortTitle}
+
+// TodoFilter selects todos; zero fields match everything. A non-nil
+// ParentID selects the subtasks of that todo, or top-level todos if empty.
+type TodoFilter struct {
+	Completed *bool
+	Project   *string
+	Tag       string
+	ParentID  *string
+	// Search matches todos whose title or description contains it, ignoring case
+	Search string
+	// Due matches todos due in the range; todos without a due date never match
+	Due *DueRange
+}
+
+// Windows of due dates todos can be listed by, counted in a time zone:
+// todos due before now, today, and in the seven days from today
+const (
+	DueOverdue = "overdue"
+	DueToday   = "today"
+	DueWeek    = "week"
+)
+
+// DueWindows lists every window of due dates
+var DueWindows = []string{DueOverdue, DueToday, DueWeek}
+
+// DueRange selects todos due from From until before To; a nil bound is
+// open. Timed todos match by instant. All-day todos match by date: when
+// their date is on or after the date of From and before the date of To,
+// each read in its own location. So todos due all day today are not due
+// before now, but are due from now on.
+type DueRange struct {
+	From *time.Time
+	To   *time.Time
+}
+
+// Matches reports whether todo is due in the range
+func (r DueRange) Matches(todo *Todo) bool {
+	if !todo.DueDate.Valid {
+		return false
+	}
+	due := todo.DueDate.Time
+	if r.From != nil {
+		from := *r.From
+		if todo.AllDay {
+			from = Date(from)
+		}
+		if due.Before(from) {
+			return false
+		}
+	}
+	if r.To != nil {
+		to := *r.To
+		if todo.AllDay {
+			to = Date(to)
+		}
+		if !due.Before(to) {
+			return false
+		}
+	}
+	return true
+}
+
+// Date returns the date of t in its location as midnight UTC, the way the
+// due dates of all-day todos are stored
+func Date(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// TodoQuery selects a page of todos. Without a Sort, todos are ordered by
+// priority descending, then created_at descending. Todos without a due
+// date sort last either way. A zero Limit selects every match and ignores
+// Offset.
+type TodoQuery struct {
+	Filter     TodoFilter
+	Sort       string
+	Descending bool
+	Limit      int
+	Offset     int
+	// After, as a store returned it, starts the page after that todo in
+	// the query's order instead of at Offset, so that pages neither
+	// overlap nor skip todos when others are written in between
+	After *Todo
+}
+
+// TodoCounts tallies the todos matching a filter
+type TodoCounts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Open      int `json:"open"`
+}
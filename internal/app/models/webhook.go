@@ -0,0 +1,93 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	// DeliveryFailed means every attempt failed; only a redelivery sends it again
+	DeliveryFailed = "failed"
+)
+
+// Webhook is a subscription delivering todo events to an HTTP endpoint
+type Webhook struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// Secret signs deliveries. It is only returned when the webhook is created.
+	Secret string `json:"secret,omitempty"`
+	// Events lists the event types delivered; empty means all of them
+	Events      []string  `json:"events"`
+	Description string    `json:"description"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Accepts reports whether events of the given type are delivered to the webhook
+func (w *Webhook) Accepts(eventType string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, t := range w.Events {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookCreate represents the data needed to create a webhook. A secret is
+// generated when none is given.
+type WebhookCreate struct {
+	URL         string   `json:"url" validate:"trim,required,url,max=2000"`
+	Secret      string   `json:"secret,omitempty" validate:"max=200"`
+	Events      []string `json:"events,omitempty" validate:"oneof=created|updated|deleted"`
+	Description string   `json:"description" validate:"max=2000"`
+	Active      *bool    `json:"active,omitempty"`
+}
+
+// WebhookUpdate represents the data needed to update a webhook
+type WebhookUpdate struct {
+	URL         *string   `json:"url,omitempty" validate:"trim,notblank,url,max=2000"`
+	Secret      *string   `json:"secret,omitempty" validate:"notblank,max=200"`
+	Events      *[]string `json:"events,omitempty" validate:"oneof=created|updated|deleted"`
+	Description *string   `json:"description,omitempty" validate:"max=2000"`
+	Active      *bool     `json:"active,omitempty"`
+}
+
+// WebhookDelivery is one event queued for delivery to one webhook
+type WebhookDelivery struct {
+	ID        string `json:"id"`
+	WebhookID string `json:"webhook_id"`
+	EventID   int64  `json:"event_id"`
+	EventType string `json:"event_type"`
+	// Payload is the exact request body sent on every attempt
+	Payload  json.RawMessage `json:"payload" swaggertype:"object"`
+	Status   string          `json:"status"`
+	Attempts int             `json:"attempts"`
+	// NextAttemptAt is when a pending delivery is tried next, otherwise when it was last tried
+	NextAttemptAt  time.Time `json:"next_attempt_at"`
+	LastError      string    `json:"last_error,omitempty"`
+	ResponseStatus int       `json:"response_status,omitempty"`
+	// RedeliveryOf is the ID of the delivery a redelivery repeats
+	RedeliveryOf string    `json:"redelivery_of,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	// AttemptLog is only filled in when a single delivery is requested
+	AttemptLog []*WebhookAttempt `json:"attempt_log,omitempty"`
+}
+
+// WebhookAttempt records one HTTP request made for a delivery
+type WebhookAttempt struct {
+	ID             int64     `json:"id"`
+	DeliveryID     string    `json:"delivery_id"`
+	Attempt        int       `json:"attempt"`
+	ResponseStatus int       `json:"response_status,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	DurationMillis int64     `json:"duration_ms"`
+	CreatedAt      time.Time `json:"created_at"`
+}
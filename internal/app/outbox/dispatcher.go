@@ -0,0 +1,226 @@
+// Package outbox relays todo events from the transactional outbox to the
+// sinks that act on them: the in-process event bus, webhooks and files.
+package outbox
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/repositories"
+)
+
+// Sink receives relayed events. Delivery is at least once: an event is
+// passed again when a later sink fails or the process stops before the
+// entry is removed, so Publish must tolerate repeats, e.g. by event ID.
+type Sink interface {
+	Publish(event *models.TodoEvent) error
+}
+
+// Options configures a Dispatcher. Zero values select the defaults.
+type Options struct {
+	// PollInterval is how often the outbox is checked without a Wake (default 1s)
+	PollInterval time.Duration
+	// BatchSize is how many entries are read at once (default 100)
+	BatchSize int
+	// Lease is how long an entry is kept from other replicas while it is
+	// relayed (default 1m)
+	Lease time.Duration
+	// RetryBase is the delay before relaying a failed entry again; it
+	// doubles with every further failure up to MaxRetryDelay (defaults 1s and 1m)
+	RetryBase     time.Duration
+	MaxRetryDelay time.Duration
+	// DrainTimeout bounds the final pass made when Run stops (default 5s)
+	DrainTimeout time.Duration
+	// Now returns the current time; tests may pin it
+	Now func() time.Time
+}
+
+// Dispatcher drains the outbox, passing every entry to each sink in order
+// and removing it once all of them accepted it. Entries for the same todo
+// are relayed in the order they were written: while one is failing, later
+// ones for that todo wait, and other todos carry on. Replicas sharing an
+// outbox claim every entry before relaying it, and leave a todo's later
+// entries alone while another replica holds one.
+type Dispatcher struct {
+	store repositories.OutboxStore
+	sinks []Sink
+	opts  Options
+	wake  chan struct{}
+
+	// mu serializes drains, which per-todo ordering relies on
+	mu sync.Mutex
+}
+
+// NewDispatcher creates a Dispatcher relaying the entries in store to sinks
+func NewDispatcher(store repositories.OutboxStore, sinks []Sink, opts Options) *Dispatcher {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = time.Minute
+	}
+	if opts.RetryBase <= 0 {
+		opts.RetryBase = time.Second
+	}
+	if opts.MaxRetryDelay <= 0 {
+		opts.MaxRetryDelay = time.Minute
+	}
+	if opts.DrainTimeout <= 0 {
+		opts.DrainTimeout = 5 * time.Second
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	return &Dispatcher{
+		store: store,
+		sinks: sinks,
+		opts:  opts,
+		wake:  make(chan struct{}, 1),
+	}
+}
+
+// Wake asks a running dispatcher to check the outbox now, e.g. after a
+// change was committed. It never blocks.
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run relays entries until ctx is cancelled, then makes a final pass
+// bounded by DrainTimeout so changes committed before shutdown are not
+// held back until the next start
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := d.Drain(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Outbox dispatcher: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			d.flush()
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// flush drains whatever is due before the dispatcher stops
+func (d *Dispatcher) flush() {
+	ctx, cancel := context.WithTimeout(context.Background(), d.opts.DrainTimeout)
+	defer cancel()
+
+	if _, err := d.Drain(ctx); err != nil {
+		log.Printf("Outbox dispatcher: %v", err)
+	}
+	if ctx.Err() != nil {
+		log.Printf("Outbox dispatcher: stopped with entries left; they are relayed on the next start")
+	}
+}
+
+// Drain relays every entry due now and returns how many were removed
+func (d *Dispatcher) Drain(ctx context.Context) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.opts.Now()
+	// blocked holds the todos with an entry that is not due, held by
+	// another replica or just failed
+	blocked := make(map[string]bool)
+	relayed := 0
+
+	var afterID int64
+	for {
+		entries, err := d.store.ListPending(afterID, d.opts.BatchSize)
+		if err != nil {
+			return relayed, err
+		}
+
+		for _, entry := range entries {
+			if ctx.Err() != nil {
+				return relayed, nil
+			}
+			afterID = entry.Event.ID
+
+			todoID := entry.Event.TodoID
+			if blocked[todoID] {
+				continue
+			}
+			if entry.NextAttemptAt.After(now) {
+				blocked[todoID] = true
+				continue
+			}
+			claimed, err := d.store.Claim(entry, now.Add(d.opts.Lease))
+			if err != nil {
+				return relayed, err
+			}
+			if !claimed {
+				blocked[todoID] = true
+				continue
+			}
+
+			if err := d.relay(entry.Event); err != nil {
+				blocked[todoID] = true
+				if err := d.reschedule(entry, err, now); err != nil {
+					return relayed, err
+				}
+				continue
+			}
+
+			if err := d.store.Delete(entry.Event.ID); err != nil {
+				return relayed, err
+			}
+			relayed++
+		}
+
+		if len(entries) < d.opts.BatchSize {
+			return relayed, nil
+		}
+	}
+}
+
+// relay passes event to every sink in order, stopping at the first failure
+func (d *Dispatcher) relay(event *models.TodoEvent) error {
+	for _, sink := range d.sinks {
+		if err := sink.Publish(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reschedule records a failed relay of entry and backs it off
+func (d *Dispatcher) reschedule(entry *models.OutboxEntry, cause error, now time.Time) error {
+	entry.Attempts++
+	entry.LastError = cause.Error()
+	entry.NextAttemptAt = now.Add(d.backoff(entry.Attempts))
+
+	log.Printf("Outbox dispatcher: failed to relay %s event %d for todo %s (attempt %d): %v",
+		entry.Event.Type, entry.Event.ID, entry.Event.TodoID, entry.Attempts, cause)
+
+	return d.store.RecordFailure(entry)
+}
+
+// backoff returns the delay after the given number of failed relays
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.opts.RetryBase
+	for i := 1; i < attempts && delay < d.opts.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > d.opts.MaxRetryDelay {
+		delay = d.opts.MaxRetryDelay
+	}
+	return delay
+}
@@ -0,0 +1,245 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/outbox"
+	"github.com/teguh/go-todo-api/internal/app/repositories"
+	"github.com/teguh/go-todo-api/internal/database"
+)
+
+// openStores returns a todo store and the outbox it writes to
+func openStores(t *testing.T, dialect string) (repositories.TodoStore, repositories.OutboxStore) {
+	t.Helper()
+
+	if dialect == database.DialectMemory {
+		entries := repositories.NewMemoryOutboxRepository()
+		return repositories.NewMemoryTodoRepository(entries), entries
+	}
+	db, err := database.Initialize("sqlite://" + filepath.Join(t.TempDir(), "todo.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return repositories.NewTodoRepository(db), repositories.NewOutboxRepository(db)
+}
+
+// createAndDelete writes a created and a deleted event for each of n todos
+func createAndDelete(t *testing.T, store repositories.TodoStore, n int) []string {
+	t.Helper()
+
+	ids := make([]string, n)
+	for i := range ids {
+		todo, err := models.NewTodo(models.TodoCreate{Title: fmt.Sprintf("todo %d", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Create(todo, &models.TodoEvent{Type: models.EventCreated}); err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = todo.ID
+	}
+	for _, id := range ids {
+		if err := store.Delete(id, 0, &models.TodoEvent{Type: models.EventDeleted}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ids
+}
+
+// journal is a Sink recording the events relayed to it, failing those
+// listed in fail
+type journal struct {
+	mu     sync.Mutex
+	events []*models.TodoEvent
+	fail   map[int64]bool
+	delay  time.Duration
+}
+
+func (j *journal) Publish(event *models.TodoEvent) error {
+	time.Sleep(j.delay)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.fail[event.ID] {
+		return errors.New("sink unavailable")
+	}
+	j.events = append(j.events, event)
+	return nil
+}
+
+// ids returns the IDs of the events relayed for todoID, in relay order
+func (j *journal) ids(todoID string) []int64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var ids []int64
+	for _, event := range j.events {
+		if event.TodoID == todoID {
+			ids = append(ids, event.ID)
+		}
+	}
+	return ids
+}
+
+func TestReplicasRelayEachEntryOnce(t *testing.T) {
+	for _, dialect := range []string{database.DialectSQLite, database.DialectMemory} {
+		t.Run(dialect, func(t *testing.T) {
+			store, entries := openStores(t, dialect)
+			todoIDs := createAndDelete(t, store, 10)
+
+			// Both replicas relay to the same journal, which sees every
+			// event in the order the replicas passed them on
+			sink := &journal{delay: time.Millisecond}
+			var wg sync.WaitGroup
+			for i := 0; i < 2; i++ {
+				d := outbox.NewDispatcher(entries, []outbox.Sink{sink}, outbox.Options{BatchSize: 4})
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for {
+						pending, err := entries.ListPending(0, 1)
+						if err != nil {
+							t.Error(err)
+							return
+						}
+						if len(pending) == 0 {
+							return
+						}
+						if _, err := d.Drain(context.Background()); err != nil {
+							t.Error(err)
+							return
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			if len(sink.events) != 2*len(todoIDs) {
+				t.Fatalf("relayed %d events, want %d", len(sink.events), 2*len(todoIDs))
+			}
+			for _, id := range todoIDs {
+				ids := sink.ids(id)
+				if len(ids) != 2 || ids[0] >= ids[1] {
+					t.Errorf("todo %s relayed as %v, want its created then its deleted event", id, ids)
+				}
+			}
+		})
+	}
+}
+
+func TestHeldEntryHoldsBackItsTodo(t *testing.T) {
+	for _, dialect := range []string{database.DialectSQLite, database.DialectMemory} {
+		t.Run(dialect, func(t *testing.T) {
+			store, entries := openStores(t, dialect)
+			todoIDs := createAndDelete(t, store, 2)
+
+			now := time.Now()
+			sink := &journal{}
+			d := outbox.NewDispatcher(entries, []outbox.Sink{sink}, outbox.Options{
+				Lease: time.Minute,
+				Now:   func() time.Time { return now },
+			})
+
+			// Another replica holds the first todo's created event
+			pending, err := entries.ListPending(0, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claimed, err := entries.Claim(pending[0], now.Add(time.Minute)); err != nil || !claimed {
+				t.Fatalf("claim = %v, %v", claimed, err)
+			}
+			// A copy read before the claim can no longer be claimed
+			if claimed, _ := entries.Claim(&models.OutboxEntry{Event: pending[0].Event, NextAttemptAt: now.Add(-time.Hour)}, now); claimed {
+				t.Fatal("claimed an entry held by another replica")
+			}
+
+			relayed, err := d.Drain(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if relayed != 2 || len(sink.ids(todoIDs[0])) != 0 || len(sink.ids(todoIDs[1])) != 2 {
+				t.Fatalf("relayed %d: first todo %v, second todo %v", relayed, sink.ids(todoIDs[0]), sink.ids(todoIDs[1]))
+			}
+
+			// Once the lease runs out, e.g. because the replica died, the
+			// held entry and the one behind it are relayed in order
+			now = now.Add(2 * time.Minute)
+			if relayed, err := d.Drain(context.Background()); err != nil || relayed != 2 {
+				t.Fatalf("drain after lease = %d, %v", relayed, err)
+			}
+			if ids := sink.ids(todoIDs[0]); len(ids) != 2 || ids[0] != pending[0].Event.ID {
+				t.Errorf("first todo relayed as %v", ids)
+			}
+		})
+	}
+}
+
+func TestFailedEntryHoldsBackItsTodo(t *testing.T) {
+	store, entries := openStores(t, database.DialectMemory)
+	todoIDs := createAndDelete(t, store, 2)
+
+	pending, err := entries.ListPending(0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &journal{fail: map[int64]bool{pending[0].Event.ID: true}}
+	d := outbox.NewDispatcher(entries, []outbox.Sink{sink}, outbox.Options{})
+
+	if relayed, err := d.Drain(context.Background()); err != nil || relayed != 2 {
+		t.Fatalf("drain = %d, %v", relayed, err)
+	}
+	if ids := sink.ids(todoIDs[0]); len(ids) != 0 {
+		t.Errorf("relayed %v past a failed entry", ids)
+	}
+	left, err := entries.ListPending(0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 2 || left[0].Attempts != 1 || left[0].LastError != "sink unavailable" {
+		t.Errorf("left in the outbox: %+v", left)
+	}
+}
+
+func TestMovesCarryTheProjectLeft(t *testing.T) {
+	for _, dialect := range []string{database.DialectSQLite, database.DialectMemory} {
+		t.Run(dialect, func(t *testing.T) {
+			store, entries := openStores(t, dialect)
+			todo, err := models.NewTodo(models.TodoCreate{Title: "Fix the shelf", Project: "home"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Create(todo, &models.TodoEvent{Type: models.EventCreated}); err != nil {
+				t.Fatal(err)
+			}
+			moved, title := "work", "Fix the office shelf"
+			if _, err := store.Update(todo.ID, 0, &models.TodoUpdate{Project: &moved}, &models.TodoEvent{Type: models.EventUpdated}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.Update(todo.ID, 0, &models.TodoUpdate{Title: &title}, &models.TodoEvent{Type: models.EventUpdated}); err != nil {
+				t.Fatal(err)
+			}
+
+			sink := &journal{}
+			if _, err := outbox.NewDispatcher(entries, []outbox.Sink{sink}, outbox.Options{}).Drain(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if len(sink.events) != 3 {
+				t.Fatalf("relayed %d events, want 3", len(sink.events))
+			}
+			for i, want := range []string{"", "home", ""} {
+				if got := sink.events[i].PreviousProject; got != want {
+					t.Errorf("event %d left project %q, want %q", i+1, got, want)
+				}
+			}
+		})
+	}
+}
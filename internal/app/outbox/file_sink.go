@@ -0,0 +1,62 @@
+package outbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+// FileSink appends every event to a file as one JSON object per line
+// (NDJSON). Relays are retried, so readers should skip event IDs they have
+// already seen.
+type FileSink struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// NewFileSink opens path for appending, creating it and its directory if needed
+func NewFileSink(path string) (*FileSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create outbox file directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open outbox file: %w", err)
+	}
+
+	return &FileSink{path: path, file: file}, nil
+}
+
+// Publish writes event as a line and syncs it to disk before returning, so
+// the outbox entry is only removed once the line is durable
+func (s *FileSink) Publish(event *models.TodoEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event %d: %w", event.ID, err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.file.Write(line); err != nil {
+		return fmt.Errorf("failed to write event %d to %s: %w", event.ID, s.path, err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// Close closes the file
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
@@ -0,0 +1,52 @@
+package reminders
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+// Notification is a reminder that fell due, together with its todo as it
+// is now. It is the JSON body of webhook reminders.
+type Notification struct {
+	Reminder *models.Reminder `json:"reminder"`
+	Todo     *models.Todo     `json:"todo"`
+}
+
+// Notifier sends reminders through one channel. An error leaves the
+// reminder to be tried again later, so Notify must not retry by itself.
+type Notifier interface {
+	Notify(ctx context.Context, notification *Notification) error
+}
+
+// LogNotifier writes reminders to a logger, which is useful in development
+// and as an audit trail
+type LogNotifier struct {
+	logger *log.Logger
+}
+
+// NewLogNotifier creates a LogNotifier writing to logger, or to the
+// standard logger if it is nil
+func NewLogNotifier(logger *log.Logger) *LogNotifier {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &LogNotifier{logger: logger}
+}
+
+// Notify logs the reminder
+func (n *LogNotifier) Notify(_ context.Context, notification *Notification) error {
+	n.logger.Printf("Reminder: %s", summary(notification.Todo))
+	return nil
+}
+
+// summary describes todo in a line: its title, and when it is due
+func summary(todo *models.Todo) string {
+	line := fmt.Sprintf("%q", todo.Title)
+	if todo.DueDateStr != "" {
+		line += " is due " + todo.DueDateStr
+	}
+	return line + " (todo " + todo.ID + ")"
+}
@@ -0,0 +1,204 @@
+// Package reminders sends the reminders of todos as they fall due, through
+// pluggable notifiers such as the server log, webhooks and email.
+package reminders
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/repositories"
+)
+
+// Options configures a Scheduler. Zero values select the defaults.
+type Options struct {
+	// Notifiers send reminders, keyed by the channel they serve
+	Notifiers map[string]Notifier
+	// PollInterval is how often due reminders are looked for (default 15s)
+	PollInterval time.Duration
+	// Lease is how long a claimed reminder is kept from other replicas; it
+	// must outlast the slowest notifier (default 1m)
+	Lease time.Duration
+	// MaxAttempts is how often a reminder is tried before it fails (default 5)
+	MaxAttempts int
+	// RetryBase is the delay before the first retry; it doubles with every
+	// further attempt up to MaxRetryDelay (defaults 1m and 1h)
+	RetryBase     time.Duration
+	MaxRetryDelay time.Duration
+	// Now returns the current time; tests may pin it
+	Now func() time.Time
+}
+
+// claimBatch is how many reminders are claimed at once
+const claimBatch = 20
+
+// Scheduler sends due reminders and reschedules failures with exponential
+// backoff. Reminders live in the database, so those that fell due while no
+// scheduler ran are sent on the next start, and replicas sharing the
+// database claim each one before sending it, so none is sent twice.
+type Scheduler struct {
+	store repositories.ReminderStore
+	todos repositories.TodoStore
+	opts  Options
+	wake  chan struct{}
+}
+
+// NewScheduler creates a Scheduler for the reminders in store, of the todos in todos
+func NewScheduler(store repositories.ReminderStore, todos repositories.TodoStore, opts Options) *Scheduler {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 15 * time.Second
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = time.Minute
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.RetryBase <= 0 {
+		opts.RetryBase = time.Minute
+	}
+	if opts.MaxRetryDelay <= 0 {
+		opts.MaxRetryDelay = time.Hour
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	return &Scheduler{
+		store: store,
+		todos: todos,
+		opts:  opts,
+		wake:  make(chan struct{}, 1),
+	}
+}
+
+// Channels returns the channels reminders can be sent through, sorted
+func (s *Scheduler) Channels() []string {
+	return slices.Sorted(maps.Keys(s.opts.Notifiers))
+}
+
+// Wake asks a running scheduler to look for due reminders now, e.g. after
+// one is created. It never blocks.
+func (s *Scheduler) Wake() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends due reminders until ctx is cancelled. Attempts interrupted by
+// cancellation are not recorded; they are retried once their claim expires.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.SendDue(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Reminder scheduler: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// SendDue sends every reminder due now and returns how many were attempted
+func (s *Scheduler) SendDue(ctx context.Context) (int, error) {
+	total := 0
+	for ctx.Err() == nil {
+		claimed, err := s.store.ClaimDueReminders(s.opts.Now(), s.opts.Lease, claimBatch)
+		if err != nil {
+			return total, err
+		}
+
+		for _, reminder := range claimed {
+			s.send(ctx, reminder)
+		}
+		total += len(claimed)
+
+		if len(claimed) < claimBatch {
+			break
+		}
+	}
+	return total, nil
+}
+
+// send makes one attempt at reminder and records the outcome. Reminders of
+// todos that were completed or deleted meanwhile are skipped.
+func (s *Scheduler) send(ctx context.Context, reminder *models.Reminder) {
+	todo, err := s.todos.GetByID(reminder.TodoID)
+	if err != nil {
+		log.Printf("Reminder scheduler: %v", err)
+		return // The claim expires and it is retried
+	}
+
+	if todo == nil || todo.Completed {
+		now := s.opts.Now()
+		reminder.Status = models.ReminderSkipped
+		reminder.NextAttemptAt = &now
+		reminder.UpdatedAt = now
+		s.record(reminder)
+		return
+	}
+
+	err = s.notify(ctx, reminder, todo)
+	if ctx.Err() != nil {
+		return
+	}
+	finished := s.opts.Now()
+
+	reminder.Attempts++
+	reminder.NextAttemptAt = &finished
+	reminder.UpdatedAt = finished
+
+	switch {
+	case err == nil:
+		reminder.Status = models.ReminderSent
+		reminder.LastError = ""
+		reminder.SentAt = &finished
+	case reminder.Attempts >= s.opts.MaxAttempts:
+		reminder.Status = models.ReminderFailed
+		reminder.LastError = err.Error()
+	default:
+		next := finished.Add(s.backoff(reminder.Attempts))
+		reminder.NextAttemptAt = &next
+		reminder.LastError = err.Error()
+	}
+	s.record(reminder)
+}
+
+// notify passes reminder to the notifier of its channel
+func (s *Scheduler) notify(ctx context.Context, reminder *models.Reminder, todo *models.Todo) error {
+	notifier, ok := s.opts.Notifiers[reminder.Channel]
+	if !ok {
+		return fmt.Errorf("%s reminders are not enabled", reminder.Channel)
+	}
+	sent := *reminder
+	return notifier.Notify(ctx, &Notification{Reminder: &sent, Todo: todo})
+}
+
+func (s *Scheduler) record(reminder *models.Reminder) {
+	if err := s.store.UpdateReminder(reminder); err != nil {
+		log.Printf("Reminder scheduler: %v", err)
+	}
+}
+
+// backoff returns the delay after the given number of failed attempts
+func (s *Scheduler) backoff(attempts int) time.Duration {
+	delay := s.opts.RetryBase
+	for i := 1; i < attempts && delay < s.opts.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > s.opts.MaxRetryDelay {
+		delay = s.opts.MaxRetryDelay
+	}
+	return delay
+}
@@ -0,0 +1,341 @@
+package reminders_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/identity"
+	"github.com/teguh/go-todo-api/internal/app/mailer"
+	"github.com/teguh/go-todo-api/internal/app/mailer/smtptest"
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/outbox"
+	"github.com/teguh/go-todo-api/internal/app/reminders"
+	"github.com/teguh/go-todo-api/internal/app/repositories"
+	"github.com/teguh/go-todo-api/internal/app/services"
+	"github.com/teguh/go-todo-api/internal/database"
+)
+
+// clock is a fake clock shared by the schedulers of a test
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// fixture holds the stores and services of a test, and the SMTP server
+// email reminders are sent to
+type fixture struct {
+	todos       *services.TodoService
+	reminders   *services.ReminderService
+	preferences *services.PreferenceService
+	store       repositories.ReminderStore
+	todoStore   repositories.TodoStore
+	relay       *outbox.Dispatcher
+	smtp        *smtptest.Server
+	mail        *mailer.Mailer
+	clock       *clock
+}
+
+func newFixture(t *testing.T, dialect string) *fixture {
+	t.Helper()
+
+	f := &fixture{smtp: smtptest.NewServer(), clock: &clock{now: time.Now()}}
+	t.Cleanup(f.smtp.Close)
+	mail, err := mailer.New(mailer.Options{Addr: f.smtp.Addr, From: "todo@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.mail = mail
+
+	var entries repositories.OutboxStore
+	var preferences repositories.PreferenceStore
+	if dialect == database.DialectMemory {
+		memory := repositories.NewMemoryOutboxRepository()
+		entries = memory
+		f.todoStore = repositories.NewMemoryTodoRepository(memory)
+		f.store = repositories.NewMemoryReminderRepository()
+		preferences = repositories.NewMemoryPreferenceRepository()
+	} else {
+		db, err := database.Initialize("sqlite://" + filepath.Join(t.TempDir(), "todo.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		entries = repositories.NewOutboxRepository(db)
+		f.todoStore = repositories.NewTodoRepository(db)
+		f.store = repositories.NewReminderRepository(db)
+		preferences = repositories.NewPreferenceRepository(db)
+	}
+
+	f.todos = services.NewTodoService(f.todoStore, nil)
+	f.preferences = services.NewPreferenceService(preferences, false, nil)
+	f.reminders = services.NewReminderService(f.store, f.todoStore, f.preferences, []string{models.ChannelEmail}, nil)
+	f.relay = outbox.NewDispatcher(entries, []outbox.Sink{f.reminders}, outbox.Options{})
+	return f
+}
+
+// newScheduler returns a scheduler mailing reminders at the fixture's clock
+func (f *fixture) newScheduler() *reminders.Scheduler {
+	return reminders.NewScheduler(f.store, f.todoStore, reminders.Options{
+		Notifiers: map[string]reminders.Notifier{models.ChannelEmail: reminders.NewSMTPNotifier(f.mail)},
+		Lease:     time.Minute,
+		Now:       f.clock.Now,
+	})
+}
+
+// relayChanges passes the changes made to todos on to the reminders
+func (f *fixture) relayChanges(t *testing.T) {
+	t.Helper()
+	if _, err := f.relay.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// sendDue runs scheduler at the fixture's clock and checks how many
+// reminders it attempted
+func sendDue(t *testing.T, scheduler *reminders.Scheduler, want int) {
+	t.Helper()
+	sent, err := scheduler.SendDue(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent != want {
+		t.Fatalf("attempted %d reminders, want %d", sent, want)
+	}
+}
+
+// subjects returns the subjects of the mail received so far
+func (f *fixture) subjects() []string {
+	var subjects []string
+	for _, msg := range f.smtp.Messages() {
+		for _, line := range strings.Split(string(msg.Data), "\r\n") {
+			if subject, ok := strings.CutPrefix(line, "Subject: "); ok {
+				subjects = append(subjects, subject)
+				break
+			}
+		}
+	}
+	return subjects
+}
+
+func TestSchedulersSendEachReminderOnce(t *testing.T) {
+	for _, dialect := range []string{database.DialectSQLite, database.DialectMemory} {
+		t.Run(dialect, func(t *testing.T) {
+			f := newFixture(t, dialect)
+			at := f.clock.Now().Add(time.Hour).Format(time.RFC3339)
+			for i := 0; i < 6; i++ {
+				todo, err := f.todos.CreateTodo(context.Background(), models.TodoCreate{Title: fmt.Sprintf("todo %d", i)})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := f.reminders.CreateReminder(context.Background(), todo.ID, models.ReminderCreate{At: at, Channel: models.ChannelEmail, Recipient: "alice@example.com"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Nothing is sent before the reminders fall due
+			first, second := f.newScheduler(), f.newScheduler()
+			sendDue(t, first, 0)
+
+			// Two replicas running at once share the reminders out
+			f.clock.Set(f.clock.Now().Add(2 * time.Hour))
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			total := 0
+			for _, scheduler := range []*reminders.Scheduler{first, second} {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					sent, err := scheduler.SendDue(context.Background())
+					if err != nil {
+						t.Error(err)
+					}
+					mu.Lock()
+					total += sent
+					mu.Unlock()
+				}()
+			}
+			wg.Wait()
+
+			if total != 6 || len(f.smtp.Messages()) != 6 {
+				t.Errorf("attempted %d reminders and mailed %d, want 6 of each", total, len(f.smtp.Messages()))
+			}
+			sendDue(t, second, 0)
+		})
+	}
+}
+
+func TestClaimedReminderWaitsForItsLease(t *testing.T) {
+	for _, dialect := range []string{database.DialectSQLite, database.DialectMemory} {
+		t.Run(dialect, func(t *testing.T) {
+			f := newFixture(t, dialect)
+			todo, err := f.todos.CreateTodo(context.Background(), models.TodoCreate{Title: "Renew passport"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			at := f.clock.Now().Add(-time.Minute).Format(time.RFC3339)
+			if _, err := f.reminders.CreateReminder(context.Background(), todo.ID, models.ReminderCreate{At: at, Channel: models.ChannelEmail, Recipient: "alice@example.com"}); err != nil {
+				t.Fatal(err)
+			}
+
+			// A replica claims the reminder, then dies before sending it
+			claimed, err := f.store.ClaimDueReminders(f.clock.Now(), time.Minute, 10)
+			if err != nil || len(claimed) != 1 {
+				t.Fatalf("claimed %v, %v", claimed, err)
+			}
+
+			scheduler := f.newScheduler()
+			sendDue(t, scheduler, 0)
+			f.clock.Set(f.clock.Now().Add(59 * time.Second))
+			sendDue(t, scheduler, 0)
+
+			// Once the lease runs out, another replica sends it
+			f.clock.Set(f.clock.Now().Add(2 * time.Second))
+			sendDue(t, scheduler, 1)
+			if subjects := f.subjects(); len(subjects) != 1 || subjects[0] != "Reminder: Renew passport" {
+				t.Errorf("mailed %v", subjects)
+			}
+		})
+	}
+}
+
+func TestRelativeRemindersFollowTheDueDate(t *testing.T) {
+	for _, dialect := range []string{database.DialectSQLite, database.DialectMemory} {
+		t.Run(dialect, func(t *testing.T) {
+			f := newFixture(t, dialect)
+			ctx := context.Background()
+			due := f.clock.Now().Add(24 * time.Hour).Truncate(time.Second)
+			todo, err := f.todos.CreateTodo(ctx, models.TodoCreate{Title: "Dentist", DueDate: due.Format(time.RFC3339)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			reminder, err := f.reminders.CreateReminder(ctx, todo.ID, models.ReminderCreate{Before: "1h", Channel: models.ChannelEmail, Recipient: "alice@example.com"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reminder.FireAt.Equal(due.Add(-time.Hour)) {
+				t.Fatalf("fires at %v, want an hour before %v", reminder.FireAt, due)
+			}
+			f.relayChanges(t)
+
+			// The appointment moves a day later, and so does its reminder
+			moved := due.Add(24 * time.Hour).Format(time.RFC3339)
+			if _, err := f.todos.UpdateTodo(ctx, todo.ID, models.TodoUpdate{DueDate: &moved}); err != nil {
+				t.Fatal(err)
+			}
+			f.relayChanges(t)
+
+			scheduler := f.newScheduler()
+			f.clock.Set(due.Add(-time.Hour))
+			sendDue(t, scheduler, 0)
+			f.clock.Set(due.Add(23 * time.Hour))
+			sendDue(t, scheduler, 1)
+
+			// Moving it again after the reminder was sent schedules it anew
+			moved = due.Add(48 * time.Hour).Format(time.RFC3339)
+			if _, err := f.todos.UpdateTodo(ctx, todo.ID, models.TodoUpdate{DueDate: &moved}); err != nil {
+				t.Fatal(err)
+			}
+			f.relayChanges(t)
+			rescheduled, err := f.reminders.GetReminder(todo.ID, reminder.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rescheduled.Status != models.ReminderPending || !rescheduled.FireAt.Equal(due.Add(47*time.Hour)) {
+				t.Fatalf("after the second move: %+v", rescheduled)
+			}
+
+			// but not once the todo is done
+			completed := true
+			if _, err := f.todos.UpdateTodo(ctx, todo.ID, models.TodoUpdate{Completed: &completed}); err != nil {
+				t.Fatal(err)
+			}
+			f.relayChanges(t)
+			f.clock.Set(due.Add(47 * time.Hour))
+			sendDue(t, scheduler, 1)
+			if skipped, _ := f.reminders.GetReminder(todo.ID, reminder.ID); skipped.Status != models.ReminderSkipped {
+				t.Errorf("reminder of a completed todo is %s, want skipped", skipped.Status)
+			}
+			if subjects := f.subjects(); len(subjects) != 1 {
+				t.Errorf("mailed %v, want one reminder", subjects)
+			}
+		})
+	}
+}
+
+func TestAllDayRemindersStartInTheUsersTimeZone(t *testing.T) {
+	for _, dialect := range []string{database.DialectSQLite, database.DialectMemory} {
+		t.Run(dialect, func(t *testing.T) {
+			f := newFixture(t, dialect)
+			alice := identity.WithUser(context.Background(), "alice")
+			if _, err := f.preferences.ReplacePreferences(alice, models.PreferencesReplace{Timezone: "Asia/Tokyo"}); err != nil {
+				t.Fatal(err)
+			}
+
+			// Midnight in Tokyo is 15:00 UTC the day before
+			due := f.clock.Now().AddDate(0, 0, 7).Format(models.DateLayout)
+			todo, err := f.todos.CreateTodo(alice, models.TodoCreate{Title: "Pay rent", DueDate: due})
+			if err != nil {
+				t.Fatal(err)
+			}
+			tokyo, _ := time.LoadLocation("Asia/Tokyo")
+			day, _ := time.ParseInLocation(models.DateLayout, due, tokyo)
+			reminder, err := f.reminders.CreateReminder(alice, todo.ID, models.ReminderCreate{Before: "1h", Channel: models.ChannelEmail, Recipient: "alice@example.com"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reminder.FireAt.Equal(day.Add(-time.Hour)) || reminder.UserID != "alice" {
+				t.Fatalf("fires at %v for %q, want an hour before %v", reminder.FireAt, reminder.UserID, day)
+			}
+
+			// Reminders set without a user count from midnight UTC
+			anonymous, err := f.reminders.CreateReminder(context.Background(), todo.ID, models.ReminderCreate{Before: "1h", Channel: models.ChannelEmail, Recipient: "bob@example.com"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if utcDay, _ := time.Parse(models.DateLayout, due); !anonymous.FireAt.Equal(utcDay.Add(-time.Hour)) {
+				t.Fatalf("anonymous reminder fires at %v", anonymous.FireAt)
+			}
+
+			// Moving the todo a day keeps the user's zone
+			moved := day.AddDate(0, 0, 1).Format(models.DateLayout)
+			if _, err := f.todos.UpdateTodo(alice, todo.ID, models.TodoUpdate{DueDate: &moved}); err != nil {
+				t.Fatal(err)
+			}
+			f.relayChanges(t)
+			rescheduled, err := f.reminders.GetReminder(todo.ID, reminder.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !rescheduled.FireAt.Equal(day.AddDate(0, 0, 1).Add(-time.Hour)) || rescheduled.UserID != "alice" {
+				t.Fatalf("after the move: %+v", rescheduled)
+			}
+
+			// and the scheduler sends it an hour before midnight in Tokyo
+			scheduler := f.newScheduler()
+			f.clock.Set(day.AddDate(0, 0, 1).Add(-61 * time.Minute))
+			sendDue(t, scheduler, 0)
+			f.clock.Set(day.AddDate(0, 0, 1).Add(-time.Hour))
+			sendDue(t, scheduler, 1)
+			if subjects := f.subjects(); len(subjects) != 1 || subjects[0] != "Reminder: Pay rent" {
+				t.Errorf("mailed %v", subjects)
+			}
+		})
+	}
+}
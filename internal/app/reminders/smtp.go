@@ -0,0 +1,43 @@
+package reminders
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/teguh/go-todo-api/internal/app/mailer"
+)
+
+// SMTPNotifier mails reminders to the address they name, in plain text
+type SMTPNotifier struct {
+	mailer *mailer.Mailer
+}
+
+// NewSMTPNotifier creates an SMTPNotifier sending through m
+func NewSMTPNotifier(m *mailer.Mailer) *SMTPNotifier {
+	return &SMTPNotifier{mailer: m}
+}
+
+// Notify sends the reminder in a message of its own
+func (n *SMTPNotifier) Notify(ctx context.Context, notification *Notification) error {
+	todo := notification.Todo
+
+	lines := []string{todo.Title}
+	if todo.DueDateStr != "" {
+		lines = append(lines, "Due: "+todo.DueDateStr)
+	}
+	if todo.Project != "" {
+		lines = append(lines, "Project: "+todo.Project)
+	}
+	if todo.Description != "" {
+		lines = append(lines, "", todo.Description)
+	}
+	lines = append(lines, "", "Todo "+todo.ID)
+
+	return n.mailer.Send(ctx, &mailer.Message{
+		To:      notification.Reminder.Recipient,
+		Subject: "Reminder: " + todo.Title,
+		Text:    strings.Join(lines, "\n"),
+		ID:      fmt.Sprintf("%s.%d", notification.Reminder.ID, notification.Reminder.Attempts),
+	})
+}
@@ -0,0 +1,104 @@
+package reminders_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"slices"
+	"testing"
+
+	"github.com/teguh/go-todo-api/internal/app/mailer"
+	"github.com/teguh/go-todo-api/internal/app/mailer/smtptest"
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/reminders"
+)
+
+func TestSMTPNotifierMailsTheReminder(t *testing.T) {
+	srv := smtptest.NewServer()
+	t.Cleanup(srv.Close)
+	m, err := mailer.New(mailer.Options{Addr: srv.Addr, From: "Todo <todo@example.com>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifier := reminders.NewSMTPNotifier(m)
+
+	todo := &models.Todo{
+		ID:          "todo-1",
+		Title:       "Zahnarzt – Müller",
+		DueDateStr:  "2030-05-01T09:30:00Z",
+		Project:     "Health",
+		Description: "Bring the card.\nAsk about the 50% = discount.",
+	}
+	reminder := &models.Reminder{ID: "reminder-1", Recipient: "Alice <alice@example.com>", Attempts: 2}
+	if err := notifier.Notify(context.Background(), &reminders.Notification{Reminder: reminder, Todo: todo}); err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := srv.Messages()
+	if len(msgs) != 1 {
+		t.Fatalf("received %d messages, want 1", len(msgs))
+	}
+	if msgs[0].From != "todo@example.com" || !slices.Equal(msgs[0].To, []string{"alice@example.com"}) {
+		t.Errorf("envelope from %q to %v", msgs[0].From, msgs[0].To)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(msgs[0].Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := map[string]string{
+		"From":                      `"Todo" <todo@example.com>`,
+		"To":                        `"Alice" <alice@example.com>`,
+		"Message-ID":                "<reminder-1.2@127.0.0.1>",
+		"Content-Type":              "text/plain; charset=utf-8",
+		"Content-Transfer-Encoding": "quoted-printable",
+	}
+	for name, want := range headers {
+		if got := msg.Header.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if subject != "Reminder: Zahnarzt – Müller" {
+		t.Errorf("subject = %q", subject)
+	}
+	if _, err := msg.Header.Date(); err != nil {
+		t.Errorf("date: %v", err)
+	}
+
+	body, err := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Zahnarzt – Müller\r\nDue: 2030-05-01T09:30:00Z\r\nProject: Health\r\n\r\n" +
+		"Bring the card.\r\nAsk about the 50% = discount.\r\n\r\nTodo todo-1\r\n"
+	if string(body) != want {
+		t.Errorf("body = %q, want %q", body, want)
+	}
+}
+
+func TestSMTPNotifierRejectsBadRecipients(t *testing.T) {
+	srv := smtptest.NewServer()
+	t.Cleanup(srv.Close)
+	m, err := mailer.New(mailer.Options{Addr: srv.Addr, From: "todo@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notification := &reminders.Notification{
+		Reminder: &models.Reminder{ID: "reminder-1", Recipient: "not an address"},
+		Todo:     &models.Todo{ID: "todo-1", Title: "Call"},
+	}
+	if err := reminders.NewSMTPNotifier(m).Notify(context.Background(), notification); err == nil {
+		t.Error("notified an invalid recipient")
+	}
+	if msgs := srv.Messages(); len(msgs) != 0 {
+		t.Errorf("received %d messages", len(msgs))
+	}
+}
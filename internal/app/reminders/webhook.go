@@ -0,0 +1,75 @@
+package reminders
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/webhooks"
+)
+
+// EventReminder is the X-Webhook-Event of webhook reminders
+const EventReminder = "reminder"
+
+// maxResponseBytes is how much of a response body is read before the
+// connection is released; the body itself is ignored
+const maxResponseBytes = 64 * 1024
+
+// WebhookNotifier POSTs reminders as JSON to the URL they name, with the
+// headers of webhook deliveries
+type WebhookNotifier struct {
+	client    *http.Client
+	secret    string
+	userAgent string
+}
+
+// NewWebhookNotifier creates a WebhookNotifier whose requests time out
+// after timeout. A non-empty secret signs them as webhook deliveries are.
+func NewWebhookNotifier(timeout time.Duration, secret, userAgent string) *WebhookNotifier {
+	return &WebhookNotifier{
+		client:    &http.Client{Timeout: timeout},
+		secret:    secret,
+		userAgent: userAgent,
+	}
+}
+
+// Notify posts the notification, treating any 2xx response as success
+func (n *WebhookNotifier) Notify(ctx context.Context, notification *Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("failed to encode reminder: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, notification.Reminder.Recipient, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid request: %w", err)
+	}
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhooks.HeaderEvent, EventReminder)
+	req.Header.Set(webhooks.HeaderDelivery, notification.Reminder.ID)
+	req.Header.Set(webhooks.HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	if n.secret != "" {
+		req.Header.Set(webhooks.HeaderSignature, webhooks.Sign(n.secret, now, body))
+	}
+	if n.userAgent != "" {
+		req.Header.Set("User-Agent", n.userAgent)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBytes))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint responded with %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/database"
+)
+
+// CalendarTokenStore persists calendar tokens, at most one per user, by the
+// hash of the token. Getters return (nil, nil) when nothing matches.
+type CalendarTokenStore interface {
+	// SaveCalendarToken stores token, replacing the one its user had
+	SaveCalendarToken(token *models.CalendarToken) error
+	// GetCalendarToken returns the token of a user
+	GetCalendarToken(userID string) (*models.CalendarToken, error)
+	// FindCalendarToken returns the token with the given hash
+	FindCalendarToken(tokenHash string) (*models.CalendarToken, error)
+	// DeleteCalendarToken removes the token of a user, reporting whether
+	// there was one
+	DeleteCalendarToken(userID string) (bool, error)
+}
+
+// CalendarTokenRepository stores calendar tokens in the calendar_tokens table
+type CalendarTokenRepository struct {
+	db     *sql.DB
+	rebind func(query string) string
+}
+
+// NewCalendarTokenRepository creates the CalendarTokenStore matching the
+// dialect of db. With the memory dialect every call returns a new, empty store.
+func NewCalendarTokenRepository(db *database.DB) CalendarTokenStore {
+	switch db.Dialect {
+	case database.DialectPostgres:
+		return &CalendarTokenRepository{db: db.DB, rebind: rebindDollar}
+	case database.DialectMemory:
+		return NewMemoryCalendarTokenRepository()
+	default:
+		return &CalendarTokenRepository{db: db.DB, rebind: func(query string) string { return query }}
+	}
+}
+
+// SaveCalendarToken inserts token, or replaces the hash and creation time
+// of its user's token
+func (r *CalendarTokenRepository) SaveCalendarToken(token *models.CalendarToken) error {
+	query := `
+		INSERT INTO calendar_tokens (user_id, token_hash, created_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET token_hash = excluded.token_hash, created_at = excluded.created_at
+	`
+
+	if _, err := r.db.Exec(r.rebind(query), token.UserID, token.TokenHash, token.CreatedAt.UTC()); err != nil {
+		return fmt.Errorf("failed to save calendar token: %w", err)
+	}
+	return nil
+}
+
+// GetCalendarToken retrieves the token of a user
+func (r *CalendarTokenRepository) GetCalendarToken(userID string) (*models.CalendarToken, error) {
+	return r.get("user_id", userID)
+}
+
+// FindCalendarToken retrieves the token with the given hash
+func (r *CalendarTokenRepository) FindCalendarToken(tokenHash string) (*models.CalendarToken, error) {
+	return r.get("token_hash", tokenHash)
+}
+
+func (r *CalendarTokenRepository) get(column, value string) (*models.CalendarToken, error) {
+	query := "SELECT user_id, token_hash, created_at FROM calendar_tokens WHERE " + column + " = ?"
+
+	var token models.CalendarToken
+	err := r.db.QueryRow(r.rebind(query), value).Scan(&token.UserID, &token.TokenHash, &token.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // Not found
+		}
+		return nil, fmt.Errorf("failed to get calendar token: %w", err)
+	}
+	return &token, nil
+}
+
+// DeleteCalendarToken removes the token of a user
+func (r *CalendarTokenRepository) DeleteCalendarToken(userID string) (bool, error) {
+	result, err := r.db.Exec(r.rebind("DELETE FROM calendar_tokens WHERE user_id = ?"), userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete calendar token: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	return rows > 0, nil
+}
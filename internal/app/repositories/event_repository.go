@@ -0,0 +1,170 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/database"
+)
+
+// EventStore is the persistent, append-only log of todo events, keyed by
+// the IDs the outbox assigned them. Appending an event already logged is a
+// no-op, so relays may safely retry. Events are read in the order they
+// were logged, by their Position, which may differ from ID order.
+type EventStore interface {
+	// Append logs event unless its ID is already there, and sets its
+	// Position either way
+	Append(event *models.TodoEvent) error
+	// ListSince returns up to limit events logged after the given position, in log order
+	ListSince(afterPosition int64, limit int) ([]*models.TodoEvent, error)
+	// Locate returns the position of the event with the given ID. For an
+	// ID that is not logged it returns the position just before the first
+	// event with a higher ID, or Latest if there is none.
+	Locate(id int64) (int64, error)
+	// Latest returns the position of the last event logged, or 0 for an empty log
+	Latest() (int64, error)
+}
+
+// EventRepository stores todo events in the todo_events table
+type EventRepository struct {
+	db     *sql.DB
+	rebind func(query string) string
+	// lock is run first in every append so that positions are handed out
+	// in the order appends commit; SQLite transactions take the write lock
+	// up front, which does the same
+	lock string
+}
+
+// NewEventRepository creates the EventStore matching the dialect of db.
+// With the memory dialect every call returns a new, empty store.
+func NewEventRepository(db *database.DB) EventStore {
+	switch db.Dialect {
+	case database.DialectPostgres:
+		return &EventRepository{db: db.DB, rebind: rebindDollar, lock: "SELECT pg_advisory_xact_lock(hashtext('todo_events'))"}
+	case database.DialectMemory:
+		return NewMemoryEventRepository()
+	default:
+		return &EventRepository{db: db.DB, rebind: func(query string) string { return query }}
+	}
+}
+
+// Append inserts event into the log at the next position unless its ID is
+// already there, and sets its Position
+func (r *EventRepository) Append(event *models.TodoEvent) error {
+	payload, err := json.Marshal(event.Todo)
+	if err != nil {
+		return fmt.Errorf("failed to encode event payload: %w", err)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if r.lock != "" {
+		if _, err := tx.Exec(r.lock); err != nil {
+			return fmt.Errorf("failed to lock event log: %w", err)
+		}
+	}
+
+	query := `
+		INSERT INTO todo_events (id, type, todo_id, actor, payload, previous_project, created_at, position)
+		VALUES (?, ?, ?, ?, ?, ?, ?, (SELECT COALESCE(MAX(position), 0) + 1 FROM todo_events))
+		ON CONFLICT (id) DO NOTHING
+	`
+
+	_, err = tx.Exec(
+		r.rebind(query),
+		event.ID,
+		event.Type,
+		event.TodoID,
+		event.Actor,
+		string(payload),
+		event.PreviousProject,
+		event.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to append event: %w", err)
+	}
+
+	if err := tx.QueryRow(r.rebind("SELECT position FROM todo_events WHERE id = ?"), event.ID).Scan(&event.Position); err != nil {
+		return fmt.Errorf("failed to read event position: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit event: %w", err)
+	}
+	return nil
+}
+
+// ListSince returns up to limit events logged after the given position, in log order
+func (r *EventRepository) ListSince(afterPosition int64, limit int) ([]*models.TodoEvent, error) {
+	query := `
+		SELECT id, type, todo_id, actor, payload, previous_project, created_at, position
+		FROM todo_events
+		WHERE position > ?
+		ORDER BY position
+		LIMIT ?
+	`
+
+	rows, err := r.db.Query(r.rebind(query), afterPosition, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*models.TodoEvent
+	for rows.Next() {
+		var event models.TodoEvent
+		var payload string
+		if err := rows.Scan(&event.ID, &event.Type, &event.TodoID, &event.Actor, &payload, &event.PreviousProject, &event.CreatedAt, &event.Position); err != nil {
+			return nil, fmt.Errorf("failed to scan event row: %w", err)
+		}
+		if err := json.Unmarshal([]byte(payload), &event.Todo); err != nil {
+			return nil, fmt.Errorf("failed to decode event payload: %w", err)
+		}
+		events = append(events, &event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating event rows: %w", err)
+	}
+
+	return events, nil
+}
+
+// Locate returns the position of the event with the given ID, or where it
+// would have been had it been logged in ID order
+func (r *EventRepository) Locate(id int64) (int64, error) {
+	var position int64
+	err := r.db.QueryRow(r.rebind("SELECT position FROM todo_events WHERE id = ?"), id).Scan(&position)
+	if err == nil {
+		return position, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("failed to locate event: %w", err)
+	}
+
+	query := `
+		SELECT COALESCE(MIN(position) - 1, (SELECT COALESCE(MAX(position), 0) FROM todo_events))
+		FROM todo_events
+		WHERE id > ?
+	`
+	if err := r.db.QueryRow(r.rebind(query), id).Scan(&position); err != nil {
+		return 0, fmt.Errorf("failed to locate event: %w", err)
+	}
+	return position, nil
+}
+
+// Latest returns the position of the last event logged, or 0 for an empty log
+func (r *EventRepository) Latest() (int64, error) {
+	var position int64
+	if err := r.db.QueryRow("SELECT COALESCE(MAX(position), 0) FROM todo_events").Scan(&position); err != nil {
+		return 0, fmt.Errorf("failed to read event log: %w", err)
+	}
+	return position, nil
+}
@@ -0,0 +1,4 @@
+package repositories
+
+// RebindDollar exposes rebindDollar to the tests
+var RebindDollar = rebindDollar
@@ -0,0 +1,68 @@
+package repositories
+
+import (
+	"sync"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+// MemoryCalendarTokenRepository is a goroutine-safe CalendarTokenStore kept
+// in memory
+type MemoryCalendarTokenRepository struct {
+	mu     sync.Mutex
+	tokens map[string]models.CalendarToken
+}
+
+// NewMemoryCalendarTokenRepository creates an empty MemoryCalendarTokenRepository
+func NewMemoryCalendarTokenRepository() *MemoryCalendarTokenRepository {
+	return &MemoryCalendarTokenRepository{tokens: make(map[string]models.CalendarToken)}
+}
+
+// SaveCalendarToken stores a copy of token, without the token itself
+func (r *MemoryCalendarTokenRepository) SaveCalendarToken(token *models.CalendarToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.tokens[token.UserID] = models.CalendarToken{
+		UserID:    token.UserID,
+		TokenHash: token.TokenHash,
+		CreatedAt: token.CreatedAt,
+	}
+	return nil
+}
+
+// GetCalendarToken returns a copy of the token of a user, or nil if there is none
+func (r *MemoryCalendarTokenRepository) GetCalendarToken(userID string) (*models.CalendarToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.tokens[userID]
+	if !ok {
+		return nil, nil
+	}
+	return &token, nil
+}
+
+// FindCalendarToken returns a copy of the token with the given hash, or nil
+// if there is none
+func (r *MemoryCalendarTokenRepository) FindCalendarToken(tokenHash string) (*models.CalendarToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, token := range r.tokens {
+		if token.TokenHash == tokenHash {
+			return &token, nil
+		}
+	}
+	return nil, nil
+}
+
+// DeleteCalendarToken removes the token of a user
+func (r *MemoryCalendarTokenRepository) DeleteCalendarToken(userID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.tokens[userID]
+	delete(r.tokens, userID)
+	return ok, nil
+}
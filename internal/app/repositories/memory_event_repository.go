@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"sync"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+// MemoryEventRepository is a goroutine-safe EventStore kept in memory.
+// Events are kept in log order, so an event's position is its index plus one.
+type MemoryEventRepository struct {
+	mu     sync.RWMutex
+	events []models.TodoEvent
+	// positions maps event IDs to their position
+	positions map[int64]int64
+}
+
+// NewMemoryEventRepository creates an empty MemoryEventRepository
+func NewMemoryEventRepository() *MemoryEventRepository {
+	return &MemoryEventRepository{positions: make(map[int64]int64)}
+}
+
+// Append stores a copy of event at the end of the log unless its ID is
+// already logged, and sets its Position
+func (r *MemoryEventRepository) Append(event *models.TodoEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if position, ok := r.positions[event.ID]; ok {
+		event.Position = position
+		return nil
+	}
+
+	event.Position = int64(len(r.events)) + 1
+	r.positions[event.ID] = event.Position
+	r.events = append(r.events, *copyEvent(event))
+	return nil
+}
+
+// ListSince returns copies of up to limit events logged after the given position
+func (r *MemoryEventRepository) ListSince(afterPosition int64, limit int) ([]*models.TodoEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var events []*models.TodoEvent
+	for i := max(afterPosition, 0); i < int64(len(r.events)) && len(events) < limit; i++ {
+		events = append(events, copyEvent(&r.events[i]))
+	}
+	return events, nil
+}
+
+// Locate returns the position of the event with the given ID, or where it
+// would have been had it been logged in ID order
+func (r *MemoryEventRepository) Locate(id int64) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if position, ok := r.positions[id]; ok {
+		return position, nil
+	}
+	for i, event := range r.events {
+		if event.ID > id {
+			return int64(i), nil
+		}
+	}
+	return int64(len(r.events)), nil
+}
+
+// Latest returns the position of the last event logged, or 0 for an empty log
+func (r *MemoryEventRepository) Latest() (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return int64(len(r.events)), nil
+}
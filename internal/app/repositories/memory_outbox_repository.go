@@ -0,0 +1,112 @@
+package repositories
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+// MemoryOutboxRepository is a goroutine-safe OutboxStore kept in memory.
+// A MemoryTodoRepository writes to it while holding its own lock, which
+// makes the change and its entry visible together.
+type MemoryOutboxRepository struct {
+	mu      sync.RWMutex
+	entries []models.OutboxEntry
+	seq     int64
+}
+
+// NewMemoryOutboxRepository creates an empty MemoryOutboxRepository
+func NewMemoryOutboxRepository() *MemoryOutboxRepository {
+	return &MemoryOutboxRepository{}
+}
+
+// ListPending returns copies of up to limit entries with an ID greater than afterID
+func (r *MemoryOutboxRepository) ListPending(afterID int64, limit int) ([]*models.OutboxEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	start := r.search(afterID + 1)
+
+	var entries []*models.OutboxEntry
+	for i := start; i < len(r.entries) && len(entries) < limit; i++ {
+		entry := r.entries[i]
+		entry.Event = copyEvent(entry.Event)
+		entries = append(entries, &entry)
+	}
+	return entries, nil
+}
+
+// Claim pushes the next attempt of entry back to until unless it changed
+// since it was listed
+func (r *MemoryOutboxRepository) Claim(entry *models.OutboxEntry, until time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.search(entry.Event.ID)
+	if i == len(r.entries) || r.entries[i].Event.ID != entry.Event.ID || !r.entries[i].NextAttemptAt.Equal(entry.NextAttemptAt) {
+		return false, nil
+	}
+	r.entries[i].NextAttemptAt = until.Round(0)
+	entry.NextAttemptAt = until
+	return true, nil
+}
+
+// Delete removes a relayed entry; deleting a missing entry is not an error
+func (r *MemoryOutboxRepository) Delete(id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if i := r.search(id); i < len(r.entries) && r.entries[i].Event.ID == id {
+		r.entries = append(r.entries[:i], r.entries[i+1:]...)
+	}
+	return nil
+}
+
+// RecordFailure saves the attempts, last error and next attempt of entry
+func (r *MemoryOutboxRepository) RecordFailure(entry *models.OutboxEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if i := r.search(entry.Event.ID); i < len(r.entries) && r.entries[i].Event.ID == entry.Event.ID {
+		stored := &r.entries[i]
+		stored.Attempts = entry.Attempts
+		stored.LastError = entry.LastError
+		stored.NextAttemptAt = entry.NextAttemptAt.Round(0)
+	}
+	return nil
+}
+
+// write stores a copy of event as a new entry due immediately and sets its ID
+func (r *MemoryOutboxRepository) write(event *models.TodoEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	r.seq++
+	event.ID = r.seq
+	r.entries = append(r.entries, models.OutboxEntry{
+		Event:         copyEvent(event),
+		NextAttemptAt: event.CreatedAt.Round(0),
+	})
+}
+
+// search returns the position of the first entry with an ID of at least id.
+// IDs increase with position, so it is a binary search.
+func (r *MemoryOutboxRepository) search(id int64) int {
+	return sort.Search(len(r.entries), func(i int) bool { return r.entries[i].Event.ID >= id })
+}
+
+// copyEvent returns a copy of event that shares nothing with it
+func copyEvent(event *models.TodoEvent) *models.TodoEvent {
+	copied := *event
+	if event.Todo != nil {
+		todo := *event.Todo
+		todo.Tags = append([]string(nil), event.Todo.Tags...)
+		copied.Todo = &todo
+	}
+	return &copied
+}
@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+// MemoryPreferenceRepository is a goroutine-safe PreferenceStore kept in memory
+type MemoryPreferenceRepository struct {
+	mu          sync.Mutex
+	preferences map[string]*models.Preferences
+}
+
+// NewMemoryPreferenceRepository creates an empty MemoryPreferenceRepository
+func NewMemoryPreferenceRepository() *MemoryPreferenceRepository {
+	return &MemoryPreferenceRepository{preferences: make(map[string]*models.Preferences)}
+}
+
+// SavePreferences stores a copy of preferences
+func (r *MemoryPreferenceRepository) SavePreferences(preferences *models.Preferences) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.preferences[preferences.UserID] = copyPreferences(preferences)
+	return nil
+}
+
+// GetPreferences returns a copy of the preferences of a user, or nil if
+// there are none
+func (r *MemoryPreferenceRepository) GetPreferences(userID string) (*models.Preferences, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	preferences, ok := r.preferences[userID]
+	if !ok {
+		return nil, nil
+	}
+	return copyPreferences(preferences), nil
+}
+
+// DeletePreferences removes the preferences of a user
+func (r *MemoryPreferenceRepository) DeletePreferences(userID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.preferences[userID]
+	delete(r.preferences, userID)
+	return ok, nil
+}
+
+// ClaimDueDigests leases up to limit due digests, earliest due first
+func (r *MemoryPreferenceRepository) ClaimDueDigests(now time.Time, lease time.Duration, limit int) ([]*models.Preferences, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var due []*models.Preferences
+	for _, preferences := range r.preferences {
+		if preferences.NextDigestAt != nil && !preferences.NextDigestAt.After(now) {
+			due = append(due, preferences)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		a, b := due[i], due[j]
+		if !a.NextDigestAt.Equal(*b.NextDigestAt) {
+			return a.NextDigestAt.Before(*b.NextDigestAt)
+		}
+		return a.UserID < b.UserID
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]*models.Preferences, 0, len(due))
+	for _, preferences := range due {
+		leaseUntil := now.Add(lease)
+		preferences.NextDigestAt = &leaseUntil
+		claimed = append(claimed, copyPreferences(preferences))
+	}
+	return claimed, nil
+}
+
+// RecordDigest updates the digest fields of preferences if they are still
+// claimed until claimedUntil
+func (r *MemoryPreferenceRepository) RecordDigest(preferences *models.Preferences, claimedUntil time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.preferences[preferences.UserID]
+	if !ok || stored.NextDigestAt == nil || !stored.NextDigestAt.Equal(claimedUntil) {
+		return false, nil
+	}
+	stored.NextDigestAt = copyTime(preferences.NextDigestAt)
+	stored.LastDigestAt = copyTime(preferences.LastDigestAt)
+	stored.LastDigestError = preferences.LastDigestError
+	return true, nil
+}
+
+func copyPreferences(preferences *models.Preferences) *models.Preferences {
+	copied := *preferences
+	copied.NextDigestAt = copyTime(preferences.NextDigestAt)
+	copied.LastDigestAt = copyTime(preferences.LastDigestAt)
+	copied.UpdatedAt = copyTime(preferences.UpdatedAt)
+	return &copied
+}
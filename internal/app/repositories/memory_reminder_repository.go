@@ -0,0 +1,145 @@
+package repositories
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+// MemoryReminderRepository is a goroutine-safe ReminderStore kept in memory
+type MemoryReminderRepository struct {
+	mu        sync.Mutex
+	reminders map[string]*models.Reminder
+}
+
+// NewMemoryReminderRepository creates an empty MemoryReminderRepository
+func NewMemoryReminderRepository() *MemoryReminderRepository {
+	return &MemoryReminderRepository{
+		reminders: make(map[string]*models.Reminder),
+	}
+}
+
+// CreateReminder stores a copy of reminder
+func (r *MemoryReminderRepository) CreateReminder(reminder *models.Reminder) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.reminders[reminder.ID] = copyReminder(reminder)
+	return nil
+}
+
+// GetReminder returns a copy of the reminder with the given ID, or nil if it does not exist
+func (r *MemoryReminderRepository) GetReminder(id string) (*models.Reminder, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reminder, ok := r.reminders[id]
+	if !ok {
+		return nil, nil
+	}
+	return copyReminder(reminder), nil
+}
+
+// ListReminders returns copies of the reminders of a todo, oldest first
+func (r *MemoryReminderRepository) ListReminders(todoID string) ([]*models.Reminder, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var reminders []*models.Reminder
+	for _, reminder := range r.reminders {
+		if reminder.TodoID == todoID {
+			reminders = append(reminders, copyReminder(reminder))
+		}
+	}
+	sort.Slice(reminders, func(i, j int) bool {
+		a, b := reminders[i], reminders[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	})
+	return reminders, nil
+}
+
+// UpdateReminder replaces the stored reminder with a copy of reminder
+func (r *MemoryReminderRepository) UpdateReminder(reminder *models.Reminder) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.reminders[reminder.ID]; ok {
+		r.reminders[reminder.ID] = copyReminder(reminder)
+	}
+	return nil
+}
+
+// DeleteReminder removes a reminder
+func (r *MemoryReminderRepository) DeleteReminder(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.reminders, id)
+	return nil
+}
+
+// DeleteTodoReminders removes the reminders of a todo
+func (r *MemoryReminderRepository) DeleteTodoReminders(todoID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, reminder := range r.reminders {
+		if reminder.TodoID == todoID {
+			delete(r.reminders, id)
+		}
+	}
+	return nil
+}
+
+// ClaimDueReminders leases up to limit due reminders, earliest due first
+func (r *MemoryReminderRepository) ClaimDueReminders(now time.Time, lease time.Duration, limit int) ([]*models.Reminder, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var due []*models.Reminder
+	for _, reminder := range r.reminders {
+		if reminder.Status == models.ReminderPending && reminder.NextAttemptAt != nil && !reminder.NextAttemptAt.After(now) {
+			due = append(due, reminder)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		a, b := due[i], due[j]
+		if !a.NextAttemptAt.Equal(*b.NextAttemptAt) {
+			return a.NextAttemptAt.Before(*b.NextAttemptAt)
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]*models.Reminder, 0, len(due))
+	for _, reminder := range due {
+		leaseUntil := now.Add(lease)
+		reminder.NextAttemptAt = &leaseUntil
+		claimed = append(claimed, copyReminder(reminder))
+	}
+	return claimed, nil
+}
+
+func copyReminder(reminder *models.Reminder) *models.Reminder {
+	copied := *reminder
+	copied.At = copyTime(reminder.At)
+	copied.FireAt = copyTime(reminder.FireAt)
+	copied.NextAttemptAt = copyTime(reminder.NextAttemptAt)
+	copied.SentAt = copyTime(reminder.SentAt)
+	return &copied
+}
+
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	copied := *t
+	return &copied
+}
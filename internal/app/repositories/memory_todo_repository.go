@@ -0,0 +1,269 @@
+package repositories
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+// MemoryTodoRepository is a goroutine-safe TodoStore that keeps todos in memory.
+// It mirrors the SQL repositories' ordering, filtering and not-found semantics
+// and is intended for tests and demos; nothing survives a restart.
+type MemoryTodoRepository struct {
+	mu     sync.RWMutex
+	todos  map[string]*memoryTodo
+	seq    int64
+	outbox *MemoryOutboxRepository
+}
+
+// memoryTodo pairs a stored todo with its insertion sequence, which stands in
+// for SQLite's rowid when priority and created_at tie
+type memoryTodo struct {
+	todo models.Todo
+	seq  int64
+}
+
+// NewMemoryTodoRepository creates an empty MemoryTodoRepository writing
+// events to outbox. With a nil outbox events are dropped.
+func NewMemoryTodoRepository(outbox *MemoryOutboxRepository) *MemoryTodoRepository {
+	return &MemoryTodoRepository{
+		todos:  make(map[string]*memoryTodo),
+		outbox: outbox,
+	}
+}
+
+// Create stores a copy of todo and writes event to the outbox
+func (r *MemoryTodoRepository) Create(todo *models.Todo, event *models.TodoEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.todos[todo.ID]; exists {
+		return fmt.Errorf("failed to create todo %s: %w", todo.ID, models.ErrConflict)
+	}
+
+	todo.Version = 1
+	r.seq++
+	r.todos[todo.ID] = &memoryTodo{todo: normalize(*todo), seq: r.seq}
+	r.recordEvent(event, todo, todo.Project)
+	return nil
+}
+
+// GetByID returns a copy of the todo with the given ID, or nil if it does not exist
+func (r *MemoryTodoRepository) GetByID(id string) (*models.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored, ok := r.todos[id]
+	if !ok {
+		return nil, nil // Not found
+	}
+
+	return stored.copy(), nil
+}
+
+// GetByIDs returns copies of the todos with the given IDs, skipping missing ones
+func (r *MemoryTodoRepository) GetByIDs(ids []string) ([]*models.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var todos []*models.Todo
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if stored, ok := r.todos[id]; ok && !seen[id] {
+			seen[id] = true
+			todos = append(todos, stored.copy())
+		}
+	}
+	return todos, nil
+}
+
+// ListSubtasks returns copies of the subtasks of every given todo, ordered as Find orders them without a sort
+func (r *MemoryTodoRepository) ListSubtasks(parentIDs []string) ([]*models.Todo, error) {
+	parents := make(map[string]bool, len(parentIDs))
+	for _, id := range parentIDs {
+		parents[id] = true
+	}
+
+	todos := r.filter(func(todo *models.Todo) bool { return todo.ParentID != "" && parents[todo.ParentID] })
+	sortTodos(todos, models.TodoQuery{})
+	return todos, nil
+}
+
+// Find returns copies of a page of the todos matching query
+func (r *MemoryTodoRepository) Find(query models.TodoQuery) ([]*models.Todo, error) {
+	todos := r.filter(func(todo *models.Todo) bool { return matchesFilter(todo, query.Filter) })
+	sortTodos(todos, query)
+	if query.After != nil {
+		less := lessForQuery(query)
+		todos = todos[sort.Search(len(todos), func(i int) bool { return less(query.After, todos[i]) }):]
+	}
+
+	if query.Limit == 0 {
+		return todos, nil
+	}
+	if query.Offset >= len(todos) {
+		return nil, nil
+	}
+	todos = todos[query.Offset:]
+	if len(todos) > query.Limit {
+		todos = todos[:query.Limit]
+	}
+	return todos, nil
+}
+
+// Count tallies the todos matching filter
+func (r *MemoryTodoRepository) Count(filter models.TodoFilter) (*models.TodoCounts, error) {
+	var counts models.TodoCounts
+	for _, todo := range r.filter(func(todo *models.Todo) bool { return matchesFilter(todo, filter) }) {
+		counts.Total++
+		if todo.Completed {
+			counts.Completed++
+		}
+	}
+	counts.Open = counts.Total - counts.Completed
+	return &counts, nil
+}
+
+// Update applies update to the stored todo and writes event to the outbox,
+// returning nil if it does not exist. A version other than zero must match
+// the stored one.
+func (r *MemoryTodoRepository) Update(id string, version int64, update *models.TodoUpdate, event *models.TodoEvent) (*models.Todo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.todos[id]
+	if !ok {
+		return nil, nil // Not found
+	}
+	if version != 0 && stored.todo.Version != version {
+		return nil, fmt.Errorf("todo %s is at version %d, not %d: %w", id, stored.todo.Version, version, models.ErrVersionMismatch)
+	}
+
+	todo := stored.copy()
+	if err := applyUpdate(todo, update); err != nil {
+		return nil, err
+	}
+	todo.UpdatedAt = time.Now().UTC()
+	todo.Version++
+
+	project := stored.todo.Project
+	stored.todo = normalize(*todo)
+	r.recordEvent(event, todo, project)
+	return todo, nil
+}
+
+// Delete removes the todo with the given ID and writes event to the outbox;
+// deleting a missing todo is not an error, deleting one with subtasks is.
+// A version other than zero must match the stored one.
+func (r *MemoryTodoRepository) Delete(id string, version int64, event *models.TodoEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.todos[id]
+	if !ok {
+		return nil
+	}
+	if version != 0 && stored.todo.Version != version {
+		return fmt.Errorf("todo %s is at version %d, not %d: %w", id, stored.todo.Version, version, models.ErrVersionMismatch)
+	}
+
+	subtasks := 0
+	for _, other := range r.todos {
+		if other.todo.ParentID == id {
+			subtasks++
+		}
+	}
+	if subtasks > 0 {
+		return fmt.Errorf("todo %s has %d subtasks: %w", id, subtasks, models.ErrConflict)
+	}
+
+	delete(r.todos, id)
+	r.recordEvent(event, stored.copy(), stored.todo.Project)
+	return nil
+}
+
+// Import creates or replaces every todo in todos under one lock, so other
+// callers see all of them or none
+func (r *MemoryTodoRepository) Import(todos []*models.Todo, events []*models.TodoEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, todo := range todos {
+		eventType, project := models.EventCreated, todo.Project
+		if stored, exists := r.todos[todo.ID]; exists {
+			eventType, project = models.EventUpdated, stored.todo.Project
+			todo.CreatedAt = stored.todo.CreatedAt
+			todo.Version = stored.todo.Version + 1
+			stored.todo = normalize(*todo)
+		} else {
+			todo.Version = 1
+			r.seq++
+			r.todos[todo.ID] = &memoryTodo{todo: normalize(*todo), seq: r.seq}
+		}
+
+		if events != nil {
+			events[i].Type = eventType
+			r.recordEvent(events[i], todo, project)
+		}
+	}
+	return nil
+}
+
+// filter returns copies of the todos for which match reports true
+func (r *MemoryTodoRepository) filter(match func(todo *models.Todo) bool) []*models.Todo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var todos []*models.Todo
+	for _, stored := range r.todos {
+		if match(&stored.todo) {
+			todos = append(todos, stored.copy())
+		}
+	}
+	return todos
+}
+
+// sortTodos orders todos the way orderClause orders rows
+func sortTodos(todos []*models.Todo, query models.TodoQuery) {
+	less := lessForQuery(query)
+	sort.Slice(todos, func(i, j int) bool { return less(todos[i], todos[j]) })
+}
+
+// copy returns a copy of the stored todo that shares nothing with it
+func (m *memoryTodo) copy() *models.Todo {
+	todo := m.todo
+	todo.Tags = append([]string{}, m.todo.Tags...)
+	todo.FormatDates()
+	return &todo
+}
+
+// recordEvent completes event, if any, with todo, which was in project
+// before the change, and writes it to the outbox; r.mu must be held so the
+// change and the entry appear together
+func (r *MemoryTodoRepository) recordEvent(event *models.TodoEvent, todo *models.Todo, project string) {
+	if event == nil || r.outbox == nil {
+		return
+	}
+	event.TodoID = todo.ID
+	event.Todo = todo
+	if project != todo.Project {
+		event.PreviousProject = project
+	}
+	r.outbox.write(event)
+}
+
+// normalize strips monotonic clock readings and converts times to UTC so
+// stored times compare the way they would after a round trip through the
+// database, and copies the tags
+func normalize(todo models.Todo) models.Todo {
+	todo.Tags = append([]string{}, todo.Tags...)
+	todo.CreatedAt = todo.CreatedAt.Round(0).UTC()
+	todo.UpdatedAt = todo.UpdatedAt.Round(0).UTC()
+	if todo.DueDate.Valid {
+		todo.DueDate.Time = todo.DueDate.Time.Round(0).UTC()
+	}
+	return todo
+}
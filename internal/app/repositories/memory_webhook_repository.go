@@ -0,0 +1,220 @@
+package repositories
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+// MemoryWebhookRepository is a goroutine-safe WebhookStore kept in memory
+type MemoryWebhookRepository struct {
+	mu         sync.Mutex
+	hooks      map[string]*models.Webhook
+	deliveries map[string]*models.WebhookDelivery
+	attempts   map[string][]*models.WebhookAttempt
+	attemptSeq int64
+}
+
+// NewMemoryWebhookRepository creates an empty MemoryWebhookRepository
+func NewMemoryWebhookRepository() *MemoryWebhookRepository {
+	return &MemoryWebhookRepository{
+		hooks:      make(map[string]*models.Webhook),
+		deliveries: make(map[string]*models.WebhookDelivery),
+		attempts:   make(map[string][]*models.WebhookAttempt),
+	}
+}
+
+// CreateWebhook stores a copy of hook
+func (r *MemoryWebhookRepository) CreateWebhook(hook *models.Webhook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.hooks[hook.ID] = copyWebhook(hook)
+	return nil
+}
+
+// GetWebhook returns a copy of the webhook with the given ID, or nil if it does not exist
+func (r *MemoryWebhookRepository) GetWebhook(id string) (*models.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	hook, ok := r.hooks[id]
+	if !ok {
+		return nil, nil
+	}
+	return copyWebhook(hook), nil
+}
+
+// ListWebhooks returns copies of every webhook, oldest first
+func (r *MemoryWebhookRepository) ListWebhooks() ([]*models.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var hooks []*models.Webhook
+	for _, hook := range r.hooks {
+		hooks = append(hooks, copyWebhook(hook))
+	}
+	sort.Slice(hooks, func(i, j int) bool {
+		if !hooks[i].CreatedAt.Equal(hooks[j].CreatedAt) {
+			return hooks[i].CreatedAt.Before(hooks[j].CreatedAt)
+		}
+		return hooks[i].ID < hooks[j].ID
+	})
+	return hooks, nil
+}
+
+// UpdateWebhook replaces the stored webhook with a copy of hook
+func (r *MemoryWebhookRepository) UpdateWebhook(hook *models.Webhook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.hooks[hook.ID]; ok {
+		r.hooks[hook.ID] = copyWebhook(hook)
+	}
+	return nil
+}
+
+// DeleteWebhook removes a webhook with its deliveries and their attempts
+func (r *MemoryWebhookRepository) DeleteWebhook(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.hooks, id)
+	for deliveryID, delivery := range r.deliveries {
+		if delivery.WebhookID == id {
+			delete(r.deliveries, deliveryID)
+			delete(r.attempts, deliveryID)
+		}
+	}
+	return nil
+}
+
+// EnqueueDelivery stores a copy of delivery, unless the webhook already has
+// a delivery of the event and delivery is not a redelivery
+func (r *MemoryWebhookRepository) EnqueueDelivery(delivery *models.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if delivery.RedeliveryOf == "" {
+		for _, queued := range r.deliveries {
+			if queued.WebhookID == delivery.WebhookID && queued.EventID == delivery.EventID && queued.RedeliveryOf == "" {
+				return nil
+			}
+		}
+	}
+	r.deliveries[delivery.ID] = copyDelivery(delivery)
+	return nil
+}
+
+// GetDelivery returns a copy of the delivery with the given ID, or nil if it does not exist
+func (r *MemoryWebhookRepository) GetDelivery(id string) (*models.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delivery, ok := r.deliveries[id]
+	if !ok {
+		return nil, nil
+	}
+	return copyDelivery(delivery), nil
+}
+
+// ListDeliveries returns copies of up to limit deliveries of a webhook, newest first
+func (r *MemoryWebhookRepository) ListDeliveries(webhookID string, limit int) ([]*models.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deliveries []*models.WebhookDelivery
+	for _, delivery := range r.deliveries {
+		if delivery.WebhookID == webhookID {
+			deliveries = append(deliveries, copyDelivery(delivery))
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		a, b := deliveries[i], deliveries[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.EventID > b.EventID
+	})
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+// ClaimDueDeliveries leases up to limit due deliveries, earliest due first
+func (r *MemoryWebhookRepository) ClaimDueDeliveries(now time.Time, lease time.Duration, limit int) ([]*models.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var due []*models.WebhookDelivery
+	for _, delivery := range r.deliveries {
+		if delivery.Status == models.DeliveryPending && !delivery.NextAttemptAt.After(now) {
+			due = append(due, delivery)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		a, b := due[i], due[j]
+		if !a.NextAttemptAt.Equal(b.NextAttemptAt) {
+			return a.NextAttemptAt.Before(b.NextAttemptAt)
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]*models.WebhookDelivery, 0, len(due))
+	for _, delivery := range due {
+		delivery.NextAttemptAt = now.Add(lease)
+		claimed = append(claimed, copyDelivery(delivery))
+	}
+	return claimed, nil
+}
+
+// RecordAttempt saves a copy of delivery and appends a copy of attempt
+func (r *MemoryWebhookRepository) RecordAttempt(delivery *models.WebhookDelivery, attempt *models.WebhookAttempt) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.deliveries[delivery.ID]; !ok {
+		return nil // Deleted along with its webhook
+	}
+	r.deliveries[delivery.ID] = copyDelivery(delivery)
+
+	if attempt != nil {
+		r.attemptSeq++
+		attempt.ID = r.attemptSeq
+		stored := *attempt
+		r.attempts[delivery.ID] = append(r.attempts[delivery.ID], &stored)
+	}
+	return nil
+}
+
+// ListAttempts returns copies of the attempt log of a delivery, oldest first
+func (r *MemoryWebhookRepository) ListAttempts(deliveryID string) ([]*models.WebhookAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var attempts []*models.WebhookAttempt
+	for _, attempt := range r.attempts[deliveryID] {
+		copied := *attempt
+		attempts = append(attempts, &copied)
+	}
+	return attempts, nil
+}
+
+func copyWebhook(hook *models.Webhook) *models.Webhook {
+	copied := *hook
+	copied.Events = append([]string{}, hook.Events...)
+	return &copied
+}
+
+func copyDelivery(delivery *models.WebhookDelivery) *models.WebhookDelivery {
+	copied := *delivery
+	copied.Payload = append([]byte(nil), delivery.Payload...)
+	copied.AttemptLog = nil
+	return &copied
+}
@@ -0,0 +1,185 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/database"
+)
+
+// OutboxStore is the reading side of the transactional outbox. Todo stores
+// write entries in the same transaction as the change they describe; the
+// relay lists them in ID order, claims each before relaying it, deletes
+// those relayed and reschedules the rest.
+type OutboxStore interface {
+	// ListPending returns up to limit entries with an ID greater than afterID, oldest first
+	ListPending(afterID int64, limit int) ([]*models.OutboxEntry, error)
+	// Claim pushes the next attempt of entry back to until, so that no other
+	// replica relays it meanwhile, and reports whether it did. It fails to
+	// when entry was claimed, rescheduled or deleted since it was listed.
+	Claim(entry *models.OutboxEntry, until time.Time) (bool, error)
+	// Delete removes a relayed entry; deleting a missing entry is a no-op
+	Delete(id int64) error
+	// RecordFailure saves the attempts, last error and next attempt of entry
+	RecordFailure(entry *models.OutboxEntry) error
+}
+
+// OutboxRepository reads the outbox table
+type OutboxRepository struct {
+	db     *sql.DB
+	rebind func(query string) string
+}
+
+// NewOutboxRepository creates the OutboxStore matching the dialect of db.
+// With the memory dialect every call returns a new, empty store that no
+// todo store writes to; use NewMemoryTodoRepository to pair the two.
+func NewOutboxRepository(db *database.DB) OutboxStore {
+	switch db.Dialect {
+	case database.DialectPostgres:
+		return &OutboxRepository{db: db.DB, rebind: rebindDollar}
+	case database.DialectMemory:
+		return NewMemoryOutboxRepository()
+	default:
+		return &OutboxRepository{db: db.DB, rebind: func(query string) string { return query }}
+	}
+}
+
+// ListPending returns up to limit entries with an ID greater than afterID, oldest first
+func (r *OutboxRepository) ListPending(afterID int64, limit int) ([]*models.OutboxEntry, error) {
+	query := `
+		SELECT id, aggregate_id, event_type, actor, payload, previous_project, attempts, last_error, next_attempt_at, created_at
+		FROM outbox
+		WHERE id > ?
+		ORDER BY id
+		LIMIT ?
+	`
+
+	rows, err := r.db.Query(r.rebind(query), afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query outbox: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*models.OutboxEntry
+	for rows.Next() {
+		event := &models.TodoEvent{}
+		entry := &models.OutboxEntry{Event: event}
+		var payload string
+		err := rows.Scan(
+			&event.ID,
+			&event.TodoID,
+			&event.Type,
+			&event.Actor,
+			&payload,
+			&event.PreviousProject,
+			&entry.Attempts,
+			&entry.LastError,
+			&entry.NextAttemptAt,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan outbox row: %w", err)
+		}
+		if err := json.Unmarshal([]byte(payload), &event.Todo); err != nil {
+			return nil, fmt.Errorf("failed to decode outbox payload: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating outbox rows: %w", err)
+	}
+
+	return entries, nil
+}
+
+// Claim leases entry until the given time with a conditional update, so
+// concurrent replicas never claim the same entry
+func (r *OutboxRepository) Claim(entry *models.OutboxEntry, until time.Time) (bool, error) {
+	query := `
+		UPDATE outbox
+		SET next_attempt_at = ?
+		WHERE id = ? AND next_attempt_at = ?
+	`
+
+	result, err := r.db.Exec(r.rebind(query), until.UTC(), entry.Event.ID, entry.NextAttemptAt.UTC())
+	if err != nil {
+		return false, fmt.Errorf("failed to claim outbox entry: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim outbox entry: %w", err)
+	}
+	if affected == 0 {
+		return false, nil
+	}
+	entry.NextAttemptAt = until
+	return true, nil
+}
+
+// Delete removes a relayed entry
+func (r *OutboxRepository) Delete(id int64) error {
+	if _, err := r.db.Exec(r.rebind("DELETE FROM outbox WHERE id = ?"), id); err != nil {
+		return fmt.Errorf("failed to delete outbox entry: %w", err)
+	}
+	return nil
+}
+
+// RecordFailure saves the attempts, last error and next attempt of entry
+func (r *OutboxRepository) RecordFailure(entry *models.OutboxEntry) error {
+	query := `
+		UPDATE outbox
+		SET attempts = ?, last_error = ?, next_attempt_at = ?
+		WHERE id = ?
+	`
+
+	_, err := r.db.Exec(
+		r.rebind(query),
+		entry.Attempts,
+		entry.LastError,
+		entry.NextAttemptAt.UTC(),
+		entry.Event.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update outbox entry: %w", err)
+	}
+	return nil
+}
+
+// writeOutbox adds event to the outbox within tx and sets its ID. The event
+// is due immediately.
+func writeOutbox(tx *sql.Tx, rebind func(query string) string, event *models.TodoEvent) error {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	payload, err := json.Marshal(event.Todo)
+	if err != nil {
+		return fmt.Errorf("failed to encode event payload: %w", err)
+	}
+
+	query := `
+		INSERT INTO outbox (aggregate_id, event_type, actor, payload, previous_project, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`
+
+	err = tx.QueryRow(
+		rebind(query),
+		event.TodoID,
+		event.Type,
+		event.Actor,
+		string(payload),
+		event.PreviousProject,
+		event.CreatedAt.UTC(),
+		event.CreatedAt.UTC(),
+	).Scan(&event.ID)
+
+	if err != nil {
+		return fmt.Errorf("failed to write outbox entry: %w", err)
+	}
+	return nil
+}
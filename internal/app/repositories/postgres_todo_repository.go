@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// NewPostgresTodoRepository creates a TodoRepository backed by a PostgreSQL connection
+func NewPostgresTodoRepository(db *sql.DB) *TodoRepository {
+	return &TodoRepository{
+		db:                db,
+		rebind:            rebindDollar,
+		isUniqueViolation: postgresIsUniqueViolation,
+	}
+}
+
+// postgresIsUniqueViolation reports whether err is a unique_violation (SQLSTATE 23505)
+func postgresIsUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// rebindDollar rewrites ? placeholders into PostgreSQL's $1, $2, ... form.
+// A ? inside a quoted string or identifier is left alone; quotes are
+// doubled to escape them, so each one toggles. Backslash escapes (E'...'),
+// dollar-quoted strings and comments are not recognised, so queries must
+// not put a ? in them.
+func rebindDollar(query string) string {
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+
+	n := 0
+	var quote rune
+	for _, r := range query {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
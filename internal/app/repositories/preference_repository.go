@@ -0,0 +1,216 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/database"
+)
+
+// PreferenceStore persists the preferences of users, keyed by user ID.
+// Getters return (nil, nil) when nothing matches.
+type PreferenceStore interface {
+	// SavePreferences stores preferences, replacing those its user had
+	SavePreferences(preferences *models.Preferences) error
+	// GetPreferences returns the preferences of a user
+	GetPreferences(userID string) (*models.Preferences, error)
+	// DeletePreferences removes the preferences of a user, reporting
+	// whether there were any
+	DeletePreferences(userID string) (bool, error)
+	// ClaimDueDigests returns up to limit preferences whose digest is due at
+	// now, pushing it back by lease so that no other replica sends it
+	// meanwhile
+	ClaimDueDigests(now time.Time, lease time.Duration, limit int) ([]*models.Preferences, error)
+	// RecordDigest saves the digest fields of preferences claimed until
+	// claimedUntil. It reports false, saving nothing, if the preferences were
+	// replaced or deleted since they were claimed.
+	RecordDigest(preferences *models.Preferences, claimedUntil time.Time) (bool, error)
+}
+
+// PreferenceRepository stores preferences in the preferences table
+type PreferenceRepository struct {
+	db     *sql.DB
+	rebind func(query string) string
+}
+
+// NewPreferenceRepository creates the PreferenceStore matching the dialect
+// of db. With the memory dialect every call returns a new, empty store.
+func NewPreferenceRepository(db *database.DB) PreferenceStore {
+	switch db.Dialect {
+	case database.DialectPostgres:
+		return &PreferenceRepository{db: db.DB, rebind: rebindDollar}
+	case database.DialectMemory:
+		return NewMemoryPreferenceRepository()
+	default:
+		return &PreferenceRepository{db: db.DB, rebind: func(query string) string { return query }}
+	}
+}
+
+const preferenceColumns = `user_id, email, timezone, digest_frequency, digest_hour, digest_weekday,
+	next_digest_at, last_digest_at, last_digest_error, updated_at`
+
+// SavePreferences inserts preferences, or replaces every field of those of
+// its user
+func (r *PreferenceRepository) SavePreferences(preferences *models.Preferences) error {
+	query := `
+		INSERT INTO preferences (` + preferenceColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET
+			email = excluded.email,
+			timezone = excluded.timezone,
+			digest_frequency = excluded.digest_frequency,
+			digest_hour = excluded.digest_hour,
+			digest_weekday = excluded.digest_weekday,
+			next_digest_at = excluded.next_digest_at,
+			last_digest_at = excluded.last_digest_at,
+			last_digest_error = excluded.last_digest_error,
+			updated_at = excluded.updated_at
+	`
+
+	_, err := r.db.Exec(
+		r.rebind(query),
+		preferences.UserID,
+		preferences.Email,
+		preferences.Timezone,
+		preferences.DigestFrequency,
+		preferences.DigestHour,
+		preferences.DigestWeekday,
+		nullTime(preferences.NextDigestAt),
+		nullTime(preferences.LastDigestAt),
+		preferences.LastDigestError,
+		nullTime(preferences.UpdatedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save preferences: %w", err)
+	}
+	return nil
+}
+
+// GetPreferences retrieves the preferences of a user
+func (r *PreferenceRepository) GetPreferences(userID string) (*models.Preferences, error) {
+	query := "SELECT " + preferenceColumns + " FROM preferences WHERE user_id = ?"
+
+	preferences, err := scanPreferences(r.db.QueryRow(r.rebind(query), userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // Not found
+		}
+		return nil, fmt.Errorf("failed to get preferences: %w", err)
+	}
+	return preferences, nil
+}
+
+// DeletePreferences removes the preferences of a user
+func (r *PreferenceRepository) DeletePreferences(userID string) (bool, error) {
+	result, err := r.db.Exec(r.rebind("DELETE FROM preferences WHERE user_id = ?"), userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete preferences: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	return rows > 0, nil
+}
+
+// ClaimDueDigests leases up to limit due digests. Each row is claimed with
+// a conditional update, so concurrent replicas never claim the same one.
+func (r *PreferenceRepository) ClaimDueDigests(now time.Time, lease time.Duration, limit int) ([]*models.Preferences, error) {
+	now = now.UTC()
+	query := `
+		SELECT ` + preferenceColumns + `
+		FROM preferences
+		WHERE next_digest_at <= ?
+		ORDER BY next_digest_at, user_id
+		LIMIT ?
+	`
+	rows, err := r.db.Query(r.rebind(query), now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query preferences: %w", err)
+	}
+	var due []*models.Preferences
+	for rows.Next() {
+		preferences, err := scanPreferences(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan preferences row: %w", err)
+		}
+		due = append(due, preferences)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating preferences rows: %w", err)
+	}
+
+	claim := "UPDATE preferences SET next_digest_at = ? WHERE user_id = ? AND next_digest_at = ?"
+
+	var claimed []*models.Preferences
+	for _, preferences := range due {
+		leaseUntil := now.Add(lease)
+		result, err := r.db.Exec(r.rebind(claim), leaseUntil, preferences.UserID, preferences.NextDigestAt.UTC())
+		if err != nil {
+			return nil, fmt.Errorf("failed to claim digest: %w", err)
+		}
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			continue // Claimed by someone else
+		}
+		preferences.NextDigestAt = &leaseUntil
+		claimed = append(claimed, preferences)
+	}
+	return claimed, nil
+}
+
+// RecordDigest updates the digest fields of preferences if they are still
+// claimed until claimedUntil
+func (r *PreferenceRepository) RecordDigest(preferences *models.Preferences, claimedUntil time.Time) (bool, error) {
+	query := `
+		UPDATE preferences
+		SET next_digest_at = ?, last_digest_at = ?, last_digest_error = ?
+		WHERE user_id = ? AND next_digest_at = ?
+	`
+
+	result, err := r.db.Exec(
+		r.rebind(query),
+		nullTime(preferences.NextDigestAt),
+		nullTime(preferences.LastDigestAt),
+		preferences.LastDigestError,
+		preferences.UserID,
+		claimedUntil.UTC(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to record digest: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	return rows > 0, nil
+}
+
+func scanPreferences(row rowScanner) (*models.Preferences, error) {
+	var preferences models.Preferences
+	var nextDigestAt, lastDigestAt sql.NullTime
+	var updatedAt time.Time
+	err := row.Scan(
+		&preferences.UserID,
+		&preferences.Email,
+		&preferences.Timezone,
+		&preferences.DigestFrequency,
+		&preferences.DigestHour,
+		&preferences.DigestWeekday,
+		&nextDigestAt,
+		&lastDigestAt,
+		&preferences.LastDigestError,
+		&updatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	preferences.NextDigestAt = timePtr(nextDigestAt)
+	preferences.LastDigestAt = timePtr(lastDigestAt)
+	preferences.UpdatedAt = &updatedAt
+	return &preferences, nil
+}
@@ -0,0 +1,27 @@
+package repositories_test
+
+import (
+	"testing"
+
+	"github.com/teguh/go-todo-api/internal/app/repositories"
+)
+
+func TestRebindDollar(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT 1", "SELECT 1"},
+		{"WHERE id = ? AND version = ?", "WHERE id = $1 AND version = $2"},
+		{"WHERE title = '?' AND id = ?", "WHERE title = '?' AND id = $1"},
+		{"WHERE title = 'it''s?' AND id = ?", "WHERE title = 'it''s?' AND id = $1"},
+		{`SELECT "what?" FROM t WHERE id = ?`, `SELECT "what?" FROM t WHERE id = $1`},
+		{`LIKE ? ESCAPE '\' OR description LIKE ?`, `LIKE $1 ESCAPE '\' OR description LIKE $2`},
+		{"WHERE note = 'déjà vu?' AND ? < 10", "WHERE note = 'déjà vu?' AND $1 < 10"},
+	}
+	for _, tt := range tests {
+		if got := repositories.RebindDollar(tt.query); got != tt.want {
+			t.Errorf("RebindDollar(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/database"
+)
+
+// ReminderStore persists reminders, which are due once their next attempt
+// is. Getters return (nil, nil) when nothing matches.
+type ReminderStore interface {
+	CreateReminder(reminder *models.Reminder) error
+	GetReminder(id string) (*models.Reminder, error)
+	// ListReminders returns the reminders of a todo, oldest first
+	ListReminders(todoID string) ([]*models.Reminder, error)
+	// UpdateReminder saves the schedule and state of reminder
+	UpdateReminder(reminder *models.Reminder) error
+	DeleteReminder(id string) error
+	// DeleteTodoReminders removes the reminders of a deleted todo
+	DeleteTodoReminders(todoID string) error
+	// ClaimDueReminders returns up to limit pending reminders due at now,
+	// pushing their next attempt back by lease so that no other replica
+	// sends them meanwhile. A replica that dies mid-send thus only delays
+	// its reminders.
+	ClaimDueReminders(now time.Time, lease time.Duration, limit int) ([]*models.Reminder, error)
+}
+
+// ReminderRepository stores reminders in the reminders table, from which
+// they are dropped along with their todo
+type ReminderRepository struct {
+	db     *sql.DB
+	rebind func(query string) string
+}
+
+// NewReminderRepository creates the ReminderStore matching the dialect of
+// db. With the memory dialect every call returns a new, empty store.
+func NewReminderRepository(db *database.DB) ReminderStore {
+	switch db.Dialect {
+	case database.DialectPostgres:
+		return &ReminderRepository{db: db.DB, rebind: rebindDollar}
+	case database.DialectMemory:
+		return NewMemoryReminderRepository()
+	default:
+		return &ReminderRepository{db: db.DB, rebind: func(query string) string { return query }}
+	}
+}
+
+const reminderColumns = `id, todo_id, user_id, remind_at, before_due, channel, recipient, status,
+	fire_at, attempts, next_attempt_at, last_error, sent_at, created_at, updated_at`
+
+// CreateReminder inserts a new reminder
+func (r *ReminderRepository) CreateReminder(reminder *models.Reminder) error {
+	query := `
+		INSERT INTO reminders (` + reminderColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := r.db.Exec(
+		r.rebind(query),
+		reminder.ID,
+		reminder.TodoID,
+		reminder.UserID,
+		nullTime(reminder.At),
+		reminder.Before,
+		reminder.Channel,
+		reminder.Recipient,
+		reminder.Status,
+		nullTime(reminder.FireAt),
+		reminder.Attempts,
+		nullTime(reminder.NextAttemptAt),
+		reminder.LastError,
+		nullTime(reminder.SentAt),
+		reminder.CreatedAt.UTC(),
+		reminder.UpdatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create reminder: %w", err)
+	}
+	return nil
+}
+
+// GetReminder retrieves a reminder by its ID
+func (r *ReminderRepository) GetReminder(id string) (*models.Reminder, error) {
+	query := "SELECT " + reminderColumns + " FROM reminders WHERE id = ?"
+
+	reminder, err := scanReminder(r.db.QueryRow(r.rebind(query), id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // Not found
+		}
+		return nil, fmt.Errorf("failed to get reminder by ID: %w", err)
+	}
+	return reminder, nil
+}
+
+// ListReminders retrieves the reminders of a todo, oldest first
+func (r *ReminderRepository) ListReminders(todoID string) ([]*models.Reminder, error) {
+	query := `
+		SELECT ` + reminderColumns + `
+		FROM reminders
+		WHERE todo_id = ?
+		ORDER BY created_at, id
+	`
+	return r.queryReminders(query, todoID)
+}
+
+// UpdateReminder saves everything about a reminder but its todo, user and creation time
+func (r *ReminderRepository) UpdateReminder(reminder *models.Reminder) error {
+	query := `
+		UPDATE reminders
+		SET remind_at = ?, before_due = ?, channel = ?, recipient = ?, status = ?, fire_at = ?,
+			attempts = ?, next_attempt_at = ?, last_error = ?, sent_at = ?, updated_at = ?
+		WHERE id = ?
+	`
+
+	_, err := r.db.Exec(
+		r.rebind(query),
+		nullTime(reminder.At),
+		reminder.Before,
+		reminder.Channel,
+		reminder.Recipient,
+		reminder.Status,
+		nullTime(reminder.FireAt),
+		reminder.Attempts,
+		nullTime(reminder.NextAttemptAt),
+		reminder.LastError,
+		nullTime(reminder.SentAt),
+		reminder.UpdatedAt.UTC(),
+		reminder.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update reminder: %w", err)
+	}
+	return nil
+}
+
+// DeleteReminder removes a reminder
+func (r *ReminderRepository) DeleteReminder(id string) error {
+	if _, err := r.db.Exec(r.rebind("DELETE FROM reminders WHERE id = ?"), id); err != nil {
+		return fmt.Errorf("failed to delete reminder: %w", err)
+	}
+	return nil
+}
+
+// DeleteTodoReminders removes the reminders of a todo. The foreign key has
+// usually done so already.
+func (r *ReminderRepository) DeleteTodoReminders(todoID string) error {
+	if _, err := r.db.Exec(r.rebind("DELETE FROM reminders WHERE todo_id = ?"), todoID); err != nil {
+		return fmt.Errorf("failed to delete reminders: %w", err)
+	}
+	return nil
+}
+
+// ClaimDueReminders leases up to limit due reminders. Each row is claimed
+// with a conditional update, so concurrent replicas never claim the same one.
+func (r *ReminderRepository) ClaimDueReminders(now time.Time, lease time.Duration, limit int) ([]*models.Reminder, error) {
+	now = now.UTC()
+	query := `
+		SELECT ` + reminderColumns + `
+		FROM reminders
+		WHERE status = ? AND next_attempt_at <= ?
+		ORDER BY next_attempt_at, created_at
+		LIMIT ?
+	`
+	due, err := r.queryReminders(query, models.ReminderPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	claim := `
+		UPDATE reminders
+		SET next_attempt_at = ?
+		WHERE id = ? AND status = ? AND next_attempt_at = ?
+	`
+
+	var claimed []*models.Reminder
+	for _, reminder := range due {
+		leaseUntil := now.Add(lease)
+		result, err := r.db.Exec(r.rebind(claim), leaseUntil, reminder.ID, models.ReminderPending, reminder.NextAttemptAt.UTC())
+		if err != nil {
+			return nil, fmt.Errorf("failed to claim reminder: %w", err)
+		}
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			continue // Claimed by someone else
+		}
+		reminder.NextAttemptAt = &leaseUntil
+		claimed = append(claimed, reminder)
+	}
+	return claimed, nil
+}
+
+// queryReminders runs a query selecting reminderColumns
+func (r *ReminderRepository) queryReminders(query string, args ...interface{}) ([]*models.Reminder, error) {
+	rows, err := r.db.Query(r.rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reminders: %w", err)
+	}
+	defer rows.Close()
+
+	var reminders []*models.Reminder
+	for rows.Next() {
+		reminder, err := scanReminder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan reminder row: %w", err)
+		}
+		reminders = append(reminders, reminder)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating reminder rows: %w", err)
+	}
+	return reminders, nil
+}
+
+func scanReminder(row rowScanner) (*models.Reminder, error) {
+	var reminder models.Reminder
+	var at, fireAt, nextAttemptAt, sentAt sql.NullTime
+	err := row.Scan(
+		&reminder.ID,
+		&reminder.TodoID,
+		&reminder.UserID,
+		&at,
+		&reminder.Before,
+		&reminder.Channel,
+		&reminder.Recipient,
+		&reminder.Status,
+		&fireAt,
+		&reminder.Attempts,
+		&nextAttemptAt,
+		&reminder.LastError,
+		&sentAt,
+		&reminder.CreatedAt,
+		&reminder.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	reminder.At = timePtr(at)
+	reminder.FireAt = timePtr(fireAt)
+	reminder.NextAttemptAt = timePtr(nextAttemptAt)
+	reminder.SentAt = timePtr(sentAt)
+	return &reminder, nil
+}
+
+// nullTime returns t in UTC as a query argument, or NULL if t is nil
+func nullTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
+
+// timePtr returns the time held by t, or nil if it is NULL
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -0,0 +1,27 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// NewSQLiteTodoRepository creates a TodoRepository backed by a SQLite connection
+func NewSQLiteTodoRepository(db *sql.DB) *TodoRepository {
+	return &TodoRepository{
+		db:                db,
+		rebind:            func(query string) string { return query },
+		isUniqueViolation: sqliteIsUniqueViolation,
+	}
+}
+
+// sqliteIsUniqueViolation reports whether err is a primary key or unique constraint failure
+func sqliteIsUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey ||
+		sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
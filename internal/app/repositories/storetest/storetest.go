@@ -0,0 +1,753 @@
+// Package storetest provides a conformance suite that every
+// repositories.TodoStore implementation is expected to pass.
+//
+// A backend's test calls Run with a factory returning a fresh, empty store:
+//
+//	func TestSQLiteConformance(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) repositories.TodoStore {
+//			db := storetest.OpenSQLite(t)
+//			return repositories.NewSQLiteTodoRepository(db)
+//		})
+//	}
+//
+// Stores must write change events to an outbox, so a memory store is
+// created with repositories.NewMemoryTodoRepository(repositories.NewMemoryOutboxRepository()).
+package storetest
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/repositories"
+	"github.com/teguh/go-todo-api/internal/database"
+)
+
+// PostgresURLEnv names the environment variable pointing at a PostgreSQL
+// server the suite may use. When unset, OpenPostgres falls back to starting
+// a throwaway cluster with the initdb and pg_ctl binaries found on PATH.
+const PostgresURLEnv = "TODO_TEST_POSTGRES_URL"
+
+// RequirePostgresEnv names the environment variable that, when set, makes
+// OpenPostgres fail rather than skip when no server is available, so that
+// CI cannot pass without running the PostgreSQL suite
+const RequirePostgresEnv = "TODO_TEST_REQUIRE_POSTGRES"
+
+// Factory returns a new, empty store for a single sub-test
+type Factory func(t *testing.T) repositories.TodoStore
+
+// Run executes the conformance suite against the stores produced by newStore
+func Run(t *testing.T, newStore Factory) {
+	t.Run("CreateAndGet", func(t *testing.T) { testCreateAndGet(t, newStore(t)) })
+	t.Run("CreateDuplicate", func(t *testing.T) { testCreateDuplicate(t, newStore(t)) })
+	t.Run("GetMissing", func(t *testing.T) { testGetMissing(t, newStore(t)) })
+	t.Run("FindDefaultOrdering", func(t *testing.T) { testFindDefaultOrdering(t, newStore(t)) })
+	t.Run("FindCompleted", func(t *testing.T) { testFindCompleted(t, newStore(t)) })
+	t.Run("Update", func(t *testing.T) { testUpdate(t, newStore(t)) })
+	t.Run("UpdateClearDueDate", func(t *testing.T) { testUpdateClearDueDate(t, newStore(t)) })
+	t.Run("UpdateVersion", func(t *testing.T) { testUpdateVersion(t, newStore(t)) })
+	t.Run("UpdateMissing", func(t *testing.T) { testUpdateMissing(t, newStore(t)) })
+	t.Run("Delete", func(t *testing.T) { testDelete(t, newStore(t)) })
+	t.Run("ChangeEvents", func(t *testing.T) { testChangeEvents(t, newStore(t)) })
+	t.Run("Tags", func(t *testing.T) { testTags(t, newStore(t)) })
+	t.Run("Subtasks", func(t *testing.T) { testSubtasks(t, newStore(t)) })
+	t.Run("GetByIDs", func(t *testing.T) { testGetByIDs(t, newStore(t)) })
+	t.Run("FindAndCount", func(t *testing.T) { testFindAndCount(t, newStore(t)) })
+	t.Run("FindAfter", func(t *testing.T) { testFindAfter(t, newStore(t)) })
+	t.Run("Import", func(t *testing.T) { testImport(t, newStore(t)) })
+}
+
+// OpenSQLite opens a SQLite database in a temporary directory with the schema applied
+func OpenSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := database.Open(database.DialectSQLite, filepath.Join(t.TempDir(), "todo.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := database.CreateTables(db, database.DialectSQLite); err != nil {
+		t.Fatalf("create tables: %v", err)
+	}
+	return db
+}
+
+// OpenPostgres connects to the server named by PostgresURLEnv, or starts a
+// local cluster when the PostgreSQL binaries are installed. The test is
+// skipped when neither is available, or fails if RequirePostgresEnv is set. The todos table is emptied before use;
+// a test sharing the database between stores calls Empty before each one.
+func OpenPostgres(t *testing.T) *sql.DB {
+	t.Helper()
+
+	url := os.Getenv(PostgresURLEnv)
+	if url == "" {
+		url = startLocalPostgres(t)
+	}
+
+	db, err := database.Open(database.DialectPostgres, url)
+	if err != nil {
+		t.Fatalf("open postgres: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := database.CreateTables(db, database.DialectPostgres); err != nil {
+		t.Fatalf("create tables: %v", err)
+	}
+	Empty(t, db)
+	return db
+}
+
+// Empty deletes every todo in db, and with them their tags
+func Empty(t *testing.T, db *sql.DB) {
+	t.Helper()
+	if _, err := db.Exec("DELETE FROM todos"); err != nil {
+		t.Fatalf("truncate todos: %v", err)
+	}
+}
+
+// startLocalPostgres initialises and starts a temporary cluster, returning its URL
+func startLocalPostgres(t *testing.T) string {
+	t.Helper()
+
+	initdb, err := exec.LookPath("initdb")
+	if err != nil {
+		skipPostgres(t, "%s not set and initdb not found on PATH", PostgresURLEnv)
+	}
+	pgctl, err := exec.LookPath("pg_ctl")
+	if err != nil {
+		skipPostgres(t, "%s not set and pg_ctl not found on PATH", PostgresURLEnv)
+	}
+
+	dir := t.TempDir()
+	dataDir := filepath.Join(dir, "data")
+	if out, err := exec.Command(initdb, "-D", dataDir, "-U", "postgres", "-A", "trust").CombinedOutput(); err != nil {
+		t.Fatalf("initdb: %v\n%s", err, out)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		t.Fatalf("find free port: %v", err)
+	}
+
+	opts := fmt.Sprintf("-p %d -k %s -h 127.0.0.1", port, dir)
+	if out, err := exec.Command(pgctl, "-D", dataDir, "-o", opts, "-w", "start").CombinedOutput(); err != nil {
+		t.Fatalf("pg_ctl start: %v\n%s", err, out)
+	}
+	t.Cleanup(func() {
+		exec.Command(pgctl, "-D", dataDir, "-m", "immediate", "stop").Run()
+	})
+
+	return fmt.Sprintf("postgres://postgres@127.0.0.1:%d/postgres?sslmode=disable", port)
+}
+
+// skipPostgres skips the test for want of a server, unless RequirePostgresEnv
+// is set, in which case it fails
+func skipPostgres(t *testing.T, format string, args ...interface{}) {
+	t.Helper()
+	if os.Getenv(RequirePostgresEnv) != "" {
+		t.Fatalf(format+" but %s is set", append(args, RequirePostgresEnv)...)
+	}
+	t.Skipf(format, args...)
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// newTodo builds a todo with a deterministic creation time
+func newTodo(t *testing.T, title string, priority int, createdAt time.Time) *models.Todo {
+	t.Helper()
+
+	todo, err := models.NewTodo(models.TodoCreate{Title: title, Priority: priority})
+	if err != nil {
+		t.Fatalf("new todo: %v", err)
+	}
+	todo.CreatedAt = createdAt
+	todo.UpdatedAt = createdAt
+	return todo
+}
+
+func mustCreate(t *testing.T, store repositories.TodoStore, todo *models.Todo) {
+	t.Helper()
+	if err := store.Create(todo, nil); err != nil {
+		t.Fatalf("create %q: %v", todo.Title, err)
+	}
+}
+
+func testCreateAndGet(t *testing.T, store repositories.TodoStore) {
+	todo, err := models.NewTodo(models.TodoCreate{
+		Title:       "Write report",
+		Description: "Quarterly numbers",
+		Project:     "finance",
+		Priority:    2,
+		DueDate:     "2030-01-02T15:04:05Z",
+	})
+	if err != nil {
+		t.Fatalf("new todo: %v", err)
+	}
+	mustCreate(t, store, todo)
+
+	got, err := store.GetByID(todo.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got == nil {
+		t.Fatal("get: todo not found after create")
+	}
+	if got.Title != todo.Title || got.Description != todo.Description || got.Project != todo.Project || got.Priority != todo.Priority || got.Completed {
+		t.Errorf("get: fields = %+v, want %+v", got, todo)
+	}
+	if !got.DueDate.Valid || !got.DueDate.Time.Equal(todo.DueDate.Time) {
+		t.Errorf("get: due date = %v, want %v", got.DueDate, todo.DueDate)
+	}
+	if got.DueDateStr != "2030-01-02T15:04:05Z" {
+		t.Errorf("get: due_date string = %q", got.DueDateStr)
+	}
+	if todo.Version != 1 || got.Version != 1 {
+		t.Errorf("versions after create = %d, %d, want 1", todo.Version, got.Version)
+	}
+}
+
+func testCreateDuplicate(t *testing.T, store repositories.TodoStore) {
+	todo := newTodo(t, "original", 0, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	mustCreate(t, store, todo)
+
+	duplicate := newTodo(t, "duplicate", 0, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC))
+	duplicate.ID = todo.ID
+	err := store.Create(duplicate, nil)
+	if !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("create duplicate: got %v, want ErrConflict", err)
+	}
+}
+
+func testGetMissing(t *testing.T, store repositories.TodoStore) {
+	got, err := store.GetByID("does-not-exist")
+	if err != nil {
+		t.Fatalf("get missing: unexpected error %v", err)
+	}
+	if got != nil {
+		t.Fatalf("get missing: got %+v, want nil", got)
+	}
+}
+
+func testFindDefaultOrdering(t *testing.T, store repositories.TodoStore) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mustCreate(t, store, newTodo(t, "low-old", 0, base))
+	mustCreate(t, store, newTodo(t, "high-old", 3, base.Add(time.Minute)))
+	mustCreate(t, store, newTodo(t, "low-new", 0, base.Add(2*time.Minute)))
+	mustCreate(t, store, newTodo(t, "high-new", 3, base.Add(3*time.Minute)))
+
+	todos, err := store.Find(models.TodoQuery{})
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+
+	want := []string{"high-new", "high-old", "low-new", "low-old"}
+	if len(todos) != len(want) {
+		t.Fatalf("find: got %d todos, want %d", len(todos), len(want))
+	}
+	for i, title := range want {
+		if todos[i].Title != title {
+			t.Errorf("find: position %d = %q, want %q", i, todos[i].Title, title)
+		}
+	}
+}
+
+func testFindCompleted(t *testing.T, store repositories.TodoStore) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	open := newTodo(t, "open", 1, base)
+	done := newTodo(t, "done", 1, base.Add(time.Minute))
+	done.Completed = true
+	mustCreate(t, store, open)
+	mustCreate(t, store, done)
+
+	all, err := store.Find(models.TodoQuery{})
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("find: got %d todos, want 2", len(all))
+	}
+
+	for _, completed := range []bool{true, false} {
+		completed := completed
+		todos, err := store.Find(models.TodoQuery{Filter: models.TodoFilter{Completed: &completed}})
+		if err != nil {
+			t.Fatalf("find completed=%v: %v", completed, err)
+		}
+		if len(todos) != 1 || todos[0].Completed != completed {
+			t.Errorf("find completed=%v: got %+v", completed, todos)
+		}
+	}
+}
+
+func testUpdate(t *testing.T, store repositories.TodoStore) {
+	todo := newTodo(t, "before", 1, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	todo.Description = "keep me"
+	mustCreate(t, store, todo)
+
+	title := "after"
+	completed := true
+	due := "2031-05-06T07:08:09Z"
+	updated, err := store.Update(todo.ID, 0, &models.TodoUpdate{Title: &title, Completed: &completed, DueDate: &due}, nil)
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if updated == nil {
+		t.Fatal("update: got nil for existing todo")
+	}
+	if updated.Title != "after" || !updated.Completed || updated.Description != "keep me" || updated.Priority != 1 {
+		t.Errorf("update: result = %+v", updated)
+	}
+	if !updated.UpdatedAt.After(todo.UpdatedAt) {
+		t.Errorf("update: updated_at %v not after %v", updated.UpdatedAt, todo.UpdatedAt)
+	}
+	if updated.Version != 2 {
+		t.Errorf("update: version = %d, want 2", updated.Version)
+	}
+
+	got, err := store.GetByID(todo.ID)
+	if err != nil || got == nil {
+		t.Fatalf("get after update: %v, %v", got, err)
+	}
+	if got.Title != "after" || !got.Completed || got.DueDateStr != due || got.Version != 2 {
+		t.Errorf("get after update: %+v", got)
+	}
+}
+
+func testUpdateClearDueDate(t *testing.T, store repositories.TodoStore) {
+	todo, err := models.NewTodo(models.TodoCreate{Title: "due", DueDate: "2030-01-01T00:00:00Z"})
+	if err != nil {
+		t.Fatalf("new todo: %v", err)
+	}
+	mustCreate(t, store, todo)
+
+	empty := ""
+	if _, err := store.Update(todo.ID, 0, &models.TodoUpdate{DueDate: &empty}, nil); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	got, err := store.GetByID(todo.ID)
+	if err != nil || got == nil {
+		t.Fatalf("get after update: %v, %v", got, err)
+	}
+	if got.DueDate.Valid || got.DueDateStr != "" {
+		t.Errorf("due date not cleared: %+v", got)
+	}
+}
+
+func testUpdateVersion(t *testing.T, store repositories.TodoStore) {
+	todo := newTodo(t, "first", 1, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	mustCreate(t, store, todo)
+
+	title := "second"
+	updated, err := store.Update(todo.ID, 1, &models.TodoUpdate{Title: &title}, nil)
+	if err != nil || updated.Version != 2 {
+		t.Fatalf("update at version 1: %+v, %v", updated, err)
+	}
+
+	// A writer still holding version 1 must not overwrite the change
+	stale := "stale"
+	event := &models.TodoEvent{Type: models.EventUpdated}
+	if _, err := store.Update(todo.ID, 1, &models.TodoUpdate{Title: &stale}, event); !errors.Is(err, models.ErrVersionMismatch) {
+		t.Fatalf("update at stale version: err = %v, want ErrVersionMismatch", err)
+	}
+	if event.ID != 0 {
+		t.Errorf("stale update wrote event %d", event.ID)
+	}
+	got, err := store.GetByID(todo.ID)
+	if err != nil || got.Title != "second" || got.Version != 2 {
+		t.Errorf("get after stale update: %+v, %v", got, err)
+	}
+
+	// Nor delete it
+	if err := store.Delete(todo.ID, 1, nil); !errors.Is(err, models.ErrVersionMismatch) {
+		t.Fatalf("delete at stale version: err = %v, want ErrVersionMismatch", err)
+	}
+	if err := store.Delete(todo.ID, 2, nil); err != nil {
+		t.Fatalf("delete at version 2: %v", err)
+	}
+	if got, err := store.GetByID(todo.ID); err != nil || got != nil {
+		t.Errorf("get after delete: %+v, %v", got, err)
+	}
+}
+
+func testUpdateMissing(t *testing.T, store repositories.TodoStore) {
+	title := "x"
+	got, err := store.Update("does-not-exist", 0, &models.TodoUpdate{Title: &title}, nil)
+	if err != nil {
+		t.Fatalf("update missing: unexpected error %v", err)
+	}
+	if got != nil {
+		t.Fatalf("update missing: got %+v, want nil", got)
+	}
+}
+
+func testDelete(t *testing.T, store repositories.TodoStore) {
+	todo := newTodo(t, "doomed", 0, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	mustCreate(t, store, todo)
+
+	if err := store.Delete(todo.ID, 0, nil); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	got, err := store.GetByID(todo.ID)
+	if err != nil {
+		t.Fatalf("get after delete: %v", err)
+	}
+	if got != nil {
+		t.Fatalf("get after delete: got %+v, want nil", got)
+	}
+
+	if err := store.Delete(todo.ID, 0, nil); err != nil {
+		t.Fatalf("delete missing: unexpected error %v", err)
+	}
+}
+
+func testChangeEvents(t *testing.T, store repositories.TodoStore) {
+	todo := newTodo(t, "tracked", 0, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	todo.Project = "home"
+	created := &models.TodoEvent{Type: models.EventCreated}
+	if err := store.Create(todo, created); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	title := "renamed"
+	updated := &models.TodoEvent{Type: models.EventUpdated}
+	if _, err := store.Update(todo.ID, 0, &models.TodoUpdate{Title: &title}, updated); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	project := "errands"
+	moved := &models.TodoEvent{Type: models.EventUpdated}
+	if _, err := store.Update(todo.ID, 0, &models.TodoUpdate{Project: &project}, moved); err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	if updated.PreviousProject != "" || moved.PreviousProject != "home" || moved.Todo.Project != "errands" {
+		t.Errorf("previous projects = %q, %q, want none, then home", updated.PreviousProject, moved.PreviousProject)
+	}
+
+	deleted := &models.TodoEvent{Type: models.EventDeleted}
+	if err := store.Delete(todo.ID, 0, deleted); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	if created.ID <= 0 || updated.ID <= created.ID || moved.ID <= updated.ID || deleted.ID <= moved.ID {
+		t.Errorf("event IDs = %d, %d, %d, %d, want increasing", created.ID, updated.ID, moved.ID, deleted.ID)
+	}
+	for _, event := range []*models.TodoEvent{created, updated, deleted} {
+		if event.TodoID != todo.ID || event.Todo == nil || event.Todo.ID != todo.ID {
+			t.Errorf("%s event not completed: %+v", event.Type, event)
+		}
+	}
+	if deleted.Todo.Title != "renamed" {
+		t.Errorf("deleted event todo = %+v, want the state before deletion", deleted.Todo)
+	}
+
+	missing := &models.TodoEvent{Type: models.EventDeleted}
+	if err := store.Delete(todo.ID, 0, missing); err != nil {
+		t.Fatalf("delete missing: %v", err)
+	}
+	if missing.ID != 0 {
+		t.Errorf("delete missing: event written with ID %d", missing.ID)
+	}
+}
+
+func testTags(t *testing.T, store repositories.TodoStore) {
+	todo, err := models.NewTodo(models.TodoCreate{Title: "tagged", Tags: []string{"Work", "home", "work"}})
+	if err != nil {
+		t.Fatalf("new todo: %v", err)
+	}
+	mustCreate(t, store, todo)
+
+	got, err := store.GetByID(todo.ID)
+	if err != nil || got == nil {
+		t.Fatalf("get: %v, %v", got, err)
+	}
+	if strings.Join(got.Tags, ",") != "home,work" {
+		t.Errorf("get: tags = %q, want [home work]", got.Tags)
+	}
+
+	tags := []string{"urgent"}
+	if _, err := store.Update(todo.ID, 0, &models.TodoUpdate{Tags: &tags}, nil); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	got, err = store.GetByID(todo.ID)
+	if err != nil || got == nil {
+		t.Fatalf("get after update: %v, %v", got, err)
+	}
+	if strings.Join(got.Tags, ",") != "urgent" {
+		t.Errorf("get after update: tags = %q, want [urgent]", got.Tags)
+	}
+
+	untagged := newTodo(t, "untagged", 0, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	mustCreate(t, store, untagged)
+	got, err = store.GetByID(untagged.ID)
+	if err != nil || got == nil {
+		t.Fatalf("get untagged: %v, %v", got, err)
+	}
+	if got.Tags == nil || len(got.Tags) != 0 {
+		t.Errorf("get untagged: tags = %#v, want empty and non-nil", got.Tags)
+	}
+}
+
+func testSubtasks(t *testing.T, store repositories.TodoStore) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	parent := newTodo(t, "parent", 0, base)
+	other := newTodo(t, "other", 0, base)
+	mustCreate(t, store, parent)
+	mustCreate(t, store, other)
+
+	low := newTodo(t, "low", 1, base)
+	low.ParentID = parent.ID
+	high := newTodo(t, "high", 3, base)
+	high.ParentID = parent.ID
+	elsewhere := newTodo(t, "elsewhere", 0, base)
+	elsewhere.ParentID = other.ID
+	for _, todo := range []*models.Todo{low, high, elsewhere} {
+		mustCreate(t, store, todo)
+	}
+
+	subtasks, err := store.ListSubtasks([]string{parent.ID, other.ID})
+	if err != nil {
+		t.Fatalf("list subtasks: %v", err)
+	}
+	if titles(subtasks) != "high,low,elsewhere" {
+		t.Errorf("list subtasks: got %s, want high,low,elsewhere", titles(subtasks))
+	}
+
+	if err := store.Delete(parent.ID, 0, nil); !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("delete parent: got %v, want ErrConflict", err)
+	}
+
+	detached := ""
+	if _, err := store.Update(elsewhere.ID, 0, &models.TodoUpdate{ParentID: &detached}, nil); err != nil {
+		t.Fatalf("detach: %v", err)
+	}
+	if err := store.Delete(other.ID, 0, nil); err != nil {
+		t.Fatalf("delete parent without subtasks: %v", err)
+	}
+}
+
+func testImport(t *testing.T, store repositories.TodoStore) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	existing := newTodo(t, "existing", 0, base)
+	mustCreate(t, store, existing)
+
+	replaced := newTodo(t, "replaced", 2, base.Add(time.Hour))
+	replaced.ID = existing.ID
+	replaced.Tags = []string{"imported"}
+	added := newTodo(t, "added", 1, base.Add(time.Hour))
+	added.ParentID = existing.ID
+	events := []*models.TodoEvent{{}, {}}
+	if err := store.Import([]*models.Todo{replaced, added}, events); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+
+	if events[0].Type != models.EventUpdated || events[1].Type != models.EventCreated {
+		t.Errorf("event types = %s, %s, want updated, created", events[0].Type, events[1].Type)
+	}
+	if events[0].ID <= 0 || events[1].ID <= events[0].ID || events[1].TodoID != added.ID {
+		t.Errorf("events not written in order: %+v, %+v", events[0], events[1])
+	}
+
+	got, err := store.GetByID(existing.ID)
+	if err != nil || got == nil {
+		t.Fatalf("get replaced: %v, %v", got, err)
+	}
+	if got.Title != "replaced" || got.Priority != 2 || strings.Join(got.Tags, ",") != "imported" {
+		t.Errorf("get replaced: got %+v", got)
+	}
+	if !got.CreatedAt.Equal(base) {
+		t.Errorf("get replaced: created_at = %v, want it kept at %v", got.CreatedAt, base)
+	}
+	if got.Version != 2 || added.Version != 1 {
+		t.Errorf("versions after import = %d, %d, want 2, 1", got.Version, added.Version)
+	}
+
+	subtasks, err := store.ListSubtasks([]string{existing.ID})
+	if err != nil {
+		t.Fatalf("list subtasks: %v", err)
+	}
+	if titles(subtasks) != "added" {
+		t.Errorf("list subtasks: got %s, want added", titles(subtasks))
+	}
+}
+
+func testGetByIDs(t *testing.T, store repositories.TodoStore) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := newTodo(t, "a", 0, base)
+	b := newTodo(t, "b", 0, base)
+	mustCreate(t, store, a)
+	mustCreate(t, store, b)
+
+	todos, err := store.GetByIDs([]string{a.ID, "does-not-exist", b.ID})
+	if err != nil {
+		t.Fatalf("get by ids: %v", err)
+	}
+	if len(todos) != 2 {
+		t.Fatalf("get by ids: got %d todos, want 2", len(todos))
+	}
+	for _, todo := range todos {
+		if todo.Tags == nil {
+			t.Errorf("get by ids: %s has nil tags", todo.Title)
+		}
+	}
+}
+
+func testFindAndCount(t *testing.T, store repositories.TodoStore) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, title := range []string{"Buy milk", "Buy bread", "Call 50% off shop", "Write report"} {
+		todo := newTodo(t, title, i, base.Add(time.Duration(i)*time.Minute))
+		todo.Completed = i%2 == 1
+		todo.Tags = []string{"errand"}
+		if i == 3 {
+			todo.Tags = []string{"work"}
+		}
+		mustCreate(t, store, todo)
+	}
+
+	found, err := store.Find(models.TodoQuery{Filter: models.TodoFilter{Search: "buy"}, Sort: models.SortTitle, Limit: 10})
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if titles(found) != "Buy bread,Buy milk" {
+		t.Errorf("find search: got %s", titles(found))
+	}
+
+	found, err = store.Find(models.TodoQuery{Filter: models.TodoFilter{Search: "50%"}, Limit: 10})
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if titles(found) != "Call 50% off shop" {
+		t.Errorf("find wildcard search: got %s", titles(found))
+	}
+
+	found, err = store.Find(models.TodoQuery{Filter: models.TodoFilter{Tag: "Errand"}, Limit: 2, Offset: 1})
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if titles(found) != "Buy bread,Buy milk" {
+		t.Errorf("find tag page: got %s", titles(found))
+	}
+
+	found, err = store.Find(models.TodoQuery{Filter: models.TodoFilter{Tag: "errand"}, Sort: models.SortTitle, Descending: true})
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if titles(found) != "Call 50% off shop,Buy milk,Buy bread" {
+		t.Errorf("find without limit: got %s", titles(found))
+	}
+
+	completed := true
+	counts, err := store.Count(models.TodoFilter{Tag: "errand"})
+	if err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if *counts != (models.TodoCounts{Total: 3, Completed: 1, Open: 2}) {
+		t.Errorf("count: got %+v", counts)
+	}
+	counts, err = store.Count(models.TodoFilter{Completed: &completed})
+	if err != nil {
+		t.Fatalf("count completed: %v", err)
+	}
+	if counts.Total != 2 || counts.Open != 0 {
+		t.Errorf("count completed: got %+v", counts)
+	}
+}
+
+func testFindAfter(t *testing.T, store repositories.TodoStore) {
+	// Ties on every sort field, and todos with and without due dates
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 7; i++ {
+		todo := newTodo(t, fmt.Sprintf("todo %d", i%3), i%2, base.Add(time.Duration(i%4)*time.Minute))
+		if i%3 != 0 {
+			if err := todo.SetDueDate(base.AddDate(0, 0, i%2).Format(time.RFC3339)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		mustCreate(t, store, todo)
+	}
+
+	queries := []models.TodoQuery{{}}
+	for _, sort := range models.SortFields {
+		queries = append(queries, models.TodoQuery{Sort: sort}, models.TodoQuery{Sort: sort, Descending: true})
+	}
+	for _, query := range queries {
+		all, err := store.Find(query)
+		if err != nil {
+			t.Fatalf("find %+v: %v", query, err)
+		}
+
+		// Pages of two, each after the last todo of the one before, add up
+		// to the whole list
+		var paged []*models.Todo
+		page := query
+		page.Limit = 2
+		for {
+			todos, err := store.Find(page)
+			if err != nil {
+				t.Fatalf("find %+v: %v", page, err)
+			}
+			paged = append(paged, todos...)
+			if len(todos) < page.Limit {
+				break
+			}
+			page.After = todos[len(todos)-1]
+		}
+		if ids(paged) != ids(all) {
+			t.Errorf("sort %q descending %t: pages gave %s, want %s", query.Sort, query.Descending, titles(paged), titles(all))
+		}
+	}
+
+	// Deleting the todo a page ended on, or todos before it, moves nothing
+	query := models.TodoQuery{Sort: models.SortTitle, Limit: 3}
+	first, err := store.Find(query)
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	for _, todo := range first {
+		if err := store.Delete(todo.ID, 0, nil); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+	}
+	query.After = first[len(first)-1]
+	query.Limit = 10
+	rest, err := store.Find(query)
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if len(rest) != 4 {
+		t.Errorf("after deleting the first page: got %s, want the other 4", titles(rest))
+	}
+}
+
+// ids joins the IDs of todos
+func ids(todos []*models.Todo) string {
+	names := make([]string, len(todos))
+	for i, todo := range todos {
+		names[i] = todo.ID
+	}
+	return strings.Join(names, ",")
+}
+
+func titles(todos []*models.Todo) string {
+	names := make([]string, len(todos))
+	for i, todo := range todos {
+		names[i] = todo.Title
+	}
+	return strings.Join(names, ",")
+}
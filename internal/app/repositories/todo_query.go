@@ -0,0 +1,245 @@
+package repositories
+
+import (
+	"strings"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+// sortColumns maps the sort fields of a TodoQuery to columns
+var sortColumns = map[string]string{
+	models.SortPriority:  "priority",
+	models.SortCreatedAt: "created_at",
+	models.SortUpdatedAt: "updated_at",
+	models.SortDueDate:   "due_date",
+	models.SortTitle:     "title",
+}
+
+// filterClause renders filter as a WHERE clause with ? placeholders, or ""
+// when it matches everything
+func filterClause(filter models.TodoFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.Completed != nil {
+		conditions = append(conditions, "completed = ?")
+		args = append(args, *filter.Completed)
+	}
+	if filter.Project != nil {
+		conditions = append(conditions, "project = ?")
+		args = append(args, *filter.Project)
+	}
+	if filter.Tag != "" {
+		conditions = append(conditions, "id IN (SELECT todo_id FROM todo_tags WHERE tag = ?)")
+		args = append(args, strings.ToLower(filter.Tag))
+	}
+	if filter.ParentID != nil {
+		conditions = append(conditions, "parent_id = ?")
+		args = append(args, *filter.ParentID)
+	}
+	if filter.Search != "" {
+		pattern := "%" + escapeLike(strings.ToLower(filter.Search)) + "%"
+		conditions = append(conditions, `(LOWER(title) LIKE ? ESCAPE '\' OR LOWER(description) LIKE ? ESCAPE '\')`)
+		args = append(args, pattern, pattern)
+	}
+	if filter.Due != nil && filter.Due.From != nil {
+		condition, boundArgs := dueCondition(">=", *filter.Due.From)
+		conditions = append(conditions, condition)
+		args = append(args, boundArgs...)
+	}
+	if filter.Due != nil && filter.Due.To != nil {
+		condition, boundArgs := dueCondition("<", *filter.Due.To)
+		conditions = append(conditions, condition)
+		args = append(args, boundArgs...)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return "\n\t\tWHERE " + strings.Join(conditions, " AND "), args
+}
+
+// dueCondition compares due_date with a bound of a models.DueRange: the
+// instant for timed todos and the date for all-day ones
+func dueCondition(operator string, bound time.Time) (string, []interface{}) {
+	condition := "((all_day = ? AND due_date " + operator + " ?) OR (all_day = ? AND due_date " + operator + " ?))"
+	return condition, []interface{}{false, bound.UTC(), true, models.Date(bound)}
+}
+
+// orderClause renders the ORDER BY list of query. The ID breaks ties so
+// that pages do not overlap.
+func orderClause(query models.TodoQuery) string {
+	column, ok := sortColumns[query.Sort]
+	if !ok {
+		return "priority DESC, created_at DESC, id"
+	}
+
+	direction := "ASC"
+	if query.Descending {
+		direction = "DESC"
+	}
+	if column == "due_date" {
+		// NULLs sort first in SQLite and last in PostgreSQL; put them last in both
+		return "due_date IS NULL, due_date " + direction + ", id"
+	}
+	return column + " " + direction + ", id"
+}
+
+// afterCondition renders the keyset condition selecting the todos after
+// query.After in the order of orderClause, with ? placeholders
+func afterCondition(query models.TodoQuery) (string, []interface{}) {
+	after := query.After
+	column, ok := sortColumns[query.Sort]
+	if !ok {
+		return "(priority < ? OR (priority = ? AND (created_at < ? OR (created_at = ? AND id > ?))))",
+			[]interface{}{after.Priority, after.Priority, after.CreatedAt.UTC(), after.CreatedAt.UTC(), after.ID}
+	}
+
+	operator := ">"
+	if query.Descending {
+		operator = "<"
+	}
+	var value interface{}
+	switch column {
+	case "priority":
+		value = after.Priority
+	case "created_at":
+		value = after.CreatedAt.UTC()
+	case "updated_at":
+		value = after.UpdatedAt.UTC()
+	case "due_date":
+		// Todos without a due date come last, in ID order
+		if !after.DueDate.Valid {
+			return "(due_date IS NULL AND id > ?)", []interface{}{after.ID}
+		}
+		value = after.DueDate.Time.UTC()
+		return "(due_date IS NULL OR due_date " + operator + " ? OR (due_date = ? AND id > ?))",
+			[]interface{}{value, value, after.ID}
+	case "title":
+		value = after.Title
+	}
+	return "(" + column + " " + operator + " ? OR (" + column + " = ? AND id > ?))", []interface{}{value, value, after.ID}
+}
+
+// escapeLike escapes the LIKE wildcards in s with backslashes
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// placeholders returns n comma-separated ? placeholders
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// batches splits ids into slices of at most idBatchSize
+func batches(ids []string) [][]string {
+	var result [][]string
+	for len(ids) > idBatchSize {
+		result = append(result, ids[:idBatchSize])
+		ids = ids[idBatchSize:]
+	}
+	if len(ids) > 0 {
+		result = append(result, ids)
+	}
+	return result
+}
+
+// stringArgs converts strings to query arguments
+func stringArgs(values []string) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
+
+// matchesFilter is the in-memory equivalent of filterClause
+func matchesFilter(todo *models.Todo, filter models.TodoFilter) bool {
+	if filter.Completed != nil && todo.Completed != *filter.Completed {
+		return false
+	}
+	if filter.Project != nil && todo.Project != *filter.Project {
+		return false
+	}
+	if filter.Tag != "" && !hasTag(todo, strings.ToLower(filter.Tag)) {
+		return false
+	}
+	if filter.ParentID != nil && todo.ParentID != *filter.ParentID {
+		return false
+	}
+	if filter.Search != "" {
+		search := strings.ToLower(filter.Search)
+		if !strings.Contains(strings.ToLower(todo.Title), search) && !strings.Contains(strings.ToLower(todo.Description), search) {
+			return false
+		}
+	}
+	if filter.Due != nil && !filter.Due.Matches(todo) {
+		return false
+	}
+	return true
+}
+
+func hasTag(todo *models.Todo, tag string) bool {
+	for _, t := range todo.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// lessForQuery is the in-memory equivalent of orderClause
+func lessForQuery(query models.TodoQuery) func(a, b *models.Todo) bool {
+	column, ok := sortColumns[query.Sort]
+	if !ok {
+		return func(a, b *models.Todo) bool {
+			if a.Priority != b.Priority {
+				return a.Priority > b.Priority
+			}
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.After(b.CreatedAt)
+			}
+			return a.ID < b.ID
+		}
+	}
+
+	// compare returns -1, 0 or 1 in ascending order
+	var compare func(a, b *models.Todo) int
+	switch column {
+	case "priority":
+		compare = func(a, b *models.Todo) int { return compareInts(a.Priority, b.Priority) }
+	case "created_at":
+		compare = func(a, b *models.Todo) int { return a.CreatedAt.Compare(b.CreatedAt) }
+	case "updated_at":
+		compare = func(a, b *models.Todo) int { return a.UpdatedAt.Compare(b.UpdatedAt) }
+	case "due_date":
+		compare = func(a, b *models.Todo) int { return a.DueDate.Time.Compare(b.DueDate.Time) }
+	case "title":
+		compare = func(a, b *models.Todo) int { return strings.Compare(a.Title, b.Title) }
+	}
+
+	return func(a, b *models.Todo) bool {
+		if column == "due_date" && a.DueDate.Valid != b.DueDate.Valid {
+			return a.DueDate.Valid
+		}
+		c := compare(a, b)
+		if query.Descending {
+			c = -c
+		}
+		if c != 0 {
+			return c < 0
+		}
+		return a.ID < b.ID
+	}
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -10,64 +10,67 @@ import (
 	"github.com/teguh/go-todo-api/internal/database"
 )
 
-// TodoRepository handles database operations for todos
+// TodoRepository handles database operations for todos.
+// Queries are written with ? placeholders and rebound for the target dialect.
 type TodoRepository struct {
-	db *sql.DB
+	db     *sql.DB
+	rebind func(query string) string
+	// isUniqueViolation recognises the driver's duplicate key error
+	isUniqueViolation func(err error) bool
 }
 
-// NewTodoRepository creates a new TodoRepository
-func NewTodoRepository() *TodoRepository {
-	return &TodoRepository{
-		db: database.DB,
+// NewTodoRepository creates the TodoStore matching the dialect of db.
+// With the memory dialect every call returns a new, empty store without an
+// outbox; use NewMemoryTodoRepository to give it one.
+func NewTodoRepository(db *database.DB) TodoStore {
+	switch db.Dialect {
+	case database.DialectPostgres:
+		return NewPostgresTodoRepository(db.DB)
+	case database.DialectMemory:
+		return NewMemoryTodoRepository(nil)
+	default:
+		return NewSQLiteTodoRepository(db.DB)
 	}
 }
 
-// Create inserts a new todo into the database
-func (r *TodoRepository) Create(todo *models.Todo) error {
-	query := `
-		INSERT INTO todos (id, title, description, completed, priority, due_date, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-	`
+// todoColumns lists the columns of todos in the order scanTodo expects
+const todoColumns = "id, title, description, project, parent_id, completed, priority, due_date, all_day, created_at, updated_at, version"
 
-	_, err := r.db.Exec(
-		query,
-		todo.ID,
-		todo.Title,
-		todo.Description,
-		todo.Completed,
-		todo.Priority,
-		todo.DueDate,
-		todo.CreatedAt,
-		todo.UpdatedAt,
-	)
+// idBatchSize caps the IDs bound in a single IN list, well below the
+// parameter limits of both dialects
+const idBatchSize = 500
 
-	if err != nil {
-		return fmt.Errorf("failed to create todo: %w", err)
-	}
+// querier is satisfied by both *sql.DB and *sql.Tx
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
 
-	return nil
+// Create inserts a new todo into the database, writing event to the outbox
+// in the same transaction
+func (r *TodoRepository) Create(todo *models.Todo, event *models.TodoEvent) error {
+	return r.inTx(func(tx *sql.Tx) error {
+		if err := r.insert(tx, todo); err != nil {
+			return err
+		}
+		return r.recordEvent(tx, event, todo, todo.Project)
+	})
 }
 
 // GetByID retrieves a todo by its ID
 func (r *TodoRepository) GetByID(id string) (*models.Todo, error) {
+	return r.getByID(r.db, id)
+}
+
+// getByID retrieves a todo with its tags through q, returning nil if it does not exist
+func (r *TodoRepository) getByID(q querier, id string) (*models.Todo, error) {
 	query := `
-		SELECT id, title, description, completed, priority, due_date, created_at, updated_at
+		SELECT ` + todoColumns + `
 		FROM todos
 		WHERE id = ?
 	`
 
-	var todo models.Todo
-	err := r.db.QueryRow(query, id).Scan(
-		&todo.ID,
-		&todo.Title,
-		&todo.Description,
-		&todo.Completed,
-		&todo.Priority,
-		&todo.DueDate,
-		&todo.CreatedAt,
-		&todo.UpdatedAt,
-	)
-
+	todo, err := scanTodo(q.QueryRow(r.rebind(query), id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil // Not found
@@ -75,150 +78,422 @@ func (r *TodoRepository) GetByID(id string) (*models.Todo, error) {
 		return nil, fmt.Errorf("failed to get todo by ID: %w", err)
 	}
 
-	todo.FormatDates()
-	return &todo, nil
+	if err := r.loadTags(q, []*models.Todo{todo}); err != nil {
+		return nil, err
+	}
+	return todo, nil
 }
 
-// GetAll retrieves all todos with optional filtering
-func (r *TodoRepository) GetAll(completed *bool) ([]*models.Todo, error) {
-	var query string
-	var args []interface{}
-
-	if completed != nil {
-		query = `
-			SELECT id, title, description, completed, priority, due_date, created_at, updated_at
-			FROM todos
-			WHERE completed = ?
-			ORDER BY priority DESC, created_at DESC
-		`
-		args = append(args, *completed)
-	} else {
-		query = `
-			SELECT id, title, description, completed, priority, due_date, created_at, updated_at
+// GetByIDs retrieves the todos with the given IDs in no particular order,
+// skipping IDs that do not exist
+func (r *TodoRepository) GetByIDs(ids []string) ([]*models.Todo, error) {
+	var todos []*models.Todo
+	for _, batch := range batches(ids) {
+		query := `
+			SELECT ` + todoColumns + `
 			FROM todos
-			ORDER BY priority DESC, created_at DESC
+			WHERE id IN (` + placeholders(len(batch)) + `)
 		`
+		found, err := r.queryTodos(query, stringArgs(batch)...)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, found...)
 	}
+	return todos, nil
+}
 
-	rows, err := r.db.Query(query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query todos: %w", err)
-	}
-	defer rows.Close()
-
+// ListSubtasks retrieves the subtasks of every given todo, ordered as Find orders them without a sort
+func (r *TodoRepository) ListSubtasks(parentIDs []string) ([]*models.Todo, error) {
 	var todos []*models.Todo
-	for rows.Next() {
-		var todo models.Todo
-		err := rows.Scan(
-			&todo.ID,
-			&todo.Title,
-			&todo.Description,
-			&todo.Completed,
-			&todo.Priority,
-			&todo.DueDate,
-			&todo.CreatedAt,
-			&todo.UpdatedAt,
-		)
+	for _, batch := range batches(parentIDs) {
+		query := `
+			SELECT ` + todoColumns + `
+			FROM todos
+			WHERE parent_id IN (` + placeholders(len(batch)) + `)
+			ORDER BY priority DESC, created_at DESC, id
+		`
+		found, err := r.queryTodos(query, stringArgs(batch)...)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan todo row: %w", err)
+			return nil, err
 		}
-
-		todo.FormatDates()
-		todos = append(todos, &todo)
+		todos = append(todos, found...)
 	}
+	return todos, nil
+}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating todo rows: %w", err)
+// Find retrieves a page of the todos matching query
+func (r *TodoRepository) Find(query models.TodoQuery) ([]*models.Todo, error) {
+	where, args := filterClause(query.Filter)
+	if query.After != nil {
+		condition, afterArgs := afterCondition(query)
+		if where == "" {
+			where = "\n\t\tWHERE " + condition
+		} else {
+			where += " AND " + condition
+		}
+		args = append(args, afterArgs...)
+	}
+	sqlQuery := `
+		SELECT ` + todoColumns + `
+		FROM todos` + where + `
+		ORDER BY ` + orderClause(query)
+	if query.Limit > 0 {
+		sqlQuery += `
+		LIMIT ? OFFSET ?`
+		args = append(args, query.Limit, query.Offset)
 	}
 
-	return todos, nil
+	return r.queryTodos(sqlQuery, args...)
+}
+
+// Count tallies the todos matching filter
+func (r *TodoRepository) Count(filter models.TodoFilter) (*models.TodoCounts, error) {
+	where, args := filterClause(filter)
+	query := `
+		SELECT COUNT(*), COALESCE(SUM(CASE WHEN completed THEN 1 ELSE 0 END), 0)
+		FROM todos` + where
+
+	var counts models.TodoCounts
+	if err := r.db.QueryRow(r.rebind(query), args...).Scan(&counts.Total, &counts.Completed); err != nil {
+		return nil, fmt.Errorf("failed to count todos: %w", err)
+	}
+	counts.Open = counts.Total - counts.Completed
+	return &counts, nil
 }
 
-// Update updates a todo in the database
-func (r *TodoRepository) Update(id string, update *models.TodoUpdate) (*models.Todo, error) {
-	// First get the existing todo
-	todo, err := r.GetByID(id)
+// Update updates a todo in the database, writing event to the outbox in the
+// same transaction. A version other than zero must match the stored one.
+func (r *TodoRepository) Update(id string, version int64, update *models.TodoUpdate, event *models.TodoEvent) (*models.Todo, error) {
+	var todo *models.Todo
+	err := r.inTx(func(tx *sql.Tx) error {
+		// First get the existing todo
+		var err error
+		todo, err = r.getByID(tx, id)
+		if err != nil || todo == nil {
+			return err
+		}
+		if version != 0 && todo.Version != version {
+			return fmt.Errorf("todo %s is at version %d, not %d: %w", id, todo.Version, version, models.ErrVersionMismatch)
+		}
+
+		// Apply updates if provided
+		project := todo.Project
+		if err := applyUpdate(todo, update); err != nil {
+			return err
+		}
+
+		// Update the updated_at timestamp and version
+		todo.UpdatedAt = time.Now().UTC()
+		todo.Version++
+
+		if err := r.update(tx, todo, update.Tags != nil); err != nil {
+			return err
+		}
+
+		return r.recordEvent(tx, event, todo, project)
+	})
+
 	if err != nil {
 		return nil, err
 	}
-	if todo == nil {
-		return nil, nil // Not found
-	}
+	return todo, nil
+}
 
-	// Apply updates if provided
-	if update.Title != nil {
-		todo.Title = *update.Title
-	}
-	if update.Description != nil {
-		todo.Description = *update.Description
-	}
-	if update.Completed != nil {
-		todo.Completed = *update.Completed
-	}
-	if update.Priority != nil {
-		todo.Priority = *update.Priority
-	}
-	if update.DueDate != nil {
-		if *update.DueDate == "" {
-			todo.DueDate = sql.NullTime{Valid: false}
-			todo.DueDateStr = ""
-		} else {
-			dueDate, err := time.Parse(time.RFC3339, *update.DueDate)
+// Delete removes a todo from the database, writing event to the outbox in
+// the same transaction. Todos with subtasks are not deleted. A version
+// other than zero must match the stored one.
+func (r *TodoRepository) Delete(id string, version int64, event *models.TodoEvent) error {
+	return r.inTx(func(tx *sql.Tx) error {
+		todo, err := r.getByID(tx, id)
+		if err != nil {
+			return err
+		}
+		if todo == nil {
+			return nil // Not found, but not an error
+		}
+		if version != 0 && todo.Version != version {
+			return fmt.Errorf("todo %s is at version %d, not %d: %w", id, todo.Version, version, models.ErrVersionMismatch)
+		}
+
+		var subtasks int
+		query := "SELECT COUNT(*) FROM todos WHERE parent_id = ?"
+		if err := tx.QueryRow(r.rebind(query), id).Scan(&subtasks); err != nil {
+			return fmt.Errorf("failed to count subtasks: %w", err)
+		}
+		if subtasks > 0 {
+			return fmt.Errorf("todo %s has %d subtasks: %w", id, subtasks, models.ErrConflict)
+		}
+
+		// Tags go with the todo through ON DELETE CASCADE. A todo changed
+		// since it was read is left alone, as in update.
+		result, err := tx.Exec(r.rebind("DELETE FROM todos WHERE id = ? AND version = ?"), id, todo.Version)
+		if err != nil {
+			return fmt.Errorf("failed to delete todo: %w", err)
+		}
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to delete todo: %w", err)
+		}
+		if deleted == 0 {
+			return fmt.Errorf("todo %s was changed concurrently: %w", id, models.ErrVersionMismatch)
+		}
+
+		return r.recordEvent(tx, event, todo, todo.Project)
+	})
+}
+
+// Import writes todos in one transaction, creating the new ones and
+// replacing the rest, and writes their events to the outbox with them
+func (r *TodoRepository) Import(todos []*models.Todo, events []*models.TodoEvent) error {
+	return r.inTx(func(tx *sql.Tx) error {
+		for i, todo := range todos {
+			existing, err := r.getByID(tx, todo.ID)
+			if err != nil {
+				return err
+			}
+
+			eventType, project := models.EventCreated, todo.Project
+			if existing == nil {
+				err = r.insert(tx, todo)
+			} else {
+				eventType, project = models.EventUpdated, existing.Project
+				todo.CreatedAt = existing.CreatedAt
+				todo.Version = existing.Version + 1
+				err = r.update(tx, todo, true)
+			}
 			if err != nil {
-				return nil, fmt.Errorf("invalid due date format: %w", err)
+				return err
 			}
-			todo.DueDate = sql.NullTime{
-				Time:  dueDate,
-				Valid: true,
+
+			if events != nil {
+				events[i].Type = eventType
+				if err := r.recordEvent(tx, events[i], todo, project); err != nil {
+					return err
+				}
 			}
-			todo.DueDateStr = *update.DueDate
 		}
+		return nil
+	})
+}
+
+// insert adds todo and its tags within tx, at version 1
+func (r *TodoRepository) insert(tx *sql.Tx, todo *models.Todo) error {
+	query := `
+		INSERT INTO todos (` + todoColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	todo.Version = 1
+
+	_, err := tx.Exec(
+		r.rebind(query),
+		todo.ID,
+		todo.Title,
+		todo.Description,
+		todo.Project,
+		todo.ParentID,
+		todo.Completed,
+		todo.Priority,
+		nullTime(timePtr(todo.DueDate)),
+		todo.AllDay,
+		todo.CreatedAt.UTC(),
+		todo.UpdatedAt.UTC(),
+		todo.Version,
+	)
+
+	if err != nil {
+		if r.isUniqueViolation(err) {
+			return fmt.Errorf("failed to create todo %s: %w", todo.ID, models.ErrConflict)
+		}
+		return fmt.Errorf("failed to create todo: %w", err)
 	}
 
-	// Update the updated_at timestamp
-	todo.UpdatedAt = time.Now()
+	return r.saveTags(tx, todo.ID, todo.Tags)
+}
 
-	// Perform the update
+// update writes every field of todo but created_at within tx, and its tags
+// if withTags is set. todo.Version must be one past the stored version: a
+// todo changed since it was read, which reads in a PostgreSQL transaction
+// do not prevent, is not overwritten.
+func (r *TodoRepository) update(tx *sql.Tx, todo *models.Todo, withTags bool) error {
 	query := `
 		UPDATE todos
-		SET title = ?, description = ?, completed = ?, priority = ?, due_date = ?, updated_at = ?
-		WHERE id = ?
+		SET title = ?, description = ?, project = ?, parent_id = ?, completed = ?, priority = ?, due_date = ?, all_day = ?, updated_at = ?, version = ?
+		WHERE id = ? AND version = ?
 	`
 
-	_, err = r.db.Exec(
-		query,
+	result, err := tx.Exec(
+		r.rebind(query),
 		todo.Title,
 		todo.Description,
+		todo.Project,
+		todo.ParentID,
 		todo.Completed,
 		todo.Priority,
-		todo.DueDate,
-		todo.UpdatedAt,
+		nullTime(timePtr(todo.DueDate)),
+		todo.AllDay,
+		todo.UpdatedAt.UTC(),
+		todo.Version,
 		todo.ID,
+		todo.Version-1,
 	)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to update todo: %w", err)
+		return fmt.Errorf("failed to update todo: %w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update todo: %w", err)
+	}
+	if updated == 0 {
+		return fmt.Errorf("todo %s was changed concurrently: %w", todo.ID, models.ErrVersionMismatch)
 	}
 
-	return todo, nil
+	if withTags {
+		return r.saveTags(tx, todo.ID, todo.Tags)
+	}
+	return nil
 }
 
-// Delete removes a todo from the database
-func (r *TodoRepository) Delete(id string) error {
-	query := "DELETE FROM todos WHERE id = ?"
-	result, err := r.db.Exec(query, id)
+// queryTodos runs a query selecting todoColumns and loads the tags of the result
+func (r *TodoRepository) queryTodos(query string, args ...interface{}) ([]*models.Todo, error) {
+	rows, err := r.db.Query(r.rebind(query), args...)
 	if err != nil {
-		return fmt.Errorf("failed to delete todo: %w", err)
+		return nil, fmt.Errorf("failed to query todos: %w", err)
 	}
+	defer rows.Close()
 
-	rowsAffected, err := result.RowsAffected()
+	var todos []*models.Todo
+	for rows.Next() {
+		todo, err := scanTodo(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan todo row: %w", err)
+		}
+		todos = append(todos, todo)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating todo rows: %w", err)
+	}
+
+	if err := r.loadTags(r.db, todos); err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+// loadTags fills in the tags of todos with one query per batch of IDs
+func (r *TodoRepository) loadTags(q querier, todos []*models.Todo) error {
+	byID := make(map[string]*models.Todo, len(todos))
+	ids := make([]string, 0, len(todos))
+	for _, todo := range todos {
+		todo.Tags = []string{}
+		byID[todo.ID] = todo
+		ids = append(ids, todo.ID)
+	}
+
+	for _, batch := range batches(ids) {
+		query := `
+			SELECT todo_id, tag
+			FROM todo_tags
+			WHERE todo_id IN (` + placeholders(len(batch)) + `)
+			ORDER BY todo_id, tag
+		`
+		rows, err := q.Query(r.rebind(query), stringArgs(batch)...)
+		if err != nil {
+			return fmt.Errorf("failed to query tags: %w", err)
+		}
+
+		for rows.Next() {
+			var todoID, tag string
+			if err := rows.Scan(&todoID, &tag); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan tag row: %w", err)
+			}
+			todo := byID[todoID]
+			todo.Tags = append(todo.Tags, tag)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return fmt.Errorf("error iterating tag rows: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// saveTags replaces the tags of a todo within tx
+func (r *TodoRepository) saveTags(tx *sql.Tx, todoID string, tags []string) error {
+	if _, err := tx.Exec(r.rebind("DELETE FROM todo_tags WHERE todo_id = ?"), todoID); err != nil {
+		return fmt.Errorf("failed to clear tags: %w", err)
+	}
+	for _, tag := range tags {
+		if _, err := tx.Exec(r.rebind("INSERT INTO todo_tags (todo_id, tag) VALUES (?, ?)"), todoID, tag); err != nil {
+			return fmt.Errorf("failed to save tag: %w", err)
+		}
+	}
+	return nil
+}
+
+// inTx runs fn in a transaction, committing only if it succeeds
+func (r *TodoRepository) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	if rowsAffected == 0 {
-		return nil // Not found, but not an error
+	if err := fn(tx); err != nil {
+		return err
 	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
 	return nil
 }
+
+// recordEvent completes event, if any, with todo, which was in project
+// before the change, and writes it to the outbox
+func (r *TodoRepository) recordEvent(tx *sql.Tx, event *models.TodoEvent, todo *models.Todo, project string) error {
+	if event == nil {
+		return nil
+	}
+	event.TodoID = todo.ID
+	event.Todo = todo
+	if project != todo.Project {
+		event.PreviousProject = project
+	}
+	return writeOutbox(tx, r.rebind, event)
+}
+
+// scanTodo reads a row of todoColumns
+func scanTodo(row rowScanner) (*models.Todo, error) {
+	var todo models.Todo
+	err := row.Scan(
+		&todo.ID,
+		&todo.Title,
+		&todo.Description,
+		&todo.Project,
+		&todo.ParentID,
+		&todo.Completed,
+		&todo.Priority,
+		&todo.DueDate,
+		&todo.AllDay,
+		&todo.CreatedAt,
+		&todo.UpdatedAt,
+		&todo.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Drivers return times in the zone of the session or as written
+	todo.DueDate.Time = todo.DueDate.Time.UTC()
+	todo.CreatedAt = todo.CreatedAt.UTC()
+	todo.UpdatedAt = todo.UpdatedAt.UTC()
+	todo.FormatDates()
+	return &todo, nil
+}
@@ -0,0 +1,74 @@
+package repositories
+
+import "github.com/teguh/go-todo-api/internal/app/models"
+
+// TodoStore is the persistence contract for todos. Every backend must
+// return (nil, nil) from GetByID and Update when the todo does not exist,
+// treat deleting a missing todo as a no-op, wrap models.ErrConflict when
+// creating a todo whose ID is taken or deleting one with subtasks, and
+// order Find results without a sort by priority descending, then
+// created_at descending.
+// Every todo returned carries its tags, sorted, and never a nil slice.
+//
+// GetByIDs and ListSubtasks serve batched lookups of many todos at once.
+// Find and Count apply a models.TodoQuery's filter, sort and page.
+//
+// Create, Update and Delete take an optional event describing the change.
+// The store fills in its todo ID and todo (as it was just before a delete)
+// and writes it to the outbox atomically with the change, setting its ID.
+// Nothing is written when the todo does not exist.
+//
+// Stores set the Version of todos: Create starts it at 1 and Update
+// increments it. Import does the same for the todos it creates and replaces.
+// Update and Delete take the version the caller expects, or zero for any:
+// when the stored todo is at another version they wrap
+// models.ErrVersionMismatch and change nothing, so read-modify-write
+// callers do not lose updates.
+//
+// Import writes a batch of todos atomically: those whose ID is new are
+// created and the others have every field but created_at replaced. Parents
+// must come before their subtasks. events is nil or holds an event per
+// todo, whose type the store sets to EventCreated or EventUpdated.
+type TodoStore interface {
+	Create(todo *models.Todo, event *models.TodoEvent) error
+	GetByID(id string) (*models.Todo, error)
+	GetByIDs(ids []string) ([]*models.Todo, error)
+	ListSubtasks(parentIDs []string) ([]*models.Todo, error)
+	Find(query models.TodoQuery) ([]*models.Todo, error)
+	Count(filter models.TodoFilter) (*models.TodoCounts, error)
+	Update(id string, version int64, update *models.TodoUpdate, event *models.TodoEvent) (*models.Todo, error)
+	Delete(id string, version int64, event *models.TodoEvent) error
+	Import(todos []*models.Todo, events []*models.TodoEvent) error
+}
+
+// applyUpdate copies the provided fields of update onto todo.
+// An empty due date clears it; tags are normalized.
+func applyUpdate(todo *models.Todo, update *models.TodoUpdate) error {
+	if update.Title != nil {
+		todo.Title = *update.Title
+	}
+	if update.Description != nil {
+		todo.Description = *update.Description
+	}
+	if update.Project != nil {
+		todo.Project = *update.Project
+	}
+	if update.ParentID != nil {
+		todo.ParentID = *update.ParentID
+	}
+	if update.Tags != nil {
+		todo.Tags = models.NormalizeTags(*update.Tags)
+	}
+	if update.Completed != nil {
+		todo.Completed = *update.Completed
+	}
+	if update.Priority != nil {
+		todo.Priority = *update.Priority
+	}
+	if update.DueDate != nil {
+		if err := todo.SetDueDate(*update.DueDate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package repositories_test
+
+import (
+	"testing"
+
+	"github.com/teguh/go-todo-api/internal/app/repositories"
+	"github.com/teguh/go-todo-api/internal/app/repositories/storetest"
+)
+
+func TestSQLiteConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) repositories.TodoStore {
+		return repositories.NewSQLiteTodoRepository(storetest.OpenSQLite(t))
+	})
+}
+
+func TestPostgresConformance(t *testing.T) {
+	// One server for the whole suite; starting a cluster per store is slow
+	db := storetest.OpenPostgres(t)
+	storetest.Run(t, func(t *testing.T) repositories.TodoStore {
+		storetest.Empty(t, db)
+		return repositories.NewPostgresTodoRepository(db)
+	})
+}
+
+func TestMemoryConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) repositories.TodoStore {
+		return repositories.NewMemoryTodoRepository(repositories.NewMemoryOutboxRepository())
+	})
+}
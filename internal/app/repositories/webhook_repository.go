@@ -0,0 +1,422 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/database"
+)
+
+// WebhookStore persists webhooks together with their delivery queue and
+// attempt log. Getters return (nil, nil) when nothing matches, deleting a
+// webhook deletes its deliveries, and lists of deliveries are newest first.
+type WebhookStore interface {
+	CreateWebhook(hook *models.Webhook) error
+	GetWebhook(id string) (*models.Webhook, error)
+	ListWebhooks() ([]*models.Webhook, error)
+	UpdateWebhook(hook *models.Webhook) error
+	DeleteWebhook(id string) error
+
+	// EnqueueDelivery adds a pending delivery to the queue. It does nothing
+	// if the webhook already has a delivery of the event, unless delivery is
+	// a redelivery, so that events published twice are sent once.
+	EnqueueDelivery(delivery *models.WebhookDelivery) error
+	GetDelivery(id string) (*models.WebhookDelivery, error)
+	ListDeliveries(webhookID string, limit int) ([]*models.WebhookDelivery, error)
+	// ClaimDueDeliveries returns up to limit pending deliveries due at now,
+	// pushing their next attempt back by lease so that no other worker picks
+	// them up meanwhile. A worker that dies mid-delivery thus only delays it.
+	ClaimDueDeliveries(now time.Time, lease time.Duration, limit int) ([]*models.WebhookDelivery, error)
+	// RecordAttempt saves the state of delivery and, if attempt is not nil,
+	// appends it to the attempt log, setting its ID
+	RecordAttempt(delivery *models.WebhookDelivery, attempt *models.WebhookAttempt) error
+	// ListAttempts returns the attempt log of a delivery, oldest first
+	ListAttempts(deliveryID string) ([]*models.WebhookAttempt, error)
+}
+
+// WebhookRepository stores webhooks in the webhooks, webhook_deliveries
+// and webhook_attempts tables
+type WebhookRepository struct {
+	db     *sql.DB
+	rebind func(query string) string
+}
+
+// NewWebhookRepository creates the WebhookStore matching the dialect of db.
+// With the memory dialect every call returns a new, empty store.
+func NewWebhookRepository(db *database.DB) WebhookStore {
+	switch db.Dialect {
+	case database.DialectPostgres:
+		return &WebhookRepository{db: db.DB, rebind: rebindDollar}
+	case database.DialectMemory:
+		return NewMemoryWebhookRepository()
+	default:
+		return &WebhookRepository{db: db.DB, rebind: func(query string) string { return query }}
+	}
+}
+
+const webhookColumns = "id, url, secret, events, description, active, created_at, updated_at"
+
+const deliveryColumns = `id, webhook_id, event_id, event_type, payload, status, attempts,
+	next_attempt_at, last_error, response_status, redelivery_of, created_at, updated_at`
+
+// CreateWebhook inserts a new webhook
+func (r *WebhookRepository) CreateWebhook(hook *models.Webhook) error {
+	query := `
+		INSERT INTO webhooks (` + webhookColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := r.db.Exec(
+		r.rebind(query),
+		hook.ID,
+		hook.URL,
+		hook.Secret,
+		strings.Join(hook.Events, ","),
+		hook.Description,
+		hook.Active,
+		hook.CreatedAt.UTC(),
+		hook.UpdatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return nil
+}
+
+// GetWebhook retrieves a webhook by its ID
+func (r *WebhookRepository) GetWebhook(id string) (*models.Webhook, error) {
+	query := "SELECT " + webhookColumns + " FROM webhooks WHERE id = ?"
+
+	hook, err := scanWebhook(r.db.QueryRow(r.rebind(query), id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // Not found
+		}
+		return nil, fmt.Errorf("failed to get webhook by ID: %w", err)
+	}
+	return hook, nil
+}
+
+// ListWebhooks retrieves every webhook, oldest first
+func (r *WebhookRepository) ListWebhooks() ([]*models.Webhook, error) {
+	query := "SELECT " + webhookColumns + " FROM webhooks ORDER BY created_at, id"
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var hooks []*models.Webhook
+	for rows.Next() {
+		hook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook row: %w", err)
+		}
+		hooks = append(hooks, hook)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook rows: %w", err)
+	}
+	return hooks, nil
+}
+
+// UpdateWebhook saves every field of hook
+func (r *WebhookRepository) UpdateWebhook(hook *models.Webhook) error {
+	query := `
+		UPDATE webhooks
+		SET url = ?, secret = ?, events = ?, description = ?, active = ?, updated_at = ?
+		WHERE id = ?
+	`
+
+	_, err := r.db.Exec(
+		r.rebind(query),
+		hook.URL,
+		hook.Secret,
+		strings.Join(hook.Events, ","),
+		hook.Description,
+		hook.Active,
+		hook.UpdatedAt.UTC(),
+		hook.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook: %w", err)
+	}
+	return nil
+}
+
+// DeleteWebhook removes a webhook and, through the foreign keys, its deliveries
+func (r *WebhookRepository) DeleteWebhook(id string) error {
+	if _, err := r.db.Exec(r.rebind("DELETE FROM webhooks WHERE id = ?"), id); err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	return nil
+}
+
+// EnqueueDelivery inserts a delivery into the queue, unless the unique
+// index on the webhook and event already holds one
+func (r *WebhookRepository) EnqueueDelivery(delivery *models.WebhookDelivery) error {
+	query := `
+		INSERT INTO webhook_deliveries (` + deliveryColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING
+	`
+
+	_, err := r.db.Exec(
+		r.rebind(query),
+		delivery.ID,
+		delivery.WebhookID,
+		delivery.EventID,
+		delivery.EventType,
+		string(delivery.Payload),
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttemptAt.UTC(),
+		delivery.LastError,
+		delivery.ResponseStatus,
+		delivery.RedeliveryOf,
+		delivery.CreatedAt.UTC(),
+		delivery.UpdatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue delivery: %w", err)
+	}
+	return nil
+}
+
+// GetDelivery retrieves a delivery by its ID
+func (r *WebhookRepository) GetDelivery(id string) (*models.WebhookDelivery, error) {
+	query := "SELECT " + deliveryColumns + " FROM webhook_deliveries WHERE id = ?"
+
+	delivery, err := scanDelivery(r.db.QueryRow(r.rebind(query), id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // Not found
+		}
+		return nil, fmt.Errorf("failed to get delivery by ID: %w", err)
+	}
+	return delivery, nil
+}
+
+// ListDeliveries retrieves up to limit deliveries of a webhook, newest first
+func (r *WebhookRepository) ListDeliveries(webhookID string, limit int) ([]*models.WebhookDelivery, error) {
+	query := `
+		SELECT ` + deliveryColumns + `
+		FROM webhook_deliveries
+		WHERE webhook_id = ?
+		ORDER BY created_at DESC, event_id DESC
+		LIMIT ?
+	`
+	return r.queryDeliveries(query, webhookID, limit)
+}
+
+// ClaimDueDeliveries leases up to limit due deliveries. Each row is claimed
+// with a conditional update, so concurrent workers never claim the same one.
+func (r *WebhookRepository) ClaimDueDeliveries(now time.Time, lease time.Duration, limit int) ([]*models.WebhookDelivery, error) {
+	now = now.UTC()
+	query := `
+		SELECT ` + deliveryColumns + `
+		FROM webhook_deliveries
+		WHERE status = ? AND next_attempt_at <= ?
+		ORDER BY next_attempt_at, created_at
+		LIMIT ?
+	`
+	due, err := r.queryDeliveries(query, models.DeliveryPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	claim := `
+		UPDATE webhook_deliveries
+		SET next_attempt_at = ?
+		WHERE id = ? AND status = ? AND next_attempt_at = ?
+	`
+
+	var claimed []*models.WebhookDelivery
+	for _, delivery := range due {
+		leaseUntil := now.Add(lease)
+		result, err := r.db.Exec(r.rebind(claim), leaseUntil, delivery.ID, models.DeliveryPending, delivery.NextAttemptAt.UTC())
+		if err != nil {
+			return nil, fmt.Errorf("failed to claim delivery: %w", err)
+		}
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			continue // Claimed by someone else
+		}
+		delivery.NextAttemptAt = leaseUntil
+		claimed = append(claimed, delivery)
+	}
+	return claimed, nil
+}
+
+// RecordAttempt saves the delivery state and appends attempt in one transaction
+func (r *WebhookRepository) RecordAttempt(delivery *models.WebhookDelivery, attempt *models.WebhookAttempt) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	update := `
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = ?, next_attempt_at = ?, last_error = ?, response_status = ?, updated_at = ?
+		WHERE id = ?
+	`
+	_, err = tx.Exec(
+		r.rebind(update),
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttemptAt.UTC(),
+		delivery.LastError,
+		delivery.ResponseStatus,
+		delivery.UpdatedAt.UTC(),
+		delivery.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update delivery: %w", err)
+	}
+
+	if attempt != nil {
+		insert := `
+			INSERT INTO webhook_attempts (delivery_id, attempt, response_status, error, duration_ms, created_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+			RETURNING id
+		`
+		err = tx.QueryRow(
+			r.rebind(insert),
+			attempt.DeliveryID,
+			attempt.Attempt,
+			attempt.ResponseStatus,
+			attempt.Error,
+			attempt.DurationMillis,
+			attempt.CreatedAt.UTC(),
+		).Scan(&attempt.ID)
+		if err != nil {
+			return fmt.Errorf("failed to record attempt: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit attempt: %w", err)
+	}
+	return nil
+}
+
+// ListAttempts retrieves the attempt log of a delivery, oldest first
+func (r *WebhookRepository) ListAttempts(deliveryID string) ([]*models.WebhookAttempt, error) {
+	query := `
+		SELECT id, delivery_id, attempt, response_status, error, duration_ms, created_at
+		FROM webhook_attempts
+		WHERE delivery_id = ?
+		ORDER BY id
+	`
+
+	rows, err := r.db.Query(r.rebind(query), deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attempts: %w", err)
+	}
+	defer rows.Close()
+
+	var attempts []*models.WebhookAttempt
+	for rows.Next() {
+		var attempt models.WebhookAttempt
+		err := rows.Scan(
+			&attempt.ID,
+			&attempt.DeliveryID,
+			&attempt.Attempt,
+			&attempt.ResponseStatus,
+			&attempt.Error,
+			&attempt.DurationMillis,
+			&attempt.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan attempt row: %w", err)
+		}
+		attempts = append(attempts, &attempt)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating attempt rows: %w", err)
+	}
+	return attempts, nil
+}
+
+// queryDeliveries runs a query selecting deliveryColumns
+func (r *WebhookRepository) queryDeliveries(query string, args ...interface{}) ([]*models.WebhookDelivery, error) {
+	rows, err := r.db.Query(r.rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []*models.WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan delivery row: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating delivery rows: %w", err)
+	}
+	return deliveries, nil
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanWebhook(row rowScanner) (*models.Webhook, error) {
+	var hook models.Webhook
+	var events string
+	err := row.Scan(
+		&hook.ID,
+		&hook.URL,
+		&hook.Secret,
+		&events,
+		&hook.Description,
+		&hook.Active,
+		&hook.CreatedAt,
+		&hook.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	hook.Events = []string{}
+	if events != "" {
+		hook.Events = strings.Split(events, ",")
+	}
+	return &hook, nil
+}
+
+func scanDelivery(row rowScanner) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	var payload string
+	err := row.Scan(
+		&delivery.ID,
+		&delivery.WebhookID,
+		&delivery.EventID,
+		&delivery.EventType,
+		&payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&delivery.LastError,
+		&delivery.ResponseStatus,
+		&delivery.RedeliveryOf,
+		&delivery.CreatedAt,
+		&delivery.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	delivery.Payload = []byte(payload)
+	return &delivery, nil
+}
@@ -0,0 +1,113 @@
+package rpc
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+	todov1 "github.com/teguh/go-todo-api/pkg/api/todo/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// sortFields maps TodoSort values to the sort fields of a TodoQuery
+var sortFields = map[todov1.TodoSort]string{
+	todov1.TodoSort_TODO_SORT_UNSPECIFIED: "",
+	todov1.TodoSort_TODO_SORT_PRIORITY:    models.SortPriority,
+	todov1.TodoSort_TODO_SORT_CREATE_TIME: models.SortCreatedAt,
+	todov1.TodoSort_TODO_SORT_UPDATE_TIME: models.SortUpdatedAt,
+	todov1.TodoSort_TODO_SORT_DUE_TIME:    models.SortDueDate,
+	todov1.TodoSort_TODO_SORT_TITLE:       models.SortTitle,
+}
+
+// eventTypes maps event types to their protobuf enum
+var eventTypes = map[string]todov1.TodoEventType{
+	models.EventCreated: todov1.TodoEventType_TODO_EVENT_TYPE_CREATED,
+	models.EventUpdated: todov1.TodoEventType_TODO_EVENT_TYPE_UPDATED,
+	models.EventDeleted: todov1.TodoEventType_TODO_EVENT_TYPE_DELETED,
+}
+
+// pageTokenPrefix marks page tokens, which are opaque to clients but encode an offset
+const pageTokenPrefix = "offset:"
+
+// todoToProto converts a todo to its protobuf message
+func todoToProto(todo *models.Todo) *todov1.Todo {
+	msg := &todov1.Todo{
+		Id:          todo.ID,
+		Title:       todo.Title,
+		Description: todo.Description,
+		Project:     todo.Project,
+		ParentId:    todo.ParentID,
+		Tags:        todo.Tags,
+		Completed:   todo.Completed,
+		Priority:    int32(todo.Priority),
+		CreateTime:  timestamppb.New(todo.CreatedAt),
+		UpdateTime:  timestamppb.New(todo.UpdatedAt),
+		AllDay:      todo.AllDay,
+	}
+	if todo.DueDate.Valid {
+		msg.DueTime = timestamppb.New(todo.DueDate.Time)
+		if todo.AllDay {
+			msg.DueDate = todo.DueDate.Time.UTC().Format(models.DateLayout)
+		}
+	}
+	return msg
+}
+
+// eventToProto converts a change event to its protobuf message
+func eventToProto(event *models.TodoEvent) *todov1.TodoEvent {
+	msg := &todov1.TodoEvent{
+		Id:              event.ID,
+		Type:            eventTypes[event.Type],
+		TodoId:          event.TodoID,
+		Actor:           event.Actor,
+		CreateTime:      timestamppb.New(event.CreatedAt),
+		PreviousProject: event.PreviousProject,
+	}
+	if event.Todo != nil {
+		msg.Todo = todoToProto(event.Todo)
+	}
+	return msg
+}
+
+// dueDate formats a due time or date the way the models expect it, as
+// RFC3339 or, for an all-day todo, as a date. At most one may be set.
+func dueDate(ts *timestamppb.Timestamp, date string) (string, error) {
+	if date != "" {
+		if ts != nil {
+			return "", invalidArgument("due_date", "due_date cannot be set together with due_time")
+		}
+		if _, err := time.Parse(models.DateLayout, date); err != nil {
+			return "", invalidArgument("due_date", "due_date must be a date in YYYY-MM-DD format")
+		}
+		return date, nil
+	}
+	if ts == nil {
+		return "", nil
+	}
+	if err := ts.CheckValid(); err != nil {
+		return "", invalidArgument("due_time", "due_time is not a valid timestamp")
+	}
+	return ts.AsTime().Format(time.RFC3339), nil
+}
+
+func encodePageToken(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(pageTokenPrefix + strconv.Itoa(offset)))
+}
+
+func decodePageToken(token string) (int, error) {
+	if token == "" {
+		return 0, nil
+	}
+	invalid := invalidArgument("page_token", "page_token is not valid")
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || !strings.HasPrefix(string(decoded), pageTokenPrefix) {
+		return 0, invalid
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(decoded), pageTokenPrefix))
+	if err != nil || offset < 0 {
+		return 0, invalid
+	}
+	return offset, nil
+}
@@ -0,0 +1,57 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// toStatus maps an error returned by the service to a gRPC status error.
+// Validation failures carry a BadRequest detail naming each field. Like the
+// REST handlers, unknown errors are logged and reported with a generic
+// message so internal details are not leaked.
+func toStatus(err error) error {
+	var validationErr *models.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		st := status.New(codes.InvalidArgument, validationErr.Error())
+		detail := &errdetails.BadRequest{}
+		for _, field := range validationErr.Fields {
+			detail.FieldViolations = append(detail.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       field.Field,
+				Description: field.Message,
+			})
+		}
+		if withDetails, err := st.WithDetails(detail); err == nil {
+			st = withDetails
+		}
+		return st.Err()
+	case errors.Is(err, models.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, models.ErrVersionMismatch):
+		// The todo changed while it was written; the client should read it
+		// again and retry
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, models.ErrConflict):
+		// Other conflicts are with the current state, such as deleting a
+		// todo that still has subtasks, and are not worth retrying as is
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		log.Printf("Error: %v", err)
+		return status.Error(codes.Internal, "Internal Server Error")
+	}
+}
+
+// invalidArgument reports a malformed request field
+func invalidArgument(field, message string) error {
+	return toStatus(models.NewValidationError(field, message))
+}
@@ -0,0 +1,335 @@
+// Package rpc serves the todo.v1 gRPC API. It is a thin layer over the same
+// TodoService and event broker as the HTTP API, so both see the same todos,
+// validation and change events.
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/events"
+	"github.com/teguh/go-todo-api/internal/app/identity"
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/services"
+	todov1 "github.com/teguh/go-todo-api/pkg/api/todo/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// Paging limits of ListTodos
+const (
+	defaultPageSize = 20
+	maxPageSize     = services.MaxPageSize
+)
+
+// replayBatchSize bounds how many logged events are read per query while a
+// resuming watcher catches up
+const replayBatchSize = 500
+
+// userMetadataKey is the metadata key naming the acting user, the gRPC
+// counterpart of the X-User-ID header
+const userMetadataKey = "x-user-id"
+
+// TodoServer implements todov1.TodoServiceServer
+type TodoServer struct {
+	todov1.UnimplementedTodoServiceServer
+
+	service *services.TodoService
+	broker  *events.Broker
+	buffer  int
+}
+
+// NewTodoServer creates a new TodoServer delegating to service and
+// streaming events from broker
+func NewTodoServer(service *services.TodoService, broker *events.Broker) *TodoServer {
+	return &TodoServer{
+		service: service,
+		broker:  broker,
+		buffer:  64,
+	}
+}
+
+// NewServer returns a gRPC server exposing todos, the standard health
+// service and reflection. The health server reports SERVING until its
+// Shutdown is called.
+func NewServer(todos *TodoServer) (*grpc.Server, *health.Server) {
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unaryIdentity),
+		grpc.ChainStreamInterceptor(streamIdentity),
+	)
+
+	todov1.RegisterTodoServiceServer(server, todos)
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(todov1.TodoService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+
+	reflection.Register(server)
+
+	return server, healthServer
+}
+
+// Stop stops server gracefully, waiting for calls in progress, but cancels
+// whatever is still running after timeout (30s if zero). Clients may hold
+// streams open indefinitely, so a graceful stop alone might never return.
+func Stop(server *grpc.Server, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-stopped:
+	case <-timer.C:
+		server.Stop()
+		<-stopped
+	}
+}
+
+// CreateTodo creates a todo
+func (s *TodoServer) CreateTodo(ctx context.Context, req *todov1.CreateTodoRequest) (*todov1.CreateTodoResponse, error) {
+	due, err := dueDate(req.GetDueTime(), req.GetDueDate())
+	if err != nil {
+		return nil, err
+	}
+
+	todo, err := s.service.CreateTodo(ctx, models.TodoCreate{
+		Title:       req.GetTitle(),
+		Description: req.GetDescription(),
+		Project:     req.GetProject(),
+		ParentID:    req.GetParentId(),
+		Tags:        req.GetTags(),
+		Priority:    int(req.GetPriority()),
+		DueDate:     due,
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &todov1.CreateTodoResponse{Todo: todoToProto(todo)}, nil
+}
+
+// GetTodo returns a todo by ID
+func (s *TodoServer) GetTodo(ctx context.Context, req *todov1.GetTodoRequest) (*todov1.GetTodoResponse, error) {
+	todo, err := s.service.GetTodoByID(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &todov1.GetTodoResponse{Todo: todoToProto(todo)}, nil
+}
+
+// ListTodos returns a page of todos. Page sizes above the maximum are
+// lowered to it rather than rejected.
+func (s *TodoServer) ListTodos(ctx context.Context, req *todov1.ListTodosRequest) (*todov1.ListTodosResponse, error) {
+	pageSize := int(req.GetPageSize())
+	switch {
+	case pageSize < 0:
+		return nil, invalidArgument("page_size", "page_size must not be negative")
+	case pageSize == 0:
+		pageSize = defaultPageSize
+	case pageSize > maxPageSize:
+		pageSize = maxPageSize
+	}
+
+	offset, err := decodePageToken(req.GetPageToken())
+	if err != nil {
+		return nil, err
+	}
+	sort, ok := sortFields[req.GetSort()]
+	if !ok {
+		return nil, invalidArgument("sort", "sort is not a known field")
+	}
+
+	query := models.TodoQuery{
+		Filter: models.TodoFilter{
+			Completed: req.Completed,
+			Project:   req.Project,
+			Tag:       req.GetTag(),
+			ParentID:  req.ParentId,
+			Search:    req.GetSearch(),
+		},
+		Sort:       sort,
+		Descending: req.GetDescending(),
+		Limit:      pageSize,
+		Offset:     offset,
+	}
+
+	todos, err := s.service.FindTodos(ctx, query)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	counts, err := s.service.CountTodos(ctx, query.Filter)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &todov1.ListTodosResponse{
+		Todos:     make([]*todov1.Todo, 0, len(todos)),
+		TotalSize: int32(counts.Total),
+	}
+	for _, todo := range todos {
+		resp.Todos = append(resp.Todos, todoToProto(todo))
+	}
+	if next := offset + len(todos); len(todos) > 0 && next < counts.Total {
+		resp.NextPageToken = encodePageToken(next)
+	}
+	return resp, nil
+}
+
+// UpdateTodo changes the fields that are set in the request
+func (s *TodoServer) UpdateTodo(ctx context.Context, req *todov1.UpdateTodoRequest) (*todov1.UpdateTodoResponse, error) {
+	if req.GetClearDueTime() && (req.GetDueTime() != nil || req.GetDueDate() != "") {
+		return nil, invalidArgument("due_time", "due_time and due_date cannot be set together with clear_due_time")
+	}
+
+	update := models.TodoUpdate{
+		Title:       req.Title,
+		Description: req.Description,
+		Project:     req.Project,
+		ParentID:    req.ParentId,
+		Completed:   req.Completed,
+	}
+	if req.Priority != nil {
+		priority := int(req.GetPriority())
+		update.Priority = &priority
+	}
+	if req.GetTags() != nil {
+		tags := req.GetTags().GetTags()
+		update.Tags = &tags
+	}
+	switch {
+	case req.GetClearDueTime():
+		cleared := ""
+		update.DueDate = &cleared
+	case req.GetDueTime() != nil || req.GetDueDate() != "":
+		due, err := dueDate(req.GetDueTime(), req.GetDueDate())
+		if err != nil {
+			return nil, err
+		}
+		update.DueDate = &due
+	}
+
+	todo, err := s.service.UpdateTodo(ctx, req.GetId(), update)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &todov1.UpdateTodoResponse{Todo: todoToProto(todo)}, nil
+}
+
+// DeleteTodo deletes a todo
+func (s *TodoServer) DeleteTodo(ctx context.Context, req *todov1.DeleteTodoRequest) (*todov1.DeleteTodoResponse, error) {
+	if err := s.service.DeleteTodo(ctx, req.GetId()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &todov1.DeleteTodoResponse{}, nil
+}
+
+// WatchTodos streams change events, first replaying logged ones after
+// after_event_id if it is set
+func (s *TodoServer) WatchTodos(req *todov1.WatchTodosRequest, stream todov1.TodoService_WatchTodosServer) error {
+	filter := watchFilter(req)
+
+	// Subscribe before replaying so nothing published in between is missed
+	sub := s.broker.Subscribe(s.buffer, filter)
+	defer sub.Close()
+
+	send := func(event *models.TodoEvent) error {
+		return stream.Send(&todov1.WatchTodosResponse{Event: eventToProto(event)})
+	}
+
+	// Live duplicates of replayed events are skipped by their position in
+	// the log, as events are not logged in ID order
+	lastPosition := sub.After
+	if req.AfterEventId != nil {
+		cursor, err := s.broker.Cursor(req.GetAfterEventId())
+		if err != nil {
+			return toStatus(err)
+		}
+		var sendErr error
+		lastPosition, err = s.broker.Replay(cursor, replayBatchSize, filter, func(event *models.TodoEvent) error {
+			sendErr = send(event)
+			return sendErr
+		})
+		if sendErr != nil {
+			return sendErr
+		}
+		if err != nil {
+			return toStatus(err)
+		}
+	}
+
+	for {
+		select {
+		case event, ok := <-sub.C:
+			if !ok {
+				if sub.Dropped() {
+					return status.Error(codes.Unavailable, "watcher fell behind; resume with after_event_id set to the last event received")
+				}
+				return status.Error(codes.Unavailable, "server is shutting down")
+			}
+			if event.Position <= lastPosition {
+				continue
+			}
+			lastPosition = event.Position
+			if err := send(event); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return toStatus(stream.Context().Err())
+		}
+	}
+}
+
+// watchFilter selects the events a WatchTodos request asked for
+func watchFilter(req *todov1.WatchTodosRequest) events.Filter {
+	project, actor := req.GetProject(), req.GetActor()
+	if project == "" && actor == "" {
+		return nil
+	}
+	return func(event *models.TodoEvent) bool {
+		if project != "" && event.PreviousProject != project && (event.Todo == nil || event.Todo.Project != project) {
+			return false
+		}
+		return actor == "" || event.Actor == actor
+	}
+}
+
+// unaryIdentity carries the user named in the metadata into the context
+func unaryIdentity(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(withUser(ctx), req)
+}
+
+// streamIdentity carries the user named in the metadata into the stream context
+func streamIdentity(srv interface{}, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &identityStream{ServerStream: stream, ctx: withUser(stream.Context())})
+}
+
+// identityStream overrides the context of a stream
+type identityStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the context carrying the user
+func (s *identityStream) Context() context.Context {
+	return s.ctx
+}
+
+func withUser(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if users := md.Get(userMetadataKey); len(users) > 0 && users[0] != "" {
+		return identity.WithUser(ctx, users[0])
+	}
+	return ctx
+}
@@ -0,0 +1,110 @@
+package rpc_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/repositories"
+	"github.com/teguh/go-todo-api/internal/app/rpc"
+	"github.com/teguh/go-todo-api/internal/app/services"
+	todov1 "github.com/teguh/go-todo-api/pkg/api/todo/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func newServer() *rpc.TodoServer {
+	store := repositories.NewMemoryTodoRepository(repositories.NewMemoryOutboxRepository())
+	return rpc.NewTodoServer(services.NewTodoService(store, nil), nil)
+}
+
+func TestAllDayTodosStayAllDay(t *testing.T) {
+	server := newServer()
+	ctx := context.Background()
+
+	created, err := server.CreateTodo(ctx, &todov1.CreateTodoRequest{Title: "Pay rent", DueDate: "2030-01-31"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	todo := created.GetTodo()
+	if !todo.GetAllDay() || todo.GetDueDate() != "2030-01-31" || !todo.GetDueTime().AsTime().Equal(time.Date(2030, 1, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("created %v, want all-day on 2030-01-31", todo)
+	}
+
+	// Echoing the due date back, as a client sending the todo it read would,
+	// keeps the todo all-day
+	updated, err := server.UpdateTodo(ctx, &todov1.UpdateTodoRequest{Id: todo.GetId(), Title: &todo.Title, DueDate: todo.GetDueDate()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !updated.GetTodo().GetAllDay() || updated.GetTodo().GetDueDate() != "2030-01-31" {
+		t.Errorf("updated %v, want all-day on 2030-01-31", updated.GetTodo())
+	}
+
+	// A due time makes it timed again
+	due := timestamppb.New(time.Date(2030, 2, 1, 9, 30, 0, 0, time.UTC))
+	updated, err = server.UpdateTodo(ctx, &todov1.UpdateTodoRequest{Id: todo.GetId(), DueTime: due})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.GetTodo().GetAllDay() || updated.GetTodo().GetDueDate() != "" || !updated.GetTodo().GetDueTime().AsTime().Equal(due.AsTime()) {
+		t.Errorf("updated %v, want due at %v", updated.GetTodo(), due.AsTime())
+	}
+}
+
+func TestDueDateIsValidated(t *testing.T) {
+	server := newServer()
+	ctx := context.Background()
+
+	for name, req := range map[string]*todov1.CreateTodoRequest{
+		"not a date":    {Title: "a", DueDate: "2030-01-31T10:00:00Z"},
+		"with due_time": {Title: "a", DueDate: "2030-01-31", DueTime: timestamppb.Now()},
+	} {
+		if _, err := server.CreateTodo(ctx, req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%s: %v, want INVALID_ARGUMENT", name, err)
+		}
+	}
+	if _, err := server.UpdateTodo(ctx, &todov1.UpdateTodoRequest{Id: "x", DueDate: "2030-01-31", ClearDueTime: true}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("due_date with clear_due_time: %v, want INVALID_ARGUMENT", err)
+	}
+}
+
+// racingStore fails every update as if another write changed the todo
+// first
+type racingStore struct {
+	repositories.TodoStore
+}
+
+func (s racingStore) Update(id string, version int64, update *models.TodoUpdate, event *models.TodoEvent) (*models.Todo, error) {
+	return nil, fmt.Errorf("todo %s was changed concurrently: %w", id, models.ErrVersionMismatch)
+}
+
+func TestConflictCodes(t *testing.T) {
+	store := repositories.NewMemoryTodoRepository(repositories.NewMemoryOutboxRepository())
+	server := rpc.NewTodoServer(services.NewTodoService(racingStore{store}, nil), nil)
+	ctx := context.Background()
+
+	parent, err := server.CreateTodo(ctx, &todov1.CreateTodoRequest{Title: "Move house"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.CreateTodo(ctx, &todov1.CreateTodoRequest{Title: "Pack books", ParentId: parent.GetTodo().GetId()}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A concurrent change is worth retrying
+	title := "Move flat"
+	_, err = server.UpdateTodo(ctx, &todov1.UpdateTodoRequest{Id: parent.GetTodo().GetId(), Title: &title})
+	if status.Code(err) != codes.Aborted {
+		t.Errorf("update of a changing todo: %v, want ABORTED", err)
+	}
+
+	// Deleting a todo with subtasks is not until they are gone
+	_, err = server.DeleteTodo(ctx, &todov1.DeleteTodoRequest{Id: parent.GetTodo().GetId()})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("delete of a parent: %v, want FAILED_PRECONDITION", err)
+	}
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/identity"
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/repositories"
+)
+
+// CalendarService issues the per-user tokens calendar apps use to read the
+// calendar feed. Calendar apps cannot set the X-User-ID header, so the
+// token stands in for it.
+type CalendarService struct {
+	store repositories.CalendarTokenStore
+}
+
+// NewCalendarService creates a new CalendarService backed by store
+func NewCalendarService(store repositories.CalendarTokenStore) *CalendarService {
+	return &CalendarService{store: store}
+}
+
+// CreateToken issues a calendar token to the user of ctx, revoking the one
+// they had. The returned token is the only one to include the secret.
+func (s *CalendarService) CreateToken(ctx context.Context) (*models.CalendarToken, error) {
+	userID, err := calendarUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate calendar token: %w", err)
+	}
+	token := &models.CalendarToken{
+		UserID:    userID,
+		Token:     secret,
+		TokenHash: hashToken(secret),
+		CreatedAt: time.Now(),
+	}
+	if err := s.store.SaveCalendarToken(token); err != nil {
+		return nil, fmt.Errorf("failed to save calendar token: %w", err)
+	}
+	return token, nil
+}
+
+// GetToken returns the calendar token of the user of ctx, without the secret
+func (s *CalendarService) GetToken(ctx context.Context) (*models.CalendarToken, error) {
+	userID, err := calendarUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := s.store.GetCalendarToken(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get calendar token: %w", err)
+	}
+	if token == nil {
+		return nil, models.ErrCalendarTokenNotFound
+	}
+	return token, nil
+}
+
+// DeleteToken revokes the calendar token of the user of ctx
+func (s *CalendarService) DeleteToken(ctx context.Context) error {
+	userID, err := calendarUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := s.store.DeleteCalendarToken(userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete calendar token: %w", err)
+	}
+	if !deleted {
+		return models.ErrCalendarTokenNotFound
+	}
+	return nil
+}
+
+// Authenticate returns the user a calendar token was issued to, or
+// ErrUnauthorized if it is not a current token
+func (s *CalendarService) Authenticate(secret string) (string, error) {
+	if secret == "" {
+		return "", fmt.Errorf("%w: a calendar token is required", models.ErrUnauthorized)
+	}
+
+	token, err := s.store.FindCalendarToken(hashToken(secret))
+	if err != nil {
+		return "", fmt.Errorf("failed to find calendar token: %w", err)
+	}
+	if token == nil {
+		return "", fmt.Errorf("%w: invalid calendar token", models.ErrUnauthorized)
+	}
+	return token.UserID, nil
+}
+
+// calendarUser returns the user of ctx, who must be named for calendar
+// tokens to belong to someone
+func calendarUser(ctx context.Context) (string, error) {
+	userID := identity.User(ctx)
+	if userID == "" {
+		return "", models.NewValidationError("user", fmt.Sprintf("calendar tokens belong to a user; name one with the %s header", identity.UserHeader))
+	}
+	return userID, nil
+}
+
+// hashToken returns the hex SHA-256 of a token. Tokens are random, so
+// they need no salt or slow hash.
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
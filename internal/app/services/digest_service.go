@@ -0,0 +1,47 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/digest"
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+// DigestService builds the digest of due todos a user would be mailed
+type DigestService struct {
+	preferences *PreferenceService
+	generator   *digest.Generator
+}
+
+// NewDigestService creates a new DigestService building digests with
+// generator, in the time zones users set in preferences
+func NewDigestService(preferences *PreferenceService, generator *digest.Generator) *DigestService {
+	return &DigestService{
+		preferences: preferences,
+		generator:   generator,
+	}
+}
+
+// Preview builds the digest of the user of ctx as of now, without sending
+// it. Days are counted in timezone, or the user's time zone if it is "".
+func (s *DigestService) Preview(ctx context.Context, timezone string) (*models.Digest, error) {
+	preferences, err := s.preferences.GetPreferences(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if timezone == "" {
+		timezone = preferences.Timezone
+	}
+	loc, err := digest.LoadLocation(timezone)
+	if err != nil {
+		return nil, invalidTimezone()
+	}
+
+	result, err := s.generator.Generate(time.Now(), loc)
+	if err != nil {
+		return nil, err
+	}
+	result.UserID = preferences.UserID
+	return result, nil
+}
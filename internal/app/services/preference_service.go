@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/digest"
+	"github.com/teguh/go-todo-api/internal/app/identity"
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/repositories"
+	"github.com/teguh/go-todo-api/internal/app/validation"
+)
+
+// PreferenceService manages the preferences of users: their time zone and
+// the schedule of their email digest
+type PreferenceService struct {
+	store   repositories.PreferenceStore
+	digests bool
+	waker   Waker
+}
+
+// NewPreferenceService creates a new PreferenceService backed by store.
+// Users may only turn digests on when digests is true, i.e. the server can
+// send email, and waker, which may be nil, is told whenever one is scheduled.
+func NewPreferenceService(store repositories.PreferenceStore, digests bool, waker Waker) *PreferenceService {
+	return &PreferenceService{
+		store:   store,
+		digests: digests,
+		waker:   waker,
+	}
+}
+
+// GetPreferences returns the preferences of the user of ctx, or the
+// defaults if they have not set any
+func (s *PreferenceService) GetPreferences(ctx context.Context) (*models.Preferences, error) {
+	userID, err := preferenceUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	preferences, err := s.store.GetPreferences(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get preferences: %w", err)
+	}
+	if preferences == nil {
+		return models.NewPreferences(userID), nil
+	}
+	return preferences, nil
+}
+
+// ReplacePreferences replaces the preferences of the user of ctx and
+// schedules their next digest
+func (s *PreferenceService) ReplacePreferences(ctx context.Context, replace models.PreferencesReplace) (*models.Preferences, error) {
+	if err := validation.Validate(&replace); err != nil {
+		return nil, err
+	}
+	current, err := s.GetPreferences(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	preferences := models.NewPreferences(current.UserID)
+	preferences.LastDigestAt = current.LastDigestAt
+	if replace.Timezone != "" {
+		if _, err := digest.LoadLocation(replace.Timezone); err != nil {
+			return nil, invalidTimezone()
+		}
+		preferences.Timezone = replace.Timezone
+	}
+	if replace.Email != "" {
+		address, err := mail.ParseAddress(replace.Email)
+		if err != nil {
+			return nil, models.NewValidationError("email", "email must be an email address")
+		}
+		preferences.Email = address.Address
+	}
+	if replace.DigestFrequency != nil {
+		preferences.DigestFrequency = *replace.DigestFrequency
+	}
+	if replace.DigestHour != nil {
+		preferences.DigestHour = *replace.DigestHour
+	}
+	if replace.DigestWeekday != nil {
+		preferences.DigestWeekday = *replace.DigestWeekday
+	}
+
+	if preferences.DigestFrequency != models.DigestOff {
+		if !s.digests {
+			return nil, models.NewValidationError("digest_frequency", "email digests are not enabled on this server")
+		}
+		if preferences.Email == "" {
+			return nil, models.NewValidationError("email", "an email address is required to receive digests")
+		}
+	}
+
+	now := time.Now()
+	preferences.NextDigestAt = digest.Next(preferences, now)
+	preferences.UpdatedAt = &now
+	if err := s.store.SavePreferences(preferences); err != nil {
+		return nil, fmt.Errorf("failed to save preferences: %w", err)
+	}
+	if preferences.NextDigestAt != nil && s.waker != nil {
+		s.waker.Wake()
+	}
+	return preferences, nil
+}
+
+// Location returns the time zone called timezone or, when it is "", the
+// one the user of ctx set. Requests without a user are in UTC.
+func (s *PreferenceService) Location(ctx context.Context, timezone string) (*time.Location, error) {
+	if timezone == "" && identity.User(ctx) != "" {
+		preferences, err := s.GetPreferences(ctx)
+		if err != nil {
+			return nil, err
+		}
+		timezone = preferences.Timezone
+	}
+	if timezone == "" {
+		return time.UTC, nil
+	}
+
+	loc, err := digest.LoadLocation(timezone)
+	if err != nil {
+		return nil, invalidTimezone()
+	}
+	return loc, nil
+}
+
+// DeletePreferences resets the preferences of the user of ctx to the
+// defaults, which stops their digest
+func (s *PreferenceService) DeletePreferences(ctx context.Context) error {
+	userID, err := preferenceUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.store.DeletePreferences(userID); err != nil {
+		return fmt.Errorf("failed to delete preferences: %w", err)
+	}
+	return nil
+}
+
+// preferenceUser returns the user of ctx, who must be named for
+// preferences to belong to someone
+func preferenceUser(ctx context.Context) (string, error) {
+	userID := identity.User(ctx)
+	if userID == "" {
+		return "", models.NewValidationError("user", fmt.Sprintf("preferences belong to a user; name one with the %s header", identity.UserHeader))
+	}
+	return userID, nil
+}
+
+func invalidTimezone() error {
+	return models.NewValidationError("timezone", "timezone must be an IANA time zone such as Europe/Berlin")
+}
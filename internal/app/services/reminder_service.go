@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/teguh/go-todo-api/internal/app/identity"
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/repositories"
+	"github.com/teguh/go-todo-api/internal/app/validation"
+)
+
+// maxReminderBefore caps how long before the due date a reminder may fire
+const maxReminderBefore = 366 * 24 * time.Hour
+
+// ReminderService manages the reminders of todos and keeps those relative
+// to a due date in step with it
+type ReminderService struct {
+	store       repositories.ReminderStore
+	todos       repositories.TodoStore
+	preferences *PreferenceService
+	channels    []string
+	waker       Waker
+}
+
+// NewReminderService creates a new ReminderService backed by store, for
+// the todos in todos, counting all-day due dates from midnight in the time
+// zones users set in preferences. Reminders may be sent through channels,
+// and waker, which may be nil, is told whenever one is scheduled.
+func NewReminderService(store repositories.ReminderStore, todos repositories.TodoStore, preferences *PreferenceService, channels []string, waker Waker) *ReminderService {
+	return &ReminderService{
+		store:       store,
+		todos:       todos,
+		preferences: preferences,
+		channels:    channels,
+		waker:       waker,
+	}
+}
+
+// CreateReminder adds a reminder to a todo for the user of ctx. Reminders
+// before the due date need the todo to have one.
+func (s *ReminderService) CreateReminder(ctx context.Context, todoID string, create models.ReminderCreate) (*models.Reminder, error) {
+	if err := validation.Validate(&create); err != nil {
+		return nil, err
+	}
+	todo, err := s.getTodo(todoID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	reminder := &models.Reminder{
+		ID:        uuid.New().String(),
+		TodoID:    todo.ID,
+		UserID:    identity.User(ctx),
+		Channel:   create.Channel,
+		Status:    models.ReminderPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.readSchedule(reminder, create); err != nil {
+		return nil, err
+	}
+	if err := s.readRecipient(reminder, create); err != nil {
+		return nil, err
+	}
+
+	reminder.FireAt, err = s.fireAt(reminder, todo)
+	if err != nil {
+		return nil, err
+	}
+	if reminder.FireAt == nil {
+		return nil, models.NewValidationError("before", "the todo has no due date")
+	}
+	reminder.NextAttemptAt = reminder.FireAt
+
+	if err := s.store.CreateReminder(reminder); err != nil {
+		return nil, fmt.Errorf("failed to save reminder: %w", err)
+	}
+	s.wake()
+	return reminder, nil
+}
+
+// readSchedule sets when reminder fires from exactly one of At and Before
+func (s *ReminderService) readSchedule(reminder *models.Reminder, create models.ReminderCreate) error {
+	switch {
+	case (create.At == "") == (create.Before == ""):
+		return models.NewValidationError("at", "exactly one of at and before must be given")
+	case create.At != "":
+		at, _ := time.Parse(time.RFC3339, create.At)
+		reminder.At = &at
+	default:
+		before, err := time.ParseDuration(create.Before)
+		if err != nil || before < 0 || before > maxReminderBefore {
+			return models.NewValidationError("before", "before must be a duration such as 30m or 24h, of at most 366 days")
+		}
+		reminder.Before = formatBefore(before)
+	}
+	return nil
+}
+
+// readRecipient checks that the channel of reminder is enabled and sets
+// the recipient it needs
+func (s *ReminderService) readRecipient(reminder *models.Reminder, create models.ReminderCreate) error {
+	if !slices.Contains(s.channels, create.Channel) {
+		return models.NewValidationError("channel", fmt.Sprintf("%s reminders are not enabled on this server", create.Channel))
+	}
+
+	switch create.Channel {
+	case models.ChannelEmail:
+		address, err := mail.ParseAddress(create.Recipient)
+		if err != nil {
+			return models.NewValidationError("recipient", "email reminders need an email address as their recipient")
+		}
+		reminder.Recipient = address.String()
+	case models.ChannelWebhook:
+		u, err := url.Parse(create.Recipient)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return models.NewValidationError("recipient", "webhook reminders need an http or https URL as their recipient")
+		}
+		reminder.Recipient = create.Recipient
+	default:
+		if create.Recipient != "" {
+			return models.NewValidationError("recipient", fmt.Sprintf("%s reminders have no recipient", create.Channel))
+		}
+	}
+	return nil
+}
+
+// GetReminder retrieves a reminder of a todo by its ID
+func (s *ReminderService) GetReminder(todoID, id string) (*models.Reminder, error) {
+	reminder, err := s.store.GetReminder(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reminder: %w", err)
+	}
+	if reminder == nil || reminder.TodoID != todoID {
+		return nil, models.ErrReminderNotFound
+	}
+	return reminder, nil
+}
+
+// ListReminders retrieves the reminders of a todo, oldest first
+func (s *ReminderService) ListReminders(todoID string) ([]*models.Reminder, error) {
+	if _, err := s.getTodo(todoID); err != nil {
+		return nil, err
+	}
+	reminders, err := s.store.ListReminders(todoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reminders: %w", err)
+	}
+	if reminders == nil {
+		reminders = []*models.Reminder{}
+	}
+	return reminders, nil
+}
+
+// DeleteReminder deletes a reminder of a todo
+func (s *ReminderService) DeleteReminder(todoID, id string) error {
+	if _, err := s.GetReminder(todoID, id); err != nil {
+		return err
+	}
+	if err := s.store.DeleteReminder(id); err != nil {
+		return fmt.Errorf("failed to delete reminder: %w", err)
+	}
+	return nil
+}
+
+// Publish reschedules the reminders of a todo before its due date when it
+// moves, sending them again even if they were sent, and removes those of
+// deleted todos. It is the reminder sink of the outbox dispatcher.
+func (s *ReminderService) Publish(event *models.TodoEvent) error {
+	if event.Type == models.EventDeleted {
+		if err := s.store.DeleteTodoReminders(event.TodoID); err != nil {
+			return fmt.Errorf("failed to delete reminders: %w", err)
+		}
+		return nil
+	}
+	if event.Todo == nil {
+		return nil
+	}
+
+	reminders, err := s.store.ListReminders(event.TodoID)
+	if err != nil {
+		return fmt.Errorf("failed to get reminders: %w", err)
+	}
+
+	rescheduled := false
+	for _, reminder := range reminders {
+		if reminder.At != nil {
+			continue
+		}
+		fire, err := s.fireAt(reminder, event.Todo)
+		if err != nil {
+			return err
+		}
+		if sameTime(fire, reminder.FireAt) {
+			continue // Repeated events change nothing
+		}
+		reminder.FireAt = fire
+		reminder.NextAttemptAt = fire
+		reminder.Status = models.ReminderPending
+		reminder.Attempts = 0
+		reminder.LastError = ""
+		reminder.SentAt = nil
+		reminder.UpdatedAt = time.Now()
+		if err := s.store.UpdateReminder(reminder); err != nil {
+			return fmt.Errorf("failed to reschedule reminder: %w", err)
+		}
+		rescheduled = true
+	}
+
+	if rescheduled {
+		s.wake()
+	}
+	return nil
+}
+
+func (s *ReminderService) getTodo(id string) (*models.Todo, error) {
+	todo, err := s.todos.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get todo: %w", err)
+	}
+	if todo == nil {
+		return nil, models.ErrNotFound
+	}
+	return todo, nil
+}
+
+func (s *ReminderService) wake() {
+	if s.waker != nil {
+		s.waker.Wake()
+	}
+}
+
+// fireAt returns when reminder falls due for todo, or nil if it is relative
+// to a due date the todo does not have. Todos in events only carry the
+// formatted due date, so that is what is read. All-day todos are due from
+// the start of their date in the time zone of the reminder's user, or UTC
+// for reminders set without one; a later change of time zone applies once
+// the due date moves.
+func (s *ReminderService) fireAt(reminder *models.Reminder, todo *models.Todo) (*time.Time, error) {
+	if reminder.At != nil {
+		at := *reminder.At
+		return &at, nil
+	}
+	if todo.DueDateStr == "" {
+		return nil, nil
+	}
+	due, allDay, err := models.ParseDueDate(todo.DueDateStr)
+	if err != nil {
+		return nil, nil
+	}
+	start := due.Time
+	if allDay && reminder.UserID != "" && s.preferences != nil {
+		loc, err := s.preferences.Location(identity.WithUser(context.Background(), reminder.UserID), "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the time zone of %s: %w", reminder.UserID, err)
+		}
+		year, month, day := start.Date()
+		start = time.Date(year, month, day, 0, 0, 0, 0, loc)
+	}
+	before, _ := time.ParseDuration(reminder.Before)
+	fire := start.Add(-before)
+	return &fire, nil
+}
+
+// formatBefore writes d as time.Duration does, but without zero minutes
+// and seconds, so 24h stays 24h rather than 24h0m0s
+func formatBefore(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
+
+// sameTime reports whether a and b are both nil or the same instant
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
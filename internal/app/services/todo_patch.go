@@ -0,0 +1,220 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/validation"
+)
+
+// PatchFormat identifies the format of a patch document
+type PatchFormat int
+
+// Supported patch formats
+const (
+	// MergePatch is a JSON Merge Patch (RFC 7396), application/merge-patch+json
+	MergePatch PatchFormat = iota + 1
+	// JSONPatch is a JSON Patch (RFC 6902), application/json-patch+json
+	JSONPatch
+)
+
+// readOnlyFields may appear in patched documents (e.g. in JSON Patch test
+// operations) but must not change
+var readOnlyFields = []string{"id", "all_day", "created_at", "updated_at", "version"}
+
+// PatchTodo applies a patch document to the JSON representation of a todo,
+// with its times in loc as the responses that clients patch render them.
+// The patch is applied to a copy: if any operation fails, including a JSON
+// Patch test, or the result is invalid, the stored todo is left unchanged.
+// The result is only written if the todo is still at the version that was
+// patched; a concurrent change makes it fail with models.ErrConflict.
+// In the patched document null or absent description, project, due_date,
+// parent_id and tags clear those fields; title, completed and priority may
+// not be removed.
+func (s *TodoService) PatchTodo(ctx context.Context, id string, format PatchFormat, patch []byte, loc *time.Location) (*models.Todo, error) {
+	todo, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get todo: %w", err)
+	}
+	if todo == nil {
+		return nil, models.ErrNotFound
+	}
+
+	original, err := json.Marshal(todo.In(loc))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode todo: %w", err)
+	}
+
+	patched, err := applyPatch(format, original, patch)
+	if err != nil {
+		return nil, err
+	}
+
+	update, err := updateFromDocument(original, patched)
+	if err != nil {
+		return nil, err
+	}
+	if err := validation.Validate(update); err != nil {
+		return nil, err
+	}
+	if update.ParentID != nil {
+		if err := s.checkParent(id, *update.ParentID); err != nil {
+			return nil, err
+		}
+	}
+
+	updated, err := s.repo.Update(id, todo.Version, update, newEvent(ctx, models.EventUpdated))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update todo: %w", err)
+	}
+	if updated == nil {
+		return nil, models.ErrNotFound
+	}
+
+	s.wake()
+	return updated, nil
+}
+
+// applyPatch applies patch to doc in the given format
+func applyPatch(format PatchFormat, doc, patch []byte) ([]byte, error) {
+	switch format {
+	case MergePatch:
+		patched, err := jsonpatch.MergePatch(doc, patch)
+		if err != nil {
+			return nil, models.NewValidationError("patch", fmt.Sprintf("invalid merge patch: %v", err))
+		}
+		return patched, nil
+	case JSONPatch:
+		ops, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return nil, models.NewValidationError("patch", fmt.Sprintf("invalid JSON patch: %v", err))
+		}
+		patched, err := ops.Apply(doc)
+		if errors.Is(err, jsonpatch.ErrTestFailed) {
+			return nil, fmt.Errorf("JSON patch test operation failed: %w", models.ErrConflict)
+		}
+		if err != nil {
+			return nil, models.NewValidationError("patch", fmt.Sprintf("JSON patch could not be applied: %v", err))
+		}
+		return patched, nil
+	default:
+		return nil, fmt.Errorf("unsupported patch format %d", format)
+	}
+}
+
+// updateFromDocument converts a patched todo document into a full TodoUpdate,
+// reporting every field that was removed, mistyped or is read-only
+func updateFromDocument(original, patched []byte) (*models.TodoUpdate, error) {
+	var before, after map[string]json.RawMessage
+	if err := json.Unmarshal(original, &before); err != nil {
+		return nil, fmt.Errorf("failed to decode todo: %w", err)
+	}
+	if err := json.Unmarshal(patched, &after); err != nil {
+		return nil, models.NewValidationError("patch", "patched document must be a JSON object")
+	}
+
+	verr := &models.ValidationError{}
+	fail := func(field, message string) {
+		verr.Fields = append(verr.Fields, models.FieldError{Field: field, Message: message})
+	}
+
+	for _, field := range readOnlyFields {
+		if !sameJSON(before[field], after[field]) {
+			fail(field, fmt.Sprintf("%s is read-only", field))
+		}
+	}
+
+	update := &models.TodoUpdate{}
+	targets := map[string]interface{}{
+		"title":       &update.Title,
+		"description": &update.Description,
+		"project":     &update.Project,
+		"completed":   &update.Completed,
+		"priority":    &update.Priority,
+		"due_date":    &update.DueDate,
+		"parent_id":   &update.ParentID,
+		"tags":        &update.Tags,
+	}
+
+	// Report unknown fields in a stable order
+	keys := make([]string, 0, len(after))
+	for key := range after {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, ok := targets[key]; !ok && !isReadOnly(key) {
+			fail(key, fmt.Sprintf("%s is not a todo field", key))
+		}
+	}
+
+	// Only fields that actually changed end up in the update, so that
+	// untouched values are not re-validated
+	cleared := ""
+	for _, field := range []string{"title", "description", "project", "completed", "priority", "due_date", "parent_id", "tags"} {
+		raw, ok := after[field]
+		if sameJSON(before[field], raw) {
+			continue
+		}
+
+		if !ok || isNull(raw) {
+			switch field {
+			case "description":
+				update.Description = &cleared
+			case "project":
+				update.Project = &cleared
+			case "due_date":
+				update.DueDate = &cleared
+			case "parent_id":
+				update.ParentID = &cleared
+			case "tags":
+				update.Tags = &[]string{}
+			default:
+				fail(field, fmt.Sprintf("%s cannot be removed", field))
+			}
+			continue
+		}
+
+		if err := json.Unmarshal(raw, targets[field]); err != nil {
+			fail(field, fmt.Sprintf("%s has the wrong type", field))
+		}
+	}
+
+	if len(verr.Fields) > 0 {
+		return nil, verr
+	}
+	return update, nil
+}
+
+func isReadOnly(field string) bool {
+	for _, f := range readOnlyFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func isNull(raw json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+}
+
+// sameJSON reports whether two raw JSON values are semantically equal
+func sameJSON(a, b json.RawMessage) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
@@ -1,105 +1,347 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/teguh/go-todo-api/internal/app/identity"
 	"github.com/teguh/go-todo-api/internal/app/models"
 	"github.com/teguh/go-todo-api/internal/app/repositories"
+	"github.com/teguh/go-todo-api/internal/app/validation"
 )
 
+// MaxPageSize is the largest number of todos FindTodos returns at once
+const MaxPageSize = 100
+
+// Waker is told when a background worker has new work, such as outbox
+// entries to relay or webhook deliveries to send
+type Waker interface {
+	Wake()
+}
+
 // TodoService handles business logic for todos
 type TodoService struct {
-	repo *repositories.TodoRepository
+	repo  repositories.TodoStore
+	waker Waker
 }
 
-// NewTodoService creates a new TodoService
-func NewTodoService() *TodoService {
+// NewTodoService creates a new TodoService backed by repo. Every change is
+// written to the outbox together with an event, and waker, which may be
+// nil, is told once it is committed.
+func NewTodoService(repo repositories.TodoStore, waker Waker) *TodoService {
 	return &TodoService{
-		repo: repositories.NewTodoRepository(),
+		repo:  repo,
+		waker: waker,
 	}
 }
 
 // CreateTodo creates a new todo
-func (s *TodoService) CreateTodo(create models.TodoCreate) (*models.Todo, error) {
+func (s *TodoService) CreateTodo(ctx context.Context, create models.TodoCreate) (*models.Todo, error) {
 	// Validate input
-	if create.Title == "" {
-		return nil, errors.New("title is required")
+	if err := validation.Validate(&create); err != nil {
+		return nil, err
+	}
+	if err := s.checkParent("", create.ParentID); err != nil {
+		return nil, err
 	}
 
 	// Create the todo model
 	todo, err := models.NewTodo(create)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create todo model: %w", err)
+		return nil, err
 	}
 
 	// Save to database
-	if err := s.repo.Create(todo); err != nil {
+	if err := s.repo.Create(todo, newEvent(ctx, models.EventCreated)); err != nil {
 		return nil, fmt.Errorf("failed to save todo: %w", err)
 	}
 
+	s.wake()
 	return todo, nil
 }
 
 // GetTodoByID retrieves a todo by its ID
-func (s *TodoService) GetTodoByID(id string) (*models.Todo, error) {
+func (s *TodoService) GetTodoByID(ctx context.Context, id string) (*models.Todo, error) {
 	todo, err := s.repo.GetByID(id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get todo: %w", err)
 	}
 	if todo == nil {
-		return nil, errors.New("todo not found")
+		return nil, models.ErrNotFound
 	}
 	return todo, nil
 }
 
-// GetAllTodos retrieves all todos with optional filtering
-func (s *TodoService) GetAllTodos(completed *bool) ([]*models.Todo, error) {
-	todos, err := s.repo.GetAll(completed)
+// FindTodos retrieves a page of the todos matching query. Limit must be
+// between 1 and MaxPageSize, or zero to retrieve every match, in which case
+// Offset must be zero too.
+func (s *TodoService) FindTodos(ctx context.Context, query models.TodoQuery) ([]*models.Todo, error) {
+	if query.Limit < 0 || query.Limit > MaxPageSize {
+		return nil, models.NewValidationError("limit", fmt.Sprintf("limit must be between 1 and %d", MaxPageSize))
+	}
+	if query.Offset < 0 {
+		return nil, models.NewValidationError("offset", "offset must not be negative")
+	}
+	if query.Limit == 0 && query.Offset > 0 {
+		return nil, models.NewValidationError("offset", "offset requires a limit")
+	}
+	if query.Sort != "" && !isSortField(query.Sort) {
+		return nil, models.NewValidationError("sort", fmt.Sprintf("cannot sort by %s", query.Sort))
+	}
+
+	todos, err := s.repo.Find(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find todos: %w", err)
+	}
+	return todos, nil
+}
+
+// CountTodos tallies the todos matching filter
+func (s *TodoService) CountTodos(ctx context.Context, filter models.TodoFilter) (*models.TodoCounts, error) {
+	counts, err := s.repo.Count(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count todos: %w", err)
+	}
+	return counts, nil
+}
+
+// GetTodosByIDs retrieves the todos with the given IDs in one query,
+// skipping missing ones
+func (s *TodoService) GetTodosByIDs(ctx context.Context, ids []string) ([]*models.Todo, error) {
+	todos, err := s.repo.GetByIDs(ids)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get todos: %w", err)
 	}
 	return todos, nil
 }
 
+// ListSubtasks retrieves the subtasks of every given todo in one query
+func (s *TodoService) ListSubtasks(ctx context.Context, parentIDs []string) ([]*models.Todo, error) {
+	todos, err := s.repo.ListSubtasks(parentIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subtasks: %w", err)
+	}
+	return todos, nil
+}
+
 // UpdateTodo updates a todo
-func (s *TodoService) UpdateTodo(id string, update models.TodoUpdate) (*models.Todo, error) {
+func (s *TodoService) UpdateTodo(ctx context.Context, id string, update models.TodoUpdate) (*models.Todo, error) {
+	// Validate input
+	if err := validation.Validate(&update); err != nil {
+		return nil, err
+	}
+
 	// Validate that the todo exists
 	exists, err := s.repo.GetByID(id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check if todo exists: %w", err)
 	}
 	if exists == nil {
-		return nil, errors.New("todo not found")
+		return nil, models.ErrNotFound
+	}
+	if update.ParentID != nil {
+		if err := s.checkParent(id, *update.ParentID); err != nil {
+			return nil, err
+		}
 	}
 
 	// Update the todo
-	updated, err := s.repo.Update(id, &update)
+	updated, err := s.repo.Update(id, 0, &update, newEvent(ctx, models.EventUpdated))
 	if err != nil {
 		return nil, fmt.Errorf("failed to update todo: %w", err)
 	}
 	if updated == nil {
-		return nil, errors.New("todo not found")
+		return nil, models.ErrNotFound
 	}
 
+	s.wake()
 	return updated, nil
 }
 
-// DeleteTodo deletes a todo
-func (s *TodoService) DeleteTodo(id string) error {
+// Precondition restricts a write to the state a client last saw a todo in,
+// such as by its ETag. It is checked atomically with the write; the zero
+// value allows any write.
+type Precondition struct {
+	// Version, when not zero, is the version the todo must be at
+	Version int64
+	// Absent requires that the todo does not exist
+	Absent bool
+}
+
+// ReplaceTodo replaces the todo with the given client-supplied ID, creating it
+// if it does not exist yet. The ID must be a canonical lowercase UUID and, if
+// repeated in the body, must match. created reports whether a new todo was made.
+func (s *TodoService) ReplaceTodo(ctx context.Context, id string, replace models.TodoReplace) (todo *models.Todo, created bool, err error) {
+	return s.ReplaceTodoIf(ctx, id, replace, Precondition{})
+}
+
+// ReplaceTodoIf is ReplaceTodo, writing only if the todo meets pre; it
+// fails with models.ErrVersionMismatch otherwise
+func (s *TodoService) ReplaceTodoIf(ctx context.Context, id string, replace models.TodoReplace, pre Precondition) (todo *models.Todo, created bool, err error) {
+	// Validate input
+	if parsed, err := uuid.Parse(id); err != nil || parsed.String() != id {
+		return nil, false, models.NewValidationError("id", "id must be a lowercase hyphenated UUID")
+	}
+	if replace.ID != "" && replace.ID != id {
+		return nil, false, fmt.Errorf("body id %s does not match path id %s: %w", replace.ID, id, models.ErrConflict)
+	}
+	if err := validation.Validate(&replace); err != nil {
+		return nil, false, err
+	}
+	if err := s.checkParent(id, replace.ParentID); err != nil {
+		return nil, false, err
+	}
+
+	existing, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to check if todo exists: %w", err)
+	}
+	switch {
+	case existing == nil && pre.Version != 0:
+		return nil, false, fmt.Errorf("todo %s no longer exists: %w", id, models.ErrVersionMismatch)
+	case existing != nil && pre.Absent:
+		return nil, false, fmt.Errorf("todo %s already exists: %w", id, models.ErrVersionMismatch)
+	}
+
+	if existing == nil {
+		todo, err := models.NewTodo(models.TodoCreate{
+			Title:       replace.Title,
+			Description: replace.Description,
+			Project:     replace.Project,
+			ParentID:    replace.ParentID,
+			Tags:        replace.Tags,
+			Priority:    replace.Priority,
+			DueDate:     replace.DueDate,
+		})
+		if err != nil {
+			return nil, false, err
+		}
+		todo.ID = id
+		todo.Completed = replace.Completed
+
+		err = s.repo.Create(todo, newEvent(ctx, models.EventCreated))
+		if err == nil {
+			s.wake()
+			return todo, true, nil
+		}
+		// Another request created it first; fall through and replace that
+		// one unless the todo had to be new
+		if !errors.Is(err, models.ErrConflict) {
+			return nil, false, fmt.Errorf("failed to save todo: %w", err)
+		}
+		if pre.Absent {
+			return nil, false, fmt.Errorf("todo %s already exists: %w", id, models.ErrVersionMismatch)
+		}
+	}
+
+	updated, err := s.repo.Update(id, pre.Version, &models.TodoUpdate{
+		Title:       &replace.Title,
+		Description: &replace.Description,
+		Project:     &replace.Project,
+		ParentID:    &replace.ParentID,
+		Tags:        &replace.Tags,
+		Completed:   &replace.Completed,
+		Priority:    &replace.Priority,
+		DueDate:     &replace.DueDate,
+	}, newEvent(ctx, models.EventUpdated))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to replace todo: %w", err)
+	}
+	if updated == nil {
+		if pre.Version != 0 {
+			return nil, false, fmt.Errorf("todo %s no longer exists: %w", id, models.ErrVersionMismatch)
+		}
+		return nil, false, models.ErrNotFound
+	}
+
+	s.wake()
+	return updated, false, nil
+}
+
+// DeleteTodo deletes a todo. Todos with subtasks cannot be deleted.
+func (s *TodoService) DeleteTodo(ctx context.Context, id string) error {
+	return s.DeleteTodoIf(ctx, id, Precondition{})
+}
+
+// DeleteTodoIf is DeleteTodo, deleting only if the todo is at the version
+// pre names; it fails with models.ErrVersionMismatch otherwise
+func (s *TodoService) DeleteTodoIf(ctx context.Context, id string, pre Precondition) error {
 	// Validate that the todo exists
 	exists, err := s.repo.GetByID(id)
 	if err != nil {
 		return fmt.Errorf("failed to check if todo exists: %w", err)
 	}
 	if exists == nil {
-		return errors.New("todo not found")
+		return models.ErrNotFound
 	}
 
 	// Delete the todo
-	if err := s.repo.Delete(id); err != nil {
+	if err := s.repo.Delete(id, pre.Version, newEvent(ctx, models.EventDeleted)); err != nil {
 		return fmt.Errorf("failed to delete todo: %w", err)
 	}
 
+	s.wake()
 	return nil
 }
+
+// checkParent verifies that the todo with the given ID, or a new todo if id
+// is empty, may become a subtask of parentID. Subtasks are one level deep.
+func (s *TodoService) checkParent(id, parentID string) error {
+	if parentID == "" {
+		return nil
+	}
+	if parentID == id {
+		return models.NewValidationError("parent_id", "a todo cannot be its own subtask")
+	}
+
+	parent, err := s.repo.GetByID(parentID)
+	if err != nil {
+		return fmt.Errorf("failed to get parent todo: %w", err)
+	}
+	if parent == nil {
+		return models.NewValidationError("parent_id", fmt.Sprintf("parent todo %s does not exist", parentID))
+	}
+	if parent.ParentID != "" {
+		return models.NewValidationError("parent_id", "subtasks cannot have subtasks")
+	}
+
+	if id != "" {
+		counts, err := s.repo.Count(models.TodoFilter{ParentID: &id})
+		if err != nil {
+			return fmt.Errorf("failed to count subtasks: %w", err)
+		}
+		if counts.Total > 0 {
+			return models.NewValidationError("parent_id", "a todo with subtasks cannot become a subtask")
+		}
+	}
+	return nil
+}
+
+func isSortField(field string) bool {
+	for _, f := range models.SortFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// newEvent starts an event for a change made on behalf of the user in ctx;
+// the store completes it when writing it to the outbox
+func newEvent(ctx context.Context, eventType string) *models.TodoEvent {
+	return &models.TodoEvent{
+		Type:      eventType,
+		Actor:     identity.User(ctx),
+		CreatedAt: time.Now(),
+	}
+}
+
+// wake tells the outbox dispatcher a change was committed
+func (s *TodoService) wake() {
+	if s.waker != nil {
+		s.waker.Wake()
+	}
+}
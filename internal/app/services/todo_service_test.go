@@ -0,0 +1,158 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/repositories"
+	"github.com/teguh/go-todo-api/internal/app/services"
+)
+
+func newTodoService() *services.TodoService {
+	return services.NewTodoService(repositories.NewMemoryTodoRepository(repositories.NewMemoryOutboxRepository()), nil)
+}
+
+func TestReplaceTodoKeepsOldDueDate(t *testing.T) {
+	svc := newTodoService()
+	ctx := context.Background()
+	id := uuid.New().String()
+
+	replace := models.TodoReplace{Title: "Old overdue todo", DueDate: "2001-02-03T04:05:06Z"}
+	todo, created, err := svc.ReplaceTodo(ctx, id, replace)
+	if err != nil || !created {
+		t.Fatalf("replace new: %v, created %v", err, created)
+	}
+	replace.Completed = true
+	todo, created, err = svc.ReplaceTodo(ctx, id, replace)
+	if err != nil || created {
+		t.Fatalf("replace unchanged due date: %v, created %v", err, created)
+	}
+	if todo.DueDateStr != "2001-02-03T04:05:06Z" || !todo.Completed {
+		t.Errorf("replaced todo = %+v", todo)
+	}
+
+	_, err = svc.CreateTodo(ctx, models.TodoCreate{Title: "New", DueDate: "2001-02-03"})
+	var verr *models.ValidationError
+	if !errors.As(err, &verr) {
+		t.Errorf("create with old due date: got %v, want a validation error", err)
+	}
+}
+
+// racingStore changes a todo right after it is read, as a concurrent
+// request would
+type racingStore struct {
+	*repositories.MemoryTodoRepository
+	raced bool
+}
+
+func (s *racingStore) GetByID(id string) (*models.Todo, error) {
+	todo, err := s.MemoryTodoRepository.GetByID(id)
+	if todo != nil && !s.raced {
+		s.raced = true
+		title := "Changed meanwhile"
+		if _, err := s.Update(id, 0, &models.TodoUpdate{Title: &title}, nil); err != nil {
+			return nil, err
+		}
+	}
+	return todo, err
+}
+
+func TestPatchTodoDoesNotOverwriteConcurrentChange(t *testing.T) {
+	store := &racingStore{MemoryTodoRepository: repositories.NewMemoryTodoRepository(repositories.NewMemoryOutboxRepository())}
+	svc := services.NewTodoService(store, nil)
+	ctx := context.Background()
+
+	todo, err := svc.CreateTodo(ctx, models.TodoCreate{Title: "Original"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.PatchTodo(ctx, todo.ID, services.MergePatch, []byte(`{"priority": 3}`), time.UTC)
+	if !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("patch over a concurrent change: err = %v, want ErrConflict", err)
+	}
+
+	// Patched again, it applies to the current state
+	patched, err := svc.PatchTodo(ctx, todo.ID, services.MergePatch, []byte(`{"priority": 3}`), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patched.Title != "Changed meanwhile" || patched.Priority != 3 || patched.Version != 3 {
+		t.Errorf("patched todo = %+v", patched)
+	}
+}
+
+func TestPatchTodoSeesTimesInTheResponseZone(t *testing.T) {
+	svc := newTodoService()
+	ctx := context.Background()
+
+	todo, err := svc.CreateTodo(ctx, models.TodoCreate{Title: "Call", DueDate: "2030-01-31T10:00:00Z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The client tests the due date as the response rendered it in Tokyo
+	patch := `[{"op": "test", "path": "/due_date", "value": "2030-01-31T19:00:00+09:00"}, {"op": "replace", "path": "/priority", "value": 2}]`
+	patched, err := svc.PatchTodo(ctx, todo.ID, services.JSONPatch, []byte(patch), tokyo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patched.Priority != 2 || !patched.DueDate.Time.Equal(todo.DueDate.Time) {
+		t.Errorf("patched = %+v", patched)
+	}
+}
+
+func TestExportIsNotShiftedByWrites(t *testing.T) {
+	svc := newTodoService()
+	ctx := context.Background()
+	var ids []string
+	for i := 0; i < 1200; i++ {
+		todo, err := svc.CreateTodo(ctx, models.TodoCreate{Title: fmt.Sprintf("todo %04d", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, todo.ID)
+	}
+
+	todos, err := svc.ExportTodos(ctx, models.TodoQuery{Sort: models.SortTitle})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]int)
+	n := 0
+	for todo, err := range todos {
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[todo.ID]++
+		n++
+		// Midway through the first batch, todos before it are deleted and
+		// new ones sort before it, which would shift an offset
+		if n == 250 {
+			for _, id := range ids[:100] {
+				if err := svc.DeleteTodo(ctx, id); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for i := 0; i < 50; i++ {
+				if _, err := svc.CreateTodo(ctx, models.TodoCreate{Title: fmt.Sprintf("added %d", i)}); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+
+	for i, id := range ids {
+		if seen[id] != 1 {
+			t.Errorf("todo %d exported %d times", i, seen[id])
+		}
+	}
+}
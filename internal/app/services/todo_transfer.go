@@ -0,0 +1,345 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/validation"
+)
+
+// exportBatchSize is how many todos an export reads from the store at a time
+const exportBatchSize = 500
+
+// ExportTodos returns the todos matching query's filter, in its order, as a
+// sequence that reads them from the store a batch at a time while it is
+// iterated, so exports need not fit in memory. Each batch starts after the
+// last todo of the one before rather than at an offset, so todos created or
+// deleted during an export never shift the others into two batches or out
+// of all of them. Only a todo whose sort fields change mid-export may show
+// up at both its old and new place, or at neither. Exports are not paged: Limit, Offset and After must be zero.
+func (s *TodoService) ExportTodos(ctx context.Context, query models.TodoQuery) (iter.Seq2[*models.Todo, error], error) {
+	if query.Limit != 0 || query.Offset != 0 || query.After != nil {
+		return nil, models.NewValidationError("limit", "exports cannot be paged")
+	}
+	if query.Sort != "" && !isSortField(query.Sort) {
+		return nil, models.NewValidationError("sort", fmt.Sprintf("cannot sort by %s", query.Sort))
+	}
+
+	return func(yield func(*models.Todo, error) bool) {
+		query.Limit = exportBatchSize
+		for {
+			todos, err := s.repo.Find(query)
+			if err != nil {
+				yield(nil, fmt.Errorf("failed to export todos: %w", err))
+				return
+			}
+			for _, todo := range todos {
+				if !yield(todo, nil) {
+					return
+				}
+			}
+			if len(todos) < query.Limit {
+				return
+			}
+			query.After = todos[len(todos)-1]
+		}
+	}, nil
+}
+
+// ImportTodos validates rows read from an import file and, unless opts is a
+// dry run, writes them in one transaction. Rows are held to the rules of
+// ReplaceTodo in both modes, since they carry the full state of a todo: due
+// dates may lie more than a year in the past, which CreateTodo rejects, so
+// an export of overdue todos imports again. A parent_id may name another
+// row of the file as well as a stored todo. Nothing is written if any row is invalid; the
+// result then lists every error instead.
+func (s *TodoService) ImportTodos(ctx context.Context, rows []models.ImportRow, opts models.ImportOptions) (*models.ImportResult, error) {
+	if opts.Mode != models.ImportCreate && opts.Mode != models.ImportUpsert {
+		return nil, models.NewValidationError("mode", fmt.Sprintf("mode must be %s or %s", models.ImportCreate, models.ImportUpsert))
+	}
+
+	plan := &importPlan{
+		service: s,
+		rows:    rows,
+		upsert:  opts.Mode == models.ImportUpsert,
+		result: &models.ImportResult{
+			Mode:           opts.Mode,
+			DryRun:         opts.DryRun,
+			Rows:           len(rows),
+			Todos:          []models.ImportedTodo{},
+			Errors:         []models.ImportRowError{},
+			IgnoredColumns: opts.IgnoredColumns,
+		},
+	}
+	if err := plan.check(); err != nil {
+		return nil, err
+	}
+
+	result := plan.result
+	if len(result.Errors) > 0 {
+		sort.SliceStable(result.Errors, func(i, j int) bool { return result.Errors[i].Row < result.Errors[j].Row })
+		return result, nil
+	}
+
+	todos, err := plan.todos()
+	if err != nil {
+		return nil, err
+	}
+
+	actions := make([]string, len(todos))
+	if opts.DryRun {
+		for i, todo := range todos {
+			actions[i] = models.EventCreated
+			if plan.stored[todo.ID] != nil {
+				actions[i] = models.EventUpdated
+			}
+		}
+	} else {
+		// Parents are written before their subtasks
+		order := make([]int, len(todos))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(a, b int) bool {
+			return todos[order[a]].ParentID == "" && todos[order[b]].ParentID != ""
+		})
+
+		batch := make([]*models.Todo, len(todos))
+		events := make([]*models.TodoEvent, len(todos))
+		for i, index := range order {
+			batch[i] = todos[index]
+			events[i] = newEvent(ctx, "")
+		}
+		if err := s.repo.Import(batch, events); err != nil {
+			return nil, fmt.Errorf("failed to import todos: %w", err)
+		}
+		for i, index := range order {
+			actions[index] = events[i].Type
+		}
+
+		result.Committed = true
+		s.wake()
+	}
+
+	for i, todo := range todos {
+		if actions[i] == models.EventCreated {
+			result.Created++
+		} else {
+			result.Updated++
+		}
+		result.Todos = append(result.Todos, models.ImportedTodo{Row: rows[i].Row, ID: todo.ID, Action: actions[i]})
+	}
+	return result, nil
+}
+
+// importPlan works out the ID and parent each row of an import ends up
+// with, collecting the errors of every row
+type importPlan struct {
+	service *TodoService
+	rows    []models.ImportRow
+	upsert  bool
+	result  *models.ImportResult
+
+	// ids holds the ID each row is written with
+	ids []string
+	// parents holds the ID each row's parent_id resolves to
+	parents []string
+	// byFileID indexes rows by the ID given in the file
+	byFileID map[string]int
+	// stored holds the todos named by the file that already exist
+	stored map[string]*models.Todo
+}
+
+// fail records an error on row i
+func (p *importPlan) fail(i int, field, message string) {
+	p.result.Errors = append(p.result.Errors, models.ImportRowError{Row: p.rows[i].Row, Field: field, Message: message})
+}
+
+// check validates every row, resolving IDs and parents
+func (p *importPlan) check() error {
+	p.ids = make([]string, len(p.rows))
+	p.parents = make([]string, len(p.rows))
+	p.byFileID = make(map[string]int, len(p.rows))
+	valid := make([]bool, len(p.rows))
+
+	for i := range p.rows {
+		// Validate what could be read too, so every problem of a row is
+		// reported at once
+		row := &p.rows[i]
+		unread := make(map[string]bool, len(row.Errors))
+		for _, fieldErr := range row.Errors {
+			p.fail(i, fieldErr.Field, fieldErr.Message)
+			unread[fieldErr.Field] = true
+		}
+		for _, warning := range row.Warnings {
+			p.result.Warnings = append(p.result.Warnings, models.ImportRowError{Row: row.Row, Field: warning.Field, Message: warning.Message})
+		}
+		valid[i] = len(row.Errors) == 0
+		if err := validation.Validate(&row.Todo); err != nil {
+			var validationErr *models.ValidationError
+			if !errors.As(err, &validationErr) {
+				return err
+			}
+			for _, fieldErr := range validationErr.Fields {
+				if !unread[fieldErr.Field] {
+					p.fail(i, fieldErr.Field, fieldErr.Message)
+				}
+			}
+			valid[i] = false
+		}
+
+		// In create mode file IDs only link rows, so they may be anything
+		id := row.Todo.ID
+		p.ids[i] = uuid.New().String()
+		if id == "" {
+			continue
+		}
+		if first, seen := p.byFileID[id]; seen {
+			p.fail(i, "id", fmt.Sprintf("id %s is already used by row %d", id, p.rows[first].Row))
+			continue
+		}
+		p.byFileID[id] = i
+		if p.upsert {
+			if parsed, err := uuid.Parse(id); err != nil || parsed.String() != id {
+				p.fail(i, "id", "id must be a lowercase hyphenated UUID")
+				continue
+			}
+			p.ids[i] = id
+		}
+	}
+
+	// Look up the stored todos the file refers to in one go
+	var lookup []string
+	for i, row := range p.rows {
+		if p.upsert && row.Todo.ID != "" {
+			lookup = append(lookup, p.ids[i])
+		}
+		if parentID := row.Todo.ParentID; parentID != "" {
+			if _, inFile := p.byFileID[parentID]; !inFile {
+				lookup = append(lookup, parentID)
+			}
+		}
+	}
+	if err := p.loadStored(lookup); err != nil {
+		return err
+	}
+
+	for i, row := range p.rows {
+		if valid[i] {
+			p.checkParent(i, row.Todo.ParentID)
+		}
+	}
+	return p.checkStoredSubtasks()
+}
+
+// loadStored fetches the stored todos with the given IDs
+func (p *importPlan) loadStored(ids []string) error {
+	p.stored = make(map[string]*models.Todo, len(ids))
+	if len(ids) == 0 {
+		return nil
+	}
+	todos, err := p.service.repo.GetByIDs(ids)
+	if err != nil {
+		return fmt.Errorf("failed to get todos: %w", err)
+	}
+	for _, todo := range todos {
+		p.stored[todo.ID] = todo
+	}
+	return nil
+}
+
+// checkParent resolves the parent_id of row i to a row of the file or a
+// stored todo and checks that it may have subtasks
+func (p *importPlan) checkParent(i int, parentID string) {
+	if parentID == "" {
+		return
+	}
+
+	if j, inFile := p.byFileID[parentID]; inFile {
+		switch {
+		case j == i:
+			p.fail(i, "parent_id", "a todo cannot be its own subtask")
+		case p.rows[j].Todo.ParentID != "":
+			p.fail(i, "parent_id", fmt.Sprintf("parent row %d is a subtask, and subtasks cannot have subtasks", p.rows[j].Row))
+		default:
+			p.parents[i] = p.ids[j]
+		}
+		return
+	}
+
+	parent := p.stored[parentID]
+	switch {
+	case parent == nil:
+		p.fail(i, "parent_id", fmt.Sprintf("parent todo %s does not exist", parentID))
+	case parent.ParentID != "":
+		p.fail(i, "parent_id", "subtasks cannot have subtasks")
+	default:
+		p.parents[i] = parentID
+	}
+}
+
+// checkStoredSubtasks rejects upserted rows that would make a stored todo
+// with subtasks a subtask itself. Subtasks replaced by the file are judged
+// by their new parent instead.
+func (p *importPlan) checkStoredSubtasks() error {
+	var ids []string
+	rowOf := make(map[string]int)
+	for i := range p.rows {
+		if p.parents[i] != "" && p.stored[p.ids[i]] != nil {
+			ids = append(ids, p.ids[i])
+			rowOf[p.ids[i]] = i
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	subtasks, err := p.service.repo.ListSubtasks(ids)
+	if err != nil {
+		return fmt.Errorf("failed to list subtasks: %w", err)
+	}
+	failed := make(map[string]bool)
+	for _, subtask := range subtasks {
+		if j, inFile := p.byFileID[subtask.ID]; inFile && p.rows[j].Todo.ParentID != subtask.ParentID {
+			continue
+		}
+		if !failed[subtask.ParentID] {
+			failed[subtask.ParentID] = true
+			p.fail(rowOf[subtask.ParentID], "parent_id", "a todo with subtasks cannot become a subtask")
+		}
+	}
+	return nil
+}
+
+// todos builds the todo each row is written as, with the same defaults and
+// due date parsing as CreateTodo
+func (p *importPlan) todos() ([]*models.Todo, error) {
+	now := time.Now().UTC()
+	todos := make([]*models.Todo, len(p.rows))
+	for i, row := range p.rows {
+		todo, err := models.NewTodo(models.TodoCreate{
+			Title:       row.Todo.Title,
+			Description: row.Todo.Description,
+			Project:     row.Todo.Project,
+			ParentID:    p.parents[i],
+			Tags:        row.Todo.Tags,
+			Priority:    row.Todo.Priority,
+			DueDate:     row.Todo.DueDate,
+		})
+		if err != nil {
+			return nil, err
+		}
+		todo.ID = p.ids[i]
+		todo.Completed = row.Todo.Completed
+		todo.CreatedAt = now
+		todo.UpdatedAt = now
+		todos[i] = todo
+	}
+	return todos, nil
+}
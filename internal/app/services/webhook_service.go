@@ -0,0 +1,267 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/repositories"
+	"github.com/teguh/go-todo-api/internal/app/validation"
+)
+
+// maxDeliveryList caps how many deliveries ListDeliveries returns
+const maxDeliveryList = 100
+
+// WebhookService manages webhook subscriptions and queues a delivery to
+// every matching webhook for each published event
+type WebhookService struct {
+	store repositories.WebhookStore
+	waker Waker
+}
+
+// NewWebhookService creates a new WebhookService backed by store. waker,
+// which may be nil, is told whenever deliveries are queued.
+func NewWebhookService(store repositories.WebhookStore, waker Waker) *WebhookService {
+	return &WebhookService{
+		store: store,
+		waker: waker,
+	}
+}
+
+// CreateWebhook creates a webhook, generating a secret if none is given.
+// The returned webhook is the only one to include the secret.
+func (s *WebhookService) CreateWebhook(create models.WebhookCreate) (*models.Webhook, error) {
+	if err := validation.Validate(&create); err != nil {
+		return nil, err
+	}
+
+	secret := create.Secret
+	if secret == "" {
+		var err error
+		if secret, err = generateSecret(); err != nil {
+			return nil, fmt.Errorf("failed to generate secret: %w", err)
+		}
+	}
+
+	now := time.Now()
+	hook := &models.Webhook{
+		ID:          uuid.New().String(),
+		URL:         create.URL,
+		Secret:      secret,
+		Events:      dedupe(create.Events),
+		Description: create.Description,
+		Active:      create.Active == nil || *create.Active,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if err := s.store.CreateWebhook(hook); err != nil {
+		return nil, fmt.Errorf("failed to save webhook: %w", err)
+	}
+	return hook, nil
+}
+
+// GetWebhook retrieves a webhook by its ID, without its secret
+func (s *WebhookService) GetWebhook(id string) (*models.Webhook, error) {
+	hook, err := s.store.GetWebhook(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	if hook == nil {
+		return nil, models.ErrWebhookNotFound
+	}
+	hook.Secret = ""
+	return hook, nil
+}
+
+// ListWebhooks retrieves every webhook, without secrets
+func (s *WebhookService) ListWebhooks() ([]*models.Webhook, error) {
+	hooks, err := s.store.ListWebhooks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhooks: %w", err)
+	}
+	for _, hook := range hooks {
+		hook.Secret = ""
+	}
+	return hooks, nil
+}
+
+// UpdateWebhook updates a webhook; setting a secret rotates it
+func (s *WebhookService) UpdateWebhook(id string, update models.WebhookUpdate) (*models.Webhook, error) {
+	if err := validation.Validate(&update); err != nil {
+		return nil, err
+	}
+
+	hook, err := s.store.GetWebhook(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if webhook exists: %w", err)
+	}
+	if hook == nil {
+		return nil, models.ErrWebhookNotFound
+	}
+
+	if update.URL != nil {
+		hook.URL = *update.URL
+	}
+	if update.Secret != nil {
+		hook.Secret = *update.Secret
+	}
+	if update.Events != nil {
+		hook.Events = dedupe(*update.Events)
+	}
+	if update.Description != nil {
+		hook.Description = *update.Description
+	}
+	if update.Active != nil {
+		hook.Active = *update.Active
+	}
+	hook.UpdatedAt = time.Now()
+
+	if err := s.store.UpdateWebhook(hook); err != nil {
+		return nil, fmt.Errorf("failed to update webhook: %w", err)
+	}
+	hook.Secret = ""
+	return hook, nil
+}
+
+// DeleteWebhook deletes a webhook together with its deliveries
+func (s *WebhookService) DeleteWebhook(id string) error {
+	if _, err := s.GetWebhook(id); err != nil {
+		return err
+	}
+	if err := s.store.DeleteWebhook(id); err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	return nil
+}
+
+// ListDeliveries retrieves the most recent deliveries of a webhook, newest first
+func (s *WebhookService) ListDeliveries(webhookID string) ([]*models.WebhookDelivery, error) {
+	if _, err := s.GetWebhook(webhookID); err != nil {
+		return nil, err
+	}
+	deliveries, err := s.store.ListDeliveries(webhookID, maxDeliveryList)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// GetDelivery retrieves a delivery of a webhook together with its attempt log
+func (s *WebhookService) GetDelivery(webhookID, deliveryID string) (*models.WebhookDelivery, error) {
+	delivery, err := s.store.GetDelivery(deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get delivery: %w", err)
+	}
+	if delivery == nil || delivery.WebhookID != webhookID {
+		return nil, models.ErrWebhookNotFound
+	}
+
+	attempts, err := s.store.ListAttempts(deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get delivery attempts: %w", err)
+	}
+	delivery.AttemptLog = attempts
+	return delivery, nil
+}
+
+// Redeliver queues a new delivery of the same payload as an earlier one,
+// whatever its outcome, and returns it
+func (s *WebhookService) Redeliver(webhookID, deliveryID string) (*models.WebhookDelivery, error) {
+	original, err := s.store.GetDelivery(deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get delivery: %w", err)
+	}
+	if original == nil || original.WebhookID != webhookID {
+		return nil, models.ErrWebhookNotFound
+	}
+
+	delivery := newDelivery(webhookID, original.EventID, original.EventType, original.Payload)
+	delivery.RedeliveryOf = original.ID
+	if err := s.store.EnqueueDelivery(delivery); err != nil {
+		return nil, fmt.Errorf("failed to queue delivery: %w", err)
+	}
+	s.wake()
+	return delivery, nil
+}
+
+// Publish queues a delivery of event to every active webhook accepting its
+// type. It is the webhook sink of the outbox dispatcher: the store queues an
+// event once per webhook, so publishing it again after a failure does not
+// send it twice.
+func (s *WebhookService) Publish(event *models.TodoEvent) error {
+	hooks, err := s.store.ListWebhooks()
+	if err != nil {
+		return fmt.Errorf("failed to get webhooks: %w", err)
+	}
+
+	var payload []byte
+	queued := false
+	for _, hook := range hooks {
+		if !hook.Active || !hook.Accepts(event.Type) {
+			continue
+		}
+		if payload == nil {
+			if payload, err = json.Marshal(event); err != nil {
+				return fmt.Errorf("failed to encode event: %w", err)
+			}
+		}
+		if err := s.store.EnqueueDelivery(newDelivery(hook.ID, event.ID, event.Type, payload)); err != nil {
+			return fmt.Errorf("failed to queue delivery: %w", err)
+		}
+		queued = true
+	}
+
+	if queued {
+		s.wake()
+	}
+	return nil
+}
+
+func (s *WebhookService) wake() {
+	if s.waker != nil {
+		s.waker.Wake()
+	}
+}
+
+// newDelivery creates a pending delivery due immediately
+func newDelivery(webhookID string, eventID int64, eventType string, payload []byte) *models.WebhookDelivery {
+	now := time.Now()
+	return &models.WebhookDelivery{
+		ID:            uuid.New().String(),
+		WebhookID:     webhookID,
+		EventID:       eventID,
+		EventType:     eventType,
+		Payload:       payload,
+		Status:        models.DeliveryPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+}
+
+// generateSecret returns 32 random bytes, hex encoded
+func generateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// dedupe removes repeated values, keeping the first occurrence of each
+func dedupe(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := []string{}
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
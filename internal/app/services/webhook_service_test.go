@@ -0,0 +1,65 @@
+package services_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/repositories"
+	"github.com/teguh/go-todo-api/internal/app/services"
+	"github.com/teguh/go-todo-api/internal/database"
+)
+
+func TestPublishQueuesEventOnce(t *testing.T) {
+	for _, url := range []string{"sqlite://" + filepath.Join(t.TempDir(), "todo.db"), "memory://"} {
+		t.Run(url[:len("sqlite")], func(t *testing.T) {
+			db, err := database.Initialize(url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			svc := services.NewWebhookService(repositories.NewWebhookRepository(db), nil)
+
+			var hooks []*models.Webhook
+			for _, target := range []string{"https://a.example.com/hook", "https://b.example.com/hook"} {
+				hook, err := svc.CreateWebhook(models.WebhookCreate{URL: target})
+				if err != nil {
+					t.Fatal(err)
+				}
+				hooks = append(hooks, hook)
+			}
+
+			// The dispatcher publishes an event again when a later sink fails
+			event := &models.TodoEvent{ID: 7, Type: models.EventCreated, TodoID: "t1"}
+			for i := 0; i < 3; i++ {
+				if err := svc.Publish(event); err != nil {
+					t.Fatalf("publish %d: %v", i, err)
+				}
+			}
+			for _, hook := range hooks {
+				deliveries, err := svc.ListDeliveries(hook.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(deliveries) != 1 {
+					t.Fatalf("%s has %d deliveries, want 1", hook.URL, len(deliveries))
+				}
+			}
+
+			// Redeliveries are queued however often they are asked for
+			deliveries, _ := svc.ListDeliveries(hooks[0].ID)
+			for i := 0; i < 2; i++ {
+				redelivery, err := svc.Redeliver(hooks[0].ID, deliveries[0].ID)
+				if err != nil {
+					t.Fatalf("redeliver: %v", err)
+				}
+				if redelivery.RedeliveryOf != deliveries[0].ID || redelivery.EventID != 7 {
+					t.Errorf("redelivery = %+v", redelivery)
+				}
+			}
+			if all, _ := svc.ListDeliveries(hooks[0].ID); len(all) != 3 {
+				t.Errorf("got %d deliveries after two redeliveries, want 3", len(all))
+			}
+		})
+	}
+}
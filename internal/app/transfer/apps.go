@@ -0,0 +1,275 @@
+package transfer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+// Formats of the export files of other apps, which can only be imported
+const (
+	// FormatTodoist is a Todoist project exported as CSV, or tasks as
+	// returned by the Todoist API
+	FormatTodoist = "todoist"
+	// FormatTrello is a Trello board exported as JSON
+	FormatTrello = "trello"
+	// FormatMSTodo is Microsoft To Do lists with their tasks, as returned by
+	// Microsoft Graph
+	FormatMSTodo = "mstodo"
+)
+
+// appFormats lists the formats of other apps' export files
+var appFormats = []string{FormatTodoist, FormatTrello, FormatMSTodo}
+
+// CanImport reports whether files in format can be imported
+func CanImport(format string) bool {
+	return ContentType(format) != "" || IsAppFormat(format)
+}
+
+// IsAppFormat reports whether format is another app's export format. Their
+// IDs are not the API's, so they only link the rows of the file and such
+// files can only be imported in create mode. Their fields are fixed, so
+// they cannot be mapped either.
+func IsAppFormat(format string) bool {
+	for _, f := range appFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// readAppRecord decodes a record of an app's export file into v, a pointer
+// to a struct, recording the keys it has no field for as ignored under
+// prefix. Keys in also are read elsewhere and are not reported.
+func (f *fileReader) readAppRecord(data json.RawMessage, v interface{}, prefix string, also ...string) error {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil || object == nil {
+		return fmt.Errorf("is not a JSON object")
+	}
+
+	known := jsonKeys(v)
+	for _, key := range also {
+		known[key] = true
+	}
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		// Microsoft Graph annotates objects with OData metadata
+		if !known[key] && !strings.Contains(key, "@odata.") {
+			f.ignore(prefix + key)
+		}
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return fmt.Errorf("%s must be a JSON %s", typeErr.Field, jsonKind(typeErr.Type))
+		}
+		return err
+	}
+	return nil
+}
+
+// jsonKeys returns the keys of the JSON fields of the struct v points to
+func jsonKeys(v interface{}) map[string]bool {
+	keys := make(map[string]bool)
+	t := reflect.TypeOf(v).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			keys[name] = true
+		}
+	}
+	return keys
+}
+
+// jsonKind names the JSON type values of t are written as
+func jsonKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int64, reflect.Float64:
+		return "number"
+	case reflect.Slice:
+		return "array"
+	case reflect.Struct, reflect.Map, reflect.Pointer:
+		return "object"
+	default:
+		return "string"
+	}
+}
+
+// appRow returns a row holding todo, with err, if not nil, as the reason
+// its record could not be read
+func appRow(todo models.TodoReplace, err error) models.ImportRow {
+	row := models.ImportRow{Todo: todo}
+	if err != nil {
+		row.Errors = append(row.Errors, models.FieldError{Message: err.Error()})
+	}
+	return row
+}
+
+// localTimeLayouts are the layouts of times without a zone in export
+// files, tried in order
+var localTimeLayouts = []string{
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseAppTime reads a time from an export file: RFC3339, or a date or
+// local time taken in zone, an IANA time zone that defaults to UTC
+func parseAppTime(value, zone string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+
+	loc := time.UTC
+	if zone != "" {
+		var err error
+		if loc, err = time.LoadLocation(zone); err != nil {
+			return time.Time{}, fmt.Errorf("unknown time zone %q", zone)
+		}
+	}
+	for _, layout := range localTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a date or time", value)
+}
+
+// setAppDue sets the due date of row from a time in an export file,
+// reporting it on the row if it cannot be read. A date alone makes the todo
+// all-day.
+func setAppDue(row *models.ImportRow, value, zone string) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+	if _, err := time.Parse(models.DateLayout, value); err == nil {
+		row.Todo.DueDate = value
+		return
+	}
+	due, err := parseAppTime(value, zone)
+	if err != nil {
+		row.Errors = append(row.Errors, models.FieldError{Field: "due_date", Message: fmt.Sprintf("due date: %v", err)})
+		return
+	}
+	row.Todo.DueDate = due.Format(time.RFC3339)
+}
+
+// The longest text todos take, as validated on models.TodoReplace
+const (
+	maxTitleLength       = 200
+	maxDescriptionLength = 2000
+	maxProjectLength     = 100
+	maxTags              = 20
+	maxTagLength         = 50
+)
+
+// fitAppRows shortens text read from other apps' export files to what todos
+// take, warning on the row of each field that was changed. Those apps allow
+// longer names and notes, and one long card should not fail an import that
+// cannot be fixed in the file.
+func fitAppRows(rows []models.ImportRow) {
+	for i := range rows {
+		row := &rows[i]
+		todo := &row.Todo
+		warn := func(field, message string) {
+			row.Warnings = append(row.Warnings, models.FieldError{Field: field, Message: message})
+		}
+
+		if title, ok := shorten(todo.Title, maxTitleLength); ok {
+			todo.Title = title
+			warn("title", fmt.Sprintf("title was shortened to %d characters", maxTitleLength))
+		}
+		if description, ok := shorten(todo.Description, maxDescriptionLength); ok {
+			todo.Description = description
+			warn("description", fmt.Sprintf("description was shortened to %d characters", maxDescriptionLength))
+		}
+		if project, ok := shorten(todo.Project, maxProjectLength); ok {
+			todo.Project = project
+			warn("project", fmt.Sprintf("project was shortened to %d characters", maxProjectLength))
+		}
+
+		if todo.Tags == nil {
+			continue
+		}
+		tags := models.NormalizeTags(todo.Tags)
+		shortened := false
+		for j, tag := range tags {
+			if short, ok := shorten(tag, maxTagLength); ok {
+				tags[j] = short
+				shortened = true
+			}
+		}
+		if shortened {
+			tags = models.NormalizeTags(tags)
+			warn("tags", fmt.Sprintf("tags were shortened to %d characters", maxTagLength))
+		}
+		if len(tags) > maxTags {
+			warn("tags", fmt.Sprintf("only the first %d of %d tags were kept", maxTags, len(tags)))
+			tags = tags[:maxTags]
+		}
+		todo.Tags = tags
+	}
+}
+
+// shorten cuts s, without surrounding white space, to at most max
+// characters, ending it with an ellipsis. It reports whether s was cut.
+func shorten(s string, max int) (string, bool) {
+	s = strings.TrimSpace(s)
+	if utf8.RuneCountInString(s) <= max {
+		return s, false
+	}
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:max-1])) + "…", true
+}
+
+// appendNote adds a note to a description, after a blank line
+func appendNote(description, note string) string {
+	note = strings.TrimSpace(note)
+	switch {
+	case note == "":
+		return description
+	case description == "":
+		return note
+	default:
+		return description + "\n\n" + note
+	}
+}
+
+// topLevel returns the record at the top of the tree id is in, following
+// parents, which maps the ID of every record of the file to the ID of its
+// parent, or "" if id is not in the file. Subtasks are one level deep, so
+// deeper ones are attached to the top of their tree; parents missing from
+// the file are ignored.
+func topLevel(id string, parents map[string]string) string {
+	if _, inFile := parents[id]; !inFile {
+		return ""
+	}
+	seen := map[string]bool{id: true}
+	for {
+		parent := parents[id]
+		if _, inFile := parents[parent]; !inFile || seen[parent] {
+			return id
+		}
+		seen[parent] = true
+		id = parent
+	}
+}
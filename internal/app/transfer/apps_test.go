@@ -0,0 +1,155 @@
+package transfer_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/repositories"
+	"github.com/teguh/go-todo-api/internal/app/services"
+	"github.com/teguh/go-todo-api/internal/app/transfer"
+)
+
+// importFixture reads an export file from testdata and imports it into an
+// empty store, failing the test unless every row is written
+func importFixture(t *testing.T, format, name string) ([]models.ImportRow, *models.ImportResult, []*models.Todo) {
+	t.Helper()
+
+	file, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	rows, ignored, err := transfer.Read(file, format, nil, 0)
+	if err != nil {
+		t.Fatalf("read %s: %v", name, err)
+	}
+
+	store := repositories.NewMemoryTodoRepository(repositories.NewMemoryOutboxRepository())
+	service := services.NewTodoService(store, nil)
+	result, err := service.ImportTodos(context.Background(), rows, models.ImportOptions{Mode: models.ImportCreate, IgnoredColumns: ignored})
+	if err != nil {
+		t.Fatalf("import %s: %v", name, err)
+	}
+	if !result.Committed || len(result.Errors) > 0 {
+		t.Fatalf("import %s not committed: %+v", name, result.Errors)
+	}
+
+	todos, err := store.Find(models.TodoQuery{Sort: models.SortTitle})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows, result, todos
+}
+
+// find returns the todo whose title starts with prefix
+func find(t *testing.T, todos []*models.Todo, prefix string) *models.Todo {
+	t.Helper()
+	for _, todo := range todos {
+		if strings.HasPrefix(todo.Title, prefix) {
+			return todo
+		}
+	}
+	t.Fatalf("no todo titled %q...", prefix)
+	return nil
+}
+
+// hasWarning reports whether result warns about field on row
+func hasWarning(result *models.ImportResult, row int, field string) bool {
+	for _, warning := range result.Warnings {
+		if warning.Row == row && warning.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+func checkShortened(t *testing.T, field, value string, max int) {
+	t.Helper()
+	if n := utf8.RuneCountInString(value); n != max || !strings.HasSuffix(value, "…") {
+		t.Errorf("%s has %d characters, want %d ending in an ellipsis", field, n, max)
+	}
+}
+
+func TestImportTrelloExport(t *testing.T) {
+	_, result, todos := importFixture(t, transfer.FormatTrello, "trello.json")
+	if result.Created != 4 {
+		t.Errorf("created %d todos, want 4", result.Created)
+	}
+
+	migration := find(t, todos, "Migrate the billing service")
+	checkShortened(t, "title", migration.Title, 200)
+	checkShortened(t, "description", migration.Description, 2000)
+	if !hasWarning(result, 1, "title") || !hasWarning(result, 1, "description") {
+		t.Errorf("no warnings for the long card: %+v", result.Warnings)
+	}
+	if strings.Join(migration.Tags, ",") != "backend,backlog,green" || migration.Project != "Platform roadmap" {
+		t.Errorf("card = %+v", migration)
+	}
+
+	step := find(t, todos, "Dual-write invoices")
+	if step.ParentID != migration.ID || step.Completed || step.DueDateStr != "2030-05-01T09:00:00Z" {
+		t.Errorf("checklist item = %+v", step)
+	}
+	if !find(t, todos, "Write the migration plan").Completed {
+		t.Error("completed checklist item imported as open")
+	}
+
+	// Due more than a year ago, in an archived list
+	retired := find(t, todos, "Retire the 2019 staging cluster")
+	if !retired.Completed || retired.DueDateStr != "2019-11-15T17:00:00Z" {
+		t.Errorf("archived card = %+v", retired)
+	}
+}
+
+func TestImportTodoistCSVExport(t *testing.T) {
+	rows, result, todos := importFixture(t, transfer.FormatTodoist, "todoist.csv")
+	if len(rows) != 3 || result.Created != 3 {
+		t.Fatalf("read %d rows and created %d todos, want 3", len(rows), result.Created)
+	}
+
+	passport := find(t, todos, "Renew passport")
+	if passport.Priority != 5 || strings.Join(passport.Tags, ",") != "admin,errands,travel" || !passport.AllDay || passport.DueDateStr != "2030-02-01" {
+		t.Errorf("task = %+v", passport)
+	}
+	if !strings.HasSuffix(passport.Description, "Office opens at 8, book online first") {
+		t.Errorf("note not added to the description: %q", passport.Description)
+	}
+	booking := find(t, todos, "Book the appointment")
+	if booking.ParentID != passport.ID || booking.DueDateStr != "2030-01-20T08:30:00Z" {
+		t.Errorf("subtask = %+v", booking)
+	}
+
+	read := find(t, todos, "Read: ")
+	checkShortened(t, "title", read.Title, 200)
+	if !hasWarning(result, 3, "title") || read.DueDateStr != "2021-03-04" {
+		t.Errorf("long task = %+v, warnings %+v", read, result.Warnings)
+	}
+}
+
+func TestImportMSTodoExport(t *testing.T) {
+	_, result, todos := importFixture(t, transfer.FormatMSTodo, "mstodo.json")
+	if result.Created != 3 || len(result.IgnoredColumns) == 0 {
+		t.Errorf("created %d todos and ignored %v", result.Created, result.IgnoredColumns)
+	}
+
+	migration := find(t, todos, "Migrate the billing service")
+	checkShortened(t, "title", migration.Title, 200)
+	checkShortened(t, "description", migration.Description, 2000)
+	if strings.Contains(migration.Description, "<p>") || migration.Priority != 5 || migration.Project != "Work" {
+		t.Errorf("task = %+v", migration)
+	}
+	if !hasWarning(result, 1, "title") || !hasWarning(result, 1, "description") {
+		t.Errorf("no warnings for the long task: %+v", result.Warnings)
+	}
+
+	expenses := find(t, todos, "File the 2018 expense report")
+	if !expenses.Completed || expenses.DueDateStr != "2019-01-07T00:00:00Z" {
+		t.Errorf("old task = %+v", expenses)
+	}
+}
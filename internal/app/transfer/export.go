@@ -0,0 +1,193 @@
+// Package transfer reads and writes todos in the file formats used to move
+// them between systems: CSV, newline-delimited JSON, JSON arrays, iCalendar
+// files of VTODOs, and the plain text of todo.txt files and Markdown
+// checklists, which keep every field so they can be edited by hand and
+// imported again. It also reads the export files of Todoist,
+// Trello and Microsoft To Do, so their tasks can be imported.
+package transfer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/ical"
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+// Supported formats
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+	FormatJSON   = "json"
+	FormatICS    = "ics"
+	// FormatTodoTxt is a todo.txt file, a todo per line
+	FormatTodoTxt = "todotxt"
+	// FormatMarkdown is a Markdown checklist under headings naming projects
+	FormatMarkdown = "markdown"
+)
+
+// contentTypes maps each format to its media type
+var contentTypes = map[string]string{
+	FormatCSV:      "text/csv",
+	FormatNDJSON:   "application/x-ndjson",
+	FormatJSON:     "application/json",
+	FormatICS:      "text/calendar",
+	FormatTodoTxt:  "text/plain",
+	FormatMarkdown: "text/markdown",
+}
+
+// extensions maps the formats whose file extension is not their name to it
+var extensions = map[string]string{
+	FormatTodoTxt:  "txt",
+	FormatMarkdown: "md",
+}
+
+// ContentType returns the media type of format, or "" if it is not supported
+func ContentType(format string) string {
+	return contentTypes[format]
+}
+
+// Extension returns the file extension of format
+func Extension(format string) string {
+	if extension, ok := extensions[format]; ok {
+		return extension
+	}
+	return format
+}
+
+// FormatOf returns the format whose media type is mediaType, or ""
+func FormatOf(mediaType string) string {
+	for format, contentType := range contentTypes {
+		if contentType == mediaType {
+			return format
+		}
+	}
+	return ""
+}
+
+// csvColumns lists the columns of exported CSV files, in order. Every one
+// but the timestamps can be imported again.
+var csvColumns = []string{"id", "title", "description", "project", "parent_id", "tags", "completed", "priority", "due_date", "created_at", "updated_at"}
+
+// tagSeparator joins the tags of a todo in a CSV cell
+const tagSeparator = ","
+
+// Writer writes todos to a file one at a time. Close must be called once
+// they are all written to finish the file.
+type Writer interface {
+	Write(todo *models.Todo) error
+	Close() error
+}
+
+// NewWriter returns a Writer producing format on w
+func NewWriter(w io.Writer, format string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case FormatNDJSON:
+		return &ndjsonWriter{enc: json.NewEncoder(w)}, nil
+	case FormatJSON:
+		return &jsonWriter{w: w}, nil
+	case FormatICS:
+		return ical.NewWriter(w, ical.WriterOptions{Method: ical.MethodPublish}), nil
+	case FormatTodoTxt:
+		return &todoTxtWriter{w: w}, nil
+	case FormatMarkdown:
+		return &markdownWriter{w: w}, nil
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+// csvWriter writes a header followed by a record per todo
+type csvWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (c *csvWriter) Write(todo *models.Todo) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	return c.w.Write([]string{
+		todo.ID,
+		todo.Title,
+		todo.Description,
+		todo.Project,
+		todo.ParentID,
+		strings.Join(todo.Tags, tagSeparator),
+		strconv.FormatBool(todo.Completed),
+		strconv.Itoa(todo.Priority),
+		todo.DueDateStr,
+		todo.CreatedAt.Format(time.RFC3339),
+		todo.UpdatedAt.Format(time.RFC3339),
+	})
+}
+
+// Close writes the header of an empty file and flushes the records
+func (c *csvWriter) Close() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) writeHeader() error {
+	if c.headerWritten {
+		return nil
+	}
+	c.headerWritten = true
+	return c.w.Write(csvColumns)
+}
+
+// ndjsonWriter writes each todo as a line of JSON
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonWriter) Write(todo *models.Todo) error {
+	return n.enc.Encode(todo)
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
+
+// jsonWriter writes a JSON array, one todo per line
+type jsonWriter struct {
+	w       io.Writer
+	written bool
+}
+
+func (j *jsonWriter) Write(todo *models.Todo) error {
+	data, err := json.Marshal(todo)
+	if err != nil {
+		return err
+	}
+
+	prefix := ",\n"
+	if !j.written {
+		prefix = "[\n"
+		j.written = true
+	}
+	if _, err := io.WriteString(j.w, prefix); err != nil {
+		return err
+	}
+	_, err = j.w.Write(data)
+	return err
+}
+
+func (j *jsonWriter) Close() error {
+	end := "\n]\n"
+	if !j.written {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(j.w, end)
+	return err
+}
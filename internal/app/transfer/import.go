@@ -0,0 +1,411 @@
+package transfer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/teguh/go-todo-api/internal/app/ical"
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+// Mapping maps the columns of an import file, lowercased, to the todo
+// fields in models.ImportFields. Columns named after a field map to it
+// unless mapped elsewhere.
+type Mapping map[string]string
+
+// ParseMapping reads column mappings written as "column:field"
+func ParseMapping(specs []string) (Mapping, error) {
+	mapping := make(Mapping, len(specs))
+	for _, spec := range specs {
+		i := strings.LastIndex(spec, ":")
+		if i < 0 {
+			return nil, models.NewValidationError("map", fmt.Sprintf("mapping %q must be written as column:field", spec))
+		}
+		column := normalizeColumn(spec[:i])
+		field := strings.TrimSpace(spec[i+1:])
+		if !isImportField(field) {
+			return nil, models.NewValidationError("map", fmt.Sprintf("cannot map %q to unknown field %q", spec[:i], field))
+		}
+		mapping[column] = field
+	}
+	return mapping, nil
+}
+
+// field returns the field column maps to, or "" if it maps to none
+func (m Mapping) field(column string) string {
+	column = normalizeColumn(column)
+	if field, ok := m[column]; ok {
+		return field
+	}
+	if isImportField(column) {
+		return column
+	}
+	return ""
+}
+
+// Read parses an import file in format into rows, returning the columns
+// that map to no field, which are skipped; for the export files of other
+// apps, these are the fields of their tasks that todos have no counterpart
+// for. Their text is shortened to fit a todo, with a warning on the row.
+// Fields that cannot be read are reported on their row; problems with
+// the file as a whole, such as malformed CSV or more than maxRows rows, are
+// returned as a *models.ValidationError for the "file" field.
+func Read(r io.Reader, format string, mapping Mapping, maxRows int) ([]models.ImportRow, []string, error) {
+	if (IsAppFormat(format) || isTextFormat(format)) && len(mapping) > 0 {
+		return nil, nil, models.NewValidationError("map", fmt.Sprintf("the fields of %s files cannot be mapped", format))
+	}
+	reader := &fileReader{mapping: mapping, maxRows: maxRows, ignoredSet: make(map[string]bool)}
+
+	var err error
+	switch format {
+	case FormatCSV:
+		err = reader.readCSV(r)
+	case FormatNDJSON:
+		err = reader.readNDJSON(r)
+	case FormatJSON:
+		err = reader.readJSON(r)
+	case FormatICS:
+		err = reader.readICS(r)
+	case FormatTodoTxt:
+		err = reader.readTodoTxt(r)
+	case FormatMarkdown:
+		err = reader.readMarkdown(r)
+	case FormatTodoist:
+		err = reader.readTodoist(r)
+	case FormatTrello:
+		err = reader.readTrello(r)
+	case FormatMSTodo:
+		err = reader.readMSTodo(r)
+	default:
+		err = fmt.Errorf("unsupported format %q", format)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if IsAppFormat(format) {
+		fitAppRows(reader.rows)
+	}
+	return reader.rows, reader.ignored, nil
+}
+
+// fileReader accumulates the rows of an import file
+type fileReader struct {
+	mapping    Mapping
+	maxRows    int
+	rows       []models.ImportRow
+	ignored    []string
+	ignoredSet map[string]bool
+}
+
+// addRow appends a row, failing once there are more than maxRows
+func (f *fileReader) addRow(row models.ImportRow) error {
+	if f.maxRows > 0 && len(f.rows) >= f.maxRows {
+		return fileError(fmt.Sprintf("the file has more than %d rows", f.maxRows))
+	}
+	row.Row = len(f.rows) + 1
+	f.rows = append(f.rows, row)
+	return nil
+}
+
+// ignore records a column that maps to no field
+func (f *fileReader) ignore(column string) {
+	if !f.ignoredSet[column] {
+		f.ignoredSet[column] = true
+		f.ignored = append(f.ignored, column)
+	}
+}
+
+// readCSV reads a CSV file whose first record names the columns
+func (f *fileReader) readCSV(r io.Reader) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return csvError(err)
+	}
+	// Spreadsheets often save CSV with a byte order mark
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+
+	fields := make([]string, len(header))
+	mappedFrom := make(map[string]string, len(header))
+	for i, column := range header {
+		field := f.mapping.field(column)
+		if field == "" {
+			f.ignore(column)
+			continue
+		}
+		if other, taken := mappedFrom[field]; taken {
+			return models.NewValidationError("map", fmt.Sprintf("columns %q and %q both map to %s", other, column, field))
+		}
+		mappedFrom[field] = column
+		fields[i] = field
+	}
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return csvError(err)
+		}
+
+		var row models.ImportRow
+		if len(record) > len(header) {
+			row.Errors = append(row.Errors, models.FieldError{
+				Message: fmt.Sprintf("has %d fields but the header has %d", len(record), len(header)),
+			})
+		}
+		for i, value := range record {
+			if i < len(fields) && fields[i] != "" {
+				if message := setField(&row.Todo, fields[i], value); message != "" {
+					row.Errors = append(row.Errors, models.FieldError{Field: fields[i], Message: message})
+				}
+			}
+		}
+		if err := f.addRow(row); err != nil {
+			return err
+		}
+	}
+}
+
+// readNDJSON reads a JSON object per line, skipping blank lines
+func (f *fileReader) readNDJSON(r io.Reader) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			if err := f.addRow(f.jsonRow(line)); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readJSON reads a JSON array of objects
+func (f *fileReader) readJSON(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return fileError("expected a JSON array of todos")
+	}
+	for dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return fileError(fmt.Sprintf("malformed JSON at offset %d", dec.InputOffset()))
+		}
+		if err := f.addRow(f.jsonRow(raw)); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return fileError("malformed JSON: the array is not closed")
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return fileError("unexpected data after the array")
+	}
+	return nil
+}
+
+// readICS reads the VTODOs of an iCalendar file, skipping its other
+// components. Its properties are fixed, so they cannot be mapped.
+func (f *fileReader) readICS(r io.Reader) error {
+	if len(f.mapping) > 0 {
+		return models.NewValidationError("map", "iCalendar properties cannot be mapped")
+	}
+
+	calendar, err := ical.Decode(r)
+	if err != nil {
+		var syntaxErr *ical.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return fileError(fmt.Sprintf("malformed iCalendar on line %d: %s", syntaxErr.Line, syntaxErr.Message))
+		}
+		return err
+	}
+
+	for _, component := range calendar.Children {
+		if component.Name != ical.Todo {
+			continue
+		}
+		var row models.ImportRow
+		row.Todo, row.Errors = ical.ReadTodo(component)
+		if err := f.addRow(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonRow reads a row from a JSON object, whose keys are the columns
+func (f *fileReader) jsonRow(data []byte) models.ImportRow {
+	var row models.ImportRow
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil || object == nil {
+		row.Errors = append(row.Errors, models.FieldError{Message: "is not a JSON object"})
+		return row
+	}
+
+	// Keys are visited in a fixed order so duplicate mappings are reported
+	// the same way every time
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	mappedFrom := make(map[string]string, len(keys))
+	for _, key := range keys {
+		field := f.mapping.field(key)
+		if field == "" {
+			f.ignore(key)
+			continue
+		}
+		if other, taken := mappedFrom[field]; taken {
+			row.Errors = append(row.Errors, models.FieldError{
+				Field:   field,
+				Message: fmt.Sprintf("keys %q and %q both map to %s", other, key, field),
+			})
+			continue
+		}
+		mappedFrom[field] = key
+		if message := setJSONField(&row.Todo, field, object[key]); message != "" {
+			row.Errors = append(row.Errors, models.FieldError{Field: field, Message: message})
+		}
+	}
+	return row
+}
+
+// setField sets a field of todo from a CSV cell, returning why it could not
+func setField(todo *models.TodoReplace, field, value string) string {
+	switch field {
+	case "id":
+		todo.ID = strings.TrimSpace(value)
+	case "title":
+		todo.Title = value
+	case "description":
+		todo.Description = value
+	case "project":
+		todo.Project = value
+	case "parent_id":
+		todo.ParentID = strings.TrimSpace(value)
+	case "tags":
+		todo.Tags = nil
+		for _, tag := range strings.Split(value, tagSeparator) {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				todo.Tags = append(todo.Tags, tag)
+			}
+		}
+	case "completed":
+		completed, ok := parseBool(value)
+		if !ok {
+			return "completed must be true or false"
+		}
+		todo.Completed = completed
+	case "priority":
+		value = strings.TrimSpace(value)
+		if value == "" {
+			todo.Priority = 0
+			return ""
+		}
+		priority, err := strconv.Atoi(value)
+		if err != nil {
+			return "priority must be an integer"
+		}
+		todo.Priority = priority
+	case "due_date":
+		todo.DueDate = strings.TrimSpace(value)
+	}
+	return ""
+}
+
+// setJSONField sets a field of todo from a JSON value; null leaves it unset
+func setJSONField(todo *models.TodoReplace, field string, raw json.RawMessage) string {
+	if string(bytes.TrimSpace(raw)) == "null" {
+		return ""
+	}
+
+	var target interface{}
+	kind := "string"
+	switch field {
+	case "id":
+		target = &todo.ID
+	case "title":
+		target = &todo.Title
+	case "description":
+		target = &todo.Description
+	case "project":
+		target = &todo.Project
+	case "parent_id":
+		target = &todo.ParentID
+	case "tags":
+		target, kind = &todo.Tags, "array of strings"
+	case "completed":
+		target, kind = &todo.Completed, "boolean"
+	case "priority":
+		target, kind = &todo.Priority, "integer"
+	case "due_date":
+		target = &todo.DueDate
+	}
+
+	if err := json.Unmarshal(raw, target); err != nil {
+		return fmt.Sprintf("%s must be a JSON %s", field, kind)
+	}
+	return ""
+}
+
+// parseBool reads the spellings of booleans found in exported spreadsheets;
+// an empty cell is false
+func parseBool(value string) (bool, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "false", "f", "no", "n", "0":
+		return false, true
+	case "true", "t", "yes", "y", "1", "x":
+		return true, true
+	}
+	return false, false
+}
+
+func isImportField(name string) bool {
+	for _, field := range models.ImportFields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeColumn lowercases a column name and trims the space around it
+func normalizeColumn(column string) string {
+	return strings.ToLower(strings.TrimSpace(column))
+}
+
+// csvError reports malformed CSV, with the line it was found on
+func csvError(err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return fileError(fmt.Sprintf("malformed CSV on line %d: %v", parseErr.Line, parseErr.Err))
+	}
+	return err
+}
+
+// fileError reports a problem with the import file as a whole
+func fileError(message string) error {
+	return models.NewValidationError("file", message)
+}
@@ -0,0 +1,197 @@
+package transfer
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+// Lines of Markdown checklists
+var (
+	markdownItem    = regexp.MustCompile(`^(\s*)[-*+] \[([ xX])\](?:\s+(.*))?$`)
+	markdownHeading = regexp.MustCompile(`^#{1,6}(?:\s+(.*))?$`)
+	markdownBreak   = regexp.MustCompile(`^(?:-{3,}|\*{3,}|_{3,})$`)
+	markdownQuote   = regexp.MustCompile(`^\s*> ?(.*)$`)
+	// markdownComment matches the HTML comment ending an item, which holds
+	// the fields not meant to be read
+	markdownComment = regexp.MustCompile(`\s*<!--((?:[^-]|-[^-])*)-->\s*$`)
+)
+
+// markdownIndent nests subtasks and descriptions under their todo
+const markdownIndent = "  "
+
+// markdown is the dialect of Markdown checklist items: #tags after an
+// optional priority, as in todo.txt
+var markdown = textDialect{
+	tag:     '#',
+	leading: textPriority.MatchString,
+}
+
+// markdownWriter writes a checklist item per todo, under a heading naming
+// its project; a thematic break ends the project for todos without one.
+// Subtasks written right after their parent are nested under it, and
+// descriptions are quoted below their item. The ID, and the parent of
+// subtasks that are not nested, are kept in an HTML comment, which is not
+// shown when the file is rendered. A heading is written whenever the
+// project changes, so exporting a project alone gives it a single heading.
+type markdownWriter struct {
+	w io.Writer
+	// project is that of the current section
+	project string
+	// topID is the ID of the last todo written that is not a subtask
+	topID   string
+	written bool
+}
+
+func (m *markdownWriter) Write(todo *models.Todo) error {
+	var b strings.Builder
+	if todo.Project != m.project {
+		if m.written {
+			b.WriteString("\n")
+		}
+		if todo.Project == "" {
+			b.WriteString("---\n\n")
+		} else {
+			b.WriteString("## " + escapeText(todo.Project, true) + "\n\n")
+		}
+		m.project = todo.Project
+		m.topID = ""
+	}
+	m.written = true
+
+	indent := ""
+	nested := todo.ParentID != "" && todo.ParentID == m.topID
+	if nested {
+		indent = markdownIndent
+	} else if todo.ParentID == "" {
+		m.topID = todo.ID
+	}
+
+	words := []string{indent + "-", checkbox(todo.Completed)}
+	if priority := priorityWord(todo.Priority); priority != "" {
+		words = append(words, priority)
+	}
+	words = append(words, markdown.title(todo.Title))
+	for _, tag := range todo.Tags {
+		words = append(words, "#"+escapeText(tag, false))
+	}
+	if todo.DueDateStr != "" {
+		words = append(words, keyDue+":"+todo.DueDateStr)
+	}
+	comment := keyID + ":" + escapeText(todo.ID, false)
+	if todo.ParentID != "" && !nested {
+		comment += " " + keyParent + ":" + escapeText(todo.ParentID, false)
+	}
+	words = append(words, "<!-- "+comment+" -->")
+	b.WriteString(strings.Join(words, " ") + "\n")
+
+	if todo.Description != "" {
+		for _, line := range strings.Split(todo.Description, "\n") {
+			if line == "" {
+				b.WriteString(indent + markdownIndent + ">\n")
+			} else {
+				b.WriteString(indent + markdownIndent + "> " + line + "\n")
+			}
+		}
+	}
+
+	_, err := io.WriteString(m.w, b.String())
+	return err
+}
+
+func (m *markdownWriter) Close() error {
+	return nil
+}
+
+func checkbox(completed bool) string {
+	if completed {
+		return "[x]"
+	}
+	return "[ ]"
+}
+
+// readMarkdown reads the checklist items of a Markdown file as todos. A
+// heading names the project of the items after it, up to the next heading
+// or thematic break. Indented items are subtasks of the item above that is
+// not, and quoted lines below an item are its description. Other lines are
+// skipped, so notes can be kept around the checklist.
+func (f *fileReader) readMarkdown(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineBytes)
+	var project string
+	last, top := -1, -1
+	quoting := false
+	for first := true; scanner.Scan(); first = false {
+		line := scanner.Text()
+		if first {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+
+		if match := markdownItem.FindStringSubmatch(line); match != nil {
+			row := markdownRow(match[3], project, match[2] != " ")
+			nested := match[1] != ""
+			if nested && top >= 0 && row.Todo.ParentID == "" {
+				parent := &f.rows[top].Todo
+				if parent.ID == "" {
+					parent.ID = uuid.New().String()
+				}
+				row.Todo.ParentID = parent.ID
+			}
+			if err := f.addRow(row); err != nil {
+				return err
+			}
+			last = len(f.rows) - 1
+			if !nested {
+				top = last
+			}
+			quoting = false
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if match := markdownQuote.FindStringSubmatch(line); match != nil && last >= 0 {
+			todo := &f.rows[last].Todo
+			if quoting {
+				todo.Description += "\n" + match[1]
+			} else {
+				todo.Description = match[1]
+				quoting = true
+			}
+			continue
+		}
+		quoting = false
+		if match := markdownHeading.FindStringSubmatch(trimmed); match != nil {
+			project = unescapeText(strings.TrimSpace(match[1]))
+			top = -1
+		} else if markdownBreak.MatchString(strings.ReplaceAll(trimmed, " ", "")) {
+			project = ""
+			top = -1
+		}
+	}
+	return textError(scanner.Err())
+}
+
+// markdownRow reads a checklist item, from the text after its checkbox
+func markdownRow(text, project string, completed bool) models.ImportRow {
+	var row models.ImportRow
+	row.Todo.Project = project
+	row.Todo.Completed = completed
+
+	var comment []string
+	if match := markdownComment.FindStringSubmatchIndex(text); match != nil {
+		comment = strings.Fields(text[match[2]:match[3]])
+		text = text[:match[0]]
+	}
+	words := strings.Fields(text)
+	if len(words) > 0 && readPriorityWord(&row.Todo, words[0]) {
+		words = words[1:]
+	}
+	row.Todo.Title = markdown.readWords(words, &row)
+	// Words of the comment that mark no field are remarks, not the title
+	markdown.readWords(comment, &row)
+	return row
+}
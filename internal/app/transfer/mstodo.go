@@ -0,0 +1,142 @@
+package transfer
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+// Values of Microsoft To Do task fields
+const (
+	msTodoStatusCompleted = "completed"
+	msTodoBodyHTML        = "html"
+)
+
+// msTodoPriorities maps the importance of Microsoft To Do tasks to the
+// priority of todos; normal is the default, so it maps to none
+var msTodoPriorities = map[string]int{"low": 1, "normal": 0, "high": 5}
+
+// htmlTag matches the tags of HTML task bodies
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// msTodoExport holds Microsoft To Do lists: the value of a Graph
+// collection, lists as written by export scripts, or a single list
+type msTodoExport struct {
+	Value []msTodoList `json:"value"`
+	Lists []msTodoList `json:"lists"`
+	msTodoList
+}
+
+// msTodoList is a Microsoft To Do list with its tasks, as returned by
+// Microsoft Graph when they are expanded
+type msTodoList struct {
+	DisplayName string            `json:"displayName"`
+	Tasks       []json.RawMessage `json:"tasks"`
+}
+
+// msTodoTask is a Microsoft To Do task
+type msTodoTask struct {
+	ID             string                `json:"id"`
+	Title          string                `json:"title"`
+	Body           *msTodoBody           `json:"body"`
+	Status         string                `json:"status"`
+	Importance     string                `json:"importance"`
+	DueDateTime    *msTodoDateTime       `json:"dueDateTime"`
+	Categories     []string              `json:"categories"`
+	ChecklistItems []msTodoChecklistItem `json:"checklistItems"`
+}
+
+// msTodoBody is the note of a task
+type msTodoBody struct {
+	Content     string `json:"content"`
+	ContentType string `json:"contentType"`
+}
+
+// msTodoDateTime is a local time in a time zone
+type msTodoDateTime struct {
+	DateTime string `json:"dateTime"`
+	TimeZone string `json:"timeZone"`
+}
+
+// msTodoChecklistItem is a step of a task
+type msTodoChecklistItem struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+	IsChecked   bool   `json:"isChecked"`
+}
+
+// readMSTodo reads Microsoft To Do lists. Every task becomes a todo in a
+// project named after its list, tagged with its categories, and its steps
+// become its subtasks. Due times must be in an IANA time zone, which Graph
+// uses unless asked for another.
+func (f *fileReader) readMSTodo(r io.Reader) error {
+	var export msTodoExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return fileError(fmt.Sprintf("malformed Microsoft To Do JSON: %v", err))
+	}
+	lists := append(export.Value, export.Lists...)
+	if export.Tasks != nil {
+		lists = append(lists, export.msTodoList)
+	}
+
+	for _, list := range lists {
+		for _, record := range list.Tasks {
+			var task msTodoTask
+			err := f.readAppRecord(record, &task, "")
+
+			todo := models.TodoReplace{
+				ID:        task.ID,
+				Title:     task.Title,
+				Project:   list.DisplayName,
+				Tags:      task.Categories,
+				Completed: task.Status == msTodoStatusCompleted,
+			}
+			if task.Body != nil {
+				todo.Description = msTodoText(task.Body)
+			}
+			row := appRow(todo, err)
+			if priority, ok := msTodoPriorities[strings.ToLower(task.Importance)]; ok {
+				row.Todo.Priority = priority
+			} else if task.Importance != "" {
+				row.Errors = append(row.Errors, models.FieldError{Field: "priority", Message: "importance must be low, normal or high"})
+			}
+			if task.DueDateTime != nil {
+				setAppDue(&row, task.DueDateTime.DateTime, task.DueDateTime.TimeZone)
+			}
+			if err := f.addRow(row); err != nil {
+				return err
+			}
+
+			if task.ID == "" {
+				continue
+			}
+			for _, item := range task.ChecklistItems {
+				row := models.ImportRow{Todo: models.TodoReplace{
+					ID:        task.ID + "/" + item.ID,
+					Title:     item.DisplayName,
+					Project:   list.DisplayName,
+					ParentID:  task.ID,
+					Completed: item.IsChecked,
+				}}
+				if err := f.addRow(row); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// msTodoText returns the text of a task body, stripping HTML
+func msTodoText(body *msTodoBody) string {
+	content := body.Content
+	if strings.EqualFold(body.ContentType, msTodoBodyHTML) {
+		content = html.UnescapeString(htmlTag.ReplaceAllString(content, ""))
+	}
+	return strings.TrimSpace(content)
+}
@@ -0,0 +1,219 @@
+package transfer
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+// Keys of the key:value pairs that plain text formats write for the fields
+// of todos they have no other notation for
+const (
+	keyID          = "id"
+	keyParent      = "parent"
+	keyDue         = "due"
+	keyPriority    = "pri"
+	keyDescription = "description"
+)
+
+// textKeys lists the keys read from plain text files. Other key:value
+// pairs, which todo.txt apps add for their own features, are kept in the
+// title so nothing is lost.
+var textKeys = []string{keyID, keyParent, keyDue, keyPriority, keyDescription}
+
+// priorityLetters holds the todo.txt letters of priorities 1 to 5, so that
+// 5 is (A), the most urgent
+const priorityLetters = "EDCBA"
+
+// textPriority matches a priority written as in todo.txt, such as (A)
+var textPriority = regexp.MustCompile(`^\(([A-Z])\)$`)
+
+// textDate matches the creation and completion dates of todo.txt lines
+var textDate = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+
+// isTextFormat reports whether format is a plain text format, whose
+// notation for each field is fixed, so it cannot be mapped
+func isTextFormat(format string) bool {
+	return format == FormatTodoTxt || format == FormatMarkdown
+}
+
+// textDialect describes how a plain text format marks the fields of a todo
+// among the words of a line
+type textDialect struct {
+	// tag and project are the characters that start tags and projects, or
+	// 0 if the dialect writes projects some other way
+	tag, project byte
+	// leading reports whether a word would be read as a field, rather than
+	// the title, if it came first
+	leading func(word string) bool
+}
+
+// field reports whether word would be read as a field
+func (d textDialect) field(word string) bool {
+	if len(word) > 1 && (word[0] == d.tag || d.project != 0 && word[0] == d.project) {
+		return true
+	}
+	key, value, ok := strings.Cut(word, ":")
+	return ok && value != "" && isTextKey(key)
+}
+
+// title returns the words of title, escaped so they are read back as the
+// title rather than fields
+func (d textDialect) title(title string) string {
+	words := strings.Split(escapeText(title, true), " ")
+	for i, word := range words {
+		if d.field(word) || i == 0 && d.leading(word) {
+			words[i] = escapeFirst(word)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// readWords sets the fields of row from the words of a line that mark them,
+// returning the title the others make up
+func (d textDialect) readWords(words []string, row *models.ImportRow) string {
+	todo := &row.Todo
+	var title []string
+	for _, word := range words {
+		if !d.field(word) {
+			title = append(title, unescapeText(word))
+			continue
+		}
+		switch word[0] {
+		case d.tag:
+			todo.Tags = append(todo.Tags, unescapeText(word[1:]))
+			continue
+		case d.project:
+			// Todos have one project; todo.txt lines may have several, and
+			// the others are kept as tags
+			if todo.Project == "" {
+				todo.Project = unescapeText(word[1:])
+			} else {
+				todo.Tags = append(todo.Tags, unescapeText(word[1:]))
+			}
+			continue
+		}
+
+		key, value, _ := strings.Cut(word, ":")
+		value = unescapeText(value)
+		switch key {
+		case keyID:
+			todo.ID = value
+		case keyParent:
+			todo.ParentID = value
+		case keyDue:
+			todo.DueDate = value
+		case keyPriority:
+			if len(value) != 1 || !setTextPriority(todo, value[0]) {
+				row.Errors = append(row.Errors, models.FieldError{Field: "priority", Message: "pri must be a letter from A to Z"})
+			}
+		case keyDescription:
+			todo.Description = value
+		}
+	}
+	return strings.Join(title, " ")
+}
+
+func isTextKey(key string) bool {
+	for _, k := range textKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// priorityWord returns the todo.txt notation of priority, or "" for none
+func priorityWord(priority int) string {
+	if priority < 1 || priority > len(priorityLetters) {
+		return ""
+	}
+	return "(" + priorityLetters[priority-1:priority] + ")"
+}
+
+// readPriorityWord sets the priority of todo from a word such as (A),
+// reporting whether it was one
+func readPriorityWord(todo *models.TodoReplace, word string) bool {
+	match := textPriority.FindStringSubmatch(word)
+	return match != nil && setTextPriority(todo, match[1][0])
+}
+
+// setTextPriority sets the priority of todo from a todo.txt letter. Letters
+// after E are less urgent than any priority, so they become the lowest.
+func setTextPriority(todo *models.TodoReplace, letter byte) bool {
+	if letter < 'A' || letter > 'Z' {
+		return false
+	}
+	todo.Priority = 1
+	if i := strings.IndexByte(priorityLetters, letter); i >= 0 {
+		todo.Priority = i + 1
+	}
+	return true
+}
+
+// escapeText percent-encodes what cannot be written in a word of a plain
+// text line: white space and control characters, and percent signs that
+// would be read as an escape. With spaces, single spaces between other
+// characters are kept, so a title stays readable.
+func escapeText(s string, spaces bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == ' ' && spaces && i > 0 && i < len(s)-1 && s[i-1] != ' ' && s[i+1] != ' ':
+			b.WriteRune(r)
+		case r == '%' && isEscape(s[i:]), unicode.IsSpace(r), unicode.IsControl(r):
+			percentEncode(&b, string(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// escapeFirst percent-encodes the first character of word
+func escapeFirst(word string) string {
+	_, size := utf8.DecodeRuneInString(word)
+	var b strings.Builder
+	percentEncode(&b, word[:size])
+	b.WriteString(word[size:])
+	return b.String()
+}
+
+func percentEncode(b *strings.Builder, s string) {
+	for i := 0; i < len(s); i++ {
+		fmt.Fprintf(b, "%%%02X", s[i])
+	}
+}
+
+// unescapeText decodes the percent escapes of s. A percent sign that starts
+// no escape is taken literally, as people write them in titles.
+func unescapeText(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if isEscape(s[i:]) {
+			c, _ := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			b.WriteByte(byte(c))
+			i += 2
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// isEscape reports whether s starts with a percent escape
+func isEscape(s string) bool {
+	return len(s) >= 3 && s[0] == '%' && isHex(s[1]) && isHex(s[2])
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
@@ -0,0 +1,192 @@
+package transfer_test
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/repositories"
+	"github.com/teguh/go-todo-api/internal/app/services"
+	"github.com/teguh/go-todo-api/internal/app/transfer"
+)
+
+// exportTodos writes every todo of service in format
+func exportTodos(t *testing.T, service *services.TodoService, format string) string {
+	t.Helper()
+
+	todos, err := service.ExportTodos(context.Background(), models.TodoQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w, err := transfer.NewWriter(&buf, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for todo, err := range todos {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Write(todo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+// importTodos reads a file in format into an empty store with mode,
+// failing the test unless every row is written
+func importTodos(t *testing.T, file, format, mode string) []*models.Todo {
+	t.Helper()
+
+	rows, _, err := transfer.Read(strings.NewReader(file), format, nil, 0)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	store := repositories.NewMemoryTodoRepository(repositories.NewMemoryOutboxRepository())
+	result, err := services.NewTodoService(store, nil).ImportTodos(context.Background(), rows, models.ImportOptions{Mode: mode})
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if !result.Committed || len(result.Errors) > 0 {
+		t.Fatalf("import not committed: %+v in\n%s", result.Errors, file)
+	}
+	todos, err := store.Find(models.TodoQuery{Sort: models.SortTitle})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return todos
+}
+
+// roundTripTodos creates todos to export, one for each feature of the
+// text formats, with a subtask under the first
+func roundTripTodos(t *testing.T) (*services.TodoService, []*models.Todo) {
+	t.Helper()
+
+	ctx := context.Background()
+	service := services.NewTodoService(repositories.NewMemoryTodoRepository(repositories.NewMemoryOutboxRepository()), nil)
+	creates := []models.TodoCreate{
+		{Title: "Plan the move", Project: "Home office", Priority: 5, DueDate: "2030-05-01"},
+		{Title: "Call the bank", Priority: 4, DueDate: "2030-05-01T09:30:00Z", Description: "Ask about fees.\n\n  Indented: keep it\n100% sure"},
+		{Title: "+1 the @review due:later (B)", Project: "R&D #1", Priority: 3, Tags: []string{"to read", "c++", "50%"}},
+		{Title: "x 2024-01-01 looks like a date", Priority: 2},
+		{Title: "Overdue for a week", Priority: 1, DueDate: time.Now().AddDate(0, 0, -7).UTC().Format(time.RFC3339)},
+		{Title: "No priority, no project"},
+	}
+	var todos []*models.Todo
+	for _, create := range creates {
+		todo, err := service.CreateTodo(ctx, create)
+		if err != nil {
+			t.Fatalf("create %q: %v", create.Title, err)
+		}
+		todos = append(todos, todo)
+	}
+
+	// Replacing, unlike creating, accepts due dates more than a year past
+	if _, _, err := service.ReplaceTodo(ctx, uuid.New().String(), models.TodoReplace{Title: "Overdue since 2020", DueDate: "2020-01-15"}); err != nil {
+		t.Fatal(err)
+	}
+
+	subtask, err := service.CreateTodo(ctx, models.TodoCreate{Title: "Pack the books", Project: "Home office", ParentID: todos[0].ID, Tags: []string{"boxes"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := true
+	if _, err := service.UpdateTodo(ctx, subtask.ID, models.TodoUpdate{Completed: &done}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.UpdateTodo(ctx, todos[2].ID, models.TodoUpdate{Completed: &done}); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := service.FindTodos(ctx, models.TodoQuery{Sort: models.SortTitle})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service, stored
+}
+
+func TestTextFormatsRoundTrip(t *testing.T) {
+	for _, format := range []string{transfer.FormatTodoTxt, transfer.FormatMarkdown} {
+		for _, mode := range []string{models.ImportUpsert, models.ImportCreate} {
+			t.Run(format+"/"+mode, func(t *testing.T) {
+				service, want := roundTripTodos(t)
+				file := exportTodos(t, service, format)
+				got := importTodos(t, file, format, mode)
+				if len(got) != len(want) {
+					t.Fatalf("imported %d todos, want %d from\n%s", len(got), len(want), file)
+				}
+
+				// IDs are kept by upserts; created todos get new ones, with
+				// subtasks under the new ID of their parent
+				ids := make(map[string]string)
+				for i := range want {
+					ids[want[i].ID] = got[i].ID
+				}
+				for i, w := range want {
+					g := got[i]
+					if mode == models.ImportUpsert && g.ID != w.ID {
+						t.Errorf("%q has ID %s, want %s", w.Title, g.ID, w.ID)
+					}
+					if g.Title != w.Title || g.Description != w.Description || g.Project != w.Project ||
+						g.Completed != w.Completed || g.Priority != w.Priority || !slices.Equal(g.Tags, w.Tags) ||
+						g.DueDateStr != w.DueDateStr || g.AllDay != w.AllDay || g.ParentID != ids[w.ParentID] {
+						t.Errorf("round trip of\n%+v\ngave\n%+v\nthrough\n%s", w, g, file)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestTodoTxtPriorities(t *testing.T) {
+	tests := []struct {
+		priority  int
+		completed bool
+		line      string
+	}{
+		{0, false, "Task id:"},
+		{1, false, "(E) Task id:"},
+		{2, false, "(D) Task id:"},
+		{3, false, "(C) Task id:"},
+		{4, false, "(B) Task id:"},
+		{5, false, "(A) Task id:"},
+		{5, true, "x 2030-01-02 Task pri:A id:"},
+		{0, true, "x 2030-01-02 Task id:"},
+	}
+	for _, tt := range tests {
+		// Completed todos give the day they were last updated as their completion date
+		todo := &models.Todo{ID: "t", Title: "Task", Priority: tt.priority, Completed: tt.completed, UpdatedAt: time.Date(2030, 1, 2, 15, 0, 0, 0, time.UTC)}
+
+		var buf bytes.Buffer
+		w, _ := transfer.NewWriter(&buf, transfer.FormatTodoTxt)
+		if err := w.Write(todo); err != nil {
+			t.Fatal(err)
+		}
+		if line := buf.String(); line != tt.line+"t\n" {
+			t.Errorf("priority %d, completed %t: wrote %q, want %q", tt.priority, tt.completed, line, tt.line+"t\n")
+		}
+
+		rows, _, err := transfer.Read(&buf, transfer.FormatTodoTxt, nil, 0)
+		if err != nil || len(rows) != 1 {
+			t.Fatalf("read: %v, %v", rows, err)
+		}
+		if read := rows[0].Todo; read.Priority != tt.priority || read.Completed != tt.completed || read.Title != "Task" {
+			t.Errorf("priority %d, completed %t: read %+v", tt.priority, tt.completed, read)
+		}
+	}
+
+	// Letters after E are less urgent than any priority
+	rows, _, err := transfer.Read(strings.NewReader("(Z) Someday\n"), transfer.FormatTodoTxt, nil, 0)
+	if err != nil || rows[0].Todo.Priority != 1 {
+		t.Errorf("(Z) read as %+v, %v", rows, err)
+	}
+}
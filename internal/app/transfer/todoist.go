@@ -0,0 +1,327 @@
+package transfer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+// Types of the records of Todoist CSV files
+const (
+	todoistTypeTask    = "task"
+	todoistTypeSection = "section"
+	todoistTypeNote    = "note"
+)
+
+// todoistColumns are the columns of Todoist CSV files that are read
+var todoistColumns = []string{"TYPE", "CONTENT", "DESCRIPTION", "PRIORITY", "INDENT", "DATE", "TIMEZONE"}
+
+// todoistPriorities maps Todoist's priorities, from p1 (urgent) to p4
+// (none), to those of todos
+var todoistPriorities = [...]int{1: 5, 2: 4, 3: 3, 4: 0}
+
+// todoistLabel matches the @labels Todoist CSV files write in the content
+// of tasks
+var todoistLabel = regexp.MustCompile(`(?:^|\s)@(\S+)`)
+
+// todoistID is the ID of a Todoist object, which older versions of the API
+// wrote as a number
+type todoistID string
+
+func (id *todoistID) UnmarshalJSON(data []byte) error {
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err == nil {
+		*id = todoistID(number)
+		return nil
+	}
+	var s *string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s != nil {
+		*id = todoistID(*s)
+	}
+	return nil
+}
+
+// todoistTask is a task as returned by the Todoist API
+type todoistTask struct {
+	ID          todoistID   `json:"id"`
+	Content     string      `json:"content"`
+	Description string      `json:"description"`
+	ProjectID   todoistID   `json:"project_id"`
+	SectionID   todoistID   `json:"section_id"`
+	ParentID    todoistID   `json:"parent_id"`
+	Labels      []string    `json:"labels"`
+	Priority    int         `json:"priority"`
+	Due         *todoistDue `json:"due"`
+	Checked     bool        `json:"checked"`
+	IsCompleted bool        `json:"is_completed"`
+	IsDeleted   bool        `json:"is_deleted"`
+}
+
+// todoistDue is when a Todoist task is due. Date holds a date, or a time
+// in older versions of the API, which give no Datetime.
+type todoistDue struct {
+	Date     string `json:"date"`
+	Datetime string `json:"datetime"`
+	Timezone string `json:"timezone"`
+}
+
+// todoistNamed is a Todoist project or section
+type todoistNamed struct {
+	ID   todoistID `json:"id"`
+	Name string    `json:"name"`
+}
+
+// todoistNote is a comment on a Todoist task
+type todoistNote struct {
+	ItemID    todoistID `json:"item_id"`
+	Content   string    `json:"content"`
+	IsDeleted bool      `json:"is_deleted"`
+}
+
+// todoistBackup is the full sync response of the Todoist Sync API. The
+// tasks are under items, or tasks or results in other versions of the API.
+type todoistBackup struct {
+	Items    []json.RawMessage `json:"items"`
+	Tasks    []json.RawMessage `json:"tasks"`
+	Results  []json.RawMessage `json:"results"`
+	Projects []todoistNamed    `json:"projects"`
+	Sections []todoistNamed    `json:"sections"`
+	Notes    []todoistNote     `json:"notes"`
+}
+
+// readTodoist reads a Todoist file: a project exported as CSV, or JSON from
+// the API, either a full sync or an array of tasks
+func (f *fileReader) readTodoist(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	switch trimmed := bytes.TrimSpace(data); {
+	case len(trimmed) == 0:
+		return nil
+	case trimmed[0] == '{' || trimmed[0] == '[':
+		return f.readTodoistJSON(trimmed)
+	default:
+		return f.readTodoistCSV(bytes.NewReader(data))
+	}
+}
+
+// readTodoistCSV reads a Todoist project exported as CSV. The project is
+// not named in the file, so todos have none; sections become tags, as do
+// the @labels in the content of tasks, and notes are added to the
+// description of the task before them. Tasks indented under another are
+// its subtasks. Dates are read if they are ISO 8601, in the TIMEZONE of
+// their task; the natural language Todoist also allows cannot be read.
+func (f *fileReader) readTodoistCSV(r io.Reader) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return csvError(err)
+	}
+	index := make(map[string]int, len(todoistColumns))
+	for i, column := range header {
+		name := strings.ToUpper(strings.TrimSpace(column))
+		if isTodoistColumn(name) {
+			index[name] = i
+		} else {
+			f.ignore(column)
+		}
+	}
+	if _, ok := index["CONTENT"]; !ok {
+		return fileError("not a Todoist CSV export: it has no CONTENT column")
+	}
+
+	var section, topID string
+	last := -1
+	for n := 1; ; n++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return csvError(err)
+		}
+		get := func(column string) string {
+			if i, ok := index[column]; ok && i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+
+		switch strings.ToLower(strings.TrimSpace(get("TYPE"))) {
+		case todoistTypeSection:
+			section = strings.TrimSpace(get("CONTENT"))
+			continue
+		case todoistTypeNote:
+			if last >= 0 {
+				todo := &f.rows[last].Todo
+				todo.Description = appendNote(todo.Description, get("CONTENT"))
+			}
+			continue
+		case todoistTypeTask:
+		default:
+			// Blank separator lines and anything newer versions add
+			continue
+		}
+
+		var row models.ImportRow
+		todo := &row.Todo
+		todo.ID = fmt.Sprintf("record-%d", n)
+		todo.Title, todo.Tags = todoistContent(get("CONTENT"))
+		todo.Description = get("DESCRIPTION")
+		if section != "" {
+			todo.Tags = append(todo.Tags, section)
+		}
+
+		if value := strings.TrimSpace(get("PRIORITY")); value != "" {
+			priority, err := strconv.Atoi(value)
+			if err != nil || priority < 1 || priority >= len(todoistPriorities) {
+				row.Errors = append(row.Errors, models.FieldError{Field: "priority", Message: "PRIORITY must be 1 (p1) to 4 (p4)"})
+			} else {
+				todo.Priority = todoistPriorities[priority]
+			}
+		}
+
+		indent := 1
+		if value := strings.TrimSpace(get("INDENT")); value != "" {
+			if indent, err = strconv.Atoi(value); err != nil || indent < 1 {
+				row.Errors = append(row.Errors, models.FieldError{Field: "parent_id", Message: "INDENT must be a positive integer"})
+				indent = 1
+			}
+		}
+		if indent == 1 {
+			topID = todo.ID
+		} else {
+			todo.ParentID = topID
+		}
+
+		setAppDue(&row, get("DATE"), strings.TrimSpace(get("TIMEZONE")))
+
+		if err := f.addRow(row); err != nil {
+			return err
+		}
+		last = len(f.rows) - 1
+	}
+}
+
+// readTodoistJSON reads tasks from the Todoist API. Deleted tasks are
+// skipped, and comments are added to the description of their task.
+func (f *fileReader) readTodoistJSON(data []byte) error {
+	var backup todoistBackup
+	if data[0] == '[' {
+		if err := json.Unmarshal(data, &backup.Tasks); err != nil {
+			return fileError("malformed Todoist JSON: expected an array of tasks")
+		}
+	} else if err := json.Unmarshal(data, &backup); err != nil {
+		return fileError(fmt.Sprintf("malformed Todoist JSON: %v", err))
+	}
+
+	projects := make(map[todoistID]string, len(backup.Projects))
+	for _, project := range backup.Projects {
+		projects[project.ID] = project.Name
+	}
+	sections := make(map[todoistID]string, len(backup.Sections))
+	for _, section := range backup.Sections {
+		sections[section.ID] = section.Name
+	}
+	notes := make(map[todoistID][]string)
+	for _, note := range backup.Notes {
+		if !note.IsDeleted {
+			notes[note.ItemID] = append(notes[note.ItemID], note.Content)
+		}
+	}
+
+	records := append(append(backup.Items, backup.Tasks...), backup.Results...)
+	tasks := make([]todoistTask, len(records))
+	errs := make([]error, len(records))
+	parents := make(map[string]string, len(records))
+	for i, record := range records {
+		errs[i] = f.readAppRecord(record, &tasks[i], "")
+		if tasks[i].ID != "" && !tasks[i].IsDeleted {
+			parents[string(tasks[i].ID)] = string(tasks[i].ParentID)
+		}
+	}
+
+	for i, task := range tasks {
+		if task.IsDeleted {
+			continue
+		}
+		todo := models.TodoReplace{
+			ID:          string(task.ID),
+			Title:       task.Content,
+			Description: task.Description,
+			Project:     projects[task.ProjectID],
+			Tags:        task.Labels,
+			Completed:   task.Checked || task.IsCompleted,
+		}
+		if task.ParentID != "" {
+			todo.ParentID = topLevel(string(task.ParentID), parents)
+		}
+		if section := sections[task.SectionID]; section != "" {
+			todo.Tags = append(todo.Tags, section)
+		}
+		for _, note := range notes[task.ID] {
+			todo.Description = appendNote(todo.Description, note)
+		}
+
+		row := appRow(todo, errs[i])
+		if task.Priority != 0 {
+			// The API numbers priorities the other way round, 4 being p1
+			if level := len(todoistPriorities) - task.Priority; level < 1 || level >= len(todoistPriorities) {
+				row.Errors = append(row.Errors, models.FieldError{Field: "priority", Message: "priority must be 1 to 4"})
+			} else {
+				row.Todo.Priority = todoistPriorities[level]
+			}
+		}
+		if task.Due != nil {
+			due := task.Due.Datetime
+			if due == "" {
+				due = task.Due.Date
+			}
+			setAppDue(&row, due, task.Due.Timezone)
+		}
+
+		if err := f.addRow(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// todoistContent splits the content of a task in a Todoist CSV file into
+// its title and @labels. A leading "* " marks tasks that cannot be
+// completed and is dropped.
+func todoistContent(content string) (string, []string) {
+	var labels []string
+	for _, match := range todoistLabel.FindAllStringSubmatch(content, -1) {
+		labels = append(labels, match[1])
+	}
+	title := todoistLabel.ReplaceAllString(content, "")
+	title = strings.TrimPrefix(strings.TrimSpace(title), "* ")
+	return strings.TrimSpace(title), labels
+}
+
+func isTodoistColumn(name string) bool {
+	for _, column := range todoistColumns {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package transfer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+// maxLineBytes bounds the lines of plain text files, which hold a whole
+// todo with its escaped description
+const maxLineBytes = 1 << 20
+
+// todoTxt is the dialect of todo.txt lines: +project and @context, which
+// todos keep as tags, after an x for completed tasks, dates and a priority
+var todoTxt = textDialect{
+	tag:     '@',
+	project: '+',
+	leading: func(word string) bool {
+		return word == "x" || textDate.MatchString(word) || textPriority.MatchString(word)
+	},
+}
+
+// todoTxtWriter writes a todo.txt line per todo. Completed todos start with
+// x and the day they were last updated, taken as their completion date, and
+// keep their priority as pri:, as todo.txt apps do; the others start with
+// their priority. Both then give the day they were created.
+type todoTxtWriter struct {
+	w io.Writer
+}
+
+func (t *todoTxtWriter) Write(todo *models.Todo) error {
+	var words []string
+	priority := priorityWord(todo.Priority)
+	if todo.Completed {
+		words = append(words, "x", todo.UpdatedAt.Format(time.DateOnly))
+	} else if priority != "" {
+		words = append(words, priority)
+	}
+	if !todo.CreatedAt.IsZero() {
+		words = append(words, todo.CreatedAt.Format(time.DateOnly))
+	}
+
+	words = append(words, todoTxt.title(todo.Title))
+	if todo.Project != "" {
+		words = append(words, "+"+escapeText(todo.Project, false))
+	}
+	for _, tag := range todo.Tags {
+		words = append(words, "@"+escapeText(tag, false))
+	}
+	if todo.DueDateStr != "" {
+		words = append(words, keyDue+":"+todo.DueDateStr)
+	}
+	if todo.Completed && priority != "" {
+		words = append(words, keyPriority+":"+priority[1:2])
+	}
+	if todo.Description != "" {
+		words = append(words, keyDescription+":"+escapeText(todo.Description, false))
+	}
+	words = append(words, keyID+":"+escapeText(todo.ID, false))
+	if todo.ParentID != "" {
+		words = append(words, keyParent+":"+escapeText(todo.ParentID, false))
+	}
+
+	_, err := io.WriteString(t.w, strings.Join(words, " ")+"\n")
+	return err
+}
+
+func (t *todoTxtWriter) Close() error {
+	return nil
+}
+
+// readTodoTxt reads a todo.txt file, a todo per line. Creation and
+// completion dates are skipped, as todos keep their own timestamps.
+func (f *fileReader) readTodoTxt(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineBytes)
+	for first := true; scanner.Scan(); first = false {
+		line := scanner.Text()
+		if first {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		words := strings.Fields(line)
+		if len(words) == 0 {
+			continue
+		}
+
+		var row models.ImportRow
+		todo := &row.Todo
+		if words[0] == "x" {
+			todo.Completed = true
+			words = words[1:]
+		}
+		for dates, priority := 0, false; len(words) > 0; words = words[1:] {
+			if dates < 2 && textDate.MatchString(words[0]) {
+				dates++
+			} else if !priority && readPriorityWord(todo, words[0]) {
+				priority = true
+			} else {
+				break
+			}
+		}
+		todo.Title = todoTxt.readWords(words, &row)
+
+		if err := f.addRow(row); err != nil {
+			return err
+		}
+	}
+	return textError(scanner.Err())
+}
+
+// textError reports a line of a plain text file too long to read
+func textError(err error) error {
+	if errors.Is(err, bufio.ErrTooLong) {
+		return fileError(fmt.Sprintf("a line is longer than %d bytes", maxLineBytes))
+	}
+	return err
+}
@@ -0,0 +1,127 @@
+package transfer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+// Checklist item state of completed items
+const trelloStateComplete = "complete"
+
+// trelloBoard is a Trello board exported as JSON
+type trelloBoard struct {
+	Name       string            `json:"name"`
+	Lists      []trelloList      `json:"lists"`
+	Cards      []json.RawMessage `json:"cards"`
+	Checklists []trelloChecklist `json:"checklists"`
+}
+
+// trelloList is a list of a board
+type trelloList struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Closed bool   `json:"closed"`
+}
+
+// trelloCard is a card on a board. Archived cards are closed.
+type trelloCard struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Desc        string        `json:"desc"`
+	IDList      string        `json:"idList"`
+	Labels      []trelloLabel `json:"labels"`
+	Due         string        `json:"due"`
+	DueComplete bool          `json:"dueComplete"`
+	Closed      bool          `json:"closed"`
+}
+
+// trelloLabel is a label on a card, which may have only a color
+type trelloLabel struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// trelloChecklist is a checklist on a card
+type trelloChecklist struct {
+	IDCard     string            `json:"idCard"`
+	CheckItems []trelloCheckItem `json:"checkItems"`
+}
+
+// trelloCheckItem is an item of a checklist
+type trelloCheckItem struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	State string `json:"state"`
+	Due   string `json:"due"`
+}
+
+// readTrello reads a Trello board exported as JSON. Every card becomes a
+// todo in a project named after the board, tagged with its labels and its
+// list, and the items of its checklists become its subtasks. Cards count
+// as completed once their due date is marked complete or they or their
+// list are archived.
+func (f *fileReader) readTrello(r io.Reader) error {
+	var board trelloBoard
+	if err := json.NewDecoder(r).Decode(&board); err != nil {
+		return fileError(fmt.Sprintf("malformed Trello board: %v", err))
+	}
+
+	lists := make(map[string]trelloList, len(board.Lists))
+	for _, list := range board.Lists {
+		lists[list.ID] = list
+	}
+	items := make(map[string][]trelloCheckItem)
+	for _, checklist := range board.Checklists {
+		items[checklist.IDCard] = append(items[checklist.IDCard], checklist.CheckItems...)
+	}
+
+	for _, record := range board.Cards {
+		var card trelloCard
+		err := f.readAppRecord(record, &card, "", "idLabels", "idChecklists", "idBoard")
+		list := lists[card.IDList]
+
+		todo := models.TodoReplace{
+			ID:          card.ID,
+			Title:       card.Name,
+			Description: card.Desc,
+			Project:     board.Name,
+			Completed:   card.DueComplete || card.Closed || list.Closed,
+		}
+		for _, label := range card.Labels {
+			if label.Name != "" {
+				todo.Tags = append(todo.Tags, label.Name)
+			} else if label.Color != "" {
+				todo.Tags = append(todo.Tags, label.Color)
+			}
+		}
+		if list.Name != "" {
+			todo.Tags = append(todo.Tags, list.Name)
+		}
+		row := appRow(todo, err)
+		setAppDue(&row, card.Due, "")
+		if err := f.addRow(row); err != nil {
+			return err
+		}
+
+		if card.ID == "" {
+			continue
+		}
+		for _, item := range items[card.ID] {
+			row := models.ImportRow{Todo: models.TodoReplace{
+				ID:        card.ID + "/" + item.ID,
+				Title:     item.Name,
+				Project:   board.Name,
+				ParentID:  card.ID,
+				Completed: item.State == trelloStateComplete,
+			}}
+			setAppDue(&row, item.Due, "")
+			if err := f.addRow(row); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,327 @@
+package app_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/teguh/go-todo-api/config"
+	"github.com/teguh/go-todo-api/internal/app"
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/database"
+)
+
+// newTransferServer starts the API on the memory store, rejecting imports
+// of more than maxRows rows
+func newTransferServer(t *testing.T, maxRows int) *fiber.App {
+	t.Helper()
+
+	db, err := database.Initialize("memory://")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := app.NewWithStores(&config.Config{AppName: "Todo API", DatabaseURL: "memory://", ImportMaxRows: maxRows}, app.NewStores(db))
+	t.Cleanup(func() { server.HTTP.Shutdown() })
+	return server.HTTP
+}
+
+// transfer makes a request with a body of contentType, returning the status
+// and the response
+func transfer(t *testing.T, a *fiber.App, method, path, contentType, body string) (int, http.Header, []byte) {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := a.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, resp.Header, data
+}
+
+// importFile imports body through path, decoding the result
+func importFile(t *testing.T, a *fiber.App, path, contentType, body string) (int, *models.ImportResult) {
+	t.Helper()
+
+	status, _, data := transfer(t, a, http.MethodPost, path, contentType, body)
+	var result models.ImportResult
+	if status == http.StatusOK || status == http.StatusUnprocessableEntity {
+		if err := json.Unmarshal(data, &result); err != nil {
+			t.Fatalf("import result %s: %v", data, err)
+		}
+	}
+	return status, &result
+}
+
+// listTodos returns every todo, by title
+func listTodos(t *testing.T, a *fiber.App) []models.Todo {
+	t.Helper()
+	var todos []models.Todo
+	if status := send(t, a, http.MethodGet, "/api/v1/todos?sort=title", "", &todos); status != http.StatusOK {
+		t.Fatalf("list = %d", status)
+	}
+	return todos
+}
+
+// seedTodos creates a parent with tags, project and due date, a subtask
+// and a plain todo
+func seedTodos(t *testing.T, a *fiber.App) []models.Todo {
+	t.Helper()
+
+	var parent models.Todo
+	if status := send(t, a, http.MethodPost, "/api/v1/todos", `{"title": "Move house", "description": "Line one\nLine \"two\", with a comma", "project": "Home", "tags": ["big", "boxes"], "priority": 5, "due_date": "2030-05-01"}`, &parent); status != http.StatusCreated {
+		t.Fatalf("create = %d", status)
+	}
+	body := fmt.Sprintf(`{"title": "Pack books", "parent_id": %q, "due_date": "2030-04-20T09:30:00Z"}`, parent.ID)
+	if status := send(t, a, http.MethodPost, "/api/v1/todos", body, nil); status != http.StatusCreated {
+		t.Fatalf("create subtask = %d", status)
+	}
+	if status := send(t, a, http.MethodPost, "/api/v1/todos", `{"title": "Call mum", "priority": 2}`, nil); status != http.StatusCreated {
+		t.Fatalf("create = %d", status)
+	}
+	return listTodos(t, a)
+}
+
+func TestExportFormats(t *testing.T) {
+	a := newTransferServer(t, 0)
+	want := seedTodos(t, a)
+
+	read := map[string]func(t *testing.T, data []byte) []models.Todo{
+		"ndjson": func(t *testing.T, data []byte) []models.Todo {
+			var todos []models.Todo
+			lines := bufio.NewScanner(bytes.NewReader(data))
+			for lines.Scan() {
+				var todo models.Todo
+				if err := json.Unmarshal(lines.Bytes(), &todo); err != nil {
+					t.Fatalf("line %q: %v", lines.Text(), err)
+				}
+				todos = append(todos, todo)
+			}
+			return todos
+		},
+		"json": func(t *testing.T, data []byte) []models.Todo {
+			var todos []models.Todo
+			if err := json.Unmarshal(data, &todos); err != nil {
+				t.Fatal(err)
+			}
+			return todos
+		},
+		"csv": func(t *testing.T, data []byte) []models.Todo {
+			records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			header := []string{"id", "title", "description", "project", "parent_id", "tags", "completed", "priority", "due_date", "created_at", "updated_at"}
+			if !slices.Equal(records[0], header) {
+				t.Fatalf("header = %v", records[0])
+			}
+			var todos []models.Todo
+			for _, record := range records[1:] {
+				todo := models.Todo{ID: record[0], Title: record[1], Description: record[2], Project: record[3], ParentID: record[4], DueDateStr: record[8]}
+				if record[5] != "" {
+					todo.Tags = strings.Split(record[5], ",")
+				}
+				todo.Completed = record[6] == "true"
+				fmt.Sscan(record[7], &todo.Priority)
+				todos = append(todos, todo)
+			}
+			return todos
+		},
+	}
+	contentTypes := map[string]string{"csv": "text/csv", "ndjson": "application/x-ndjson", "json": "application/json"}
+
+	for format, readFile := range read {
+		t.Run(format, func(t *testing.T) {
+			status, header, data := transfer(t, a, http.MethodGet, "/api/v1/todos/export?sort=title&format="+format, "", "")
+			if status != http.StatusOK || !strings.HasPrefix(header.Get("Content-Type"), contentTypes[format]) {
+				t.Fatalf("export = %d, %s", status, header.Get("Content-Type"))
+			}
+			if disposition := header.Get("Content-Disposition"); disposition != `attachment; filename="todos.`+format+`"` {
+				t.Errorf("Content-Disposition = %s", disposition)
+			}
+
+			got := readFile(t, data)
+			if len(got) != len(want) {
+				t.Fatalf("exported %d todos, want %d in\n%s", len(got), len(want), data)
+			}
+			for i, w := range want {
+				g := got[i]
+				if g.ID != w.ID || g.Title != w.Title || g.Description != w.Description || g.Project != w.Project ||
+					g.ParentID != w.ParentID || !slices.Equal(g.Tags, w.Tags) || g.Priority != w.Priority || g.DueDateStr != w.DueDateStr {
+					t.Errorf("exported\n%+v\nwant\n%+v", g, w)
+				}
+			}
+		})
+	}
+
+	// Filters apply as in the list endpoint
+	status, _, data := transfer(t, a, http.MethodGet, "/api/v1/todos/export?format=ndjson&project=Home", "", "")
+	if got := read["ndjson"](t, data); status != http.StatusOK || len(got) != 1 || got[0].Title != "Move house" {
+		t.Errorf("export of a project = %d, %+v", status, got)
+	}
+	if status, _, _ := transfer(t, a, http.MethodGet, "/api/v1/todos/export?format=xlsx", "", ""); status != http.StatusBadRequest {
+		t.Errorf("export as xlsx = %d, want 400", status)
+	}
+}
+
+func TestImportMapsColumns(t *testing.T) {
+	a := newTransferServer(t, 0)
+	file := "\ufeffTask Name,Notes,Labels,priority,Colour\n" +
+		"Water plants,\"Every other day,\nmore in summer\",\"home,green\",3,blue\n"
+	status, result := importFile(t, a, "/api/v1/todos/import?map=Task%20Name:title&map=notes:description&map=Labels:tags", "text/csv", file)
+	if status != http.StatusOK || !result.Committed || result.Created != 1 || !slices.Equal(result.IgnoredColumns, []string{"Colour"}) {
+		t.Fatalf("import = %d, %+v", status, result)
+	}
+	todos := listTodos(t, a)
+	if len(todos) != 1 || todos[0].Title != "Water plants" || todos[0].Description != "Every other day,\nmore in summer" ||
+		!slices.Equal(todos[0].Tags, []string{"green", "home"}) || todos[0].Priority != 3 {
+		t.Errorf("imported %+v", todos)
+	}
+
+	// Two columns cannot fill one field, and fields must exist
+	if status, _ := importFile(t, a, "/api/v1/todos/import?map=Task:title", "text/csv", "Task,title\na,b\n"); status != http.StatusBadRequest {
+		t.Errorf("two columns mapped to title = %d, want 400", status)
+	}
+	if status, _ := importFile(t, a, "/api/v1/todos/import?map=Task:colour", "text/csv", "Task\na\n"); status != http.StatusBadRequest {
+		t.Errorf("mapping to an unknown field = %d, want 400", status)
+	}
+}
+
+func TestImportIsAllOrNothing(t *testing.T) {
+	a := newTransferServer(t, 0)
+	file := `{"title": "Fine"}
+{"title": "", "priority": 9}
+{"title": "Also fine", "due_date": "someday"}
+{"title": "Orphan", "parent_id": "missing"}
+`
+
+	// A dry run reports the problems of every row and writes nothing
+	status, result := importFile(t, a, "/api/v1/todos/import?dry_run=true", "application/x-ndjson", file)
+	if status != http.StatusOK || result.Committed || !result.DryRun || result.Rows != 4 {
+		t.Fatalf("dry run = %d, %+v", status, result)
+	}
+	var rows []int
+	for _, rowErr := range result.Errors {
+		rows = append(rows, rowErr.Row)
+	}
+	slices.Sort(rows)
+	if !slices.Equal(slices.Compact(rows), []int{2, 3, 4}) {
+		t.Errorf("errors on rows %v in %+v, want 2, 3 and 4", rows, result.Errors)
+	}
+	fields := make(map[string]bool)
+	for _, rowErr := range result.Errors {
+		if rowErr.Row == 2 {
+			fields[rowErr.Field] = true
+		}
+	}
+	if !fields["title"] || !fields["priority"] {
+		t.Errorf("row 2 errors %+v, want title and priority", result.Errors)
+	}
+
+	// Without a dry run one bad row stops the rest
+	status, result = importFile(t, a, "/api/v1/todos/import", "application/x-ndjson", file)
+	if status != http.StatusUnprocessableEntity || result.Committed || len(result.Errors) == 0 {
+		t.Errorf("import = %d, %+v", status, result)
+	}
+	if todos := listTodos(t, a); len(todos) != 0 {
+		t.Errorf("wrote %+v", todos)
+	}
+
+	// A clean dry run counts what would be written, and still writes nothing
+	status, result = importFile(t, a, "/api/v1/todos/import?dry_run=true", "application/x-ndjson", `{"title": "Fine"}`)
+	if status != http.StatusOK || result.Committed || result.Created != 1 || len(result.Errors) != 0 {
+		t.Errorf("clean dry run = %d, %+v", status, result)
+	}
+	if todos := listTodos(t, a); len(todos) != 0 {
+		t.Errorf("dry run wrote %+v", todos)
+	}
+}
+
+func TestImportUpsertAndCreate(t *testing.T) {
+	a := newTransferServer(t, 0)
+	seeded := seedTodos(t, a)
+	_, _, exported := transfer(t, a, http.MethodGet, "/api/v1/todos/export?format=json", "", "")
+
+	// Upserting an edited export replaces the todos it names and creates
+	// the one it adds, keeping its ID
+	var file []map[string]interface{}
+	if err := json.Unmarshal(exported, &file); err != nil {
+		t.Fatal(err)
+	}
+	file[0]["title"] = "Edited"
+	file = append(file, map[string]interface{}{"id": "8f0c3a1e-6b1d-4d8e-9a57-0c2f1b9d7e11", "title": "Brand new"})
+	edited, _ := json.Marshal(file)
+	status, result := importFile(t, a, "/api/v1/todos/import?mode=upsert", "application/json", string(edited))
+	if status != http.StatusOK || !result.Committed || result.Updated != 3 || result.Created != 1 {
+		t.Fatalf("upsert = %d, %+v", status, result)
+	}
+	todos := listTodos(t, a)
+	if len(todos) != 4 {
+		t.Fatalf("after upsert: %+v", todos)
+	}
+	byID := make(map[string]models.Todo)
+	for _, todo := range todos {
+		byID[todo.ID] = todo
+	}
+	if byID[file[0]["id"].(string)].Title != "Edited" || byID["8f0c3a1e-6b1d-4d8e-9a57-0c2f1b9d7e11"].Title != "Brand new" {
+		t.Errorf("after upsert: %+v", todos)
+	}
+
+	// Creating from the original export copies every todo under a new ID,
+	// subtasks under the copy of their parent
+	status, result = importFile(t, a, "/api/v1/todos/import", "application/json", string(exported))
+	if status != http.StatusOK || result.Created != 3 || result.Updated != 0 {
+		t.Fatalf("create = %d, %+v", status, result)
+	}
+	created := make(map[string]bool)
+	for _, imported := range result.Todos {
+		if byID[imported.ID].ID != "" || imported.Action != models.EventCreated {
+			t.Errorf("row %d reused %s as %s", imported.Row, imported.ID, imported.Action)
+		}
+		created[imported.ID] = true
+	}
+	var parentCopy string
+	for _, todo := range listTodos(t, a) {
+		if created[todo.ID] && todo.Title == "Move house" {
+			parentCopy = todo.ID
+		}
+	}
+	for _, todo := range listTodos(t, a) {
+		if created[todo.ID] && todo.Title == "Pack books" && todo.ParentID != parentCopy {
+			t.Errorf("copied subtask has parent %s, want %s", todo.ParentID, parentCopy)
+		}
+	}
+	if len(listTodos(t, a)) != 2*len(seeded)+1 {
+		t.Errorf("have %d todos after creating copies", len(listTodos(t, a)))
+	}
+}
+
+func TestImportMaxRows(t *testing.T) {
+	a := newTransferServer(t, 2)
+	if status, result := importFile(t, a, "/api/v1/todos/import", "application/x-ndjson", "{\"title\": \"a\"}\n{\"title\": \"b\"}\n"); status != http.StatusOK || result.Created != 2 {
+		t.Errorf("two rows = %d, %+v", status, result)
+	}
+	status, _, data := transfer(t, a, http.MethodPost, "/api/v1/todos/import", "text/csv", "title\na\nb\nc\n")
+	if status != http.StatusBadRequest || !strings.Contains(string(data), "more than 2 rows") {
+		t.Errorf("three rows = %d, %s", status, data)
+	}
+	if todos := listTodos(t, a); len(todos) != 2 {
+		t.Errorf("have %d todos, want the first import's 2", len(todos))
+	}
+}
@@ -0,0 +1,358 @@
+// Package validation enforces the `validate` struct tags on request models.
+//
+// Rules are comma separated and applied in order:
+//
+//	trim        trim surrounding whitespace from a string, or every string in a slice, in place
+//	required    the value must be present: non-nil, and non-blank for strings
+//	notblank    a string, or every string in a slice, must not be blank
+//	min=N       an integer must be >= N
+//	max=N       an integer must be <= N, a string at most N characters, a slice at most N items
+//	itemmax=N   every string in a slice must be at most N characters
+//	rfc3339     a non-empty string must be an RFC3339 timestamp
+//	duedate     a non-empty string must be an RFC3339 timestamp or a date such as 2006-01-02
+//	maxpast=D   an RFC3339 timestamp or date must not lie more than duration D in the past
+//	url         a non-empty string must be an absolute http or https URL
+//	oneof=A|B   a string, or every string in a slice, must be one of the listed values
+//
+// Pointer fields are optional: every rule except required is skipped when
+// the pointer is nil. Field names in errors are taken from the json tag.
+package validation
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+)
+
+// Now returns the current time; it is a variable so callers can pin the clock
+var Now = time.Now
+
+// rule is a single parsed validate tag entry
+type rule struct {
+	name  string
+	param string
+}
+
+// fieldRules are the rules declared on one struct field
+type fieldRules struct {
+	index int
+	name  string
+	rules []rule
+}
+
+// checkFunc validates a dereferenced value, returning a message or ""
+type checkFunc func(name string, value reflect.Value, param string) string
+
+var checks = map[string]checkFunc{
+	"required": checkRequired,
+	"notblank": checkNotBlank,
+	"min":      checkMin,
+	"max":      checkMax,
+	"itemmax":  checkItemMax,
+	"rfc3339":  checkRFC3339,
+	"duedate":  checkDueDate,
+	"maxpast":  checkMaxPast,
+	"url":      checkURL,
+	"oneof":    checkOneOf,
+}
+
+// cache holds parsed rules per struct type
+var cache sync.Map
+
+// Validate applies the validate tags of the struct pointed to by v and
+// returns a *models.ValidationError listing every failing field, or nil.
+// Rules that modify input, such as trim, update v in place.
+func Validate(v interface{}) error {
+	ptr := reflect.ValueOf(v)
+	if ptr.Kind() != reflect.Ptr || ptr.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validation: Validate requires a pointer to a struct, got %T", v))
+	}
+	value := ptr.Elem()
+
+	fields, err := rulesFor(value.Type())
+	if err != nil {
+		panic(err.Error())
+	}
+
+	var failures []models.FieldError
+	for _, field := range fields {
+		if message := validateField(field, value.Field(field.index)); message != "" {
+			failures = append(failures, models.FieldError{Field: field.name, Message: message})
+		}
+	}
+
+	if len(failures) > 0 {
+		return &models.ValidationError{Fields: failures}
+	}
+	return nil
+}
+
+// validateField applies a field's rules in order and reports the first failure
+func validateField(field fieldRules, value reflect.Value) string {
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			for _, r := range field.rules {
+				if r.name == "required" {
+					return fmt.Sprintf("%s is required", field.name)
+				}
+			}
+			return ""
+		}
+		value = value.Elem()
+	}
+
+	for _, r := range field.rules {
+		if r.name == "trim" {
+			trim(value)
+			continue
+		}
+		if message := checks[r.name](field.name, value, r.param); message != "" {
+			return message
+		}
+	}
+	return ""
+}
+
+// rulesFor parses and caches the validate tags of a struct type
+func rulesFor(t reflect.Type) ([]fieldRules, error) {
+	if cached, ok := cache.Load(t); ok {
+		return cached.([]fieldRules), nil
+	}
+
+	var fields []fieldRules
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("validate")
+		if !ok || tag == "" || tag == "-" {
+			continue
+		}
+
+		field := fieldRules{index: i, name: jsonName(sf)}
+		for _, entry := range strings.Split(tag, ",") {
+			name, param, _ := strings.Cut(strings.TrimSpace(entry), "=")
+			if _, known := checks[name]; !known && name != "trim" {
+				return nil, fmt.Errorf("validation: unknown rule %q on %s.%s", name, t.Name(), sf.Name)
+			}
+			field.rules = append(field.rules, rule{name: name, param: param})
+		}
+		fields = append(fields, field)
+	}
+
+	cache.Store(t, fields)
+	return fields, nil
+}
+
+// jsonName returns the name a field has in JSON payloads
+func jsonName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return sf.Name
+	}
+	return name
+}
+
+func checkRequired(name string, value reflect.Value, _ string) string {
+	if value.Kind() == reflect.String && strings.TrimSpace(value.String()) == "" {
+		return fmt.Sprintf("%s is required", name)
+	}
+	return ""
+}
+
+func checkNotBlank(name string, value reflect.Value, _ string) string {
+	for _, s := range stringsOf(value) {
+		if strings.TrimSpace(s) == "" {
+			return fmt.Sprintf("%s must not be blank", name)
+		}
+	}
+	return ""
+}
+
+func checkMin(name string, value reflect.Value, param string) string {
+	limit, err := strconv.ParseInt(param, 10, 64)
+	if err != nil {
+		panic(fmt.Sprintf("validation: bad min parameter %q on %s", param, name))
+	}
+	if isInt(value) && value.Int() < limit {
+		return fmt.Sprintf("%s must be at least %d", name, limit)
+	}
+	return ""
+}
+
+func checkMax(name string, value reflect.Value, param string) string {
+	limit, err := strconv.ParseInt(param, 10, 64)
+	if err != nil {
+		panic(fmt.Sprintf("validation: bad max parameter %q on %s", param, name))
+	}
+	switch {
+	case isInt(value) && value.Int() > limit:
+		return fmt.Sprintf("%s must be at most %d", name, limit)
+	case value.Kind() == reflect.String && int64(utf8.RuneCountInString(value.String())) > limit:
+		return fmt.Sprintf("%s must be at most %d characters", name, limit)
+	case value.Kind() == reflect.Slice && int64(value.Len()) > limit:
+		return fmt.Sprintf("%s must have at most %d items", name, limit)
+	}
+	return ""
+}
+
+func checkItemMax(name string, value reflect.Value, param string) string {
+	limit, err := strconv.ParseInt(param, 10, 64)
+	if err != nil {
+		panic(fmt.Sprintf("validation: bad itemmax parameter %q on %s", param, name))
+	}
+	if value.Kind() != reflect.Slice {
+		return ""
+	}
+	for _, s := range stringsOf(value) {
+		if int64(utf8.RuneCountInString(s)) > limit {
+			return fmt.Sprintf("each of %s must be at most %d characters", name, limit)
+		}
+	}
+	return ""
+}
+
+func checkRFC3339(name string, value reflect.Value, _ string) string {
+	if value.Kind() != reflect.String || value.String() == "" {
+		return ""
+	}
+	if _, err := time.Parse(time.RFC3339, value.String()); err != nil {
+		return fmt.Sprintf("%s must be an RFC3339 timestamp", name)
+	}
+	return ""
+}
+
+func checkDueDate(name string, value reflect.Value, _ string) string {
+	if value.Kind() != reflect.String || value.String() == "" {
+		return ""
+	}
+	if _, ok := parseTimeOrDate(value.String()); !ok {
+		return fmt.Sprintf("%s must be an RFC3339 timestamp or a date such as 2006-01-02", name)
+	}
+	return ""
+}
+
+// parseTimeOrDate parses an RFC3339 timestamp, or a date as midnight UTC
+func parseTimeOrDate(value string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse(models.DateLayout, value); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+func checkMaxPast(name string, value reflect.Value, param string) string {
+	limit, err := time.ParseDuration(param)
+	if err != nil {
+		panic(fmt.Sprintf("validation: bad maxpast parameter %q on %s", param, name))
+	}
+	if value.Kind() != reflect.String || value.String() == "" {
+		return ""
+	}
+	t, ok := parseTimeOrDate(value.String())
+	if !ok {
+		return "" // reported by rfc3339 or duedate
+	}
+	if t.Before(Now().Add(-limit)) {
+		return fmt.Sprintf("%s must not be more than %s in the past", name, humanDuration(limit))
+	}
+	return ""
+}
+
+func checkURL(name string, value reflect.Value, _ string) string {
+	if value.Kind() != reflect.String || value.String() == "" {
+		return ""
+	}
+	u, err := url.Parse(value.String())
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Sprintf("%s must be an absolute http or https URL", name)
+	}
+	return ""
+}
+
+func checkOneOf(name string, value reflect.Value, param string) string {
+	allowed := strings.Split(param, "|")
+	valid := func(s string) bool {
+		for _, a := range allowed {
+			if s == a {
+				return true
+			}
+		}
+		return false
+	}
+	message := fmt.Sprintf("%s must be one of %s", name, strings.Join(allowed, ", "))
+
+	switch value.Kind() {
+	case reflect.String:
+		if !valid(value.String()) {
+			return message
+		}
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			if elem := value.Index(i); elem.Kind() == reflect.String && !valid(elem.String()) {
+				return message
+			}
+		}
+	}
+	return ""
+}
+
+// trim trims surrounding whitespace from a string, or every string in a slice
+func trim(value reflect.Value) {
+	switch value.Kind() {
+	case reflect.String:
+		if value.CanSet() {
+			value.SetString(strings.TrimSpace(value.String()))
+		}
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			if elem := value.Index(i); elem.Kind() == reflect.String {
+				elem.SetString(strings.TrimSpace(elem.String()))
+			}
+		}
+	}
+}
+
+// stringsOf returns a string, or the strings in a slice, as a list
+func stringsOf(value reflect.Value) []string {
+	switch value.Kind() {
+	case reflect.String:
+		return []string{value.String()}
+	case reflect.Slice:
+		var values []string
+		for i := 0; i < value.Len(); i++ {
+			if elem := value.Index(i); elem.Kind() == reflect.String {
+				values = append(values, elem.String())
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func isInt(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+// humanDuration renders whole days as "N days" and anything else as time.Duration does
+func humanDuration(d time.Duration) string {
+	const day = 24 * time.Hour
+	if d%day == 0 {
+		if d == day {
+			return "1 day"
+		}
+		return fmt.Sprintf("%d days", d/day)
+	}
+	return d.String()
+}
@@ -0,0 +1,155 @@
+package validation_test
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/validation"
+)
+
+// input has a field for every rule
+type input struct {
+	Name     string   `json:"name" validate:"trim,required,max=5"`
+	Nickname *string  `json:"nickname,omitempty" validate:"trim,notblank"`
+	Count    int      `json:"count" validate:"min=1,max=3"`
+	Tags     []string `json:"tags" validate:"max=2,trim,notblank,itemmax=4"`
+	At       string   `json:"at" validate:"rfc3339"`
+	Due      string   `json:"due" validate:"trim,duedate,maxpast=48h"`
+	Hook     string   `json:"hook" validate:"url"`
+	Kind     string   `json:"kind" validate:"oneof=a|b"`
+	Events   []string `json:"events" validate:"oneof=created|deleted"`
+}
+
+// valid returns an input passing every rule
+func valid() input {
+	return input{Name: "ok", Count: 2, Kind: "a"}
+}
+
+func TestRules(t *testing.T) {
+	now := time.Date(2030, 6, 15, 12, 0, 0, 0, time.UTC)
+	validation.Now = func() time.Time { return now }
+	t.Cleanup(func() { validation.Now = time.Now })
+	blank := "  "
+
+	tests := []struct {
+		name    string
+		change  func(in *input)
+		field   string
+		message string // empty when the input is valid
+	}{
+		{"valid", func(in *input) {}, "", ""},
+		{"required", func(in *input) { in.Name = "" }, "name", "name is required"},
+		{"required after trim", func(in *input) { in.Name = " \t" }, "name", "name is required"},
+		{"max characters", func(in *input) { in.Name = "ünïcø" }, "", ""},
+		{"max characters exceeded", func(in *input) { in.Name = "toolong" }, "name", "name must be at most 5 characters"},
+		{"optional pointer", func(in *input) { in.Nickname = nil }, "", ""},
+		{"notblank pointer", func(in *input) { in.Nickname = &blank }, "nickname", "nickname must not be blank"},
+		{"min", func(in *input) { in.Count = 0 }, "count", "count must be at least 1"},
+		{"max", func(in *input) { in.Count = 4 }, "count", "count must be at most 3"},
+		{"max items", func(in *input) { in.Tags = []string{"a", "b", "c"} }, "tags", "tags must have at most 2 items"},
+		{"notblank items", func(in *input) { in.Tags = []string{"a", " "} }, "tags", "tags must not be blank"},
+		{"itemmax", func(in *input) { in.Tags = []string{"abcde"} }, "tags", "each of tags must be at most 4 characters"},
+		{"itemmax after trim", func(in *input) { in.Tags = []string{" abcd "} }, "", ""},
+		{"rfc3339", func(in *input) { in.At = "2030-06-15T10:00:00+02:00" }, "", ""},
+		{"rfc3339 rejects dates", func(in *input) { in.At = "2030-06-15" }, "at", "at must be an RFC3339 timestamp"},
+		{"duedate timestamp", func(in *input) { in.Due = "2030-06-14T12:00:00Z" }, "", ""},
+		{"duedate date", func(in *input) { in.Due = " 2030-06-14 " }, "", ""},
+		{"duedate malformed", func(in *input) { in.Due = "tomorrow" }, "due", "due must be an RFC3339 timestamp or a date such as 2006-01-02"},
+		{"maxpast exceeded", func(in *input) { in.Due = "2030-06-13T11:59:59Z" }, "due", "due must not be more than 2 days in the past"},
+		{"maxpast date", func(in *input) { in.Due = "2030-06-13" }, "due", "due must not be more than 2 days in the past"},
+		{"url", func(in *input) { in.Hook = "https://example.com/hook" }, "", ""},
+		{"url relative", func(in *input) { in.Hook = "/hook" }, "hook", "hook must be an absolute http or https URL"},
+		{"url scheme", func(in *input) { in.Hook = "ftp://example.com" }, "hook", "hook must be an absolute http or https URL"},
+		{"oneof", func(in *input) { in.Kind = "c" }, "kind", "kind must be one of a, b"},
+		{"oneof items", func(in *input) { in.Events = []string{"created", "updated"} }, "events", "events must be one of created, deleted"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := valid()
+			tt.change(&in)
+			err := validation.Validate(&in)
+			if tt.message == "" {
+				if err != nil {
+					t.Fatalf("Validate = %v", err)
+				}
+				return
+			}
+			var validationErr *models.ValidationError
+			if !errors.As(err, &validationErr) || len(validationErr.Fields) != 1 {
+				t.Fatalf("Validate = %v, want one field error", err)
+			}
+			if got := validationErr.Fields[0]; got.Field != tt.field || got.Message != tt.message {
+				t.Errorf("error = %+v, want %s: %s", got, tt.field, tt.message)
+			}
+		})
+	}
+}
+
+func TestEveryFieldIsReported(t *testing.T) {
+	in := input{Name: "", Count: 9, Tags: []string{""}, Kind: "z", Hook: "nope"}
+	err := validation.Validate(&in)
+	var validationErr *models.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Validate = %v", err)
+	}
+	var fields []string
+	for _, field := range validationErr.Fields {
+		fields = append(fields, field.Field)
+	}
+	// In declaration order, one error per field
+	if strings.Join(fields, ",") != "name,count,tags,hook,kind" {
+		t.Errorf("fields = %v", fields)
+	}
+}
+
+func TestTrimIsInPlace(t *testing.T) {
+	nickname := "  Bo "
+	in := valid()
+	in.Name = "  Al  "
+	in.Nickname = &nickname
+	in.Tags = []string{" red", "blue  "}
+	if err := validation.Validate(&in); err != nil {
+		t.Fatal(err)
+	}
+	if in.Name != "Al" || *in.Nickname != "Bo" || !slices.Equal(in.Tags, []string{"red", "blue"}) {
+		t.Errorf("trimmed to %q, %q, %q", in.Name, *in.Nickname, in.Tags)
+	}
+}
+
+func TestBadTagsPanic(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+	}{
+		{"bad min", &struct {
+			N int `validate:"min=one"`
+		}{}},
+		{"bad max", &struct {
+			S string `validate:"max=1.5"`
+		}{}},
+		{"bad itemmax", &struct {
+			S []string `validate:"itemmax="`
+		}{}},
+		{"bad maxpast", &struct {
+			S string `validate:"maxpast=a year"`
+		}{}},
+		{"unknown rule", &struct {
+			S string `validate:"email"`
+		}{}},
+		{"not a pointer", struct{}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Validate did not panic")
+				}
+			}()
+			validation.Validate(tt.v)
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/repositories"
+)
+
+// Options configures a Dispatcher. Zero values select the defaults.
+type Options struct {
+	// Client sends deliveries; by default a client with Timeout
+	Client *http.Client
+	// Timeout bounds each attempt of the default client (default 10s)
+	Timeout time.Duration
+	// MaxAttempts is how often a delivery is tried before it fails (default 8)
+	MaxAttempts int
+	// RetryBase is the delay before the first retry; it doubles with every
+	// further attempt up to MaxRetryDelay (defaults 30s and 1h)
+	RetryBase     time.Duration
+	MaxRetryDelay time.Duration
+	// PollInterval is how often the queue is checked for due retries (default 5s)
+	PollInterval time.Duration
+	// Concurrency bounds the deliveries in flight at once (default 4)
+	Concurrency int
+	// UserAgent is sent with every delivery
+	UserAgent string
+	// Now returns the current time; tests may pin it
+	Now func() time.Time
+}
+
+// claimBatch is how many deliveries are claimed from the queue at once
+const claimBatch = 20
+
+// maxResponseBytes is how much of a response body is read before the
+// connection is released; the body itself is ignored
+const maxResponseBytes = 64 * 1024
+
+// Dispatcher drains the webhook delivery queue, sending every due delivery
+// and rescheduling failures with exponential backoff
+type Dispatcher struct {
+	store repositories.WebhookStore
+	opts  Options
+	lease time.Duration
+	wake  chan struct{}
+}
+
+// NewDispatcher creates a Dispatcher for the queue in store
+func NewDispatcher(store repositories.WebhookStore, opts Options) *Dispatcher {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: opts.Timeout}
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 8
+	}
+	if opts.RetryBase <= 0 {
+		opts.RetryBase = 30 * time.Second
+	}
+	if opts.MaxRetryDelay <= 0 {
+		opts.MaxRetryDelay = time.Hour
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 5 * time.Second
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 4
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	// A claim outlives the slowest attempt, so only a crashed worker's
+	// deliveries are ever picked up again early
+	lease := 2 * opts.Client.Timeout
+	if lease <= 0 {
+		lease = time.Minute
+	}
+
+	return &Dispatcher{
+		store: store,
+		opts:  opts,
+		lease: lease,
+		wake:  make(chan struct{}, 1),
+	}
+}
+
+// Wake asks a running dispatcher to check the queue now, e.g. after
+// enqueuing deliveries. It never blocks.
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers due deliveries until ctx is cancelled. Attempts interrupted
+// by cancellation are not recorded; they are retried once their claim expires.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := d.DeliverDue(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Webhook dispatcher: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// DeliverDue sends every delivery due now and returns how many were attempted
+func (d *Dispatcher) DeliverDue(ctx context.Context) (int, error) {
+	total := 0
+	for ctx.Err() == nil {
+		claimed, err := d.store.ClaimDueDeliveries(d.opts.Now(), d.lease, claimBatch)
+		if err != nil {
+			return total, err
+		}
+
+		d.deliverAll(ctx, claimed)
+		total += len(claimed)
+
+		if len(claimed) < claimBatch {
+			break
+		}
+	}
+	return total, nil
+}
+
+// deliverAll sends deliveries with at most Concurrency in flight
+func (d *Dispatcher) deliverAll(ctx context.Context, deliveries []*models.WebhookDelivery) {
+	hooks := make(map[string]*models.Webhook)
+	sem := make(chan struct{}, d.opts.Concurrency)
+	var wg sync.WaitGroup
+
+	for _, delivery := range deliveries {
+		hook, ok := hooks[delivery.WebhookID]
+		if !ok {
+			var err error
+			hook, err = d.store.GetWebhook(delivery.WebhookID)
+			if err != nil {
+				log.Printf("Webhook dispatcher: %v", err)
+				continue // The claim expires and it is retried
+			}
+			hooks[delivery.WebhookID] = hook
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(delivery *models.WebhookDelivery) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			d.deliver(ctx, hook, delivery)
+		}(delivery)
+	}
+	wg.Wait()
+}
+
+// deliver makes one attempt at delivery and records the outcome
+func (d *Dispatcher) deliver(ctx context.Context, hook *models.Webhook, delivery *models.WebhookDelivery) {
+	if hook == nil || !hook.Active {
+		// Disabled since the delivery was queued; give up without a request
+		delivery.Status = models.DeliveryFailed
+		delivery.LastError = "webhook is disabled"
+		delivery.UpdatedAt = d.opts.Now()
+		if err := d.store.RecordAttempt(delivery, nil); err != nil {
+			log.Printf("Webhook dispatcher: %v", err)
+		}
+		return
+	}
+
+	started := d.opts.Now()
+	status, err := d.send(ctx, hook, delivery, started)
+	if ctx.Err() != nil {
+		return
+	}
+	finished := d.opts.Now()
+
+	delivery.Attempts++
+	delivery.ResponseStatus = status
+	delivery.NextAttemptAt = finished
+	delivery.UpdatedAt = finished
+	attempt := &models.WebhookAttempt{
+		DeliveryID:     delivery.ID,
+		Attempt:        delivery.Attempts,
+		ResponseStatus: status,
+		DurationMillis: finished.Sub(started).Milliseconds(),
+		CreatedAt:      started,
+	}
+
+	switch {
+	case err == nil:
+		delivery.Status = models.DeliverySucceeded
+		delivery.LastError = ""
+	case delivery.Attempts >= d.opts.MaxAttempts:
+		delivery.Status = models.DeliveryFailed
+		delivery.LastError = err.Error()
+		attempt.Error = err.Error()
+	default:
+		delivery.NextAttemptAt = finished.Add(d.backoff(delivery.Attempts))
+		delivery.LastError = err.Error()
+		attempt.Error = err.Error()
+	}
+
+	if err := d.store.RecordAttempt(delivery, attempt); err != nil {
+		log.Printf("Webhook dispatcher: %v", err)
+	}
+}
+
+// send posts the delivery payload, treating any 2xx response as success
+func (d *Dispatcher) send(ctx context.Context, hook *models.Webhook, delivery *models.WebhookDelivery, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("invalid request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, now, delivery.Payload))
+	if d.opts.UserAgent != "" {
+		req.Header.Set("User-Agent", d.opts.UserAgent)
+	}
+
+	resp, err := d.opts.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBytes))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay after the given number of failed attempts
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.opts.RetryBase
+	for i := 1; i < attempts && delay < d.opts.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > d.opts.MaxRetryDelay {
+		delay = d.opts.MaxRetryDelay
+	}
+	return delay
+}
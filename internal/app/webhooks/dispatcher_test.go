@@ -0,0 +1,234 @@
+package webhooks_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/teguh/go-todo-api/internal/app/models"
+	"github.com/teguh/go-todo-api/internal/app/repositories"
+	"github.com/teguh/go-todo-api/internal/app/services"
+	"github.com/teguh/go-todo-api/internal/app/webhooks"
+)
+
+// received is a request the endpoint was sent
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// endpoint is a webhook receiver answering with the statuses queued in
+// replies, then 200
+type endpoint struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	replies  []int
+	received []received
+}
+
+func newEndpoint(t *testing.T, replies ...int) *endpoint {
+	e := &endpoint{replies: replies}
+	e.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		e.received = append(e.received, received{header: r.Header.Clone(), body: body})
+		status := http.StatusOK
+		if len(e.replies) > 0 {
+			status, e.replies = e.replies[0], e.replies[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(e.Close)
+	return e
+}
+
+func (e *endpoint) requests() []received {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]received(nil), e.received...)
+}
+
+// fixture queues deliveries through a WebhookService and sends them with a
+// dispatcher whose clock only moves when the test says so
+type fixture struct {
+	service    *services.WebhookService
+	dispatcher *webhooks.Dispatcher
+	now        time.Time
+}
+
+func newFixture(t *testing.T, endpoint *endpoint) *fixture {
+	t.Helper()
+
+	// Deliveries are queued due at the real time, which the clock starts past
+	store := repositories.NewMemoryWebhookRepository()
+	f := &fixture{service: services.NewWebhookService(store, nil), now: time.Now().Add(time.Second)}
+	f.dispatcher = webhooks.NewDispatcher(store, webhooks.Options{
+		Client:      endpoint.Client(),
+		MaxAttempts: 3,
+		RetryBase:   30 * time.Second,
+		UserAgent:   "Todo API Webhooks",
+		Now:         func() time.Time { return f.now },
+	})
+	return f
+}
+
+// publish queues a created event to every webhook
+func (f *fixture) publish(t *testing.T, id int64) {
+	t.Helper()
+	event := &models.TodoEvent{ID: id, Type: models.EventCreated, TodoID: "t1", Todo: &models.Todo{ID: "t1", Title: "Buy milk"}}
+	if err := f.service.Publish(event); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// deliverDue runs the dispatcher at f.now and checks how many deliveries it attempted
+func (f *fixture) deliverDue(t *testing.T, want int) {
+	t.Helper()
+	attempted, err := f.dispatcher.DeliverDue(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempted != want {
+		t.Fatalf("attempted %d deliveries at %v, want %d", attempted, f.now, want)
+	}
+}
+
+// delivery returns a delivery of hook with its attempt log
+func (f *fixture) delivery(t *testing.T, hookID, id string) *models.WebhookDelivery {
+	t.Helper()
+	delivery, err := f.service.GetDelivery(hookID, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return delivery
+}
+
+func TestDeliveriesAreSigned(t *testing.T) {
+	endpoint := newEndpoint(t)
+	f := newFixture(t, endpoint)
+	hook, err := f.service.CreateWebhook(models.WebhookCreate{URL: endpoint.URL, Secret: "s3cret", Events: []string{models.EventCreated}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.publish(t, 1)
+	f.deliverDue(t, 1)
+
+	requests := endpoint.requests()
+	if len(requests) != 1 {
+		t.Fatalf("endpoint received %d requests, want 1", len(requests))
+	}
+	req := requests[0]
+	if req.header.Get(webhooks.HeaderEvent) != models.EventCreated || req.header.Get("User-Agent") != "Todo API Webhooks" || req.header.Get("Content-Type") != "application/json" {
+		t.Errorf("headers = %v", req.header)
+	}
+	signature, timestamp := req.header.Get(webhooks.HeaderSignature), req.header.Get(webhooks.HeaderTimestamp)
+	if err := webhooks.Verify("s3cret", signature, timestamp, req.body, 5*time.Minute, f.now); err != nil {
+		t.Errorf("Verify = %v", err)
+	}
+	if err := webhooks.Verify("s3cret", signature, timestamp, append(req.body, ' '), 5*time.Minute, f.now); !errors.Is(err, webhooks.ErrInvalidSignature) {
+		t.Errorf("Verify of a changed body = %v, want ErrInvalidSignature", err)
+	}
+	if err := webhooks.Verify("other", signature, timestamp, req.body, 5*time.Minute, f.now); !errors.Is(err, webhooks.ErrInvalidSignature) {
+		t.Errorf("Verify with another secret = %v, want ErrInvalidSignature", err)
+	}
+	if err := webhooks.Verify("s3cret", signature, timestamp, req.body, 5*time.Minute, f.now.Add(10*time.Minute)); !errors.Is(err, webhooks.ErrStaleTimestamp) {
+		t.Errorf("Verify of a replay = %v, want ErrStaleTimestamp", err)
+	}
+
+	delivery := f.delivery(t, hook.ID, req.header.Get(webhooks.HeaderDelivery))
+	if delivery.Status != models.DeliverySucceeded || delivery.Attempts != 1 || delivery.ResponseStatus != http.StatusOK || len(delivery.AttemptLog) != 1 {
+		t.Errorf("delivery = %+v", delivery)
+	}
+}
+
+func TestFailedDeliveriesBackOffAndCanBeRedelivered(t *testing.T) {
+	endpoint := newEndpoint(t, http.StatusInternalServerError, http.StatusBadGateway, http.StatusInternalServerError)
+	f := newFixture(t, endpoint)
+	hook, err := f.service.CreateWebhook(models.WebhookCreate{URL: endpoint.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.publish(t, 1)
+
+	// Retries wait 30s, then 60s, and the third failure is final
+	start := f.now
+	f.deliverDue(t, 1)
+	f.now = start.Add(29 * time.Second)
+	f.deliverDue(t, 0)
+	f.now = start.Add(30 * time.Second)
+	f.deliverDue(t, 1)
+	f.now = start.Add(89 * time.Second)
+	f.deliverDue(t, 0)
+	f.now = start.Add(90 * time.Second)
+	f.deliverDue(t, 1)
+	f.now = start.Add(24 * time.Hour)
+	f.deliverDue(t, 0)
+
+	requests := endpoint.requests()
+	id := requests[0].header.Get(webhooks.HeaderDelivery)
+	failed := f.delivery(t, hook.ID, id)
+	if failed.Status != models.DeliveryFailed || failed.Attempts != 3 || failed.ResponseStatus != http.StatusInternalServerError {
+		t.Fatalf("delivery = %+v", failed)
+	}
+	wantStatuses := []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusInternalServerError}
+	if len(failed.AttemptLog) != len(wantStatuses) {
+		t.Fatalf("attempt log = %+v", failed.AttemptLog)
+	}
+	for i, attempt := range failed.AttemptLog {
+		if attempt.Attempt != i+1 || attempt.ResponseStatus != wantStatuses[i] || attempt.Error == "" {
+			t.Errorf("attempt %d = %+v", i+1, attempt)
+		}
+	}
+
+	// A redelivery sends the same payload again as a new delivery
+	redelivery, err := f.service.Redeliver(hook.ID, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.deliverDue(t, 1)
+	requests = endpoint.requests()
+	if len(requests) != 4 || requests[3].header.Get(webhooks.HeaderDelivery) != redelivery.ID || string(requests[3].body) != string(requests[0].body) {
+		t.Fatalf("redelivered %d requests: %+v", len(requests), requests[len(requests)-1])
+	}
+	if sent := f.delivery(t, hook.ID, redelivery.ID); sent.Status != models.DeliverySucceeded || sent.RedeliveryOf != id || len(sent.AttemptLog) != 1 {
+		t.Errorf("redelivery = %+v", sent)
+	}
+	if original := f.delivery(t, hook.ID, id); original.Status != models.DeliveryFailed || len(original.AttemptLog) != 3 {
+		t.Errorf("original after redelivery = %+v", original)
+	}
+}
+
+func TestDisabledWebhooksAreNotSent(t *testing.T) {
+	endpoint := newEndpoint(t)
+	f := newFixture(t, endpoint)
+	hook, err := f.service.CreateWebhook(models.WebhookCreate{URL: endpoint.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.publish(t, 1)
+	inactive := false
+	if _, err := f.service.UpdateWebhook(hook.ID, models.WebhookUpdate{Active: &inactive}); err != nil {
+		t.Fatal(err)
+	}
+
+	f.deliverDue(t, 1)
+	if requests := endpoint.requests(); len(requests) != 0 {
+		t.Fatalf("sent %d requests to a disabled webhook", len(requests))
+	}
+	deliveries, err := f.service.ListDeliveries(hook.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || deliveries[0].Status != models.DeliveryFailed || deliveries[0].LastError != "webhook is disabled" {
+		t.Errorf("deliveries = %+v", deliveries)
+	}
+}
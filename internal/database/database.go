@@ -6,75 +6,539 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 )
 
-// DB is the database connection
-var DB *sql.DB
+// Supported SQL dialects
+const (
+	DialectSQLite   = "sqlite3"
+	DialectPostgres = "postgres"
+	DialectMemory   = "memory"
+)
+
+// DB is a database handle together with the SQL dialect it speaks.
+// The embedded connection is nil for the memory dialect.
+type DB struct {
+	*sql.DB
+	Dialect string
+}
+
+// Initialize sets up the database connection and creates tables if they don't exist.
+// The backend is chosen by the scheme of databaseURL: sqlite:// (or a bare path)
+// opens a SQLite file, postgres:// or postgresql:// connects to PostgreSQL, and
+// memory:// opens no connection at all, leaving storage to the in-memory store.
+func Initialize(databaseURL string) (*DB, error) {
+	dialect, dsn, err := ParseURL(databaseURL)
+	if err != nil {
+		return nil, err
+	}
 
-// Initialize sets up the database connection and creates tables if they don't exist
-func Initialize(dbPath string) error {
-	// Ensure directory exists
-	dbDir := filepath.Dir(dbPath)
-	if err := os.MkdirAll(dbDir, 0755); err != nil {
-		return fmt.Errorf("failed to create database directory: %w", err)
+	if dialect == DialectMemory {
+		log.Println("Database initialized successfully (in-memory, not persisted)")
+		return &DB{Dialect: dialect}, nil
 	}
 
-	// Open database connection
-	db, err := sql.Open("sqlite3", dbPath+"?_foreign_keys=on")
+	db, err := Open(dialect, dsn)
 	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+		return nil, err
 	}
 
-	// Test the connection
-	if err := db.Ping(); err != nil {
-		return fmt.Errorf("failed to ping database: %w", err)
+	// Create tables
+	if err := CreateTables(db, dialect); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create tables: %w", err)
 	}
 
-	// Set connection pool settings
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(25)
+	log.Printf("Database initialized successfully (%s)", dialect)
+	return &DB{DB: db, Dialect: dialect}, nil
+}
 
-	// Store the database connection
-	DB = db
+// ParseURL splits a database URL into the dialect and the DSN understood by its driver
+func ParseURL(databaseURL string) (dialect string, dsn string, err error) {
+	switch {
+	case databaseURL == "memory://" || databaseURL == "memory:":
+		return DialectMemory, "", nil
+	case strings.HasPrefix(databaseURL, "postgres://"), strings.HasPrefix(databaseURL, "postgresql://"):
+		return DialectPostgres, databaseURL, nil
+	case strings.HasPrefix(databaseURL, "sqlite://"):
+		return DialectSQLite, strings.TrimPrefix(databaseURL, "sqlite://"), nil
+	case strings.HasPrefix(databaseURL, "sqlite:"):
+		return DialectSQLite, strings.TrimPrefix(databaseURL, "sqlite:"), nil
+	case strings.Contains(databaseURL, "://"):
+		return "", "", fmt.Errorf("unsupported database URL scheme: %s", databaseURL)
+	default:
+		// A bare path is treated as a SQLite file
+		return DialectSQLite, databaseURL, nil
+	}
+}
 
-	// Create tables
-	if err := createTables(); err != nil {
-		return fmt.Errorf("failed to create tables: %w", err)
+// Open opens and pings a connection for the given dialect
+func Open(dialect, dsn string) (*sql.DB, error) {
+	var db *sql.DB
+	var err error
+
+	switch dialect {
+	case DialectSQLite:
+		// Ensure directory exists, unless the database does not live in a plain file
+		if !isSQLiteSpecial(dsn) {
+			dbDir := filepath.Dir(dsn)
+			if err := os.MkdirAll(dbDir, 0755); err != nil {
+				return nil, fmt.Errorf("failed to create database directory: %w", err)
+			}
+		}
+		separator := "?"
+		if strings.Contains(dsn, "?") {
+			separator = "&"
+		}
+		// Transactions take the write lock up front; a read-then-write
+		// transaction upgrading its lock could otherwise fail with SQLITE_BUSY
+		db, err = sql.Open("sqlite3", dsn+separator+"_foreign_keys=on&_txlock=immediate")
+	case DialectPostgres:
+		db, err = sql.Open("postgres", dsn)
+	default:
+		return nil, fmt.Errorf("unsupported database dialect: %s", dialect)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	log.Println("Database initialized successfully")
-	return nil
+	// Test the connection
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	// Set connection pool settings. Every connection to a SQLite :memory:
+	// database gets its own private copy, so the pool is pinned to one.
+	maxConns := 25
+	if dialect == DialectSQLite && strings.Contains(dsn, ":memory:") {
+		maxConns = 1
+	}
+	db.SetMaxOpenConns(maxConns)
+	db.SetMaxIdleConns(maxConns)
+
+	return db, nil
 }
 
-// Close closes the database connection
-func Close() {
-	if DB != nil {
-		DB.Close()
+// isSQLiteSpecial reports whether dsn names an in-memory or URI-style SQLite database
+func isSQLiteSpecial(dsn string) bool {
+	return dsn == ":memory:" || strings.HasPrefix(dsn, "file:")
+}
+
+// Close closes the database connection, if there is one
+func (db *DB) Close() error {
+	if db.DB == nil {
+		return nil
 	}
+	return db.DB.Close()
 }
 
-// createTables creates the necessary tables if they don't exist
-func createTables() error {
-	// Create todos table
-	query := `
+// schemas holds the table definitions for each dialect
+var schemas = map[string][]string{
+	DialectSQLite: {
+		`
 	CREATE TABLE IF NOT EXISTS todos (
 		id TEXT PRIMARY KEY,
 		title TEXT NOT NULL,
 		description TEXT,
+		project TEXT NOT NULL DEFAULT '',
+		parent_id TEXT NOT NULL DEFAULT '',
 		completed BOOLEAN NOT NULL DEFAULT 0,
 		priority INTEGER NOT NULL DEFAULT 0,
 		due_date TIMESTAMP,
+		all_day BOOLEAN NOT NULL DEFAULT 0,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		version INTEGER NOT NULL DEFAULT 1
+	);
+	`,
+		`
+	CREATE TABLE IF NOT EXISTS todo_tags (
+		todo_id TEXT NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+		tag TEXT NOT NULL,
+		PRIMARY KEY (todo_id, tag)
+	);
+	`,
+		`CREATE INDEX IF NOT EXISTS idx_todo_tags_tag ON todo_tags (tag);`,
+		`
+	CREATE TABLE IF NOT EXISTS todo_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		type TEXT NOT NULL,
+		todo_id TEXT NOT NULL,
+		actor TEXT NOT NULL DEFAULT '',
+		payload TEXT NOT NULL,
+		previous_project TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		position BIGINT NOT NULL DEFAULT 0
+	);
+	`,
+		`
+	CREATE TABLE IF NOT EXISTS outbox (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		aggregate_id TEXT NOT NULL,
+		event_type TEXT NOT NULL,
+		actor TEXT NOT NULL DEFAULT '',
+		payload TEXT NOT NULL,
+		previous_project TEXT NOT NULL DEFAULT '',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT '',
+		next_attempt_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	`,
+		`
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id TEXT PRIMARY KEY,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		events TEXT NOT NULL DEFAULT '',
+		description TEXT NOT NULL DEFAULT '',
+		active BOOLEAN NOT NULL DEFAULT 1,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	`,
+		`
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id TEXT PRIMARY KEY,
+		webhook_id TEXT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+		event_id INTEGER NOT NULL,
+		event_type TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMP NOT NULL,
+		last_error TEXT NOT NULL DEFAULT '',
+		response_status INTEGER NOT NULL DEFAULT 0,
+		redelivery_of TEXT NOT NULL DEFAULT '',
 		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
 		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 	);
-	`
+	`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries (webhook_id, created_at);`,
+		`
+	CREATE TABLE IF NOT EXISTS webhook_attempts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		delivery_id TEXT NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+		attempt INTEGER NOT NULL,
+		response_status INTEGER NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		duration_ms INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_attempts_delivery ON webhook_attempts (delivery_id);`,
+		`
+	CREATE TABLE IF NOT EXISTS calendar_tokens (
+		user_id TEXT PRIMARY KEY,
+		token_hash TEXT NOT NULL UNIQUE,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	`,
+		`
+	CREATE TABLE IF NOT EXISTS reminders (
+		id TEXT PRIMARY KEY,
+		todo_id TEXT NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+		user_id TEXT NOT NULL DEFAULT '',
+		remind_at TIMESTAMP,
+		before_due TEXT NOT NULL DEFAULT '',
+		channel TEXT NOT NULL,
+		recipient TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL,
+		fire_at TIMESTAMP,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMP,
+		last_error TEXT NOT NULL DEFAULT '',
+		sent_at TIMESTAMP,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	`,
+		`CREATE INDEX IF NOT EXISTS idx_reminders_due ON reminders (status, next_attempt_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_reminders_todo ON reminders (todo_id);`,
+		`
+	CREATE TABLE IF NOT EXISTS preferences (
+		user_id TEXT PRIMARY KEY,
+		email TEXT NOT NULL DEFAULT '',
+		timezone TEXT NOT NULL DEFAULT 'UTC',
+		digest_frequency TEXT NOT NULL DEFAULT 'off',
+		digest_hour INTEGER NOT NULL DEFAULT 7,
+		digest_weekday TEXT NOT NULL DEFAULT 'monday',
+		next_digest_at TIMESTAMP,
+		last_digest_at TIMESTAMP,
+		last_digest_error TEXT NOT NULL DEFAULT '',
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	`,
+		`CREATE INDEX IF NOT EXISTS idx_preferences_digest ON preferences (next_digest_at);`,
+	},
+	DialectPostgres: {
+		`
+	CREATE TABLE IF NOT EXISTS todos (
+		id TEXT PRIMARY KEY,
+		title TEXT NOT NULL,
+		description TEXT,
+		project TEXT NOT NULL DEFAULT '',
+		parent_id TEXT NOT NULL DEFAULT '',
+		completed BOOLEAN NOT NULL DEFAULT FALSE,
+		priority INTEGER NOT NULL DEFAULT 0,
+		due_date TIMESTAMPTZ,
+		all_day BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		version BIGINT NOT NULL DEFAULT 1
+	);
+	`,
+		`
+	CREATE TABLE IF NOT EXISTS todo_tags (
+		todo_id TEXT NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+		tag TEXT NOT NULL,
+		PRIMARY KEY (todo_id, tag)
+	);
+	`,
+		`CREATE INDEX IF NOT EXISTS idx_todo_tags_tag ON todo_tags (tag);`,
+		`
+	CREATE TABLE IF NOT EXISTS todo_events (
+		id BIGSERIAL PRIMARY KEY,
+		type TEXT NOT NULL,
+		todo_id TEXT NOT NULL,
+		actor TEXT NOT NULL DEFAULT '',
+		payload TEXT NOT NULL,
+		previous_project TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		position BIGINT NOT NULL DEFAULT 0
+	);
+	`,
+		`
+	CREATE TABLE IF NOT EXISTS outbox (
+		id BIGSERIAL PRIMARY KEY,
+		aggregate_id TEXT NOT NULL,
+		event_type TEXT NOT NULL,
+		actor TEXT NOT NULL DEFAULT '',
+		payload TEXT NOT NULL,
+		previous_project TEXT NOT NULL DEFAULT '',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT '',
+		next_attempt_at TIMESTAMPTZ NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	`,
+		`
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id TEXT PRIMARY KEY,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		events TEXT NOT NULL DEFAULT '',
+		description TEXT NOT NULL DEFAULT '',
+		active BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	`,
+		`
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id TEXT PRIMARY KEY,
+		webhook_id TEXT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+		event_id BIGINT NOT NULL,
+		event_type TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMPTZ NOT NULL,
+		last_error TEXT NOT NULL DEFAULT '',
+		response_status INTEGER NOT NULL DEFAULT 0,
+		redelivery_of TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries (webhook_id, created_at);`,
+		`
+	CREATE TABLE IF NOT EXISTS webhook_attempts (
+		id BIGSERIAL PRIMARY KEY,
+		delivery_id TEXT NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+		attempt INTEGER NOT NULL,
+		response_status INTEGER NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		duration_ms BIGINT NOT NULL DEFAULT 0,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_attempts_delivery ON webhook_attempts (delivery_id);`,
+		`
+	CREATE TABLE IF NOT EXISTS calendar_tokens (
+		user_id TEXT PRIMARY KEY,
+		token_hash TEXT NOT NULL UNIQUE,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	`,
+		`
+	CREATE TABLE IF NOT EXISTS reminders (
+		id TEXT PRIMARY KEY,
+		todo_id TEXT NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+		user_id TEXT NOT NULL DEFAULT '',
+		remind_at TIMESTAMPTZ,
+		before_due TEXT NOT NULL DEFAULT '',
+		channel TEXT NOT NULL,
+		recipient TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL,
+		fire_at TIMESTAMPTZ,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMPTZ,
+		last_error TEXT NOT NULL DEFAULT '',
+		sent_at TIMESTAMPTZ,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	`,
+		`CREATE INDEX IF NOT EXISTS idx_reminders_due ON reminders (status, next_attempt_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_reminders_todo ON reminders (todo_id);`,
+		`
+	CREATE TABLE IF NOT EXISTS preferences (
+		user_id TEXT PRIMARY KEY,
+		email TEXT NOT NULL DEFAULT '',
+		timezone TEXT NOT NULL DEFAULT 'UTC',
+		digest_frequency TEXT NOT NULL DEFAULT 'off',
+		digest_hour INTEGER NOT NULL DEFAULT 7,
+		digest_weekday TEXT NOT NULL DEFAULT 'monday',
+		next_digest_at TIMESTAMPTZ,
+		last_digest_at TIMESTAMPTZ,
+		last_digest_error TEXT NOT NULL DEFAULT '',
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	`,
+		`CREATE INDEX IF NOT EXISTS idx_preferences_digest ON preferences (next_digest_at);`,
+	},
+}
 
-	_, err := DB.Exec(query)
-	if err != nil {
-		return fmt.Errorf("failed to create todos table: %w", err)
+// column is a column added after its table was first released. Tables
+// created from schemas already have it; older databases get it on startup.
+type column struct {
+	table      string
+	name       string
+	definition string
+}
+
+// addedColumns lists columns to add to existing tables, in order
+var addedColumns = []column{
+	{table: "todos", name: "project", definition: "TEXT NOT NULL DEFAULT ''"},
+	{table: "todos", name: "parent_id", definition: "TEXT NOT NULL DEFAULT ''"},
+	{table: "todos", name: "version", definition: "BIGINT NOT NULL DEFAULT 1"},
+	{table: "todos", name: "all_day", definition: "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{table: "webhook_deliveries", name: "redelivery_of", definition: "TEXT NOT NULL DEFAULT ''"},
+	{table: "todo_events", name: "position", definition: "BIGINT NOT NULL DEFAULT 0"},
+	{table: "outbox", name: "previous_project", definition: "TEXT NOT NULL DEFAULT ''"},
+	{table: "todo_events", name: "previous_project", definition: "TEXT NOT NULL DEFAULT ''"},
+	{table: "reminders", name: "user_id", definition: "TEXT NOT NULL DEFAULT ''"},
+}
+
+// columnIndexes are created once addedColumns exist and stored values are
+// normalized; the statements are valid in every dialect. An event is
+// queued for a webhook once, however often it is published; only
+// redeliveries repeat it.
+var columnIndexes = []string{
+	`CREATE INDEX IF NOT EXISTS idx_todos_parent ON todos (parent_id);`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_deliveries_event ON webhook_deliveries (webhook_id, event_id) WHERE redelivery_of = '';`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_todo_events_position ON todo_events (position);`,
+}
+
+// normalizations rewrite values stored by earlier versions, per dialect;
+// every statement is idempotent. SQLite keeps times as text with the offset
+// they were written with, which only compares and sorts correctly as text
+// once every time is in UTC, as they are now written.
+var normalizations = map[string][]string{
+	DialectSQLite: {
+		sqliteUTC("todos", "due_date"),
+		sqliteUTC("todos", "created_at"),
+		sqliteUTC("todos", "updated_at"),
+		markRedeliveries,
+		positionEvents,
+	},
+	DialectPostgres: {
+		markRedeliveries,
+		positionEvents,
+	},
+}
+
+// positionEvents places the events logged by earlier versions, which were
+// kept in ID order, at their ID
+const positionEvents = `UPDATE todo_events SET position = id WHERE position = 0`
+
+// markRedeliveries marks the deliveries earlier versions queued again for
+// the same webhook and event as redeliveries of the first, so that they
+// fit the unique index of columnIndexes
+const markRedeliveries = `
+	UPDATE webhook_deliveries SET redelivery_of = (
+		SELECT earliest.id FROM webhook_deliveries earliest
+		WHERE earliest.webhook_id = webhook_deliveries.webhook_id AND earliest.event_id = webhook_deliveries.event_id
+		ORDER BY earliest.created_at, earliest.id LIMIT 1
+	)
+	WHERE redelivery_of = '' AND id <> (
+		SELECT earliest.id FROM webhook_deliveries earliest
+		WHERE earliest.webhook_id = webhook_deliveries.webhook_id AND earliest.event_id = webhook_deliveries.event_id
+		ORDER BY earliest.created_at, earliest.id LIMIT 1
+	)
+`
+
+// sqliteUTC returns a statement rewriting the times in table.col that are
+// not in UTC in the format the driver writes, keeping milliseconds
+func sqliteUTC(table, col string) string {
+	utc := fmt.Sprintf("strftime('%%Y-%%m-%%d %%H:%%M:%%f+00:00', %s)", col)
+	return fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s NOT LIKE '%%+00:00' AND %s IS NOT NULL", table, col, utc, col, utc)
+}
+
+// CreateTables creates the necessary tables if they don't exist, adds
+// columns missing from tables created by earlier versions and normalizes
+// the values those versions stored
+func CreateTables(db *sql.DB, dialect string) error {
+	statements, ok := schemas[dialect]
+	if !ok {
+		return fmt.Errorf("unsupported database dialect: %s", dialect)
+	}
+
+	for _, query := range statements {
+		if _, err := db.Exec(query); err != nil {
+			return fmt.Errorf("failed to apply schema: %w", err)
+		}
+	}
+
+	for _, col := range addedColumns {
+		if err := addColumn(db, col); err != nil {
+			return err
+		}
+	}
+
+	for _, query := range normalizations[dialect] {
+		if _, err := db.Exec(query); err != nil {
+			return fmt.Errorf("failed to normalize stored values: %w", err)
+		}
 	}
 
+	for _, query := range columnIndexes {
+		if _, err := db.Exec(query); err != nil {
+			return fmt.Errorf("failed to create index: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// addColumn adds col to its table unless it is already there. Selecting
+// the column is the one existence check both dialects understand.
+func addColumn(db *sql.DB, col column) error {
+	rows, err := db.Query(fmt.Sprintf("SELECT %s FROM %s LIMIT 0", col.name, col.table))
+	if err == nil {
+		return rows.Close()
+	}
+
+	query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", col.table, col.name, col.definition)
+	if _, err := db.Exec(query); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", col.table, col.name, err)
+	}
 	return nil
 }
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -9,8 +10,23 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/gofiber/helmet/v2"
+	"github.com/teguh/go-todo-api/internal/app/identity"
 )
 
+// RequestIDHeader is the header carrying the request ID
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey is the c.Locals key holding the request ID
+const requestIDKey = "requestID"
+
+// RequestID returns the ID assigned to the current request
+func RequestID(c *fiber.Ctx) string {
+	if id, ok := c.Locals(requestIDKey).(string); ok {
+		return id
+	}
+	return c.Get(RequestIDHeader)
+}
+
 // SetupMiddleware sets up all middleware for the application
 func SetupMiddleware(app *fiber.App) {
 	// Recover from panics
@@ -23,13 +39,16 @@ func SetupMiddleware(app *fiber.App) {
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     "http://localhost:3000,http://localhost:8080",
 		AllowMethods:     "GET,POST,PUT,DELETE,PATCH",
-		AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
+		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, Last-Event-ID, X-User-ID, X-Error-Format",
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
 
-	// Compression
+	// Compression, skipped for event streams which must be flushed as they are written
 	app.Use(compress.New(compress.Config{
+		Next: func(c *fiber.Ctx) bool {
+			return strings.Contains(c.Get(fiber.HeaderAccept), "text/event-stream")
+		},
 		Level: compress.LevelBestSpeed,
 	}))
 
@@ -42,9 +61,21 @@ func SetupMiddleware(app *fiber.App) {
 
 	// Request ID
 	app.Use(func(c *fiber.Ctx) error {
-		// Add request ID if not present
-		if c.Get("X-Request-ID") == "" {
-			c.Set("X-Request-ID", time.Now().Format("20060102150405")+"-"+c.IP())
+		// Reuse the caller's request ID or generate one, and echo it back
+		id := c.Get(RequestIDHeader)
+		if id == "" {
+			id = time.Now().Format("20060102150405") + "-" + c.IP()
+		}
+		c.Set(RequestIDHeader, id)
+		c.Locals(requestIDKey, id)
+		return c.Next()
+	})
+
+	// Acting user, made available to services through the user context.
+	// Services may store it, so it must not share Fiber's request buffer.
+	app.Use(func(c *fiber.Ctx) error {
+		if user := strings.Clone(c.Get(identity.UserHeader)); user != "" {
+			c.SetUserContext(identity.WithUser(c.UserContext(), user))
 		}
 		return c.Next()
 	})
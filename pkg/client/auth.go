@@ -0,0 +1,41 @@
+package client
+
+import (
+	"encoding/base64"
+	"net/http"
+)
+
+// Authenticator adds credentials to each request before it is sent,
+// including every retry. Implement it to sign requests or to refresh
+// short-lived tokens.
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// AuthFunc adapts a function to an Authenticator
+type AuthFunc func(req *http.Request) error
+
+// Authenticate calls f(req)
+func (f AuthFunc) Authenticate(req *http.Request) error {
+	return f(req)
+}
+
+// BearerToken sends token in the Authorization header
+func BearerToken(token string) Authenticator {
+	return Header("Authorization", "Bearer "+token)
+}
+
+// BasicAuth sends HTTP basic credentials in the Authorization header
+func BasicAuth(username, password string) Authenticator {
+	credentials := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	return Header("Authorization", "Basic "+credentials)
+}
+
+// Header sends value in the named header, as API gateways expecting keys
+// such as X-API-Key require
+func Header(name, value string) Authenticator {
+	return AuthFunc(func(req *http.Request) error {
+		req.Header.Set(name, value)
+		return nil
+	})
+}
@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// CalendarToken lets calendar apps subscribe to the calendar feed of a user
+type CalendarToken struct {
+	UserID string `json:"user_id"`
+	// Token and FeedURL are only returned by CreateCalendarToken
+	Token     string    `json:"token,omitempty"`
+	FeedURL   string    `json:"feed_url,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateCalendarToken issues a calendar token to the client's user (see
+// WithUser), revoking the one they had. The returned token carries the
+// secret and the feed URL, which are never returned again.
+func (c *Client) CreateCalendarToken(ctx context.Context) (*CalendarToken, error) {
+	var token CalendarToken
+	if _, err := c.do(ctx, http.MethodPost, "/calendar/token", nil, nil, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// GetCalendarToken returns the calendar token of the client's user, without
+// the secret
+func (c *Client) GetCalendarToken(ctx context.Context) (*CalendarToken, error) {
+	var token CalendarToken
+	if _, err := c.do(ctx, http.MethodGet, "/calendar/token", nil, nil, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// DeleteCalendarToken revokes the calendar token of the client's user
+func (c *Client) DeleteCalendarToken(ctx context.Context) error {
+	_, err := c.do(ctx, http.MethodDelete, "/calendar/token", nil, nil, nil)
+	return err
+}
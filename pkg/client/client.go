@@ -0,0 +1,247 @@
+// Package client is a typed Go client for the Todo REST API. It speaks the
+// same JSON as the /api/v1 endpoints, so callers work with Todo and Webhook
+// values and *Error problems instead of hand-rolled requests.
+//
+// Every method takes a context that bounds the whole call, retries
+// included. Requests rejected with 429 or 503 are retried with backoff as
+// configured by WithRetryPolicy, and credentials are added by a pluggable
+// Authenticator. Lists too long for one request are paged through with
+// iterators such as Todos.
+//
+// The WebSocket channel is not wrapped: WatchTodos streams the same change
+// events and the REST methods make the same changes.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// apiPrefix is the path of the API relative to the base URL
+const apiPrefix = "/api/v1"
+
+// Client calls the Todo API. It is safe for concurrent use.
+type Client struct {
+	baseURL   *url.URL
+	http      *http.Client
+	auth      Authenticator
+	user      string
+	userAgent string
+	retry     RetryPolicy
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sends requests with hc instead of a client without timeouts.
+// Timeouts on hc also cut event streams short, so prefer contexts.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.http = hc
+	}
+}
+
+// WithAuth adds credentials to every request with auth
+func WithAuth(auth Authenticator) Option {
+	return func(c *Client) {
+		c.auth = auth
+	}
+}
+
+// WithToken sends token as a bearer token, unless it is empty. It is
+// shorthand for WithAuth(BearerToken(token)).
+func WithToken(token string) Option {
+	return func(c *Client) {
+		if token != "" {
+			c.auth = BearerToken(token)
+		}
+	}
+}
+
+// WithUser sends user in the X-User-ID header, so changes are recorded as
+// theirs
+func WithUser(user string) Option {
+	return func(c *Client) {
+		c.user = user
+	}
+}
+
+// WithUserAgent overrides the User-Agent header
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithRetryPolicy replaces DefaultRetryPolicy
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// New creates a Client for the API served at baseURL, such as
+// http://localhost:3000
+func New(baseURL string, opts ...Option) (*Client, error) {
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q: must be an absolute http or https URL", baseURL)
+	}
+	parsed.Path = strings.TrimSuffix(parsed.Path, "/")
+
+	c := &Client{
+		baseURL:   parsed,
+		http:      &http.Client{},
+		userAgent: "go-todo-api-client",
+		retry:     DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// request describes a call before it is sent, so that it can be sent again
+type request struct {
+	method string
+	// path is relative to the base URL
+	path        string
+	query       url.Values
+	body        []byte
+	contentType string
+	accept      string
+	header      http.Header
+	// allowStatus is an error status whose response is returned like a
+	// success, for endpoints that report failures in their normal body
+	allowStatus int
+}
+
+// jsonRequest returns a request for path, relative to the API prefix, with
+// body encoded as JSON if it is not nil
+func jsonRequest(method, path string, query url.Values, body interface{}) (*request, error) {
+	r := &request{
+		method: method,
+		path:   apiPrefix + path,
+		query:  query,
+		accept: "application/json",
+	}
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request body: %w", err)
+		}
+		r.body = data
+		r.contentType = "application/json"
+	}
+	return r, nil
+}
+
+// newRequest builds an HTTP request for r
+func (c *Client) newRequest(ctx context.Context, r *request) (*http.Request, error) {
+	endpoint := *c.baseURL
+	endpoint.Path += r.path
+	endpoint.RawQuery = r.query.Encode()
+
+	var body io.Reader
+	if r.body != nil {
+		body = bytes.NewReader(r.body)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, endpoint.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range r.header {
+		req.Header[name] = values
+	}
+	if r.contentType != "" {
+		req.Header.Set("Content-Type", r.contentType)
+	}
+	if r.accept != "" {
+		req.Header.Set("Accept", r.accept)
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	if c.user != "" {
+		req.Header.Set("X-User-ID", c.user)
+	}
+	if c.auth != nil {
+		if err := c.auth.Authenticate(req); err != nil {
+			return nil, fmt.Errorf("failed to authenticate request: %w", err)
+		}
+	}
+	return req, nil
+}
+
+// send sends r, retrying as the retry policy allows. Successful responses
+// are returned with their body open for the caller to close; error
+// responses are returned as *Error.
+func (c *Client) send(ctx context.Context, r *request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := c.newRequest(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode < 400 || resp.StatusCode == r.allowStatus {
+			return resp, nil
+		}
+
+		apiErr := decodeError(resp)
+		resp.Body.Close()
+		if !retryable(r.method, resp.StatusCode, apiErr.RetryAfter) {
+			return resp, apiErr
+		}
+		wait, ok := c.retry.backoff(attempt, apiErr.RetryAfter)
+		if !ok {
+			return resp, apiErr
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return resp, apiErr
+		}
+	}
+}
+
+// do sends a JSON request to path, relative to the API prefix, and decodes
+// a successful response into out, if it is not nil. The response is
+// returned for its status and headers; its body is closed.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) (*http.Response, error) {
+	r, err := jsonRequest(method, path, query, body)
+	if err != nil {
+		return nil, err
+	}
+	return c.receive(ctx, r, out)
+}
+
+// receive sends r and decodes a successful response into out, if it is not nil
+func (c *Client) receive(ctx context.Context, r *request, out interface{}) (*http.Response, error) {
+	resp, err := c.send(ctx, r)
+	if err != nil {
+		return resp, err
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return resp, nil
+}
+
+// Ptr returns a pointer to v, for filling in the optional fields of
+// TodoUpdate, ListOptions and the like
+func Ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,315 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/teguh/go-todo-api/config"
+	"github.com/teguh/go-todo-api/internal/app"
+	"github.com/teguh/go-todo-api/pkg/client"
+)
+
+// fiberTransport serves requests with app.Test, so the client talks to the
+// real app without a listener, and remembers the headers last sent
+type fiberTransport struct {
+	app  *fiber.App
+	sent atomic.Pointer[http.Header]
+}
+
+func (t *fiberTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	header := req.Header.Clone()
+	t.sent.Store(&header)
+	return t.app.Test(req, -1)
+}
+
+// newApp returns the app with its default configuration over the in-memory store
+func newApp(t *testing.T) *fiber.App {
+	t.Helper()
+
+	t.Setenv("DATABASE_URL", "memory://")
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := app.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.HTTP.Shutdown() })
+	return server.HTTP
+}
+
+// newClient returns a client of a new app and the transport it sends through
+func newClient(t *testing.T, opts ...client.Option) (*client.Client, *fiberTransport) {
+	t.Helper()
+
+	transport := &fiberTransport{app: newApp(t)}
+	opts = append([]client.Option{client.WithHTTPClient(&http.Client{Transport: transport})}, opts...)
+	c, err := client.New("http://todo.test/", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, transport
+}
+
+func TestTodoLifecycle(t *testing.T) {
+	c, transport := newClient(t, client.WithToken("secret"), client.WithUser("alice"))
+	ctx := context.Background()
+
+	due := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	todo, err := c.CreateTodo(ctx, client.TodoCreate{Title: "Write docs", Tags: []string{"Docs"}, DueDate: &due, Priority: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent := *transport.sent.Load(); sent.Get("Authorization") != "Bearer secret" || sent.Get("X-User-ID") != "alice" {
+		t.Errorf("sent headers %v", sent)
+	}
+	if !todo.DueDate.Equal(due) || len(todo.Tags) != 1 || todo.Tags[0] != "docs" || todo.Version != 1 {
+		t.Fatalf("created %+v", todo)
+	}
+
+	if got, err := c.GetTodo(ctx, todo.ID); err != nil || got.Title != "Write docs" {
+		t.Fatalf("get = %+v, %v", got, err)
+	}
+	if _, err := c.GetTodo(ctx, "missing"); !client.IsNotFound(err) {
+		t.Errorf("get missing: %v, want not found", err)
+	}
+
+	var apiErr *client.Error
+	_, err = c.CreateTodo(ctx, client.TodoCreate{Priority: 9})
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.Type != client.ProblemValidation || len(apiErr.Fields) != 2 || apiErr.RequestID == "" {
+		t.Fatalf("invalid create: %#v", err)
+	}
+
+	updated, err := c.UpdateTodo(ctx, todo.ID, client.TodoUpdate{Completed: client.Ptr(true), ClearDueDate: true})
+	if err != nil || !updated.Completed || updated.DueDate != nil {
+		t.Fatalf("update = %+v, %v", updated, err)
+	}
+	merged, err := c.MergePatchTodo(ctx, todo.ID, map[string]interface{}{"tags": nil, "project": "site"})
+	if err != nil || len(merged.Tags) != 0 || merged.Project != "site" {
+		t.Fatalf("merge patch = %+v, %v", merged, err)
+	}
+	stale := []client.PatchOperation{{Op: "test", Path: "/version", Value: 1}, {Op: "replace", Path: "/title", Value: "Stale"}}
+	if _, err := c.JSONPatchTodo(ctx, todo.ID, stale); !client.IsConflict(err) {
+		t.Errorf("patch of a stale version: %v, want conflict", err)
+	}
+	current := []client.PatchOperation{{Op: "test", Path: "/version", Value: merged.Version}, {Op: "replace", Path: "/title", Value: "Write the docs"}}
+	if patched, err := c.JSONPatchTodo(ctx, todo.ID, current); err != nil || patched.Title != "Write the docs" {
+		t.Fatalf("patch = %+v, %v", patched, err)
+	}
+
+	// An all-day subtask, created with a client-chosen ID, then replaced
+	id := "0b5c0a6e-1111-4222-8333-444455556666"
+	day := time.Date(2030, 3, 4, 0, 0, 0, 0, time.UTC)
+	replaced, created, err := c.ReplaceTodo(ctx, id, client.TodoReplace{Title: "Proofread", ParentID: todo.ID, DueDate: &day, AllDay: true})
+	if err != nil || !created || replaced.ID != id || !replaced.AllDay || !replaced.DueDate.Equal(day) {
+		t.Fatalf("replace = %+v, %t, %v", replaced, created, err)
+	}
+	if _, created, err := c.ReplaceTodo(ctx, id, client.TodoReplace{Title: "Proofread twice", ParentID: todo.ID}); err != nil || created {
+		t.Fatalf("second replace created %t, %v", created, err)
+	}
+	if subtasks, err := c.ListTodos(ctx, client.ListOptions{ParentID: client.Ptr(todo.ID)}); err != nil || len(subtasks) != 1 || subtasks[0].Title != "Proofread twice" {
+		t.Fatalf("subtasks = %v, %v", subtasks, err)
+	}
+
+	// A todo with subtasks cannot be deleted before them
+	if err := c.DeleteTodo(ctx, todo.ID); !client.IsConflict(err) {
+		t.Errorf("delete of a parent: %v, want conflict", err)
+	}
+	if err := c.DeleteTodo(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeleteTodo(ctx, todo.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetTodo(ctx, todo.ID); !client.IsNotFound(err) {
+		t.Errorf("get deleted: %v, want not found", err)
+	}
+}
+
+func TestTodosPagesThroughResults(t *testing.T) {
+	c, _ := newClient(t)
+	ctx := context.Background()
+
+	for i := 0; i < 45; i++ {
+		if _, err := c.CreateTodo(ctx, client.TodoCreate{Title: fmt.Sprintf("todo %02d", i), Project: "bulk"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var titles []string
+	for todo, err := range c.Todos(ctx, client.ListOptions{Project: client.Ptr("bulk"), Sort: client.SortTitle, Limit: 10}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		titles = append(titles, todo.Title)
+	}
+	if len(titles) != 45 || titles[0] != "todo 00" || titles[44] != "todo 44" {
+		t.Errorf("paged through %d todos: %v", len(titles), titles)
+	}
+
+	page, err := c.ListTodosPage(ctx, client.ListOptions{Project: client.Ptr("bulk"), Limit: 10, Offset: 40})
+	if err != nil || page.Total != 45 || len(page.Todos) != 5 {
+		t.Fatalf("last page = %+v, %v", page, err)
+	}
+
+	for _, err := range c.Todos(ctx, client.ListOptions{Sort: "bogus"}) {
+		var apiErr *client.Error
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+			t.Errorf("unknown sort: %v, want 400", err)
+		}
+	}
+}
+
+func TestWebhooksGraphQLAndTransfer(t *testing.T) {
+	c, _ := newClient(t)
+	ctx := context.Background()
+
+	hook, err := c.CreateWebhook(ctx, client.WebhookCreate{URL: "http://127.0.0.1:1/hook", Events: []string{"created"}})
+	if err != nil || hook.Secret == "" {
+		t.Fatalf("create webhook = %+v, %v", hook, err)
+	}
+	if hooks, err := c.ListWebhooks(ctx); err != nil || len(hooks) != 1 || hooks[0].Secret != "" {
+		t.Fatalf("webhooks = %+v, %v", hooks, err)
+	}
+	if paused, err := c.UpdateWebhook(ctx, hook.ID, client.WebhookUpdate{Active: client.Ptr(false)}); err != nil || paused.Active {
+		t.Fatalf("pause = %+v, %v", paused, err)
+	}
+	if _, err := c.Redeliver(ctx, hook.ID, "missing"); !client.IsNotFound(err) {
+		t.Errorf("redeliver missing: %v, want not found", err)
+	}
+	if err := c.DeleteWebhook(ctx, hook.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := c.ImportTodos(ctx, strings.NewReader("Name,tags\nPlan,\"a,b\"\nShip,\n"), client.ImportOptions{
+		Format:  client.FormatCSV,
+		Mapping: map[string]string{"Name": "title"},
+	})
+	if err != nil || !result.Committed || result.Created != 2 {
+		t.Fatalf("import = %+v, %v", result, err)
+	}
+
+	var data struct {
+		Todos struct {
+			TotalCount int `json:"totalCount"`
+			Nodes      []struct {
+				Title string   `json:"title"`
+				Tags  []string `json:"tags"`
+			} `json:"nodes"`
+		} `json:"todos"`
+	}
+	if err := c.GraphQL(ctx, client.GraphQLRequest{Query: `{ todos(sort: TITLE) { totalCount nodes { title tags } } }`}, &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Todos.TotalCount != 2 || data.Todos.Nodes[0].Title != "Plan" || len(data.Todos.Nodes[0].Tags) != 2 {
+		t.Errorf("GraphQL todos = %+v", data.Todos)
+	}
+	var gqlErrs client.GraphQLErrors
+	err = c.GraphQL(ctx, client.GraphQLRequest{Query: `mutation { createTodo(input: {title: ""}) { id } }`}, nil)
+	if !errors.As(err, &gqlErrs) || gqlErrs[0].Code != client.CodeBadUserInput {
+		t.Errorf("invalid mutation: %#v", err)
+	}
+
+	export, err := c.ExportTodos(ctx, client.FormatCSV, client.ListOptions{Sort: client.SortTitle})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer export.Close()
+	csv, err := io.ReadAll(export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(csv)), "\n"); len(lines) != 3 || !strings.Contains(lines[1], "Plan") {
+		t.Errorf("export:\n%s", csv)
+	}
+}
+
+// unavailable answers the first fails requests itself with status, as a
+// proxy in front of the app would, and passes the rest on
+type unavailable struct {
+	next       http.RoundTripper
+	fails      int32
+	status     int
+	retryAfter string
+	calls      atomic.Int32
+}
+
+func (u *unavailable) RoundTrip(req *http.Request) (*http.Response, error) {
+	if u.calls.Add(1) > u.fails {
+		return u.next.RoundTrip(req)
+	}
+	header := http.Header{}
+	if u.retryAfter != "" {
+		header.Set("Retry-After", u.retryAfter)
+	}
+	return &http.Response{
+		StatusCode: u.status,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(http.StatusText(u.status))),
+		Request:    req,
+	}, nil
+}
+
+func TestRetries(t *testing.T) {
+	a := newApp(t)
+	ctx := context.Background()
+	policy := client.RetryPolicy{MaxRetries: 3, MinBackoff: time.Millisecond, MaxBackoff: 2 * time.Second}
+
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		create     bool
+		wantCalls  int32
+	}{
+		{name: "GET on 503", status: http.StatusServiceUnavailable, wantCalls: 3},
+		{name: "POST on 429", status: http.StatusTooManyRequests, create: true, wantCalls: 3},
+		{name: "POST on 503", status: http.StatusServiceUnavailable, create: true, wantCalls: 1},
+		{name: "POST on 503 with Retry-After", status: http.StatusServiceUnavailable, retryAfter: "1", create: true, wantCalls: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &unavailable{next: &fiberTransport{app: a}, fails: 2, status: tt.status, retryAfter: tt.retryAfter}
+			c, err := client.New("http://todo.test/", client.WithHTTPClient(&http.Client{Transport: transport}), client.WithRetryPolicy(policy))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.create {
+				_, err = c.CreateTodo(ctx, client.TodoCreate{Title: "Retried"})
+			} else {
+				_, err = c.ListTodos(ctx, client.ListOptions{})
+			}
+			if calls := transport.calls.Load(); calls != tt.wantCalls {
+				t.Errorf("sent %d times, want %d", calls, tt.wantCalls)
+			}
+			var apiErr *client.Error
+			if tt.wantCalls == 1 && (!errors.As(err, &apiErr) || apiErr.StatusCode != tt.status) {
+				t.Errorf("err = %v, want status %d", err, tt.status)
+			}
+			if tt.wantCalls > 1 && err != nil {
+				t.Errorf("err = %v after retrying", err)
+			}
+		})
+	}
+
+	// A wait beyond MaxBackoff is left to the caller
+	transport := &unavailable{next: &fiberTransport{app: a}, fails: 1, status: http.StatusTooManyRequests, retryAfter: "100"}
+	c, err := client.New("http://todo.test/", client.WithHTTPClient(&http.Client{Transport: transport}), client.WithRetryPolicy(policy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var apiErr *client.Error
+	if _, err := c.ListTodos(ctx, client.ListOptions{}); !errors.As(err, &apiErr) || apiErr.RetryAfter != 100*time.Second || transport.calls.Load() != 1 {
+		t.Errorf("err = %v after %d calls, want Retry-After of 100s after 1", err, transport.calls.Load())
+	}
+}
@@ -0,0 +1,122 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Problem types reported by the API, matching Error.Type
+const (
+	ProblemValidation  = "/problems/validation-error"
+	ProblemInvalidBody = "/problems/invalid-body"
+	ProblemNotFound    = "/problems/not-found"
+	ProblemConflict    = "/problems/conflict"
+	ProblemInternal    = "/problems/internal-error"
+)
+
+// maxErrorBody bounds how much of an error response is read
+const maxErrorBody = 64 << 10
+
+// Error is an error response from the API, decoded from its RFC 9457
+// problem details or, from servers answering in the legacy format, from
+// the message of utils.ErrorResponse
+type Error struct {
+	StatusCode int
+	// Type identifies the class of problem; it is empty for legacy responses
+	Type      string
+	Title     string
+	Detail    string
+	Instance  string
+	RequestID string
+	// Fields lists the invalid fields of validation errors
+	Fields []FieldError
+	// RetryAfter is how long the server asked clients to wait before trying
+	// again, if it said
+	RetryAfter time.Duration
+}
+
+// FieldError describes a single invalid field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error returns the detail of the problem, or its title if there is none
+func (e *Error) Error() string {
+	message := e.Detail
+	if message == "" {
+		message = e.Title
+	}
+	if message == "" {
+		message = http.StatusText(e.StatusCode)
+	}
+	return fmt.Sprintf("todo api: %d %s", e.StatusCode, message)
+}
+
+// IsNotFound reports whether err is an Error for a missing resource
+func IsNotFound(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// IsConflict reports whether err is an Error for a request that conflicts
+// with the current state, such as deleting a todo that has subtasks
+func IsConflict(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict
+}
+
+// decodeError reads the error response resp. Bodies that are neither
+// problem details nor legacy errors, such as those of proxies, are kept as
+// the detail.
+func decodeError(resp *http.Response) *Error {
+	apiErr := &Error{
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get("X-Request-ID"),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	if err != nil {
+		return apiErr
+	}
+
+	var problem struct {
+		Type      string       `json:"type"`
+		Title     string       `json:"title"`
+		Detail    string       `json:"detail"`
+		Instance  string       `json:"instance"`
+		RequestID string       `json:"request_id"`
+		Errors    []FieldError `json:"errors"`
+	}
+	if json.Unmarshal(body, &problem) == nil && (problem.Type != "" || problem.Title != "") {
+		apiErr.Type = problem.Type
+		apiErr.Title = problem.Title
+		apiErr.Detail = problem.Detail
+		apiErr.Instance = problem.Instance
+		if problem.RequestID != "" {
+			apiErr.RequestID = problem.RequestID
+		}
+		apiErr.Fields = problem.Errors
+		return apiErr
+	}
+
+	// utils.ErrorResponse, sent to clients asking for X-Error-Format: legacy
+	var legacy struct {
+		Success *bool  `json:"success"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &legacy) == nil && legacy.Success != nil && !*legacy.Success {
+		apiErr.Title = http.StatusText(resp.StatusCode)
+		apiErr.Detail = legacy.Message
+		return apiErr
+	}
+
+	apiErr.Detail = strings.TrimSpace(string(body))
+	return apiErr
+}
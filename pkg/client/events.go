@@ -0,0 +1,156 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Todo event types
+const (
+	EventCreated = "created"
+	EventUpdated = "updated"
+	EventDeleted = "deleted"
+)
+
+// defaultRetry is how long WatchTodos waits before reconnecting when the
+// server has not said otherwise
+const defaultRetry = 3 * time.Second
+
+// Event records a change to a todo. For deletions Todo is the todo as it
+// was just before. IDs increase monotonically.
+type Event struct {
+	ID        int64     `json:"id"`
+	Type      string    `json:"type"`
+	TodoID    string    `json:"todo_id"`
+	Actor     string    `json:"actor,omitempty"`
+	Todo      *Todo     `json:"todo"`
+	CreatedAt time.Time `json:"created_at"`
+	// PreviousProject is the project an update moved the todo out of
+	PreviousProject string `json:"previous_project,omitempty"`
+}
+
+// WatchOptions selects the events streamed by WatchTodos
+type WatchOptions struct {
+	// After resumes after the event with this ID, replaying the ones since
+	After *int64
+	// User only streams changes made by this user
+	User string
+}
+
+// WatchTodos streams change events to handle until ctx is done or handle
+// returns an error, which is returned. Dropped connections are resumed
+// after the last event received, so no event is missed or repeated, but
+// failing to connect in the first place is reported.
+func (c *Client) WatchTodos(ctx context.Context, opts WatchOptions, handle func(*Event) error) error {
+	lastID := opts.After
+	for attempt := 0; ; attempt++ {
+		retry, err := c.streamEvents(ctx, opts.User, lastID, func(event *Event) error {
+			lastID = &event.ID
+			return handle(event)
+		})
+
+		var apiErr *Error
+		var handleErr handlerError
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case errors.As(err, &handleErr):
+			return handleErr.err
+		case errors.As(err, &apiErr):
+			return err
+		case attempt == 0 && errors.Is(err, errConnect):
+			return err
+		}
+
+		timer := time.NewTimer(retry)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// errConnect wraps failures to open an event stream
+var errConnect = errors.New("failed to connect")
+
+// handlerError wraps an error returned by the handler of WatchTodos so it is
+// not mistaken for a dropped connection
+type handlerError struct {
+	err error
+}
+
+func (e handlerError) Error() string {
+	return e.err.Error()
+}
+
+// streamEvents reads one event stream until it ends, returning how long
+// the server asked clients to wait before reconnecting
+func (c *Client) streamEvents(ctx context.Context, user string, lastID *int64, handle func(*Event) error) (time.Duration, error) {
+	retry := defaultRetry
+
+	r := &request{
+		method: http.MethodGet,
+		path:   apiPrefix + "/todos/events",
+		query:  url.Values{},
+		accept: "text/event-stream",
+		header: http.Header{},
+	}
+	if user != "" {
+		r.query.Set("user", user)
+	}
+	if lastID != nil {
+		r.header.Set("Last-Event-ID", strconv.FormatInt(*lastID, 10))
+	}
+
+	resp, err := c.send(ctx, r)
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return retry, err
+	}
+	if err != nil {
+		return retry, fmt.Errorf("%w: %w", errConnect, err)
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch {
+		case line == "":
+			// A blank line dispatches the event collected so far
+			if data.Len() == 0 {
+				continue
+			}
+			var event Event
+			if err := json.Unmarshal([]byte(data.String()), &event); err != nil {
+				return retry, fmt.Errorf("failed to decode event: %w", err)
+			}
+			data.Reset()
+			if err := handle(&event); err != nil {
+				return retry, handlerError{err}
+			}
+		case field == "data":
+			data.WriteString(value)
+		case field == "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms >= 0 {
+				retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+	return retry, scanner.Err()
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// GraphQL error codes, matching GraphQLError.Code
+const (
+	CodeBadUserInput   = "BAD_USER_INPUT"
+	CodeNotFound       = "NOT_FOUND"
+	CodeConflict       = "CONFLICT"
+	CodeTooComplex     = "QUERY_TOO_COMPLEX"
+	CodeInternalServer = "INTERNAL_SERVER_ERROR"
+)
+
+// GraphQLRequest is a GraphQL operation
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// GraphQLError is one error reported by a GraphQL operation
+type GraphQLError struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+	// Code is one of the Code constants; it is empty for errors found while
+	// parsing or validating the query
+	Code string
+	// Fields lists the invalid fields of BAD_USER_INPUT errors
+	Fields []FieldError
+}
+
+// UnmarshalJSON reads the code and fields from the error's extensions
+func (e *GraphQLError) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Message    string        `json:"message"`
+		Path       []interface{} `json:"path"`
+		Extensions struct {
+			Code   string       `json:"code"`
+			Fields []FieldError `json:"fields"`
+		} `json:"extensions"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*e = GraphQLError{
+		Message: raw.Message,
+		Path:    raw.Path,
+		Code:    raw.Extensions.Code,
+		Fields:  raw.Extensions.Fields,
+	}
+	return nil
+}
+
+// GraphQLErrors are the errors of an operation. Data may still have been
+// returned for the fields that resolved.
+type GraphQLErrors []*GraphQLError
+
+// Error joins the messages of the errors
+func (e GraphQLErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Message
+	}
+	return "graphql: " + strings.Join(messages, "; ")
+}
+
+// GraphQL executes req and decodes its data into out, if it is not nil.
+// Errors of the operation are returned as GraphQLErrors after out is filled
+// in with whatever data was returned.
+func (c *Client) GraphQL(ctx context.Context, req GraphQLRequest, out interface{}) error {
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors GraphQLErrors   `json:"errors"`
+	}
+	if _, err := c.do(ctx, http.MethodPost, "/graphql", nil, req, &result); err != nil {
+		return err
+	}
+
+	if out != nil && len(result.Data) > 0 && string(result.Data) != "null" {
+		if err := json.Unmarshal(result.Data, out); err != nil {
+			return fmt.Errorf("failed to decode data: %w", err)
+		}
+	}
+	if len(result.Errors) > 0 {
+		return result.Errors
+	}
+	return nil
+}
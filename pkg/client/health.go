@@ -0,0 +1,28 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Health is the status reported by the health check endpoint
+type Health struct {
+	Status string    `json:"status"`
+	Time   time.Time `json:"time"`
+}
+
+// Health calls the health check endpoint, which is served outside the API
+// prefix
+func (c *Client) Health(ctx context.Context) (*Health, error) {
+	var health Health
+	r := &request{
+		method: http.MethodGet,
+		path:   "/health",
+		accept: "application/json",
+	}
+	if _, err := c.receive(ctx, r, &health); err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
@@ -0,0 +1,138 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Digest frequencies
+const (
+	DigestOff    = "off"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// Preferences are the settings of a user: the time zone their days are
+// counted in and the email digest of due todos they receive
+type Preferences struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email,omitempty"`
+	// Timezone is an IANA time zone such as Europe/Berlin
+	Timezone        string `json:"timezone"`
+	DigestFrequency string `json:"digest_frequency"`
+	// DigestHour is the hour of the day, in Timezone, digests are sent at
+	DigestHour    int        `json:"digest_hour"`
+	DigestWeekday string     `json:"digest_weekday"`
+	NextDigestAt  *time.Time `json:"next_digest_at,omitempty"`
+	LastDigestAt  *time.Time `json:"last_digest_at,omitempty"`
+	// LastDigestError is why the last attempt at a digest failed, if it did
+	LastDigestError string     `json:"last_digest_error,omitempty"`
+	UpdatedAt       *time.Time `json:"updated_at,omitempty"`
+}
+
+// PreferencesReplace holds the full preferences of a user. Omitted fields
+// are reset to their defaults: UTC, digests off, at 7 on mondays.
+type PreferencesReplace struct {
+	Email           string  `json:"email,omitempty"`
+	Timezone        string  `json:"timezone,omitempty"`
+	DigestFrequency *string `json:"digest_frequency,omitempty"`
+	DigestHour      *int    `json:"digest_hour,omitempty"`
+	DigestWeekday   *string `json:"digest_weekday,omitempty"`
+}
+
+// Digest summarises the open todos of a user that are overdue, due today
+// and due this week, each section grouped by project
+type Digest struct {
+	UserID      string           `json:"user_id,omitempty"`
+	Timezone    string           `json:"timezone"`
+	GeneratedAt time.Time        `json:"generated_at"`
+	Sections    []*DigestSection `json:"sections"`
+	Total       int              `json:"total"`
+}
+
+// DigestSection is one part of a digest; Name is overdue, today or this_week
+type DigestSection struct {
+	Name     string           `json:"name"`
+	Title    string           `json:"title"`
+	Projects []*DigestProject `json:"projects"`
+	Count    int              `json:"count"`
+}
+
+// DigestProject lists the todos of a project in a digest section
+type DigestProject struct {
+	Project string  `json:"project"`
+	Todos   []*Todo `json:"todos"`
+}
+
+// GetPreferences returns the preferences of the client's user (see
+// WithUser), or the defaults if they have set none
+func (c *Client) GetPreferences(ctx context.Context) (*Preferences, error) {
+	var preferences Preferences
+	if _, err := c.do(ctx, http.MethodGet, "/preferences", nil, nil, &preferences); err != nil {
+		return nil, err
+	}
+	return &preferences, nil
+}
+
+// ReplacePreferences replaces the preferences of the client's user
+func (c *Client) ReplacePreferences(ctx context.Context, replace PreferencesReplace) (*Preferences, error) {
+	var preferences Preferences
+	if _, err := c.do(ctx, http.MethodPut, "/preferences", nil, replace, &preferences); err != nil {
+		return nil, err
+	}
+	return &preferences, nil
+}
+
+// DeletePreferences resets the preferences of the client's user to the
+// defaults, which stops their digest
+func (c *Client) DeletePreferences(ctx context.Context) error {
+	_, err := c.do(ctx, http.MethodDelete, "/preferences", nil, nil, nil)
+	return err
+}
+
+// PreviewDigest returns the digest the client's user would be mailed now,
+// without sending it. Days are counted in timezone, or the user's time
+// zone if it is "".
+func (c *Client) PreviewDigest(ctx context.Context, timezone string) (*Digest, error) {
+	var digest Digest
+	if _, err := c.do(ctx, http.MethodGet, "/digest/preview", digestQuery("json", timezone), nil, &digest); err != nil {
+		return nil, err
+	}
+	return &digest, nil
+}
+
+// RenderDigest returns the digest the client's user would be mailed now as
+// the email would carry it, in format html or text
+func (c *Client) RenderDigest(ctx context.Context, format, timezone string) (string, error) {
+	if format != "html" && format != "text" {
+		return "", fmt.Errorf("unsupported format %q", format)
+	}
+
+	resp, err := c.send(ctx, &request{
+		method: http.MethodGet,
+		path:   apiPrefix + "/digest/preview",
+		query:  digestQuery(format, timezone),
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read digest: %w", err)
+	}
+	return string(body), nil
+}
+
+func digestQuery(format, timezone string) url.Values {
+	query := url.Values{"format": {format}}
+	if timezone != "" {
+		query.Set("timezone", timezone)
+	}
+	return query
+}
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Reminder channels
+const (
+	ChannelLog     = "log"
+	ChannelWebhook = "webhook"
+	ChannelEmail   = "email"
+)
+
+// Reminder statuses
+const (
+	ReminderPending = "pending"
+	ReminderSent    = "sent"
+	ReminderFailed  = "failed"
+	ReminderSkipped = "skipped"
+)
+
+// Reminder notifies someone of a todo, either At a set time or Before its
+// due date
+type Reminder struct {
+	ID     string     `json:"id"`
+	TodoID string     `json:"todo_id"`
+	UserID string     `json:"user_id,omitempty"`
+	At     *time.Time `json:"at,omitempty"`
+	// Before is a duration such as "30m" or "24h"
+	Before    string `json:"before,omitempty"`
+	Channel   string `json:"channel"`
+	Recipient string `json:"recipient,omitempty"`
+	Status    string `json:"status"`
+	// FireAt is when the reminder is due; nil while a reminder before the
+	// due date belongs to a todo without one
+	FireAt        *time.Time `json:"fire_at,omitempty"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// ReminderCreate holds the fields of a new reminder. Exactly one of At, an
+// RFC3339 timestamp, and Before must be set.
+type ReminderCreate struct {
+	At        string `json:"at,omitempty"`
+	Before    string `json:"before,omitempty"`
+	Channel   string `json:"channel"`
+	Recipient string `json:"recipient,omitempty"`
+}
+
+// CreateReminder adds a reminder to a todo
+func (c *Client) CreateReminder(ctx context.Context, todoID string, create ReminderCreate) (*Reminder, error) {
+	var reminder Reminder
+	if _, err := c.do(ctx, http.MethodPost, remindersPath(todoID), nil, create, &reminder); err != nil {
+		return nil, err
+	}
+	return &reminder, nil
+}
+
+// ListReminders returns the reminders of a todo, oldest first
+func (c *Client) ListReminders(ctx context.Context, todoID string) ([]*Reminder, error) {
+	var reminders []*Reminder
+	if _, err := c.do(ctx, http.MethodGet, remindersPath(todoID), nil, nil, &reminders); err != nil {
+		return nil, err
+	}
+	return reminders, nil
+}
+
+// GetReminder returns a reminder of a todo
+func (c *Client) GetReminder(ctx context.Context, todoID, id string) (*Reminder, error) {
+	var reminder Reminder
+	if _, err := c.do(ctx, http.MethodGet, remindersPath(todoID)+"/"+url.PathEscape(id), nil, nil, &reminder); err != nil {
+		return nil, err
+	}
+	return &reminder, nil
+}
+
+// DeleteReminder deletes a reminder of a todo
+func (c *Client) DeleteReminder(ctx context.Context, todoID, id string) error {
+	_, err := c.do(ctx, http.MethodDelete, remindersPath(todoID)+"/"+url.PathEscape(id), nil, nil, nil)
+	return err
+}
+
+func remindersPath(todoID string) string {
+	return "/todos/" + url.PathEscape(todoID) + "/reminders"
+}
@@ -0,0 +1,96 @@
+package client
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how requests rejected with 429 Too Many Requests or
+// 503 Service Unavailable are retried. The server has not acted on requests
+// it rate limited, so those are retried whatever their method. A 503 may
+// come from a proxy after the request reached the API, so POST and PATCH,
+// which are not idempotent, are only retried on 503 if the response says
+// when to come back in Retry-After. Waits double from MinBackoff up to
+// MaxBackoff with random jitter, unless Retry-After is given; if that is
+// later than MaxBackoff the error is returned instead, carrying the wait in
+// Error.RetryAfter.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt; zero disables retrying
+	MaxRetries int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy is used by clients created without WithRetryPolicy
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	MinBackoff: 250 * time.Millisecond,
+	MaxBackoff: 10 * time.Second,
+}
+
+// retryable reports whether a request with method may be retried after a
+// response with status and the given Retry-After
+func retryable(method string, status int, retryAfter time.Duration) bool {
+	switch status {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusServiceUnavailable:
+		return retryAfter > 0 || (method != http.MethodPost && method != http.MethodPatch)
+	}
+	return false
+}
+
+// backoff returns how long to wait before retry number attempt (from 0),
+// and false if the request should not be retried
+func (p RetryPolicy) backoff(attempt int, retryAfter time.Duration) (time.Duration, bool) {
+	if attempt >= p.MaxRetries {
+		return 0, false
+	}
+	if retryAfter > 0 {
+		return retryAfter, retryAfter <= p.MaxBackoff
+	}
+
+	wait := p.MinBackoff << attempt
+	if wait <= 0 || wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+	// Full jitter over the upper half keeps clients that failed together
+	// from retrying together
+	half := wait / 2
+	if half > 0 {
+		wait = half + rand.N(half)
+	}
+	return wait, true
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an
+// HTTP date, returning zero if it is absent or invalid
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,437 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Fields todos can be sorted by
+const (
+	SortPriority  = "priority"
+	SortCreatedAt = "created_at"
+	SortUpdatedAt = "updated_at"
+	SortDueDate   = "due_date"
+	SortTitle     = "title"
+)
+
+// Due date windows todos can be listed by, counted in the time zone of
+// ListOptions: todos due before now, today, and in the seven days from today
+const (
+	DueOverdue = "overdue"
+	DueToday   = "today"
+	DueWeek    = "week"
+)
+
+// dateLayout is the format of the due date of all-day todos
+const dateLayout = "2006-01-02"
+
+// Todo is a todo item. ParentID names the todo it is a subtask of. An
+// all-day todo is due on a date rather than at a time; its DueDate is
+// midnight UTC of that date.
+type Todo struct {
+	ID          string     `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Project     string     `json:"project"`
+	ParentID    string     `json:"parent_id,omitempty"`
+	Tags        []string   `json:"tags"`
+	Completed   bool       `json:"completed"`
+	Priority    int        `json:"priority"`
+	DueDate     *time.Time `json:"due_date,omitempty"`
+	AllDay      bool       `json:"all_day"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	// Version is incremented by every change to the todo
+	Version int64 `json:"version"`
+}
+
+// MarshalJSON encodes the todo as the API does, with the date alone as the
+// due_date of an all-day todo
+func (t Todo) MarshalJSON() ([]byte, error) {
+	type plain Todo
+	return json.Marshal(struct {
+		plain
+		DueDate *string `json:"due_date,omitempty"`
+	}{
+		plain:   plain(t),
+		DueDate: formatDueDate(t.DueDate, t.AllDay),
+	})
+}
+
+// UnmarshalJSON decodes a todo, whose due_date is a date alone when it is
+// all-day
+func (t *Todo) UnmarshalJSON(data []byte) error {
+	type plain Todo
+	var decoded struct {
+		*plain
+		DueDate string `json:"due_date"`
+	}
+	decoded.plain = (*plain)(t)
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	t.DueDate = nil
+	if decoded.DueDate == "" {
+		return nil
+	}
+	layout := time.RFC3339
+	if t.AllDay {
+		layout = dateLayout
+	}
+	due, err := time.Parse(layout, decoded.DueDate)
+	if err != nil {
+		return fmt.Errorf("invalid due_date: %w", err)
+	}
+	t.DueDate = &due
+	return nil
+}
+
+// TodoCreate holds the fields of a new todo. Only Title is required. With
+// AllDay the todo is due on the date of DueDate, in its location.
+type TodoCreate struct {
+	Title       string
+	Description string
+	Project     string
+	ParentID    string
+	Tags        []string
+	Priority    int
+	DueDate     *time.Time
+	AllDay      bool
+}
+
+// MarshalJSON encodes the todo as the API expects it
+func (t TodoCreate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Title       string   `json:"title"`
+		Description string   `json:"description,omitempty"`
+		Project     string   `json:"project,omitempty"`
+		ParentID    string   `json:"parent_id,omitempty"`
+		Tags        []string `json:"tags,omitempty"`
+		Priority    int      `json:"priority,omitempty"`
+		DueDate     *string  `json:"due_date,omitempty"`
+	}{
+		Title:       t.Title,
+		Description: t.Description,
+		Project:     t.Project,
+		ParentID:    t.ParentID,
+		Tags:        t.Tags,
+		Priority:    t.Priority,
+		DueDate:     formatDueDate(t.DueDate, t.AllDay),
+	})
+}
+
+// TodoUpdate holds the fields to change on a todo; nil fields are left as
+// they are. An empty ParentID detaches a subtask, Tags replaces every tag
+// and ClearDueDate removes the due date. A DueDate makes the todo all-day,
+// due on its date, with AllDay, and timed otherwise.
+type TodoUpdate struct {
+	Title        *string
+	Description  *string
+	Project      *string
+	ParentID     *string
+	Tags         *[]string
+	Completed    *bool
+	Priority     *int
+	DueDate      *time.Time
+	AllDay       bool
+	ClearDueDate bool
+}
+
+// MarshalJSON encodes the update as the API expects it
+func (u TodoUpdate) MarshalJSON() ([]byte, error) {
+	dueDate := formatDueDate(u.DueDate, u.AllDay)
+	if u.ClearDueDate {
+		dueDate = Ptr("")
+	}
+	return json.Marshal(struct {
+		Title       *string   `json:"title,omitempty"`
+		Description *string   `json:"description,omitempty"`
+		Project     *string   `json:"project,omitempty"`
+		ParentID    *string   `json:"parent_id,omitempty"`
+		Tags        *[]string `json:"tags,omitempty"`
+		Completed   *bool     `json:"completed,omitempty"`
+		Priority    *int      `json:"priority,omitempty"`
+		DueDate     *string   `json:"due_date,omitempty"`
+	}{
+		Title:       u.Title,
+		Description: u.Description,
+		Project:     u.Project,
+		ParentID:    u.ParentID,
+		Tags:        u.Tags,
+		Completed:   u.Completed,
+		Priority:    u.Priority,
+		DueDate:     dueDate,
+	})
+}
+
+// TodoReplace holds the full state of a todo for ReplaceTodo. Fields left
+// zero are reset to their defaults. With AllDay the todo is due on the date
+// of DueDate.
+type TodoReplace struct {
+	Title       string
+	Description string
+	Project     string
+	ParentID    string
+	Tags        []string
+	Completed   bool
+	Priority    int
+	DueDate     *time.Time
+	AllDay      bool
+}
+
+// MarshalJSON encodes the todo as the API expects it
+func (t TodoReplace) MarshalJSON() ([]byte, error) {
+	tags := t.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return json.Marshal(struct {
+		Title       string   `json:"title"`
+		Description string   `json:"description"`
+		Project     string   `json:"project"`
+		ParentID    string   `json:"parent_id,omitempty"`
+		Tags        []string `json:"tags"`
+		Completed   bool     `json:"completed"`
+		Priority    int      `json:"priority"`
+		DueDate     *string  `json:"due_date,omitempty"`
+	}{
+		Title:       t.Title,
+		Description: t.Description,
+		Project:     t.Project,
+		ParentID:    t.ParentID,
+		Tags:        tags,
+		Completed:   t.Completed,
+		Priority:    t.Priority,
+		DueDate:     formatDueDate(t.DueDate, t.AllDay),
+	})
+}
+
+// PatchOperation is one operation of a JSON Patch (RFC 6902), such as
+// {Op: "replace", Path: "/title", Value: "New title"} or
+// {Op: "test", Path: "/completed", Value: false}
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// ListOptions selects the todos returned by ListTodos; zero fields match
+// everything. A non-nil Project or ParentID that is empty selects todos
+// without a project or top-level todos.
+type ListOptions struct {
+	Completed *bool
+	Project   *string
+	Tag       string
+	ParentID  *string
+	// Search matches todos whose title or description contains it, ignoring case
+	Search string
+	// Due is one of the Due constants
+	Due string
+	// Timezone is the IANA time zone days are counted in for Due; the
+	// default is the user's time zone, else UTC
+	Timezone string
+	// Sort is one of the Sort constants; the default is priority, then newest first
+	Sort       string
+	Descending bool
+	// Limit caps the number of todos returned, at most MaxPageSize; zero
+	// returns every match. It is the page size of Todos.
+	Limit int
+	// Offset skips that many matches; it requires a Limit
+	Offset int
+}
+
+// MaxPageSize is the largest Limit the API accepts
+const MaxPageSize = 100
+
+// TodoPage is one page of the todos matching a ListOptions
+type TodoPage struct {
+	Todos []*Todo
+	// Total is the number of todos matching the options across all pages
+	Total int
+}
+
+// values encodes the options as query parameters
+func (o ListOptions) values() url.Values {
+	query := url.Values{}
+	if o.Completed != nil {
+		query.Set("completed", strconv.FormatBool(*o.Completed))
+	}
+	if o.Project != nil {
+		query.Set("project", *o.Project)
+	}
+	if o.Tag != "" {
+		query.Set("tag", o.Tag)
+	}
+	if o.ParentID != nil {
+		query.Set("parent_id", *o.ParentID)
+	}
+	if o.Search != "" {
+		query.Set("search", o.Search)
+	}
+	if o.Due != "" {
+		query.Set("due", o.Due)
+	}
+	if o.Timezone != "" {
+		query.Set("timezone", o.Timezone)
+	}
+	if o.Sort != "" {
+		query.Set("sort", o.Sort)
+	}
+	if o.Descending {
+		query.Set("order", "desc")
+	}
+	if o.Limit > 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Offset > 0 {
+		query.Set("offset", strconv.Itoa(o.Offset))
+	}
+	return query
+}
+
+// CreateTodo creates a todo
+func (c *Client) CreateTodo(ctx context.Context, create TodoCreate) (*Todo, error) {
+	var todo Todo
+	if _, err := c.do(ctx, http.MethodPost, "/todos", nil, create, &todo); err != nil {
+		return nil, err
+	}
+	return &todo, nil
+}
+
+// GetTodo returns the todo with the given ID
+func (c *Client) GetTodo(ctx context.Context, id string) (*Todo, error) {
+	var todo Todo
+	if _, err := c.do(ctx, http.MethodGet, "/todos/"+url.PathEscape(id), nil, nil, &todo); err != nil {
+		return nil, err
+	}
+	return &todo, nil
+}
+
+// ListTodos returns the todos matching opts
+func (c *Client) ListTodos(ctx context.Context, opts ListOptions) ([]*Todo, error) {
+	var todos []*Todo
+	if _, err := c.do(ctx, http.MethodGet, "/todos", opts.values(), nil, &todos); err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+// ListTodosPage returns the page of todos selected by opts with the total
+// number of matches
+func (c *Client) ListTodosPage(ctx context.Context, opts ListOptions) (*TodoPage, error) {
+	page := &TodoPage{}
+	resp, err := c.do(ctx, http.MethodGet, "/todos", opts.values(), nil, &page.Todos)
+	if err != nil {
+		return nil, err
+	}
+
+	page.Total = len(page.Todos)
+	if total := resp.Header.Get("X-Total-Count"); total != "" {
+		if page.Total, err = strconv.Atoi(total); err != nil {
+			return nil, fmt.Errorf("invalid X-Total-Count %q", total)
+		}
+	}
+	return page, nil
+}
+
+// Todos iterates over every todo matching opts, fetching pages of
+// opts.Limit (MaxPageSize if zero) as it goes. Iteration stops at the first
+// error, which is yielded. Todos created or deleted meanwhile may shift
+// later pages, so a todo can be seen twice or missed.
+func (c *Client) Todos(ctx context.Context, opts ListOptions) iter.Seq2[*Todo, error] {
+	return func(yield func(*Todo, error) bool) {
+		if opts.Limit <= 0 {
+			opts.Limit = MaxPageSize
+		}
+		for {
+			page, err := c.ListTodosPage(ctx, opts)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			for _, todo := range page.Todos {
+				if !yield(todo, nil) {
+					return
+				}
+			}
+
+			opts.Offset += len(page.Todos)
+			if len(page.Todos) < opts.Limit || opts.Offset >= page.Total {
+				return
+			}
+		}
+	}
+}
+
+// ReplaceTodo replaces every field of the todo with the given ID, creating
+// it with that ID if it does not exist, which created reports. IDs must be
+// lowercase UUIDs, so clients can create todos offline and sync them later.
+func (c *Client) ReplaceTodo(ctx context.Context, id string, replace TodoReplace) (todo *Todo, created bool, err error) {
+	todo = &Todo{}
+	resp, err := c.do(ctx, http.MethodPut, "/todos/"+url.PathEscape(id), nil, replace, todo)
+	if err != nil {
+		return nil, false, err
+	}
+	return todo, resp.StatusCode == http.StatusCreated, nil
+}
+
+// UpdateTodo changes the fields set in update and returns the todo
+func (c *Client) UpdateTodo(ctx context.Context, id string, update TodoUpdate) (*Todo, error) {
+	var todo Todo
+	if _, err := c.do(ctx, http.MethodPatch, "/todos/"+url.PathEscape(id), nil, update, &todo); err != nil {
+		return nil, err
+	}
+	return &todo, nil
+}
+
+// MergePatchTodo applies a JSON Merge Patch (RFC 7396) to the todo, where
+// nil values clear description, project, parent_id, tags or due_date
+func (c *Client) MergePatchTodo(ctx context.Context, id string, patch map[string]interface{}) (*Todo, error) {
+	return c.patchTodo(ctx, id, "application/merge-patch+json", patch)
+}
+
+// JSONPatchTodo applies a JSON Patch (RFC 6902) to the todo atomically. A
+// failed test operation is reported as a conflict.
+func (c *Client) JSONPatchTodo(ctx context.Context, id string, ops []PatchOperation) (*Todo, error) {
+	return c.patchTodo(ctx, id, "application/json-patch+json", ops)
+}
+
+func (c *Client) patchTodo(ctx context.Context, id, contentType string, patch interface{}) (*Todo, error) {
+	r, err := jsonRequest(http.MethodPatch, "/todos/"+url.PathEscape(id), nil, patch)
+	if err != nil {
+		return nil, err
+	}
+	r.contentType = contentType
+
+	var todo Todo
+	if _, err := c.receive(ctx, r, &todo); err != nil {
+		return nil, err
+	}
+	return &todo, nil
+}
+
+// DeleteTodo deletes the todo with the given ID
+func (c *Client) DeleteTodo(ctx context.Context, id string) error {
+	_, err := c.do(ctx, http.MethodDelete, "/todos/"+url.PathEscape(id), nil, nil, nil)
+	return err
+}
+
+// formatDueDate formats a due date as the API expects it: RFC3339, or the
+// date alone for an all-day todo
+func formatDueDate(due *time.Time, allDay bool) *string {
+	switch {
+	case due == nil:
+		return nil
+	case allDay:
+		return Ptr(due.Format(dateLayout))
+	}
+	return Ptr(due.Format(time.RFC3339))
+}
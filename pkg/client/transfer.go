@@ -0,0 +1,179 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// File formats of ExportTodos and ImportTodos
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+	FormatJSON   = "json"
+	// FormatICS is an iCalendar file with a VTODO per todo
+	FormatICS = "ics"
+	// FormatTodoTxt is a todo.txt file with a todo per line
+	FormatTodoTxt = "todotxt"
+	// FormatMarkdown is a Markdown checklist with a heading per project
+	FormatMarkdown = "markdown"
+)
+
+// Formats of other apps' export files, which ImportTodos can read. They can
+// only be imported in ImportCreate mode, without a Mapping.
+const (
+	// FormatTodoist is a Todoist project exported as CSV, or tasks from the
+	// Todoist API
+	FormatTodoist = "todoist"
+	// FormatTrello is a Trello board exported as JSON
+	FormatTrello = "trello"
+	// FormatMSTodo is Microsoft To Do lists with their tasks, from Microsoft Graph
+	FormatMSTodo = "mstodo"
+)
+
+// Import modes
+const (
+	// ImportCreate creates a todo for every row; IDs in the file only link
+	// subtasks to their parents
+	ImportCreate = "create"
+	// ImportUpsert replaces the todos whose ID exists and creates the rest
+	ImportUpsert = "upsert"
+)
+
+// formatTypes maps each file format to its media type
+var formatTypes = map[string]string{
+	FormatCSV:      "text/csv",
+	FormatNDJSON:   "application/x-ndjson",
+	FormatJSON:     "application/json",
+	FormatICS:      "text/calendar",
+	FormatTodoTxt:  "text/plain",
+	FormatMarkdown: "text/markdown",
+}
+
+// appFormatType is the media type other apps' export files are sent as;
+// the server tells their formats apart by the format parameter
+const appFormatType = "application/octet-stream"
+
+// ImportOptions controls ImportTodos
+type ImportOptions struct {
+	// Format is one of the Format constants
+	Format string
+	// Mode is ImportCreate, the default, or ImportUpsert
+	Mode string
+	// DryRun validates the file and reports what would happen without writing
+	DryRun bool
+	// Mapping maps columns of the file to todo fields, such as
+	// {"Task Name": "title"}. Columns named after a field need no mapping.
+	Mapping map[string]string
+}
+
+// ImportResult reports the outcome of an import. Nothing is written unless
+// every row is valid, so Committed is false whenever Errors is not empty.
+// Warnings do not stop an import.
+type ImportResult struct {
+	Mode      string `json:"mode"`
+	DryRun    bool   `json:"dry_run"`
+	Committed bool   `json:"committed"`
+	Rows      int    `json:"rows"`
+	// Created and Updated count the todos written, or that would be on a dry run
+	Created        int              `json:"created"`
+	Updated        int              `json:"updated"`
+	Todos          []ImportedTodo   `json:"todos"`
+	Errors         []ImportRowError `json:"errors"`
+	Warnings       []ImportRowError `json:"warnings,omitempty"`
+	IgnoredColumns []string         `json:"ignored_columns,omitempty"`
+}
+
+// ImportedTodo tells which todo a row became
+type ImportedTodo struct {
+	// Row numbers the records of the file from 1, not counting a CSV header
+	Row int    `json:"row"`
+	ID  string `json:"id"`
+	// Action is "created" or "updated"
+	Action string `json:"action"`
+}
+
+// ImportRowError describes why a row, or one of its fields, was rejected,
+// or how it was changed
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// ExportTodos returns the todos matching opts as a file in format, which
+// the caller must close. It is streamed as the server reads the todos, so
+// a read error means the export was cut short. Limit and Offset must be zero.
+func (c *Client) ExportTodos(ctx context.Context, format string, opts ListOptions) (io.ReadCloser, error) {
+	contentType, ok := formatTypes[format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+
+	query := opts.values()
+	query.Set("format", format)
+	resp, err := c.send(ctx, &request{
+		method: http.MethodGet,
+		path:   apiPrefix + "/todos/export",
+		query:  query,
+		accept: contentType,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// ImportTodos imports the todos in file. Rows with errors are reported in
+// the result rather than as an error, in which case nothing was written.
+func (c *Client) ImportTodos(ctx context.Context, file io.Reader, opts ImportOptions) (*ImportResult, error) {
+	contentType, ok := formatTypes[opts.Format]
+	if !ok {
+		switch opts.Format {
+		case FormatTodoist, FormatTrello, FormatMSTodo:
+			contentType = appFormatType
+		default:
+			return nil, fmt.Errorf("unsupported format %q", opts.Format)
+		}
+	}
+	// The body is kept in memory so the request can be retried
+	body, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read import file: %w", err)
+	}
+
+	query := url.Values{"format": {opts.Format}}
+	if opts.Mode != "" {
+		query.Set("mode", opts.Mode)
+	}
+	if opts.DryRun {
+		query.Set("dry_run", strconv.FormatBool(true))
+	}
+	columns := make([]string, 0, len(opts.Mapping))
+	for column := range opts.Mapping {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	for _, column := range columns {
+		query.Add("map", column+":"+opts.Mapping[column])
+	}
+
+	var result ImportResult
+	r := &request{
+		method:      http.MethodPost,
+		path:        apiPrefix + "/todos/import",
+		query:       query,
+		body:        body,
+		contentType: contentType,
+		accept:      "application/json",
+		allowStatus: http.StatusUnprocessableEntity,
+	}
+	if _, err := c.receive(ctx, r, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
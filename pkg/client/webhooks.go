@@ -0,0 +1,160 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook is a subscription delivering todo events to an HTTP endpoint
+type Webhook struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// Secret signs deliveries. It is only returned when the webhook is created.
+	Secret string `json:"secret,omitempty"`
+	// Events lists the event types delivered; empty means all of them
+	Events      []string  `json:"events"`
+	Description string    `json:"description"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookCreate holds the fields of a new webhook. A secret is generated
+// when none is given, and webhooks are active unless Active says otherwise.
+type WebhookCreate struct {
+	URL         string   `json:"url"`
+	Secret      string   `json:"secret,omitempty"`
+	Events      []string `json:"events,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Active      *bool    `json:"active,omitempty"`
+}
+
+// WebhookUpdate holds the fields to change on a webhook; nil fields are
+// left as they are
+type WebhookUpdate struct {
+	URL         *string   `json:"url,omitempty"`
+	Secret      *string   `json:"secret,omitempty"`
+	Events      *[]string `json:"events,omitempty"`
+	Description *string   `json:"description,omitempty"`
+	Active      *bool     `json:"active,omitempty"`
+}
+
+// WebhookDelivery is one event queued for delivery to one webhook
+type WebhookDelivery struct {
+	ID        string `json:"id"`
+	WebhookID string `json:"webhook_id"`
+	EventID   int64  `json:"event_id"`
+	EventType string `json:"event_type"`
+	// Payload is the exact request body sent on every attempt
+	Payload  json.RawMessage `json:"payload"`
+	Status   string          `json:"status"`
+	Attempts int             `json:"attempts"`
+	// NextAttemptAt is when a pending delivery is tried next, otherwise when it was last tried
+	NextAttemptAt  time.Time `json:"next_attempt_at"`
+	LastError      string    `json:"last_error,omitempty"`
+	ResponseStatus int       `json:"response_status,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	// AttemptLog is only filled in by GetDelivery
+	AttemptLog []*WebhookAttempt `json:"attempt_log,omitempty"`
+}
+
+// WebhookAttempt records one HTTP request made for a delivery
+type WebhookAttempt struct {
+	ID             int64     `json:"id"`
+	DeliveryID     string    `json:"delivery_id"`
+	Attempt        int       `json:"attempt"`
+	ResponseStatus int       `json:"response_status,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	DurationMillis int64     `json:"duration_ms"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// CreateWebhook creates a webhook. The returned webhook carries its secret,
+// which is never returned again.
+func (c *Client) CreateWebhook(ctx context.Context, create WebhookCreate) (*Webhook, error) {
+	var hook Webhook
+	if _, err := c.do(ctx, http.MethodPost, "/webhooks", nil, create, &hook); err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+// ListWebhooks returns every webhook
+func (c *Client) ListWebhooks(ctx context.Context) ([]*Webhook, error) {
+	var hooks []*Webhook
+	if _, err := c.do(ctx, http.MethodGet, "/webhooks", nil, nil, &hooks); err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+// GetWebhook returns the webhook with the given ID
+func (c *Client) GetWebhook(ctx context.Context, id string) (*Webhook, error) {
+	var hook Webhook
+	if _, err := c.do(ctx, http.MethodGet, webhookPath(id), nil, nil, &hook); err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+// UpdateWebhook changes the fields set in update and returns the webhook
+func (c *Client) UpdateWebhook(ctx context.Context, id string, update WebhookUpdate) (*Webhook, error) {
+	var hook Webhook
+	if _, err := c.do(ctx, http.MethodPatch, webhookPath(id), nil, update, &hook); err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+// DeleteWebhook deletes the webhook with the given ID and its deliveries
+func (c *Client) DeleteWebhook(ctx context.Context, id string) error {
+	_, err := c.do(ctx, http.MethodDelete, webhookPath(id), nil, nil, nil)
+	return err
+}
+
+// ListDeliveries returns the recent deliveries of a webhook, newest first
+func (c *Client) ListDeliveries(ctx context.Context, webhookID string) ([]*WebhookDelivery, error) {
+	var deliveries []*WebhookDelivery
+	if _, err := c.do(ctx, http.MethodGet, webhookPath(webhookID)+"/deliveries", nil, nil, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// GetDelivery returns a delivery of a webhook with its attempt log
+func (c *Client) GetDelivery(ctx context.Context, webhookID, deliveryID string) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	if _, err := c.do(ctx, http.MethodGet, deliveryPath(webhookID, deliveryID), nil, nil, &delivery); err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// Redeliver queues a new delivery with the same payload as an earlier one,
+// whatever its outcome, and returns the new delivery
+func (c *Client) Redeliver(ctx context.Context, webhookID, deliveryID string) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	if _, err := c.do(ctx, http.MethodPost, deliveryPath(webhookID, deliveryID)+"/redeliver", nil, nil, &delivery); err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func webhookPath(id string) string {
+	return "/webhooks/" + url.PathEscape(id)
+}
+
+func deliveryPath(webhookID, deliveryID string) string {
+	return webhookPath(webhookID) + "/deliveries/" + url.PathEscape(deliveryID)
+}
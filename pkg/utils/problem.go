@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"github.com/gofiber/fiber/v2"
+	fiberutils "github.com/gofiber/fiber/v2/utils"
+)
+
+// ProblemContentType is the media type of RFC 9457 problem details
+const ProblemContentType = "application/problem+json"
+
+// ProblemDetails represents an RFC 9457 problem details error response
+type ProblemDetails struct {
+	Type      string         `json:"type"`
+	Title     string         `json:"title"`
+	Status    int            `json:"status"`
+	Detail    string         `json:"detail,omitempty"`
+	Instance  string         `json:"instance,omitempty"`
+	RequestID string         `json:"request_id,omitempty"`
+	Errors    []ProblemError `json:"errors,omitempty"`
+}
+
+// ProblemError describes a single invalid field within a problem response
+type ProblemError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// SendProblem sends a problem details response, defaulting the type to
+// about:blank and the title to the status code's reason phrase
+func SendProblem(c *fiber.Ctx, problem ProblemDetails) error {
+	if problem.Type == "" {
+		problem.Type = "about:blank"
+	}
+	if problem.Title == "" {
+		problem.Title = fiberutils.StatusMessage(problem.Status)
+	}
+
+	return c.Status(problem.Status).JSON(problem, ProblemContentType)
+}
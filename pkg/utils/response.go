@@ -4,7 +4,8 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-// ErrorResponse represents an error response
+// ErrorResponse represents the legacy error response, still sent to clients
+// that request it with the X-Error-Format: legacy header
 type ErrorResponse struct {
 	Success bool   `json:"success"`
 	Message string `json:"message"`
@@ -2,36 +2,25 @@ package main
 
 import (
 	"log"
-	"os"
-	"path/filepath"
 
-	"github.com/joho/godotenv"
+	"github.com/teguh/go-todo-api/config"
 	"github.com/teguh/go-todo-api/internal/database"
 )
 
 func main() {
-	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Println("Warning: .env file not found, using default values")
-	}
-
-	// Get database path from environment or use default
-	dbPath := os.Getenv("DATABASE_PATH")
-	if dbPath == "" {
-		dbPath = "data/todo.db"
-	}
-
-	// Ensure directory exists
-	dbDir := filepath.Dir(dbPath)
-	if err := os.MkdirAll(dbDir, 0755); err != nil {
-		log.Fatalf("Failed to create database directory: %v", err)
+	// Load configuration (DATABASE_URL, falling back to DATABASE_PATH)
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
 	// Initialize database
 	log.Println("Running database migrations...")
-	if err := database.Initialize(dbPath); err != nil {
+	db, err := database.Initialize(cfg.DatabaseURL)
+	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
+	defer db.Close()
 
 	log.Println("Migrations completed successfully!")
 }